@@ -0,0 +1,153 @@
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"karto/objectstore"
+	"karto/parquet"
+	"karto/routeports"
+	"karto/types"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	partitionDateFormat = "2006-01-02"
+	fileTimeFormat      = "20060102T150405Z"
+)
+
+type Options struct {
+	Destination string
+	Interval    time.Duration
+}
+
+// RouteColumns and FindingColumns are the schemas of the rows built by RouteRow and FindingRow
+var RouteColumns = []parquet.Column{
+	{Name: "analyzed_at", Type: parquet.Timestamp},
+	{Name: "source_namespace", Type: parquet.String},
+	{Name: "source_pod", Type: parquet.String},
+	{Name: "target_namespace", Type: parquet.String},
+	{Name: "target_pod", Type: parquet.String},
+	{Name: "ports", Type: parquet.String},
+	{Name: "all_ports", Type: parquet.Boolean},
+	{Name: "egress_policies", Type: parquet.String},
+	{Name: "ingress_policies", Type: parquet.String},
+	{Name: "warnings", Type: parquet.String},
+	{Name: "intents", Type: parquet.String},
+	{Name: "risk_score", Type: parquet.Int64},
+}
+
+var FindingColumns = []parquet.Column{
+	{Name: "analyzed_at", Type: parquet.Timestamp},
+	{Name: "fingerprint", Type: parquet.String},
+	{Name: "rule", Type: parquet.String},
+	{Name: "severity", Type: parquet.String},
+	{Name: "resource_kind", Type: parquet.String},
+	{Name: "resource_namespace", Type: parquet.String},
+	{Name: "resource_name", Type: parquet.String},
+	{Name: "peer_kind", Type: parquet.String},
+	{Name: "peer_namespace", Type: parquet.String},
+	{Name: "peer_name", Type: parquet.String},
+	{Name: "message", Type: parquet.String},
+	{Name: "fixable", Type: parquet.Boolean},
+}
+
+type exporter struct {
+	store              objectstore.Store
+	options            Options
+	mutex              sync.Mutex
+	lastAnalysisResult *types.AnalysisResult
+	now                func() time.Time
+}
+
+func newExporter(store objectstore.Store, options Options) *exporter {
+	return &exporter{
+		store:   store,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+func Export(store objectstore.Store, options Options, resultsChannel <-chan types.AnalysisResult) {
+	exporter := newExporter(store, options)
+	go exporter.exportPeriodically()
+	for {
+		analysisResult := <-resultsChannel
+		exporter.mutex.Lock()
+		exporter.lastAnalysisResult = &analysisResult
+		exporter.mutex.Unlock()
+	}
+}
+
+func (exporter *exporter) exportPeriodically() {
+	for {
+		time.Sleep(exporter.options.Interval)
+		exporter.mutex.Lock()
+		lastAnalysisResult := exporter.lastAnalysisResult
+		exporter.mutex.Unlock()
+		if lastAnalysisResult == nil {
+			continue
+		}
+		err := exporter.export(*lastAnalysisResult)
+		if err != nil {
+			log.Printf("Unable to export the analysis result to %s: %s\n", exporter.options.Destination, err)
+		}
+	}
+}
+
+func (exporter *exporter) export(analysisResult types.AnalysisResult) error {
+	analyzedAt := exporter.now().UTC()
+	routeRows := make([][]interface{}, 0)
+	for _, route := range analysisResult.AllowedRoutes {
+		routeRows = append(routeRows, RouteRow(analyzedAt, route))
+	}
+	err := exporter.put("routes", analyzedAt, RouteColumns, routeRows)
+	if err != nil {
+		return err
+	}
+	findingRows := make([][]interface{}, 0)
+	for _, finding := range analysisResult.Findings {
+		findingRows = append(findingRows, FindingRow(analyzedAt, finding))
+	}
+	return exporter.put("findings", analyzedAt, FindingColumns, findingRows)
+}
+
+func RouteRow(analyzedAt time.Time, route *types.AllowedRoute) []interface{} {
+	return []interface{}{analyzedAt, route.SourcePod.Namespace, route.SourcePod.Name, route.TargetPod.Namespace,
+		route.TargetPod.Name, strings.Join(routeports.Of(route).Strings(), ","), route.Ports == nil,
+		joinPolicies(route.EgressPolicies), joinPolicies(route.IngressPolicies), strings.Join(route.Warnings, "\n"),
+		strings.Join(route.Intents, ","), int64(route.RiskScore)}
+}
+
+func FindingRow(analyzedAt time.Time, finding *types.Finding) []interface{} {
+	peer := types.ResourceRef{}
+	if finding.Peer != nil {
+		peer = *finding.Peer
+	}
+	return []interface{}{analyzedAt, finding.Fingerprint, finding.Rule, finding.Severity, finding.Resource.Kind,
+		finding.Resource.Namespace, finding.Resource.Name, peer.Kind, peer.Namespace, peer.Name, finding.Message,
+		finding.Remediation != nil}
+}
+
+// Files are partitioned by day, as expected by most lakehouse engines
+func (exporter *exporter) put(table string, analyzedAt time.Time, columns []parquet.Column,
+	rows [][]interface{}) error {
+	var content bytes.Buffer
+	err := parquet.Write(&content, columns, rows)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s/date=%s/%s-%s.parquet", table, analyzedAt.Format(partitionDateFormat), table,
+		analyzedAt.Format(fileTimeFormat))
+	return exporter.store.Put(key, content.Bytes())
+}
+
+func joinPolicies(policies []types.NetworkPolicy) string {
+	formattedPolicies := make([]string, 0)
+	for _, policy := range policies {
+		formattedPolicies = append(formattedPolicies, policy.Namespace+"/"+policy.Name)
+	}
+	return strings.Join(formattedPolicies, ",")
+}
@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"karto/parquet"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	objects map[string][]byte
+}
+
+func (store *mockStore) Put(key string, content []byte) error {
+	store.objects[key] = content
+	return nil
+}
+
+func (store *mockStore) List(string) ([]string, error) {
+	return nil, nil
+}
+
+func (store *mockStore) Delete(string) error {
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	analyzedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns1"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns2"}
+	analysisResult := types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{80, 443},
+				IngressPolicies: []types.NetworkPolicy{{Name: "in1", Namespace: "ns2"}, {Name: "in2", Namespace: "ns2"}},
+				Warnings:        []string{"warning"}},
+			{SourcePod: podRef2, TargetPod: podRef1, Ports: nil, Intents: []string{"backup"}, RiskScore: 3},
+			{SourcePod: podRef2, TargetPod: podRef2, Ports: []int32{9090},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+		},
+		Findings: []*types.Finding{
+			{Fingerprint: "abc", Rule: "rule", Severity: "high",
+				Resource: types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns1"},
+				Peer:     &types.ResourceRef{Kind: "Pod", Name: "pod2", Namespace: "ns2"}, Message: "msg",
+				Remediation: &types.Remediation{Operation: "create"}},
+		},
+	}
+	expectedRoutes := expectedParquet(t, RouteColumns, [][]interface{}{
+		{analyzedAt, "ns1", "pod1", "ns2", "pod2", "80,443", false, "", "ns2/in1,ns2/in2", "warning", "", int64(0)},
+		{analyzedAt, "ns2", "pod2", "ns1", "pod1", "", true, "", "", "", "backup", int64(3)},
+		{analyzedAt, "ns2", "pod2", "ns2", "pod2", "8000-8100,9090", false, "", "", "", "", int64(0)},
+	})
+	expectedFindings := expectedParquet(t, FindingColumns, [][]interface{}{
+		{analyzedAt, "abc", "rule", "high", "Pod", "ns1", "pod1", "Pod", "ns2", "pod2", "msg", true},
+	})
+	store := &mockStore{objects: make(map[string][]byte)}
+	exporter := newExporter(store, Options{})
+	exporter.now = func() time.Time { return analyzedAt }
+	err := exporter.export(analysisResult)
+	if err != nil {
+		t.Fatalf("export() returned an error: %s", err)
+	}
+	expectedObjects := map[string][]byte{
+		"routes/date=2021-04-01/routes-20210401T120000Z.parquet":     expectedRoutes,
+		"findings/date=2021-04-01/findings-20210401T120000Z.parquet": expectedFindings,
+	}
+	if diff := cmp.Diff(expectedObjects, store.objects); diff != "" {
+		t.Errorf("export() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func expectedParquet(t *testing.T, columns []parquet.Column, rows [][]interface{}) []byte {
+	var content bytes.Buffer
+	err := parquet.Write(&content, columns, rows)
+	if err != nil {
+		t.Fatalf("unable to write the expected parquet file: %s", err)
+	}
+	return content.Bytes()
+}
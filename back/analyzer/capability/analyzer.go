@@ -0,0 +1,82 @@
+package capability
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	"karto/types"
+)
+
+const (
+	adminNetworkPolicyGroup = "policy.networking.k8s.io"
+	calicoPolicyGroup       = "crd.projectcalico.org"
+	ciliumPolicyGroup       = "cilium.io"
+)
+
+var (
+	sctpMinVersion    = utilversion.MustParseGeneric("1.19")
+	endPortMinVersion = utilversion.MustParseGeneric("1.22")
+)
+
+type ClusterState struct {
+	ServerVersion *version.Info
+	APIGroups     []metav1.APIGroup
+}
+
+type AnalysisResult struct {
+	Capabilities types.ClusterCapabilities
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	serverVersion := analyzer.parseServerVersion(clusterState.ServerVersion)
+	capabilities := types.ClusterCapabilities{
+		SCTP:               analyzer.supportedSince(serverVersion, sctpMinVersion),
+		EndPort:            analyzer.supportedSince(serverVersion, endPortMinVersion),
+		AdminNetworkPolicy: analyzer.groupServed(clusterState.APIGroups, adminNetworkPolicyGroup),
+		// Ordered policies of these engines are only evaluated by the route explanations
+		CalicoPolicies: analyzer.groupServed(clusterState.APIGroups, calicoPolicyGroup),
+		CiliumPolicies: analyzer.groupServed(clusterState.APIGroups, ciliumPolicyGroup),
+	}
+	if serverVersion != nil {
+		capabilities.ServerVersion = serverVersion.String()
+	}
+	return AnalysisResult{
+		Capabilities: capabilities,
+	}
+}
+
+func (analyzer analyzerImpl) parseServerVersion(serverVersion *version.Info) *utilversion.Version {
+	if serverVersion == nil {
+		return nil
+	}
+	parsedVersion, err := utilversion.ParseGeneric(serverVersion.GitVersion)
+	if err != nil {
+		return nil
+	}
+	return parsedVersion
+}
+
+func (analyzer analyzerImpl) supportedSince(serverVersion *utilversion.Version,
+	minVersion *utilversion.Version) bool {
+	// Without any version information, the latest semantics are applied
+	return serverVersion == nil || serverVersion.AtLeast(minVersion)
+}
+
+func (analyzer analyzerImpl) groupServed(apiGroups []metav1.APIGroup, groupName string) bool {
+	for _, apiGroup := range apiGroups {
+		if apiGroup.Name == groupName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package capability
+
+import (
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		clusterState ClusterState
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "latest semantics are assumed when the server version is unknown",
+			args: args{
+				clusterState: ClusterState{},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Capabilities: types.ClusterCapabilities{
+					ServerVersion:      "",
+					SCTP:               true,
+					EndPort:            true,
+					AdminNetworkPolicy: false,
+				},
+			},
+		},
+		{
+			name: "old servers support neither SCTP nor endPort",
+			args: args{
+				clusterState: ClusterState{
+					ServerVersion: &version.Info{GitVersion: "v1.18.20"},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Capabilities: types.ClusterCapabilities{
+					ServerVersion:      "1.18.20",
+					SCTP:               false,
+					EndPort:            false,
+					AdminNetworkPolicy: false,
+				},
+			},
+		},
+		{
+			name: "SCTP is supported before endPort",
+			args: args{
+				clusterState: ClusterState{
+					ServerVersion: &version.Info{GitVersion: "v1.21.14-eks-18ef993"},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Capabilities: types.ClusterCapabilities{
+					ServerVersion:      "1.21.14",
+					SCTP:               true,
+					EndPort:            false,
+					AdminNetworkPolicy: false,
+				},
+			},
+		},
+		{
+			name: "admin network policies are supported when their API group is served",
+			args: args{
+				clusterState: ClusterState{
+					ServerVersion: &version.Info{GitVersion: "v1.29.0"},
+					APIGroups: []metav1.APIGroup{
+						{Name: "apps"},
+						{Name: "policy.networking.k8s.io"},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Capabilities: types.ClusterCapabilities{
+					ServerVersion:      "1.29.0",
+					SCTP:               true,
+					EndPort:            true,
+					AdminNetworkPolicy: true,
+				},
+			},
+		},
+		{
+			name: "calico and cilium policies are reported when their API groups are served",
+			args: args{
+				clusterState: ClusterState{
+					ServerVersion: &version.Info{GitVersion: "v1.29.0"},
+					APIGroups: []metav1.APIGroup{
+						{Name: "crd.projectcalico.org"},
+						{Name: "cilium.io"},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Capabilities: types.ClusterCapabilities{
+					ServerVersion:      "1.29.0",
+					SCTP:               true,
+					EndPort:            true,
+					AdminNetworkPolicy: false,
+					CalicoPolicies:     true,
+					CiliumPolicies:     true,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
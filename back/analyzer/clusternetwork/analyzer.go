@@ -0,0 +1,271 @@
+package clusternetwork
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/config"
+	"karto/types"
+	"net"
+	"strings"
+)
+
+const (
+	ScopeInternal = "internal"
+	ScopeExternal = "external"
+	ScopeMixed    = "mixed"
+)
+
+const (
+	serviceCIDRFlag = "--service-cluster-ip-range="
+	podCIDRFlag     = "--cluster-cidr="
+)
+
+type ClusterState struct {
+	Pods            []*corev1.Pod
+	Nodes           []*corev1.Node
+	Services        []*corev1.Service
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+type AnalysisResult struct {
+	Network          *types.ClusterNetwork
+	IPBlocks         []*types.IPBlockScope
+	InternetAccesses []*types.InternetAccess
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	networkConfig config.NetworkConfig
+}
+
+func NewAnalyzer(networkConfig *config.NetworkConfig) Analyzer {
+	analyzer := analyzerImpl{}
+	if networkConfig != nil {
+		analyzer.networkConfig = *networkConfig
+	}
+	return analyzer
+}
+
+// Analyze classifies the ipBlock peers of the policies against the ranges of the cluster, so that only the addresses
+// outside of these ranges let pods reach or be reached from the internet, as do the load balancers letting clients
+// in. Without any known range, every ipBlock is external.
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	network := analyzer.networkOf(clusterState)
+	clusterCIDRs := parseCIDRs(append(append([]string{}, network.PodCIDRs...), network.ServiceCIDRs...))
+	ipBlocks := make([]*types.IPBlockScope, 0)
+	scopes := make(map[*networkingv1.IPBlock]string)
+	for _, policy := range clusterState.NetworkPolicies {
+		for _, rule := range policy.Spec.Ingress {
+			ipBlocks = analyzer.appendIPBlockScopes(ipBlocks, scopes, policy, networkingv1.PolicyTypeIngress,
+				rule.From, clusterCIDRs, clusterState.Pods)
+		}
+		for _, rule := range policy.Spec.Egress {
+			ipBlocks = analyzer.appendIPBlockScopes(ipBlocks, scopes, policy, networkingv1.PolicyTypeEgress,
+				rule.To, clusterCIDRs, clusterState.Pods)
+		}
+	}
+	internetAccesses := make([]*types.InternetAccess, 0)
+	nodeIPs := nodeIPsOf(clusterState.Nodes)
+	for _, pod := range clusterState.Pods {
+		ingress, egress := analyzer.internetAccessOf(pod, clusterState.NetworkPolicies, scopes)
+		loadBalancers := analyzer.loadBalancerAccessesOf(pod, clusterState.Services, clusterState.NetworkPolicies,
+			nodeIPs)
+		for _, loadBalancer := range loadBalancers {
+			ingress = ingress || len(loadBalancer.ClientCIDRs) > 0
+		}
+		if ingress || egress {
+			internetAccesses = append(internetAccesses, &types.InternetAccess{
+				Pod:           types.PodRef{Name: pod.Name, Namespace: pod.Namespace},
+				Egress:        egress,
+				Ingress:       ingress,
+				LoadBalancers: loadBalancers,
+			})
+		}
+	}
+	return AnalysisResult{
+		Network:          network,
+		IPBlocks:         ipBlocks,
+		InternetAccesses: internetAccesses,
+	}
+}
+
+// networkOf detects the ranges which are not configured: service ranges from the flags of the API server, and pod
+// ranges from those of the controller manager or else from the ranges allocated to the nodes
+func (analyzer analyzerImpl) networkOf(clusterState ClusterState) *types.ClusterNetwork {
+	network := &types.ClusterNetwork{
+		PodCIDRs:     analyzer.networkConfig.PodCIDRs,
+		ServiceCIDRs: analyzer.networkConfig.ServiceCIDRs,
+	}
+	if len(network.ServiceCIDRs) == 0 {
+		network.ServiceCIDRs = analyzer.controlPlaneFlag(clusterState.Pods, "kube-apiserver", serviceCIDRFlag)
+	}
+	if len(network.PodCIDRs) == 0 {
+		network.PodCIDRs = analyzer.controlPlaneFlag(clusterState.Pods, "kube-controller-manager", podCIDRFlag)
+	}
+	if len(network.PodCIDRs) == 0 {
+		for _, node := range clusterState.Nodes {
+			nodeCIDRs := node.Spec.PodCIDRs
+			if len(nodeCIDRs) == 0 && node.Spec.PodCIDR != "" {
+				nodeCIDRs = []string{node.Spec.PodCIDR}
+			}
+			network.PodCIDRs = appendUnique(network.PodCIDRs, nodeCIDRs...)
+		}
+	}
+	if network.PodCIDRs == nil {
+		network.PodCIDRs = make([]string, 0)
+	}
+	if network.ServiceCIDRs == nil {
+		network.ServiceCIDRs = make([]string, 0)
+	}
+	return network
+}
+
+// controlPlaneFlag returns the comma separated values of a flag of the static pods of a control plane component,
+// labeled as such by kubeadm
+func (analyzer analyzerImpl) controlPlaneFlag(pods []*corev1.Pod, component string, flag string) []string {
+	var values []string
+	for _, pod := range pods {
+		if pod.Labels["component"] != component {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, arg := range append(append([]string{}, container.Command...), container.Args...) {
+				if strings.HasPrefix(arg, flag) {
+					values = appendUnique(values, strings.Split(strings.TrimPrefix(arg, flag), ",")...)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func (analyzer analyzerImpl) appendIPBlockScopes(ipBlocks []*types.IPBlockScope,
+	scopes map[*networkingv1.IPBlock]string, policy *networkingv1.NetworkPolicy, direction networkingv1.PolicyType,
+	peers []networkingv1.NetworkPolicyPeer, clusterCIDRs []*net.IPNet, pods []*corev1.Pod) []*types.IPBlockScope {
+	for _, peer := range peers {
+		if peer.IPBlock == nil {
+			continue
+		}
+		scope, ok := analyzer.scopeOf(peer.IPBlock, clusterCIDRs)
+		if !ok {
+			continue
+		}
+		scopes[peer.IPBlock] = scope
+		ipBlocks = append(ipBlocks, &types.IPBlockScope{
+			Policy: types.NetworkPolicy{
+				Name:      policy.Name,
+				Namespace: policy.Namespace,
+				Labels:    policy.Labels,
+			},
+			Direction:    string(direction),
+			CIDR:         peer.IPBlock.CIDR,
+			Except:       peer.IPBlock.Except,
+			Scope:        scope,
+			ExceptedPods: analyzer.exceptedPodsOf(*peer.IPBlock, pods),
+		})
+	}
+	return ipBlocks
+}
+
+func (analyzer analyzerImpl) exceptedPodsOf(ipBlock networkingv1.IPBlock, pods []*corev1.Pod) []*types.ExceptedPod {
+	exceptedPods := make([]*types.ExceptedPod, 0)
+	if len(ipBlock.Except) == 0 {
+		return exceptedPods
+	}
+	for _, pod := range pods {
+		if matches, except := utils.IPBlockMatches(ipBlock, pod); !matches && except != "" {
+			exceptedPods = append(exceptedPods, &types.ExceptedPod{
+				Pod:    types.PodRef{Name: pod.Name, Namespace: pod.Namespace},
+				Except: except,
+			})
+		}
+	}
+	return exceptedPods
+}
+
+// scopeOf returns false for invalid CIDRs, which the API server rejects anyway
+func (analyzer analyzerImpl) scopeOf(ipBlock *networkingv1.IPBlock, clusterCIDRs []*net.IPNet) (string, bool) {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return "", false
+	}
+	excepts := parseCIDRs(ipBlock.Except)
+	external := !covered(cidr, append(append([]*net.IPNet{}, clusterCIDRs...), excepts...))
+	internal := false
+	for _, clusterCIDR := range clusterCIDRs {
+		if common := overlap(cidr, clusterCIDR); common != nil && !covered(common, excepts) {
+			internal = true
+		}
+	}
+	switch {
+	case internal && external:
+		return ScopeMixed, true
+	case external:
+		return ScopeExternal, true
+	default:
+		return ScopeInternal, true
+	}
+}
+
+// internetAccessOf tells whether the policies of the pod let it be reached from the internet, and reach it. Pods
+// which are not isolated in a direction are allowed all addresses, as are rules without peers.
+func (analyzer analyzerImpl) internetAccessOf(pod *corev1.Pod, policies []*networkingv1.NetworkPolicy,
+	scopes map[*networkingv1.IPBlock]string) (bool, bool) {
+	ingressIsolated, egressIsolated := false, false
+	ingress, egress := false, false
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace || !utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			continue
+		}
+		for _, policyType := range policy.Spec.PolicyTypes {
+			switch policyType {
+			case networkingv1.PolicyTypeIngress:
+				ingressIsolated = true
+				for _, rule := range policy.Spec.Ingress {
+					ingress = ingress || analyzer.peersReachInternet(rule.From, scopes)
+				}
+			case networkingv1.PolicyTypeEgress:
+				egressIsolated = true
+				for _, rule := range policy.Spec.Egress {
+					egress = egress || analyzer.peersReachInternet(rule.To, scopes)
+				}
+			}
+		}
+	}
+	return ingress || !ingressIsolated, egress || !egressIsolated
+}
+
+func (analyzer analyzerImpl) peersReachInternet(peers []networkingv1.NetworkPolicyPeer,
+	scopes map[*networkingv1.IPBlock]string) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peer.IPBlock == nil {
+			continue
+		}
+		if scope, ok := scopes[peer.IPBlock]; ok && scope != ScopeInternal {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, others ...string) []string {
+	for _, other := range others {
+		found := false
+		for _, value := range values {
+			if value == other {
+				found = true
+			}
+		}
+		if !found && other != "" {
+			values = append(values, other)
+		}
+	}
+	return values
+}
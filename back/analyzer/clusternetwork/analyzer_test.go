@@ -0,0 +1,253 @@
+package clusternetwork
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		networkConfig *config.NetworkConfig
+		clusterState  ClusterState
+	}
+	k8sAPIServer := testutils.NewPodBuilder().WithName("kube-apiserver-node1").WithNamespace("kube-system").
+		WithLabel("component", "kube-apiserver").
+		WithCommand("kube-apiserver", "--service-cluster-ip-range=10.96.0.0/12,fd00:10:96::/112").Build()
+	k8sNode1 := testutils.NewNodeBuilder().WithName("node1").WithPodCIDR("10.244.0.0/24").Build()
+	k8sNode2 := testutils.NewNodeBuilder().WithName("node2").WithPodCIDR("10.244.1.0/24").Build()
+	k8sFront := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").
+		WithIP("10.244.0.5").Build()
+	k8sBack := testutils.NewPodBuilder().WithName("back").WithNamespace("shop").WithLabel("app", "back").
+		WithIP("10.244.1.5").Build()
+	frontSelector := testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "front").Build()
+	backSelector := testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "back").Build()
+	ipBlockPeer := func(cidr string, except ...string) []networkingv1.NetworkPolicyPeer {
+		return []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr, Except: except}}}
+	}
+	k8sFrontPolicy := testutils.NewNetworkPolicyBuilder().WithName("front").WithNamespace("shop").
+		WithPodSelector(frontSelector).WithTypes(networkingv1.PolicyTypeEgress).
+		WithEgressRule(networkingv1.NetworkPolicyEgressRule{To: ipBlockPeer("10.96.0.0/12")}).
+		WithEgressRule(networkingv1.NetworkPolicyEgressRule{To: ipBlockPeer("10.0.0.0/8", "10.0.0.0/9")}).Build()
+	k8sBackPolicy := testutils.NewNetworkPolicyBuilder().WithName("back").WithNamespace("shop").
+		WithPodSelector(backSelector).WithTypes(networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("10.244.0.0/16")}).
+		WithEgressRule(networkingv1.NetworkPolicyEgressRule{To: ipBlockPeer("0.0.0.0/0", "10.0.0.0/8")}).Build()
+	noPod := []*types.ExceptedPod{}
+	noLoadBalancer := []*types.LoadBalancerAccess{}
+	exceptedPod := func(name string, except string) *types.ExceptedPod {
+		return &types.ExceptedPod{Pod: types.PodRef{Name: name, Namespace: "shop"}, Except: except}
+	}
+	k8sDB := testutils.NewPodBuilder().WithName("db").WithNamespace("shop").WithLabel("app", "db").Build()
+	dbSelector := testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "db").Build()
+	k8sDBPolicy := testutils.NewNetworkPolicyBuilder().WithName("db").WithNamespace("shop").
+		WithPodSelector(dbSelector).WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("203.0.113.0/24", "203.0.113.128/25")}).
+		Build()
+	loadBalancer := func(name string, policy corev1.ServiceExternalTrafficPolicyType) *testutils.ServiceBuilder {
+		return testutils.NewServiceBuilder().WithName(name).WithNamespace("shop").WithSelectorLabel("app", "db").
+			WithType(corev1.ServiceTypeLoadBalancer).WithExternalTrafficPolicy(policy)
+	}
+	policyRef := func(name string) types.NetworkPolicy {
+		return types.NetworkPolicy{Name: name, Namespace: "shop", Labels: map[string]string{}}
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "ranges are detected from the API server and the nodes, and classify the ipBlocks with their excepted pods",
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sAPIServer, k8sFront, k8sBack},
+					Nodes:           []*corev1.Node{k8sNode1, k8sNode2},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sFrontPolicy, k8sBackPolicy},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{
+					PodCIDRs:     []string{"10.244.0.0/24", "10.244.1.0/24"},
+					ServiceCIDRs: []string{"10.96.0.0/12", "fd00:10:96::/112"},
+				},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("front"), Direction: "Egress", CIDR: "10.96.0.0/12", Scope: ScopeInternal, ExceptedPods: noPod},
+					{Policy: policyRef("front"), Direction: "Egress", CIDR: "10.0.0.0/8", Except: []string{"10.0.0.0/9"},
+						Scope: ScopeMixed, ExceptedPods: noPod},
+					{Policy: policyRef("back"), Direction: "Ingress", CIDR: "10.244.0.0/16", Scope: ScopeMixed, ExceptedPods: noPod},
+					{Policy: policyRef("back"), Direction: "Egress", CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/8"},
+						Scope: ScopeExternal, ExceptedPods: []*types.ExceptedPod{
+							exceptedPod("front", "10.0.0.0/8"), exceptedPod("back", "10.0.0.0/8"),
+						}},
+				},
+				InternetAccesses: []*types.InternetAccess{
+					{Pod: types.PodRef{Name: "kube-apiserver-node1", Namespace: "kube-system"}, Egress: true,
+						Ingress: true, LoadBalancers: noLoadBalancer},
+					{Pod: types.PodRef{Name: "front", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: noLoadBalancer},
+					{Pod: types.PodRef{Name: "back", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: noLoadBalancer},
+				},
+			},
+		},
+		{
+			name: "configured ranges take precedence over the detected ones",
+			args: args{
+				networkConfig: &config.NetworkConfig{PodCIDRs: []string{"10.244.0.0/16"}},
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sFront, k8sBack},
+					Nodes:           []*corev1.Node{k8sNode1, k8sNode2},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sBackPolicy},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{
+					PodCIDRs:     []string{"10.244.0.0/16"},
+					ServiceCIDRs: []string{},
+				},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("back"), Direction: "Ingress", CIDR: "10.244.0.0/16", Scope: ScopeInternal,
+						ExceptedPods: noPod},
+					{Policy: policyRef("back"), Direction: "Egress", CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/8"},
+						Scope: ScopeExternal, ExceptedPods: []*types.ExceptedPod{
+							exceptedPod("front", "10.0.0.0/8"), exceptedPod("back", "10.0.0.0/8"),
+						}},
+				},
+				InternetAccesses: []*types.InternetAccess{
+					{Pod: types.PodRef{Name: "front", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: noLoadBalancer},
+					{Pod: types.PodRef{Name: "back", Namespace: "shop"}, Egress: true, Ingress: false,
+						LoadBalancers: noLoadBalancer},
+				},
+			},
+		},
+		{
+			name: "every ipBlock is external without any known range",
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sFront},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sFrontPolicy},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{PodCIDRs: []string{}, ServiceCIDRs: []string{}},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("front"), Direction: "Egress", CIDR: "10.96.0.0/12", Scope: ScopeExternal, ExceptedPods: noPod},
+					{Policy: policyRef("front"), Direction: "Egress", CIDR: "10.0.0.0/8", Except: []string{"10.0.0.0/9"},
+						Scope: ScopeExternal, ExceptedPods: noPod},
+				},
+				InternetAccesses: []*types.InternetAccess{
+					{Pod: types.PodRef{Name: "front", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: noLoadBalancer},
+				},
+			},
+		},
+		{
+			name: "pods whose ipBlocks are all internal have no internet access",
+			args: args{
+				networkConfig: &config.NetworkConfig{PodCIDRs: []string{"10.244.0.0/16"},
+					ServiceCIDRs: []string{"10.96.0.0/12"}},
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{k8sFront},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("front").WithNamespace("shop").
+							WithPodSelector(frontSelector).
+							WithTypes(networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress).
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{To: ipBlockPeer("10.96.0.0/12")}).
+							Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{PodCIDRs: []string{"10.244.0.0/16"},
+					ServiceCIDRs: []string{"10.96.0.0/12"}},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("front"), Direction: "Egress", CIDR: "10.96.0.0/12", Scope: ScopeInternal, ExceptedPods: noPod},
+				},
+				InternetAccesses: []*types.InternetAccess{},
+			},
+		},
+		{
+			name: "load balancers let in their source ranges, filtered by the policies when the client address is kept",
+			args: args{
+				networkConfig: &config.NetworkConfig{PodCIDRs: []string{"10.244.0.0/16"}},
+				clusterState: ClusterState{
+					Pods:  []*corev1.Pod{k8sDB},
+					Nodes: []*corev1.Node{testutils.NewNodeBuilder().WithInternalIP("10.0.0.1").Build()},
+					Services: []*corev1.Service{
+						loadBalancer("db-local", corev1.ServiceExternalTrafficPolicyTypeLocal).
+							WithLoadBalancerSourceRange("203.0.0.0/16").
+							WithLoadBalancerSourceRange("198.51.100.0/24").Build(),
+						loadBalancer("db-cluster", corev1.ServiceExternalTrafficPolicyTypeCluster).Build(),
+						testutils.NewServiceBuilder().WithName("db").WithNamespace("shop").
+							WithSelectorLabel("app", "db").Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sDBPolicy},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{PodCIDRs: []string{"10.244.0.0/16"}, ServiceCIDRs: []string{}},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("db"), Direction: "Ingress", CIDR: "203.0.113.0/24",
+						Except: []string{"203.0.113.128/25"}, Scope: ScopeExternal, ExceptedPods: noPod},
+				},
+				InternetAccesses: []*types.InternetAccess{
+					{Pod: types.PodRef{Name: "db", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: []*types.LoadBalancerAccess{
+							{Service: types.ServiceRef{Name: "db-local", Namespace: "shop"},
+								ExternalTrafficPolicy: "Local", ClientCIDRs: []string{"203.0.113.0/25"}},
+							// The policies do not let the nodes in, where the client address is replaced
+							{Service: types.ServiceRef{Name: "db-cluster", Namespace: "shop"},
+								ExternalTrafficPolicy: "Cluster", ClientCIDRs: []string{}},
+						}},
+				},
+			},
+		},
+		{
+			name: "load balancers replacing the client address let in their source ranges when the nodes are allowed",
+			args: args{
+				networkConfig: &config.NetworkConfig{PodCIDRs: []string{"10.244.0.0/16"}},
+				clusterState: ClusterState{
+					Pods:  []*corev1.Pod{k8sDB},
+					Nodes: []*corev1.Node{testutils.NewNodeBuilder().WithInternalIP("10.0.0.1").Build()},
+					Services: []*corev1.Service{
+						loadBalancer("db-cluster", corev1.ServiceExternalTrafficPolicyTypeCluster).Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("db").WithNamespace("shop").
+							WithPodSelector(dbSelector).WithTypes(networkingv1.PolicyTypeIngress).
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("10.0.0.0/24")}).
+							Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Network: &types.ClusterNetwork{PodCIDRs: []string{"10.244.0.0/16"}, ServiceCIDRs: []string{}},
+				IPBlocks: []*types.IPBlockScope{
+					{Policy: policyRef("db"), Direction: "Ingress", CIDR: "10.0.0.0/24", Scope: ScopeExternal,
+						ExceptedPods: noPod},
+				},
+				InternetAccesses: []*types.InternetAccess{
+					{Pod: types.PodRef{Name: "db", Namespace: "shop"}, Egress: true, Ingress: true,
+						LoadBalancers: []*types.LoadBalancerAccess{
+							{Service: types.ServiceRef{Name: "db-cluster", Namespace: "shop"},
+								ExternalTrafficPolicy: "Cluster", ClientCIDRs: []string{"0.0.0.0/0", "::/0"}},
+						}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.args.networkConfig)
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package clusternetwork
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/analyzer/utils"
+	"karto/types"
+	"net"
+)
+
+// Load balancers let all clients in without loadBalancerSourceRanges
+var allClientCIDRs = []string{"0.0.0.0/0", "::/0"}
+
+// loadBalancerAccessesOf returns the client ranges reaching the pod through each load balancer service targeting it.
+// With the Local externalTrafficPolicy the address of the clients is preserved, and only the ranges allowed by the
+// ingress policies of the pod get in. With the Cluster one it is replaced by the address of a node, which the
+// policies must allow for any client to get in.
+func (analyzer analyzerImpl) loadBalancerAccessesOf(pod *corev1.Pod, services []*corev1.Service,
+	policies []*networkingv1.NetworkPolicy, nodeIPs []net.IP) []*types.LoadBalancerAccess {
+	accesses := make([]*types.LoadBalancerAccess, 0)
+	var allowsAll, evaluated bool
+	var ipBlocks []networkingv1.IPBlock
+	for _, service := range services {
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer || !analyzer.targets(service, pod) {
+			continue
+		}
+		if !evaluated {
+			allowsAll, ipBlocks = analyzer.ingressIPBlocksOf(pod, policies)
+			evaluated = true
+		}
+		sourceRanges := service.Spec.LoadBalancerSourceRanges
+		if len(sourceRanges) == 0 {
+			sourceRanges = allClientCIDRs
+		}
+		access := &types.LoadBalancerAccess{
+			Service:               types.ServiceRef{Name: service.Name, Namespace: service.Namespace},
+			ExternalTrafficPolicy: string(corev1.ServiceExternalTrafficPolicyTypeCluster),
+			ClientCIDRs:           make([]string, 0),
+		}
+		switch {
+		case service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal:
+			access.ExternalTrafficPolicy = string(corev1.ServiceExternalTrafficPolicyTypeLocal)
+			access.ClientCIDRs = analyzer.allowedClientCIDRs(parseCIDRs(sourceRanges), allowsAll, ipBlocks)
+		case allowsAll || analyzer.ipBlocksContainAny(ipBlocks, nodeIPs):
+			access.ClientCIDRs = appendUnique(access.ClientCIDRs, sourceRanges...)
+		}
+		accesses = append(accesses, access)
+	}
+	return accesses
+}
+
+func (analyzer analyzerImpl) targets(service *corev1.Service, pod *corev1.Pod) bool {
+	return service.Namespace == pod.Namespace && len(service.Spec.Selector) > 0 &&
+		utils.SelectorMatches(pod.Labels, metav1.LabelSelector{MatchLabels: service.Spec.Selector})
+}
+
+// ingressIPBlocksOf returns the ipBlock peers allowing traffic to the pod, or whether any address is allowed, as for
+// pods which are not isolated for ingress and rules without peers
+func (analyzer analyzerImpl) ingressIPBlocksOf(pod *corev1.Pod,
+	policies []*networkingv1.NetworkPolicy) (bool, []networkingv1.IPBlock) {
+	isolated := false
+	var ipBlocks []networkingv1.IPBlock
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace || !utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) ||
+			!hasIngressType(policy) {
+			continue
+		}
+		isolated = true
+		for _, rule := range policy.Spec.Ingress {
+			if len(rule.From) == 0 {
+				return true, nil
+			}
+			for _, peer := range rule.From {
+				if peer.IPBlock != nil {
+					ipBlocks = append(ipBlocks, *peer.IPBlock)
+				}
+			}
+		}
+	}
+	return !isolated, ipBlocks
+}
+
+func hasIngressType(policy *networkingv1.NetworkPolicy) bool {
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedClientCIDRs intersects the source ranges with the ipBlocks, without their except ranges
+func (analyzer analyzerImpl) allowedClientCIDRs(sourceRanges []*net.IPNet, allowsAll bool,
+	ipBlocks []networkingv1.IPBlock) []string {
+	clientCIDRs := make([]string, 0)
+	for _, sourceRange := range sourceRanges {
+		if allowsAll {
+			clientCIDRs = appendUnique(clientCIDRs, sourceRange.String())
+			continue
+		}
+		for _, ipBlock := range ipBlocks {
+			_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+			if err != nil {
+				continue
+			}
+			common := overlap(sourceRange, cidr)
+			if common == nil {
+				continue
+			}
+			for _, allowed := range subtract(common, parseCIDRs(ipBlock.Except)) {
+				clientCIDRs = appendUnique(clientCIDRs, allowed.String())
+			}
+		}
+	}
+	return clientCIDRs
+}
+
+func (analyzer analyzerImpl) ipBlocksContainAny(ipBlocks []networkingv1.IPBlock, ips []net.IP) bool {
+	for _, ipBlock := range ipBlocks {
+		_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+		if err != nil {
+			continue
+		}
+		excepts := parseCIDRs(ipBlock.Except)
+		for _, ip := range ips {
+			if cidr.Contains(ip) && !containedByAny(excepts, ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containedByAny(ipNets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeIPsOf(nodes []*corev1.Node) []net.IP {
+	var ips []net.IP
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP {
+				continue
+			}
+			if ip := net.ParseIP(address.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
@@ -0,0 +1,83 @@
+package clusternetwork
+
+import (
+	"net"
+)
+
+// parseCIDRs skips the invalid CIDRs
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			result = append(result, ipNet)
+		}
+	}
+	return result
+}
+
+// covered tells whether every address of the range belongs to one of the others. The range is split in halves as long
+// as one of the others is strictly inside it, so the recursion is bounded by their prefix lengths.
+func covered(ipNet *net.IPNet, others []*net.IPNet) bool {
+	ones, bits := ipNet.Mask.Size()
+	inside := false
+	for _, other := range others {
+		otherOnes, otherBits := other.Mask.Size()
+		if otherBits != bits {
+			continue
+		}
+		if otherOnes <= ones && other.Contains(ipNet.IP) {
+			return true
+		}
+		if otherOnes > ones && ipNet.Contains(other.IP) {
+			inside = true
+		}
+	}
+	if !inside {
+		return false
+	}
+	lower, upper := halves(ipNet)
+	return covered(lower, others) && covered(upper, others)
+}
+
+// subtract returns the ranges made of the addresses of the range which belong to none of the others, splitting it in
+// halves as long as one of the others is strictly inside it
+func subtract(ipNet *net.IPNet, others []*net.IPNet) []*net.IPNet {
+	if covered(ipNet, others) {
+		return nil
+	}
+	ones, bits := ipNet.Mask.Size()
+	for _, other := range others {
+		otherOnes, otherBits := other.Mask.Size()
+		if otherBits == bits && otherOnes > ones && ipNet.Contains(other.IP) {
+			lower, upper := halves(ipNet)
+			return append(subtract(lower, others), subtract(upper, others)...)
+		}
+	}
+	return []*net.IPNet{ipNet}
+}
+
+// overlap returns the smallest of two ranges when it is inside the other, ranges being either nested or disjoint
+func overlap(ipNet *net.IPNet, other *net.IPNet) *net.IPNet {
+	ones, bits := ipNet.Mask.Size()
+	otherOnes, otherBits := other.Mask.Size()
+	switch {
+	case bits != otherBits:
+		return nil
+	case ones <= otherOnes && ipNet.Contains(other.IP):
+		return other
+	case otherOnes <= ones && other.Contains(ipNet.IP):
+		return ipNet
+	default:
+		return nil
+	}
+}
+
+func halves(ipNet *net.IPNet) (*net.IPNet, *net.IPNet) {
+	ones, bits := ipNet.Mask.Size()
+	mask := net.CIDRMask(ones+1, bits)
+	lower := &net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask}
+	upperIP := make(net.IP, len(lower.IP))
+	copy(upperIP, lower.IP)
+	upperIP[ones/8] |= 0x80 >> uint(ones%8)
+	return lower, &net.IPNet{IP: upperIP, Mask: mask}
+}
@@ -0,0 +1,94 @@
+package clusternetwork
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestCovered(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		others   []string
+		expected bool
+	}{
+		{
+			name:     "a range is covered by a larger one",
+			cidr:     "10.1.0.0/16",
+			others:   []string{"10.0.0.0/8"},
+			expected: true,
+		},
+		{
+			name:     "a range is covered by the union of its parts",
+			cidr:     "10.0.0.0/23",
+			others:   []string{"10.0.1.0/24", "10.0.0.0/25", "10.0.0.128/25"},
+			expected: true,
+		},
+		{
+			name:     "a range is not covered when one of its parts is missing",
+			cidr:     "10.0.0.0/23",
+			others:   []string{"10.0.1.0/24", "10.0.0.0/25"},
+			expected: false,
+		},
+		{
+			name:     "a range is not covered by ranges of another family",
+			cidr:     "0.0.0.0/0",
+			others:   []string{"::/0"},
+			expected: false,
+		},
+		{
+			name:     "an IPv6 range is covered by the union of its halves",
+			cidr:     "fd00::/64",
+			others:   []string{"fd00::/65", "fd00::8000:0:0:0/65"},
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr := parseCIDRs([]string{tt.cidr})[0]
+			if result := covered(cidr, parseCIDRs(tt.others)); result != tt.expected {
+				t.Errorf("covered() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		others   []string
+		expected []string
+	}{
+		{
+			name:     "a range without others inside is kept",
+			cidr:     "10.0.0.0/8",
+			others:   []string{"192.168.0.0/16"},
+			expected: []string{"10.0.0.0/8"},
+		},
+		{
+			name:     "a range covered by the others is removed",
+			cidr:     "10.1.0.0/16",
+			others:   []string{"10.0.0.0/8"},
+			expected: []string{},
+		},
+		{
+			name:     "a range is split around the others inside it",
+			cidr:     "10.0.0.0/8",
+			others:   []string{"10.0.0.0/10", "10.192.0.0/10"},
+			expected: []string{"10.64.0.0/10", "10.128.0.0/10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr := parseCIDRs([]string{tt.cidr})[0]
+			result := make([]string, 0)
+			for _, ipNet := range subtract(cidr, parseCIDRs(tt.others)) {
+				result = append(result, ipNet.String())
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("subtract() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
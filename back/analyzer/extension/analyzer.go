@@ -0,0 +1,73 @@
+package extension
+
+import (
+	"encoding/json"
+	"fmt"
+	"karto/analyzer/finding"
+	"karto/types"
+	"log"
+)
+
+type ClusterState struct {
+	ClusterState   types.ClusterState
+	AnalysisResult types.AnalysisResult
+}
+
+type AnalysisResult struct {
+	Sections map[string]json.RawMessage
+	Findings []*types.Finding
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	extensions []Extension
+}
+
+func NewAnalyzer(extensions []Extension) Analyzer {
+	return analyzerImpl{
+		extensions: extensions,
+	}
+}
+
+// A failing extension is logged and left out of the analysis result, it never fails the whole analysis
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	sections := make(map[string]json.RawMessage)
+	findings := make([]*types.Finding, 0)
+	for _, extension := range analyzer.extensions {
+		contribution, err := analyze(extension, clusterState)
+		if err != nil {
+			log.Printf("Extension %s failed, its contribution is ignored: %s\n", extension.Name(), err)
+			continue
+		}
+		if contribution.Section != nil {
+			section, err := json.Marshal(contribution.Section)
+			if err != nil {
+				log.Printf("Extension %s returned a section which cannot be serialized: %s\n", extension.Name(), err)
+				continue
+			}
+			sections[extension.Name()] = section
+		}
+		for _, extensionFinding := range contribution.Findings {
+			if extensionFinding.Fingerprint == "" {
+				extensionFinding.Fingerprint = finding.Fingerprint(extensionFinding)
+			}
+			findings = append(findings, extensionFinding)
+		}
+	}
+	return AnalysisResult{
+		Sections: sections,
+		Findings: findings,
+	}
+}
+
+func analyze(extension Extension, clusterState ClusterState) (contribution Contribution, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return extension.Analyze(clusterState.ClusterState, clusterState.AnalysisResult)
+}
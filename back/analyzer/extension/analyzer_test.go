@@ -0,0 +1,89 @@
+package extension
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+type fakeExtension struct {
+	name         string
+	contribution Contribution
+	err          error
+	panics       bool
+}
+
+func (extension fakeExtension) Name() string {
+	return extension.name
+}
+
+func (extension fakeExtension) Analyze(_ types.ClusterState, _ types.AnalysisResult) (Contribution, error) {
+	if extension.panics {
+		panic("unexpected state")
+	}
+	return extension.contribution, extension.err
+}
+
+func TestAnalyze(t *testing.T) {
+	resource := types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns"}
+	tests := []struct {
+		name                   string
+		extensions             []Extension
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name:       "no extension",
+			extensions: []Extension{},
+			expectedAnalysisResult: AnalysisResult{
+				Sections: map[string]json.RawMessage{},
+				Findings: []*types.Finding{},
+			},
+		},
+		{
+			name: "sections are serialized and findings are fingerprinted",
+			extensions: []Extension{
+				fakeExtension{name: "costs", contribution: Contribution{
+					Section: map[string]int{"total": 3},
+					Findings: []*types.Finding{
+						{Rule: "expensive-pod", Severity: "low", Resource: resource},
+						{Fingerprint: "custom", Rule: "expensive-pod", Severity: "low", Resource: resource},
+					},
+				}},
+				fakeExtension{name: "owners", contribution: Contribution{}},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Sections: map[string]json.RawMessage{"costs": json.RawMessage(`{"total":3}`)},
+				Findings: []*types.Finding{
+					{Fingerprint: "572e59d927ff7a8d", Rule: "expensive-pod", Severity: "low", Resource: resource},
+					{Fingerprint: "custom", Rule: "expensive-pod", Severity: "low", Resource: resource},
+				},
+			},
+		},
+		{
+			name: "failing extensions are ignored",
+			extensions: []Extension{
+				fakeExtension{name: "failing", err: errors.New("unavailable"), contribution: Contribution{
+					Section: "ignored",
+				}},
+				fakeExtension{name: "panicking", panics: true},
+				fakeExtension{name: "unserializable", contribution: Contribution{Section: func() {}}},
+				fakeExtension{name: "owners", contribution: Contribution{Section: []string{"team-a"}}},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Sections: map[string]json.RawMessage{"owners": json.RawMessage(`["team-a"]`)},
+				Findings: []*types.Finding{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.extensions)
+			analysisResult := analyzer.Analyze(ClusterState{})
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
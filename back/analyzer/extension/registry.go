@@ -0,0 +1,59 @@
+package extension
+
+import (
+	"fmt"
+	"karto/types"
+	"sort"
+	"sync"
+)
+
+// Extension is implemented by third party analyzers. Their package registers them from an init function, and is
+// compiled into Karto with a blank import in the main package.
+type Extension interface {
+	// Name identifies the section contributed to the analysis result, it must be unique
+	Name() string
+	// Analyze receives the analysis result of the built-in analyzers, which must not be modified
+	Analyze(clusterState types.ClusterState, analysisResult types.AnalysisResult) (Contribution, error)
+}
+
+// Contribution is added to the analysis result. The section is serialized in JSON under the name of the extension,
+// and the findings are appended to the built-in ones.
+type Contribution struct {
+	Section  interface{}
+	Findings []*types.Finding
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      = make(map[string]Extension)
+)
+
+// Register panics when the name is empty or already registered, as registration happens at startup
+func Register(extension Extension) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	name := extension.Name()
+	if name == "" {
+		panic("extension: Register called with an empty name")
+	}
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("extension: Register called twice for %s", name))
+	}
+	registry[name] = extension
+}
+
+// Registered returns the registered extensions sorted by name, so that they always run in the same order
+func Registered() []Extension {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	extensions := make([]Extension, 0, len(names))
+	for _, name := range names {
+		extensions = append(extensions, registry[name])
+	}
+	return extensions
+}
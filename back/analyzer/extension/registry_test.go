@@ -0,0 +1,30 @@
+package extension
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	registry = make(map[string]Extension)
+	defer func() { registry = make(map[string]Extension) }()
+	Register(fakeExtension{name: "owners"})
+	Register(fakeExtension{name: "costs"})
+	names := make([]string, 0)
+	for _, extension := range Registered() {
+		names = append(names, extension.Name())
+	}
+	if diff := cmp.Diff([]string{"costs", "owners"}, names); diff != "" {
+		t.Errorf("Registered() result mismatch (-want +got):\n%s", diff)
+	}
+	for _, name := range []string{"costs", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register() did not panic for name %q", name)
+				}
+			}()
+			Register(fakeExtension{name: name})
+		}()
+	}
+}
@@ -0,0 +1,361 @@
+package finding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/authoring"
+	"karto/config"
+	"karto/types"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	RulePodNotIngressIsolated        = "pod-not-ingress-isolated"
+	RulePodNotEgressIsolated         = "pod-not-egress-isolated"
+	RuleUnusedNetworkPolicy          = "unused-network-policy"
+	RuleRouteWithoutIntent           = "route-without-intent"
+	RuleDNSEgressBlocked             = "dns-egress-blocked"
+	RulePrivilegedPodExposed         = "privileged-pod-reachable-from-other-namespaces"
+	RuleIngressBackendUnreachable    = "ingress-backend-unreachable"
+	RuleMetricsScrapeBlocked         = "metrics-scrape-blocked"
+	RuleAPIServerBackendUnreachable  = "api-server-backend-unreachable"
+	RuleHostPortExposed              = "host-port-exposed"
+	RuleExpiredNetworkPolicy         = "expired-network-policy"
+	RuleStalePolicyException         = "stale-policy-exception"
+	RuleComplianceDefaultDeny        = "compliance-default-deny"
+	RuleComplianceKubeSystemAccess   = "compliance-kube-system-access"
+	RuleComplianceUnrestrictedEgress = "compliance-unrestricted-egress"
+	RuleMissingPermission            = "missing-permission"
+	RuleServicePortBlocked           = "service-port-blocked"
+)
+
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+const (
+	RemediationCreate = "create"
+	RemediationPatch  = "patch"
+	RemediationDelete = "delete"
+)
+
+type ClusterState struct {
+	Namespaces      []*corev1.Namespace
+	Pods            []*corev1.Pod
+	NetworkPolicies []*networkingv1.NetworkPolicy
+	PodIsolations   []*types.PodIsolation
+	AllowedRoutes   []*types.AllowedRoute
+	Services        []*types.Service
+	// ServiceSpecs are the services as declared, for their ports
+	ServiceSpecs                    []*corev1.Service
+	Ingresses                       []*types.Ingress
+	Nodes                           []*corev1.Node
+	ValidatingWebhookConfigurations []*admissionregistrationv1.ValidatingWebhookConfiguration
+	MutatingWebhookConfigurations   []*admissionregistrationv1.MutatingWebhookConfiguration
+	APIServices                     []*types.APIService
+	Intents                         []config.Intent
+	ConnectivityRules               []config.ConnectivityRule
+	HostPortExposures               []*types.HostPortExposure
+	PolicyExceptions                []*types.PolicyException
+}
+
+type AnalysisResult struct {
+	Findings   []*types.Finding
+	Compliance []*types.ComplianceProfile
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	customRules            []config.Rule
+	intents                []config.Intent
+	monitoring             *config.MonitoringConfig
+	policyExpiryAnnotation string
+	complianceProfiles     []string
+	messages               map[string]*template.Template
+	now                    func() time.Time
+}
+
+func NewAnalyzer(customRules []config.Rule, intents []config.Intent, monitoring *config.MonitoringConfig,
+	policyExpiry *config.PolicyExpiryConfig, compliance *config.ComplianceConfig,
+	messages map[string]string) Analyzer {
+	policyExpiryAnnotation := config.DefaultPolicyExpiryAnnotation
+	if policyExpiry != nil && policyExpiry.Annotation != "" {
+		policyExpiryAnnotation = policyExpiry.Annotation
+	}
+	var complianceProfiles []string
+	if compliance != nil {
+		complianceProfiles = compliance.Profiles
+	}
+	return analyzerImpl{
+		customRules:            customRules,
+		intents:                intents,
+		monitoring:             monitoring,
+		policyExpiryAnnotation: policyExpiryAnnotation,
+		complianceProfiles:     complianceProfiles,
+		messages:               parseMessages(messages),
+		now:                    time.Now,
+	}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	findings := make([]*types.Finding, 0)
+	findings = append(findings, analyzer.podIsolationFindings(clusterState.PodIsolations)...)
+	findings = append(findings, analyzer.unusedNetworkPolicyFindings(clusterState.NetworkPolicies,
+		clusterState.Pods)...)
+	findings = append(findings, analyzer.expiredPolicyFindings(clusterState.NetworkPolicies)...)
+	findings = append(findings, analyzer.staleExceptionFindings(clusterState.PolicyExceptions)...)
+	findings = append(findings, analyzer.routeWithoutIntentFindings(clusterState.AllowedRoutes,
+		clusterState.Intents)...)
+	findings = append(findings, analyzer.dnsEgressBlockedFindings(clusterState.Pods, clusterState.PodIsolations,
+		clusterState.AllowedRoutes)...)
+	findings = append(findings, analyzer.privilegedPodFindings(clusterState.Pods, clusterState.PodIsolations,
+		clusterState.AllowedRoutes)...)
+	findings = append(findings, analyzer.ingressBackendFindings(clusterState.Pods, clusterState.Ingresses,
+		clusterState.Services, clusterState.AllowedRoutes)...)
+	findings = append(findings, analyzer.metricsScrapeFindings(clusterState.Pods, clusterState.AllowedRoutes)...)
+	findings = append(findings, analyzer.servicePortFindings(clusterState)...)
+	findings = append(findings, analyzer.hostPortFindings(clusterState.HostPortExposures,
+		clusterState.PodIsolations)...)
+	findings = append(findings, analyzer.apiServerBackendFindings(clusterState)...)
+	findings = append(findings, analyzer.customRuleFindings(clusterState)...)
+	findings = append(findings, analyzer.connectivityRuleFindings(clusterState.ConnectivityRules, clusterState.Pods,
+		clusterState.AllowedRoutes)...)
+	complianceFindings, compliance := analyzer.complianceFindings(clusterState)
+	findings = append(findings, complianceFindings...)
+	return AnalysisResult{
+		Findings:   findings,
+		Compliance: compliance,
+	}
+}
+
+func (analyzer analyzerImpl) podIsolationFindings(podIsolations []*types.PodIsolation) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, podIsolation := range podIsolations {
+		pod := types.ResourceRef{Kind: "Pod", Name: podIsolation.Pod.Name, Namespace: podIsolation.Pod.Namespace}
+		parameters := map[string]string{"namespace": pod.Namespace, "pod": pod.Name}
+		if !podIsolation.IsIngressIsolated {
+			findings = append(findings, analyzer.newFinding(RulePodNotIngressIsolated, SeverityMedium, pod, nil,
+				CodePodNotIngressIsolated, parameters))
+		}
+		if !podIsolation.IsEgressIsolated {
+			findings = append(findings, analyzer.newFinding(RulePodNotEgressIsolated, SeverityLow, pod, nil,
+				CodePodNotEgressIsolated, parameters))
+		}
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) unusedNetworkPolicyFindings(policies []*networkingv1.NetworkPolicy,
+	pods []*corev1.Pod) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, policy := range policies {
+		if analyzer.selectsAnyPod(policy, pods) {
+			continue
+		}
+		networkPolicy := types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace}
+		finding := analyzer.newFinding(RuleUnusedNetworkPolicy, SeverityLow, networkPolicy, nil,
+			CodeUnusedNetworkPolicy, map[string]string{"namespace": policy.Namespace, "policy": policy.Name})
+		finding.Remediation = &types.Remediation{Operation: RemediationDelete, Resource: networkPolicy}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) routeWithoutIntentFindings(allowedRoutes []*types.AllowedRoute,
+	declaredIntents []config.Intent) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	if len(analyzer.intents) == 0 && len(declaredIntents) == 0 {
+		// Without any declared intent, every route would be flagged
+		return findings
+	}
+	for _, allowedRoute := range allowedRoutes {
+		if len(allowedRoute.Intents) > 0 {
+			continue
+		}
+		findings = append(findings, analyzer.newRouteFinding(RuleRouteWithoutIntent, SeverityLow,
+			allowedRoute.SourcePod, allowedRoute.TargetPod, CodeRouteWithoutIntent,
+			routeParameters(allowedRoute.SourcePod, allowedRoute.TargetPod)))
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) dnsEgressBlockedFindings(pods []*corev1.Pod, podIsolations []*types.PodIsolation,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	dnsPods := make(map[types.PodRef]bool)
+	for _, pod := range pods {
+		if pod.Namespace == authoring.DNSNamespace && pod.Labels[authoring.DNSPodLabel] == authoring.DNSPodValue {
+			dnsPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+		}
+	}
+	if len(dnsPods) == 0 {
+		// The DNS of the cluster is not recognized
+		return findings
+	}
+	resolvingPods := make(map[types.PodRef]bool)
+	for _, allowedRoute := range allowedRoutes {
+		if dnsPods[allowedRoute.TargetPod] && utils.AllowsPort(allowedRoute, authoring.DNSPort, corev1.ProtocolUDP) {
+			resolvingPods[allowedRoute.SourcePod] = true
+		}
+	}
+	blockedPodsByNamespace := make(map[string][]string)
+	namespacesWithUnisolatedPods := make(map[string]bool)
+	namespaces := make([]string, 0)
+	for _, podIsolation := range podIsolations {
+		if !podIsolation.IsEgressIsolated {
+			namespacesWithUnisolatedPods[podIsolation.Pod.Namespace] = true
+			continue
+		}
+		if resolvingPods[podIsolation.Pod] || dnsPods[podIsolation.Pod] {
+			continue
+		}
+		if _, ok := blockedPodsByNamespace[podIsolation.Pod.Namespace]; !ok {
+			namespaces = append(namespaces, podIsolation.Pod.Namespace)
+		}
+		blockedPodsByNamespace[podIsolation.Pod.Namespace] = append(
+			blockedPodsByNamespace[podIsolation.Pod.Namespace], podIsolation.Pod.Name)
+	}
+	sort.Strings(namespaces)
+	for _, namespaceName := range namespaces {
+		namespace := types.ResourceRef{Kind: "Namespace", Name: namespaceName}
+		finding := analyzer.newFinding(RuleDNSEgressBlocked, SeverityHigh, namespace, nil, CodeDNSEgressBlocked,
+			map[string]string{
+				"namespace": namespaceName,
+				"pods":      strings.Join(blockedPodsByNamespace[namespaceName], ", "),
+				"dns":       authoring.DNSPodValue,
+			})
+		if !namespacesWithUnisolatedPods[namespaceName] {
+			// Allowing DNS for all pods would otherwise isolate the pods which can currently reach anything
+			policy := authoring.DNSEgressPolicy(namespaceName)
+			finding.Remediation = &types.Remediation{
+				Operation: RemediationCreate,
+				Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace},
+				Manifest:  policy,
+			}
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// Network exposure matters more for pods which can escape their containers, running privileged or sharing the
+// process or IPC namespaces of their node
+func (analyzer analyzerImpl) privilegedPodFindings(pods []*corev1.Pod, podIsolations []*types.PodIsolation,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	ingressIsolatedPods := make(map[types.PodRef]bool)
+	for _, podIsolation := range podIsolations {
+		if podIsolation.IsIngressIsolated {
+			ingressIsolatedPods[podIsolation.Pod] = true
+		}
+	}
+	sourceNamespaces := make(map[types.PodRef]map[string]bool)
+	for _, allowedRoute := range allowedRoutes {
+		if allowedRoute.SourcePod.Namespace == allowedRoute.TargetPod.Namespace {
+			continue
+		}
+		if sourceNamespaces[allowedRoute.TargetPod] == nil {
+			sourceNamespaces[allowedRoute.TargetPod] = make(map[string]bool)
+		}
+		sourceNamespaces[allowedRoute.TargetPod][allowedRoute.SourcePod.Namespace] = true
+	}
+	for _, pod := range pods {
+		if !pod.Spec.HostPID && !pod.Spec.HostIPC && !utils.HasPrivilegedContainer(pod) {
+			continue
+		}
+		podRef := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+		resource := types.ResourceRef{Kind: "Pod", Name: pod.Name, Namespace: pod.Namespace}
+		if !ingressIsolatedPods[podRef] {
+			findings = append(findings, analyzer.newFinding(RulePrivilegedPodExposed, SeverityHigh, resource, nil,
+				CodePrivilegedPodOpen, map[string]string{"namespace": pod.Namespace, "pod": pod.Name}))
+			continue
+		}
+		if len(sourceNamespaces[podRef]) == 0 {
+			continue
+		}
+		namespaces := make([]string, 0)
+		for namespace := range sourceNamespaces[podRef] {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		findings = append(findings, analyzer.newFinding(RulePrivilegedPodExposed, SeverityHigh, resource, nil,
+			CodePrivilegedPodExposed, map[string]string{
+				"namespace":        pod.Namespace,
+				"pod":              pod.Name,
+				"sourceNamespaces": strings.Join(namespaces, ", "),
+			}))
+	}
+	return findings
+}
+
+// Host ports matter most for ingress isolated pods, whose isolation they bypass without it showing in their routes
+func (analyzer analyzerImpl) hostPortFindings(exposures []*types.HostPortExposure,
+	podIsolations []*types.PodIsolation) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	ingressIsolatedPods := make(map[types.PodRef]bool)
+	for _, podIsolation := range podIsolations {
+		if podIsolation.IsIngressIsolated {
+			ingressIsolatedPods[podIsolation.Pod] = true
+		}
+	}
+	for _, exposure := range exposures {
+		ports := make([]string, 0, len(exposure.Ports))
+		for _, hostPort := range exposure.Ports {
+			ports = append(ports, fmt.Sprintf("%d/%s", hostPort.HostPort, hostPort.Protocol))
+		}
+		resource := types.ResourceRef{Kind: "Pod", Name: exposure.Pod.Name, Namespace: exposure.Pod.Namespace}
+		parameters := map[string]string{"namespace": exposure.Pod.Namespace, "pod": exposure.Pod.Name,
+			"node": exposure.Node, "ports": strings.Join(ports, ", ")}
+		if ingressIsolatedPods[exposure.Pod] {
+			findings = append(findings, analyzer.newFinding(RuleHostPortExposed, SeverityHigh, resource, nil,
+				CodeHostPortBypassesIsolation, parameters))
+		} else {
+			findings = append(findings, analyzer.newFinding(RuleHostPortExposed, SeverityMedium, resource, nil,
+				CodeHostPortExposed, parameters))
+		}
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) selectsAnyPod(policy *networkingv1.NetworkPolicy, pods []*corev1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Namespace == policy.Namespace && utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFinding renders the message of the finding with the default catalog
+func NewFinding(rule string, severity string, resource types.ResourceRef, code string,
+	parameters map[string]string) *types.Finding {
+	return analyzerImpl{}.newFinding(rule, severity, resource, nil, code, parameters)
+}
+
+func NewRouteFinding(rule string, severity string, source types.PodRef, target types.PodRef, code string,
+	parameters map[string]string) *types.Finding {
+	return analyzerImpl{}.newRouteFinding(rule, severity, source, target, code, parameters)
+}
+
+func Fingerprint(finding *types.Finding) string {
+	// The fingerprint only depends on what is flagged, so that it is stable across analyses
+	parts := []string{finding.Rule, finding.Resource.Kind, finding.Resource.Namespace, finding.Resource.Name}
+	if finding.Peer != nil {
+		parts = append(parts, finding.Peer.Kind, finding.Peer.Namespace, finding.Peer.Name)
+	}
+	hash := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,717 @@
+package finding
+
+import (
+	"github.com/google/go-cmp/cmp"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/authoring"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		intents      []config.Intent
+		monitoring   *config.MonitoringConfig
+		clusterState ClusterState
+	}
+	pod1 := types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns"}
+	policy1 := types.ResourceRef{Kind: "NetworkPolicy", Name: "policy1", Namespace: "other"}
+	policy2 := types.ResourceRef{Kind: "NetworkPolicy", Name: "policy2", Namespace: "ns"}
+	dnsPod := types.PodRef{Name: "coredns", Namespace: "kube-system"}
+	shopDNSFinding := NewFinding(RuleDNSEgressBlocked, SeverityHigh, types.ResourceRef{Kind: "Namespace", Name: "shop"},
+		CodeDNSEgressBlocked, map[string]string{"namespace": "shop", "pods": "app2", "dns": "kube-dns"})
+	shopDNSFinding.Remediation = &types.Remediation{
+		Operation: RemediationCreate,
+		Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: "allow-dns-egress", Namespace: "shop"},
+		Manifest:  authoring.DNSEgressPolicy("shop"),
+	}
+	ingressNginxFinding := &types.Finding{Rule: RuleIngressBackendUnreachable, Severity: SeverityHigh,
+		Resource: types.ResourceRef{Kind: "Ingress", Name: "front", Namespace: "shop"},
+		Peer:     &types.ResourceRef{Kind: "Service", Name: "front", Namespace: "shop"},
+		Message: "ingress shop/front routes to service shop/front, but ingress controller ingress-nginx cannot " +
+			"reach its pods front-2",
+		Code: CodeIngressBackendUnreachable, Parameters: map[string]string{"namespace": "shop", "ingress": "front",
+			"serviceNamespace": "shop", "service": "front", "controller": "ingress-nginx", "pods": "front-2"}}
+	ingressNginxFinding.Fingerprint = Fingerprint(ingressNginxFinding)
+	traefikFinding := &types.Finding{Rule: RuleIngressBackendUnreachable, Severity: SeverityHigh,
+		Resource: types.ResourceRef{Kind: "Ingress", Name: "api", Namespace: "shop"},
+		Peer:     &types.ResourceRef{Kind: "Service", Name: "api", Namespace: "shop"},
+		Message: "ingress shop/api routes to service shop/api, but ingress controller traefik cannot " +
+			"reach its pods api-1",
+		Code: CodeIngressBackendUnreachable, Parameters: map[string]string{"namespace": "shop", "ingress": "api",
+			"serviceNamespace": "shop", "service": "api", "controller": "traefik", "pods": "api-1"}}
+	traefikFinding.Fingerprint = Fingerprint(traefikFinding)
+	validatorFinding := &types.Finding{Rule: RuleAPIServerBackendUnreachable, Severity: SeverityHigh,
+		Resource: types.ResourceRef{Kind: "ValidatingWebhookConfiguration", Name: "validator"},
+		Peer:     &types.ResourceRef{Kind: "Service", Name: "validator", Namespace: "policy"},
+		Message: "validating webhook validator calls service policy/validator, whose pods validator-1 do not " +
+			"accept traffic from the API server",
+		Code: CodeValidatingWebhookUnreachable, Parameters: map[string]string{"name": "validator",
+			"serviceNamespace": "policy", "service": "validator", "pods": "validator-1"}}
+	validatorFinding.Fingerprint = Fingerprint(validatorFinding)
+	customAPIFinding := &types.Finding{Rule: RuleAPIServerBackendUnreachable, Severity: SeverityHigh,
+		Resource: types.ResourceRef{Kind: "APIService", Name: "v1.custom.io"},
+		Peer:     &types.ResourceRef{Kind: "Service", Name: "custom-api", Namespace: "custom"},
+		Message: "aggregated API v1.custom.io calls service custom/custom-api, whose pods custom-api-1 do not " +
+			"accept traffic from the API server",
+		Code: CodeAggregatedAPIUnreachable, Parameters: map[string]string{"name": "v1.custom.io",
+			"serviceNamespace": "custom", "service": "custom-api", "pods": "custom-api-1"}}
+	customAPIFinding.Fingerprint = Fingerprint(customAPIFinding)
+	webDNSFinding := NewFinding(RuleDNSEgressBlocked, SeverityHigh, types.ResourceRef{Kind: "Namespace", Name: "web"},
+		CodeDNSEgressBlocked, map[string]string{"namespace": "web", "pods": "app3", "dns": "kube-dns"})
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "no finding for isolated pods selected by policies",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("pod1").WithNamespace("ns").WithLabel("app", "foo").Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("policy1").WithNamespace("ns").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "foo").Build()).
+							Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "pod1", Namespace: "ns"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings:   []*types.Finding{},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "non isolated pods and unused policies are flagged",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("pod1").WithNamespace("ns").WithLabel("app", "foo").Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("policy1").WithNamespace("other").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().Build()).Build(),
+						testutils.NewNetworkPolicyBuilder().WithName("policy2").WithNamespace("ns").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "bar").Build()).
+							Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "pod1", Namespace: "ns"}, IsIngressIsolated: false,
+							IsEgressIsolated: false},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					{Fingerprint: "621a33e21f772146", Rule: RulePodNotIngressIsolated, Severity: SeverityMedium,
+						Resource: pod1, Message: "pod ns/pod1 accepts incoming traffic from any source",
+						Code: CodePodNotIngressIsolated, Parameters: map[string]string{"namespace": "ns", "pod": "pod1"}},
+					{Fingerprint: "1e158f2d3fe49b1d", Rule: RulePodNotEgressIsolated, Severity: SeverityLow,
+						Resource: pod1, Message: "pod ns/pod1 can send traffic to any destination",
+						Code: CodePodNotEgressIsolated, Parameters: map[string]string{"namespace": "ns", "pod": "pod1"}},
+					{Fingerprint: "21e9cee83f18ac7b", Rule: RuleUnusedNetworkPolicy, Severity: SeverityLow,
+						Resource: policy1, Message: "network policy other/policy1 does not select any pod",
+						Code:        CodeUnusedNetworkPolicy,
+						Parameters:  map[string]string{"namespace": "other", "policy": "policy1"},
+						Remediation: &types.Remediation{Operation: RemediationDelete, Resource: policy1}},
+					{Fingerprint: "233133e36cc2d1f0", Rule: RuleUnusedNetworkPolicy, Severity: SeverityLow,
+						Resource: policy2, Message: "network policy ns/policy2 does not select any pod",
+						Code:        CodeUnusedNetworkPolicy,
+						Parameters:  map[string]string{"namespace": "ns", "policy": "policy2"},
+						Remediation: &types.Remediation{Operation: RemediationDelete, Resource: policy2}},
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "allowed routes without intent are flagged when intents are declared",
+			args: args{
+				intents: []config.Intent{{Purpose: "purpose"}},
+				clusterState: ClusterState{
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "pod1", Namespace: "ns"},
+							TargetPod: types.PodRef{Name: "pod2", Namespace: "ns"}, Intents: []string{"purpose"}},
+						{SourcePod: types.PodRef{Name: "pod2", Namespace: "ns"},
+							TargetPod: types.PodRef{Name: "pod1", Namespace: "ns"}, Intents: []string{}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewRouteFinding(RuleRouteWithoutIntent, SeverityLow, types.PodRef{Name: "pod2", Namespace: "ns"},
+						types.PodRef{Name: "pod1", Namespace: "ns"}, CodeRouteWithoutIntent, map[string]string{
+							"sourceNamespace": "ns", "sourcePod": "pod2", "targetNamespace": "ns", "targetPod": "pod1"}),
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "allowed routes are not flagged when no intent is declared",
+			args: args{
+				intents: nil,
+				clusterState: ClusterState{
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "pod2", Namespace: "ns"},
+							TargetPod: types.PodRef{Name: "pod1", Namespace: "ns"}, Intents: []string{}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings:   []*types.Finding{},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "namespaces with egress isolated pods which cannot reach the cluster DNS are flagged",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("coredns").WithNamespace("kube-system").
+							WithLabel("k8s-app", "kube-dns").Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: dnsPod, IsIngressIsolated: true, IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "app1", Namespace: "shop"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "app2", Namespace: "shop"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "app3", Namespace: "web"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "app4", Namespace: "web"}, IsIngressIsolated: true,
+							IsEgressIsolated: false},
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "app1", Namespace: "shop"}, TargetPod: dnsPod,
+							Ports: []int32{53}},
+						// Names are resolved over UDP
+						{SourcePod: types.PodRef{Name: "app2", Namespace: "shop"}, TargetPod: dnsPod,
+							Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+								{Protocol: corev1.ProtocolTCP, Ports: []int32{53}}}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RulePodNotEgressIsolated, SeverityLow,
+						types.ResourceRef{Kind: "Pod", Name: "app4", Namespace: "web"},
+						CodePodNotEgressIsolated, map[string]string{"namespace": "web", "pod": "app4"}),
+					shopDNSFinding,
+					// Allowing DNS to all pods of the namespace would isolate app4, no remediation is proposed
+					webDNSFinding,
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "privileged pods reachable from other namespaces are flagged",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("agent").WithNamespace("monitoring").
+							WithPrivilegedContainer().Build(),
+						testutils.NewPodBuilder().WithName("debug").WithNamespace("ops").WithHostPID().Build(),
+						testutils.NewPodBuilder().WithName("ipc").WithNamespace("ops").WithHostIPC().Build(),
+						testutils.NewPodBuilder().WithName("app").WithNamespace("shop").Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "agent", Namespace: "monitoring"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "debug", Namespace: "ops"}, IsIngressIsolated: false,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "ipc", Namespace: "ops"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "app", Namespace: "shop"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "app", Namespace: "shop"},
+							TargetPod: types.PodRef{Name: "agent", Namespace: "monitoring"}},
+						{SourcePod: types.PodRef{Name: "debug", Namespace: "ops"},
+							TargetPod: types.PodRef{Name: "agent", Namespace: "monitoring"}},
+						{SourcePod: types.PodRef{Name: "debug", Namespace: "ops"},
+							TargetPod: types.PodRef{Name: "ipc", Namespace: "ops"}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RulePodNotIngressIsolated, SeverityMedium,
+						types.ResourceRef{Kind: "Pod", Name: "debug", Namespace: "ops"},
+						CodePodNotIngressIsolated, map[string]string{"namespace": "ops", "pod": "debug"}),
+					NewFinding(RulePrivilegedPodExposed, SeverityHigh,
+						types.ResourceRef{Kind: "Pod", Name: "agent", Namespace: "monitoring"},
+						CodePrivilegedPodExposed, map[string]string{"namespace": "monitoring", "pod": "agent",
+							"sourceNamespaces": "ops, shop"}),
+					NewFinding(RulePrivilegedPodExposed, SeverityHigh,
+						types.ResourceRef{Kind: "Pod", Name: "debug", Namespace: "ops"},
+						CodePrivilegedPodOpen, map[string]string{"namespace": "ops", "pod": "debug"}),
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "pods exposing host ports are flagged, more severely when ingress isolated",
+			args: args{
+				clusterState: ClusterState{
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "app", Namespace: "shop"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "exporter", Namespace: "monitoring"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+					},
+					HostPortExposures: []*types.HostPortExposure{
+						{Node: "node1", Pod: types.PodRef{Name: "app", Namespace: "shop"},
+							Ports: []types.HostPort{{HostPort: 30090, ContainerPort: 9090, Protocol: "TCP"}}},
+						{Node: "node2", Pod: types.PodRef{Name: "ingress-1", Namespace: "ingress"},
+							Ports: []types.HostPort{
+								{HostPort: 80, ContainerPort: 80, Protocol: "TCP"},
+								{HostPort: 443, ContainerPort: 443, Protocol: "TCP"},
+							}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RuleHostPortExposed, SeverityHigh,
+						types.ResourceRef{Kind: "Pod", Name: "app", Namespace: "shop"},
+						CodeHostPortBypassesIsolation, map[string]string{"namespace": "shop", "pod": "app",
+							"node": "node1", "ports": "30090/TCP"}),
+					NewFinding(RuleHostPortExposed, SeverityMedium,
+						types.ResourceRef{Kind: "Pod", Name: "ingress-1", Namespace: "ingress"},
+						CodeHostPortExposed, map[string]string{"namespace": "ingress", "pod": "ingress-1",
+							"node": "node2", "ports": "80/TCP, 443/TCP"}),
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "ingress backends which their controller cannot reach are flagged",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("nginx").WithNamespace("ingress").
+							WithLabel("app.kubernetes.io/name", "ingress-nginx").Build(),
+						testutils.NewPodBuilder().WithName("traefik").WithNamespace("ingress").
+							WithLabel("app.kubernetes.io/name", "traefik").Build(),
+					},
+					Services: []*types.Service{
+						{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{
+							{Name: "front-1", Namespace: "shop"}, {Name: "front-2", Namespace: "shop"}}},
+						{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{
+							{Name: "api-1", Namespace: "shop"}}},
+					},
+					Ingresses: []*types.Ingress{
+						{Name: "front", Namespace: "shop", IngressClass: "nginx",
+							TargetServices: []types.ServiceRef{{Name: "front", Namespace: "shop"}}},
+						{Name: "api", Namespace: "shop",
+							TargetServices: []types.ServiceRef{{Name: "api", Namespace: "shop"}}},
+						{Name: "other", Namespace: "shop", IngressClass: "custom",
+							TargetServices: []types.ServiceRef{{Name: "api", Namespace: "shop"}}},
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "nginx", Namespace: "ingress"},
+							TargetPod: types.PodRef{Name: "front-1", Namespace: "shop"}},
+						{SourcePod: types.PodRef{Name: "nginx", Namespace: "ingress"},
+							TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					ingressNginxFinding,
+					traefikFinding,
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "pods whose metrics cannot be scraped by the monitoring are flagged",
+			args: args{
+				monitoring: &config.MonitoringConfig{Scrapers: []config.PodSelector{
+					{Namespace: "monitoring", PodLabels: map[string]string{"app": "prometheus"}},
+				}},
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("prometheus").WithNamespace("monitoring").
+							WithLabel("app", "prometheus").WithContainerPort("metrics", 9090).Build(),
+						testutils.NewPodBuilder().WithName("api").WithNamespace("shop").
+							WithContainerPort("http", 8080).WithContainerPort("metrics", 9100).
+							WithAnnotation("prometheus.io/scrape", "true").
+							WithAnnotation("prometheus.io/port", "8081").Build(),
+						testutils.NewPodBuilder().WithName("db").WithNamespace("shop").
+							WithContainerPort("http-metrics", 9187).Build(),
+						testutils.NewPodBuilder().WithName("front").WithNamespace("shop").
+							WithContainerPort("http", 8080).Build(),
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "prometheus", Namespace: "monitoring"},
+							TargetPod: types.PodRef{Name: "api", Namespace: "shop"}, Ports: []int32{9100}},
+						{SourcePod: types.PodRef{Name: "prometheus", Namespace: "monitoring"},
+							TargetPod: types.PodRef{Name: "db", Namespace: "shop"}, Ports: nil},
+						{SourcePod: types.PodRef{Name: "front", Namespace: "shop"},
+							TargetPod: types.PodRef{Name: "api", Namespace: "shop"}, Ports: nil},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RuleMetricsScrapeBlocked, SeverityMedium,
+						types.ResourceRef{Kind: "Pod", Name: "api", Namespace: "shop"},
+						CodeMetricsScrapeBlocked, map[string]string{"namespace": "shop", "pod": "api", "ports": "8081"}),
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "service ports blocked from the consumers expected by the intents are flagged",
+			args: args{
+				intents: []config.Intent{{Purpose: "orders",
+					Source: config.PodSelector{Namespace: "shop", PodLabels: map[string]string{"app": "front"}},
+					Target: config.PodSelector{Namespace: "shop", PodLabels: map[string]string{"app": "api"}}}},
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("front").WithNamespace("shop").
+							WithLabel("app", "front").Build(),
+						testutils.NewPodBuilder().WithName("batch").WithNamespace("shop").
+							WithLabel("app", "batch").Build(),
+						testutils.NewPodBuilder().WithName("api").WithNamespace("shop").
+							WithLabel("app", "api").WithContainerPort("http", 8080).
+							WithContainerPort("grpc", 9090).Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("api-ingress").WithNamespace("shop").
+							WithPodSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}).
+							WithTypes(networkingv1.PolicyTypeIngress).Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "api", Namespace: "shop"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: types.PodRef{Name: "front", Namespace: "shop"},
+							TargetPod: types.PodRef{Name: "api", Namespace: "shop"}, Ports: []int32{8080},
+							Intents: []string{"orders"}},
+					},
+					Services: []*types.Service{
+						{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{{Name: "api", Namespace: "shop"}}},
+					},
+					ServiceSpecs: []*corev1.Service{
+						testutils.NewServiceBuilder().WithName("api").WithNamespace("shop").
+							WithNamedTargetPort(80, "http").WithNamedTargetPort(90, "grpc").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RuleServicePortBlocked, SeverityMedium,
+						types.ResourceRef{Kind: "Service", Name: "api", Namespace: "shop"},
+						CodeServicePortBlocked, map[string]string{"namespace": "shop", "service": "api", "port": "90",
+							"targetPort": "9090", "consumers": "shop/front", "policies": "api-ingress"}),
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+		{
+			name: "services called by the API server which do not accept its traffic are flagged",
+			args: args{
+				clusterState: ClusterState{
+					Nodes: []*corev1.Node{
+						testutils.NewNodeBuilder().WithName("cp").WithLabel("node-role.kubernetes.io/control-plane", "").
+							WithInternalIP("10.0.0.1").Build(),
+						testutils.NewNodeBuilder().WithName("worker").WithInternalIP("10.0.1.1").Build(),
+					},
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("webhook-1").WithNamespace("certs").Build(),
+						testutils.NewPodBuilder().WithName("validator-1").WithNamespace("policy").Build(),
+						testutils.NewPodBuilder().WithName("metrics-server-1").WithNamespace("kube-system").Build(),
+						testutils.NewPodBuilder().WithName("custom-api-1").WithNamespace("custom").Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "webhook-1", Namespace: "certs"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "validator-1", Namespace: "policy"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "metrics-server-1", Namespace: "kube-system"},
+							IsIngressIsolated: false, IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "custom-api-1", Namespace: "custom"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("control-plane").WithNamespace("certs").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().Build()).
+							WithTypes(networkingv1.PolicyTypeIngress).
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+								{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/24"}}}}).Build(),
+						testutils.NewNetworkPolicyBuilder().WithName("same-namespace").WithNamespace("policy").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().Build()).
+							WithTypes(networkingv1.PolicyTypeIngress).
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+								{PodSelector: testutils.NewLabelSelectorBuilder().Build()}}}).Build(),
+						testutils.NewNetworkPolicyBuilder().WithName("workers").WithNamespace("custom").
+							WithPodSelector(testutils.NewLabelSelectorBuilder().Build()).
+							WithTypes(networkingv1.PolicyTypeIngress).
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+								{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/16", Except: []string{"10.0.0.0/24"}}},
+							}}).Build(),
+					},
+					Services: []*types.Service{
+						{Name: "webhook", Namespace: "certs",
+							TargetPods: []types.PodRef{{Name: "webhook-1", Namespace: "certs"}}},
+						{Name: "validator", Namespace: "policy",
+							TargetPods: []types.PodRef{{Name: "validator-1", Namespace: "policy"}}},
+						{Name: "metrics-server", Namespace: "kube-system",
+							TargetPods: []types.PodRef{{Name: "metrics-server-1", Namespace: "kube-system"}}},
+						{Name: "custom-api", Namespace: "custom",
+							TargetPods: []types.PodRef{{Name: "custom-api-1", Namespace: "custom"}}},
+					},
+					ValidatingWebhookConfigurations: []*admissionregistrationv1.ValidatingWebhookConfiguration{
+						{ObjectMeta: metav1.ObjectMeta{Name: "validator"},
+							Webhooks: []admissionregistrationv1.ValidatingWebhook{
+								{Name: "pods.validator.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+									Service: &admissionregistrationv1.ServiceReference{Name: "validator",
+										Namespace: "policy"}}},
+								{Name: "services.validator.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+									Service: &admissionregistrationv1.ServiceReference{Name: "validator",
+										Namespace: "policy"}}},
+							}},
+					},
+					MutatingWebhookConfigurations: []*admissionregistrationv1.MutatingWebhookConfiguration{
+						{ObjectMeta: metav1.ObjectMeta{Name: "certs"},
+							Webhooks: []admissionregistrationv1.MutatingWebhook{
+								{Name: "certs.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+									Service: &admissionregistrationv1.ServiceReference{Name: "webhook",
+										Namespace: "certs"}}},
+							}},
+					},
+					APIServices: []*types.APIService{
+						{Name: "v1.apps"},
+						{Name: "v1beta1.metrics.k8s.io",
+							Service: &types.ServiceRef{Name: "metrics-server", Namespace: "kube-system"}},
+						{Name: "v1.custom.io", Service: &types.ServiceRef{Name: "custom-api", Namespace: "custom"}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Findings: []*types.Finding{
+					NewFinding(RulePodNotIngressIsolated, SeverityMedium,
+						types.ResourceRef{Kind: "Pod", Name: "metrics-server-1", Namespace: "kube-system"},
+						CodePodNotIngressIsolated, map[string]string{"namespace": "kube-system",
+							"pod": "metrics-server-1"}),
+					validatorFinding,
+					customAPIFinding,
+				},
+				Compliance: []*types.ComplianceProfile{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, tt.args.intents, tt.args.monitoring, nil, nil, nil)
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	pod := types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns"}
+	otherPod := types.ResourceRef{Kind: "Pod", Name: "pod2", Namespace: "ns"}
+	code := CodePodNotIngressIsolated
+	finding := NewFinding(RulePodNotIngressIsolated, SeverityMedium, pod, code, nil)
+	if finding.Fingerprint != NewFinding(RulePodNotIngressIsolated, SeverityLow, pod, CodePodNotEgressIsolated,
+		map[string]string{"pod": "pod1"}).Fingerprint {
+		t.Errorf("Fingerprint() depends on the severity or message")
+	}
+	if finding.Fingerprint == NewFinding(RulePodNotEgressIsolated, SeverityMedium, pod, code, nil).Fingerprint {
+		t.Errorf("Fingerprint() does not depend on the rule")
+	}
+	if finding.Fingerprint == NewFinding(RulePodNotIngressIsolated, SeverityMedium, otherPod, code, nil).Fingerprint {
+		t.Errorf("Fingerprint() does not depend on the resource")
+	}
+	source1 := types.PodRef{Name: "source1", Namespace: "ns"}
+	source2 := types.PodRef{Name: "source2", Namespace: "ns"}
+	target := types.PodRef{Name: "target", Namespace: "ns"}
+	if NewRouteFinding("rule", SeverityHigh, source1, target, code, nil).Fingerprint ==
+		NewRouteFinding("rule", SeverityHigh, source2, target, code, nil).Fingerprint {
+		t.Errorf("Fingerprint() does not depend on the peer")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	routeParameters := map[string]string{"sourceNamespace": "dev", "sourcePod": "app", "targetNamespace": "prod",
+		"targetPod": "db", "rule": "no-dev-to-prod"}
+	apiServerParameters := map[string]string{"name": "validator", "serviceNamespace": "policy",
+		"service": "validator", "pods": "validator-1, validator-2"}
+	tests := []struct {
+		name            string
+		messages        map[string]string
+		code            string
+		parameters      map[string]string
+		expectedMessage string
+	}{
+		{
+			name:            "pods not ingress isolated",
+			code:            CodePodNotIngressIsolated,
+			parameters:      map[string]string{"namespace": "ns", "pod": "pod1"},
+			expectedMessage: "pod ns/pod1 accepts incoming traffic from any source",
+		},
+		{
+			name:            "pods not egress isolated",
+			code:            CodePodNotEgressIsolated,
+			parameters:      map[string]string{"namespace": "ns", "pod": "pod1"},
+			expectedMessage: "pod ns/pod1 can send traffic to any destination",
+		},
+		{
+			name:            "unused network policies",
+			code:            CodeUnusedNetworkPolicy,
+			parameters:      map[string]string{"namespace": "ns", "policy": "policy1"},
+			expectedMessage: "network policy ns/policy1 does not select any pod",
+		},
+		{
+			name:            "routes without intent",
+			code:            CodeRouteWithoutIntent,
+			parameters:      routeParameters,
+			expectedMessage: "traffic from pod dev/app to pod prod/db is allowed but matches no declared intent",
+		},
+		{
+			name:       "blocked DNS egress",
+			code:       CodeDNSEgressBlocked,
+			parameters: map[string]string{"namespace": "shop", "pods": "app1, app2", "dns": "kube-dns"},
+			expectedMessage: "pods app1, app2 of namespace shop cannot resolve DNS names, their egress to kube-dns " +
+				"is not allowed",
+		},
+		{
+			name:            "privileged pods reachable from any namespace",
+			code:            CodePrivilegedPodOpen,
+			parameters:      map[string]string{"namespace": "ops", "pod": "debug"},
+			expectedMessage: "privileged pod ops/debug accepts incoming traffic from any namespace",
+		},
+		{
+			name:            "privileged pods reachable from other namespaces",
+			code:            CodePrivilegedPodExposed,
+			parameters:      map[string]string{"namespace": "ops", "pod": "debug", "sourceNamespaces": "dev, shop"},
+			expectedMessage: "privileged pod ops/debug accepts incoming traffic from namespaces dev, shop",
+		},
+		{
+			name: "unreachable ingress backends",
+			code: CodeIngressBackendUnreachable,
+			parameters: map[string]string{"namespace": "shop", "ingress": "front", "serviceNamespace": "shop",
+				"service": "front", "controller": "traefik", "pods": "front-1"},
+			expectedMessage: "ingress shop/front routes to service shop/front, but ingress controller traefik cannot " +
+				"reach its pods front-1",
+		},
+		{
+			name:       "blocked metrics scraping",
+			code:       CodeMetricsScrapeBlocked,
+			parameters: map[string]string{"namespace": "shop", "pod": "api", "ports": "8081, 9100"},
+			expectedMessage: "metrics of pod shop/api on ports 8081, 9100 cannot be scraped, their ingress from the " +
+				"monitoring pods is not allowed",
+		},
+		{
+			name: "blocked service ports",
+			code: CodeServicePortBlocked,
+			parameters: map[string]string{"namespace": "shop", "service": "api", "port": "90", "targetPort": "9090",
+				"consumers": "shop/front", "policies": "api-ingress"},
+			expectedMessage: "service shop/api exposes port 90 on target port 9090, but ingress policies api-ingress " +
+				"of its pods do not allow it from its consumers shop/front",
+		},
+		{
+			name:       "unreachable validating webhooks",
+			code:       CodeValidatingWebhookUnreachable,
+			parameters: apiServerParameters,
+			expectedMessage: "validating webhook validator calls service policy/validator, whose pods validator-1, " +
+				"validator-2 do not accept traffic from the API server",
+		},
+		{
+			name:       "unreachable mutating webhooks",
+			code:       CodeMutatingWebhookUnreachable,
+			parameters: apiServerParameters,
+			expectedMessage: "mutating webhook validator calls service policy/validator, whose pods validator-1, " +
+				"validator-2 do not accept traffic from the API server",
+		},
+		{
+			name:       "unreachable aggregated APIs",
+			code:       CodeAggregatedAPIUnreachable,
+			parameters: apiServerParameters,
+			expectedMessage: "aggregated API validator calls service policy/validator, whose pods validator-1, " +
+				"validator-2 do not accept traffic from the API server",
+		},
+		{
+			name:            "missing default deny policies",
+			code:            CodeMissingDefaultDeny,
+			parameters:      map[string]string{"namespace": "prod", "policyTypes": "ingress and egress"},
+			expectedMessage: "namespace prod has no default deny ingress and egress policy",
+		},
+		{
+			name:            "forbidden routes",
+			code:            CodeForbiddenRoute,
+			parameters:      routeParameters,
+			expectedMessage: "traffic from pod dev/app to pod prod/db is allowed but forbidden by rule no-dev-to-prod",
+		},
+		{
+			name:       "routes expected denied",
+			code:       CodeRouteExpectedDenied,
+			parameters: routeParameters,
+			expectedMessage: "traffic from pod dev/app to pod prod/db is allowed but connectivity rule no-dev-to-prod " +
+				"expects it denied",
+		},
+		{
+			name: "routes expected allowed on a port",
+			code: CodeRouteExpectedAllowed,
+			parameters: map[string]string{"sourceNamespace": "dev", "sourcePod": "app", "targetNamespace": "prod",
+				"targetPod": "db", "rule": "prod/dev-to-db", "port": "5432"},
+			expectedMessage: "traffic from pod dev/app to pod prod/db on port 5432 is denied but connectivity rule " +
+				"prod/dev-to-db expects it allowed",
+		},
+		{
+			name:       "exposed host ports",
+			code:       CodeHostPortExposed,
+			parameters: map[string]string{"namespace": "ingress", "pod": "nginx", "node": "node1", "ports": "80/TCP"},
+			expectedMessage: "pod ingress/nginx exposes host ports 80/TCP on node node1, reachable by anything " +
+				"reaching the node",
+		},
+		{
+			name:       "host ports bypassing isolation",
+			code:       CodeHostPortBypassesIsolation,
+			parameters: map[string]string{"namespace": "shop", "pod": "app", "node": "node1", "ports": "30090/TCP"},
+			expectedMessage: "pod shop/app is ingress isolated, but its host ports 30090/TCP on node node1 are " +
+				"reachable whatever its network policies",
+		},
+		{
+			name:            "configured messages replace the default ones",
+			messages:        map[string]string{CodeUnusedNetworkPolicy: "{{.policy}} ({{.namespace}}) est inutilisée"},
+			code:            CodeUnusedNetworkPolicy,
+			parameters:      map[string]string{"namespace": "ns", "policy": "policy1"},
+			expectedMessage: "policy1 (ns) est inutilisée",
+		},
+		{
+			name:            "missing parameters are rendered empty",
+			messages:        map[string]string{CodeUnusedNetworkPolicy: "{{.policy}}{{.unknown}} is unused"},
+			code:            CodeUnusedNetworkPolicy,
+			parameters:      map[string]string{"namespace": "ns", "policy": "policy1"},
+			expectedMessage: "policy1 is unused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, nil, nil, tt.messages).(analyzerImpl)
+			finding := analyzer.newFinding("rule", SeverityLow, types.ResourceRef{}, nil, tt.code, tt.parameters)
+			if diff := cmp.Diff(tt.expectedMessage, finding.Message); diff != "" {
+				t.Errorf("newFinding() message mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
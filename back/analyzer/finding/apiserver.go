@@ -0,0 +1,195 @@
+package finding
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/types"
+	"net"
+	"sort"
+	"strings"
+)
+
+var controlPlaneLabels = []string{"node-role.kubernetes.io/control-plane", "node-role.kubernetes.io/master"}
+
+type apiServerBackend struct {
+	resource types.ResourceRef
+	code     string
+	service  types.ServiceRef
+}
+
+// The API server calls admission webhooks and aggregated API services from the addresses of the control plane nodes.
+// Managed control planes do not appear as nodes, the pods of these services must then at least accept an IP block.
+// Ports are not checked.
+func (analyzer analyzerImpl) apiServerBackendFindings(clusterState ClusterState) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	apiServerIPs := analyzer.apiServerIPsOf(clusterState.Nodes)
+	ingressIsolatedPods := make(map[types.PodRef]bool)
+	for _, podIsolation := range clusterState.PodIsolations {
+		if podIsolation.IsIngressIsolated {
+			ingressIsolatedPods[podIsolation.Pod] = true
+		}
+	}
+	podsByRef := make(map[types.PodRef]*corev1.Pod)
+	for _, pod := range clusterState.Pods {
+		podsByRef[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod
+	}
+	servicesByRef := make(map[types.ServiceRef]*types.Service)
+	for _, service := range clusterState.Services {
+		servicesByRef[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = service
+	}
+	for _, backend := range analyzer.apiServerBackendsOf(clusterState) {
+		service, ok := servicesByRef[backend.service]
+		if !ok {
+			continue
+		}
+		blockedPods := make([]string, 0)
+		for _, podRef := range service.TargetPods {
+			pod, ok := podsByRef[podRef]
+			if !ok || !ingressIsolatedPods[podRef] {
+				continue
+			}
+			if !analyzer.acceptsAPIServer(pod, clusterState.NetworkPolicies, apiServerIPs) {
+				blockedPods = append(blockedPods, pod.Name)
+			}
+		}
+		if len(blockedPods) == 0 {
+			continue
+		}
+		findings = append(findings, analyzer.newFinding(RuleAPIServerBackendUnreachable, SeverityHigh,
+			backend.resource, &types.ResourceRef{Kind: "Service", Name: backend.service.Name,
+				Namespace: backend.service.Namespace}, backend.code, map[string]string{
+				"name":             backend.resource.Name,
+				"serviceNamespace": backend.service.Namespace,
+				"service":          backend.service.Name,
+				"pods":             strings.Join(blockedPods, ", "),
+			}))
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) apiServerBackendsOf(clusterState ClusterState) []apiServerBackend {
+	backends := make([]apiServerBackend, 0)
+	seen := make(map[apiServerBackend]bool)
+	addBackend := func(backend apiServerBackend) {
+		if !seen[backend] {
+			seen[backend] = true
+			backends = append(backends, backend)
+		}
+	}
+	for _, configuration := range clusterState.ValidatingWebhookConfigurations {
+		for _, webhook := range configuration.Webhooks {
+			if service, ok := analyzer.webhookServiceOf(webhook.ClientConfig); ok {
+				addBackend(apiServerBackend{
+					resource: types.ResourceRef{Kind: "ValidatingWebhookConfiguration", Name: configuration.Name},
+					code:     CodeValidatingWebhookUnreachable,
+					service:  service,
+				})
+			}
+		}
+	}
+	for _, configuration := range clusterState.MutatingWebhookConfigurations {
+		for _, webhook := range configuration.Webhooks {
+			if service, ok := analyzer.webhookServiceOf(webhook.ClientConfig); ok {
+				addBackend(apiServerBackend{
+					resource: types.ResourceRef{Kind: "MutatingWebhookConfiguration", Name: configuration.Name},
+					code:     CodeMutatingWebhookUnreachable,
+					service:  service,
+				})
+			}
+		}
+	}
+	for _, apiService := range clusterState.APIServices {
+		if apiService.Service == nil {
+			// Local API services are served by the API server itself
+			continue
+		}
+		addBackend(apiServerBackend{
+			resource: types.ResourceRef{Kind: "APIService", Name: apiService.Name},
+			code:     CodeAggregatedAPIUnreachable,
+			service:  *apiService.Service,
+		})
+	}
+	return backends
+}
+
+func (analyzer analyzerImpl) webhookServiceOf(
+	clientConfig admissionregistrationv1.WebhookClientConfig) (types.ServiceRef, bool) {
+	if clientConfig.Service == nil {
+		// Webhooks called by URL may be hosted outside the cluster
+		return types.ServiceRef{}, false
+	}
+	return types.ServiceRef{Name: clientConfig.Service.Name, Namespace: clientConfig.Service.Namespace}, true
+}
+
+func (analyzer analyzerImpl) apiServerIPsOf(nodes []*corev1.Node) []net.IP {
+	ips := make([]net.IP, 0)
+	for _, node := range nodes {
+		isControlPlane := false
+		for _, label := range controlPlaneLabels {
+			if _, ok := node.Labels[label]; ok {
+				isControlPlane = true
+			}
+		}
+		if !isControlPlane {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP {
+				continue
+			}
+			if ip := net.ParseIP(address.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i].String() < ips[j].String() })
+	return ips
+}
+
+func (analyzer analyzerImpl) acceptsAPIServer(pod *corev1.Pod, policies []*networkingv1.NetworkPolicy,
+	apiServerIPs []net.IP) bool {
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace || !utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			continue
+		}
+		for _, rule := range policy.Spec.Ingress {
+			if len(rule.From) == 0 {
+				return true
+			}
+			for _, peer := range rule.From {
+				if peer.IPBlock != nil && analyzer.ipBlockContainsAny(peer.IPBlock, apiServerIPs) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) ipBlockContainsAny(ipBlock *networkingv1.IPBlock, ips []net.IP) bool {
+	if len(ips) == 0 {
+		return true
+	}
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if !cidr.Contains(ip) {
+			continue
+		}
+		excepted := false
+		for _, except := range ipBlock.Except {
+			_, exceptCIDR, err := net.ParseCIDR(except)
+			if err == nil && exceptCIDR.Contains(ip) {
+				excepted = true
+			}
+		}
+		if !excepted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/analyzer/utils"
+	"karto/authoring"
+	"karto/config"
+	"karto/types"
+	"sort"
+	"strings"
+)
+
+type complianceCheck struct {
+	rule  string
+	title string
+}
+
+type complianceProfile struct {
+	title  string
+	checks []complianceCheck
+}
+
+// Profiles share the checks covering the same recommendation, whose findings are then raised once
+var complianceProfiles = map[string]complianceProfile{
+	config.ComplianceProfileCIS: {
+		title: "CIS Kubernetes Benchmark",
+		checks: []complianceCheck{
+			{rule: RuleComplianceDefaultDeny, title: "5.3.2 Ensure that all Namespaces have Network Policies defined"},
+		},
+	},
+	config.ComplianceProfileNSA: {
+		title: "NSA/CISA Kubernetes Hardening Guidance",
+		checks: []complianceCheck{
+			{rule: RuleComplianceDefaultDeny, title: "Deny all ingress and egress traffic by default in every namespace"},
+			{rule: RuleComplianceKubeSystemAccess, title: "Restrict the access to the pods of kube-system"},
+			{rule: RuleComplianceUnrestrictedEgress, title: "Do not allow unrestricted egress"},
+		},
+	},
+}
+
+func (analyzer analyzerImpl) complianceFindings(
+	clusterState ClusterState) ([]*types.Finding, []*types.ComplianceProfile) {
+	findings := make([]*types.Finding, 0)
+	profiles := make([]*types.ComplianceProfile, 0, len(analyzer.complianceProfiles))
+	fingerprintsByRule := make(map[string][]string)
+	for _, profileName := range analyzer.complianceProfiles {
+		profile := complianceProfiles[profileName]
+		result := &types.ComplianceProfile{
+			Profile: profileName,
+			Title:   profile.title,
+			Checks:  make([]*types.ComplianceCheck, 0, len(profile.checks)),
+		}
+		for _, check := range profile.checks {
+			fingerprints, ok := fingerprintsByRule[check.rule]
+			if !ok {
+				checkFindings := analyzer.complianceCheckFindings(check.rule, clusterState)
+				fingerprints = make([]string, 0, len(checkFindings))
+				for _, checkFinding := range checkFindings {
+					fingerprints = append(fingerprints, checkFinding.Fingerprint)
+				}
+				fingerprintsByRule[check.rule] = fingerprints
+				findings = append(findings, checkFindings...)
+			}
+			passed := len(fingerprints) == 0
+			if passed {
+				result.Passed++
+			} else {
+				result.Failed++
+			}
+			result.Checks = append(result.Checks, &types.ComplianceCheck{
+				Rule:     check.rule,
+				Title:    check.title,
+				Passed:   passed,
+				Findings: fingerprints,
+			})
+		}
+		profiles = append(profiles, result)
+	}
+	return findings, profiles
+}
+
+func (analyzer analyzerImpl) complianceCheckFindings(rule string, clusterState ClusterState) []*types.Finding {
+	switch rule {
+	case RuleComplianceDefaultDeny:
+		return analyzer.complianceDefaultDenyFindings(clusterState.Namespaces, clusterState.NetworkPolicies)
+	case RuleComplianceKubeSystemAccess:
+		return analyzer.kubeSystemAccessFindings(clusterState.Pods, clusterState.AllowedRoutes)
+	case RuleComplianceUnrestrictedEgress:
+		return analyzer.unrestrictedEgressFindings(clusterState.NetworkPolicies)
+	default:
+		return nil
+	}
+}
+
+func (analyzer analyzerImpl) complianceDefaultDenyFindings(namespaces []*corev1.Namespace,
+	policies []*networkingv1.NetworkPolicy) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, namespace := range namespaces {
+		missingPolicyTypes := make([]string, 0)
+		missingTypes := make([]networkingv1.PolicyType, 0)
+		for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress,
+			networkingv1.PolicyTypeEgress} {
+			if !utils.HasDefaultDeny(namespace.Name, policyType, policies) {
+				missingPolicyTypes = append(missingPolicyTypes, strings.ToLower(string(policyType)))
+				missingTypes = append(missingTypes, policyType)
+			}
+		}
+		if len(missingTypes) == 0 {
+			continue
+		}
+		resource := types.ResourceRef{Kind: "Namespace", Name: namespace.Name}
+		finding := analyzer.newFinding(RuleComplianceDefaultDeny, SeverityMedium, resource, nil,
+			CodeMissingDefaultDeny, map[string]string{"namespace": namespace.Name,
+				"policyTypes": strings.Join(missingPolicyTypes, " and ")})
+		policy := authoring.DefaultDenyPolicy(namespace.Name, missingTypes...)
+		finding.Remediation = &types.Remediation{
+			Operation: RemediationCreate,
+			Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace},
+			Manifest:  policy,
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// The pods of kube-system are expected to only be reached from other namespaces for name resolution
+func (analyzer analyzerImpl) kubeSystemAccessFindings(pods []*corev1.Pod,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	dnsPods := make(map[types.PodRef]bool)
+	for _, pod := range pods {
+		if pod.Namespace == authoring.DNSNamespace && pod.Labels[authoring.DNSPodLabel] == authoring.DNSPodValue {
+			dnsPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+		}
+	}
+	sourceNamespaces := make(map[types.PodRef]map[string]bool)
+	for _, allowedRoute := range allowedRoutes {
+		if allowedRoute.TargetPod.Namespace != metav1.NamespaceSystem ||
+			allowedRoute.SourcePod.Namespace == metav1.NamespaceSystem {
+			continue
+		}
+		if dnsPods[allowedRoute.TargetPod] && onlyPort(allowedRoute.Ports, authoring.DNSPort) {
+			continue
+		}
+		if sourceNamespaces[allowedRoute.TargetPod] == nil {
+			sourceNamespaces[allowedRoute.TargetPod] = make(map[string]bool)
+		}
+		sourceNamespaces[allowedRoute.TargetPod][allowedRoute.SourcePod.Namespace] = true
+	}
+	for _, pod := range pods {
+		podRef := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+		if len(sourceNamespaces[podRef]) == 0 {
+			continue
+		}
+		namespaces := make([]string, 0, len(sourceNamespaces[podRef]))
+		for namespace := range sourceNamespaces[podRef] {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		resource := types.ResourceRef{Kind: "Pod", Name: pod.Name, Namespace: pod.Namespace}
+		findings = append(findings, analyzer.newFinding(RuleComplianceKubeSystemAccess, SeverityHigh, resource, nil,
+			CodeKubeSystemPodReachable, map[string]string{"namespace": pod.Namespace, "pod": pod.Name,
+				"sourceNamespaces": strings.Join(namespaces, ", ")}))
+	}
+	return findings
+}
+
+// An egress rule is unrestricted when it allows any port towards any peer, or towards every address
+func (analyzer analyzerImpl) unrestrictedEgressFindings(policies []*networkingv1.NetworkPolicy) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, policy := range policies {
+		if !appliesToEgress(policy) {
+			continue
+		}
+		for _, egressRule := range policy.Spec.Egress {
+			if len(egressRule.Ports) > 0 || !allowsAnyPeer(egressRule.To) {
+				continue
+			}
+			resource := types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace}
+			findings = append(findings, analyzer.newFinding(RuleComplianceUnrestrictedEgress, SeverityMedium,
+				resource, nil, CodeUnrestrictedEgress, map[string]string{"namespace": policy.Namespace,
+					"policy": policy.Name}))
+			break
+		}
+	}
+	return findings
+}
+
+func appliesToEgress(policy *networkingv1.NetworkPolicy) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return len(policy.Spec.Egress) > 0
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeEgress {
+			return true
+		}
+	}
+	return false
+}
+
+func allowsAnyPeer(peers []networkingv1.NetworkPolicyPeer) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil && len(peer.IPBlock.Except) == 0 &&
+			(peer.IPBlock.CIDR == "0.0.0.0/0" || peer.IPBlock.CIDR == "::/0") {
+			return true
+		}
+	}
+	return false
+}
+
+func onlyPort(ports []int32, port int32) bool {
+	if ports == nil {
+		return false
+	}
+	for _, allowedPort := range ports {
+		if allowedPort != port {
+			return false
+		}
+	}
+	return true
+}
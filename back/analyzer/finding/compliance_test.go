@@ -0,0 +1,116 @@
+package finding
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/authoring"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyzeCompliance(t *testing.T) {
+	coreDNS := types.PodRef{Name: "coredns", Namespace: "kube-system"}
+	metricsServer := types.PodRef{Name: "metrics-server", Namespace: "kube-system"}
+	clusterState := ClusterState{
+		Namespaces: []*corev1.Namespace{
+			testutils.NewNamespaceBuilder().WithName("shop").Build(),
+			testutils.NewNamespaceBuilder().WithName("web").Build(),
+		},
+		Pods: []*corev1.Pod{
+			testutils.NewPodBuilder().WithName("coredns").WithNamespace("kube-system").
+				WithLabel("k8s-app", "kube-dns").Build(),
+			testutils.NewPodBuilder().WithName("metrics-server").WithNamespace("kube-system").Build(),
+		},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{
+			testutils.NewNetworkPolicyBuilder().WithName("deny-all").WithNamespace("shop").
+				WithTypes(networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress).Build(),
+			testutils.NewNetworkPolicyBuilder().WithName("open-egress").WithNamespace("shop").
+				WithTypes(networkingv1.PolicyTypeEgress).WithEgressRule(networkingv1.NetworkPolicyEgressRule{}).Build(),
+			testutils.NewNetworkPolicyBuilder().WithName("deny-ingress").WithNamespace("web").
+				WithTypes(networkingv1.PolicyTypeIngress).Build(),
+			testutils.NewNetworkPolicyBuilder().WithName("https").WithNamespace("web").
+				WithTypes(networkingv1.PolicyTypeEgress).WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+				Ports: []networkingv1.NetworkPolicyPort{{}},
+				To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0"}}},
+			}).Build(),
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "front", Namespace: "shop"}, TargetPod: coreDNS, Ports: []int32{53}},
+			{SourcePod: types.PodRef{Name: "app", Namespace: "web"}, TargetPod: metricsServer},
+			{SourcePod: coreDNS, TargetPod: metricsServer},
+		},
+	}
+	defaultDenyFinding := NewFinding(RuleComplianceDefaultDeny, SeverityMedium,
+		types.ResourceRef{Kind: "Namespace", Name: "web"}, CodeMissingDefaultDeny,
+		map[string]string{"namespace": "web", "policyTypes": "egress"})
+	defaultDenyPolicy := authoring.DefaultDenyPolicy("web", networkingv1.PolicyTypeEgress)
+	defaultDenyFinding.Remediation = &types.Remediation{
+		Operation: RemediationCreate,
+		Resource: types.ResourceRef{Kind: "NetworkPolicy", Name: defaultDenyPolicy.Name,
+			Namespace: defaultDenyPolicy.Namespace},
+		Manifest: defaultDenyPolicy,
+	}
+	kubeSystemFinding := NewFinding(RuleComplianceKubeSystemAccess, SeverityHigh,
+		types.ResourceRef{Kind: "Pod", Name: "metrics-server", Namespace: "kube-system"}, CodeKubeSystemPodReachable,
+		map[string]string{"namespace": "kube-system", "pod": "metrics-server", "sourceNamespaces": "web"})
+	egressFinding := NewFinding(RuleComplianceUnrestrictedEgress, SeverityMedium,
+		types.ResourceRef{Kind: "NetworkPolicy", Name: "open-egress", Namespace: "shop"}, CodeUnrestrictedEgress,
+		map[string]string{"namespace": "shop", "policy": "open-egress"})
+	cisProfile := &types.ComplianceProfile{Profile: "cis", Title: "CIS Kubernetes Benchmark", Failed: 1,
+		Checks: []*types.ComplianceCheck{
+			{Rule: RuleComplianceDefaultDeny, Title: "5.3.2 Ensure that all Namespaces have Network Policies defined",
+				Findings: []string{defaultDenyFinding.Fingerprint}},
+		}}
+	tests := []struct {
+		name               string
+		profiles           []string
+		expectedFindings   []*types.Finding
+		expectedCompliance []*types.ComplianceProfile
+	}{
+		{
+			name:               "profiles only run their own checks",
+			profiles:           []string{config.ComplianceProfileCIS},
+			expectedFindings:   []*types.Finding{defaultDenyFinding},
+			expectedCompliance: []*types.ComplianceProfile{cisProfile},
+		},
+		{
+			name:             "checks shared by profiles raise their findings once",
+			profiles:         []string{config.ComplianceProfileCIS, config.ComplianceProfileNSA},
+			expectedFindings: []*types.Finding{defaultDenyFinding, kubeSystemFinding, egressFinding},
+			expectedCompliance: []*types.ComplianceProfile{
+				cisProfile,
+				{Profile: "nsa", Title: "NSA/CISA Kubernetes Hardening Guidance", Failed: 3,
+					Checks: []*types.ComplianceCheck{
+						{Rule: RuleComplianceDefaultDeny,
+							Title:    "Deny all ingress and egress traffic by default in every namespace",
+							Findings: []string{defaultDenyFinding.Fingerprint}},
+						{Rule: RuleComplianceKubeSystemAccess, Title: "Restrict the access to the pods of kube-system",
+							Findings: []string{kubeSystemFinding.Fingerprint}},
+						{Rule: RuleComplianceUnrestrictedEgress, Title: "Do not allow unrestricted egress",
+							Findings: []string{egressFinding.Fingerprint}},
+					}},
+			},
+		},
+		{
+			name:               "no check is run without profile",
+			expectedFindings:   []*types.Finding{},
+			expectedCompliance: []*types.ComplianceProfile{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, nil, &config.ComplianceConfig{Profiles: tt.profiles},
+				nil).(analyzerImpl)
+			findings, compliance := analyzer.complianceFindings(clusterState)
+			if diff := cmp.Diff(tt.expectedFindings, findings); diff != "" {
+				t.Errorf("complianceFindings() findings mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCompliance, compliance); diff != "" {
+				t.Errorf("complianceFindings() compliance mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/analyzer/utils"
+	"karto/config"
+	"karto/types"
+	"strconv"
+)
+
+// Findings of connectivity rules are named after their resource, so that the fingerprints of two rules flagging the
+// same route differ
+func (analyzer analyzerImpl) connectivityRuleFindings(rules []config.ConnectivityRule, pods []*corev1.Pod,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	if len(rules) == 0 {
+		return findings
+	}
+	routes := make(map[types.PodRef]map[types.PodRef]*types.AllowedRoute)
+	for _, allowedRoute := range allowedRoutes {
+		if routes[allowedRoute.SourcePod] == nil {
+			routes[allowedRoute.SourcePod] = make(map[types.PodRef]*types.AllowedRoute)
+		}
+		routes[allowedRoute.SourcePod][allowedRoute.TargetPod] = allowedRoute
+	}
+	for _, rule := range rules {
+		ruleName := rule.Namespace + "/" + rule.Name
+		severity := rule.Severity
+		if severity == "" {
+			severity = SeverityMedium
+		}
+		code := CodeRouteExpectedDenied
+		if rule.Expect == config.ExpectAllowed {
+			code = CodeRouteExpectedAllowed
+		}
+		for _, source := range pods {
+			if !rule.Source.Matches(source.Namespace, source.Labels) {
+				continue
+			}
+			sourceRef := types.PodRef{Name: source.Name, Namespace: source.Namespace}
+			for _, target := range pods {
+				targetRef := types.PodRef{Name: target.Name, Namespace: target.Namespace}
+				if targetRef == sourceRef || !rule.Target.Matches(target.Namespace, target.Labels) {
+					continue
+				}
+				route, ok := routes[sourceRef][targetRef]
+				allowed := ok && (rule.Port == 0 || utils.AllowsPort(route, rule.Port, corev1.ProtocolTCP))
+				if allowed == (rule.Expect == config.ExpectAllowed) {
+					continue
+				}
+				parameters := routeParameters(sourceRef, targetRef)
+				parameters["rule"] = ruleName
+				if rule.Port != 0 {
+					parameters["port"] = strconv.Itoa(int(rule.Port))
+				}
+				findings = append(findings, analyzer.newRouteFinding(ruleName, severity, sourceRef, targetRef, code,
+					parameters))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,76 @@
+package finding
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyzeConnectivityRules(t *testing.T) {
+	type args struct {
+		rules         []config.ConnectivityRule
+		allowedRoutes []*types.AllowedRoute
+	}
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").Build()
+	db := testutils.NewPodBuilder().WithName("db").WithNamespace("shop").WithLabel("app", "db").Build()
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	dbRef := types.PodRef{Name: "db", Namespace: "shop"}
+	noFrontToDB := config.ConnectivityRule{Name: "no-front-to-db", Namespace: "shop", Expect: config.ExpectDenied,
+		Source: config.PodSelector{PodLabels: map[string]string{"app": "front"}},
+		Target: config.PodSelector{PodLabels: map[string]string{"app": "db"}}}
+	frontToDB := config.ConnectivityRule{Name: "front-to-db", Namespace: "shop", Expect: config.ExpectAllowed,
+		Severity: SeverityHigh, Port: 5432,
+		Source: config.PodSelector{PodLabels: map[string]string{"app": "front"}},
+		Target: config.PodSelector{PodLabels: map[string]string{"app": "db"}}}
+	tests := []struct {
+		name             string
+		args             args
+		expectedFindings []*types.Finding
+	}{
+		{
+			name: "an allowed route expected denied is flagged",
+			args: args{
+				rules:         []config.ConnectivityRule{noFrontToDB},
+				allowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: dbRef, Ports: []int32{5432}}},
+			},
+			expectedFindings: []*types.Finding{
+				NewRouteFinding("shop/no-front-to-db", SeverityMedium, frontRef, dbRef, CodeRouteExpectedDenied,
+					map[string]string{"sourceNamespace": "shop", "sourcePod": "front", "targetNamespace": "shop",
+						"targetPod": "db", "rule": "shop/no-front-to-db"}),
+			},
+		},
+		{
+			name: "a route expected allowed on a port which is not allowed is flagged",
+			args: args{
+				rules:         []config.ConnectivityRule{frontToDB},
+				allowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: dbRef, Ports: []int32{80}}},
+			},
+			expectedFindings: []*types.Finding{
+				NewRouteFinding("shop/front-to-db", SeverityHigh, frontRef, dbRef, CodeRouteExpectedAllowed,
+					map[string]string{"sourceNamespace": "shop", "sourcePod": "front", "targetNamespace": "shop",
+						"targetPod": "db", "rule": "shop/front-to-db", "port": "5432"}),
+			},
+		},
+		{
+			name: "routes meeting their expectations are not flagged",
+			args: args{
+				rules:         []config.ConnectivityRule{frontToDB},
+				allowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: dbRef}},
+			},
+			expectedFindings: []*types.Finding{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := analyzerImpl{}
+			findings := analyzer.connectivityRuleFindings(tt.args.rules, []*corev1.Pod{front, db},
+				tt.args.allowedRoutes)
+			if diff := cmp.Diff(tt.expectedFindings, findings); diff != "" {
+				t.Errorf("connectivityRuleFindings() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/authoring"
+	"karto/config"
+	"karto/types"
+	"strings"
+)
+
+func (analyzer analyzerImpl) customRuleFindings(clusterState ClusterState) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, rule := range analyzer.customRules {
+		if rule.DefaultDeny != nil {
+			findings = append(findings, analyzer.defaultDenyFindings(rule, clusterState.Namespaces,
+				clusterState.NetworkPolicies)...)
+		}
+		if rule.ForbiddenRoute != nil {
+			findings = append(findings, analyzer.forbiddenRouteFindings(rule, clusterState.Pods,
+				clusterState.AllowedRoutes)...)
+		}
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) defaultDenyFindings(rule config.Rule, namespaces []*corev1.Namespace,
+	policies []*networkingv1.NetworkPolicy) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	if !analyzer.namespaceExists(rule.DefaultDeny.Namespace, namespaces) {
+		return findings
+	}
+	policyTypes := rule.DefaultDeny.PolicyTypes
+	if len(policyTypes) == 0 {
+		policyTypes = []string{string(networkingv1.PolicyTypeIngress)}
+	}
+	missingPolicyTypes := make([]string, 0)
+	missingTypes := make([]networkingv1.PolicyType, 0)
+	for _, policyType := range policyTypes {
+		if !utils.HasDefaultDeny(rule.DefaultDeny.Namespace, networkingv1.PolicyType(policyType), policies) {
+			missingPolicyTypes = append(missingPolicyTypes, strings.ToLower(policyType))
+			missingTypes = append(missingTypes, networkingv1.PolicyType(policyType))
+		}
+	}
+	if len(missingPolicyTypes) == 0 {
+		return findings
+	}
+	namespace := types.ResourceRef{Kind: "Namespace", Name: rule.DefaultDeny.Namespace}
+	parameters := map[string]string{
+		"namespace":   namespace.Name,
+		"policyTypes": strings.Join(missingPolicyTypes, " and "),
+		"rule":        rule.Name,
+	}
+	finding := analyzer.newFinding(rule.Name, rule.Severity, namespace, nil, CodeMissingDefaultDeny, parameters)
+	finding.Message = analyzer.ruleMessageOf(rule, CodeMissingDefaultDeny, parameters)
+	policy := authoring.DefaultDenyPolicy(namespace.Name, missingTypes...)
+	finding.Remediation = &types.Remediation{
+		Operation: RemediationCreate,
+		Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace},
+		Manifest:  policy,
+	}
+	return append(findings, finding)
+}
+
+func (analyzer analyzerImpl) namespaceExists(name string, namespaces []*corev1.Namespace) bool {
+	for _, namespace := range namespaces {
+		if namespace.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) forbiddenRouteFindings(rule config.Rule, pods []*corev1.Pod,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	podLabels := make(map[types.PodRef]map[string]string)
+	for _, pod := range pods {
+		podLabels[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod.Labels
+	}
+	forbiddenRoute := rule.ForbiddenRoute
+	for _, allowedRoute := range allowedRoutes {
+		sourceMatches := config.PodSelector{Namespace: forbiddenRoute.SourceNamespace,
+			PodLabels: forbiddenRoute.SourcePodLabels}.Matches(allowedRoute.SourcePod.Namespace,
+			podLabels[allowedRoute.SourcePod])
+		targetMatches := config.PodSelector{Namespace: forbiddenRoute.TargetNamespace,
+			PodLabels: forbiddenRoute.TargetPodLabels}.Matches(allowedRoute.TargetPod.Namespace,
+			podLabels[allowedRoute.TargetPod])
+		if !sourceMatches || !targetMatches {
+			continue
+		}
+		parameters := routeParameters(allowedRoute.SourcePod, allowedRoute.TargetPod)
+		parameters["rule"] = rule.Name
+		finding := analyzer.newRouteFinding(rule.Name, rule.Severity, allowedRoute.SourcePod, allowedRoute.TargetPod,
+			CodeForbiddenRoute, parameters)
+		finding.Message = analyzer.ruleMessageOf(rule, CodeForbiddenRoute, parameters)
+		findings = append(findings, finding)
+	}
+	return findings
+}
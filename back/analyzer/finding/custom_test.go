@@ -0,0 +1,132 @@
+package finding
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/authoring"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyzeCustomRules(t *testing.T) {
+	type args struct {
+		customRules  []config.Rule
+		clusterState ClusterState
+	}
+	namespaces := []*corev1.Namespace{
+		testutils.NewNamespaceBuilder().WithName("dev").Build(),
+		testutils.NewNamespaceBuilder().WithName("prod").Build(),
+	}
+	devPod := testutils.NewPodBuilder().WithName("dev-pod").WithNamespace("dev").WithLabel("app", "front").Build()
+	prodPod := testutils.NewPodBuilder().WithName("prod-pod").WithNamespace("prod").WithLabel("app", "db").Build()
+	devPodRef := types.PodRef{Name: "dev-pod", Namespace: "dev"}
+	prodPodRef := types.PodRef{Name: "prod-pod", Namespace: "prod"}
+	defaultDenyIngress := testutils.NewNetworkPolicyBuilder().WithName("default-deny").WithNamespace("prod").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().Build()).WithTypes(networkingv1.PolicyTypeIngress).Build()
+	missingDefaultDenyFinding := NewFinding("prod-default-deny", "high",
+		types.ResourceRef{Kind: "Namespace", Name: "prod"}, CodeMissingDefaultDeny,
+		map[string]string{"namespace": "prod", "policyTypes": "egress", "rule": "prod-default-deny"})
+	forbiddenRouteFinding := NewRouteFinding("no-dev-to-prod-db", "medium", devPodRef, prodPodRef, CodeForbiddenRoute,
+		map[string]string{"sourceNamespace": "dev", "sourcePod": "dev-pod", "targetNamespace": "prod",
+			"targetPod": "prod-pod", "rule": "no-dev-to-prod-db"})
+	forbiddenRouteFinding.Message = "dev-pod must not reach prod databases"
+	missingDefaultDenyFinding.Remediation = &types.Remediation{
+		Operation: RemediationCreate,
+		Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: "default-deny-egress", Namespace: "prod"},
+		Manifest:  authoring.DefaultDenyPolicy("prod", networkingv1.PolicyTypeEgress),
+	}
+	tests := []struct {
+		name             string
+		args             args
+		expectedFindings []*types.Finding
+	}{
+		{
+			name: "a namespace without the required default deny policies is flagged",
+			args: args{
+				customRules: []config.Rule{
+					{Name: "prod-default-deny", Severity: "high", DefaultDeny: &config.DefaultDenyRule{
+						Namespace: "prod", PolicyTypes: []string{"Ingress", "Egress"}}},
+				},
+				clusterState: ClusterState{
+					Namespaces:      namespaces,
+					NetworkPolicies: []*networkingv1.NetworkPolicy{defaultDenyIngress},
+				},
+			},
+			expectedFindings: []*types.Finding{missingDefaultDenyFinding},
+		},
+		{
+			name: "a namespace with the required default deny policy is not flagged",
+			args: args{
+				customRules: []config.Rule{
+					{Name: "prod-default-deny", Severity: "high", DefaultDeny: &config.DefaultDenyRule{
+						Namespace: "prod"}},
+				},
+				clusterState: ClusterState{
+					Namespaces:      namespaces,
+					NetworkPolicies: []*networkingv1.NetworkPolicy{defaultDenyIngress},
+				},
+			},
+			expectedFindings: []*types.Finding{},
+		},
+		{
+			name: "a missing namespace is not flagged",
+			args: args{
+				customRules: []config.Rule{
+					{Name: "staging-default-deny", Severity: "high", DefaultDeny: &config.DefaultDenyRule{
+						Namespace: "staging"}},
+				},
+				clusterState: ClusterState{
+					Namespaces: namespaces,
+				},
+			},
+			expectedFindings: []*types.Finding{},
+		},
+		{
+			name: "allowed routes matching a forbidden route are flagged with the rule message",
+			args: args{
+				customRules: []config.Rule{
+					{Name: "no-dev-to-prod-db", Severity: "medium", Message: "{{.sourcePod}} must not reach prod databases",
+						ForbiddenRoute: &config.ForbiddenRouteRule{SourceNamespace: "dev", TargetNamespace: "prod",
+							TargetPodLabels: map[string]string{"app": "db"}}},
+				},
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{devPod, prodPod},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: devPodRef, TargetPod: prodPodRef},
+						{SourcePod: prodPodRef, TargetPod: devPodRef},
+					},
+				},
+			},
+			expectedFindings: []*types.Finding{
+				forbiddenRouteFinding,
+			},
+		},
+		{
+			name: "allowed routes not matching the forbidden route labels are not flagged",
+			args: args{
+				customRules: []config.Rule{
+					{Name: "no-dev-to-prod-cache", Severity: "medium",
+						ForbiddenRoute: &config.ForbiddenRouteRule{SourceNamespace: "dev", TargetNamespace: "prod",
+							TargetPodLabels: map[string]string{"app": "cache"}}},
+				},
+				clusterState: ClusterState{
+					Pods:          []*corev1.Pod{devPod, prodPod},
+					AllowedRoutes: []*types.AllowedRoute{{SourcePod: devPodRef, TargetPod: prodPodRef}},
+				},
+			},
+			expectedFindings: []*types.Finding{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := analyzerImpl{customRules: tt.args.customRules}
+			findings := analyzer.customRuleFindings(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedFindings, findings); diff != "" {
+				t.Errorf("customRuleFindings() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
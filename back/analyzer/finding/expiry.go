@@ -0,0 +1,64 @@
+package finding
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/types"
+	"time"
+)
+
+const expiryDateFormat = "2006-01-02"
+
+// Policies granting temporary exceptions are flagged once past their expiry, which is either a date, the policy
+// expiring at its start in UTC, or a RFC 3339 time. An unreadable expiry is flagged as well, as the exception would
+// otherwise silently become permanent.
+func (analyzer analyzerImpl) expiredPolicyFindings(policies []*networkingv1.NetworkPolicy) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	now := analyzer.now()
+	for _, policy := range policies {
+		expiry, ok := policy.Annotations[analyzer.policyExpiryAnnotation]
+		if !ok {
+			continue
+		}
+		networkPolicy := types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace}
+		parameters := map[string]string{"namespace": policy.Namespace, "policy": policy.Name, "expiry": expiry,
+			"annotation": analyzer.policyExpiryAnnotation}
+		expiresAt, err := parseExpiry(expiry)
+		if err != nil {
+			findings = append(findings, analyzer.newFinding(RuleExpiredNetworkPolicy, SeverityLow, networkPolicy, nil,
+				CodeInvalidPolicyExpiry, parameters))
+			continue
+		}
+		if now.Before(expiresAt) {
+			continue
+		}
+		finding := analyzer.newFinding(RuleExpiredNetworkPolicy, SeverityMedium, networkPolicy, nil,
+			CodeExpiredNetworkPolicy, parameters)
+		finding.Remediation = &types.Remediation{Operation: RemediationDelete, Resource: networkPolicy}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+func parseExpiry(expiry string) (time.Time, error) {
+	expiresAt, err := time.Parse(expiryDateFormat, expiry)
+	if err == nil {
+		return expiresAt, nil
+	}
+	return time.Parse(time.RFC3339, expiry)
+}
+
+func (analyzer analyzerImpl) staleExceptionFindings(exceptions []*types.PolicyException) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	for _, exception := range exceptions {
+		if !exception.Stale {
+			continue
+		}
+		policy := exception.Policy
+		networkPolicy := types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace}
+		findings = append(findings, analyzer.newFinding(RuleStalePolicyException, SeverityMedium, networkPolicy, nil,
+			CodeStalePolicyException, map[string]string{"namespace": policy.Namespace, "policy": policy.Name,
+				"owner": exception.Owner, "ticket": exception.Ticket,
+				"since": exception.CreatedAt.Format(expiryDateFormat)}))
+	}
+	return findings
+}
@@ -0,0 +1,97 @@
+package finding
+
+import (
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeExpiredPolicies(t *testing.T) {
+	now := time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+	policyWithExpiry := func(expiry string) *networkingv1.NetworkPolicy {
+		return testutils.NewNetworkPolicyBuilder().WithName("exception").WithNamespace("shop").
+			WithAnnotation(config.DefaultPolicyExpiryAnnotation, expiry).Build()
+	}
+	exception := types.ResourceRef{Kind: "NetworkPolicy", Name: "exception", Namespace: "shop"}
+	parametersOf := func(expiry string) map[string]string {
+		return map[string]string{"namespace": "shop", "policy": "exception", "expiry": expiry,
+			"annotation": config.DefaultPolicyExpiryAnnotation}
+	}
+	expiredFinding := func(expiry string) *types.Finding {
+		finding := NewFinding(RuleExpiredNetworkPolicy, SeverityMedium, exception, CodeExpiredNetworkPolicy,
+			parametersOf(expiry))
+		finding.Remediation = &types.Remediation{Operation: RemediationDelete, Resource: exception}
+		return finding
+	}
+	tests := []struct {
+		name             string
+		policies         []*networkingv1.NetworkPolicy
+		expectedFindings []*types.Finding
+	}{
+		{
+			name:             "a policy expiring on a past date is flagged, from the start of that date",
+			policies:         []*networkingv1.NetworkPolicy{policyWithExpiry("2025-01-01")},
+			expectedFindings: []*types.Finding{expiredFinding("2025-01-01")},
+		},
+		{
+			name:             "a policy expiring on a past time is flagged",
+			policies:         []*networkingv1.NetworkPolicy{policyWithExpiry("2025-01-01T11:00:00Z")},
+			expectedFindings: []*types.Finding{expiredFinding("2025-01-01T11:00:00Z")},
+		},
+		{
+			name: "policies which are not expired yet, or without expiry, are not flagged",
+			policies: []*networkingv1.NetworkPolicy{
+				policyWithExpiry("2025-01-02"),
+				policyWithExpiry("2025-01-01T13:00:00+00:00"),
+				testutils.NewNetworkPolicyBuilder().WithName("permanent").WithNamespace("shop").Build(),
+			},
+			expectedFindings: []*types.Finding{},
+		},
+		{
+			name:     "an invalid expiry is flagged",
+			policies: []*networkingv1.NetworkPolicy{policyWithExpiry("next week")},
+			expectedFindings: []*types.Finding{
+				NewFinding(RuleExpiredNetworkPolicy, SeverityLow, exception, CodeInvalidPolicyExpiry,
+					parametersOf("next week")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, nil, nil, nil).(analyzerImpl)
+			analyzer.now = func() time.Time { return now }
+			findings := analyzer.expiredPolicyFindings(tt.policies)
+			if diff := cmp.Diff(tt.expectedFindings, findings); diff != "" {
+				t.Errorf("expiredPolicyFindings() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStaleExceptions(t *testing.T) {
+	createdAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	exceptionPolicy := types.NetworkPolicy{Name: "exception", Namespace: "shop"}
+	exceptions := []*types.PolicyException{
+		{Policy: exceptionPolicy, Owner: "payments", Ticket: "SEC-42", CreatedAt: createdAt, Stale: true},
+		{Policy: types.NetworkPolicy{Name: "recent", Namespace: "shop"}, CreatedAt: createdAt},
+	}
+	expectedFindings := []*types.Finding{
+		NewFinding(RuleStalePolicyException, SeverityMedium,
+			types.ResourceRef{Kind: "NetworkPolicy", Name: "exception", Namespace: "shop"}, CodeStalePolicyException,
+			map[string]string{"namespace": "shop", "policy": "exception", "owner": "payments", "ticket": "SEC-42",
+				"since": "2021-04-01"}),
+	}
+	findings := analyzerImpl{}.staleExceptionFindings(exceptions)
+	if diff := cmp.Diff(expectedFindings, findings); diff != "" {
+		t.Errorf("staleExceptionFindings() result mismatch (-want +got):\n%s", diff)
+	}
+	expectedMessage := "network policy shop/exception has been a temporary exception since 2021-04-01, owned by " +
+		"payments, under ticket SEC-42"
+	if findings[0].Message != expectedMessage {
+		t.Errorf("staleExceptionFindings() message = %s, expected %s", findings[0].Message, expectedMessage)
+	}
+}
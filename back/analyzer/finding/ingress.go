@@ -0,0 +1,138 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"sort"
+	"strings"
+)
+
+type ingressController struct {
+	name    string
+	classes []string
+	labels  map[string][]string
+}
+
+// Well-known ingress controllers are recognized by the labels of their usual manifests, whatever their namespace
+var knownIngressControllers = []ingressController{
+	{name: "ingress-nginx", classes: []string{"nginx"}, labels: map[string][]string{
+		"app.kubernetes.io/name": {"ingress-nginx"},
+		"app":                    {"ingress-nginx", "nginx-ingress"},
+	}},
+	{name: "traefik", classes: []string{"traefik"}, labels: map[string][]string{
+		"app.kubernetes.io/name": {"traefik"},
+	}},
+	{name: "haproxy-ingress", classes: []string{"haproxy"}, labels: map[string][]string{
+		"app.kubernetes.io/name": {"haproxy-ingress", "kubernetes-ingress"},
+	}},
+}
+
+// Ingresses without class are served by the default controller, which cannot be told apart, so all the controllers
+// found in the cluster are expected to reach their backends. Ingresses of an unknown class are left out.
+func (analyzer analyzerImpl) ingressBackendFindings(pods []*corev1.Pod, ingresses []*types.Ingress,
+	services []*types.Service, allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	controllerPods := make(map[string][]types.PodRef)
+	for _, pod := range pods {
+		if name, ok := analyzer.ingressControllerOf(pod); ok {
+			controllerPods[name] = append(controllerPods[name], types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+		}
+	}
+	if len(controllerPods) == 0 {
+		// No ingress controller is recognized
+		return findings
+	}
+	reachedPods := make(map[types.PodRef]map[types.PodRef]bool)
+	for _, allowedRoute := range allowedRoutes {
+		if reachedPods[allowedRoute.SourcePod] == nil {
+			reachedPods[allowedRoute.SourcePod] = make(map[types.PodRef]bool)
+		}
+		reachedPods[allowedRoute.SourcePod][allowedRoute.TargetPod] = true
+	}
+	servicesByRef := make(map[types.ServiceRef]*types.Service)
+	for _, service := range services {
+		servicesByRef[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = service
+	}
+	for _, ingress := range ingresses {
+		controllers := analyzer.ingressControllersOf(ingress, controllerPods)
+		for _, serviceRef := range ingress.TargetServices {
+			service, ok := servicesByRef[serviceRef]
+			if !ok {
+				continue
+			}
+			for _, controller := range controllers {
+				unreachedPods := make([]string, 0)
+				for _, targetPod := range service.TargetPods {
+					if !analyzer.reachesPod(controllerPods[controller], targetPod, reachedPods) {
+						unreachedPods = append(unreachedPods, targetPod.Name)
+					}
+				}
+				if len(unreachedPods) == 0 {
+					continue
+				}
+				findings = append(findings, analyzer.newIngressBackendFinding(ingress, serviceRef, map[string]string{
+					"namespace":        ingress.Namespace,
+					"ingress":          ingress.Name,
+					"serviceNamespace": serviceRef.Namespace,
+					"service":          serviceRef.Name,
+					"controller":       controller,
+					"pods":             strings.Join(unreachedPods, ", "),
+				}))
+			}
+		}
+	}
+	return findings
+}
+
+func (analyzer analyzerImpl) ingressControllerOf(pod *corev1.Pod) (string, bool) {
+	for _, controller := range knownIngressControllers {
+		for key, values := range controller.labels {
+			for _, value := range values {
+				if pod.Labels[key] == value {
+					return controller.name, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func (analyzer analyzerImpl) ingressControllersOf(ingress *types.Ingress,
+	controllerPods map[string][]types.PodRef) []string {
+	controllers := make([]string, 0)
+	for _, controller := range knownIngressControllers {
+		if _, ok := controllerPods[controller.name]; !ok {
+			continue
+		}
+		if ingress.IngressClass == "" {
+			controllers = append(controllers, controller.name)
+			continue
+		}
+		for _, class := range controller.classes {
+			if class == ingress.IngressClass {
+				controllers = append(controllers, controller.name)
+			}
+		}
+	}
+	sort.Strings(controllers)
+	return controllers
+}
+
+// A backend pod is reachable as soon as one of the replicas of the controller can reach it
+func (analyzer analyzerImpl) reachesPod(controllerPods []types.PodRef, targetPod types.PodRef,
+	reachedPods map[types.PodRef]map[types.PodRef]bool) bool {
+	for _, controllerPod := range controllerPods {
+		if reachedPods[controllerPod][targetPod] {
+			return true
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) newIngressBackendFinding(ingress *types.Ingress, service types.ServiceRef,
+	parameters map[string]string) *types.Finding {
+	return analyzer.newFinding(RuleIngressBackendUnreachable, SeverityHigh,
+		types.ResourceRef{Kind: "Ingress", Name: ingress.Name, Namespace: ingress.Namespace},
+		&types.ResourceRef{Kind: "Service", Name: service.Name, Namespace: service.Namespace},
+		CodeIngressBackendUnreachable, parameters)
+}
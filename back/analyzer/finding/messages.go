@@ -0,0 +1,159 @@
+package finding
+
+import (
+	"bytes"
+	"karto/config"
+	"karto/types"
+	"log"
+	"text/template"
+)
+
+// Codes identify the wording of a finding message, a rule having one per situation it reports
+const (
+	CodePodNotIngressIsolated         = "pod-not-ingress-isolated"
+	CodePodNotEgressIsolated          = "pod-not-egress-isolated"
+	CodeUnusedNetworkPolicy           = "unused-network-policy"
+	CodeRouteWithoutIntent            = "route-without-intent"
+	CodeDNSEgressBlocked              = "dns-egress-blocked"
+	CodePrivilegedPodOpen             = "privileged-pod-reachable-from-any-namespace"
+	CodePrivilegedPodExposed          = "privileged-pod-reachable-from-namespaces"
+	CodeIngressBackendUnreachable     = "ingress-backend-unreachable"
+	CodeMetricsScrapeBlocked          = "metrics-scrape-blocked"
+	CodeValidatingWebhookUnreachable  = "validating-webhook-unreachable"
+	CodeMutatingWebhookUnreachable    = "mutating-webhook-unreachable"
+	CodeAggregatedAPIUnreachable      = "aggregated-api-unreachable"
+	CodeMissingDefaultDeny            = "missing-default-deny"
+	CodeForbiddenRoute                = "forbidden-route"
+	CodeRouteExpectedDenied           = "route-expected-denied"
+	CodeRouteExpectedAllowed          = "route-expected-allowed"
+	CodeHostPortExposed               = "host-port-exposed"
+	CodeHostPortBypassesIsolation     = "host-port-bypasses-isolation"
+	CodeExpiredNetworkPolicy          = "expired-network-policy"
+	CodeInvalidPolicyExpiry           = "invalid-network-policy-expiry"
+	CodeStalePolicyException          = "stale-policy-exception"
+	CodeKubeSystemPodReachable        = "kube-system-pod-reachable"
+	CodeUnrestrictedEgress            = "unrestricted-egress"
+	CodeMissingPermission             = "missing-permission"
+	CodeServicePortBlocked            = "service-port-blocked"
+	sourceTargetRouteMessageParameter = "traffic from pod {{.sourceNamespace}}/{{.sourcePod}} to pod " +
+		"{{.targetNamespace}}/{{.targetPod}}"
+)
+
+// DefaultMessages is the catalog of the templates of finding messages, rendered with the parameters of each finding
+var DefaultMessages = map[string]string{
+	CodePodNotIngressIsolated: "pod {{.namespace}}/{{.pod}} accepts incoming traffic from any source",
+	CodePodNotEgressIsolated:  "pod {{.namespace}}/{{.pod}} can send traffic to any destination",
+	CodeUnusedNetworkPolicy:   "network policy {{.namespace}}/{{.policy}} does not select any pod",
+	CodeRouteWithoutIntent:    sourceTargetRouteMessageParameter + " is allowed but matches no declared intent",
+	CodeDNSEgressBlocked: "pods {{.pods}} of namespace {{.namespace}} cannot resolve DNS names, their egress to " +
+		"{{.dns}} is not allowed",
+	CodePrivilegedPodOpen: "privileged pod {{.namespace}}/{{.pod}} accepts incoming traffic from any namespace",
+	CodePrivilegedPodExposed: "privileged pod {{.namespace}}/{{.pod}} accepts incoming traffic from namespaces " +
+		"{{.sourceNamespaces}}",
+	CodeIngressBackendUnreachable: "ingress {{.namespace}}/{{.ingress}} routes to service " +
+		"{{.serviceNamespace}}/{{.service}}, but ingress controller {{.controller}} cannot reach its pods {{.pods}}",
+	CodeMetricsScrapeBlocked: "metrics of pod {{.namespace}}/{{.pod}} on ports {{.ports}} cannot be scraped, their " +
+		"ingress from the monitoring pods is not allowed",
+	CodeValidatingWebhookUnreachable: "validating webhook {{.name}} calls service {{.serviceNamespace}}/{{.service}}, " +
+		"whose pods {{.pods}} do not accept traffic from the API server",
+	CodeMutatingWebhookUnreachable: "mutating webhook {{.name}} calls service {{.serviceNamespace}}/{{.service}}, " +
+		"whose pods {{.pods}} do not accept traffic from the API server",
+	CodeAggregatedAPIUnreachable: "aggregated API {{.name}} calls service {{.serviceNamespace}}/{{.service}}, " +
+		"whose pods {{.pods}} do not accept traffic from the API server",
+	CodeMissingDefaultDeny: "namespace {{.namespace}} has no default deny {{.policyTypes}} policy",
+	CodeForbiddenRoute:     sourceTargetRouteMessageParameter + " is allowed but forbidden by rule {{.rule}}",
+	CodeRouteExpectedDenied: sourceTargetRouteMessageParameter + "{{if .port}} on port {{.port}}{{end}} is allowed " +
+		"but connectivity rule {{.rule}} expects it denied",
+	CodeRouteExpectedAllowed: sourceTargetRouteMessageParameter + "{{if .port}} on port {{.port}}{{end}} is denied " +
+		"but connectivity rule {{.rule}} expects it allowed",
+	CodeHostPortExposed: "pod {{.namespace}}/{{.pod}} exposes host ports {{.ports}} on node {{.node}}, reachable " +
+		"by anything reaching the node",
+	CodeHostPortBypassesIsolation: "pod {{.namespace}}/{{.pod}} is ingress isolated, but its host ports {{.ports}} " +
+		"on node {{.node}} are reachable whatever its network policies",
+	CodeExpiredNetworkPolicy: "network policy {{.namespace}}/{{.policy}} expired on {{.expiry}}, the exception it " +
+		"grants should be removed",
+	CodeInvalidPolicyExpiry: "network policy {{.namespace}}/{{.policy}} has an invalid expiry {{.expiry}} in " +
+		"annotation {{.annotation}}, expected a date such as 2025-01-01 or a RFC 3339 time",
+	CodeStalePolicyException: "network policy {{.namespace}}/{{.policy}} has been a temporary exception since " +
+		"{{.since}}{{if .owner}}, owned by {{.owner}}{{end}}{{if .ticket}}, under ticket {{.ticket}}{{end}}",
+	CodeKubeSystemPodReachable: "pod {{.namespace}}/{{.pod}} accepts incoming traffic from namespaces " +
+		"{{.sourceNamespaces}}, beyond name resolution",
+	CodeUnrestrictedEgress: "network policy {{.namespace}}/{{.policy}} allows egress to any destination on any port",
+	CodeMissingPermission: "the service account of Karto cannot {{.verb}} {{.resource}}, required by feature " +
+		"{{.feature}}",
+	CodeServicePortBlocked: "service {{.namespace}}/{{.service}} exposes port {{.port}} on target port " +
+		"{{.targetPort}}, but ingress policies {{.policies}} of its pods do not allow it from its consumers " +
+		"{{.consumers}}",
+}
+
+var defaultTemplates = parseMessages(DefaultMessages)
+
+// Messages replacing the default ones are expected to be valid templates, which the configuration ensures
+func parseMessages(messages map[string]string) map[string]*template.Template {
+	templates := make(map[string]*template.Template)
+	for code, message := range messages {
+		messageTemplate, err := template.New(code).Option("missingkey=zero").Parse(message)
+		if err != nil {
+			log.Printf("Ignoring the invalid template of message %s: %s\n", code, err)
+			continue
+		}
+		templates[code] = messageTemplate
+	}
+	return templates
+}
+
+func renderMessage(templates map[string]*template.Template, code string, parameters map[string]string) string {
+	messageTemplate, ok := templates[code]
+	if !ok {
+		messageTemplate, ok = defaultTemplates[code]
+	}
+	if !ok {
+		return code
+	}
+	var buffer bytes.Buffer
+	err := messageTemplate.Execute(&buffer, parameters)
+	if err != nil {
+		log.Printf("Unable to render message %s: %s\n", code, err)
+		return code
+	}
+	return buffer.String()
+}
+
+// Custom rules may replace the message of their findings, which is rendered with the same parameters
+func (analyzer analyzerImpl) ruleMessageOf(rule config.Rule, code string, parameters map[string]string) string {
+	if rule.Message == "" {
+		return renderMessage(analyzer.messages, code, parameters)
+	}
+	return renderMessage(parseMessages(map[string]string{rule.Name: rule.Message}), rule.Name, parameters)
+}
+
+func (analyzer analyzerImpl) newFinding(rule string, severity string, resource types.ResourceRef,
+	peer *types.ResourceRef, code string, parameters map[string]string) *types.Finding {
+	finding := &types.Finding{
+		Rule:       rule,
+		Severity:   severity,
+		Resource:   resource,
+		Peer:       peer,
+		Message:    renderMessage(analyzer.messages, code, parameters),
+		Code:       code,
+		Parameters: parameters,
+	}
+	finding.Fingerprint = Fingerprint(finding)
+	return finding
+}
+
+func (analyzer analyzerImpl) newRouteFinding(rule string, severity string, source types.PodRef, target types.PodRef,
+	code string, parameters map[string]string) *types.Finding {
+	return analyzer.newFinding(rule, severity, types.ResourceRef{Kind: "Pod", Name: target.Name,
+		Namespace: target.Namespace}, &types.ResourceRef{Kind: "Pod", Name: source.Name, Namespace: source.Namespace},
+		code, parameters)
+}
+
+func routeParameters(source types.PodRef, target types.PodRef) map[string]string {
+	return map[string]string{
+		"sourceNamespace": source.Namespace,
+		"sourcePod":       source.Name,
+		"targetNamespace": target.Namespace,
+		"targetPod":       target.Name,
+	}
+}
@@ -0,0 +1,101 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/analyzer/utils"
+	"karto/config"
+	"karto/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	scrapeAnnotation     = "prometheus.io/scrape"
+	scrapePortAnnotation = "prometheus.io/port"
+)
+
+// Only the routes from the configured scrapers matter, a pod reachable from anywhere else is still left unmonitored
+func (analyzer analyzerImpl) metricsScrapeFindings(pods []*corev1.Pod,
+	allowedRoutes []*types.AllowedRoute) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	if analyzer.monitoring == nil {
+		return findings
+	}
+	scraperPods := make(map[types.PodRef]bool)
+	for _, pod := range pods {
+		for _, scraper := range analyzer.monitoring.Scrapers {
+			if scraper.Matches(pod.Namespace, pod.Labels) {
+				scraperPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+			}
+		}
+	}
+	if len(scraperPods) == 0 {
+		// The monitoring of the cluster is not deployed yet
+		return findings
+	}
+	scrapeRoutes := make(map[types.PodRef][]*types.AllowedRoute)
+	for _, allowedRoute := range allowedRoutes {
+		if scraperPods[allowedRoute.SourcePod] {
+			scrapeRoutes[allowedRoute.TargetPod] = append(scrapeRoutes[allowedRoute.TargetPod], allowedRoute)
+		}
+	}
+	for _, pod := range pods {
+		podRef := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+		if scraperPods[podRef] {
+			continue
+		}
+		blockedPorts := make([]string, 0)
+		for _, port := range analyzer.metricsPortsOf(pod) {
+			allowed := false
+			for _, allowedRoute := range scrapeRoutes[podRef] {
+				if utils.AllowsPort(allowedRoute, port, corev1.ProtocolTCP) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				blockedPorts = append(blockedPorts, strconv.Itoa(int(port)))
+			}
+		}
+		if len(blockedPorts) == 0 {
+			continue
+		}
+		findings = append(findings, analyzer.newFinding(RuleMetricsScrapeBlocked, SeverityMedium,
+			types.ResourceRef{Kind: "Pod", Name: pod.Name, Namespace: pod.Namespace}, nil, CodeMetricsScrapeBlocked,
+			map[string]string{"namespace": pod.Namespace, "pod": pod.Name, "ports": strings.Join(blockedPorts, ", ")}))
+	}
+	return findings
+}
+
+// Metrics are exposed on the container ports named as configured, and on the port of the usual Prometheus annotations
+func (analyzer analyzerImpl) metricsPortsOf(pod *corev1.Pod) []int32 {
+	portNames := analyzer.monitoring.MetricsPortNames
+	if len(portNames) == 0 {
+		portNames = config.DefaultMetricsPortNames
+	}
+	ports := make([]int32, 0)
+	addPort := func(port int32) {
+		for _, other := range ports {
+			if other == port {
+				return
+			}
+		}
+		ports = append(ports, port)
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			for _, portName := range portNames {
+				if containerPort.Name == portName {
+					addPort(containerPort.ContainerPort)
+				}
+			}
+		}
+	}
+	if pod.Annotations[scrapeAnnotation] == "true" {
+		port, err := strconv.Atoi(pod.Annotations[scrapePortAnnotation])
+		if err == nil && port > 0 && port <= 65535 {
+			addPort(int32(port))
+		}
+	}
+	return ports
+}
@@ -0,0 +1,158 @@
+package finding
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/analyzer/utils"
+	"karto/config"
+	"karto/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type podPair struct {
+	source types.PodRef
+	target types.PodRef
+}
+
+// The consumers expected to reach a service are the sources of the intents targeting its pods, and its ports are
+// resolved on each of its ingress isolated pods, named ones against their container ports
+func (analyzer analyzerImpl) servicePortFindings(clusterState ClusterState) []*types.Finding {
+	findings := make([]*types.Finding, 0)
+	intents := append(append([]config.Intent{}, analyzer.intents...), clusterState.Intents...)
+	if len(intents) == 0 {
+		return findings
+	}
+	podsByRef := make(map[types.PodRef]*corev1.Pod, len(clusterState.Pods))
+	for _, pod := range clusterState.Pods {
+		podsByRef[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod
+	}
+	ingressIsolated := make(map[types.PodRef]bool)
+	for _, podIsolation := range clusterState.PodIsolations {
+		ingressIsolated[podIsolation.Pod] = podIsolation.IsIngressIsolated
+	}
+	routes := make(map[podPair]*types.AllowedRoute)
+	for _, allowedRoute := range clusterState.AllowedRoutes {
+		routes[podPair{source: allowedRoute.SourcePod, target: allowedRoute.TargetPod}] = allowedRoute
+	}
+	targetPods := make(map[types.ServiceRef][]types.PodRef)
+	for _, service := range clusterState.Services {
+		targetPods[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = service.TargetPods
+	}
+	for _, service := range clusterState.ServiceSpecs {
+		for _, servicePort := range service.Spec.Ports {
+			consumers, targetPorts, policies := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+			for _, targetRef := range targetPods[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] {
+				target, ok := podsByRef[targetRef]
+				if !ok || !ingressIsolated[targetRef] {
+					continue
+				}
+				targetPort, ok := analyzer.targetPortOf(servicePort, target)
+				if !ok {
+					continue
+				}
+				blocked := false
+				for _, consumer := range clusterState.Pods {
+					consumerRef := types.PodRef{Name: consumer.Name, Namespace: consumer.Namespace}
+					if consumerRef == targetRef || !analyzer.expectedConsumer(intents, consumer, target) {
+						continue
+					}
+					route, ok := routes[podPair{source: consumerRef, target: targetRef}]
+					if ok && utils.AllowsPort(route, targetPort, servicePort.Protocol) {
+						continue
+					}
+					blocked = true
+					consumers[consumerRef.Namespace+"/"+consumerRef.Name] = true
+				}
+				if !blocked {
+					continue
+				}
+				targetPorts[strconv.Itoa(int(targetPort))] = true
+				for _, policy := range analyzer.ingressPoliciesOf(target, clusterState.NetworkPolicies) {
+					policies[policy] = true
+				}
+			}
+			if len(consumers) == 0 {
+				continue
+			}
+			findings = append(findings, analyzer.newFinding(RuleServicePortBlocked, SeverityMedium,
+				types.ResourceRef{Kind: "Service", Name: service.Name, Namespace: service.Namespace}, nil,
+				CodeServicePortBlocked, map[string]string{
+					"namespace":  service.Namespace,
+					"service":    service.Name,
+					"port":       strconv.Itoa(int(servicePort.Port)),
+					"targetPort": joinSorted(targetPorts),
+					"consumers":  joinSorted(consumers),
+					"policies":   joinSorted(policies),
+				}))
+		}
+	}
+	return findings
+}
+
+// targetPortOf defaults to the port of the service, as the API server does, and tells whether the pod declares the
+// named target port
+func (analyzer analyzerImpl) targetPortOf(servicePort corev1.ServicePort, pod *corev1.Pod) (int32, bool) {
+	if servicePort.TargetPort.Type == intstr.Int {
+		if servicePort.TargetPort.IntVal == 0 {
+			return servicePort.Port, true
+		}
+		return servicePort.TargetPort.IntVal, true
+	}
+	protocol := servicePort.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			containerProtocol := containerPort.Protocol
+			if containerProtocol == "" {
+				containerProtocol = corev1.ProtocolTCP
+			}
+			if containerPort.Name == servicePort.TargetPort.StrVal && containerProtocol == protocol {
+				return containerPort.ContainerPort, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (analyzer analyzerImpl) expectedConsumer(intents []config.Intent, consumer *corev1.Pod,
+	target *corev1.Pod) bool {
+	for _, intent := range intents {
+		if intent.Source.Matches(consumer.Namespace, consumer.Labels) &&
+			intent.Target.Matches(target.Namespace, target.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// ingressPoliciesOf returns the names of the policies isolating the pod for ingress
+func (analyzer analyzerImpl) ingressPoliciesOf(pod *corev1.Pod, policies []*networkingv1.NetworkPolicy) []string {
+	names := make([]string, 0)
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace || !utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			continue
+		}
+		isIngress := len(policy.Spec.PolicyTypes) == 0
+		for _, policyType := range policy.Spec.PolicyTypes {
+			isIngress = isIngress || policyType == networkingv1.PolicyTypeIngress
+		}
+		if isIngress {
+			names = append(names, policy.Name)
+		}
+	}
+	return names
+}
+
+func joinSorted(values map[string]bool) string {
+	sorted := make([]string, 0, len(values))
+	for value := range values {
+		sorted = append(sorted, value)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
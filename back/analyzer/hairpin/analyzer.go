@@ -0,0 +1,277 @@
+package hairpin
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/types"
+	"net"
+	"sort"
+)
+
+type ClusterState struct {
+	Pods            []*corev1.Pod
+	Nodes           []*corev1.Node
+	Services        []*corev1.Service
+	ServiceTargets  []*types.Service
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+type AnalysisResult struct {
+	HairpinRoutes []*types.HairpinRoute
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+// serviceRoute gathers what the egress policies of a pod allow towards a service, before it is told per target pod
+type serviceRoute struct {
+	service           types.ServiceRef
+	egressPolicies    []*networkingv1.NetworkPolicy
+	nodePorts         []int32
+	loadBalancerPorts []int32
+}
+
+// Analyze resolves the ipBlocks of the egress rules covering the addresses of nodes or load balancers back to the
+// services exposed there. Only pods isolated for egress are analyzed, the others being allowed to reach any address.
+// The ingress policies of the target pods are not evaluated, the source address they see depending on the
+// externalTrafficPolicy of the service and on the network plugin.
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	hairpinRoutes := make([]*types.HairpinRoute, 0)
+	nodeIPs := analyzer.nodeIPsOf(clusterState.Nodes)
+	targetPods := make(map[types.ServiceRef][]types.PodRef)
+	for _, service := range clusterState.ServiceTargets {
+		targetPods[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = service.TargetPods
+	}
+	for _, pod := range clusterState.Pods {
+		sourcePod := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+		for _, route := range analyzer.serviceRoutesOf(pod, clusterState, nodeIPs) {
+			for _, targetPod := range targetPods[route.service] {
+				if targetPod == sourcePod {
+					continue
+				}
+				hairpinRoutes = append(hairpinRoutes, &types.HairpinRoute{
+					SourcePod:         sourcePod,
+					EgressPolicies:    analyzer.toNetworkPolicies(route.egressPolicies),
+					Service:           route.service,
+					TargetPod:         targetPod,
+					NodePorts:         route.nodePorts,
+					LoadBalancerPorts: route.loadBalancerPorts,
+				})
+			}
+		}
+	}
+	return AnalysisResult{
+		HairpinRoutes: hairpinRoutes,
+	}
+}
+
+func (analyzer analyzerImpl) serviceRoutesOf(pod *corev1.Pod, clusterState ClusterState,
+	nodeIPs []net.IP) []*serviceRoute {
+	routes := make([]*serviceRoute, 0)
+	routesByService := make(map[types.ServiceRef]*serviceRoute)
+	for _, policy := range analyzer.egressPoliciesOf(pod, clusterState.NetworkPolicies) {
+		for _, rule := range policy.Spec.Egress {
+			for _, peer := range rule.To {
+				if peer.IPBlock == nil {
+					continue
+				}
+				coversNodes := analyzer.ipBlockContainsAny(peer.IPBlock, nodeIPs)
+				for _, service := range clusterState.Services {
+					var nodePorts, loadBalancerPorts []int32
+					if coversNodes && analyzer.hasNodePorts(service) {
+						nodePorts = analyzer.allowedPorts(service, rule.Ports, true)
+					}
+					if analyzer.ipBlockContainsAny(peer.IPBlock, analyzer.loadBalancerIPsOf(service)) {
+						loadBalancerPorts = analyzer.allowedPorts(service, rule.Ports, false)
+					}
+					if len(nodePorts) == 0 && len(loadBalancerPorts) == 0 {
+						continue
+					}
+					ref := types.ServiceRef{Name: service.Name, Namespace: service.Namespace}
+					route, ok := routesByService[ref]
+					if !ok {
+						route = &serviceRoute{service: ref, nodePorts: []int32{}, loadBalancerPorts: []int32{}}
+						routesByService[ref] = route
+						routes = append(routes, route)
+					}
+					route.egressPolicies = appendUniquePolicy(route.egressPolicies, policy)
+					route.nodePorts = mergePorts(route.nodePorts, nodePorts)
+					route.loadBalancerPorts = mergePorts(route.loadBalancerPorts, loadBalancerPorts)
+				}
+			}
+		}
+	}
+	return routes
+}
+
+func (analyzer analyzerImpl) egressPoliciesOf(pod *corev1.Pod,
+	policies []*networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	egressPolicies := make([]*networkingv1.NetworkPolicy, 0)
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace || !utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			continue
+		}
+		for _, policyType := range policy.Spec.PolicyTypes {
+			if policyType == networkingv1.PolicyTypeEgress {
+				egressPolicies = append(egressPolicies, policy)
+			}
+		}
+	}
+	return egressPolicies
+}
+
+func (analyzer analyzerImpl) nodeIPsOf(nodes []*corev1.Node) []net.IP {
+	ips := make([]net.IP, 0)
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP && address.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(address.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+func (analyzer analyzerImpl) loadBalancerIPsOf(service *corev1.Service) []net.IP {
+	ips := make([]net.IP, 0)
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return ips
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ip := net.ParseIP(ingress.IP); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func (analyzer analyzerImpl) hasNodePorts(service *corev1.Service) bool {
+	return service.Spec.Type == corev1.ServiceTypeNodePort || service.Spec.Type == corev1.ServiceTypeLoadBalancer
+}
+
+// allowedPorts returns the node ports, or else the ports, of the service which the rule ports allow, in order
+func (analyzer analyzerImpl) allowedPorts(service *corev1.Service, rulePorts []networkingv1.NetworkPolicyPort,
+	nodePorts bool) []int32 {
+	ports := make([]int32, 0)
+	for _, servicePort := range service.Spec.Ports {
+		port := servicePort.Port
+		if nodePorts {
+			port = servicePort.NodePort
+		}
+		if port == 0 || !analyzer.rulePortsAllow(rulePorts, port, servicePort.Protocol) {
+			continue
+		}
+		ports = mergePorts(ports, []int32{port})
+	}
+	return ports
+}
+
+// rulePortsAllow ignores named ports, which name container ports rather than the ports of nodes or load balancers
+func (analyzer analyzerImpl) rulePortsAllow(rulePorts []networkingv1.NetworkPolicyPort, port int32,
+	protocol corev1.Protocol) bool {
+	if len(rulePorts) == 0 {
+		return true
+	}
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	for _, rulePort := range rulePorts {
+		ruleProtocol := corev1.ProtocolTCP
+		if rulePort.Protocol != nil {
+			ruleProtocol = *rulePort.Protocol
+		}
+		if ruleProtocol != protocol {
+			continue
+		}
+		if rulePort.Port == nil {
+			return true
+		}
+		first := rulePort.Port.IntVal
+		last := first
+		if rulePort.EndPort != nil {
+			last = *rulePort.EndPort
+		}
+		if first <= port && port <= last {
+			return true
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) ipBlockContainsAny(ipBlock *networkingv1.IPBlock, ips []net.IP) bool {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if !cidr.Contains(ip) {
+			continue
+		}
+		excepted := false
+		for _, except := range ipBlock.Except {
+			_, exceptCIDR, err := net.ParseCIDR(except)
+			if err == nil && exceptCIDR.Contains(ip) {
+				excepted = true
+			}
+		}
+		if !excepted {
+			return true
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) toNetworkPolicies(policies []*networkingv1.NetworkPolicy) []types.NetworkPolicy {
+	result := make([]types.NetworkPolicy, 0, len(policies))
+	for _, policy := range policies {
+		result = append(result, types.NetworkPolicy{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    policy.Labels,
+		})
+	}
+	return result
+}
+
+func appendUniquePolicy(policies []*networkingv1.NetworkPolicy,
+	policy *networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	for _, existing := range policies {
+		if existing == policy {
+			return policies
+		}
+	}
+	return append(policies, policy)
+}
+
+// mergePorts returns the sorted union of the ports
+func mergePorts(ports []int32, others []int32) []int32 {
+	if len(others) == 0 {
+		return ports
+	}
+	merged := make([]int32, 0, len(ports)+len(others))
+	merged = append(merged, ports...)
+	for _, port := range others {
+		found := false
+		for _, existing := range merged {
+			if existing == port {
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, port)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
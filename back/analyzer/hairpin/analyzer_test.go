@@ -0,0 +1,152 @@
+package hairpin
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	k8sNode := testutils.NewNodeBuilder().WithName("node1").WithInternalIP("10.0.0.1").
+		WithExternalIP("203.0.113.1").Build()
+	k8sClient := testutils.NewPodBuilder().WithName("client").WithNamespace("shop").WithLabel("app", "client").Build()
+	k8sFront1 := testutils.NewPodBuilder().WithName("front-1").WithNamespace("shop").WithLabel("app", "front").Build()
+	k8sFront2 := testutils.NewPodBuilder().WithName("front-2").WithNamespace("shop").WithLabel("app", "front").Build()
+	k8sNodePortService := testutils.NewServiceBuilder().WithName("front-np").WithNamespace("shop").
+		WithType(corev1.ServiceTypeNodePort).WithNodePort(80, 30080).WithNodePort(443, 30443).Build()
+	k8sLoadBalancerService := testutils.NewServiceBuilder().WithName("front-lb").WithNamespace("shop").
+		WithType(corev1.ServiceTypeLoadBalancer).WithNodePort(443, 31443).WithLoadBalancerIP("198.51.100.7").Build()
+	k8sClusterIPService := testutils.NewServiceBuilder().WithName("front").WithNamespace("shop").
+		WithPort(80, 8080).Build()
+	serviceTargets := []*types.Service{
+		{Name: "front-np", Namespace: "shop", TargetPods: []types.PodRef{
+			{Name: "front-1", Namespace: "shop"}, {Name: "front-2", Namespace: "shop"}}},
+		{Name: "front-lb", Namespace: "shop", TargetPods: []types.PodRef{{Name: "front-1", Namespace: "shop"}}},
+		{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{{Name: "front-1", Namespace: "shop"}}},
+	}
+	clientSelector := testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "client").Build()
+	egressTo := func(cidr string, except []string, ports ...int32) networkingv1.NetworkPolicyEgressRule {
+		rule := networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr, Except: except}}},
+		}
+		for _, port := range ports {
+			portValue := intstr.FromInt(int(port))
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Port: &portValue})
+		}
+		return rule
+	}
+	tests := []struct {
+		name                   string
+		clusterState           ClusterState
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "an ipBlock covering a node reaches the pods of node port services on the allowed node ports",
+			clusterState: ClusterState{
+				Pods:     []*corev1.Pod{k8sClient, k8sFront1, k8sFront2},
+				Nodes:    []*corev1.Node{k8sNode},
+				Services: []*corev1.Service{k8sNodePortService, k8sClusterIPService},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{
+					testutils.NewNetworkPolicyBuilder().WithName("to-nodes").WithNamespace("shop").
+						WithPodSelector(clientSelector).WithTypes(networkingv1.PolicyTypeEgress).
+						WithEgressRule(egressTo("10.0.0.0/24", nil, 30080)).Build(),
+				},
+				ServiceTargets: serviceTargets,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				HairpinRoutes: []*types.HairpinRoute{
+					{
+						SourcePod:         types.PodRef{Name: "client", Namespace: "shop"},
+						EgressPolicies:    []types.NetworkPolicy{{Name: "to-nodes", Namespace: "shop", Labels: map[string]string{}}},
+						Service:           types.ServiceRef{Name: "front-np", Namespace: "shop"},
+						TargetPod:         types.PodRef{Name: "front-1", Namespace: "shop"},
+						NodePorts:         []int32{30080},
+						LoadBalancerPorts: []int32{},
+					},
+					{
+						SourcePod:         types.PodRef{Name: "client", Namespace: "shop"},
+						EgressPolicies:    []types.NetworkPolicy{{Name: "to-nodes", Namespace: "shop", Labels: map[string]string{}}},
+						Service:           types.ServiceRef{Name: "front-np", Namespace: "shop"},
+						TargetPod:         types.PodRef{Name: "front-2", Namespace: "shop"},
+						NodePorts:         []int32{30080},
+						LoadBalancerPorts: []int32{},
+					},
+				},
+			},
+		},
+		{
+			name: "an ipBlock covering a load balancer reaches the pods of its service on the service ports",
+			clusterState: ClusterState{
+				Pods:     []*corev1.Pod{k8sClient, k8sFront1},
+				Nodes:    []*corev1.Node{k8sNode},
+				Services: []*corev1.Service{k8sLoadBalancerService},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{
+					testutils.NewNetworkPolicyBuilder().WithName("to-internet").WithNamespace("shop").
+						WithPodSelector(clientSelector).WithTypes(networkingv1.PolicyTypeEgress).
+						WithEgressRule(egressTo("0.0.0.0/0", []string{"10.0.0.0/8"})).Build(),
+				},
+				ServiceTargets: serviceTargets,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				HairpinRoutes: []*types.HairpinRoute{
+					{
+						SourcePod: types.PodRef{Name: "client", Namespace: "shop"},
+						EgressPolicies: []types.NetworkPolicy{
+							{Name: "to-internet", Namespace: "shop", Labels: map[string]string{}}},
+						Service:           types.ServiceRef{Name: "front-lb", Namespace: "shop"},
+						TargetPod:         types.PodRef{Name: "front-1", Namespace: "shop"},
+						NodePorts:         []int32{31443},
+						LoadBalancerPorts: []int32{443},
+					},
+				},
+			},
+		},
+		{
+			name: "ipBlocks excepting the nodes and pods not isolated for egress have no hairpin route",
+			clusterState: ClusterState{
+				Pods:     []*corev1.Pod{k8sClient, k8sFront1, k8sFront2},
+				Nodes:    []*corev1.Node{k8sNode},
+				Services: []*corev1.Service{k8sNodePortService},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{
+					testutils.NewNetworkPolicyBuilder().WithName("to-internet").WithNamespace("shop").
+						WithPodSelector(clientSelector).WithTypes(networkingv1.PolicyTypeEgress).
+						WithEgressRule(egressTo("0.0.0.0/0", []string{"10.0.0.0/8", "203.0.113.0/24"})).Build(),
+				},
+				ServiceTargets: serviceTargets,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				HairpinRoutes: []*types.HairpinRoute{},
+			},
+		},
+		{
+			name: "rule ports allowing none of the node ports do not reach the service",
+			clusterState: ClusterState{
+				Pods:     []*corev1.Pod{k8sClient, k8sFront1},
+				Nodes:    []*corev1.Node{k8sNode},
+				Services: []*corev1.Service{k8sNodePortService},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{
+					testutils.NewNetworkPolicyBuilder().WithName("to-nodes").WithNamespace("shop").
+						WithPodSelector(clientSelector).WithTypes(networkingv1.PolicyTypeEgress).
+						WithEgressRule(egressTo("203.0.113.1/32", nil, 80, 443)).Build(),
+				},
+				ServiceTargets: serviceTargets,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				HairpinRoutes: []*types.HairpinRoute{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analysisResult := analyzer.Analyze(tt.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
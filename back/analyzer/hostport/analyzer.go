@@ -0,0 +1,84 @@
+package hostport
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/types"
+)
+
+type ClusterState struct {
+	Pods []*corev1.Pod
+}
+
+type AnalysisResult struct {
+	Exposures []*types.HostPortExposure
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	ignoredNamespaces map[string]bool
+	enabled           bool
+}
+
+// NewAnalyzer finds no exposure without configuration, host ports being common among the agents running on nodes
+func NewAnalyzer(hostPortConfig *config.HostPortConfig) Analyzer {
+	analyzer := analyzerImpl{ignoredNamespaces: make(map[string]bool)}
+	if hostPortConfig == nil {
+		return analyzer
+	}
+	analyzer.enabled = true
+	for _, namespace := range hostPortConfig.IgnoredNamespaces {
+		analyzer.ignoredNamespaces[namespace] = true
+	}
+	return analyzer
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	exposures := make([]*types.HostPortExposure, 0)
+	if !analyzer.enabled {
+		return AnalysisResult{Exposures: exposures}
+	}
+	for _, pod := range clusterState.Pods {
+		// Host ports are only bound once the pod is scheduled
+		if pod.Spec.NodeName == "" || analyzer.ignoredNamespaces[pod.Namespace] {
+			continue
+		}
+		hostPorts := analyzer.hostPortsOf(pod)
+		if len(hostPorts) == 0 {
+			continue
+		}
+		exposures = append(exposures, &types.HostPortExposure{
+			Node:  pod.Spec.NodeName,
+			Pod:   types.PodRef{Name: pod.Name, Namespace: pod.Namespace},
+			Ports: hostPorts,
+		})
+	}
+	return AnalysisResult{
+		Exposures: exposures,
+	}
+}
+
+func (analyzer analyzerImpl) hostPortsOf(pod *corev1.Pod) []types.HostPort {
+	hostPorts := make([]types.HostPort, 0)
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.HostPort == 0 {
+				continue
+			}
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			hostPorts = append(hostPorts, types.HostPort{
+				HostPort:      port.HostPort,
+				ContainerPort: port.ContainerPort,
+				Protocol:      string(protocol),
+				HostIP:        port.HostIP,
+			})
+		}
+	}
+	return hostPorts
+}
@@ -0,0 +1,81 @@
+package hostport
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		hostPortConfig *config.HostPortConfig
+		clusterState   ClusterState
+	}
+	pods := []*corev1.Pod{
+		testutils.NewPodBuilder().WithName("ingress-1").WithNamespace("ingress").WithNodeName("node1").
+			WithHostPort(80, 80).WithHostPort(443, 443).Build(),
+		testutils.NewPodBuilder().WithName("app").WithNamespace("shop").WithNodeName("node2").
+			WithContainerPort("http", 8080).WithHostPort(9090, 30090).Build(),
+		testutils.NewPodBuilder().WithName("pending").WithNamespace("shop").WithHostPort(9090, 30091).Build(),
+		testutils.NewPodBuilder().WithName("db").WithNamespace("shop").WithNodeName("node2").
+			WithContainerPort("postgres", 5432).Build(),
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "no exposure is found when the analysis is not configured",
+			args: args{
+				clusterState: ClusterState{Pods: pods},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Exposures: []*types.HostPortExposure{},
+			},
+		},
+		{
+			name: "scheduled pods exposing host ports are reachable through their node",
+			args: args{
+				hostPortConfig: &config.HostPortConfig{},
+				clusterState:   ClusterState{Pods: pods},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Exposures: []*types.HostPortExposure{
+					{Node: "node1", Pod: types.PodRef{Name: "ingress-1", Namespace: "ingress"},
+						Ports: []types.HostPort{
+							{HostPort: 80, ContainerPort: 80, Protocol: "TCP"},
+							{HostPort: 443, ContainerPort: 443, Protocol: "TCP"},
+						}},
+					{Node: "node2", Pod: types.PodRef{Name: "app", Namespace: "shop"},
+						Ports: []types.HostPort{{HostPort: 30090, ContainerPort: 9090, Protocol: "TCP"}}},
+				},
+			},
+		},
+		{
+			name: "pods of ignored namespaces are not analyzed",
+			args: args{
+				hostPortConfig: &config.HostPortConfig{IgnoredNamespaces: []string{"ingress"}},
+				clusterState:   ClusterState{Pods: pods},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Exposures: []*types.HostPortExposure{
+					{Node: "node2", Pod: types.PodRef{Name: "app", Namespace: "shop"},
+						Ports: []types.HostPort{{HostPort: 30090, ContainerPort: 9090, Protocol: "TCP"}}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.args.hostPortConfig)
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
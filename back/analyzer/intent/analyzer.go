@@ -0,0 +1,60 @@
+package intent
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/types"
+)
+
+type ClusterState struct {
+	Pods          []*corev1.Pod
+	AllowedRoutes []*types.AllowedRoute
+	Intents       []config.Intent
+}
+
+type AnalysisResult struct {
+	AllowedRoutes []*types.AllowedRoute
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	intents []config.Intent
+}
+
+func NewAnalyzer(intents []config.Intent) Analyzer {
+	return analyzerImpl{
+		intents: intents,
+	}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	podLabels := make(map[types.PodRef]map[string]string)
+	for _, pod := range clusterState.Pods {
+		podLabels[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod.Labels
+	}
+	allowedRoutes := make([]*types.AllowedRoute, 0)
+	for _, allowedRoute := range clusterState.AllowedRoutes {
+		annotatedRoute := *allowedRoute
+		annotatedRoute.Intents = analyzer.matchingPurposes(allowedRoute, podLabels, clusterState.Intents)
+		allowedRoutes = append(allowedRoutes, &annotatedRoute)
+	}
+	return AnalysisResult{
+		AllowedRoutes: allowedRoutes,
+	}
+}
+
+func (analyzer analyzerImpl) matchingPurposes(allowedRoute *types.AllowedRoute,
+	podLabels map[types.PodRef]map[string]string, declaredIntents []config.Intent) []string {
+	purposes := make([]string, 0)
+	for _, intent := range append(append([]config.Intent{}, analyzer.intents...), declaredIntents...) {
+		sourceMatches := intent.Source.Matches(allowedRoute.SourcePod.Namespace, podLabels[allowedRoute.SourcePod])
+		targetMatches := intent.Target.Matches(allowedRoute.TargetPod.Namespace, podLabels[allowedRoute.TargetPod])
+		if sourceMatches && targetMatches {
+			purposes = append(purposes, intent.Purpose)
+		}
+	}
+	return purposes
+}
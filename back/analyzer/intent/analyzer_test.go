@@ -0,0 +1,103 @@
+package intent
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		intents      []config.Intent
+		clusterState ClusterState
+	}
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").WithLabel("app", "api").Build()
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api", Namespace: "shop"}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "allowed routes are annotated with the purpose of all matching intents",
+			args: args{
+				intents: []config.Intent{
+					{Purpose: "front calls the API",
+						Source: config.PodSelector{Namespace: "shop", PodLabels: map[string]string{"app": "front"}},
+						Target: config.PodSelector{Namespace: "shop", PodLabels: map[string]string{"app": "api"}}},
+					{Purpose: "anything inside the shop",
+						Source: config.PodSelector{Namespace: "shop"},
+						Target: config.PodSelector{Namespace: "shop"}},
+				},
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{front, api},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: frontRef, TargetPod: apiRef, Ports: []int32{80}},
+						{SourcePod: apiRef, TargetPod: frontRef, Ports: nil},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				AllowedRoutes: []*types.AllowedRoute{
+					{SourcePod: frontRef, TargetPod: apiRef, Ports: []int32{80},
+						Intents: []string{"front calls the API", "anything inside the shop"}},
+					{SourcePod: apiRef, TargetPod: frontRef, Ports: nil,
+						Intents: []string{"anything inside the shop"}},
+				},
+			},
+		},
+		{
+			name: "allowed routes matching no intent have no purpose",
+			args: args{
+				intents: []config.Intent{
+					{Purpose: "front calls the API",
+						Source: config.PodSelector{PodLabels: map[string]string{"app": "front"}},
+						Target: config.PodSelector{PodLabels: map[string]string{"app": "api"}}},
+				},
+				clusterState: ClusterState{
+					Pods:          []*corev1.Pod{front, api},
+					AllowedRoutes: []*types.AllowedRoute{{SourcePod: apiRef, TargetPod: frontRef}},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				AllowedRoutes: []*types.AllowedRoute{{SourcePod: apiRef, TargetPod: frontRef, Intents: []string{}}},
+			},
+		},
+		{
+			name: "intents declared as custom resources are matched after the configured ones",
+			args: args{
+				intents: []config.Intent{
+					{Purpose: "front calls the API",
+						Source: config.PodSelector{PodLabels: map[string]string{"app": "front"}},
+						Target: config.PodSelector{PodLabels: map[string]string{"app": "api"}}},
+				},
+				clusterState: ClusterState{
+					Pods:          []*corev1.Pod{front, api},
+					AllowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: apiRef}},
+					Intents: []config.Intent{
+						{Purpose: "declared by the shop team", Source: config.PodSelector{Namespace: "shop"},
+							Target: config.PodSelector{Namespace: "shop"}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				AllowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: apiRef,
+					Intents: []string{"front calls the API", "declared by the shop team"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.args.intents)
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package namespace
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"karto/types"
+)
+
+type ClusterState struct {
+	Namespaces      []*corev1.Namespace
+	NetworkPolicies []*networkingv1.NetworkPolicy
+	PodIsolations   []*types.PodIsolation
+}
+
+type AnalysisResult struct {
+	Namespaces []*types.Namespace
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	return AnalysisResult{
+		Namespaces: analyzer.toNamespaces(clusterState),
+	}
+}
+
+func (analyzer analyzerImpl) toNamespaces(clusterState ClusterState) []*types.Namespace {
+	result := make([]*types.Namespace, 0)
+	byName := make(map[string]*types.Namespace)
+	for _, k8sNamespace := range clusterState.Namespaces {
+		namespace := analyzer.toNamespace(k8sNamespace, clusterState.NetworkPolicies)
+		byName[namespace.Name] = namespace
+		result = append(result, namespace)
+	}
+	// Every analyzed pod has an isolation, which makes them the source of the pod counts
+	for _, podIsolation := range clusterState.PodIsolations {
+		namespace, ok := byName[podIsolation.Pod.Namespace]
+		if !ok {
+			continue
+		}
+		namespace.PodCount++
+		if podIsolation.IsIngressIsolated {
+			namespace.IngressIsolatedPods++
+		}
+		if podIsolation.IsEgressIsolated {
+			namespace.EgressIsolatedPods++
+		}
+	}
+	return result
+}
+
+func (analyzer analyzerImpl) toNamespace(namespace *corev1.Namespace,
+	networkPolicies []*networkingv1.NetworkPolicy) *types.Namespace {
+	return &types.Namespace{
+		Name:               namespace.Name,
+		Labels:             namespace.Labels,
+		DefaultDenyIngress: utils.HasDefaultDeny(namespace.Name, networkingv1.PolicyTypeIngress, networkPolicies),
+		DefaultDenyEgress:  utils.HasDefaultDeny(namespace.Name, networkingv1.PolicyTypeEgress, networkPolicies),
+	}
+}
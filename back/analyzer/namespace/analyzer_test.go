@@ -0,0 +1,82 @@
+package namespace
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		clusterState ClusterState
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "namespace info are propagated",
+			args: args{
+				clusterState: ClusterState{
+					Namespaces: []*corev1.Namespace{
+						testutils.NewNamespaceBuilder().WithName("ns1").WithLabel("k1", "foo").Build(),
+						testutils.NewNamespaceBuilder().WithName("ns2").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Namespaces: []*types.Namespace{
+					{Name: "ns1", Labels: map[string]string{"k1": "foo"}},
+					{Name: "ns2", Labels: map[string]string{}},
+				},
+			},
+		},
+		{
+			name: "pod counts, isolation coverage and default deny status are aggregated per namespace",
+			args: args{
+				clusterState: ClusterState{
+					Namespaces: []*corev1.Namespace{
+						testutils.NewNamespaceBuilder().WithName("ns1").Build(),
+						testutils.NewNamespaceBuilder().WithName("ns2").Build(),
+					},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("default-deny").WithNamespace("ns1").
+							WithTypes(networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress).Build(),
+						testutils.NewNetworkPolicyBuilder().WithName("allow-all").WithNamespace("ns2").
+							WithTypes(networkingv1.PolicyTypeIngress).
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{}).Build(),
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: "pod1", Namespace: "ns1"}, IsIngressIsolated: true,
+							IsEgressIsolated: true},
+						{Pod: types.PodRef{Name: "pod2", Namespace: "ns1"}, IsIngressIsolated: true,
+							IsEgressIsolated: false},
+						{Pod: types.PodRef{Name: "pod3", Namespace: "ns2"}, IsIngressIsolated: true,
+							IsEgressIsolated: false},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Namespaces: []*types.Namespace{
+					{Name: "ns1", Labels: map[string]string{}, PodCount: 2, IngressIsolatedPods: 2,
+						EgressIsolatedPods: 1, DefaultDenyIngress: true, DefaultDenyEgress: true},
+					{Name: "ns2", Labels: map[string]string{}, PodCount: 1, IngressIsolatedPods: 1,
+						EgressIsolatedPods: 0, DefaultDenyIngress: false, DefaultDenyEgress: false},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package networkpolicy
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/config"
+	"karto/types"
+	"strings"
+	"time"
+)
+
+type ClusterState struct {
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+type AnalysisResult struct {
+	NetworkPolicies []*types.NetworkPolicy
+	Exceptions      []*types.PolicyException
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	exceptionAnnotation string
+	exceptionMaxAge     time.Duration
+	now                 func() time.Time
+}
+
+func NewAnalyzer(exceptionConfig *config.ExceptionConfig) Analyzer {
+	analyzer := analyzerImpl{
+		exceptionAnnotation: config.DefaultExceptionAnnotation,
+		exceptionMaxAge:     config.DefaultExceptionMaxAge,
+		now:                 time.Now,
+	}
+	if exceptionConfig != nil {
+		if exceptionConfig.Annotation != "" {
+			analyzer.exceptionAnnotation = exceptionConfig.Annotation
+		}
+		// The maximum age is validated with the configuration
+		if maxAge, err := time.ParseDuration(exceptionConfig.MaxAge); err == nil {
+			analyzer.exceptionMaxAge = maxAge
+		}
+	}
+	return analyzer
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	return AnalysisResult{
+		NetworkPolicies: analyzer.toNetworkPolicies(clusterState.NetworkPolicies),
+		Exceptions:      analyzer.exceptionsOf(clusterState.NetworkPolicies),
+	}
+}
+
+func (analyzer analyzerImpl) toNetworkPolicies(policies []*networkingv1.NetworkPolicy) []*types.NetworkPolicy {
+	result := make([]*types.NetworkPolicy, 0)
+	for _, policy := range policies {
+		result = append(result, analyzer.toNetworkPolicy(policy))
+	}
+	return result
+}
+
+func (analyzer analyzerImpl) toNetworkPolicy(policy *networkingv1.NetworkPolicy) *types.NetworkPolicy {
+	return &types.NetworkPolicy{
+		Name:      policy.Name,
+		Namespace: policy.Namespace,
+		Labels:    policy.Labels,
+	}
+}
+
+// exceptionsOf ages the exceptions from the creation of their policy, the annotation being part of its manifest
+func (analyzer analyzerImpl) exceptionsOf(policies []*networkingv1.NetworkPolicy) []*types.PolicyException {
+	result := make([]*types.PolicyException, 0)
+	now := analyzer.now()
+	for _, policy := range policies {
+		value, ok := policy.Annotations[analyzer.exceptionAnnotation]
+		if !ok {
+			continue
+		}
+		exception := &types.PolicyException{
+			Policy:    *analyzer.toNetworkPolicy(policy),
+			CreatedAt: policy.CreationTimestamp.Time,
+			Stale:     now.Sub(policy.CreationTimestamp.Time) > analyzer.exceptionMaxAge,
+		}
+		// Unknown keys are ignored, so that the annotation can carry more than what Karto reads
+		for _, part := range strings.Split(value, ",") {
+			keyValue := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(keyValue) != 2 {
+				continue
+			}
+			switch strings.TrimSpace(keyValue[0]) {
+			case "owner":
+				exception.Owner = strings.TrimSpace(keyValue[1])
+			case "ticket":
+				exception.Ticket = strings.TrimSpace(keyValue[1])
+			}
+		}
+		result = append(result, exception)
+	}
+	return result
+}
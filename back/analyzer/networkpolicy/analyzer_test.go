@@ -0,0 +1,96 @@
+package networkpolicy
+
+import (
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		exceptionConfig *config.ExceptionConfig
+		clusterState    ClusterState
+	}
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	exceptionCreatedAt := now.Add(-10 * 24 * time.Hour)
+	policyWithException := func(annotation string, value string) *networkingv1.NetworkPolicy {
+		policy := testutils.NewNetworkPolicyBuilder().WithName("exception").WithNamespace("ns1").
+			WithAnnotation(annotation, value).Build()
+		policy.CreationTimestamp = metav1.NewTime(exceptionCreatedAt)
+		return policy
+	}
+	exceptionPolicy := types.NetworkPolicy{Name: "exception", Namespace: "ns1", Labels: map[string]string{}}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "network policy info are propagated",
+			args: args{
+				clusterState: ClusterState{
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("policy1").WithNamespace("ns1").
+							WithLabel("k1", "foo").Build(),
+						testutils.NewNetworkPolicyBuilder().WithName("policy2").WithNamespace("ns2").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				NetworkPolicies: []*types.NetworkPolicy{
+					{Name: "policy1", Namespace: "ns1", Labels: map[string]string{"k1": "foo"}},
+					{Name: "policy2", Namespace: "ns2", Labels: map[string]string{}},
+				},
+				Exceptions: []*types.PolicyException{},
+			},
+		},
+		{
+			name: "exceptions are read from the annotation of the policies",
+			args: args{
+				clusterState: ClusterState{
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						policyWithException(config.DefaultExceptionAnnotation, "owner=payments, ticket=SEC-42,other=x"),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				NetworkPolicies: []*types.NetworkPolicy{&exceptionPolicy},
+				Exceptions: []*types.PolicyException{
+					{Policy: exceptionPolicy, Owner: "payments", Ticket: "SEC-42", CreatedAt: exceptionCreatedAt},
+				},
+			},
+		},
+		{
+			name: "exceptions older than the configured maximum age are stale",
+			args: args{
+				exceptionConfig: &config.ExceptionConfig{Annotation: "example.com/exception", MaxAge: "168h"},
+				clusterState: ClusterState{
+					NetworkPolicies: []*networkingv1.NetworkPolicy{
+						policyWithException("example.com/exception", ""),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				NetworkPolicies: []*types.NetworkPolicy{&exceptionPolicy},
+				Exceptions: []*types.PolicyException{
+					{Policy: exceptionPolicy, CreatedAt: exceptionCreatedAt, Stale: true},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.args.exceptionConfig).(analyzerImpl)
+			analyzer.now = func() time.Time { return now }
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
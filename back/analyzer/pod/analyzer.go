@@ -2,11 +2,13 @@ package pod
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"karto/analyzer/utils"
 	"karto/types"
 )
 
 type ClusterState struct {
-	Pods []*corev1.Pod
+	Pods  []*corev1.Pod
+	Nodes []*corev1.Node
 }
 
 type AnalysisResult struct {
@@ -25,22 +27,30 @@ func NewAnalyzer() Analyzer {
 
 func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
 	return AnalysisResult{
-		Pods: analyzer.toPods(clusterState.Pods),
+		Pods: analyzer.toPods(clusterState.Pods, clusterState.Nodes),
 	}
 }
 
-func (analyzer analyzerImpl) toPods(pods []*corev1.Pod) []*types.Pod {
+func (analyzer analyzerImpl) toPods(pods []*corev1.Pod, nodes []*corev1.Node) []*types.Pod {
+	zonesByNode := make(map[string]string)
+	for _, node := range nodes {
+		zonesByNode[node.Name] = node.Labels[corev1.LabelTopologyZone]
+	}
 	result := make([]*types.Pod, 0)
 	for _, pod := range pods {
-		result = append(result, analyzer.toPod(pod))
+		result = append(result, analyzer.toPod(pod, zonesByNode[pod.Spec.NodeName]))
 	}
 	return result
 }
 
-func (analyzer analyzerImpl) toPod(pod *corev1.Pod) *types.Pod {
+func (analyzer analyzerImpl) toPod(pod *corev1.Pod, zone string) *types.Pod {
 	return &types.Pod{
-		Name:      pod.Name,
-		Namespace: pod.Namespace,
-		Labels:    pod.Labels,
+		Name:       pod.Name,
+		Namespace:  pod.Namespace,
+		Labels:     pod.Labels,
+		HostPID:    pod.Spec.HostPID,
+		HostIPC:    pod.Spec.HostIPC,
+		Privileged: utils.HasPrivilegedContainer(pod),
+		Zone:       zone,
 	}
 }
@@ -36,6 +36,30 @@ func TestAnalyze(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "pods are located in the zone of their node",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("name1").WithNamespace("ns1").WithNodeName("node1").Build(),
+						testutils.NewPodBuilder().WithName("name2").WithNamespace("ns1").WithNodeName("node2").Build(),
+						testutils.NewPodBuilder().WithName("name3").WithNamespace("ns1").Build(),
+					},
+					Nodes: []*corev1.Node{
+						testutils.NewNodeBuilder().WithName("node1").
+							WithLabel("topology.kubernetes.io/zone", "eu-west-1a").Build(),
+						testutils.NewNodeBuilder().WithName("node2").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Pods: []*types.Pod{
+					{Name: "name1", Namespace: "ns1", Labels: map[string]string{}, Zone: "eu-west-1a"},
+					{Name: "name2", Namespace: "ns1", Labels: map[string]string{}},
+					{Name: "name3", Namespace: "ns1", Labels: map[string]string{}},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"karto/types"
+	"sync"
+)
+
+// ReloadableScheduler analyzes with the last scheduler given to Reload, so that the configuration of the analyzers
+// changes without restarting. Each reload analyzes again the last cluster state received by
+// AnalyzeOnClusterStateChange, cancelling the analysis in progress.
+type ReloadableScheduler struct {
+	mutex     sync.RWMutex
+	scheduler AnalysisScheduler
+	reloads   []chan struct{}
+}
+
+func NewReloadableScheduler(scheduler AnalysisScheduler) *ReloadableScheduler {
+	return &ReloadableScheduler{scheduler: scheduler}
+}
+
+func (reloadableScheduler *ReloadableScheduler) Reload(scheduler AnalysisScheduler) {
+	reloadableScheduler.mutex.Lock()
+	defer reloadableScheduler.mutex.Unlock()
+	reloadableScheduler.scheduler = scheduler
+	for _, reloads := range reloadableScheduler.reloads {
+		// A pending reload already analyzes with the new scheduler
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (reloadableScheduler *ReloadableScheduler) AnalyzeOnClusterStateChange(
+	clusterStateChannel <-chan types.ClusterState, resultsChannel chan<- types.AnalysisResult,
+	shardsChannel chan<- types.NamespaceRoutes) {
+	reloads := make(chan struct{}, 1)
+	reloadableScheduler.mutex.Lock()
+	reloadableScheduler.reloads = append(reloadableScheduler.reloads, reloads)
+	reloadableScheduler.mutex.Unlock()
+	analyzeOnChange(reloadableScheduler.current, reloads, maxAnalysisDelay, clusterStateChannel, resultsChannel,
+		shardsChannel)
+}
+
+func (reloadableScheduler *ReloadableScheduler) Analyze(clusterState types.ClusterState) types.AnalysisResult {
+	return reloadableScheduler.current().Analyze(clusterState)
+}
+
+func (reloadableScheduler *ReloadableScheduler) current() AnalysisScheduler {
+	reloadableScheduler.mutex.RLock()
+	defer reloadableScheduler.mutex.RUnlock()
+	return reloadableScheduler.scheduler
+}
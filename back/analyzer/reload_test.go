@@ -0,0 +1,49 @@
+package analyzer
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+type stubAnalysisScheduler struct {
+	analysisResult types.AnalysisResult
+}
+
+func (scheduler stubAnalysisScheduler) AnalyzeOnClusterStateChange(<-chan types.ClusterState,
+	chan<- types.AnalysisResult, chan<- types.NamespaceRoutes) {
+}
+
+func (scheduler stubAnalysisScheduler) Analyze(types.ClusterState) types.AnalysisResult {
+	return scheduler.analysisResult
+}
+
+func TestReloadableScheduler(t *testing.T) {
+	before := types.AnalysisResult{Pods: []*types.Pod{{Name: "before", Namespace: "ns"}}}
+	after := types.AnalysisResult{Pods: []*types.Pod{{Name: "after", Namespace: "ns"}}}
+	reloadableScheduler := NewReloadableScheduler(stubAnalysisScheduler{analysisResult: before})
+	clusterStateChannel := make(chan types.ClusterState)
+	resultsChannel := make(chan types.AnalysisResult)
+	go reloadableScheduler.AnalyzeOnClusterStateChange(clusterStateChannel, resultsChannel, nil)
+	clusterStateChannel <- types.ClusterState{}
+	receive := func() types.AnalysisResult {
+		select {
+		case analysisResult := <-resultsChannel:
+			return analysisResult
+		case <-time.After(3 * time.Second):
+			t.Fatal("AnalyzeOnClusterStateChange() sent no result")
+			return types.AnalysisResult{}
+		}
+	}
+	if diff := cmp.Diff(before, receive()); diff != "" {
+		t.Errorf("AnalyzeOnClusterStateChange() result mismatch (-want +got):\n%s", diff)
+	}
+	reloadableScheduler.Reload(stubAnalysisScheduler{analysisResult: after})
+	if diff := cmp.Diff(after, receive()); diff != "" {
+		t.Errorf("AnalyzeOnClusterStateChange() result after reload mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(after, reloadableScheduler.Analyze(types.ClusterState{})); diff != "" {
+		t.Errorf("Analyze() result after reload mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+package risk
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/analyzer/utils"
+	"karto/config"
+	"karto/types"
+)
+
+type ClusterState struct {
+	Pods           []*corev1.Pod
+	Services       []*corev1.Service
+	ServiceTargets []*types.Service
+	Ingresses      []*types.Ingress
+	AllowedRoutes  []*types.AllowedRoute
+}
+
+type AnalysisResult struct {
+	AllowedRoutes []*types.AllowedRoute
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct {
+	weights        config.RiskWeights
+	sensitivePorts map[int32]bool
+}
+
+func NewAnalyzer(riskConfig *config.RiskConfig) Analyzer {
+	if riskConfig == nil {
+		riskConfig = &config.DefaultRiskConfig
+	}
+	sensitivePorts := make(map[int32]bool)
+	for _, port := range riskConfig.SensitivePorts {
+		sensitivePorts[port] = true
+	}
+	return analyzerImpl{
+		weights:        riskConfig.Weights,
+		sensitivePorts: sensitivePorts,
+	}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	privilegedPods := make(map[types.PodRef]bool)
+	for _, pod := range clusterState.Pods {
+		if pod.Spec.HostPID || pod.Spec.HostIPC || utils.HasPrivilegedContainer(pod) {
+			privilegedPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+		}
+	}
+	internetFacingPods := analyzer.internetFacingPods(clusterState)
+	allowedRoutes := make([]*types.AllowedRoute, 0)
+	for _, allowedRoute := range clusterState.AllowedRoutes {
+		scoredRoute := *allowedRoute
+		scoredRoute.RiskScore = 0
+		if allowedRoute.SourcePod.Namespace != allowedRoute.TargetPod.Namespace {
+			scoredRoute.RiskScore += analyzer.weights.CrossNamespace
+		}
+		if analyzer.allowsSensitivePort(allowedRoute) {
+			scoredRoute.RiskScore += analyzer.weights.SensitivePort
+		}
+		if privilegedPods[allowedRoute.TargetPod] {
+			scoredRoute.RiskScore += analyzer.weights.PrivilegedTarget
+		}
+		if internetFacingPods[allowedRoute.SourcePod] {
+			scoredRoute.RiskScore += analyzer.weights.InternetFacingSource
+		}
+		allowedRoutes = append(allowedRoutes, &scoredRoute)
+	}
+	return AnalysisResult{
+		AllowedRoutes: allowedRoutes,
+	}
+}
+
+// Pods are internet-facing when they are exposed by a load balancer, a node port or an ingress
+func (analyzer analyzerImpl) internetFacingPods(clusterState ClusterState) map[types.PodRef]bool {
+	exposedServices := make(map[types.ServiceRef]bool)
+	for _, service := range clusterState.Services {
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer || service.Spec.Type == corev1.ServiceTypeNodePort {
+			exposedServices[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = true
+		}
+	}
+	for _, ingress := range clusterState.Ingresses {
+		for _, serviceRef := range ingress.TargetServices {
+			exposedServices[serviceRef] = true
+		}
+	}
+	result := make(map[types.PodRef]bool)
+	for _, service := range clusterState.ServiceTargets {
+		if !exposedServices[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] {
+			continue
+		}
+		for _, podRef := range service.TargetPods {
+			result[podRef] = true
+		}
+	}
+	return result
+}
+
+// A route allowed on all ports also allows the sensitive ones
+func (analyzer analyzerImpl) allowsSensitivePort(allowedRoute *types.AllowedRoute) bool {
+	for port := range analyzer.sensitivePorts {
+		if utils.AllowsPort(allowedRoute, port, corev1.ProtocolTCP) {
+			return true
+		}
+	}
+	return false
+}
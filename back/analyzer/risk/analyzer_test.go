@@ -0,0 +1,92 @@
+package risk
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/config"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		riskConfig   *config.RiskConfig
+		clusterState ClusterState
+	}
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	api := types.PodRef{Name: "api", Namespace: "shop"}
+	agent := types.PodRef{Name: "agent", Namespace: "monitoring"}
+	db := types.PodRef{Name: "db", Namespace: "data"}
+	clusterState := ClusterState{
+		Pods: []*corev1.Pod{
+			testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build(),
+			testutils.NewPodBuilder().WithName("api").WithNamespace("shop").Build(),
+			testutils.NewPodBuilder().WithName("agent").WithNamespace("monitoring").WithPrivilegedContainer().Build(),
+			testutils.NewPodBuilder().WithName("db").WithNamespace("data").Build(),
+		},
+		Services: []*corev1.Service{
+			testutils.NewServiceBuilder().WithName("front").WithNamespace("shop").
+				WithType(corev1.ServiceTypeLoadBalancer).Build(),
+			testutils.NewServiceBuilder().WithName("api").WithNamespace("shop").Build(),
+		},
+		ServiceTargets: []*types.Service{
+			{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{front}},
+			{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{api}},
+		},
+		Ingresses: []*types.Ingress{
+			{Name: "api", Namespace: "shop", TargetServices: []types.ServiceRef{{Name: "api", Namespace: "shop"}}},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: front, TargetPod: api, Ports: []int32{8080}},
+			{SourcePod: api, TargetPod: db, Ports: []int32{5432}},
+			{SourcePod: db, TargetPod: agent, Ports: nil},
+		},
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "routes are scored with the default weights",
+			args: args{
+				riskConfig:   nil,
+				clusterState: clusterState,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				AllowedRoutes: []*types.AllowedRoute{
+					{SourcePod: front, TargetPod: api, Ports: []int32{8080}, RiskScore: 2},
+					{SourcePod: api, TargetPod: db, Ports: []int32{5432}, RiskScore: 5},
+					{SourcePod: db, TargetPod: agent, Ports: nil, RiskScore: 6},
+				},
+			},
+		},
+		{
+			name: "routes are scored with the configured weights and sensitive ports",
+			args: args{
+				riskConfig: &config.RiskConfig{
+					Weights:        config.RiskWeights{CrossNamespace: 10, SensitivePort: 1},
+					SensitivePorts: []int32{8080},
+				},
+				clusterState: clusterState,
+			},
+			expectedAnalysisResult: AnalysisResult{
+				AllowedRoutes: []*types.AllowedRoute{
+					{SourcePod: front, TargetPod: api, Ports: []int32{8080}, RiskScore: 1},
+					{SourcePod: api, TargetPod: db, Ports: []int32{5432}, RiskScore: 10},
+					{SourcePod: db, TargetPod: agent, Ports: nil, RiskScore: 11},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(tt.args.riskConfig)
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
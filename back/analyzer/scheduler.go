@@ -1,56 +1,275 @@
 package analyzer
 
 import (
+	"context"
+	"karto/analyzer/capability"
+	"karto/analyzer/clusternetwork"
+	"karto/analyzer/extension"
+	"karto/analyzer/finding"
+	"karto/analyzer/hairpin"
 	"karto/analyzer/health"
+	"karto/analyzer/hostport"
+	"karto/analyzer/intent"
+	"karto/analyzer/namespace"
+	"karto/analyzer/networkpolicy"
 	"karto/analyzer/pod"
+	"karto/analyzer/risk"
+	"karto/analyzer/system"
+	"karto/analyzer/tightening"
 	"karto/analyzer/traffic"
 	"karto/analyzer/workload"
 	"karto/types"
 	"log"
+	"sync"
 	"time"
 )
 
+// maxAnalysisDelay is the longest time without a published result after which changes stop cancelling the running
+// analysis
+const maxAnalysisDelay = time.Minute
+
 type AnalysisScheduler interface {
+	// AnalyzeOnClusterStateChange publishes the routes towards each namespace on shardsChannel, unless it is nil, as
+	// soon as they are computed and before the analysis result is complete. A cluster state received while an
+	// analysis is running cancels it, only the analysis of the latest cluster state publishes a result, unless no
+	// result has been published for maxAnalysisDelay.
 	AnalyzeOnClusterStateChange(clusterStateChannel <-chan types.ClusterState,
-		resultsChannel chan<- types.AnalysisResult)
+		resultsChannel chan<- types.AnalysisResult, shardsChannel chan<- types.NamespaceRoutes)
+	Analyze(clusterState types.ClusterState) types.AnalysisResult
 }
 
 type analysisSchedulerImpl struct {
-	podAnalyzer      pod.Analyzer
-	trafficAnalyzer  traffic.Analyzer
-	workloadAnalyzer workload.Analyzer
-	healthAnalyzer   health.Analyzer
+	podAnalyzer        pod.Analyzer
+	trafficAnalyzer    traffic.Analyzer
+	workloadAnalyzer   workload.Analyzer
+	healthAnalyzer     health.Analyzer
+	capabilityAnalyzer capability.Analyzer
+	findingAnalyzer    finding.Analyzer
+	intentAnalyzer     intent.Analyzer
+	namespaceAnalyzer  namespace.Analyzer
+	tighteningAnalyzer tightening.Analyzer
+	policyAnalyzer     networkpolicy.Analyzer
+	systemAnalyzer     system.Analyzer
+	riskAnalyzer       risk.Analyzer
+	hostPortAnalyzer   hostport.Analyzer
+	hairpinAnalyzer    hairpin.Analyzer
+	networkAnalyzer    clusternetwork.Analyzer
+	extensionAnalyzer  extension.Analyzer
 }
 
 func NewAnalysisScheduler(podAnalyzer pod.Analyzer, trafficAnalyzer traffic.Analyzer,
-	workloadAnalyzer workload.Analyzer, healthAnalyzer health.Analyzer) AnalysisScheduler {
+	workloadAnalyzer workload.Analyzer, healthAnalyzer health.Analyzer,
+	capabilityAnalyzer capability.Analyzer, findingAnalyzer finding.Analyzer,
+	intentAnalyzer intent.Analyzer, namespaceAnalyzer namespace.Analyzer,
+	tighteningAnalyzer tightening.Analyzer, policyAnalyzer networkpolicy.Analyzer,
+	systemAnalyzer system.Analyzer, riskAnalyzer risk.Analyzer, hostPortAnalyzer hostport.Analyzer,
+	hairpinAnalyzer hairpin.Analyzer, networkAnalyzer clusternetwork.Analyzer,
+	extensionAnalyzer extension.Analyzer) AnalysisScheduler {
 	return analysisSchedulerImpl{
-		podAnalyzer:      podAnalyzer,
-		trafficAnalyzer:  trafficAnalyzer,
-		workloadAnalyzer: workloadAnalyzer,
-		healthAnalyzer:   healthAnalyzer,
+		podAnalyzer:        podAnalyzer,
+		trafficAnalyzer:    trafficAnalyzer,
+		workloadAnalyzer:   workloadAnalyzer,
+		healthAnalyzer:     healthAnalyzer,
+		capabilityAnalyzer: capabilityAnalyzer,
+		findingAnalyzer:    findingAnalyzer,
+		intentAnalyzer:     intentAnalyzer,
+		namespaceAnalyzer:  namespaceAnalyzer,
+		tighteningAnalyzer: tighteningAnalyzer,
+		policyAnalyzer:     policyAnalyzer,
+		systemAnalyzer:     systemAnalyzer,
+		riskAnalyzer:       riskAnalyzer,
+		hostPortAnalyzer:   hostPortAnalyzer,
+		hairpinAnalyzer:    hairpinAnalyzer,
+		networkAnalyzer:    networkAnalyzer,
+		extensionAnalyzer:  extensionAnalyzer,
 	}
 }
 
 func (analysisScheduler analysisSchedulerImpl) AnalyzeOnClusterStateChange(
-	clusterStateChannel <-chan types.ClusterState, resultsChannel chan<- types.AnalysisResult) {
+	clusterStateChannel <-chan types.ClusterState, resultsChannel chan<- types.AnalysisResult,
+	shardsChannel chan<- types.NamespaceRoutes) {
+	analyzeOnChange(func() AnalysisScheduler { return analysisScheduler }, nil, maxAnalysisDelay, clusterStateChannel,
+		resultsChannel, shardsChannel)
+}
+
+// analyzeOnChange analyzes each cluster state with the current scheduler, and the last one again on each reload. A
+// change no longer cancels the running analysis once no result has been published for maxDelay, so that constant
+// changes cannot starve the results: the analysis completes and the latest cluster state is analyzed next.
+func analyzeOnChange(current func() AnalysisScheduler, reloads <-chan struct{}, maxDelay time.Duration,
+	clusterStateChannel <-chan types.ClusterState, resultsChannel chan<- types.AnalysisResult,
+	shardsChannel chan<- types.NamespaceRoutes) {
+	clusterState := <-clusterStateChannel
+	publishedAt := time.Now()
 	for {
-		clusterState := <-clusterStateChannel
-		analysisResult := analysisScheduler.analyze(clusterState)
-		resultsChannel <- analysisResult
+		ctx, cancel := context.WithCancel(context.Background())
+		analysisResults := analyzeInBackground(ctx, current(), clusterState, shardsChannel)
+		var pendingClusterState *types.ClusterState
+		analyzing := true
+		for analyzing {
+			select {
+			case analysisResult := <-analysisResults:
+				cancel()
+				resultsChannel <- analysisResult
+				publishedAt = time.Now()
+				if pendingClusterState != nil {
+					clusterState = *pendingClusterState
+				} else {
+					select {
+					case clusterState = <-clusterStateChannel:
+					case <-reloads:
+					}
+				}
+				analyzing = false
+			case nextClusterState := <-clusterStateChannel:
+				if time.Since(publishedAt) >= maxDelay {
+					pendingClusterState = &nextClusterState
+					continue
+				}
+				cancel()
+				clusterState = nextClusterState
+				// Waits for the cancelled analysis to stop, so that its shards are not published with the next ones
+				for range analysisResults {
+				}
+				log.Printf("Cancelled analysis of an outdated cluster state")
+				analyzing = false
+			case <-reloads:
+				cancel()
+				for range analysisResults {
+				}
+				if pendingClusterState != nil {
+					clusterState = *pendingClusterState
+				}
+				log.Printf("Cancelled analysis with an outdated configuration")
+				analyzing = false
+			}
+		}
+	}
+}
+
+// analyzeInBackground can only cancel the analyses of the schedulers built by NewAnalysisScheduler, the others
+// running to completion
+func analyzeInBackground(ctx context.Context, analysisScheduler AnalysisScheduler, clusterState types.ClusterState,
+	shardsChannel chan<- types.NamespaceRoutes) <-chan types.AnalysisResult {
+	if schedulerImpl, ok := analysisScheduler.(analysisSchedulerImpl); ok {
+		return schedulerImpl.analyzeInBackground(ctx, clusterState, shardsChannel)
+	}
+	analysisResults := make(chan types.AnalysisResult, 1)
+	go func() {
+		defer close(analysisResults)
+		analysisResults <- analysisScheduler.Analyze(clusterState)
+	}()
+	return analysisResults
+}
+
+// analyzeInBackground sends the result of the analysis on the returned channel, then closes it. It is closed without
+// result when ctx is cancelled first.
+func (analysisScheduler analysisSchedulerImpl) analyzeInBackground(ctx context.Context, clusterState types.ClusterState,
+	shardsChannel chan<- types.NamespaceRoutes) <-chan types.AnalysisResult {
+	analysisResults := make(chan types.AnalysisResult, 1)
+	go func() {
+		defer close(analysisResults)
+		analysisResult, err := analysisScheduler.analyze(ctx, clusterState, shardsChannel)
+		if err == nil {
+			analysisResults <- analysisResult
+		}
+	}()
+	return analysisResults
+}
+
+// shardPublisher sends the shards one at a time, so that their progress only increases
+func (analysisScheduler analysisSchedulerImpl) shardPublisher(shardsChannel chan<- types.NamespaceRoutes,
+	startedAt time.Time, pods []*types.Pod) func(shard traffic.Shard) {
+	var mutex sync.Mutex
+	completedShards := 0
+	return func(shard traffic.Shard) {
+		namespacePods := make([]*types.Pod, 0)
+		for _, pod := range pods {
+			if pod.Namespace == shard.Namespace {
+				namespacePods = append(namespacePods, pod)
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		completedShards++
+		shardsChannel <- types.NamespaceRoutes{
+			Namespace:         shard.Namespace,
+			AnalysisStartedAt: startedAt.UTC(),
+			Pods:              namespacePods,
+			PodIsolations:     shard.PodIsolations,
+			AllowedRoutes:     shard.AllowedRoutes,
+			Progress:          completedShards * 100 / shard.Total,
+		}
 	}
 }
 
-func (analysisScheduler analysisSchedulerImpl) analyze(clusterState types.ClusterState) types.AnalysisResult {
-	start := time.Now()
+func (analysisScheduler analysisSchedulerImpl) Analyze(clusterState types.ClusterState) types.AnalysisResult {
+	analysisResult, _ := analysisScheduler.analyze(context.Background(), clusterState, nil)
+	return analysisResult
+}
+
+// analyze returns the error of ctx when it is cancelled before the routes are computed, the longest stage by far
+func (analysisScheduler analysisSchedulerImpl) analyze(ctx context.Context, clusterState types.ClusterState,
+	shardsChannel chan<- types.NamespaceRoutes) (types.AnalysisResult, error) {
+	timer := newStageTimer()
+	capabilityResult := analysisScheduler.capabilityAnalyzer.Analyze(capability.ClusterState{
+		ServerVersion: clusterState.ServerVersion,
+		APIGroups:     clusterState.APIGroups,
+	})
+	timer.lap("capabilities")
+	policiesResult := analysisScheduler.policyAnalyzer.Analyze(networkpolicy.ClusterState{
+		NetworkPolicies: clusterState.NetworkPolicies,
+	})
+	timer.lap("policies")
 	podsResult := analysisScheduler.podAnalyzer.Analyze(pod.ClusterState{
+		Pods:  clusterState.Pods,
+		Nodes: clusterState.Nodes,
+	})
+	timer.lap("pods")
+	hostPortResult := analysisScheduler.hostPortAnalyzer.Analyze(hostport.ClusterState{
 		Pods: clusterState.Pods,
 	})
+	timer.lap("hostPorts")
+	networkResult := analysisScheduler.networkAnalyzer.Analyze(clusternetwork.ClusterState{
+		Pods:            clusterState.Pods,
+		Nodes:           clusterState.Nodes,
+		Services:        clusterState.Services,
+		NetworkPolicies: clusterState.NetworkPolicies,
+	})
+	timer.lap("network")
+	systemResult := analysisScheduler.systemAnalyzer.Analyze(system.ClusterState{
+		Pods: clusterState.Pods,
+	})
+	timer.lap("systemComponents")
+	var onShardComplete func(shard traffic.Shard)
+	if shardsChannel != nil {
+		onShardComplete = analysisScheduler.shardPublisher(shardsChannel, timer.startedAt, podsResult.Pods)
+	}
 	trafficResult := analysisScheduler.trafficAnalyzer.Analyze(traffic.ClusterState{
 		Pods:            clusterState.Pods,
+		Namespaces:      clusterState.Namespaces,
+		Nodes:           clusterState.Nodes,
+		DaemonSets:      clusterState.DaemonSets,
+		NetworkPolicies: clusterState.NetworkPolicies,
+		Capabilities:    capabilityResult.Capabilities,
+		OnShardComplete: onShardComplete,
+		Done:            ctx.Done(),
+	})
+	if trafficResult.Cancelled {
+		return types.AnalysisResult{}, ctx.Err()
+	}
+	timer.splitLap("isolation", trafficResult.IsolationDuration, "routes")
+	namespacesResult := analysisScheduler.namespaceAnalyzer.Analyze(namespace.ClusterState{
 		Namespaces:      clusterState.Namespaces,
 		NetworkPolicies: clusterState.NetworkPolicies,
+		PodIsolations:   trafficResult.Pods,
+	})
+	timer.lap("namespaces")
+	intentResult := analysisScheduler.intentAnalyzer.Analyze(intent.ClusterState{
+		Pods:          clusterState.Pods,
+		AllowedRoutes: trafficResult.AllowedRoutes,
+		Intents:       clusterState.Intents,
 	})
+	timer.lap("intents")
 	workloadResult := analysisScheduler.workloadAnalyzer.Analyze(workload.ClusterState{
 		Pods:         clusterState.Pods,
 		Services:     clusterState.Services,
@@ -60,12 +279,58 @@ func (analysisScheduler analysisSchedulerImpl) analyze(clusterState types.Cluste
 		DaemonSets:   clusterState.DaemonSets,
 		Deployments:  clusterState.Deployments,
 	})
+	timer.lap("workloads")
+	hairpinResult := analysisScheduler.hairpinAnalyzer.Analyze(hairpin.ClusterState{
+		Pods:            clusterState.Pods,
+		Nodes:           clusterState.Nodes,
+		Services:        clusterState.Services,
+		ServiceTargets:  workloadResult.Services,
+		NetworkPolicies: clusterState.NetworkPolicies,
+	})
+	timer.lap("hairpin")
+	riskResult := analysisScheduler.riskAnalyzer.Analyze(risk.ClusterState{
+		Pods:           clusterState.Pods,
+		Services:       clusterState.Services,
+		ServiceTargets: workloadResult.Services,
+		Ingresses:      workloadResult.Ingresses,
+		AllowedRoutes:  intentResult.AllowedRoutes,
+	})
+	timer.lap("risk")
 	healthResult := analysisScheduler.healthAnalyzer.Analyze(health.ClusterState{
 		Pods: clusterState.Pods,
 	})
+	timer.lap("health")
+	findingResult := analysisScheduler.findingAnalyzer.Analyze(finding.ClusterState{
+		Namespaces:                      clusterState.Namespaces,
+		Pods:                            clusterState.Pods,
+		NetworkPolicies:                 clusterState.NetworkPolicies,
+		PodIsolations:                   trafficResult.Pods,
+		AllowedRoutes:                   intentResult.AllowedRoutes,
+		Services:                        workloadResult.Services,
+		ServiceSpecs:                    clusterState.Services,
+		Ingresses:                       workloadResult.Ingresses,
+		Nodes:                           clusterState.Nodes,
+		ValidatingWebhookConfigurations: clusterState.ValidatingWebhookConfigurations,
+		MutatingWebhookConfigurations:   clusterState.MutatingWebhookConfigurations,
+		APIServices:                     clusterState.APIServices,
+		Intents:                         clusterState.Intents,
+		ConnectivityRules:               clusterState.ConnectivityRules,
+		HostPortExposures:               hostPortResult.Exposures,
+		PolicyExceptions:                policiesResult.Exceptions,
+	})
+	timer.lap("findings")
+	tighteningResult := analysisScheduler.tighteningAnalyzer.Analyze(tightening.ClusterState{
+		Pods:            clusterState.Pods,
+		NetworkPolicies: clusterState.NetworkPolicies,
+		AllowedRoutes:   intentResult.AllowedRoutes,
+	})
+	timer.lap("tightening")
+	namespaces := namespacesResult.Namespaces
 	pods := podsResult.Pods
 	podIsolations := trafficResult.Pods
-	allowedRoutes := trafficResult.AllowedRoutes
+	allowedRoutes := riskResult.AllowedRoutes
+	networkPolicies := policiesResult.NetworkPolicies
+	policyExceptions := policiesResult.Exceptions
 	services := workloadResult.Services
 	ingresses := workloadResult.Ingresses
 	replicaSets := workloadResult.ReplicaSets
@@ -73,20 +338,60 @@ func (analysisScheduler analysisSchedulerImpl) analyze(clusterState types.Cluste
 	daemonSets := workloadResult.DaemonSets
 	deployments := workloadResult.Deployments
 	podHealths := healthResult.Pods
-	elapsed := time.Since(start)
-	log.Printf("Finished analysis in %s, found: %d pods, %d allowed routes, %d services, %d ingresses, "+
-		"%d replicaSets, %d statefulSets, %d daemonSets and %d deployments\n", elapsed, len(pods), len(allowedRoutes),
-		len(services), len(ingresses), len(replicaSets), len(statefulSets), len(daemonSets), len(deployments))
-	return types.AnalysisResult{
-		Pods:          pods,
-		PodIsolations: podIsolations,
-		AllowedRoutes: allowedRoutes,
-		Services:      services,
-		Ingresses:     ingresses,
-		ReplicaSets:   replicaSets,
-		StatefulSets:  statefulSets,
-		DaemonSets:    daemonSets,
-		Deployments:   deployments,
-		PodHealths:    podHealths,
+	systemComponents := systemResult.SystemComponents
+	hostPortExposures := hostPortResult.Exposures
+	hairpinRoutes := hairpinResult.HairpinRoutes
+	network := networkResult.Network
+	ipBlocks := networkResult.IPBlocks
+	internetAccesses := networkResult.InternetAccesses
+	capabilities := capabilityResult.Capabilities
+	findings := findingResult.Findings
+	compliance := findingResult.Compliance
+	tighteningSuggestions := tighteningResult.Suggestions
+	analysisResult := types.AnalysisResult{
+		Namespaces:            namespaces,
+		Pods:                  pods,
+		PodIsolations:         podIsolations,
+		AllowedRoutes:         allowedRoutes,
+		NetworkPolicies:       networkPolicies,
+		PolicyExceptions:      policyExceptions,
+		Services:              services,
+		Ingresses:             ingresses,
+		ReplicaSets:           replicaSets,
+		StatefulSets:          statefulSets,
+		DaemonSets:            daemonSets,
+		Deployments:           deployments,
+		PodHealths:            podHealths,
+		SystemComponents:      systemComponents,
+		HostPortExposures:     hostPortExposures,
+		HairpinRoutes:         hairpinRoutes,
+		Network:               network,
+		IPBlocks:              ipBlocks,
+		InternetAccesses:      internetAccesses,
+		Capabilities:          capabilities,
+		RouteVerifications:    make([]*types.RouteVerification, 0),
+		Findings:              findings,
+		Compliance:            compliance,
+		TighteningSuggestions: tighteningSuggestions,
+		Complete:              true,
+		Progress:              100,
 	}
+	// Extensions run last, on the result of the built-in analyzers
+	extensionResult := analysisScheduler.extensionAnalyzer.Analyze(extension.ClusterState{
+		ClusterState:   clusterState,
+		AnalysisResult: analysisResult,
+	})
+	analysisResult.Findings = append(findings, extensionResult.Findings...)
+	analysisResult.Extensions = extensionResult.Sections
+	timer.lap("extensions")
+	analysisResult.Stats = timer.stats(analysisResult)
+	analysisResult.Stats.Counts.ExcludedPairs = trafficResult.ExcludedPairs
+	analysisResult.Stats.Counts.MergedRoutes = trafficResult.MergedRoutes
+	analysisResult.Stats.ResourceVersions = clusterState.ResourceVersions
+	elapsed := time.Since(timer.startedAt)
+	log.Printf("Finished analysis in %s, found: %d pods, %d allowed routes, %d services, %d ingresses, "+
+		"%d replicaSets, %d statefulSets, %d daemonSets, %d deployments and %d findings\n", elapsed, len(pods),
+		len(allowedRoutes), len(services), len(ingresses), len(replicaSets), len(statefulSets), len(daemonSets),
+		len(deployments), len(analysisResult.Findings))
+	return analysisResult, nil
 }
@@ -1,18 +1,36 @@
 package analyzer
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"karto/analyzer/capability"
+	"karto/analyzer/clusternetwork"
+	"karto/analyzer/extension"
+	"karto/analyzer/finding"
+	"karto/analyzer/hairpin"
 	"karto/analyzer/health"
+	"karto/analyzer/hostport"
+	"karto/analyzer/intent"
+	"karto/analyzer/namespace"
+	"karto/analyzer/networkpolicy"
 	"karto/analyzer/pod"
+	"karto/analyzer/risk"
+	"karto/analyzer/system"
+	"karto/analyzer/tightening"
 	"karto/analyzer/traffic"
 	"karto/analyzer/workload"
 	"karto/testutils"
 	"karto/types"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -22,12 +40,27 @@ func TestAnalyze(t *testing.T) {
 		clusterState types.ClusterState
 	}
 	type mocks struct {
-		pods     []mockPodAnalyzerCall
-		traffic  []mockTrafficAnalyzerCall
-		workload []mockWorkloadAnalyzerCall
-		health   []mockHealthAnalyzerCall
+		pods       []mockPodAnalyzerCall
+		traffic    []mockTrafficAnalyzerCall
+		workload   []mockWorkloadAnalyzerCall
+		health     []mockHealthAnalyzerCall
+		capability []mockCapabilityAnalyzerCall
+		finding    []mockFindingAnalyzerCall
+		intent     []mockIntentAnalyzerCall
+		namespace  []mockNamespaceAnalyzerCall
+		tightening []mockTighteningAnalyzerCall
+		policies   []mockPolicyAnalyzerCall
+		system     []mockSystemAnalyzerCall
+		risk       []mockRiskAnalyzerCall
+		hostPort   []mockHostPortAnalyzerCall
+		hairpin    []mockHairpinAnalyzerCall
+		network    []mockNetworkAnalyzerCall
+		extension  []mockExtensionAnalyzerCall
 	}
 	k8sNamespace := testutils.NewNamespaceBuilder().WithName("ns").Build()
+	k8sNode := testutils.NewNodeBuilder().WithName("node").Build()
+	k8sServerVersion := &version.Info{GitVersion: "v1.21.0"}
+	k8sAPIGroups := []metav1.APIGroup{{Name: "networking.k8s.io"}}
 	k8sPod1 := testutils.NewPodBuilder().WithName("pod1").WithNamespace("ns").
 		WithLabel("k1", "v1").
 		WithContainerStatus(true, false, 0).Build()
@@ -51,6 +84,8 @@ func TestAnalyze(t *testing.T) {
 	k8sDaemonSet2 := testutils.NewDaemonSetBuilder().WithName("rs2").WithNamespace("ns").Build()
 	k8sDeployment1 := testutils.NewDeploymentBuilder().WithName("deploy1").WithNamespace("ns").Build()
 	k8sDeployment2 := testutils.NewDeploymentBuilder().WithName("deploy2").WithNamespace("ns").Build()
+	namespace1 := &types.Namespace{Name: k8sNamespace.Name, Labels: k8sNamespace.Labels, PodCount: 2,
+		IngressIsolatedPods: 1, EgressIsolatedPods: 1}
 	pod1 := &types.Pod{Name: k8sPod1.Name, Namespace: k8sPod1.Namespace, Labels: k8sPod1.Labels}
 	pod2 := &types.Pod{Name: k8sPod2.Name, Namespace: k8sPod2.Namespace, Labels: k8sPod2.Labels}
 	podRef1 := types.PodRef{Name: k8sPod1.Name, Namespace: k8sPod1.Namespace}
@@ -63,6 +98,13 @@ func TestAnalyze(t *testing.T) {
 		Labels: k8sNetworkPolicy2.Labels}
 	allowedRoute := &types.AllowedRoute{SourcePod: podRef1, EgressPolicies: []types.NetworkPolicy{networkPolicy1},
 		TargetPod: podRef2, IngressPolicies: []types.NetworkPolicy{networkPolicy2}, Ports: []int32{80, 443}}
+	annotatedAllowedRoute := &types.AllowedRoute{SourcePod: podRef1,
+		EgressPolicies: []types.NetworkPolicy{networkPolicy1}, TargetPod: podRef2,
+		IngressPolicies: []types.NetworkPolicy{networkPolicy2}, Ports: []int32{80, 443}, Intents: []string{"purpose"}}
+	scoredAllowedRoute := &types.AllowedRoute{SourcePod: podRef1,
+		EgressPolicies: []types.NetworkPolicy{networkPolicy1}, TargetPod: podRef2,
+		IngressPolicies: []types.NetworkPolicy{networkPolicy2}, Ports: []int32{80, 443}, Intents: []string{"purpose"},
+		RiskScore: 2}
 	service1 := &types.Service{Name: k8sService1.Name, Namespace: k8sService1.Namespace,
 		TargetPods: []types.PodRef{podRef1}}
 	service2 := &types.Service{Name: k8sService2.Name, Namespace: k8sService2.Namespace,
@@ -95,6 +137,82 @@ func TestAnalyze(t *testing.T) {
 		ContainersWithoutRestart: 1}
 	podHealth2 := &types.PodHealth{Pod: podRef2, Containers: 2, ContainersRunning: 1, ContainersReady: 0,
 		ContainersWithoutRestart: 2}
+	systemComponent := &types.SystemComponent{Name: "kube-dns", Namespace: "ns", Pods: []types.PodRef{podRef1}}
+	capabilities := types.ClusterCapabilities{ServerVersion: "1.21.0", SCTP: true}
+	hostPortExposure := &types.HostPortExposure{Node: "node", Pod: podRef1,
+		Ports: []types.HostPort{{HostPort: 8080, ContainerPort: 80, Protocol: "TCP"}}}
+	hairpinRoute := &types.HairpinRoute{SourcePod: podRef1, Service: serviceRef2, TargetPod: podRef2,
+		NodePorts: []int32{30080}, LoadBalancerPorts: []int32{}}
+	policyException := &types.PolicyException{Policy: networkPolicy2, Owner: "payments", Ticket: "SEC-42"}
+	clusterNetwork := &types.ClusterNetwork{PodCIDRs: []string{"10.244.0.0/16"}, ServiceCIDRs: []string{"10.96.0.0/12"}}
+	ipBlock := &types.IPBlockScope{Policy: networkPolicy1, Direction: "Egress", CIDR: "0.0.0.0/0", Scope: "mixed"}
+	internetAccess := &types.InternetAccess{Pod: podRef1, Egress: true}
+	tighteningSuggestion := &types.TighteningSuggestion{Policy: networkPolicy2,
+		Reasons: []string{"reason"}, SuggestedPolicy: k8sNetworkPolicy2}
+	finding1 := &types.Finding{Fingerprint: "abc", Rule: "rule", Severity: "low",
+		Resource: types.ResourceRef{Kind: "Pod", Name: k8sPod1.Name, Namespace: k8sPod1.Namespace}, Message: "msg"}
+	complianceProfile := &types.ComplianceProfile{Profile: "cis", Failed: 1, Checks: []*types.ComplianceCheck{
+		{Rule: "rule", Findings: []string{"abc"}}}}
+	finding2 := &types.Finding{Fingerprint: "def", Rule: "costs", Severity: "low",
+		Resource: types.ResourceRef{Kind: "Pod", Name: k8sPod2.Name, Namespace: k8sPod2.Namespace}, Message: "msg"}
+	clusterState := types.ClusterState{
+		Namespaces:       []*corev1.Namespace{k8sNamespace},
+		Nodes:            []*corev1.Node{k8sNode},
+		Pods:             []*corev1.Pod{k8sPod1, k8sPod2},
+		Services:         []*corev1.Service{k8sService1, k8sService2},
+		Ingresses:        []*networkingv1beta1.Ingress{k8sIngress1, k8sIngress2},
+		ReplicaSets:      []*appsv1.ReplicaSet{k8sReplicaSet1, k8sReplicaSet2},
+		StatefulSets:     []*appsv1.StatefulSet{k8sStatefulSet1, k8sStatefulSet2},
+		DaemonSets:       []*appsv1.DaemonSet{k8sDaemonSet1, k8sDaemonSet2},
+		Deployments:      []*appsv1.Deployment{k8sDeployment1, k8sDeployment2},
+		NetworkPolicies:  []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+		ServerVersion:    k8sServerVersion,
+		APIGroups:        k8sAPIGroups,
+		ResourceVersions: map[string]string{"pods": "1200", "networkPolicies": "1180"},
+	}
+	builtInAnalysisResult := types.AnalysisResult{
+		Namespaces:            []*types.Namespace{namespace1},
+		Pods:                  []*types.Pod{pod1, pod2},
+		PodIsolations:         []*types.PodIsolation{podIsolation1, podIsolation2},
+		AllowedRoutes:         []*types.AllowedRoute{scoredAllowedRoute},
+		NetworkPolicies:       []*types.NetworkPolicy{&networkPolicy1, &networkPolicy2},
+		PolicyExceptions:      []*types.PolicyException{policyException},
+		Services:              []*types.Service{service1, service2},
+		Ingresses:             []*types.Ingress{ingress1, ingress2},
+		ReplicaSets:           []*types.ReplicaSet{replicaSet1, replicaSet2},
+		StatefulSets:          []*types.StatefulSet{statefulSet1, statefulSet2},
+		DaemonSets:            []*types.DaemonSet{daemonSet1, daemonSet2},
+		Deployments:           []*types.Deployment{deployment1, deployment2},
+		PodHealths:            []*types.PodHealth{podHealth1, podHealth2},
+		SystemComponents:      []*types.SystemComponent{systemComponent},
+		HostPortExposures:     []*types.HostPortExposure{hostPortExposure},
+		HairpinRoutes:         []*types.HairpinRoute{hairpinRoute},
+		Network:               clusterNetwork,
+		IPBlocks:              []*types.IPBlockScope{ipBlock},
+		InternetAccesses:      []*types.InternetAccess{internetAccess},
+		Capabilities:          capabilities,
+		RouteVerifications:    []*types.RouteVerification{},
+		Findings:              []*types.Finding{finding1},
+		Compliance:            []*types.ComplianceProfile{complianceProfile},
+		TighteningSuggestions: []*types.TighteningSuggestion{tighteningSuggestion},
+		Complete:              true,
+		Progress:              100,
+	}
+	expectedAnalysisResult := builtInAnalysisResult
+	expectedAnalysisResult.Findings = []*types.Finding{finding1, finding2}
+	expectedAnalysisResult.Extensions = map[string]json.RawMessage{"costs": json.RawMessage(`{"total":3}`)}
+	expectedAnalysisResult.Stats = &types.AnalysisStats{
+		Stages: []*types.StageStats{
+			{Name: "capabilities"}, {Name: "policies"}, {Name: "pods"}, {Name: "hostPorts"}, {Name: "network"},
+			{Name: "systemComponents"},
+			{Name: "isolation"}, {Name: "routes"}, {Name: "namespaces"}, {Name: "intents"}, {Name: "workloads"},
+			{Name: "hairpin"}, {Name: "risk"}, {Name: "health"}, {Name: "findings"}, {Name: "tightening"},
+			{Name: "extensions"},
+		},
+		Counts: types.ObjectCounts{Namespaces: 1, Pods: 2, NetworkPolicies: 2, Services: 2, Ingresses: 2, Workloads: 8,
+			AllowedRoutes: 1, Findings: 2},
+		ResourceVersions: map[string]string{"pods": "1200", "networkPolicies": "1180"},
+	}
 	tests := []struct {
 		name                   string
 		mocks                  mocks
@@ -104,22 +222,69 @@ func TestAnalyze(t *testing.T) {
 		{
 			name: "schedules analysis and posts results when cluster state changes",
 			mocks: mocks{
+				namespace: []mockNamespaceAnalyzerCall{
+					{
+						clusterState: namespace.ClusterState{
+							Namespaces:      []*corev1.Namespace{k8sNamespace},
+							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+							PodIsolations:   []*types.PodIsolation{podIsolation1, podIsolation2},
+						},
+						returnValue: namespace.AnalysisResult{
+							Namespaces: []*types.Namespace{namespace1},
+						},
+					},
+				},
+				policies: []mockPolicyAnalyzerCall{
+					{
+						clusterState: networkpolicy.ClusterState{
+							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+						},
+						returnValue: networkpolicy.AnalysisResult{
+							NetworkPolicies: []*types.NetworkPolicy{&networkPolicy1, &networkPolicy2},
+							Exceptions:      []*types.PolicyException{policyException},
+						},
+					},
+				},
 				pods: []mockPodAnalyzerCall{
 					{
 						clusterState: pod.ClusterState{
-							Pods: []*corev1.Pod{k8sPod1, k8sPod2},
+							Pods:  []*corev1.Pod{k8sPod1, k8sPod2},
+							Nodes: []*corev1.Node{k8sNode},
 						},
 						returnValue: pod.AnalysisResult{
 							Pods: []*types.Pod{pod1, pod2},
 						},
 					},
 				},
+				hostPort: []mockHostPortAnalyzerCall{
+					{
+						clusterState: hostport.ClusterState{
+							Pods: []*corev1.Pod{k8sPod1, k8sPod2},
+						},
+						returnValue: hostport.AnalysisResult{
+							Exposures: []*types.HostPortExposure{hostPortExposure},
+						},
+					},
+				},
+				system: []mockSystemAnalyzerCall{
+					{
+						clusterState: system.ClusterState{
+							Pods: []*corev1.Pod{k8sPod1, k8sPod2},
+						},
+						returnValue: system.AnalysisResult{
+							SystemComponents: []*types.SystemComponent{systemComponent},
+						},
+					},
+				},
 				traffic: []mockTrafficAnalyzerCall{
 					{
 						clusterState: traffic.ClusterState{
 							Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
 							Namespaces:      []*corev1.Namespace{k8sNamespace},
+							Nodes:           []*corev1.Node{k8sNode},
+							DaemonSets:      []*appsv1.DaemonSet{k8sDaemonSet1, k8sDaemonSet2},
 							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+							Capabilities:    capabilities,
 						},
 						returnValue: traffic.AnalysisResult{
 							Pods:          []*types.PodIsolation{podIsolation1, podIsolation2},
@@ -158,32 +323,121 @@ func TestAnalyze(t *testing.T) {
 						},
 					},
 				},
-			},
-			args: args{
-				clusterState: types.ClusterState{
-					Namespaces:      []*corev1.Namespace{k8sNamespace},
-					Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
-					Services:        []*corev1.Service{k8sService1, k8sService2},
-					Ingresses:       []*networkingv1beta1.Ingress{k8sIngress1, k8sIngress2},
-					ReplicaSets:     []*appsv1.ReplicaSet{k8sReplicaSet1, k8sReplicaSet2},
-					StatefulSets:    []*appsv1.StatefulSet{k8sStatefulSet1, k8sStatefulSet2},
-					DaemonSets:      []*appsv1.DaemonSet{k8sDaemonSet1, k8sDaemonSet2},
-					Deployments:     []*appsv1.Deployment{k8sDeployment1, k8sDeployment2},
-					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+				capability: []mockCapabilityAnalyzerCall{
+					{
+						clusterState: capability.ClusterState{
+							ServerVersion: k8sServerVersion,
+							APIGroups:     k8sAPIGroups,
+						},
+						returnValue: capability.AnalysisResult{
+							Capabilities: capabilities,
+						},
+					},
+				},
+				intent: []mockIntentAnalyzerCall{
+					{
+						clusterState: intent.ClusterState{
+							Pods:          []*corev1.Pod{k8sPod1, k8sPod2},
+							AllowedRoutes: []*types.AllowedRoute{allowedRoute},
+						},
+						returnValue: intent.AnalysisResult{
+							AllowedRoutes: []*types.AllowedRoute{annotatedAllowedRoute},
+						},
+					},
+				},
+				network: []mockNetworkAnalyzerCall{
+					{
+						clusterState: clusternetwork.ClusterState{
+							Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
+							Nodes:           []*corev1.Node{k8sNode},
+							Services:        []*corev1.Service{k8sService1, k8sService2},
+							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+						},
+						returnValue: clusternetwork.AnalysisResult{
+							Network:          clusterNetwork,
+							IPBlocks:         []*types.IPBlockScope{ipBlock},
+							InternetAccesses: []*types.InternetAccess{internetAccess},
+						},
+					},
+				},
+				hairpin: []mockHairpinAnalyzerCall{
+					{
+						clusterState: hairpin.ClusterState{
+							Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
+							Nodes:           []*corev1.Node{k8sNode},
+							Services:        []*corev1.Service{k8sService1, k8sService2},
+							ServiceTargets:  []*types.Service{service1, service2},
+							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+						},
+						returnValue: hairpin.AnalysisResult{
+							HairpinRoutes: []*types.HairpinRoute{hairpinRoute},
+						},
+					},
+				},
+				risk: []mockRiskAnalyzerCall{
+					{
+						clusterState: risk.ClusterState{
+							Pods:           []*corev1.Pod{k8sPod1, k8sPod2},
+							Services:       []*corev1.Service{k8sService1, k8sService2},
+							ServiceTargets: []*types.Service{service1, service2},
+							Ingresses:      []*types.Ingress{ingress1, ingress2},
+							AllowedRoutes:  []*types.AllowedRoute{annotatedAllowedRoute},
+						},
+						returnValue: risk.AnalysisResult{
+							AllowedRoutes: []*types.AllowedRoute{scoredAllowedRoute},
+						},
+					},
+				},
+				tightening: []mockTighteningAnalyzerCall{
+					{
+						clusterState: tightening.ClusterState{
+							Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
+							NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+							AllowedRoutes:   []*types.AllowedRoute{annotatedAllowedRoute},
+						},
+						returnValue: tightening.AnalysisResult{
+							Suggestions: []*types.TighteningSuggestion{tighteningSuggestion},
+						},
+					},
+				},
+				finding: []mockFindingAnalyzerCall{
+					{
+						clusterState: finding.ClusterState{
+							Namespaces:        []*corev1.Namespace{k8sNamespace},
+							Pods:              []*corev1.Pod{k8sPod1, k8sPod2},
+							NetworkPolicies:   []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
+							PodIsolations:     []*types.PodIsolation{podIsolation1, podIsolation2},
+							AllowedRoutes:     []*types.AllowedRoute{annotatedAllowedRoute},
+							Services:          []*types.Service{service1, service2},
+							ServiceSpecs:      []*corev1.Service{k8sService1, k8sService2},
+							Ingresses:         []*types.Ingress{ingress1, ingress2},
+							Nodes:             []*corev1.Node{k8sNode},
+							HostPortExposures: []*types.HostPortExposure{hostPortExposure},
+							PolicyExceptions:  []*types.PolicyException{policyException},
+						},
+						returnValue: finding.AnalysisResult{
+							Findings:   []*types.Finding{finding1},
+							Compliance: []*types.ComplianceProfile{complianceProfile},
+						},
+					},
+				},
+				extension: []mockExtensionAnalyzerCall{
+					{
+						clusterState: extension.ClusterState{
+							ClusterState:   clusterState,
+							AnalysisResult: builtInAnalysisResult,
+						},
+						returnValue: extension.AnalysisResult{
+							Sections: map[string]json.RawMessage{"costs": json.RawMessage(`{"total":3}`)},
+							Findings: []*types.Finding{finding2},
+						},
+					},
 				},
 			},
-			expectedAnalysisResult: types.AnalysisResult{
-				Pods:          []*types.Pod{pod1, pod2},
-				PodIsolations: []*types.PodIsolation{podIsolation1, podIsolation2},
-				AllowedRoutes: []*types.AllowedRoute{allowedRoute},
-				Services:      []*types.Service{service1, service2},
-				Ingresses:     []*types.Ingress{ingress1, ingress2},
-				ReplicaSets:   []*types.ReplicaSet{replicaSet1, replicaSet2},
-				StatefulSets:  []*types.StatefulSet{statefulSet1, statefulSet2},
-				DaemonSets:    []*types.DaemonSet{daemonSet1, daemonSet2},
-				Deployments:   []*types.Deployment{deployment1, deployment2},
-				PodHealths:    []*types.PodHealth{podHealth1, podHealth2},
+			args: args{
+				clusterState: clusterState,
 			},
+			expectedAnalysisResult: expectedAnalysisResult,
 		},
 	}
 	for _, tt := range tests {
@@ -192,14 +446,31 @@ func TestAnalyze(t *testing.T) {
 			trafficAnalyzer := createMockTrafficAnalyzer(t, tt.mocks.traffic)
 			workloadAnalyzer := createMockWorkloadAnalyzer(t, tt.mocks.workload)
 			healthAnalyzer := createMockHealthAnalyzer(t, tt.mocks.health)
-			analyzer := NewAnalysisScheduler(podAnalyzer, trafficAnalyzer, workloadAnalyzer, healthAnalyzer)
+			capabilityAnalyzer := createMockCapabilityAnalyzer(t, tt.mocks.capability)
+			findingAnalyzer := createMockFindingAnalyzer(t, tt.mocks.finding)
+			intentAnalyzer := createMockIntentAnalyzer(t, tt.mocks.intent)
+			namespaceAnalyzer := createMockNamespaceAnalyzer(t, tt.mocks.namespace)
+			tighteningAnalyzer := createMockTighteningAnalyzer(t, tt.mocks.tightening)
+			policyAnalyzer := createMockPolicyAnalyzer(t, tt.mocks.policies)
+			systemAnalyzer := createMockSystemAnalyzer(t, tt.mocks.system)
+			riskAnalyzer := createMockRiskAnalyzer(t, tt.mocks.risk)
+			hostPortAnalyzer := createMockHostPortAnalyzer(t, tt.mocks.hostPort)
+			hairpinAnalyzer := createMockHairpinAnalyzer(t, tt.mocks.hairpin)
+			networkAnalyzer := createMockNetworkAnalyzer(t, tt.mocks.network)
+			extensionAnalyzer := createMockExtensionAnalyzer(t, tt.mocks.extension)
+			analyzer := NewAnalysisScheduler(podAnalyzer, trafficAnalyzer, workloadAnalyzer, healthAnalyzer,
+				capabilityAnalyzer, findingAnalyzer, intentAnalyzer, namespaceAnalyzer, tighteningAnalyzer,
+				policyAnalyzer, systemAnalyzer, riskAnalyzer, hostPortAnalyzer,
+				hairpinAnalyzer, networkAnalyzer, extensionAnalyzer)
 			clusterStateChannel := make(chan types.ClusterState)
 			resultsChannel := make(chan types.AnalysisResult)
-			go analyzer.AnalyzeOnClusterStateChange(clusterStateChannel, resultsChannel)
+			go analyzer.AnalyzeOnClusterStateChange(clusterStateChannel, resultsChannel, nil)
 			clusterStateChannel <- tt.args.clusterState
 			select {
 			case analysisResult := <-resultsChannel:
-				if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult,
+					cmpopts.IgnoreFields(types.AnalysisStats{}, "StartedAt", "DurationMs"),
+					cmpopts.IgnoreFields(types.StageStats{}, "DurationMs")); diff != "" {
 					t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
 				}
 			case <-time.After(3 * time.Second):
@@ -209,6 +480,112 @@ func TestAnalyze(t *testing.T) {
 	}
 }
 
+func TestShardPublisher(t *testing.T) {
+	startedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	front := &types.Pod{Name: "front", Namespace: "shop"}
+	db := &types.Pod{Name: "db", Namespace: "data"}
+	shardsChannel := make(chan types.NamespaceRoutes, 2)
+	publish := analysisSchedulerImpl{}.shardPublisher(shardsChannel, startedAt, []*types.Pod{front, db})
+	publish(traffic.Shard{Namespace: "data", Total: 2})
+	publish(traffic.Shard{Namespace: "shop", Total: 2})
+	expectedShards := []types.NamespaceRoutes{
+		{Namespace: "data", AnalysisStartedAt: startedAt, Pods: []*types.Pod{db}, Progress: 50},
+		{Namespace: "shop", AnalysisStartedAt: startedAt, Pods: []*types.Pod{front}, Progress: 100},
+	}
+	if diff := cmp.Diff(expectedShards, []types.NamespaceRoutes{<-shardsChannel, <-shardsChannel}); diff != "" {
+		t.Errorf("shardPublisher() published shards mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// slowAnalysisScheduler takes delay to analyze a cluster state, naming the pod of its result after the first pod of
+// the cluster state
+type slowAnalysisScheduler struct {
+	delay time.Duration
+}
+
+func (scheduler slowAnalysisScheduler) AnalyzeOnClusterStateChange(<-chan types.ClusterState,
+	chan<- types.AnalysisResult, chan<- types.NamespaceRoutes) {
+}
+
+func (scheduler slowAnalysisScheduler) Analyze(clusterState types.ClusterState) types.AnalysisResult {
+	time.Sleep(scheduler.delay)
+	return types.AnalysisResult{Pods: []*types.Pod{{Name: clusterState.Pods[0].Name}}}
+}
+
+func TestAnalyzeOnChangePublishesDuringConstantChanges(t *testing.T) {
+	scheduler := slowAnalysisScheduler{delay: 20 * time.Millisecond}
+	clusterStateChannel := make(chan types.ClusterState)
+	resultsChannel := make(chan types.AnalysisResult)
+	go analyzeOnChange(func() AnalysisScheduler { return scheduler }, nil, 100*time.Millisecond,
+		clusterStateChannel, resultsChannel, nil)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		// A new cluster state is sent every millisecond, far more often than an analysis completes
+		for i := 0; ; i++ {
+			clusterState := types.ClusterState{Pods: []*corev1.Pod{
+				testutils.NewPodBuilder().WithName(strconv.Itoa(i)).Build(),
+			}}
+			select {
+			case clusterStateChannel <- clusterState:
+				time.Sleep(time.Millisecond)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	analyzedStates := make([]int, 0)
+	for len(analyzedStates) < 2 {
+		select {
+		case analysisResult := <-resultsChannel:
+			analyzedState, _ := strconv.Atoi(analysisResult.Pods[0].Name)
+			analyzedStates = append(analyzedStates, analyzedState)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("analyzeOnChange() published %d results during constant changes, expected 2", len(analyzedStates))
+		}
+	}
+	if analyzedStates[1] <= analyzedStates[0] {
+		t.Errorf("analyzeOnChange() analyzed cluster state %d after %d, expected a later one", analyzedStates[1],
+			analyzedStates[0])
+	}
+}
+
+func TestAnalyzeInBackgroundCancelled(t *testing.T) {
+	analyzer := analysisSchedulerImpl{
+		capabilityAnalyzer: createMockCapabilityAnalyzer(t, []mockCapabilityAnalyzerCall{
+			{clusterState: capability.ClusterState{}, returnValue: capability.AnalysisResult{}},
+		}),
+		policyAnalyzer: createMockPolicyAnalyzer(t, []mockPolicyAnalyzerCall{
+			{clusterState: networkpolicy.ClusterState{}, returnValue: networkpolicy.AnalysisResult{}},
+		}),
+		podAnalyzer: createMockPodAnalyzer(t, []mockPodAnalyzerCall{
+			{clusterState: pod.ClusterState{}, returnValue: pod.AnalysisResult{}},
+		}),
+		hostPortAnalyzer: createMockHostPortAnalyzer(t, []mockHostPortAnalyzerCall{
+			{clusterState: hostport.ClusterState{}, returnValue: hostport.AnalysisResult{}},
+		}),
+		networkAnalyzer: createMockNetworkAnalyzer(t, []mockNetworkAnalyzerCall{
+			{clusterState: clusternetwork.ClusterState{}, returnValue: clusternetwork.AnalysisResult{}},
+		}),
+		systemAnalyzer: createMockSystemAnalyzer(t, []mockSystemAnalyzerCall{
+			{clusterState: system.ClusterState{}, returnValue: system.AnalysisResult{}},
+		}),
+		trafficAnalyzer: createMockTrafficAnalyzer(t, []mockTrafficAnalyzerCall{
+			{clusterState: traffic.ClusterState{}, returnValue: traffic.AnalysisResult{Cancelled: true}},
+		}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	select {
+	case analysisResult, ok := <-analyzer.analyzeInBackground(ctx, types.ClusterState{}, nil):
+		if ok {
+			t.Errorf("analyzeInBackground() sent %v, expected no result once cancelled", analysisResult)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("Test timed out (the channel was not closed)")
+	}
+}
+
 type mockPodAnalyzerCall struct {
 	clusterState pod.ClusterState
 	returnValue  pod.AnalysisResult
@@ -247,12 +624,14 @@ type mockTrafficAnalyzer struct {
 }
 
 func (mock mockTrafficAnalyzer) Analyze(clusterState traffic.ClusterState) traffic.AnalysisResult {
+	// Done comes from the context of each analysis, it cannot be known by the test cases
+	clusterState.Done = nil
 	for _, call := range mock.calls {
 		if reflect.DeepEqual(call.clusterState, clusterState) {
 			return call.returnValue
 		}
 	}
-	mock.t.Fatalf("mockTrafficAnalyzer was called with unexpected arguments: \n\tclusterState: %s\n",
+	mock.t.Fatalf("mockTrafficAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
 		clusterState)
 	return traffic.AnalysisResult{}
 }
@@ -319,3 +698,336 @@ func createMockHealthAnalyzer(t *testing.T, calls []mockHealthAnalyzerCall) heal
 		calls: calls,
 	}
 }
+
+type mockCapabilityAnalyzerCall struct {
+	clusterState capability.ClusterState
+	returnValue  capability.AnalysisResult
+}
+
+type mockCapabilityAnalyzer struct {
+	t     *testing.T
+	calls []mockCapabilityAnalyzerCall
+}
+
+func (mock mockCapabilityAnalyzer) Analyze(clusterState capability.ClusterState) capability.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockCapabilityAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return capability.AnalysisResult{}
+}
+
+func createMockCapabilityAnalyzer(t *testing.T, calls []mockCapabilityAnalyzerCall) capability.Analyzer {
+	return mockCapabilityAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockFindingAnalyzerCall struct {
+	clusterState finding.ClusterState
+	returnValue  finding.AnalysisResult
+}
+
+type mockFindingAnalyzer struct {
+	t     *testing.T
+	calls []mockFindingAnalyzerCall
+}
+
+func (mock mockFindingAnalyzer) Analyze(clusterState finding.ClusterState) finding.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockFindingAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return finding.AnalysisResult{}
+}
+
+func createMockFindingAnalyzer(t *testing.T, calls []mockFindingAnalyzerCall) finding.Analyzer {
+	return mockFindingAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockIntentAnalyzerCall struct {
+	clusterState intent.ClusterState
+	returnValue  intent.AnalysisResult
+}
+
+type mockIntentAnalyzer struct {
+	t     *testing.T
+	calls []mockIntentAnalyzerCall
+}
+
+func (mock mockIntentAnalyzer) Analyze(clusterState intent.ClusterState) intent.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockIntentAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return intent.AnalysisResult{}
+}
+
+func createMockIntentAnalyzer(t *testing.T, calls []mockIntentAnalyzerCall) intent.Analyzer {
+	return mockIntentAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockNamespaceAnalyzerCall struct {
+	clusterState namespace.ClusterState
+	returnValue  namespace.AnalysisResult
+}
+
+type mockNamespaceAnalyzer struct {
+	t     *testing.T
+	calls []mockNamespaceAnalyzerCall
+}
+
+func (mock mockNamespaceAnalyzer) Analyze(clusterState namespace.ClusterState) namespace.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockNamespaceAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return namespace.AnalysisResult{}
+}
+
+func createMockNamespaceAnalyzer(t *testing.T, calls []mockNamespaceAnalyzerCall) namespace.Analyzer {
+	return mockNamespaceAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockTighteningAnalyzerCall struct {
+	clusterState tightening.ClusterState
+	returnValue  tightening.AnalysisResult
+}
+
+type mockTighteningAnalyzer struct {
+	t     *testing.T
+	calls []mockTighteningAnalyzerCall
+}
+
+func (mock mockTighteningAnalyzer) Analyze(clusterState tightening.ClusterState) tightening.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockTighteningAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return tightening.AnalysisResult{}
+}
+
+func createMockTighteningAnalyzer(t *testing.T, calls []mockTighteningAnalyzerCall) tightening.Analyzer {
+	return mockTighteningAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockPolicyAnalyzerCall struct {
+	clusterState networkpolicy.ClusterState
+	returnValue  networkpolicy.AnalysisResult
+}
+
+type mockPolicyAnalyzer struct {
+	t     *testing.T
+	calls []mockPolicyAnalyzerCall
+}
+
+func (mock mockPolicyAnalyzer) Analyze(clusterState networkpolicy.ClusterState) networkpolicy.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockPolicyAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return networkpolicy.AnalysisResult{}
+}
+
+func createMockPolicyAnalyzer(t *testing.T, calls []mockPolicyAnalyzerCall) networkpolicy.Analyzer {
+	return mockPolicyAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockSystemAnalyzerCall struct {
+	clusterState system.ClusterState
+	returnValue  system.AnalysisResult
+}
+
+type mockSystemAnalyzer struct {
+	t     *testing.T
+	calls []mockSystemAnalyzerCall
+}
+
+func (mock mockSystemAnalyzer) Analyze(clusterState system.ClusterState) system.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockSystemAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return system.AnalysisResult{}
+}
+
+func createMockSystemAnalyzer(t *testing.T, calls []mockSystemAnalyzerCall) system.Analyzer {
+	return mockSystemAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockRiskAnalyzerCall struct {
+	clusterState risk.ClusterState
+	returnValue  risk.AnalysisResult
+}
+
+type mockRiskAnalyzer struct {
+	t     *testing.T
+	calls []mockRiskAnalyzerCall
+}
+
+func (mock mockRiskAnalyzer) Analyze(clusterState risk.ClusterState) risk.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockRiskAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return risk.AnalysisResult{}
+}
+
+func createMockRiskAnalyzer(t *testing.T, calls []mockRiskAnalyzerCall) risk.Analyzer {
+	return mockRiskAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockHostPortAnalyzerCall struct {
+	clusterState hostport.ClusterState
+	returnValue  hostport.AnalysisResult
+}
+
+type mockHostPortAnalyzer struct {
+	t     *testing.T
+	calls []mockHostPortAnalyzerCall
+}
+
+func (mock mockHostPortAnalyzer) Analyze(clusterState hostport.ClusterState) hostport.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockHostPortAnalyzer was called with unexpected arguments: \n\tclusterState: %s\n", clusterState)
+	return hostport.AnalysisResult{}
+}
+
+func createMockHostPortAnalyzer(t *testing.T, calls []mockHostPortAnalyzerCall) hostport.Analyzer {
+	return mockHostPortAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockHairpinAnalyzerCall struct {
+	clusterState hairpin.ClusterState
+	returnValue  hairpin.AnalysisResult
+}
+
+type mockHairpinAnalyzer struct {
+	t     *testing.T
+	calls []mockHairpinAnalyzerCall
+}
+
+func (mock mockHairpinAnalyzer) Analyze(clusterState hairpin.ClusterState) hairpin.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockHairpinAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n", clusterState)
+	return hairpin.AnalysisResult{}
+}
+
+func createMockHairpinAnalyzer(t *testing.T, calls []mockHairpinAnalyzerCall) hairpin.Analyzer {
+	return mockHairpinAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockNetworkAnalyzerCall struct {
+	clusterState clusternetwork.ClusterState
+	returnValue  clusternetwork.AnalysisResult
+}
+
+type mockNetworkAnalyzer struct {
+	t     *testing.T
+	calls []mockNetworkAnalyzerCall
+}
+
+func (mock mockNetworkAnalyzer) Analyze(clusterState clusternetwork.ClusterState) clusternetwork.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockNetworkAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n", clusterState)
+	return clusternetwork.AnalysisResult{}
+}
+
+func createMockNetworkAnalyzer(t *testing.T, calls []mockNetworkAnalyzerCall) clusternetwork.Analyzer {
+	return mockNetworkAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
+
+type mockExtensionAnalyzerCall struct {
+	clusterState extension.ClusterState
+	returnValue  extension.AnalysisResult
+}
+
+type mockExtensionAnalyzer struct {
+	t     *testing.T
+	calls []mockExtensionAnalyzerCall
+}
+
+func (mock mockExtensionAnalyzer) Analyze(clusterState extension.ClusterState) extension.AnalysisResult {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.clusterState, clusterState) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockExtensionAnalyzer was called with unexpected arguments: \n\tclusterState: %v\n",
+		clusterState)
+	return extension.AnalysisResult{}
+}
+
+func createMockExtensionAnalyzer(t *testing.T, calls []mockExtensionAnalyzerCall) extension.Analyzer {
+	return mockExtensionAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
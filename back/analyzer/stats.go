@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"karto/types"
+	"time"
+)
+
+// stageTimer measures the successive stages of an analysis, each stage lasting until the next lap
+type stageTimer struct {
+	startedAt time.Time
+	lastLap   time.Time
+	stages    []*types.StageStats
+}
+
+func newStageTimer() *stageTimer {
+	now := time.Now()
+	return &stageTimer{
+		startedAt: now,
+		lastLap:   now,
+		stages:    make([]*types.StageStats, 0),
+	}
+}
+
+func (timer *stageTimer) lap(name string) {
+	now := time.Now()
+	timer.record(name, now.Sub(timer.lastLap))
+	timer.lastLap = now
+}
+
+// splitLap ends a stage made of two parts, the duration of the first one being measured by the stage itself
+func (timer *stageTimer) splitLap(firstName string, firstDuration time.Duration, secondName string) {
+	now := time.Now()
+	timer.record(firstName, firstDuration)
+	timer.record(secondName, now.Sub(timer.lastLap)-firstDuration)
+	timer.lastLap = now
+}
+
+func (timer *stageTimer) record(name string, duration time.Duration) {
+	timer.stages = append(timer.stages, &types.StageStats{Name: name, DurationMs: milliseconds(duration)})
+}
+
+func (timer *stageTimer) stats(analysisResult types.AnalysisResult) *types.AnalysisStats {
+	return &types.AnalysisStats{
+		StartedAt:  timer.startedAt.UTC(),
+		DurationMs: milliseconds(time.Since(timer.startedAt)),
+		Stages:     timer.stages,
+		Counts: types.ObjectCounts{
+			Namespaces:      len(analysisResult.Namespaces),
+			Pods:            len(analysisResult.Pods),
+			NetworkPolicies: len(analysisResult.NetworkPolicies),
+			Services:        len(analysisResult.Services),
+			Ingresses:       len(analysisResult.Ingresses),
+			Workloads: len(analysisResult.ReplicaSets) + len(analysisResult.StatefulSets) +
+				len(analysisResult.DaemonSets) + len(analysisResult.Deployments),
+			AllowedRoutes: len(analysisResult.AllowedRoutes),
+			Findings:      len(analysisResult.Findings),
+		},
+	}
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
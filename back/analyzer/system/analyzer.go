@@ -0,0 +1,78 @@
+package system
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+)
+
+type component struct {
+	name   string
+	labels map[string][]string
+}
+
+// Well-known components are recognized by the labels of their usual manifests, whatever their namespace
+var knownComponents = []component{
+	{name: "kube-dns", labels: map[string][]string{"k8s-app": {"kube-dns", "coredns"}}},
+	{name: "metrics-server", labels: map[string][]string{
+		"k8s-app":                {"metrics-server"},
+		"app.kubernetes.io/name": {"metrics-server"},
+	}},
+	{name: "kube-proxy", labels: map[string][]string{"k8s-app": {"kube-proxy"}}},
+}
+
+type ClusterState struct {
+	Pods []*corev1.Pod
+}
+
+type AnalysisResult struct {
+	SystemComponents []*types.SystemComponent
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	systemComponents := make([]*types.SystemComponent, 0)
+	componentsByKey := make(map[string]*types.SystemComponent)
+	for _, pod := range clusterState.Pods {
+		name, ok := analyzer.componentOf(pod)
+		if !ok {
+			continue
+		}
+		key := pod.Namespace + "/" + name
+		systemComponent, ok := componentsByKey[key]
+		if !ok {
+			systemComponent = &types.SystemComponent{
+				Name:      name,
+				Namespace: pod.Namespace,
+				Pods:      make([]types.PodRef, 0),
+			}
+			componentsByKey[key] = systemComponent
+			systemComponents = append(systemComponents, systemComponent)
+		}
+		systemComponent.Pods = append(systemComponent.Pods, types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	return AnalysisResult{
+		SystemComponents: systemComponents,
+	}
+}
+
+func (analyzer analyzerImpl) componentOf(pod *corev1.Pod) (string, bool) {
+	for _, knownComponent := range knownComponents {
+		for key, values := range knownComponent.labels {
+			for _, value := range values {
+				if pod.Labels[key] == value {
+					return knownComponent.name, true
+				}
+			}
+		}
+	}
+	return "", false
+}
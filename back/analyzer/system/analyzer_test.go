@@ -0,0 +1,76 @@
+package system
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		clusterState ClusterState
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "pods of well-known components are grouped by component and namespace",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("coredns-1").WithNamespace("kube-system").
+							WithLabel("k8s-app", "kube-dns").Build(),
+						testutils.NewPodBuilder().WithName("app").WithNamespace("default").
+							WithLabel("app", "front").Build(),
+						testutils.NewPodBuilder().WithName("coredns-2").WithNamespace("kube-system").
+							WithLabel("k8s-app", "kube-dns").Build(),
+						testutils.NewPodBuilder().WithName("metrics-server").WithNamespace("monitoring").
+							WithLabel("app.kubernetes.io/name", "metrics-server").Build(),
+						testutils.NewPodBuilder().WithName("kube-proxy-abc").WithNamespace("kube-system").
+							WithLabel("k8s-app", "kube-proxy").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				SystemComponents: []*types.SystemComponent{
+					{Name: "kube-dns", Namespace: "kube-system", Pods: []types.PodRef{
+						{Name: "coredns-1", Namespace: "kube-system"},
+						{Name: "coredns-2", Namespace: "kube-system"},
+					}},
+					{Name: "metrics-server", Namespace: "monitoring", Pods: []types.PodRef{
+						{Name: "metrics-server", Namespace: "monitoring"},
+					}},
+					{Name: "kube-proxy", Namespace: "kube-system", Pods: []types.PodRef{
+						{Name: "kube-proxy-abc", Namespace: "kube-system"},
+					}},
+				},
+			},
+		},
+		{
+			name: "no component is identified without well-known labels",
+			args: args{
+				clusterState: ClusterState{
+					Pods: []*corev1.Pod{
+						testutils.NewPodBuilder().WithName("coredns").WithNamespace("kube-system").Build(),
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				SystemComponents: []*types.SystemComponent{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
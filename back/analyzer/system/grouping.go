@@ -0,0 +1,209 @@
+package system
+
+import (
+	"karto/routeports"
+	"karto/types"
+)
+
+const ComponentLabel = "karto.zenika.com/system-component"
+
+// Group replaces the pods of each system component by a single pod named after the component, merging their
+// isolations, routes, health and workload references so that the routes of the component are preserved
+func Group(analysisResult types.AnalysisResult) types.AnalysisResult {
+	if len(analysisResult.SystemComponents) == 0 {
+		return analysisResult
+	}
+	componentPods := make(map[types.PodRef]types.PodRef)
+	for _, systemComponent := range analysisResult.SystemComponents {
+		componentPod := types.PodRef{Name: systemComponent.Name, Namespace: systemComponent.Namespace}
+		for _, pod := range systemComponent.Pods {
+			componentPods[pod] = componentPod
+		}
+	}
+	groupRef := func(pod types.PodRef) types.PodRef {
+		if componentPod, ok := componentPods[pod]; ok {
+			return componentPod
+		}
+		return pod
+	}
+	analysisResult.Pods = groupPods(analysisResult.Pods, componentPods)
+	analysisResult.PodIsolations = groupPodIsolations(analysisResult.PodIsolations, groupRef)
+	analysisResult.AllowedRoutes = groupAllowedRoutes(analysisResult.AllowedRoutes, groupRef)
+	analysisResult.PodHealths = groupPodHealths(analysisResult.PodHealths, groupRef)
+	services := make([]*types.Service, 0)
+	for _, service := range analysisResult.Services {
+		grouped := *service
+		grouped.TargetPods = groupRefs(service.TargetPods, groupRef)
+		services = append(services, &grouped)
+	}
+	analysisResult.Services = services
+	replicaSets := make([]*types.ReplicaSet, 0)
+	for _, replicaSet := range analysisResult.ReplicaSets {
+		grouped := *replicaSet
+		grouped.TargetPods = groupRefs(replicaSet.TargetPods, groupRef)
+		replicaSets = append(replicaSets, &grouped)
+	}
+	analysisResult.ReplicaSets = replicaSets
+	statefulSets := make([]*types.StatefulSet, 0)
+	for _, statefulSet := range analysisResult.StatefulSets {
+		grouped := *statefulSet
+		grouped.TargetPods = groupRefs(statefulSet.TargetPods, groupRef)
+		statefulSets = append(statefulSets, &grouped)
+	}
+	analysisResult.StatefulSets = statefulSets
+	daemonSets := make([]*types.DaemonSet, 0)
+	for _, daemonSet := range analysisResult.DaemonSets {
+		grouped := *daemonSet
+		grouped.TargetPods = groupRefs(daemonSet.TargetPods, groupRef)
+		daemonSets = append(daemonSets, &grouped)
+	}
+	analysisResult.DaemonSets = daemonSets
+	return analysisResult
+}
+
+func groupPods(pods []*types.Pod, componentPods map[types.PodRef]types.PodRef) []*types.Pod {
+	result := make([]*types.Pod, 0)
+	added := make(map[types.PodRef]bool)
+	for _, pod := range pods {
+		componentPod, ok := componentPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}]
+		if !ok {
+			result = append(result, pod)
+			continue
+		}
+		if added[componentPod] {
+			continue
+		}
+		added[componentPod] = true
+		result = append(result, &types.Pod{
+			Name:      componentPod.Name,
+			Namespace: componentPod.Namespace,
+			Labels:    map[string]string{ComponentLabel: componentPod.Name},
+		})
+	}
+	return result
+}
+
+// A component is only considered isolated when all its pods are
+func groupPodIsolations(podIsolations []*types.PodIsolation,
+	groupRef func(types.PodRef) types.PodRef) []*types.PodIsolation {
+	result := make([]*types.PodIsolation, 0)
+	byPod := make(map[types.PodRef]*types.PodIsolation)
+	for _, podIsolation := range podIsolations {
+		pod := groupRef(podIsolation.Pod)
+		grouped, ok := byPod[pod]
+		if !ok {
+			grouped = &types.PodIsolation{Pod: pod, IsIngressIsolated: true, IsEgressIsolated: true}
+			byPod[pod] = grouped
+			result = append(result, grouped)
+		}
+		grouped.IsIngressIsolated = grouped.IsIngressIsolated && podIsolation.IsIngressIsolated
+		grouped.IsEgressIsolated = grouped.IsEgressIsolated && podIsolation.IsEgressIsolated
+	}
+	return result
+}
+
+func groupAllowedRoutes(allowedRoutes []*types.AllowedRoute,
+	groupRef func(types.PodRef) types.PodRef) []*types.AllowedRoute {
+	type routeKey struct {
+		source types.PodRef
+		target types.PodRef
+	}
+	result := make([]*types.AllowedRoute, 0)
+	byKey := make(map[routeKey]*types.AllowedRoute)
+	for _, allowedRoute := range allowedRoutes {
+		key := routeKey{source: groupRef(allowedRoute.SourcePod), target: groupRef(allowedRoute.TargetPod)}
+		if key.source == key.target && (key.source != allowedRoute.SourcePod || key.target != allowedRoute.TargetPod) {
+			// Routes between the pods of a same component are internal to it
+			continue
+		}
+		grouped, ok := byKey[key]
+		if !ok {
+			copied := *allowedRoute
+			copied.SourcePod = key.source
+			copied.TargetPod = key.target
+			byKey[key] = &copied
+			result = append(result, &copied)
+			continue
+		}
+		grouped.EgressPolicies = mergePolicies(grouped.EgressPolicies, allowedRoute.EgressPolicies)
+		grouped.IngressPolicies = mergePolicies(grouped.IngressPolicies, allowedRoute.IngressPolicies)
+		ports := routeports.Merge(routeports.Of(grouped), routeports.Of(allowedRoute))
+		grouped.Ports, grouped.PortRanges, grouped.ProtocolPorts = ports.Ports, ports.PortRanges, ports.ProtocolPorts
+		grouped.Warnings = mergeStrings(grouped.Warnings, allowedRoute.Warnings)
+		grouped.Intents = mergeStrings(grouped.Intents, allowedRoute.Intents)
+		grouped.SameNode = mergeSameNode(grouped.SameNode, allowedRoute.SameNode)
+	}
+	return result
+}
+
+// A grouped route is only known to stay on a same node, or to cross nodes, when all of its routes do
+func mergeSameNode(sameNode *bool, other *bool) *bool {
+	if sameNode == nil || other == nil || *sameNode != *other {
+		return nil
+	}
+	return sameNode
+}
+
+func groupPodHealths(podHealths []*types.PodHealth, groupRef func(types.PodRef) types.PodRef) []*types.PodHealth {
+	result := make([]*types.PodHealth, 0)
+	byPod := make(map[types.PodRef]*types.PodHealth)
+	for _, podHealth := range podHealths {
+		pod := groupRef(podHealth.Pod)
+		grouped, ok := byPod[pod]
+		if !ok {
+			grouped = &types.PodHealth{Pod: pod}
+			byPod[pod] = grouped
+			result = append(result, grouped)
+		}
+		grouped.Containers += podHealth.Containers
+		grouped.ContainersRunning += podHealth.ContainersRunning
+		grouped.ContainersReady += podHealth.ContainersReady
+		grouped.ContainersWithoutRestart += podHealth.ContainersWithoutRestart
+	}
+	return result
+}
+
+func groupRefs(pods []types.PodRef, groupRef func(types.PodRef) types.PodRef) []types.PodRef {
+	result := make([]types.PodRef, 0)
+	added := make(map[types.PodRef]bool)
+	for _, pod := range pods {
+		grouped := groupRef(pod)
+		if !added[grouped] {
+			added[grouped] = true
+			result = append(result, grouped)
+		}
+	}
+	return result
+}
+
+func mergePolicies(policies []types.NetworkPolicy, others []types.NetworkPolicy) []types.NetworkPolicy {
+	result := append(make([]types.NetworkPolicy, 0), policies...)
+	for _, other := range others {
+		found := false
+		for _, policy := range policies {
+			if policy.Name == other.Name && policy.Namespace == other.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+func mergeStrings(values []string, others []string) []string {
+	if values == nil && others == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, value := range append(append(make([]string, 0), values...), others...) {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
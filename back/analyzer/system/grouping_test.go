@@ -0,0 +1,120 @@
+package system
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	type args struct {
+		analysisResult types.AnalysisResult
+	}
+	dns1 := types.PodRef{Name: "coredns-1", Namespace: "kube-system"}
+	dns2 := types.PodRef{Name: "coredns-2", Namespace: "kube-system"}
+	app := types.PodRef{Name: "app", Namespace: "default"}
+	dns := types.PodRef{Name: "kube-dns", Namespace: "kube-system"}
+	policy1 := types.NetworkPolicy{Name: "allow-dns", Namespace: "kube-system"}
+	policy2 := types.NetworkPolicy{Name: "allow-metrics", Namespace: "kube-system"}
+	sameNode, otherNode := true, false
+	systemComponents := []*types.SystemComponent{
+		{Name: "kube-dns", Namespace: "kube-system", Pods: []types.PodRef{dns1, dns2}},
+	}
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult types.AnalysisResult
+	}{
+		{
+			name: "pods of a component are replaced by a single labeled pod keeping their merged routes",
+			args: args{
+				analysisResult: types.AnalysisResult{
+					Pods: []*types.Pod{
+						{Name: "coredns-1", Namespace: "kube-system", Labels: map[string]string{"k8s-app": "kube-dns"}},
+						{Name: "app", Namespace: "default", Labels: map[string]string{"app": "front"}},
+						{Name: "coredns-2", Namespace: "kube-system", Labels: map[string]string{"k8s-app": "kube-dns"}},
+					},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: dns1, IsIngressIsolated: true, IsEgressIsolated: true},
+						{Pod: app, IsIngressIsolated: false, IsEgressIsolated: true},
+						{Pod: dns2, IsIngressIsolated: true, IsEgressIsolated: false},
+					},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: app, TargetPod: dns1, IngressPolicies: []types.NetworkPolicy{policy1},
+							Ports: []int32{53}, SameNode: &sameNode,
+							ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolUDP, Ports: []int32{53}}}},
+						{SourcePod: app, TargetPod: dns2, IngressPolicies: []types.NetworkPolicy{policy1, policy2},
+							Ports: []int32{9153, 53}, SameNode: &otherNode,
+							ProtocolPorts: []types.ProtocolPorts{
+								{Protocol: corev1.ProtocolTCP, Ports: []int32{9153}},
+								{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+							}},
+						{SourcePod: dns1, TargetPod: dns2, Ports: nil},
+						{SourcePod: dns2, TargetPod: app, Ports: nil, Warnings: []string{"warning"},
+							SameNode: &otherNode},
+					},
+					Services: []*types.Service{
+						{Name: "kube-dns", Namespace: "kube-system", TargetPods: []types.PodRef{dns1, dns2}},
+					},
+					PodHealths: []*types.PodHealth{
+						{Pod: dns1, Containers: 1, ContainersRunning: 1, ContainersReady: 1, ContainersWithoutRestart: 1},
+						{Pod: dns2, Containers: 1, ContainersRunning: 1, ContainersReady: 0, ContainersWithoutRestart: 0},
+					},
+					SystemComponents: systemComponents,
+				},
+			},
+			expectedAnalysisResult: types.AnalysisResult{
+				Pods: []*types.Pod{
+					{Name: "kube-dns", Namespace: "kube-system", Labels: map[string]string{ComponentLabel: "kube-dns"}},
+					{Name: "app", Namespace: "default", Labels: map[string]string{"app": "front"}},
+				},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: dns, IsIngressIsolated: true, IsEgressIsolated: false},
+					{Pod: app, IsIngressIsolated: false, IsEgressIsolated: true},
+				},
+				AllowedRoutes: []*types.AllowedRoute{
+					{SourcePod: app, TargetPod: dns, IngressPolicies: []types.NetworkPolicy{policy1, policy2},
+						EgressPolicies: []types.NetworkPolicy{}, Ports: []int32{53, 9153},
+						ProtocolPorts: []types.ProtocolPorts{
+							{Protocol: corev1.ProtocolTCP, Ports: []int32{9153}},
+							{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+						}},
+					{SourcePod: dns, TargetPod: app, Ports: nil, Warnings: []string{"warning"},
+						SameNode: &otherNode},
+				},
+				Services: []*types.Service{
+					{Name: "kube-dns", Namespace: "kube-system", TargetPods: []types.PodRef{dns}},
+				},
+				ReplicaSets:  []*types.ReplicaSet{},
+				StatefulSets: []*types.StatefulSet{},
+				DaemonSets:   []*types.DaemonSet{},
+				PodHealths: []*types.PodHealth{
+					{Pod: dns, Containers: 2, ContainersRunning: 2, ContainersReady: 1, ContainersWithoutRestart: 1},
+				},
+				SystemComponents: systemComponents,
+			},
+		},
+		{
+			name: "the analysis result is left untouched without system components",
+			args: args{
+				analysisResult: types.AnalysisResult{
+					Pods:             []*types.Pod{{Name: "app", Namespace: "default"}},
+					SystemComponents: []*types.SystemComponent{},
+				},
+			},
+			expectedAnalysisResult: types.AnalysisResult{
+				Pods:             []*types.Pod{{Name: "app", Namespace: "default"}},
+				SystemComponents: []*types.SystemComponent{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysisResult := Group(tt.args.analysisResult)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Group() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tightening
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/analyzer/utils"
+	"karto/routeports"
+	"karto/types"
+	"sort"
+	"strings"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+type ClusterState struct {
+	Pods            []*corev1.Pod
+	NetworkPolicies []*networkingv1.NetworkPolicy
+	AllowedRoutes   []*types.AllowedRoute
+}
+
+type AnalysisResult struct {
+	Suggestions []*types.TighteningSuggestion
+}
+
+type Analyzer interface {
+	Analyze(clusterState ClusterState) AnalysisResult
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+type peerGroup struct {
+	namespace string
+	podLabels map[string]string
+	ports     routeports.Set
+}
+
+func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
+	podsByRef := make(map[types.PodRef]*corev1.Pod)
+	for _, pod := range clusterState.Pods {
+		podsByRef[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod
+	}
+	suggestions := make([]*types.TighteningSuggestion, 0)
+	for _, policy := range clusterState.NetworkPolicies {
+		suggestion := analyzer.tighten(policy, clusterState.AllowedRoutes, podsByRef)
+		if suggestion != nil {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return AnalysisResult{
+		Suggestions: suggestions,
+	}
+}
+
+func (analyzer analyzerImpl) tighten(policy *networkingv1.NetworkPolicy, allowedRoutes []*types.AllowedRoute,
+	podsByRef map[types.PodRef]*corev1.Pod) *types.TighteningSuggestion {
+	reasons := make([]string, 0)
+	keptRules := make([]networkingv1.NetworkPolicyIngressRule, 0)
+	for _, ingressRule := range policy.Spec.Ingress {
+		ruleReasons := analyzer.broadnessReasons(ingressRule)
+		if len(ruleReasons) == 0 {
+			keptRules = append(keptRules, ingressRule)
+		}
+		reasons = append(reasons, ruleReasons...)
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	peerGroups, unexpectedSources := analyzer.observedPeerGroups(policy, allowedRoutes, podsByRef)
+	if len(peerGroups) == 0 {
+		// Without any evidence of the expected peers, the only narrower policy would deny everything
+		return nil
+	}
+	if unexpectedSources > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d allowed sources without egress policy are not kept, as no "+
+			"rule declares their traffic as expected", unexpectedSources))
+	}
+	suggestedPolicy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    policy.Labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: policy.Spec.PodSelector,
+			PolicyTypes: policy.Spec.PolicyTypes,
+			Ingress:     append(keptRules, analyzer.toIngressRules(peerGroups, policy.Namespace)...),
+			Egress:      policy.Spec.Egress,
+		},
+	}
+	return &types.TighteningSuggestion{
+		Policy:          types.NetworkPolicy{Name: policy.Name, Namespace: policy.Namespace, Labels: policy.Labels},
+		Reasons:         reasons,
+		SuggestedPolicy: suggestedPolicy,
+	}
+}
+
+func (analyzer analyzerImpl) broadnessReasons(ingressRule networkingv1.NetworkPolicyIngressRule) []string {
+	reasons := make([]string, 0)
+	if len(ingressRule.From) == 0 {
+		reasons = append(reasons, "an ingress rule allows traffic from any source")
+	}
+	for _, peer := range ingressRule.From {
+		if peer.NamespaceSelector != nil && analyzer.isEmpty(peer.NamespaceSelector) {
+			reasons = append(reasons, "an ingress rule allows traffic from all namespaces")
+			break
+		}
+	}
+	if len(ingressRule.Ports) == 0 {
+		reasons = append(reasons, "an ingress rule allows traffic on all ports")
+	}
+	return reasons
+}
+
+func (analyzer analyzerImpl) isEmpty(selector *metav1.LabelSelector) bool {
+	return len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0
+}
+
+func (analyzer analyzerImpl) observedPeerGroups(policy *networkingv1.NetworkPolicy,
+	allowedRoutes []*types.AllowedRoute, podsByRef map[types.PodRef]*corev1.Pod) ([]*peerGroup, int) {
+	peerGroupsByKey := make(map[string]*peerGroup)
+	unexpectedSources := make(map[types.PodRef]bool)
+	for _, allowedRoute := range allowedRoutes {
+		if !analyzer.involves(allowedRoute.IngressPolicies, policy) {
+			continue
+		}
+		if len(allowedRoute.EgressPolicies) == 0 {
+			unexpectedSources[allowedRoute.SourcePod] = true
+			continue
+		}
+		sourcePod, ok := podsByRef[allowedRoute.SourcePod]
+		if !ok {
+			continue
+		}
+		podLabels := utils.SelectableLabels(sourcePod.Labels)
+		key := sourcePod.Namespace + "/" + analyzer.labelsKey(podLabels)
+		group, ok := peerGroupsByKey[key]
+		if !ok {
+			group = &peerGroup{namespace: sourcePod.Namespace, podLabels: podLabels, ports: routeports.None()}
+			peerGroupsByKey[key] = group
+		}
+		group.ports = routeports.Merge(group.ports, routeports.Of(allowedRoute))
+	}
+	keys := make([]string, 0)
+	for key := range peerGroupsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	peerGroups := make([]*peerGroup, 0)
+	for _, key := range keys {
+		peerGroups = append(peerGroups, peerGroupsByKey[key])
+	}
+	return peerGroups, len(unexpectedSources)
+}
+
+func (analyzer analyzerImpl) involves(policies []types.NetworkPolicy, policy *networkingv1.NetworkPolicy) bool {
+	for _, candidate := range policies {
+		if candidate.Name == policy.Name && candidate.Namespace == policy.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) labelsKey(podLabels map[string]string) string {
+	pairs := make([]string, 0)
+	for key, value := range podLabels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (analyzer analyzerImpl) toIngressRules(peerGroups []*peerGroup,
+	policyNamespace string) []networkingv1.NetworkPolicyIngressRule {
+	ingressRules := make([]networkingv1.NetworkPolicyIngressRule, 0)
+	for _, group := range peerGroups {
+		peer := networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: group.podLabels},
+		}
+		if group.namespace != policyNamespace {
+			peer.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabel: group.namespace},
+			}
+		}
+		ingressRules = append(ingressRules, networkingv1.NetworkPolicyIngressRule{
+			From:  []networkingv1.NetworkPolicyPeer{peer},
+			Ports: group.ports.NetworkPolicyPorts(),
+		})
+	}
+	return ingressRules
+}
@@ -0,0 +1,188 @@
+package tightening
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		clusterState ClusterState
+	}
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("back").WithLabel("app", "api").Build()
+	front1 := testutils.NewPodBuilder().WithName("front1").WithNamespace("shop").WithLabel("app", "front").
+		WithLabel("pod-template-hash", "abc").Build()
+	front2 := testutils.NewPodBuilder().WithName("front2").WithNamespace("shop").WithLabel("app", "front").
+		WithLabel("pod-template-hash", "def").Build()
+	job := testutils.NewPodBuilder().WithName("job").WithNamespace("batch").WithLabel("app", "job").Build()
+	apiRef := types.PodRef{Name: "api", Namespace: "back"}
+	front1Ref := types.PodRef{Name: "front1", Namespace: "shop"}
+	front2Ref := types.PodRef{Name: "front2", Namespace: "shop"}
+	jobRef := types.PodRef{Name: "job", Namespace: "batch"}
+	broadPolicy := testutils.NewNetworkPolicyBuilder().WithName("api").WithNamespace("back").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "api").Build()).
+		WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+		}).Build()
+	narrowPolicy := testutils.NewNetworkPolicyBuilder().WithName("narrow").WithNamespace("back").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "api").Build()).
+		WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			From:  []networkingv1.NetworkPolicyPeer{{PodSelector: testutils.NewLabelSelectorBuilder().Build()}},
+			Ports: []networkingv1.NetworkPolicyPort{{Port: &intstr.IntOrString{IntVal: 80}}},
+		}).Build()
+	egressPolicy := types.NetworkPolicy{Name: "front", Namespace: "shop"}
+	apiPolicy := types.NetworkPolicy{Name: "api", Namespace: "back"}
+	tcp := corev1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port443 := intstr.FromInt(443)
+	udp := corev1.ProtocolUDP
+	port53 := intstr.FromInt(53)
+	port8000 := intstr.FromInt(8000)
+	endPort8100 := int32(8100)
+	tests := []struct {
+		name                   string
+		args                   args
+		expectedAnalysisResult AnalysisResult
+	}{
+		{
+			name: "broad ingress rules are narrowed to the peers and ports declared by egress policies",
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{api, front1, front2, job},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{broadPolicy, narrowPolicy},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: front1Ref, TargetPod: apiRef, Ports: []int32{80},
+							IngressPolicies: []types.NetworkPolicy{apiPolicy},
+							EgressPolicies:  []types.NetworkPolicy{egressPolicy}},
+						{SourcePod: front2Ref, TargetPod: apiRef, Ports: []int32{443},
+							IngressPolicies: []types.NetworkPolicy{apiPolicy},
+							EgressPolicies:  []types.NetworkPolicy{egressPolicy}},
+						{SourcePod: jobRef, TargetPod: apiRef, Ports: nil,
+							IngressPolicies: []types.NetworkPolicy{apiPolicy},
+							EgressPolicies:  []types.NetworkPolicy{}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Suggestions: []*types.TighteningSuggestion{
+					{
+						Policy: types.NetworkPolicy{Name: "api", Namespace: "back", Labels: map[string]string{}},
+						Reasons: []string{
+							"an ingress rule allows traffic from all namespaces",
+							"an ingress rule allows traffic on all ports",
+							"1 allowed sources without egress policy are not kept, as no rule declares their " +
+								"traffic as expected",
+						},
+						SuggestedPolicy: &networkingv1.NetworkPolicy{
+							TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+							ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "back",
+								Labels: map[string]string{}},
+							Spec: networkingv1.NetworkPolicySpec{
+								PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+								PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+								Ingress: []networkingv1.NetworkPolicyIngressRule{{
+									From: []networkingv1.NetworkPolicyPeer{{
+										PodSelector: &metav1.LabelSelector{
+											MatchLabels: map[string]string{"app": "front"}},
+										NamespaceSelector: &metav1.LabelSelector{
+											MatchLabels: map[string]string{namespaceNameLabel: "shop"}},
+									}},
+									Ports: []networkingv1.NetworkPolicyPort{
+										{Protocol: &tcp, Port: &port80},
+										{Protocol: &tcp, Port: &port443},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "narrowed ports keep the ranges and protocols of the routes",
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{api, front1},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{broadPolicy},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: front1Ref, TargetPod: apiRef, Ports: []int32{53},
+							PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+							ProtocolPorts: []types.ProtocolPorts{
+								{Protocol: corev1.ProtocolTCP, Ports: []int32{},
+									PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+								{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+							},
+							IngressPolicies: []types.NetworkPolicy{apiPolicy},
+							EgressPolicies:  []types.NetworkPolicy{egressPolicy}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Suggestions: []*types.TighteningSuggestion{
+					{
+						Policy: types.NetworkPolicy{Name: "api", Namespace: "back", Labels: map[string]string{}},
+						Reasons: []string{
+							"an ingress rule allows traffic from all namespaces",
+							"an ingress rule allows traffic on all ports",
+						},
+						SuggestedPolicy: &networkingv1.NetworkPolicy{
+							TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+							ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "back",
+								Labels: map[string]string{}},
+							Spec: networkingv1.NetworkPolicySpec{
+								PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+								PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+								Ingress: []networkingv1.NetworkPolicyIngressRule{{
+									From: []networkingv1.NetworkPolicyPeer{{
+										PodSelector: &metav1.LabelSelector{
+											MatchLabels: map[string]string{"app": "front"}},
+										NamespaceSelector: &metav1.LabelSelector{
+											MatchLabels: map[string]string{namespaceNameLabel: "shop"}},
+									}},
+									Ports: []networkingv1.NetworkPolicyPort{
+										{Protocol: &tcp, Port: &port8000, EndPort: &endPort8100},
+										{Protocol: &udp, Port: &port53},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "broad policies without declared peers are not narrowed",
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{api, job},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{broadPolicy},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: jobRef, TargetPod: apiRef, Ports: nil,
+							IngressPolicies: []types.NetworkPolicy{apiPolicy},
+							EgressPolicies:  []types.NetworkPolicy{}},
+					},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Suggestions: []*types.TighteningSuggestion{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analysisResult := analyzer.Analyze(tt.args.clusterState)
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
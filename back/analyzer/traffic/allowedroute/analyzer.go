@@ -3,18 +3,17 @@ package allowedroute
 import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"karto/analyzer/traffic/shared"
-	"karto/analyzer/utils"
 	"karto/types"
-	"sort"
+	"sync"
 )
 
 const portWildcard = -1
 
 type Analyzer interface {
 	Analyze(sourcePodIsolation *shared.PodIsolation, targetPodIsolation *shared.PodIsolation,
-		namespaces []*corev1.Namespace) *types.AllowedRoute
+		index *shared.LabelIndex) *types.AllowedRoute
 }
 
 type analyzerImpl struct {
@@ -24,173 +23,192 @@ func NewAnalyzer() Analyzer {
 	return analyzerImpl{}
 }
 
+// portPolicy is the policy of a rule that allows a span of ports. Non-isolated pods are allowed on all ports by no
+// policy.
+type portPolicy struct {
+	span   portSpan
+	policy *networkingv1.NetworkPolicy
+}
+
+// scratch holds the buffers reused across pairs of pods, so that only the allowed routes allocate
+type scratch struct {
+	ingress         []portPolicy
+	egress          []portPolicy
+	matched         portSet
+	ingressPolicies []*networkingv1.NetworkPolicy
+	egressPolicies  []*networkingv1.NetworkPolicy
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return &scratch{} }}
+
 func (analyzer analyzerImpl) Analyze(sourcePodIsolation *shared.PodIsolation, targetPodIsolation *shared.PodIsolation,
-	namespaces []*corev1.Namespace) *types.AllowedRoute {
-	ingressPoliciesByPort := analyzer.ingressPoliciesByPort(sourcePodIsolation.Pod, targetPodIsolation, namespaces)
-	egressPoliciesByPort := analyzer.egressPoliciesByPort(targetPodIsolation.Pod, sourcePodIsolation, namespaces)
-	ports, ingressPolicies, egressPolicies := analyzer.matchPoliciesByPort(ingressPoliciesByPort, egressPoliciesByPort)
-	if ports == nil || len(ports) > 0 {
-		return &types.AllowedRoute{
-			SourcePod:       analyzer.toPodRef(sourcePodIsolation),
-			EgressPolicies:  analyzer.toNetworkPolicies(egressPolicies),
-			TargetPod:       analyzer.toPodRef(targetPodIsolation),
-			IngressPolicies: analyzer.toNetworkPolicies(ingressPolicies),
-			Ports:           ports,
-		}
-	} else {
+	index *shared.LabelIndex) *types.AllowedRoute {
+	buffers := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(buffers)
+	buffers.ingress = analyzer.ingressPortPolicies(buffers.ingress[:0], sourcePodIsolation.Pod, targetPodIsolation,
+		index)
+	if len(buffers.ingress) == 0 {
+		return nil
+	}
+	buffers.egress = analyzer.egressPortPolicies(buffers.egress[:0], targetPodIsolation.Pod, sourcePodIsolation, index)
+	if !analyzer.matchPortPolicies(buffers) {
 		return nil
 	}
+	return &types.AllowedRoute{
+		SourcePod:       analyzer.toPodRef(sourcePodIsolation),
+		EgressPolicies:  analyzer.toNetworkPolicies(buffers.egressPolicies),
+		TargetPod:       analyzer.toPodRef(targetPodIsolation),
+		IngressPolicies: analyzer.toNetworkPolicies(buffers.ingressPolicies),
+		Ports:           buffers.matched.ports(),
+		PortRanges:      buffers.matched.portRanges(),
+		ProtocolPorts:   buffers.matched.protocolPorts(),
+	}
 }
 
-func (analyzer analyzerImpl) ingressPoliciesByPort(sourcePod *corev1.Pod, targetPodIsolation *shared.PodIsolation,
-	namespaces []*corev1.Namespace) map[int32][]*networkingv1.NetworkPolicy {
-	policiesByPort := make(map[int32][]*networkingv1.NetworkPolicy)
+func (analyzer analyzerImpl) ingressPortPolicies(portPolicies []portPolicy, sourcePod *corev1.Pod,
+	targetPodIsolation *shared.PodIsolation, index *shared.LabelIndex) []portPolicy {
 	if !targetPodIsolation.IsIngressIsolated() {
-		policiesByPort[portWildcard] = make([]*networkingv1.NetworkPolicy, 0)
-	} else {
-		for i, ingressPolicy := range targetPodIsolation.IngressPolicies {
-			for _, ingressRule := range ingressPolicy.Spec.Ingress {
-				if analyzer.ingressRuleAllows(sourcePod, ingressRule, namespaces) {
-					if len(ingressRule.Ports) == 0 {
-						policies := policiesByPort[portWildcard]
-						if policies == nil {
-							policies = make([]*networkingv1.NetworkPolicy, 0)
-						}
-						policies = append(policies, targetPodIsolation.IngressPolicies[i])
-						policiesByPort[portWildcard] = policies
-					} else {
-						for _, port := range ingressRule.Ports {
-							policies := policiesByPort[port.Port.IntVal]
-							if policies == nil {
-								policies = make([]*networkingv1.NetworkPolicy, 0)
-							}
-							policies = append(policies, targetPodIsolation.IngressPolicies[i])
-							policiesByPort[port.Port.IntVal] = policies
-						}
-					}
-				}
+		return appendAllPorts(portPolicies, nil)
+	}
+	for _, ingressPolicy := range targetPodIsolation.IngressPolicies {
+		for _, ingressRule := range ingressPolicy.Spec.Ingress {
+			if analyzer.ingressRuleAllows(sourcePod, ingressRule, index) {
+				portPolicies = analyzer.appendRulePorts(portPolicies, ingressRule.Ports, ingressPolicy,
+					targetPodIsolation.Pod)
 			}
 		}
 	}
-	return policiesByPort
+	return portPolicies
 }
 
 func (analyzer analyzerImpl) ingressRuleAllows(sourcePod *corev1.Pod, ingressRule networkingv1.NetworkPolicyIngressRule,
-	namespaces []*corev1.Namespace) bool {
-	for _, policyPeer := range ingressRule.From {
-		if analyzer.networkRuleMatches(sourcePod, policyPeer, namespaces) {
+	index *shared.LabelIndex) bool {
+	for i := range ingressRule.From {
+		if index.PeerMatches(sourcePod, &ingressRule.From[i]) {
 			return true
 		}
 	}
 	return false
 }
 
-func (analyzer analyzerImpl) egressPoliciesByPort(targetPod *corev1.Pod, sourcePodIsolation *shared.PodIsolation,
-	namespaces []*corev1.Namespace) map[int32][]*networkingv1.NetworkPolicy {
-	policiesByPort := make(map[int32][]*networkingv1.NetworkPolicy)
+func (analyzer analyzerImpl) egressPortPolicies(portPolicies []portPolicy, targetPod *corev1.Pod,
+	sourcePodIsolation *shared.PodIsolation, index *shared.LabelIndex) []portPolicy {
 	if !sourcePodIsolation.IsEgressIsolated() {
-		policiesByPort[portWildcard] = make([]*networkingv1.NetworkPolicy, 0)
-	} else {
-		for i, egressPolicy := range sourcePodIsolation.EgressPolicies {
-			for _, egressRule := range egressPolicy.Spec.Egress {
-				if analyzer.egressRuleAllows(targetPod, egressRule, namespaces) {
-					if len(egressRule.Ports) == 0 {
-						policies := policiesByPort[portWildcard]
-						if policies == nil {
-							policies = make([]*networkingv1.NetworkPolicy, 0)
-						}
-						policies = append(policies, sourcePodIsolation.EgressPolicies[i])
-						policiesByPort[portWildcard] = policies
-					} else {
-						for _, port := range egressRule.Ports {
-							policies := policiesByPort[port.Port.IntVal]
-							if policies == nil {
-								policies = make([]*networkingv1.NetworkPolicy, 0)
-							}
-							policies = append(policies, sourcePodIsolation.EgressPolicies[i])
-							policiesByPort[port.Port.IntVal] = policies
-						}
-					}
-				}
+		return appendAllPorts(portPolicies, nil)
+	}
+	for _, egressPolicy := range sourcePodIsolation.EgressPolicies {
+		for _, egressRule := range egressPolicy.Spec.Egress {
+			if analyzer.egressRuleAllows(targetPod, egressRule, index) {
+				portPolicies = analyzer.appendRulePorts(portPolicies, egressRule.Ports, egressPolicy, targetPod)
 			}
 		}
 	}
-	return policiesByPort
+	return portPolicies
 }
 
 func (analyzer analyzerImpl) egressRuleAllows(targetPod *corev1.Pod, egressRule networkingv1.NetworkPolicyEgressRule,
-	namespaces []*corev1.Namespace) bool {
-	for _, policyPeer := range egressRule.To {
-		if analyzer.networkRuleMatches(targetPod, policyPeer, namespaces) {
+	index *shared.LabelIndex) bool {
+	for i := range egressRule.To {
+		if index.PeerMatches(targetPod, &egressRule.To[i]) {
 			return true
 		}
 	}
 	return false
 }
 
-func (analyzer analyzerImpl) matchPoliciesByPort(ingressPoliciesByPort map[int32][]*networkingv1.NetworkPolicy,
-	egressPoliciesByPort map[int32][]*networkingv1.NetworkPolicy) ([]int32, []*networkingv1.NetworkPolicy,
-	[]*networkingv1.NetworkPolicy) {
-	portsSet := make(map[int32]bool)
-	ingressPoliciesSet := make(map[*networkingv1.NetworkPolicy]bool)
-	egressPoliciesSet := make(map[*networkingv1.NetworkPolicy]bool)
-	for ingressPort, ingressPolicies := range ingressPoliciesByPort {
-		for egressPort, egressPolicies := range egressPoliciesByPort {
-			if ingressPort == portWildcard || egressPort == portWildcard || ingressPort == egressPort {
-				if ingressPort == portWildcard {
-					portsSet[egressPort] = true
-				} else {
-					portsSet[ingressPort] = true
-				}
-				for _, egressPolicy := range egressPolicies {
-					egressPoliciesSet[egressPolicy] = true
-				}
-				for _, ingressPolicy := range ingressPolicies {
-					ingressPoliciesSet[ingressPolicy] = true
-				}
+// appendRulePorts resolves the named ports of the rule against the container ports of the target pod, those it does
+// not declare allowing no port. The endPort of a numeric port extends it to a range, an endPort before the port
+// allowing no port as the API server rejects it. A rule port without number allows all the ports of its protocol.
+func (analyzer analyzerImpl) appendRulePorts(portPolicies []portPolicy, rulePorts []networkingv1.NetworkPolicyPort,
+	policy *networkingv1.NetworkPolicy, targetPod *corev1.Pod) []portPolicy {
+	if len(rulePorts) == 0 {
+		return appendAllPorts(portPolicies, policy)
+	}
+	for _, rulePort := range rulePorts {
+		protocol := corev1.ProtocolTCP
+		if rulePort.Protocol != nil {
+			protocol = *rulePort.Protocol
+		}
+		span := allPortsOf(protocol)
+		if rulePort.Port != nil && rulePort.Port.Type == intstr.String {
+			containerPort, ok := analyzer.namedPort(targetPod, rulePort)
+			if !ok {
+				continue
+			}
+			span.port, span.endPort = containerPort, containerPort
+		} else if rulePort.Port != nil {
+			span.port, span.endPort = rulePort.Port.IntVal, rulePort.Port.IntVal
+			if rulePort.EndPort != nil {
+				span.endPort = *rulePort.EndPort
+			}
+			if span.endPort < span.port {
+				continue
 			}
 		}
+		portPolicies = append(portPolicies, portPolicy{span: span, policy: policy})
 	}
-	if portsSet[portWildcard] {
-		portsSet = nil
-	}
-	var ports []int32
-	if portsSet != nil {
-		ports = make([]int32, 0, len(portsSet))
-		for port := range portsSet {
-			ports = append(ports, port)
-		}
-		sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return portPolicies
+}
+
+func appendAllPorts(portPolicies []portPolicy, policy *networkingv1.NetworkPolicy) []portPolicy {
+	for _, protocol := range protocols {
+		portPolicies = append(portPolicies, portPolicy{span: allPortsOf(protocol), policy: policy})
 	}
-	ingressPolicies := make([]*networkingv1.NetworkPolicy, 0)
-	for ingressPolicy := range ingressPoliciesSet {
-		ingressPolicies = append(ingressPolicies, ingressPolicy)
+	return portPolicies
+}
+
+func (analyzer analyzerImpl) namedPort(pod *corev1.Pod, rulePort networkingv1.NetworkPolicyPort) (int32, bool) {
+	protocol := corev1.ProtocolTCP
+	if rulePort.Protocol != nil {
+		protocol = *rulePort.Protocol
 	}
-	egressPolicies := make([]*networkingv1.NetworkPolicy, 0)
-	for egressPolicy := range egressPoliciesSet {
-		egressPolicies = append(egressPolicies, egressPolicy)
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			containerProtocol := containerPort.Protocol
+			if containerProtocol == "" {
+				containerProtocol = corev1.ProtocolTCP
+			}
+			if containerPort.Name == rulePort.Port.StrVal && containerProtocol == protocol {
+				return containerPort.ContainerPort, true
+			}
+		}
 	}
-	return ports, ingressPolicies, egressPolicies
+	return 0, false
 }
 
-func (analyzer analyzerImpl) networkRuleMatches(pod *corev1.Pod, policyPeer networkingv1.NetworkPolicyPeer,
-	namespaces []*corev1.Namespace) bool {
-	namespaceMatches := policyPeer.NamespaceSelector == nil ||
-		analyzer.namespaceLabelsMatches(pod.Namespace, namespaces, *policyPeer.NamespaceSelector)
-	selectorMatches := policyPeer.PodSelector == nil || utils.SelectorMatches(pod.Labels, *policyPeer.PodSelector)
-	return selectorMatches && namespaceMatches
+// matchPortPolicies keeps in buffers the ports and policies of the ingress and egress entries allowing a same port of
+// a same protocol, and returns whether any does
+func (analyzer analyzerImpl) matchPortPolicies(buffers *scratch) bool {
+	buffers.matched.reset()
+	buffers.ingressPolicies = buffers.ingressPolicies[:0]
+	buffers.egressPolicies = buffers.egressPolicies[:0]
+	matched := false
+	for _, ingress := range buffers.ingress {
+		for _, egress := range buffers.egress {
+			span, ok := ingress.span.intersect(egress.span)
+			if !ok {
+				continue
+			}
+			matched = true
+			buffers.matched.add(span)
+			buffers.ingressPolicies = appendUniquePolicy(buffers.ingressPolicies, ingress.policy)
+			buffers.egressPolicies = appendUniquePolicy(buffers.egressPolicies, egress.policy)
+		}
+	}
+	return matched
 }
 
-func (analyzer analyzerImpl) namespaceLabelsMatches(namespaceName string, namespaces []*corev1.Namespace,
-	selector metav1.LabelSelector) bool {
-	var namespace corev1.Namespace
-	for _, candidateNamespace := range namespaces {
-		if candidateNamespace.Name == namespaceName {
-			namespace = *candidateNamespace
-			break
+func appendUniquePolicy(policies []*networkingv1.NetworkPolicy,
+	policy *networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	if policy == nil {
+		return policies
+	}
+	for _, existing := range policies {
+		if existing == policy {
+			return policies
 		}
 	}
-	return utils.SelectorMatches(namespace.Labels, selector)
+	return append(policies, policy)
 }
 
 func (analyzer analyzerImpl) toPodRef(podIsolation *shared.PodIsolation) types.PodRef {
@@ -201,7 +219,7 @@ func (analyzer analyzerImpl) toPodRef(podIsolation *shared.PodIsolation) types.P
 }
 
 func (analyzer analyzerImpl) toNetworkPolicies(networkPolicies []*networkingv1.NetworkPolicy) []types.NetworkPolicy {
-	result := make([]types.NetworkPolicy, 0)
+	result := make([]types.NetworkPolicy, 0, len(networkPolicies))
 	for _, networkPolicy := range networkPolicies {
 		result = append(result, analyzer.toNetworkPolicy(networkPolicy))
 	}
@@ -17,6 +17,8 @@ func TestAnalyze(t *testing.T) {
 		targetPodIsolation *shared.PodIsolation
 		namespaces         []*corev1.Namespace
 	}
+	endPort8100, endPort9000 := int32(8100), int32(9000)
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
 	tests := []struct {
 		name                 string
 		args                 args
@@ -559,7 +561,8 @@ func TestAnalyze(t *testing.T) {
 				IngressPolicies: []types.NetworkPolicy{
 					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
 				},
-				Ports: []int32{80},
+				Ports:         []int32{80},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80}}},
 			},
 		},
 		{
@@ -610,7 +613,8 @@ func TestAnalyze(t *testing.T) {
 				IngressPolicies: []types.NetworkPolicy{
 					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
 				},
-				Ports: []int32{80, 443},
+				Ports:         []int32{80, 443},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80, 443}}},
 			},
 		},
 		{
@@ -661,7 +665,8 @@ func TestAnalyze(t *testing.T) {
 				IngressPolicies: []types.NetworkPolicy{
 					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
 				},
-				Ports: []int32{80, 443},
+				Ports:         []int32{80, 443},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80, 443}}},
 			},
 		},
 		{
@@ -824,14 +829,387 @@ func TestAnalyze(t *testing.T) {
 				IngressPolicies: []types.NetworkPolicy{
 					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
 				},
-				Ports: []int32{80},
+				Ports:         []int32{80},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80}}},
+			},
+		},
+		{
+			name: "allowed route ports are egress rule ports when ingress rule port has no number",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{IntVal: 443}},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports:         []int32{443},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{443}}},
+			},
+		},
+		{
+			name: "named ports are resolved against the container ports of the target pod",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "http"}},
+									{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "metrics"}},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").WithContainerPort("http", 8080).
+						WithContainerPort("https", 8443).Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "http"}},
+									{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "https"}},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports:         []int32{8080},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{8080}}},
+			},
+		},
+		{
+			name: "route is forbidden when the target pod declares none of the named ports",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies:  []*networkingv1.NetworkPolicy{},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").WithContainerPort("http", 8080).Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "grpc"}},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: nil,
+		},
+		{
+			name: "allowed route port ranges are the intersection of the ingress and egress rule ranges",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{IntVal: 8050}, EndPort: &endPort9000},
+									{Port: &intstr.IntOrString{IntVal: 53}},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{IntVal: 8000}, EndPort: &endPort8100},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports:      []int32{},
+				PortRanges: []types.PortRange{{Port: 8050, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{
+					{Protocol: corev1.ProtocolTCP, Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8050, EndPort: 8100}}},
+				},
+			},
+		},
+		{
+			name: "allowed route ports are the single rule ports within the ranges of the other side",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{IntVal: 8080}},
+									{Port: &intstr.IntOrString{IntVal: 8443}},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Port: &intstr.IntOrString{IntVal: 8000}, EndPort: &endPort8100},
+									{Port: &intstr.IntOrString{IntVal: 8443}},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports:         []int32{8080, 8443},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{8080, 8443}}},
+			},
+		},
+		{
+			name: "allowed route ports are those of the same protocol on both sides",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Protocol: &tcp},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 80}},
+									{Protocol: &udp, Port: &intstr.IntOrString{IntVal: 53}},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports:         []int32{80},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80}}},
+			},
+		},
+		{
+			name: "allowed route ports of different protocols are listed apart",
+			args: args{
+				sourcePodIsolation: &shared.PodIsolation{
+					Pod:             testutils.NewPodBuilder().WithName("Pod1").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{},
+					EgressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("eg1").WithTypes("Egress").
+							WithEgressRule(networkingv1.NetworkPolicyEgressRule{
+								To: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Protocol: &tcp},
+									{Protocol: &udp},
+								},
+							}).Build(),
+					},
+				},
+				targetPodIsolation: &shared.PodIsolation{
+					Pod: testutils.NewPodBuilder().WithName("Pod2").Build(),
+					IngressPolicies: []*networkingv1.NetworkPolicy{
+						testutils.NewNetworkPolicyBuilder().WithName("in1").WithTypes("Ingress").
+							WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+								From: []networkingv1.NetworkPolicyPeer{
+									{
+										PodSelector: testutils.NewLabelSelectorBuilder().Build(),
+									},
+								},
+								Ports: []networkingv1.NetworkPolicyPort{
+									{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 80}},
+									{Protocol: &udp, Port: &intstr.IntOrString{IntVal: 53}},
+								},
+							}).Build(),
+					},
+					EgressPolicies: []*networkingv1.NetworkPolicy{},
+				},
+				namespaces: []*corev1.Namespace{
+					testutils.NewNamespaceBuilder().WithName("default").Build(),
+				},
+			},
+			expectedAllowedRoute: &types.AllowedRoute{
+				SourcePod: types.PodRef{Name: "Pod1", Namespace: "default"},
+				EgressPolicies: []types.NetworkPolicy{
+					{Name: "eg1", Namespace: "default", Labels: map[string]string{}},
+				},
+				TargetPod: types.PodRef{Name: "Pod2", Namespace: "default"},
+				IngressPolicies: []types.NetworkPolicy{
+					{Name: "in1", Namespace: "default", Labels: map[string]string{}},
+				},
+				Ports: []int32{53, 80},
+				ProtocolPorts: []types.ProtocolPorts{
+					{Protocol: corev1.ProtocolTCP, Ports: []int32{80}},
+					{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			analyzer := NewAnalyzer()
-			allowedRoute := analyzer.Analyze(tt.args.sourcePodIsolation, tt.args.targetPodIsolation, tt.args.namespaces)
+			index := shared.NewLabelIndex([]*corev1.Pod{tt.args.sourcePodIsolation.Pod, tt.args.targetPodIsolation.Pod},
+				tt.args.namespaces)
+			allowedRoute := analyzer.Analyze(tt.args.sourcePodIsolation, tt.args.targetPodIsolation, index)
 			if diff := cmp.Diff(tt.expectedAllowedRoute, allowedRoute); diff != "" {
 				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
 			}
@@ -0,0 +1,325 @@
+//go:build go1.18
+// +build go1.18
+
+package allowedroute
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/analyzer/traffic/shared"
+	"karto/types"
+	"sort"
+	"testing"
+)
+
+var (
+	fuzzNamespaces  = []string{"shop", "monitoring"}
+	fuzzLabelKeys   = []string{"app", "tier"}
+	fuzzLabelValues = []string{"front", "back", "db"}
+	fuzzPorts       = []int32{80, 443, 8080}
+	fuzzOperators   = []metav1.LabelSelectorOperator{metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn,
+		metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist}
+)
+
+// fuzzCluster is a small cluster decoded from the fuzzed bytes, each byte choosing among a few labels, selectors and
+// ports so that peers often match
+type fuzzCluster struct {
+	data       []byte
+	namespaces []*corev1.Namespace
+	pods       []*corev1.Pod
+	policies   []*networkingv1.NetworkPolicy
+}
+
+// sidePorts are the ports one side of a route allows, all of them or those of the set
+type sidePorts struct {
+	allowed bool
+	all     bool
+	ports   map[int32]bool
+}
+
+func (cluster *fuzzCluster) next(n int) int {
+	if len(cluster.data) == 0 {
+		return 0
+	}
+	value := int(cluster.data[0]) % n
+	cluster.data = cluster.data[1:]
+	return value
+}
+
+func (cluster *fuzzCluster) labels() map[string]string {
+	podLabels := map[string]string{}
+	for _, key := range fuzzLabelKeys {
+		if value := cluster.next(len(fuzzLabelValues) + 1); value < len(fuzzLabelValues) {
+			podLabels[key] = fuzzLabelValues[value]
+		}
+	}
+	return podLabels
+}
+
+// selector may have invalid requirements, such as In without values, which match nothing
+func (cluster *fuzzCluster) selector() *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{MatchLabels: cluster.labels()}
+	for i := cluster.next(3); i > 0; i-- {
+		requirement := metav1.LabelSelectorRequirement{
+			Key:      fuzzLabelKeys[cluster.next(len(fuzzLabelKeys))],
+			Operator: fuzzOperators[cluster.next(len(fuzzOperators))],
+		}
+		for j := cluster.next(3); j > 0; j-- {
+			requirement.Values = append(requirement.Values, fuzzLabelValues[cluster.next(len(fuzzLabelValues))])
+		}
+		selector.MatchExpressions = append(selector.MatchExpressions, requirement)
+	}
+	return selector
+}
+
+func (cluster *fuzzCluster) peers() []networkingv1.NetworkPolicyPeer {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0)
+	for i := cluster.next(3); i > 0; i-- {
+		peer := networkingv1.NetworkPolicyPeer{}
+		switch cluster.next(3) {
+		case 0:
+			peer.PodSelector = cluster.selector()
+		case 1:
+			peer.NamespaceSelector = cluster.selector()
+		default:
+			peer.PodSelector = cluster.selector()
+			peer.NamespaceSelector = cluster.selector()
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+func (cluster *fuzzCluster) ports() []networkingv1.NetworkPolicyPort {
+	ports := make([]networkingv1.NetworkPolicyPort, 0)
+	for i := cluster.next(3); i > 0; i-- {
+		port := networkingv1.NetworkPolicyPort{}
+		if value := cluster.next(len(fuzzPorts) + 1); value < len(fuzzPorts) {
+			portNumber := intstr.FromInt(int(fuzzPorts[value]))
+			port.Port = &portNumber
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+func newFuzzCluster(data []byte) *fuzzCluster {
+	cluster := &fuzzCluster{data: data}
+	for _, namespace := range fuzzNamespaces {
+		cluster.namespaces = append(cluster.namespaces, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: cluster.labels()},
+		})
+	}
+	for i := 2 + cluster.next(4); i > 0; i-- {
+		cluster.pods = append(cluster.pods, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      fuzzLabelValues[i%len(fuzzLabelValues)] + string(rune('a'+i)),
+			Namespace: fuzzNamespaces[cluster.next(len(fuzzNamespaces))],
+			Labels:    cluster.labels(),
+		}})
+	}
+	for i := cluster.next(5); i > 0; i-- {
+		policy := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "policy" + string(rune('a'+i)),
+				Namespace: fuzzNamespaces[cluster.next(len(fuzzNamespaces))],
+			},
+			Spec: networkingv1.NetworkPolicySpec{PodSelector: *cluster.selector()},
+		}
+		policyTypes := cluster.next(3)
+		if policyTypes != 1 {
+			policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+			for j := cluster.next(3); j > 0; j-- {
+				policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+					From: cluster.peers(), Ports: cluster.ports()})
+			}
+		}
+		if policyTypes != 0 {
+			policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+			for j := cluster.next(3); j > 0; j-- {
+				policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+					To: cluster.peers(), Ports: cluster.ports()})
+			}
+		}
+		cluster.policies = append(cluster.policies, policy)
+	}
+	return cluster
+}
+
+func (cluster *fuzzCluster) isolations() []*shared.PodIsolation {
+	isolations := make([]*shared.PodIsolation, 0, len(cluster.pods))
+	for _, pod := range cluster.pods {
+		isolation := &shared.PodIsolation{Pod: pod}
+		for _, policy := range cluster.policies {
+			if policy.Namespace != pod.Namespace || !referenceMatches(&policy.Spec.PodSelector, pod.Labels) {
+				continue
+			}
+			for _, policyType := range policy.Spec.PolicyTypes {
+				if policyType == networkingv1.PolicyTypeIngress {
+					isolation.IngressPolicies = append(isolation.IngressPolicies, policy)
+				} else {
+					isolation.EgressPolicies = append(isolation.EgressPolicies, policy)
+				}
+			}
+		}
+		isolations = append(isolations, isolation)
+	}
+	return isolations
+}
+
+func (cluster *fuzzCluster) routes(isolations []*shared.PodIsolation, reversed bool) []*types.AllowedRoute {
+	analyzer := NewAnalyzer()
+	index := shared.NewLabelIndex(cluster.pods, cluster.namespaces)
+	routes := make([]*types.AllowedRoute, 0)
+	for i := range isolations {
+		for j := range isolations {
+			source, target := isolations[i], isolations[j]
+			if reversed {
+				source, target = isolations[len(isolations)-1-i], isolations[len(isolations)-1-j]
+			}
+			if route := analyzer.Analyze(source, target, index); route != nil {
+				routes = append(routes, route)
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].SourcePod != routes[j].SourcePod {
+			return routes[i].SourcePod.Name < routes[j].SourcePod.Name
+		}
+		return routes[i].TargetPod.Name < routes[j].TargetPod.Name
+	})
+	return routes
+}
+
+// referenceMatches evaluates selectors with the selectors of apimachinery, invalid ones matching nothing
+func referenceMatches(selector *metav1.LabelSelector, objectLabels map[string]string) bool {
+	referenceSelector, err := metav1.LabelSelectorAsSelector(selector)
+	return err == nil && referenceSelector.Matches(labels.Set(objectLabels))
+}
+
+// referencePeerMatches follows the model of the analyzer, where a peer without namespace selector matches pods of
+// all namespaces
+func (cluster *fuzzCluster) referencePeerMatches(peer networkingv1.NetworkPolicyPeer, pod *corev1.Pod) bool {
+	if peer.NamespaceSelector != nil {
+		var namespaceLabels map[string]string
+		for _, namespace := range cluster.namespaces {
+			if namespace.Name == pod.Namespace {
+				namespaceLabels = namespace.Labels
+			}
+		}
+		if !referenceMatches(peer.NamespaceSelector, namespaceLabels) {
+			return false
+		}
+	}
+	return peer.PodSelector == nil || referenceMatches(peer.PodSelector, pod.Labels)
+}
+
+func (cluster *fuzzCluster) referenceSide(isolated bool, rulesOf func(*networkingv1.NetworkPolicy) []ruleOf,
+	policies []*networkingv1.NetworkPolicy, peerPod *corev1.Pod) sidePorts {
+	side := sidePorts{ports: map[int32]bool{}}
+	if !isolated {
+		return sidePorts{allowed: true, all: true}
+	}
+	for _, policy := range policies {
+		for _, rule := range rulesOf(policy) {
+			matches := false
+			for _, peer := range rule.peers {
+				matches = matches || cluster.referencePeerMatches(peer, peerPod)
+			}
+			if !matches {
+				continue
+			}
+			side.allowed = true
+			side.all = side.all || len(rule.ports) == 0
+			for _, port := range rule.ports {
+				if port.Port == nil {
+					side.all = true
+				} else {
+					side.ports[port.Port.IntVal] = true
+				}
+			}
+		}
+	}
+	return side
+}
+
+type ruleOf struct {
+	peers []networkingv1.NetworkPolicyPeer
+	ports []networkingv1.NetworkPolicyPort
+}
+
+func ingressRulesOf(policy *networkingv1.NetworkPolicy) []ruleOf {
+	rules := make([]ruleOf, 0)
+	for _, rule := range policy.Spec.Ingress {
+		rules = append(rules, ruleOf{peers: rule.From, ports: rule.Ports})
+	}
+	return rules
+}
+
+func egressRulesOf(policy *networkingv1.NetworkPolicy) []ruleOf {
+	rules := make([]ruleOf, 0)
+	for _, rule := range policy.Spec.Egress {
+		rules = append(rules, ruleOf{peers: rule.To, ports: rule.Ports})
+	}
+	return rules
+}
+
+// referencePorts returns whether the source may reach the target, and on which ports, nil standing for all of them
+func (cluster *fuzzCluster) referencePorts(source *shared.PodIsolation, target *shared.PodIsolation) (bool, []int32) {
+	ingress := cluster.referenceSide(target.IsIngressIsolated(), ingressRulesOf, target.IngressPolicies, source.Pod)
+	egress := cluster.referenceSide(source.IsEgressIsolated(), egressRulesOf, source.EgressPolicies, target.Pod)
+	if !ingress.allowed || !egress.allowed {
+		return false, nil
+	}
+	if ingress.all && egress.all {
+		return true, nil
+	}
+	ports := make([]int32, 0)
+	for _, port := range fuzzPorts {
+		if (ingress.all || ingress.ports[port]) && (egress.all || egress.ports[port]) {
+			ports = append(ports, port)
+		}
+	}
+	return len(ports) > 0, ports
+}
+
+func FuzzAnalyze(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{3, 0, 1, 2, 3, 1, 0, 2, 1, 0, 3, 1, 0, 0, 1, 2, 1, 0, 1, 0, 2, 1, 1})
+	f.Add([]byte{2, 1, 1, 0, 0, 1, 3, 2, 2, 0, 2, 1, 2, 0, 1, 1, 3, 2, 1, 1, 0, 2, 2, 1, 1, 0, 0, 3, 1, 2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cluster := newFuzzCluster(data)
+		isolations := cluster.isolations()
+		routes := cluster.routes(isolations, false)
+		if diff := cmp.Diff(routes, cluster.routes(isolations, true)); diff != "" {
+			t.Fatalf("Analyze() depends on the order of the pairs of pods (-forward +reversed):\n%s", diff)
+		}
+		routesByPods := make(map[[2]types.PodRef]*types.AllowedRoute, len(routes))
+		for _, route := range routes {
+			routesByPods[[2]types.PodRef{route.SourcePod, route.TargetPod}] = route
+		}
+		for _, source := range isolations {
+			for _, target := range isolations {
+				route := routesByPods[[2]types.PodRef{
+					{Name: source.Pod.Name, Namespace: source.Pod.Namespace},
+					{Name: target.Pod.Name, Namespace: target.Pod.Namespace},
+				}]
+				allowed, ports := cluster.referencePorts(source, target)
+				if allowed != (route != nil) {
+					t.Fatalf("Analyze() allows %s to %s: %t, expected %t", source.Pod.Name, target.Pod.Name,
+						route != nil, allowed)
+				}
+				if route == nil {
+					continue
+				}
+				if diff := cmp.Diff(ports, route.Ports); diff != "" {
+					t.Fatalf("Analyze() ports of %s to %s are not those of the rules (-want +got):\n%s",
+						source.Pod.Name, target.Pod.Name, diff)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package allowedroute
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"sort"
+)
+
+const (
+	minPort = 1
+	maxPort = 65535
+)
+
+// protocols are those of network policy ports, all of which are allowed by a rule without ports
+var protocols = []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP}
+
+// portSpan is the range of ports of a protocol from port to endPort, equal for a single port, or all its ports when
+// both are portWildcard
+type portSpan struct {
+	protocol corev1.Protocol
+	port     int32
+	endPort  int32
+}
+
+func allPortsOf(protocol corev1.Protocol) portSpan {
+	return portSpan{protocol: protocol, port: portWildcard, endPort: portWildcard}
+}
+
+// intersect returns the ports of both spans, which must be of the same protocol, a wildcard leaving those of the
+// other one
+func (span portSpan) intersect(other portSpan) (portSpan, bool) {
+	if span.protocol != other.protocol {
+		return portSpan{}, false
+	}
+	if span.port == portWildcard {
+		return other, true
+	}
+	if other.port == portWildcard {
+		return span, true
+	}
+	intersection := span
+	if other.port > intersection.port {
+		intersection.port = other.port
+	}
+	if other.endPort < intersection.endPort {
+		intersection.endPort = other.endPort
+	}
+	return intersection, intersection.port <= intersection.endPort
+}
+
+// portSet is a union of spans. The ports and ranges of the allowed routes are their union over all protocols, all
+// the ports of some protocols only being listed as the range of all port numbers, all ports (nil ports) standing for
+// all the ports of every protocol. The ports and ranges of each protocol are listed apart.
+type portSet struct {
+	wildcards []corev1.Protocol
+	spans     []portSpan
+}
+
+func (set *portSet) reset() {
+	set.wildcards = set.wildcards[:0]
+	set.spans = set.spans[:0]
+}
+
+func (set *portSet) add(span portSpan) {
+	if span.port == portWildcard {
+		if set.allowsAllOf(span.protocol) {
+			return
+		}
+		set.wildcards = append(set.wildcards, span.protocol)
+		return
+	}
+	for _, existing := range set.spans {
+		if existing == span {
+			return
+		}
+	}
+	set.spans = append(set.spans, span)
+}
+
+func (set *portSet) allowsAll() bool {
+	return len(set.wildcards) == len(protocols)
+}
+
+// ports returns the single ports of the set which are in none of its ranges, sorted, nil when it allows all ports
+func (set *portSet) ports() []int32 {
+	if set.allowsAll() {
+		return nil
+	}
+	return singlePorts(set.spans, set.portRanges())
+}
+
+// portRanges returns the ranges of the set, whatever their protocol, sorted and merged when overlapping or adjacent.
+// It is nil when the set has none or allows all ports.
+func (set *portSet) portRanges() []types.PortRange {
+	if set.allowsAll() {
+		return nil
+	}
+	if len(set.wildcards) > 0 {
+		return []types.PortRange{{Port: minPort, EndPort: maxPort}}
+	}
+	return mergedRanges(set.spans)
+}
+
+// protocolPorts returns the ports and ranges of each protocol of the set, in the order of protocols, nil when it
+// allows all ports. Those of a protocol whose ports are all allowed are nil.
+func (set *portSet) protocolPorts() []types.ProtocolPorts {
+	if set.allowsAll() {
+		return nil
+	}
+	result := make([]types.ProtocolPorts, 0)
+	for _, protocol := range protocols {
+		if set.allowsAllOf(protocol) {
+			result = append(result, types.ProtocolPorts{Protocol: protocol})
+			continue
+		}
+		spans := make([]portSpan, 0, len(set.spans))
+		for _, span := range set.spans {
+			if span.protocol == protocol {
+				spans = append(spans, span)
+			}
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		ranges := mergedRanges(spans)
+		result = append(result, types.ProtocolPorts{
+			Protocol:   protocol,
+			Ports:      singlePorts(spans, ranges),
+			PortRanges: ranges,
+		})
+	}
+	return result
+}
+
+func (set *portSet) allowsAllOf(protocol corev1.Protocol) bool {
+	for _, wildcard := range set.wildcards {
+		if wildcard == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func singlePorts(spans []portSpan, ranges []types.PortRange) []int32 {
+	ports := make([]int32, 0, len(spans))
+	for _, span := range spans {
+		if span.port == span.endPort && !inRanges(ranges, span.port) && !containsPort(ports, span.port) {
+			ports = append(ports, span.port)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports
+}
+
+func mergedRanges(spans []portSpan) []types.PortRange {
+	sorted := make([]types.PortRange, 0)
+	for _, span := range spans {
+		if span.port != span.endPort {
+			sorted = append(sorted, types.PortRange{Port: span.port, EndPort: span.endPort})
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Port < sorted[j].Port })
+	ranges := []types.PortRange{sorted[0]}
+	for _, portRange := range sorted[1:] {
+		last := &ranges[len(ranges)-1]
+		if portRange.Port > last.EndPort+1 {
+			ranges = append(ranges, portRange)
+		} else if portRange.EndPort > last.EndPort {
+			last.EndPort = portRange.EndPort
+		}
+	}
+	return ranges
+}
+
+func inRanges(ranges []types.PortRange, port int32) bool {
+	for _, portRange := range ranges {
+		if portRange.Port <= port && port <= portRange.EndPort {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPort(ports []int32, port int32) bool {
+	for _, existing := range ports {
+		if existing == port {
+			return true
+		}
+	}
+	return false
+}
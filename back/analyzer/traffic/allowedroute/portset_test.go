@@ -0,0 +1,137 @@
+package allowedroute
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"testing"
+)
+
+func TestPortSet(t *testing.T) {
+	tcp := func(port int32, endPort int32) portSpan {
+		return portSpan{protocol: corev1.ProtocolTCP, port: port, endPort: endPort}
+	}
+	udp := func(port int32, endPort int32) portSpan {
+		return portSpan{protocol: corev1.ProtocolUDP, port: port, endPort: endPort}
+	}
+	allProtocols := []portSpan{allPortsOf(corev1.ProtocolTCP), allPortsOf(corev1.ProtocolUDP),
+		allPortsOf(corev1.ProtocolSCTP)}
+	tests := []struct {
+		name               string
+		ingress            []portSpan
+		egress             []portSpan
+		expectedMatch      bool
+		expectedPorts      []int32
+		expectedPortRanges []types.PortRange
+		expectedProtocols  []types.ProtocolPorts
+	}{
+		{
+			name:          "all ports of every protocol on both sides allow all ports",
+			ingress:       allProtocols,
+			egress:        allProtocols,
+			expectedMatch: true,
+			expectedPorts: nil,
+		},
+		{
+			name:          "all TCP ports only keep the TCP ports of the other side",
+			ingress:       []portSpan{tcp(80, 80), udp(53, 53)},
+			egress:        []portSpan{allPortsOf(corev1.ProtocolTCP)},
+			expectedMatch: true,
+			expectedPorts: []int32{80},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP, Ports: []int32{80}},
+			},
+		},
+		{
+			name:               "all TCP ports against all ports span all port numbers",
+			ingress:            allProtocols,
+			egress:             []portSpan{allPortsOf(corev1.ProtocolTCP)},
+			expectedMatch:      true,
+			expectedPorts:      []int32{},
+			expectedPortRanges: []types.PortRange{{Port: 1, EndPort: 65535}},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP},
+			},
+		},
+		{
+			name:    "a same port of different protocols does not match",
+			ingress: []portSpan{udp(53, 53)},
+			egress:  []portSpan{tcp(53, 53)},
+		},
+		{
+			name:          "ranges keep the ports of the same protocol within them",
+			ingress:       []portSpan{tcp(8000, 8100)},
+			egress:        []portSpan{tcp(8080, 8080), udp(8090, 8090)},
+			expectedMatch: true,
+			expectedPorts: []int32{8080},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP, Ports: []int32{8080}},
+			},
+		},
+		{
+			name:               "ranges of different protocols are merged, along with the ports within them",
+			ingress:            []portSpan{tcp(8000, 8100), udp(8050, 8200), tcp(9000, 9000)},
+			egress:             []portSpan{allPortsOf(corev1.ProtocolTCP), allPortsOf(corev1.ProtocolUDP)},
+			expectedMatch:      true,
+			expectedPorts:      []int32{9000},
+			expectedPortRanges: []types.PortRange{{Port: 8000, EndPort: 8200}},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP, Ports: []int32{9000},
+					PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+				{Protocol: corev1.ProtocolUDP, Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8050, EndPort: 8200}}},
+			},
+		},
+		{
+			name:          "ports of different protocols are listed apart",
+			ingress:       []portSpan{tcp(80, 80), udp(53, 53)},
+			egress:        []portSpan{allPortsOf(corev1.ProtocolTCP), allPortsOf(corev1.ProtocolUDP)},
+			expectedMatch: true,
+			expectedPorts: []int32{53, 80},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP, Ports: []int32{80}},
+				{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+			},
+		},
+		{
+			name:               "all the ports of a protocol are listed apart from the ports of another",
+			ingress:            allProtocols,
+			egress:             []portSpan{allPortsOf(corev1.ProtocolTCP), udp(53, 53)},
+			expectedMatch:      true,
+			expectedPorts:      []int32{},
+			expectedPortRanges: []types.PortRange{{Port: 1, EndPort: 65535}},
+			expectedProtocols: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP},
+				{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := portSet{}
+			matched := false
+			for _, ingress := range tt.ingress {
+				for _, egress := range tt.egress {
+					if span, ok := ingress.intersect(egress); ok {
+						matched = true
+						set.add(span)
+					}
+				}
+			}
+			if matched != tt.expectedMatch {
+				t.Fatalf("intersect() matched = %v, expected %v", matched, tt.expectedMatch)
+			}
+			if !matched {
+				return
+			}
+			if diff := cmp.Diff(tt.expectedPorts, set.ports()); diff != "" {
+				t.Errorf("ports() result mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedPortRanges, set.portRanges()); diff != "" {
+				t.Errorf("portRanges() result mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedProtocols, set.protocolPorts()); diff != "" {
+				t.Errorf("protocolPorts() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
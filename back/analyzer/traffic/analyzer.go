@@ -1,23 +1,59 @@
 package traffic
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"karto/analyzer/traffic/allowedroute"
+	"karto/analyzer/traffic/enforcement"
 	"karto/analyzer/traffic/podisolation"
 	"karto/analyzer/traffic/shared"
+	"karto/config"
+	"karto/routekey"
 	"karto/types"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 )
 
 type ClusterState struct {
 	Pods            []*corev1.Pod
 	Namespaces      []*corev1.Namespace
+	Nodes           []*corev1.Node
+	DaemonSets      []*appsv1.DaemonSet
 	NetworkPolicies []*networkingv1.NetworkPolicy
+	Capabilities    types.ClusterCapabilities
+	// OnShardComplete, when set, is called with each shard as soon as it is computed, possibly from several
+	// goroutines at once
+	OnShardComplete func(shard Shard)
+	// Done, when closed, stops the computation of routes between the remaining pairs of pods
+	Done <-chan struct{}
+}
+
+// Shard holds the routes towards the pods of a namespace, and the isolation of these pods
+type Shard struct {
+	Namespace     string
+	PodIsolations []*types.PodIsolation
+	AllowedRoutes []*types.AllowedRoute
+	// Total is the number of shards of the analysis
+	Total int
 }
 
 type AnalysisResult struct {
 	Pods          []*types.PodIsolation
 	AllowedRoutes []*types.AllowedRoute
+	// IsolationDuration is the part of the analysis spent computing the isolation of pods, the rest being spent on
+	// the routes between them
+	IsolationDuration time.Duration
+	// ExcludedPairs counts the pairs of pods skipped by the configured exclusions
+	ExcludedPairs int
+	// MergedRoutes counts the routes merged into another of the same canonical key, such as those of a pod
+	// declared twice
+	MergedRoutes int
+	// Cancelled is set when Done was closed before all routes were computed, the result is then incomplete
+	Cancelled bool
 }
 
 type Analyzer interface {
@@ -27,22 +63,135 @@ type Analyzer interface {
 type analyzerImpl struct {
 	podIsolationAnalyzer podisolation.Analyzer
 	allowedRouteAnalyzer allowedroute.Analyzer
+	enforcementAnalyzer  enforcement.Analyzer
+	exclusions           config.ExclusionConfig
+	buffers              *routeBuffers
 }
 
-func NewAnalyzer(podIsolationAnalyzer podisolation.Analyzer, allowedRouteAnalyzer allowedroute.Analyzer) Analyzer {
+func NewAnalyzer(podIsolationAnalyzer podisolation.Analyzer, allowedRouteAnalyzer allowedroute.Analyzer,
+	enforcementAnalyzer enforcement.Analyzer, exclusionConfig *config.ExclusionConfig) Analyzer {
+	exclusions := config.ExclusionConfig{}
+	if exclusionConfig != nil {
+		exclusions = *exclusionConfig
+	}
 	return analyzerImpl{
 		podIsolationAnalyzer: podIsolationAnalyzer,
 		allowedRouteAnalyzer: allowedRouteAnalyzer,
+		enforcementAnalyzer:  enforcementAnalyzer,
+		exclusions:           exclusions,
+		buffers:              newRouteBuffers(),
 	}
 }
 
 func (analyzer analyzerImpl) Analyze(clusterState ClusterState) AnalysisResult {
-	podIsolations := analyzer.podIsolationsOfAllPods(clusterState.Pods, clusterState.NetworkPolicies)
-	allowedRoutes := analyzer.allowedRoutesOfAllPods(podIsolations, clusterState.Namespaces)
+	networkPolicies := analyzer.supportedNetworkPolicies(clusterState.NetworkPolicies, clusterState.Capabilities)
+	start := time.Now()
+	podIsolations := analyzer.podIsolationsOfAllPods(clusterState.Pods, networkPolicies)
+	isolationDuration := time.Since(start)
+	enforcementWarnings := analyzer.enforcementWarningsOfAllPods(clusterState.Pods, clusterState.Nodes,
+		clusterState.DaemonSets)
+	excluded := analyzer.pairExclusion(clusterState.Pods, clusterState.DaemonSets)
+	index := shared.NewLabelIndex(clusterState.Pods, clusterState.Namespaces)
+	allowedRoutes, excludedPairs := analyzer.allowedRoutesOfAllPods(podIsolations, enforcementWarnings, index,
+		excluded, clusterState.OnShardComplete, clusterState.Done)
+	if isDone(clusterState.Done) {
+		return AnalysisResult{Cancelled: true}
+	}
+	mergedRoutes := 0
+	// Each pair of pods has at most one route, which can only be duplicated by a pod declared twice
+	if hasDuplicatePods(clusterState.Pods) {
+		allowedRoutes, mergedRoutes = routekey.Deduplicate(allowedRoutes)
+	}
 	return AnalysisResult{
-		Pods:          analyzer.toPodIsolations(podIsolations),
-		AllowedRoutes: allowedRoutes,
+		Pods:              analyzer.toPodIsolations(podIsolations),
+		AllowedRoutes:     allowedRoutes,
+		IsolationDuration: isolationDuration,
+		ExcludedPairs:     excludedPairs,
+		MergedRoutes:      mergedRoutes,
+	}
+}
+
+func (analyzer analyzerImpl) supportedNetworkPolicies(policies []*networkingv1.NetworkPolicy,
+	capabilities types.ClusterCapabilities) []*networkingv1.NetworkPolicy {
+	supportedPolicies := make([]*networkingv1.NetworkPolicy, 0)
+	for _, policy := range policies {
+		if analyzer.usesUnsupportedPorts(policy, capabilities) {
+			policy = analyzer.withSupportedPorts(policy, capabilities)
+		}
+		supportedPolicies = append(supportedPolicies, policy)
+	}
+	return supportedPolicies
+}
+
+func (analyzer analyzerImpl) usesUnsupportedPorts(policy *networkingv1.NetworkPolicy,
+	capabilities types.ClusterCapabilities) bool {
+	for _, ingressRule := range policy.Spec.Ingress {
+		for _, port := range ingressRule.Ports {
+			if !analyzer.portSupported(port, capabilities) {
+				return true
+			}
+		}
+	}
+	for _, egressRule := range policy.Spec.Egress {
+		for _, port := range egressRule.Ports {
+			if !analyzer.portSupported(port, capabilities) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (analyzer analyzerImpl) portSupported(port networkingv1.NetworkPolicyPort,
+	capabilities types.ClusterCapabilities) bool {
+	isSCTP := port.Protocol != nil && *port.Protocol == corev1.ProtocolSCTP
+	return (capabilities.SCTP || !isSCTP) && (capabilities.EndPort || port.EndPort == nil)
+}
+
+func (analyzer analyzerImpl) withSupportedPorts(policy *networkingv1.NetworkPolicy,
+	capabilities types.ClusterCapabilities) *networkingv1.NetworkPolicy {
+	policy = policy.DeepCopy()
+	ingressRules := make([]networkingv1.NetworkPolicyIngressRule, 0)
+	for _, ingressRule := range policy.Spec.Ingress {
+		ports, ok := analyzer.supportedPorts(ingressRule.Ports, capabilities)
+		if ok {
+			ingressRule.Ports = ports
+			ingressRules = append(ingressRules, ingressRule)
+		}
+	}
+	policy.Spec.Ingress = ingressRules
+	egressRules := make([]networkingv1.NetworkPolicyEgressRule, 0)
+	for _, egressRule := range policy.Spec.Egress {
+		ports, ok := analyzer.supportedPorts(egressRule.Ports, capabilities)
+		if ok {
+			egressRule.Ports = ports
+			egressRules = append(egressRules, egressRule)
+		}
+	}
+	policy.Spec.Egress = egressRules
+	return policy
+}
+
+func (analyzer analyzerImpl) supportedPorts(ports []networkingv1.NetworkPolicyPort,
+	capabilities types.ClusterCapabilities) ([]networkingv1.NetworkPolicyPort, bool) {
+	if len(ports) == 0 {
+		return ports, true
 	}
+	supportedPorts := make([]networkingv1.NetworkPolicyPort, 0)
+	for _, port := range ports {
+		isSCTP := port.Protocol != nil && *port.Protocol == corev1.ProtocolSCTP
+		if isSCTP && !capabilities.SCTP {
+			// Unsupported protocols are never allowed
+			continue
+		}
+		if port.EndPort != nil && !capabilities.EndPort {
+			// Without endPort support, only the first port of the range is allowed
+			port.EndPort = nil
+		}
+		supportedPorts = append(supportedPorts, port)
+	}
+	// A rule whose ports were all removed must not become a rule allowing all ports
+	return supportedPorts, len(supportedPorts) > 0
 }
 
 func (analyzer analyzerImpl) podIsolationsOfAllPods(pods []*corev1.Pod,
@@ -55,22 +204,233 @@ func (analyzer analyzerImpl) podIsolationsOfAllPods(pods []*corev1.Pod,
 	return podIsolations
 }
 
+func (analyzer analyzerImpl) enforcementWarningsOfAllPods(pods []*corev1.Pod, nodes []*corev1.Node,
+	daemonSets []*appsv1.DaemonSet) [][]string {
+	enforcementWarnings := make([][]string, 0)
+	for _, pod := range pods {
+		warnings := analyzer.enforcementAnalyzer.Analyze(pod, nodes, daemonSets)
+		enforcementWarnings = append(enforcementWarnings, warnings)
+	}
+	return enforcementWarnings
+}
+
+// pairExclusion tells whether the routes between the pods of the given indexes must not be computed
+func (analyzer analyzerImpl) pairExclusion(pods []*corev1.Pod, daemonSets []*appsv1.DaemonSet) func(i int, j int) bool {
+	excludedNamespaces := make(map[string]bool)
+	for _, namespace := range analyzer.exclusions.Namespaces {
+		excludedNamespaces[namespace] = true
+	}
+	daemonSetUIDs := make(map[k8stypes.UID]bool)
+	if analyzer.exclusions.SameDaemonSet {
+		for _, daemonSet := range daemonSets {
+			daemonSetUIDs[daemonSet.UID] = true
+		}
+	}
+	podDaemonSets := make([]k8stypes.UID, len(pods))
+	for i, pod := range pods {
+		for _, ownerReference := range pod.OwnerReferences {
+			if daemonSetUIDs[ownerReference.UID] {
+				podDaemonSets[i] = ownerReference.UID
+			}
+		}
+	}
+	return func(i int, j int) bool {
+		if podDaemonSets[i] != "" && podDaemonSets[i] == podDaemonSets[j] {
+			return true
+		}
+		return pods[i].Namespace == pods[j].Namespace && excludedNamespaces[pods[i].Namespace]
+	}
+}
+
+// routeShard holds the routes towards the target pods of a namespace, computed independently of other shards
+type routeShard struct {
+	namespace     string
+	targets       []int
+	routes        []indexedRoute
+	excludedPairs int
+}
+
+type indexedRoute struct {
+	source       int
+	target       int
+	allowedRoute *types.AllowedRoute
+}
+
+// allowedRoutesOfAllPods computes the routes of each target namespace in parallel, and returns them in the order of
+// their source and target pods whatever the order in which shards completed. Once done is closed, workers stop and
+// the remaining shards are neither computed nor completed.
 func (analyzer analyzerImpl) allowedRoutesOfAllPods(podIsolations []*shared.PodIsolation,
-	namespaces []*corev1.Namespace) []*types.AllowedRoute {
-	allowedRoutes := make([]*types.AllowedRoute, 0)
+	enforcementWarnings [][]string, index *shared.LabelIndex, excluded func(i int, j int) bool,
+	onShardComplete func(shard Shard), done <-chan struct{}) ([]*types.AllowedRoute, int) {
+	shards := analyzer.shardsByTargetNamespace(podIsolations)
+	shardsChannel := make(chan *routeShard)
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < workerCount(len(shards)); worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for shard := range shardsChannel {
+				if !analyzer.computeShard(shard, podIsolations, enforcementWarnings, index, excluded, done) {
+					continue
+				}
+				if onShardComplete != nil {
+					onShardComplete(shard.toShard(podIsolations, len(shards)))
+				}
+			}
+		}()
+	}
+sendShards:
+	for _, shard := range shards {
+		select {
+		case shardsChannel <- shard:
+		case <-done:
+			break sendShards
+		}
+	}
+	close(shardsChannel)
+	waitGroup.Wait()
+	routeCount := 0
+	excludedPairs := 0
+	for _, shard := range shards {
+		routeCount += len(shard.routes)
+		excludedPairs += shard.excludedPairs
+	}
+	routes := analyzer.buffers.forAll(routeCount)
+	for _, shard := range shards {
+		routes = append(routes, shard.routes...)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].source != routes[j].source {
+			return routes[i].source < routes[j].source
+		}
+		return routes[i].target < routes[j].target
+	})
+	allowedRoutes := make([]*types.AllowedRoute, 0, len(routes))
+	for _, route := range routes {
+		allowedRoutes = append(allowedRoutes, route.allowedRoute)
+	}
+	analyzer.buffers.release(shards, routes)
+	return allowedRoutes, excludedPairs
+}
+
+func (analyzer analyzerImpl) shardsByTargetNamespace(podIsolations []*shared.PodIsolation) []*routeShard {
+	shards := make([]*routeShard, 0)
+	shardsByNamespace := make(map[string]*routeShard)
+	for j, podIsolation := range podIsolations {
+		namespace := podIsolation.Pod.Namespace
+		shard, ok := shardsByNamespace[namespace]
+		if !ok {
+			shard = &routeShard{namespace: namespace}
+			shardsByNamespace[namespace] = shard
+			shards = append(shards, shard)
+		}
+		shard.targets = append(shard.targets, j)
+	}
+	return shards
+}
+
+// computeShard returns false when done was closed before all the routes of the shard were computed
+func (analyzer analyzerImpl) computeShard(shard *routeShard, podIsolations []*shared.PodIsolation,
+	enforcementWarnings [][]string, index *shared.LabelIndex, excluded func(i int, j int) bool,
+	done <-chan struct{}) bool {
+	shard.routes = analyzer.buffers.forNamespace(shard.namespace)
 	for i, sourcePodIsolation := range podIsolations {
-		for j, targetPodIsolation := range podIsolations {
-			if i == j {
-				// Ignore traffic to itself
+		if isDone(done) {
+			return false
+		}
+		for _, j := range shard.targets {
+			if i == j || samePod(sourcePodIsolation.Pod, podIsolations[j].Pod) {
+				// Ignore traffic to itself, including when the pod is declared twice
 				continue
 			}
-			allowedRoute := analyzer.allowedRouteAnalyzer.Analyze(sourcePodIsolation, targetPodIsolation, namespaces)
+			if excluded(i, j) {
+				shard.excludedPairs++
+				continue
+			}
+			allowedRoute := analyzer.allowedRouteAnalyzer.Analyze(sourcePodIsolation, podIsolations[j], index)
 			if allowedRoute != nil {
-				allowedRoutes = append(allowedRoutes, allowedRoute)
+				allowedRoute.Warnings = analyzer.mergeWarnings(enforcementWarnings[i], enforcementWarnings[j])
+				allowedRoute.SameNode = sameNode(sourcePodIsolation.Pod, podIsolations[j].Pod)
+				shard.routes = append(shard.routes, indexedRoute{source: i, target: j, allowedRoute: allowedRoute})
 			}
 		}
 	}
-	return allowedRoutes
+	return true
+}
+
+func hasDuplicatePods(pods []*corev1.Pod) bool {
+	declared := make(map[k8stypes.NamespacedName]bool, len(pods))
+	for _, pod := range pods {
+		name := k8stypes.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+		if declared[name] {
+			return true
+		}
+		declared[name] = true
+	}
+	return false
+}
+
+func samePod(pod1 *corev1.Pod, pod2 *corev1.Pod) bool {
+	return pod1.Name == pod2.Name && pod1.Namespace == pod2.Namespace
+}
+
+// sameNode is nil when either pod is not scheduled yet, or its node is unknown
+func sameNode(pod1 *corev1.Pod, pod2 *corev1.Pod) *bool {
+	if pod1.Spec.NodeName == "" || pod2.Spec.NodeName == "" {
+		return nil
+	}
+	same := pod1.Spec.NodeName == pod2.Spec.NodeName
+	return &same
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (shard *routeShard) toShard(podIsolations []*shared.PodIsolation, total int) Shard {
+	targetIsolations := make([]*types.PodIsolation, 0, len(shard.targets))
+	for _, j := range shard.targets {
+		targetIsolations = append(targetIsolations, podIsolations[j].ToPodIsolation())
+	}
+	allowedRoutes := make([]*types.AllowedRoute, 0, len(shard.routes))
+	for _, route := range shard.routes {
+		allowedRoutes = append(allowedRoutes, route.allowedRoute)
+	}
+	return Shard{
+		Namespace:     shard.namespace,
+		PodIsolations: targetIsolations,
+		AllowedRoutes: allowedRoutes,
+		Total:         total,
+	}
+}
+
+func workerCount(shardCount int) int {
+	workers := runtime.GOMAXPROCS(0)
+	if shardCount < workers {
+		return shardCount
+	}
+	return workers
+}
+
+func (analyzer analyzerImpl) mergeWarnings(sourceWarnings []string, targetWarnings []string) []string {
+	warningsSet := make(map[string]bool)
+	for _, warning := range sourceWarnings {
+		warningsSet[warning] = true
+	}
+	for _, warning := range targetWarnings {
+		warningsSet[warning] = true
+	}
+	warnings := make([]string, 0, len(warningsSet))
+	for warning := range warningsSet {
+		warnings = append(warnings, warning)
+	}
+	sort.Strings(warnings)
+	return warnings
 }
 
 func (analyzer analyzerImpl) toPodIsolations(podIsolations []*shared.PodIsolation) []*types.PodIsolation {
@@ -2,14 +2,20 @@ package traffic
 
 import (
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"karto/analyzer/traffic/allowedroute"
+	"karto/analyzer/traffic/enforcement"
 	"karto/analyzer/traffic/podisolation"
 	"karto/analyzer/traffic/shared"
+	"karto/config"
 	"karto/testutils"
 	"karto/types"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -20,10 +26,13 @@ func TestAnalyze(t *testing.T) {
 	type mocks struct {
 		podIsolation []mockPodIsolationAnalyzerCall
 		allowedRoute []mockAllowedRouteAnalyzerCall
+		enforcement  []mockEnforcementAnalyzerCall
 	}
 	k8sNamespace := testutils.NewNamespaceBuilder().WithName("ns").Build()
-	k8sPod1 := testutils.NewPodBuilder().WithName("pod1").WithNamespace("ns").Build()
-	k8sPod2 := testutils.NewPodBuilder().WithName("pod2").WithNamespace("ns").Build()
+	k8sNode := testutils.NewNodeBuilder().WithName("node").Build()
+	k8sDaemonSet := testutils.NewDaemonSetBuilder().WithName("ds").Build()
+	k8sPod1 := testutils.NewPodBuilder().WithName("pod1").WithNamespace("ns").WithNodeName("node").Build()
+	k8sPod2 := testutils.NewPodBuilder().WithName("pod2").WithNamespace("ns").WithNodeName("node").Build()
 	k8sNetworkPolicy1 := testutils.NewNetworkPolicyBuilder().WithName("netPol1").WithNamespace("ns1").
 		WithLabel("k", "v1").Build()
 	k8sNetworkPolicy2 := testutils.NewNetworkPolicyBuilder().WithName("netPol2").WithNamespace("ns2").
@@ -51,14 +60,62 @@ func TestAnalyze(t *testing.T) {
 		},
 		Ports: []int32{80, 443},
 	}
+	sameNode := true
+	expectedAllowedRoute := &types.AllowedRoute{
+		SourcePod:       allowedRoute.SourcePod,
+		EgressPolicies:  allowedRoute.EgressPolicies,
+		TargetPod:       allowedRoute.TargetPod,
+		IngressPolicies: allowedRoute.IngressPolicies,
+		Ports:           allowedRoute.Ports,
+		Warnings:        []string{"warning1", "warning2"},
+		SameNode:        &sameNode,
+	}
+	tcp := corev1.ProtocolTCP
+	sctp := corev1.ProtocolSCTP
+	port80 := intstr.FromInt(80)
+	port90 := intstr.FromInt(90)
+	endPort100 := int32(100)
+	k8sNetworkPolicyWithPorts := testutils.NewNetworkPolicyBuilder().WithName("netPol3").WithNamespace("ns").
+		WithTypes("Ingress").
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: &port80},
+				{Protocol: &sctp, Port: &port80},
+				{Protocol: &tcp, Port: &port90, EndPort: &endPort100},
+			},
+		}).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &sctp, Port: &port90},
+			},
+		}).Build()
+	k8sNetworkPolicyWithSupportedPorts := testutils.NewNetworkPolicyBuilder().WithName("netPol3").WithNamespace("ns").
+		WithTypes("Ingress").
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: &port80},
+				{Protocol: &tcp, Port: &port90},
+			},
+		}).Build()
+	k8sNetworkPolicyWithSupportedPorts.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{}
+	k8sAgentDaemonSet := testutils.NewDaemonSetBuilder().WithName("agent").WithNamespace("agents").WithUID("ds-uid").
+		Build()
+	k8sAgentPod1 := testutils.NewPodBuilder().WithName("agent1").WithNamespace("agents").WithOwnerUID("ds-uid").Build()
+	k8sAgentPod2 := testutils.NewPodBuilder().WithName("agent2").WithNamespace("agents").WithOwnerUID("ds-uid").Build()
+	agentIsolation1 := &shared.PodIsolation{Pod: k8sAgentPod1}
+	agentIsolation2 := &shared.PodIsolation{Pod: k8sAgentPod2}
+	noPolicies := make([]*networkingv1.NetworkPolicy, 0)
+	index := shared.NewLabelIndex([]*corev1.Pod{k8sPod1, k8sPod2}, []*corev1.Namespace{k8sNamespace})
+	agentIndex := shared.NewLabelIndex([]*corev1.Pod{k8sAgentPod1, k8sAgentPod2, k8sPod1}, []*corev1.Namespace{})
 	tests := []struct {
 		name                   string
 		mocks                  mocks
 		args                   args
+		exclusions             *config.ExclusionConfig
 		expectedAnalysisResult AnalysisResult
 	}{
 		{
-			name: "delegates to pod isolation and allowed route analyzers",
+			name: "delegates to pod isolation, allowed route and enforcement analyzers",
 			mocks: mocks{
 				podIsolation: []mockPodIsolationAnalyzerCall{
 					{
@@ -77,7 +134,7 @@ func TestAnalyze(t *testing.T) {
 						args: mockAllowedRouteAnalyzerCallArgs{
 							sourcePodIsolation: podIsolation1,
 							targetPodIsolation: podIsolation2,
-							namespaces:         []*corev1.Namespace{k8sNamespace},
+							index:              index,
 						},
 						returnValue: allowedRoute,
 					},
@@ -85,17 +142,31 @@ func TestAnalyze(t *testing.T) {
 						args: mockAllowedRouteAnalyzerCallArgs{
 							sourcePodIsolation: podIsolation2,
 							targetPodIsolation: podIsolation1,
-							namespaces:         []*corev1.Namespace{k8sNamespace},
+							index:              index,
 						},
 						returnValue: nil,
 					},
 				},
+				enforcement: []mockEnforcementAnalyzerCall{
+					{
+						args: mockEnforcementAnalyzerCallArgs{pod: k8sPod1, nodes: []*corev1.Node{k8sNode},
+							daemonSets: []*appsv1.DaemonSet{k8sDaemonSet}},
+						returnValue: []string{"warning2", "warning1"},
+					},
+					{
+						args: mockEnforcementAnalyzerCallArgs{pod: k8sPod2, nodes: []*corev1.Node{k8sNode},
+							daemonSets: []*appsv1.DaemonSet{k8sDaemonSet}},
+						returnValue: []string{"warning1"},
+					},
+				},
 			},
 			args: args{
 				clusterState: ClusterState{
 					Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
 					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicy1, k8sNetworkPolicy2},
 					Namespaces:      []*corev1.Namespace{k8sNamespace},
+					Nodes:           []*corev1.Node{k8sNode},
+					DaemonSets:      []*appsv1.DaemonSet{k8sDaemonSet},
 				},
 			},
 			expectedAnalysisResult: AnalysisResult{
@@ -103,7 +174,136 @@ func TestAnalyze(t *testing.T) {
 					{Pod: podRef1, IsIngressIsolated: false, IsEgressIsolated: false},
 					{Pod: podRef2, IsIngressIsolated: false, IsEgressIsolated: false},
 				},
-				AllowedRoutes: []*types.AllowedRoute{allowedRoute},
+				AllowedRoutes: []*types.AllowedRoute{expectedAllowedRoute},
+			},
+		},
+		{
+			name: "ports not supported by the cluster are removed from network policies",
+			mocks: mocks{
+				podIsolation: []mockPodIsolationAnalyzerCall{
+					{
+						args: mockPodIsolationAnalyzerCallArgs{pod: k8sPod1,
+							networkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicyWithSupportedPorts}},
+						returnValue: podIsolation1,
+					},
+				},
+				allowedRoute: []mockAllowedRouteAnalyzerCall{},
+				enforcement: []mockEnforcementAnalyzerCall{
+					{
+						args: mockEnforcementAnalyzerCallArgs{pod: k8sPod1, nodes: []*corev1.Node{},
+							daemonSets: []*appsv1.DaemonSet{}},
+						returnValue: []string{},
+					},
+				},
+			},
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sPod1},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{k8sNetworkPolicyWithPorts},
+					Namespaces:      []*corev1.Namespace{k8sNamespace},
+					Nodes:           []*corev1.Node{},
+					DaemonSets:      []*appsv1.DaemonSet{},
+					Capabilities:    types.ClusterCapabilities{SCTP: false, EndPort: false},
+				},
+			},
+			expectedAnalysisResult: AnalysisResult{
+				Pods: []*types.PodIsolation{
+					{Pod: podRef1, IsIngressIsolated: false, IsEgressIsolated: false},
+				},
+				AllowedRoutes: []*types.AllowedRoute{},
+			},
+		},
+		{
+			name: "routes between pods of a same DaemonSet are not computed",
+			mocks: mocks{
+				podIsolation: []mockPodIsolationAnalyzerCall{
+					{
+						args:        mockPodIsolationAnalyzerCallArgs{pod: k8sAgentPod1, networkPolicies: noPolicies},
+						returnValue: agentIsolation1,
+					},
+					{
+						args:        mockPodIsolationAnalyzerCallArgs{pod: k8sAgentPod2, networkPolicies: noPolicies},
+						returnValue: agentIsolation2,
+					},
+					{
+						args:        mockPodIsolationAnalyzerCallArgs{pod: k8sPod1, networkPolicies: noPolicies},
+						returnValue: podIsolation1,
+					},
+				},
+				allowedRoute: []mockAllowedRouteAnalyzerCall{
+					{args: mockAllowedRouteAnalyzerCallArgs{sourcePodIsolation: agentIsolation1,
+						targetPodIsolation: podIsolation1, index: agentIndex}},
+					{args: mockAllowedRouteAnalyzerCallArgs{sourcePodIsolation: agentIsolation2,
+						targetPodIsolation: podIsolation1, index: agentIndex}},
+					{args: mockAllowedRouteAnalyzerCallArgs{sourcePodIsolation: podIsolation1,
+						targetPodIsolation: agentIsolation1, index: agentIndex}},
+					{args: mockAllowedRouteAnalyzerCallArgs{sourcePodIsolation: podIsolation1,
+						targetPodIsolation: agentIsolation2, index: agentIndex}},
+				},
+				enforcement: []mockEnforcementAnalyzerCall{
+					{args: mockEnforcementAnalyzerCallArgs{pod: k8sAgentPod1, nodes: []*corev1.Node{},
+						daemonSets: []*appsv1.DaemonSet{k8sAgentDaemonSet}}},
+					{args: mockEnforcementAnalyzerCallArgs{pod: k8sAgentPod2, nodes: []*corev1.Node{},
+						daemonSets: []*appsv1.DaemonSet{k8sAgentDaemonSet}}},
+					{args: mockEnforcementAnalyzerCallArgs{pod: k8sPod1, nodes: []*corev1.Node{},
+						daemonSets: []*appsv1.DaemonSet{k8sAgentDaemonSet}}},
+				},
+			},
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sAgentPod1, k8sAgentPod2, k8sPod1},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{},
+					Namespaces:      []*corev1.Namespace{},
+					Nodes:           []*corev1.Node{},
+					DaemonSets:      []*appsv1.DaemonSet{k8sAgentDaemonSet},
+				},
+			},
+			exclusions: &config.ExclusionConfig{SameDaemonSet: true},
+			expectedAnalysisResult: AnalysisResult{
+				Pods: []*types.PodIsolation{
+					{Pod: types.PodRef{Name: "agent1", Namespace: "agents"}},
+					{Pod: types.PodRef{Name: "agent2", Namespace: "agents"}},
+					{Pod: podRef1},
+				},
+				AllowedRoutes: []*types.AllowedRoute{},
+				ExcludedPairs: 2,
+			},
+		},
+		{
+			name: "routes within an excluded namespace are not computed",
+			mocks: mocks{
+				podIsolation: []mockPodIsolationAnalyzerCall{
+					{
+						args:        mockPodIsolationAnalyzerCallArgs{pod: k8sPod1, networkPolicies: noPolicies},
+						returnValue: podIsolation1,
+					},
+					{
+						args:        mockPodIsolationAnalyzerCallArgs{pod: k8sPod2, networkPolicies: noPolicies},
+						returnValue: podIsolation2,
+					},
+				},
+				allowedRoute: []mockAllowedRouteAnalyzerCall{},
+				enforcement: []mockEnforcementAnalyzerCall{
+					{args: mockEnforcementAnalyzerCallArgs{pod: k8sPod1, nodes: []*corev1.Node{},
+						daemonSets: []*appsv1.DaemonSet{}}},
+					{args: mockEnforcementAnalyzerCallArgs{pod: k8sPod2, nodes: []*corev1.Node{},
+						daemonSets: []*appsv1.DaemonSet{}}},
+				},
+			},
+			args: args{
+				clusterState: ClusterState{
+					Pods:            []*corev1.Pod{k8sPod1, k8sPod2},
+					NetworkPolicies: []*networkingv1.NetworkPolicy{},
+					Namespaces:      []*corev1.Namespace{},
+					Nodes:           []*corev1.Node{},
+					DaemonSets:      []*appsv1.DaemonSet{},
+				},
+			},
+			exclusions: &config.ExclusionConfig{Namespaces: []string{"ns"}},
+			expectedAnalysisResult: AnalysisResult{
+				Pods:          []*types.PodIsolation{{Pod: podRef1}, {Pod: podRef2}},
+				AllowedRoutes: []*types.AllowedRoute{},
+				ExcludedPairs: 2,
 			},
 		},
 	}
@@ -111,9 +311,11 @@ func TestAnalyze(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			podIsolationAnalyzer := createMockPodIsolationAnalyzer(t, tt.mocks.podIsolation)
 			allowedRouteAnalyzer := createMockAllowedRouteAnalyzer(t, tt.mocks.allowedRoute)
-			analyzer := NewAnalyzer(podIsolationAnalyzer, allowedRouteAnalyzer)
+			enforcementAnalyzer := createMockEnforcementAnalyzer(t, tt.mocks.enforcement)
+			analyzer := NewAnalyzer(podIsolationAnalyzer, allowedRouteAnalyzer, enforcementAnalyzer, tt.exclusions)
 			analysisResult := analyzer.Analyze(tt.args.clusterState)
-			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult); diff != "" {
+			if diff := cmp.Diff(tt.expectedAnalysisResult, analysisResult,
+				cmpopts.IgnoreFields(AnalysisResult{}, "IsolationDuration")); diff != "" {
 				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -155,10 +357,85 @@ func createMockPodIsolationAnalyzer(t *testing.T, calls []mockPodIsolationAnalyz
 	}
 }
 
+func TestAnalyzeShards(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build()
+	db := testutils.NewPodBuilder().WithName("db").WithNamespace("data").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").Build()
+	analyzer := NewAnalyzer(podisolation.NewAnalyzer(), allowedroute.NewAnalyzer(), enforcement.NewAnalyzer(), nil)
+	var mutex sync.Mutex
+	shards := make(map[string][]string)
+	analysisResult := analyzer.Analyze(ClusterState{
+		Pods: []*corev1.Pod{front, db, api},
+		OnShardComplete: func(shard Shard) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			shards[shard.Namespace] = routeNames(shard.AllowedRoutes)
+			for _, podIsolation := range shard.PodIsolations {
+				if podIsolation.Pod.Namespace != shard.Namespace {
+					t.Errorf("Shard %s holds the isolation of pod %s", shard.Namespace, podIsolation.Pod)
+				}
+			}
+			if shard.Total != 2 {
+				t.Errorf("Shard total = %d, expected 2", shard.Total)
+			}
+		},
+	})
+	expectedShards := map[string][]string{
+		"shop": {"db->front", "db->api", "api->front", "front->api"},
+		"data": {"front->db", "api->db"},
+	}
+	if diff := cmp.Diff(expectedShards, shards, cmpopts.SortSlices(func(x, y string) bool { return x < y })); diff != "" {
+		t.Errorf("Analyze() published shards mismatch (-want +got):\n%s", diff)
+	}
+	expectedRoutes := []string{"front->db", "front->api", "db->front", "db->api", "api->front", "api->db"}
+	if diff := cmp.Diff(expectedRoutes, routeNames(analysisResult.AllowedRoutes)); diff != "" {
+		t.Errorf("Analyze() routes order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalyzeCancelled(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build()
+	db := testutils.NewPodBuilder().WithName("db").WithNamespace("data").Build()
+	analyzer := NewAnalyzer(podisolation.NewAnalyzer(), allowedroute.NewAnalyzer(), enforcement.NewAnalyzer(), nil)
+	done := make(chan struct{})
+	close(done)
+	analysisResult := analyzer.Analyze(ClusterState{
+		Pods: []*corev1.Pod{front, db},
+		OnShardComplete: func(shard Shard) {
+			t.Errorf("Shard %s completed after Done was closed", shard.Namespace)
+		},
+		Done: done,
+	})
+	if diff := cmp.Diff(AnalysisResult{Cancelled: true}, analysisResult); diff != "" {
+		t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalyzeMergesRoutesOfPodsDeclaredTwice(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build()
+	db := testutils.NewPodBuilder().WithName("db").WithNamespace("shop").Build()
+	analyzer := NewAnalyzer(podisolation.NewAnalyzer(), allowedroute.NewAnalyzer(), enforcement.NewAnalyzer(), nil)
+	analysisResult := analyzer.Analyze(ClusterState{Pods: []*corev1.Pod{front, db, front.DeepCopy()}})
+	if diff := cmp.Diff([]string{"front->db", "db->front"}, routeNames(analysisResult.AllowedRoutes)); diff != "" {
+		t.Errorf("Analyze() routes mismatch (-want +got):\n%s", diff)
+	}
+	if analysisResult.MergedRoutes != 2 {
+		t.Errorf("Analyze() merged routes = %d, expected 2", analysisResult.MergedRoutes)
+	}
+}
+
+func routeNames(allowedRoutes []*types.AllowedRoute) []string {
+	names := make([]string, 0)
+	for _, allowedRoute := range allowedRoutes {
+		names = append(names, allowedRoute.SourcePod.Name+"->"+allowedRoute.TargetPod.Name)
+	}
+	return names
+}
+
 type mockAllowedRouteAnalyzerCallArgs struct {
 	sourcePodIsolation *shared.PodIsolation
 	targetPodIsolation *shared.PodIsolation
-	namespaces         []*corev1.Namespace
+	index              *shared.LabelIndex
 }
 
 type mockAllowedRouteAnalyzerCall struct {
@@ -172,17 +449,16 @@ type mockAllowedRouteAnalyzer struct {
 }
 
 func (mock mockAllowedRouteAnalyzer) Analyze(sourcePodIsolation *shared.PodIsolation,
-	targetPodIsolation *shared.PodIsolation, namespaces []*corev1.Namespace) *types.AllowedRoute {
+	targetPodIsolation *shared.PodIsolation, index *shared.LabelIndex) *types.AllowedRoute {
 	for _, call := range mock.calls {
 		if reflect.DeepEqual(call.args.sourcePodIsolation, sourcePodIsolation) &&
 			reflect.DeepEqual(call.args.targetPodIsolation, targetPodIsolation) &&
-			reflect.DeepEqual(call.args.namespaces, namespaces) {
+			reflect.DeepEqual(call.args.index, index) {
 			return call.returnValue
 		}
 	}
-	mock.t.Fatalf("mockAllowedRouteAnalyzer was called with unexpected arguments: \n"+
-		"\tsourcePodIsolation: %s\n\ttargetPodIsolation: %s\n\tnamespaces: %s\n", sourcePodIsolation,
-		targetPodIsolation, namespaces)
+	mock.t.Errorf("mockAllowedRouteAnalyzer was called with unexpected arguments: \n"+
+		"\tsourcePodIsolation: %s\n\ttargetPodIsolation: %s\n", sourcePodIsolation, targetPodIsolation)
 	return nil
 }
 
@@ -192,3 +468,40 @@ func createMockAllowedRouteAnalyzer(t *testing.T, calls []mockAllowedRouteAnalyz
 		calls: calls,
 	}
 }
+
+type mockEnforcementAnalyzerCallArgs struct {
+	pod        *corev1.Pod
+	nodes      []*corev1.Node
+	daemonSets []*appsv1.DaemonSet
+}
+
+type mockEnforcementAnalyzerCall struct {
+	args        mockEnforcementAnalyzerCallArgs
+	returnValue []string
+}
+
+type mockEnforcementAnalyzer struct {
+	t     *testing.T
+	calls []mockEnforcementAnalyzerCall
+}
+
+func (mock mockEnforcementAnalyzer) Analyze(pod *corev1.Pod, nodes []*corev1.Node,
+	daemonSets []*appsv1.DaemonSet) []string {
+	for _, call := range mock.calls {
+		if reflect.DeepEqual(call.args.pod, pod) &&
+			reflect.DeepEqual(call.args.nodes, nodes) &&
+			reflect.DeepEqual(call.args.daemonSets, daemonSets) {
+			return call.returnValue
+		}
+	}
+	mock.t.Fatalf("mockEnforcementAnalyzer was called with unexpected arguments: \n\tpod: %s\n"+
+		"\tnodes: %s\n\tdaemonSets: %s\n", pod, nodes, daemonSets)
+	return nil
+}
+
+func createMockEnforcementAnalyzer(t *testing.T, calls []mockEnforcementAnalyzerCall) enforcement.Analyzer {
+	return mockEnforcementAnalyzer{
+		t:     t,
+		calls: calls,
+	}
+}
@@ -0,0 +1,55 @@
+package traffic
+
+import (
+	"fmt"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/traffic/allowedroute"
+	"karto/analyzer/traffic/enforcement"
+	"karto/analyzer/traffic/podisolation"
+	"karto/testutils"
+	"testing"
+)
+
+// denseClusterState has every pod selected by several policies, each of them allowing traffic from several peers of
+// all namespaces
+func denseClusterState(namespaceCount int, podsPerNamespace int, policiesPerNamespace int) ClusterState {
+	clusterState := ClusterState{}
+	for i := 0; i < namespaceCount; i++ {
+		namespace := fmt.Sprintf("ns-%d", i)
+		clusterState.Namespaces = append(clusterState.Namespaces, testutils.NewNamespaceBuilder().
+			WithName(namespace).WithLabel("team", fmt.Sprintf("team-%d", i%3)).Build())
+		for j := 0; j < podsPerNamespace; j++ {
+			clusterState.Pods = append(clusterState.Pods, testutils.NewPodBuilder().
+				WithName(fmt.Sprintf("pod-%d", j)).WithNamespace(namespace).
+				WithLabel("app", fmt.Sprintf("app-%d", j%policiesPerNamespace)).
+				WithLabel("tier", fmt.Sprintf("tier-%d", j%4)).Build())
+		}
+		for j := 0; j < policiesPerNamespace; j++ {
+			ingressRule := networkingv1.NetworkPolicyIngressRule{}
+			for k := 0; k < 4; k++ {
+				ingressRule.From = append(ingressRule.From, networkingv1.NetworkPolicyPeer{
+					PodSelector: testutils.NewLabelSelectorBuilder().
+						WithMatchLabel("tier", fmt.Sprintf("tier-%d", k)).Build(),
+					NamespaceSelector: testutils.NewLabelSelectorBuilder().
+						WithMatchLabel("team", fmt.Sprintf("team-%d", (j+k)%3)).Build(),
+				})
+			}
+			clusterState.NetworkPolicies = append(clusterState.NetworkPolicies, testutils.NewNetworkPolicyBuilder().
+				WithName(fmt.Sprintf("policy-%d", j)).WithNamespace(namespace).
+				WithPodSelector(testutils.NewLabelSelectorBuilder().
+					WithMatchLabel("app", fmt.Sprintf("app-%d", j)).Build()).
+				WithTypes(networkingv1.PolicyTypeIngress).WithIngressRule(ingressRule).Build())
+		}
+	}
+	return clusterState
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	analyzer := NewAnalyzer(podisolation.NewAnalyzer(), allowedroute.NewAnalyzer(), enforcement.NewAnalyzer(), nil)
+	clusterState := denseClusterState(10, 40, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		analyzer.Analyze(clusterState)
+	}
+}
@@ -0,0 +1,78 @@
+package traffic
+
+import (
+	"sync"
+)
+
+// routeBuffers keeps the buffers of routes of the last analysis, so that analyzing a cluster of a stable size every
+// few seconds reuses them instead of growing new ones. Unlike those of a sync.Pool, they survive garbage collections.
+// The routes themselves are part of the analysis result, which is still served while the next one is computed, so
+// only the buffers referencing them are reused.
+type routeBuffers struct {
+	mutex sync.Mutex
+	free  [][]indexedRoute
+	// lengths holds the number of routes of each namespace in the last analysis
+	lengths map[string]int
+}
+
+func newRouteBuffers() *routeBuffers {
+	return &routeBuffers{lengths: make(map[string]int)}
+}
+
+// forNamespace returns an empty buffer fitting the routes of the namespace in the last analysis
+func (buffers *routeBuffers) forNamespace(namespace string) []indexedRoute {
+	buffers.mutex.Lock()
+	defer buffers.mutex.Unlock()
+	return buffers.take(buffers.lengths[namespace])
+}
+
+// forAll returns an empty buffer fitting the given number of routes
+func (buffers *routeBuffers) forAll(length int) []indexedRoute {
+	buffers.mutex.Lock()
+	defer buffers.mutex.Unlock()
+	return buffers.take(length)
+}
+
+// take removes from the free buffers the smallest one with the given capacity, or makes a new one
+func (buffers *routeBuffers) take(capacity int) []indexedRoute {
+	best := -1
+	for i, buffer := range buffers.free {
+		if cap(buffer) >= capacity && (best < 0 || cap(buffer) < cap(buffers.free[best])) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return make([]indexedRoute, 0, capacity)
+	}
+	buffer := buffers.free[best]
+	last := len(buffers.free) - 1
+	buffers.free[best] = buffers.free[last]
+	buffers.free[last] = nil
+	buffers.free = buffers.free[:last]
+	return buffer[:0]
+}
+
+// release takes back the buffers of an analysis, once its routes were copied out of them. They replace the buffers
+// left from previous analyses, so that the retained memory follows the size of the cluster.
+func (buffers *routeBuffers) release(shards []*routeShard, merged []indexedRoute) {
+	released := make([][]indexedRoute, 0, len(shards)+1)
+	lengths := make(map[string]int, len(shards))
+	for _, shard := range shards {
+		lengths[shard.namespace] = len(shard.routes)
+		released = append(released, clearRoutes(shard.routes))
+		shard.routes = nil
+	}
+	released = append(released, clearRoutes(merged))
+	buffers.mutex.Lock()
+	defer buffers.mutex.Unlock()
+	buffers.free = released
+	buffers.lengths = lengths
+}
+
+// clearRoutes drops the references to the routes, which must not be kept alive by a free buffer
+func clearRoutes(routes []indexedRoute) []indexedRoute {
+	for i := range routes {
+		routes[i] = indexedRoute{}
+	}
+	return routes[:0]
+}
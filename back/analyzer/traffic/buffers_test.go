@@ -0,0 +1,33 @@
+package traffic
+
+import (
+	"karto/types"
+	"testing"
+)
+
+func TestRouteBuffersReuse(t *testing.T) {
+	buffers := newRouteBuffers()
+	route := indexedRoute{allowedRoute: &types.AllowedRoute{}}
+	shop := &routeShard{namespace: "shop", routes: buffers.forNamespace("shop")}
+	shop.routes = append(shop.routes, route, route, route)
+	data := &routeShard{namespace: "data", routes: buffers.forNamespace("data")}
+	data.routes = append(data.routes, route)
+	merged := append(buffers.forAll(4), route, route, route, route)
+	buffers.release([]*routeShard{shop, data}, merged)
+	reusedMerged := buffers.forAll(4)
+	if cap(reusedMerged) < 4 || &reusedMerged[:1][0] != &merged[0] {
+		t.Errorf("forAll() did not reuse the released buffer of merged routes")
+	}
+	reusedShop := buffers.forNamespace("shop")
+	if cap(reusedShop) < 3 || len(reusedShop) != 0 {
+		t.Errorf("forNamespace() = %d/%d, expected an empty buffer fitting 3 routes", len(reusedShop),
+			cap(reusedShop))
+	}
+	for _, reused := range [][]indexedRoute{reusedMerged, reusedShop} {
+		for _, route := range reused[:cap(reused)] {
+			if route.allowedRoute != nil {
+				t.Errorf("Released buffer still references a route")
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package enforcement
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+)
+
+const (
+	osLabel                = "kubernetes.io/os"
+	windowsOS              = "windows"
+	awsNodeDaemonSet       = "aws-node"
+	awsPolicyAgentName     = "aws-network-policy-agent"
+	windowsNodeWarning     = "pod runs on Windows node %s where network policies may not be enforced"
+	unsupportedCNIsWarning = "network policies are not enforced by the detected CNI (%s)"
+)
+
+var enforcingCNIDaemonSets = map[string]bool{
+	"calico-node":           true,
+	"canal":                 true,
+	"cilium":                true,
+	"weave-net":             true,
+	"antrea-agent":          true,
+	"kube-router":           true,
+	"kube-ovn-cni":          true,
+	"ovnkube-node":          true,
+	"romana-agent":          true,
+	"kube-network-policies": true,
+}
+
+var nonEnforcingCNIsByDaemonSet = map[string]string{
+	"kube-flannel-ds": "flannel",
+	"kube-flannel":    "flannel",
+	awsNodeDaemonSet:  "amazon-vpc-cni",
+}
+
+type Analyzer interface {
+	Analyze(pod *corev1.Pod, nodes []*corev1.Node, daemonSets []*appsv1.DaemonSet) []string
+}
+
+type analyzerImpl struct{}
+
+func NewAnalyzer() Analyzer {
+	return analyzerImpl{}
+}
+
+func (analyzer analyzerImpl) Analyze(pod *corev1.Pod, nodes []*corev1.Node,
+	daemonSets []*appsv1.DaemonSet) []string {
+	warnings := make([]string, 0)
+	node := analyzer.nodeOf(pod, nodes)
+	if node != nil && analyzer.isWindowsNode(node) {
+		warnings = append(warnings, fmt.Sprintf(windowsNodeWarning, node.Name))
+	}
+	nonEnforcingCNIs := analyzer.nonEnforcingCNIs(daemonSets)
+	if len(nonEnforcingCNIs) > 0 {
+		warnings = append(warnings, fmt.Sprintf(unsupportedCNIsWarning, strings.Join(nonEnforcingCNIs, ", ")))
+	}
+	return warnings
+}
+
+func (analyzer analyzerImpl) nodeOf(pod *corev1.Pod, nodes []*corev1.Node) *corev1.Node {
+	if pod.Spec.NodeName == "" {
+		return nil
+	}
+	for _, node := range nodes {
+		if node.Name == pod.Spec.NodeName {
+			return node
+		}
+	}
+	return nil
+}
+
+func (analyzer analyzerImpl) isWindowsNode(node *corev1.Node) bool {
+	return node.Labels[osLabel] == windowsOS || node.Status.NodeInfo.OperatingSystem == windowsOS
+}
+
+func (analyzer analyzerImpl) nonEnforcingCNIs(daemonSets []*appsv1.DaemonSet) []string {
+	nonEnforcingCNIsSet := make(map[string]bool)
+	for _, daemonSet := range daemonSets {
+		if enforcingCNIDaemonSets[daemonSet.Name] {
+			return nil
+		}
+		if daemonSet.Name == awsNodeDaemonSet && analyzer.hasContainer(daemonSet, awsPolicyAgentName) {
+			return nil
+		}
+		if cni, ok := nonEnforcingCNIsByDaemonSet[daemonSet.Name]; ok {
+			nonEnforcingCNIsSet[cni] = true
+		}
+	}
+	nonEnforcingCNIs := make([]string, 0, len(nonEnforcingCNIsSet))
+	for cni := range nonEnforcingCNIsSet {
+		nonEnforcingCNIs = append(nonEnforcingCNIs, cni)
+	}
+	sort.Strings(nonEnforcingCNIs)
+	return nonEnforcingCNIs
+}
+
+func (analyzer analyzerImpl) hasContainer(daemonSet *appsv1.DaemonSet, containerName string) bool {
+	for _, container := range daemonSet.Spec.Template.Spec.Containers {
+		if container.Name == containerName {
+			return true
+		}
+	}
+	return false
+}
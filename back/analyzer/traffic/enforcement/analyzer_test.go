@@ -0,0 +1,119 @@
+package enforcement
+
+import (
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"karto/testutils"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	type args struct {
+		pod        *corev1.Pod
+		nodes      []*corev1.Node
+		daemonSets []*appsv1.DaemonSet
+	}
+	tests := []struct {
+		name             string
+		args             args
+		expectedWarnings []string
+	}{
+		{
+			name: "no warning for a pod on a linux node with an enforcing CNI",
+			args: args{
+				pod: testutils.NewPodBuilder().WithName("pod1").WithNodeName("node1").Build(),
+				nodes: []*corev1.Node{
+					testutils.NewNodeBuilder().WithName("node1").WithLabel("kubernetes.io/os", "linux").Build(),
+				},
+				daemonSets: []*appsv1.DaemonSet{
+					testutils.NewDaemonSetBuilder().WithName("calico-node").WithNamespace("kube-system").Build(),
+				},
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			name: "no warning when no CNI is recognized",
+			args: args{
+				pod:        testutils.NewPodBuilder().WithName("pod1").Build(),
+				nodes:      []*corev1.Node{},
+				daemonSets: []*appsv1.DaemonSet{testutils.NewDaemonSetBuilder().WithName("other").Build()},
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			name: "a pod on a windows node is flagged",
+			args: args{
+				pod: testutils.NewPodBuilder().WithName("pod1").WithNodeName("node2").Build(),
+				nodes: []*corev1.Node{
+					testutils.NewNodeBuilder().WithName("node1").WithLabel("kubernetes.io/os", "linux").Build(),
+					testutils.NewNodeBuilder().WithName("node2").WithLabel("kubernetes.io/os", "windows").Build(),
+				},
+				daemonSets: []*appsv1.DaemonSet{},
+			},
+			expectedWarnings: []string{
+				"pod runs on Windows node node2 where network policies may not be enforced",
+			},
+		},
+		{
+			name: "a CNI known not to enforce network policies is flagged",
+			args: args{
+				pod:   testutils.NewPodBuilder().WithName("pod1").Build(),
+				nodes: []*corev1.Node{},
+				daemonSets: []*appsv1.DaemonSet{
+					testutils.NewDaemonSetBuilder().WithName("kube-flannel-ds").WithNamespace("kube-system").Build(),
+				},
+			},
+			expectedWarnings: []string{
+				"network policies are not enforced by the detected CNI (flannel)",
+			},
+		},
+		{
+			name: "a non enforcing CNI is not flagged when combined with an enforcing one",
+			args: args{
+				pod:   testutils.NewPodBuilder().WithName("pod1").Build(),
+				nodes: []*corev1.Node{},
+				daemonSets: []*appsv1.DaemonSet{
+					testutils.NewDaemonSetBuilder().WithName("kube-flannel-ds").WithNamespace("kube-system").Build(),
+					testutils.NewDaemonSetBuilder().WithName("canal").WithNamespace("kube-system").Build(),
+				},
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			name: "amazon VPC CNI is only flagged when its network policy agent is not deployed",
+			args: args{
+				pod:   testutils.NewPodBuilder().WithName("pod1").Build(),
+				nodes: []*corev1.Node{},
+				daemonSets: []*appsv1.DaemonSet{
+					testutils.NewDaemonSetBuilder().WithName("aws-node").WithNamespace("kube-system").
+						WithContainer("aws-node").Build(),
+				},
+			},
+			expectedWarnings: []string{
+				"network policies are not enforced by the detected CNI (amazon-vpc-cni)",
+			},
+		},
+		{
+			name: "amazon VPC CNI is not flagged when its network policy agent is deployed",
+			args: args{
+				pod:   testutils.NewPodBuilder().WithName("pod1").Build(),
+				nodes: []*corev1.Node{},
+				daemonSets: []*appsv1.DaemonSet{
+					testutils.NewDaemonSetBuilder().WithName("aws-node").WithNamespace("kube-system").
+						WithContainer("aws-node").WithContainer("aws-network-policy-agent").Build(),
+				},
+			},
+			expectedWarnings: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			warnings := analyzer.Analyze(tt.args.pod, tt.args.nodes, tt.args.daemonSets)
+			if diff := cmp.Diff(tt.expectedWarnings, warnings); diff != "" {
+				t.Errorf("Analyze() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
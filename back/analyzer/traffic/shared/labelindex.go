@@ -0,0 +1,128 @@
+package shared
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/analyzer/utils"
+	"sync"
+)
+
+type label struct {
+	key   string
+	value string
+}
+
+// LabelIndex tells which pods the peers of network policy rules match. Pods are indexed by label when the index is
+// built, so that the pods matched by a peer are only searched among those having its first matchLabels, once per
+// peer. ipBlock peers match the pods by address instead. It is safe for concurrent use.
+type LabelIndex struct {
+	pods            []*corev1.Pod
+	podIndexes      map[*corev1.Pod]int
+	podsByLabel     map[label][]int
+	namespaceLabels map[string]map[string]string
+	mutex           sync.Mutex
+	matchedPods     map[*networkingv1.NetworkPolicyPeer][]bool
+}
+
+func NewLabelIndex(pods []*corev1.Pod, namespaces []*corev1.Namespace) *LabelIndex {
+	index := &LabelIndex{
+		pods:            pods,
+		podIndexes:      make(map[*corev1.Pod]int, len(pods)),
+		podsByLabel:     make(map[label][]int),
+		namespaceLabels: make(map[string]map[string]string, len(namespaces)),
+		matchedPods:     make(map[*networkingv1.NetworkPolicyPeer][]bool),
+	}
+	for i, pod := range pods {
+		index.podIndexes[pod] = i
+		for key, value := range pod.Labels {
+			podLabel := label{key: key, value: value}
+			index.podsByLabel[podLabel] = append(index.podsByLabel[podLabel], i)
+		}
+	}
+	for _, namespace := range namespaces {
+		if _, ok := index.namespaceLabels[namespace.Name]; !ok {
+			index.namespaceLabels[namespace.Name] = namespace.Labels
+		}
+	}
+	return index
+}
+
+// PeerMatches must be given peers by address, from the network policies of the analyzed snapshot: the pods matched
+// by a peer are remembered for its address.
+func (index *LabelIndex) PeerMatches(pod *corev1.Pod, peer *networkingv1.NetworkPolicyPeer) bool {
+	podIndex, ok := index.podIndexes[pod]
+	if !ok {
+		return index.peerMatches(pod, peer)
+	}
+	index.mutex.Lock()
+	matchedPods, ok := index.matchedPods[peer]
+	index.mutex.Unlock()
+	if !ok {
+		matchedPods = index.podsMatchedBy(peer)
+		index.mutex.Lock()
+		index.matchedPods[peer] = matchedPods
+		index.mutex.Unlock()
+	}
+	return matchedPods[podIndex]
+}
+
+func (index *LabelIndex) podsMatchedBy(peer *networkingv1.NetworkPolicyPeer) []bool {
+	matchedPods := make([]bool, len(index.pods))
+	if peer.IPBlock != nil {
+		for podIndex, pod := range index.pods {
+			matchedPods[podIndex], _ = utils.IPBlockMatches(*peer.IPBlock, pod)
+		}
+		return matchedPods
+	}
+	namespaceMatches := make(map[string]bool)
+	for _, podIndex := range index.candidates(peer) {
+		pod := index.pods[podIndex]
+		matches, ok := namespaceMatches[pod.Namespace]
+		if !ok {
+			matches = index.namespaceMatches(pod.Namespace, peer)
+			namespaceMatches[pod.Namespace] = matches
+		}
+		matchedPods[podIndex] = matches && (peer.PodSelector == nil ||
+			utils.SelectorMatches(pod.Labels, *peer.PodSelector))
+	}
+	return matchedPods
+}
+
+// candidates are the pods having the matchLabels of the peer with the fewest pods, or all pods without matchLabels
+func (index *LabelIndex) candidates(peer *networkingv1.NetworkPolicyPeer) []int {
+	var candidates []int
+	if peer.PodSelector != nil {
+		for key, value := range peer.PodSelector.MatchLabels {
+			podIndexes := index.podsByLabel[label{key: key, value: value}]
+			if candidates == nil || len(podIndexes) < len(candidates) {
+				candidates = podIndexes
+			}
+			if len(candidates) == 0 {
+				return candidates
+			}
+		}
+	}
+	if candidates != nil {
+		return candidates
+	}
+	candidates = make([]int, len(index.pods))
+	for i := range index.pods {
+		candidates[i] = i
+	}
+	return candidates
+}
+
+func (index *LabelIndex) peerMatches(pod *corev1.Pod, peer *networkingv1.NetworkPolicyPeer) bool {
+	if peer.IPBlock != nil {
+		matches, _ := utils.IPBlockMatches(*peer.IPBlock, pod)
+		return matches
+	}
+	return index.namespaceMatches(pod.Namespace, peer) &&
+		(peer.PodSelector == nil || utils.SelectorMatches(pod.Labels, *peer.PodSelector))
+}
+
+// Pods of unknown namespaces are matched as if their namespace had no labels
+func (index *LabelIndex) namespaceMatches(namespace string, peer *networkingv1.NetworkPolicyPeer) bool {
+	return peer.NamespaceSelector == nil ||
+		utils.SelectorMatches(index.namespaceLabels[namespace], *peer.NamespaceSelector)
+}
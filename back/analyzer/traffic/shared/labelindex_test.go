@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/testutils"
+	"testing"
+)
+
+func TestLabelIndexPeerMatches(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").
+		WithLabel("tier", "web").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").WithLabel("app", "api").
+		WithLabel("tier", "web").Build()
+	db := testutils.NewPodBuilder().WithName("db").WithNamespace("data").WithLabel("app", "db").
+		WithIP("10.1.0.5").Build()
+	orphan := testutils.NewPodBuilder().WithName("orphan").WithNamespace("unknown").WithLabel("tier", "web").Build()
+	unindexed := testutils.NewPodBuilder().WithName("late").WithNamespace("shop").WithLabel("tier", "web").
+		WithIP("10.2.0.5").Build()
+	namespaces := []*corev1.Namespace{
+		testutils.NewNamespaceBuilder().WithName("shop").WithLabel("env", "prod").Build(),
+		testutils.NewNamespaceBuilder().WithName("data").WithLabel("env", "prod").Build(),
+	}
+	index := NewLabelIndex([]*corev1.Pod{front, api, db, orphan}, namespaces)
+	prod := testutils.NewLabelSelectorBuilder().WithMatchLabel("env", "prod").Build()
+	tests := []struct {
+		name            string
+		peer            networkingv1.NetworkPolicyPeer
+		expectedMatches map[*corev1.Pod]bool
+	}{
+		{
+			name: "pods having all the matchLabels",
+			peer: networkingv1.NetworkPolicyPeer{PodSelector: testutils.NewLabelSelectorBuilder().
+				WithMatchLabel("tier", "web").WithMatchLabel("app", "api").Build()},
+			expectedMatches: map[*corev1.Pod]bool{api: true},
+		},
+		{
+			name: "pods matching expressions",
+			peer: networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"front", "db"}},
+				},
+			}},
+			expectedMatches: map[*corev1.Pod]bool{front: true, db: true},
+		},
+		{
+			name:            "pods of matching namespaces",
+			peer:            networkingv1.NetworkPolicyPeer{NamespaceSelector: prod},
+			expectedMatches: map[*corev1.Pod]bool{front: true, api: true, db: true, unindexed: true},
+		},
+		{
+			name: "pods of unknown namespaces match empty namespace selectors",
+			peer: networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{},
+				PodSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("tier", "web").Build()},
+			expectedMatches: map[*corev1.Pod]bool{front: true, api: true, orphan: true, unindexed: true},
+		},
+		{
+			name: "no pods have an unknown label",
+			peer: networkingv1.NetworkPolicyPeer{PodSelector: testutils.NewLabelSelectorBuilder().
+				WithMatchLabel("app", "cache").Build()},
+			expectedMatches: map[*corev1.Pod]bool{},
+		},
+		{
+			name: "pods having an address of ipBlocks, outside of their except ranges",
+			peer: networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8",
+				Except: []string{"10.1.0.0/16"}}},
+			expectedMatches: map[*corev1.Pod]bool{unindexed: true},
+		},
+	}
+	for i := range tests {
+		// Peers are remembered by address, each case must have its own
+		tt := &tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			for _, pod := range []*corev1.Pod{front, api, db, orphan, unindexed} {
+				// Twice, to go through the remembered matches
+				for n := 0; n < 2; n++ {
+					if matches := index.PeerMatches(pod, &tt.peer); matches != tt.expectedMatches[pod] {
+						t.Errorf("PeerMatches(%s) = %v, expected %v", pod.Name, matches, tt.expectedMatches[pod])
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPeerMatches(b *testing.B) {
+	pods := make([]*corev1.Pod, 0)
+	for i := 0; i < 2000; i++ {
+		pods = append(pods, testutils.NewPodBuilder().WithName(fmt.Sprintf("pod-%d", i)).
+			WithNamespace(fmt.Sprintf("ns-%d", i%20)).WithLabel("app", fmt.Sprintf("app-%d", i%100)).
+			WithLabel("tier", fmt.Sprintf("tier-%d", i%3)).Build())
+	}
+	namespaces := make([]*corev1.Namespace, 0)
+	for i := 0; i < 20; i++ {
+		namespaces = append(namespaces, testutils.NewNamespaceBuilder().WithName(fmt.Sprintf("ns-%d", i)).
+			WithLabel("team", fmt.Sprintf("team-%d", i%5)).Build())
+	}
+	peers := make([]networkingv1.NetworkPolicyPeer, 0)
+	for i := 0; i < 100; i++ {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("app", fmt.Sprintf("app-%d", i)).Build(),
+			NamespaceSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("team", fmt.Sprintf("team-%d", i%5)).
+				Build(),
+		})
+	}
+	b.Run("index", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			index := NewLabelIndex(pods, namespaces)
+			for i := range peers {
+				for _, pod := range pods {
+					index.PeerMatches(pod, &peers[i])
+				}
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		b.ReportAllocs()
+		index := NewLabelIndex(nil, namespaces)
+		for n := 0; n < b.N; n++ {
+			for i := range peers {
+				for _, pod := range pods {
+					index.peerMatches(pod, &peers[i])
+				}
+			}
+		}
+	})
+}
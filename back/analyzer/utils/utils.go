@@ -1,11 +1,200 @@
 package utils
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"karto/types"
+	"net"
 )
 
+// SelectorMatches evaluates the selector in place rather than converting it with metav1.LabelSelectorAsSelector,
+// which allocates on each call and is called for most pairs of pods. Invalid requirements match no object.
 func SelectorMatches(objectLabels map[string]string, labelSelector metav1.LabelSelector) bool {
-	selector, _ := metav1.LabelSelectorAsSelector(&labelSelector)
-	return selector.Matches(labels.Set(objectLabels))
+	for key, value := range labelSelector.MatchLabels {
+		objectValue, ok := objectLabels[key]
+		if !ok || objectValue != value {
+			return false
+		}
+	}
+	for _, requirement := range labelSelector.MatchExpressions {
+		if !requirementMatches(objectLabels, requirement) {
+			return false
+		}
+	}
+	return true
+}
+
+func requirementMatches(objectLabels map[string]string, requirement metav1.LabelSelectorRequirement) bool {
+	objectValue, ok := objectLabels[requirement.Key]
+	switch requirement.Operator {
+	case metav1.LabelSelectorOpIn:
+		return len(requirement.Values) > 0 && ok && containsValue(requirement.Values, objectValue)
+	case metav1.LabelSelectorOpNotIn:
+		return len(requirement.Values) > 0 && (!ok || !containsValue(requirement.Values, objectValue))
+	case metav1.LabelSelectorOpExists:
+		return len(requirement.Values) == 0 && ok
+	case metav1.LabelSelectorOpDoesNotExist:
+		return len(requirement.Values) == 0 && !ok
+	default:
+		return false
+	}
+}
+
+func containsValue(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Labels set by controllers on each pod would make a selector match a single pod revision
+var controllerPodLabels = map[string]bool{
+	"pod-template-hash":                  true,
+	"controller-revision-hash":           true,
+	"pod-template-generation":            true,
+	"statefulset.kubernetes.io/pod-name": true,
+}
+
+func SelectableLabels(podLabels map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range podLabels {
+		if !controllerPodLabels[key] {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func HasDefaultDeny(namespace string, policyType networkingv1.PolicyType,
+	policies []*networkingv1.NetworkPolicy) bool {
+	for _, policy := range policies {
+		if policy.Namespace != namespace || !selectsAllPods(policy) ||
+			!hasPolicyType(policy, policyType) {
+			continue
+		}
+		if policyType == networkingv1.PolicyTypeIngress && len(policy.Spec.Ingress) == 0 {
+			return true
+		}
+		if policyType == networkingv1.PolicyTypeEgress && len(policy.Spec.Egress) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func selectsAllPods(policy *networkingv1.NetworkPolicy) bool {
+	return len(policy.Spec.PodSelector.MatchLabels) == 0 && len(policy.Spec.PodSelector.MatchExpressions) == 0
+}
+
+func hasPolicyType(policy *networkingv1.NetworkPolicy,
+	policyType networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		// Policies without explicit types are ingress policies, and egress ones if they have egress rules
+		return policyType == networkingv1.PolicyTypeIngress ||
+			(policyType == networkingv1.PolicyTypeEgress && len(policy.Spec.Egress) > 0)
+	}
+	for _, declaredPolicyType := range policy.Spec.PolicyTypes {
+		if declaredPolicyType == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+func HasPrivilegedContainer(pod *corev1.Pod) bool {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.SecurityContext != nil && container.SecurityContext.Privileged != nil &&
+				*container.SecurityContext.Privileged {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IPBlockMatches tells whether an address of the pod is in the cidr of the ipBlock and in none of its except entries.
+// When its addresses in the cidr are all excepted, the entry excepting the first one is returned. Pods without
+// address and invalid CIDRs match no ipBlock.
+func IPBlockMatches(ipBlock networkingv1.IPBlock, pod *corev1.Pod) (bool, string) {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false, ""
+	}
+	denyingExcept := ""
+	for _, podIP := range podIPsOf(pod) {
+		ip := net.ParseIP(podIP)
+		if ip == nil || !cidr.Contains(ip) {
+			continue
+		}
+		except := exceptContaining(ipBlock.Except, ip)
+		if except == "" {
+			return true, ""
+		}
+		if denyingExcept == "" {
+			denyingExcept = except
+		}
+	}
+	return false, denyingExcept
+}
+
+func podIPsOf(pod *corev1.Pod) []string {
+	if len(pod.Status.PodIPs) == 0 {
+		if pod.Status.PodIP == "" {
+			return nil
+		}
+		return []string{pod.Status.PodIP}
+	}
+	podIPs := make([]string, 0, len(pod.Status.PodIPs))
+	for _, podIP := range pod.Status.PodIPs {
+		podIPs = append(podIPs, podIP.IP)
+	}
+	return podIPs
+}
+
+func exceptContaining(excepts []string, ip net.IP) string {
+	for _, except := range excepts {
+		_, exceptCIDR, err := net.ParseCIDR(except)
+		if err == nil && exceptCIDR.Contains(ip) {
+			return except
+		}
+	}
+	return ""
+}
+
+// AllowsPort tells whether the route allows the port of the protocol, TCP when empty, either listed or in one of its
+// ranges. The ports of a route which does not list those of each protocol apply to every protocol.
+func AllowsPort(allowedRoute *types.AllowedRoute, port int32, protocol corev1.Protocol) bool {
+	if allowedRoute.Ports == nil {
+		return true
+	}
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	ports, portRanges := allowedRoute.Ports, allowedRoute.PortRanges
+	if allowedRoute.ProtocolPorts != nil {
+		ports, portRanges = make([]int32, 0), nil
+		for _, protocolPorts := range allowedRoute.ProtocolPorts {
+			if protocolPorts.Protocol == protocol {
+				ports, portRanges = protocolPorts.Ports, protocolPorts.PortRanges
+			}
+		}
+		if ports == nil {
+			return true
+		}
+	}
+	for _, allowedPort := range ports {
+		if allowedPort == port {
+			return true
+		}
+	}
+	for _, portRange := range portRanges {
+		if portRange.Port <= port && port <= portRange.EndPort {
+			return true
+		}
+	}
+	return false
 }
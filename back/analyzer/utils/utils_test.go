@@ -0,0 +1,151 @@
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/types"
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	objectLabels := []map[string]string{
+		nil,
+		{"app": "front"},
+		{"app": "front", "tier": "web"},
+		{"app": "db", "tier": "data"},
+	}
+	tests := []struct {
+		name          string
+		labelSelector metav1.LabelSelector
+	}{
+		{name: "empty selector", labelSelector: metav1.LabelSelector{}},
+		{name: "matchLabels", labelSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "front", "tier": "web"}}},
+		{name: "In", labelSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"front", "api"}}}}},
+		{name: "NotIn", labelSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"data"}}}}},
+		{name: "Exists and DoesNotExist", labelSelector: metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "app", Operator: metav1.LabelSelectorOpExists},
+				{Key: "tier", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}}},
+		{name: "matchLabels and expressions", labelSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "db"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"data"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := metav1.LabelSelectorAsSelector(&tt.labelSelector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, object := range objectLabels {
+				expected := selector.Matches(labels.Set(object))
+				if matches := SelectorMatches(object, tt.labelSelector); matches != expected {
+					t.Errorf("SelectorMatches(%v) = %v, expected %v as with a converted selector", object, matches,
+						expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectorMatchesInvalidRequirements(t *testing.T) {
+	invalidSelector := metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "app", Operator: metav1.LabelSelectorOpIn},
+	}}
+	if SelectorMatches(map[string]string{"app": "front"}, invalidSelector) {
+		t.Errorf("SelectorMatches() = true, expected invalid requirements to match no object")
+	}
+}
+
+func TestIPBlockMatches(t *testing.T) {
+	ipBlock := networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16", "10.2.0.0/16"}}
+	podWithIPs := func(ips ...string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		for _, ip := range ips {
+			pod.Status.PodIPs = append(pod.Status.PodIPs, corev1.PodIP{IP: ip})
+		}
+		return pod
+	}
+	tests := []struct {
+		name           string
+		ipBlock        networkingv1.IPBlock
+		pod            *corev1.Pod
+		expectedMatch  bool
+		expectedExcept string
+	}{
+		{name: "address in the cidr", ipBlock: ipBlock, pod: podWithIPs("10.3.0.1"), expectedMatch: true},
+		{name: "single address in the cidr", ipBlock: ipBlock,
+			pod: &corev1.Pod{Status: corev1.PodStatus{PodIP: "10.3.0.1"}}, expectedMatch: true},
+		{name: "address outside of the cidr", ipBlock: ipBlock, pod: podWithIPs("192.168.0.1")},
+		{name: "excepted address", ipBlock: ipBlock, pod: podWithIPs("10.2.0.1"), expectedExcept: "10.2.0.0/16"},
+		{name: "an address not excepted is enough", ipBlock: ipBlock, pod: podWithIPs("10.1.0.1", "10.3.0.1"),
+			expectedMatch: true},
+		{name: "the first excepted address tells the except entry", ipBlock: ipBlock,
+			pod: podWithIPs("fd00::1", "10.1.0.1", "10.2.0.1"), expectedExcept: "10.1.0.0/16"},
+		{name: "pod without address", ipBlock: ipBlock, pod: podWithIPs()},
+		{name: "invalid cidr", ipBlock: networkingv1.IPBlock{CIDR: "10.0.0.0"}, pod: podWithIPs("10.0.0.0")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, except := IPBlockMatches(tt.ipBlock, tt.pod)
+			if match != tt.expectedMatch || except != tt.expectedExcept {
+				t.Errorf("IPBlockMatches() = %v, %q, expected %v, %q", match, except, tt.expectedMatch,
+					tt.expectedExcept)
+			}
+		})
+	}
+}
+
+func TestAllowsPort(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowedRoute  *types.AllowedRoute
+		port          int32
+		protocol      corev1.Protocol
+		expectedAllow bool
+	}{
+		{name: "all ports", allowedRoute: &types.AllowedRoute{Ports: nil}, port: 80, expectedAllow: true},
+		{name: "listed port", allowedRoute: &types.AllowedRoute{Ports: []int32{80}}, port: 80, expectedAllow: true},
+		{name: "port in a range", allowedRoute: &types.AllowedRoute{Ports: []int32{},
+			PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}, port: 8100, expectedAllow: true},
+		{name: "port out of the ports and ranges", allowedRoute: &types.AllowedRoute{Ports: []int32{80},
+			PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}, port: 8101},
+		{name: "no port", allowedRoute: &types.AllowedRoute{Ports: []int32{}}, port: 80},
+		{name: "port of the protocol", allowedRoute: &types.AllowedRoute{Ports: []int32{53},
+			ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolUDP, Ports: []int32{53}}}}, port: 53,
+			protocol: corev1.ProtocolUDP, expectedAllow: true},
+		{name: "port of another protocol", allowedRoute: &types.AllowedRoute{Ports: []int32{53},
+			ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolUDP, Ports: []int32{53}}}}, port: 53},
+		{name: "all ports of the protocol", allowedRoute: &types.AllowedRoute{Ports: []int32{},
+			PortRanges:    []types.PortRange{{Port: 1, EndPort: 65535}},
+			ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolSCTP}}}, port: 80,
+			protocol: corev1.ProtocolSCTP, expectedAllow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allow := AllowsPort(tt.allowedRoute, tt.port, tt.protocol); allow != tt.expectedAllow {
+				t.Errorf("AllowsPort() = %v, expected %v", allow, tt.expectedAllow)
+			}
+		})
+	}
+}
+
+func BenchmarkSelectorMatches(b *testing.B) {
+	objectLabels := map[string]string{"app": "front", "tier": "web", "team": "shop"}
+	labelSelector := metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "front"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"web", "api"}},
+		},
+	}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		SelectorMatches(objectLabels, labelSelector)
+	}
+}
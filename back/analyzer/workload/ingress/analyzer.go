@@ -6,6 +6,8 @@ import (
 	"karto/types"
 )
 
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
 type Analyzer interface {
 	Analyze(ingress *networkingv1beta1.Ingress, services []*corev1.Service) *types.Ingress
 }
@@ -33,10 +35,19 @@ func (analyzer analyzerImpl) Analyze(ingress *networkingv1beta1.Ingress, service
 	return &types.Ingress{
 		Name:           ingress.Name,
 		Namespace:      ingress.Namespace,
+		IngressClass:   analyzer.ingressClassOf(ingress),
 		TargetServices: targetServices,
 	}
 }
 
+// The class of older ingresses is only given by the deprecated annotation
+func (analyzer analyzerImpl) ingressClassOf(ingress *networkingv1beta1.Ingress) string {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+	return ingress.Annotations[ingressClassAnnotation]
+}
+
 func (analyzer analyzerImpl) ingressNamespaceMatches(service *corev1.Service, ingress *networkingv1beta1.Ingress) bool {
 	return service.Namespace == ingress.Namespace
 }
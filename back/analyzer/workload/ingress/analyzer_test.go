@@ -31,6 +31,20 @@ func TestAnalyze(t *testing.T) {
 				TargetServices: []types.ServiceRef{},
 			},
 		},
+		{
+			name: "ingress class is read from the spec or the annotation",
+			args: args{
+				ingress: testutils.NewIngressBuilder().WithName("ing").WithNamespace("ns").
+					WithIngressClass("nginx").Build(),
+				services: []*corev1.Service{},
+			},
+			expectedIngressWithTargetServices: &types.Ingress{
+				Name:           "ing",
+				Namespace:      "ns",
+				IngressClass:   "nginx",
+				TargetServices: []types.ServiceRef{},
+			},
+		},
 		{
 			name: "only services declared as ingress backends are detected as target",
 			args: args{
@@ -4,9 +4,16 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"karto/analyzer/utils"
+	"karto/servicerouting"
 	"karto/types"
 )
 
+// Annotations enabling topology-aware routing, the second one replacing the first one since Kubernetes 1.27
+const (
+	topologyAwareHintsAnnotation = "service.kubernetes.io/topology-aware-hints"
+	topologyModeAnnotation       = "service.kubernetes.io/topology-mode"
+)
+
 type Analyzer interface {
 	Analyze(service *corev1.Service, pods []*corev1.Pod) *types.Service
 }
@@ -30,7 +37,21 @@ func (analyzer analyzerImpl) Analyze(service *corev1.Service, pods []*corev1.Pod
 		Name:       service.Name,
 		Namespace:  service.Namespace,
 		TargetPods: targetPods,
+		Routing:    analyzer.routingOf(service),
+	}
+}
+
+func (analyzer analyzerImpl) routingOf(service *corev1.Service) string {
+	if service.Spec.InternalTrafficPolicy != nil &&
+		*service.Spec.InternalTrafficPolicy == corev1.ServiceInternalTrafficPolicyLocal {
+		return servicerouting.RoutingNode
+	}
+	for _, annotation := range []string{topologyModeAnnotation, topologyAwareHintsAnnotation} {
+		if value := service.Annotations[annotation]; value == "Auto" || value == "auto" {
+			return servicerouting.RoutingZone
+		}
 	}
+	return servicerouting.RoutingCluster
 }
 
 func (analyzer analyzerImpl) serviceNamespaceMatches(pod *corev1.Pod, service *corev1.Service) bool {
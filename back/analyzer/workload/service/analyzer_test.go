@@ -29,6 +29,7 @@ func TestAnalyze(t *testing.T) {
 				Name:       "svc",
 				Namespace:  "ns",
 				TargetPods: []types.PodRef{},
+				Routing:    "cluster",
 			},
 		},
 		{
@@ -45,6 +46,7 @@ func TestAnalyze(t *testing.T) {
 				TargetPods: []types.PodRef{
 					{Name: "name1", Namespace: "default"},
 				},
+				Routing: "cluster",
 			},
 		},
 		{
@@ -61,6 +63,7 @@ func TestAnalyze(t *testing.T) {
 				TargetPods: []types.PodRef{
 					{Name: "name1", Namespace: "ns"},
 				},
+				Routing: "cluster",
 			},
 		},
 		{
@@ -79,6 +82,36 @@ func TestAnalyze(t *testing.T) {
 			expectedServiceWithTargetPods: &types.Service{
 				Namespace:  "default",
 				TargetPods: []types.PodRef{},
+				Routing:    "cluster",
+			},
+		},
+		{
+			name: "services with a local internal traffic policy route to the pods of the node",
+			args: args{
+				service: testutils.NewServiceBuilder().WithName("svc").
+					WithInternalTrafficPolicy(corev1.ServiceInternalTrafficPolicyLocal).
+					WithAnnotation("service.kubernetes.io/topology-mode", "Auto").Build(),
+				pods: []*corev1.Pod{},
+			},
+			expectedServiceWithTargetPods: &types.Service{
+				Name:       "svc",
+				Namespace:  "default",
+				TargetPods: []types.PodRef{},
+				Routing:    "node",
+			},
+		},
+		{
+			name: "services with topology-aware routing route to the pods of the zone",
+			args: args{
+				service: testutils.NewServiceBuilder().WithName("svc").
+					WithAnnotation("service.kubernetes.io/topology-aware-hints", "auto").Build(),
+				pods: []*corev1.Pod{},
+			},
+			expectedServiceWithTargetPods: &types.Service{
+				Name:       "svc",
+				Namespace:  "default",
+				TargetPods: []types.PodRef{},
+				Routing:    "zone",
 			},
 		},
 	}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"karto/objectstore"
+	"karto/types"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotPrefix     = "snapshots/"
+	snapshotExtension  = ".json.gz"
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+type Options struct {
+	Destination string
+	Interval    time.Duration
+	Retention   time.Duration
+}
+
+type archiver struct {
+	store              objectstore.Store
+	options            Options
+	mutex              sync.Mutex
+	lastAnalysisResult *types.AnalysisResult
+	now                func() time.Time
+}
+
+func newArchiver(store objectstore.Store, options Options) *archiver {
+	return &archiver{
+		store:   store,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+func Archive(store objectstore.Store, options Options, resultsChannel <-chan types.AnalysisResult) {
+	archiver := newArchiver(store, options)
+	go archiver.archivePeriodically()
+	for {
+		analysisResult := <-resultsChannel
+		archiver.mutex.Lock()
+		archiver.lastAnalysisResult = &analysisResult
+		archiver.mutex.Unlock()
+	}
+}
+
+func (archiver *archiver) archivePeriodically() {
+	for {
+		time.Sleep(archiver.options.Interval)
+		archiver.mutex.Lock()
+		lastAnalysisResult := archiver.lastAnalysisResult
+		archiver.mutex.Unlock()
+		if lastAnalysisResult == nil {
+			continue
+		}
+		err := archiver.archive(*lastAnalysisResult)
+		if err != nil {
+			log.Printf("Unable to archive the analysis result to %s: %s\n", archiver.options.Destination, err)
+			continue
+		}
+		err = archiver.expire()
+		if err != nil {
+			log.Printf("Unable to expire archived snapshots in %s: %s\n", archiver.options.Destination, err)
+		}
+	}
+}
+
+func (archiver *archiver) archive(analysisResult types.AnalysisResult) error {
+	var content bytes.Buffer
+	writer := gzip.NewWriter(&content)
+	err := json.NewEncoder(writer).Encode(analysisResult)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	key := snapshotPrefix + "snapshot-" + archiver.now().UTC().Format(snapshotTimeFormat) + snapshotExtension
+	return archiver.store.Put(key, content.Bytes())
+}
+
+// Snapshots are dated by their key, so that retention does not depend on the metadata kept by the store
+func (archiver *archiver) expire() error {
+	if archiver.options.Retention <= 0 {
+		return nil
+	}
+	keys, err := archiver.store.List(snapshotPrefix)
+	if err != nil {
+		return err
+	}
+	oldest := archiver.now().Add(-archiver.options.Retention)
+	for _, key := range keys {
+		archivedAt, ok := archivedAtOf(key)
+		if !ok || !archivedAt.Before(oldest) {
+			continue
+		}
+		err = archiver.store.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archivedAtOf(key string) (time.Time, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(key, snapshotPrefix+"snapshot-"), snapshotExtension)
+	archivedAt, err := time.Parse(snapshotTimeFormat, name)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return archivedAt, true
+}
@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"karto/types"
+	"sort"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	objects map[string][]byte
+}
+
+func (store *mockStore) Put(key string, content []byte) error {
+	store.objects[key] = content
+	return nil
+}
+
+func (store *mockStore) List(string) ([]string, error) {
+	keys := make([]string, 0)
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (store *mockStore) Delete(key string) error {
+	delete(store.objects, key)
+	return nil
+}
+
+func TestArchive(t *testing.T) {
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{{Name: "pod", Namespace: "ns", Labels: map[string]string{"app": "front"}}},
+	}
+	store := &mockStore{objects: make(map[string][]byte)}
+	archiver := newArchiver(store, Options{})
+	archiver.now = func() time.Time { return time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC) }
+	err := archiver.archive(analysisResult)
+	if err != nil {
+		t.Fatalf("archive() returned an error: %s", err)
+	}
+	content, ok := store.objects["snapshots/snapshot-20210401T120000Z.json.gz"]
+	if !ok {
+		t.Fatalf("archive() did not upload the expected snapshot, got: %v", store.objects)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("archive() did not upload a gzip snapshot: %s", err)
+	}
+	snapshot, _ := ioutil.ReadAll(reader)
+	expectedSnapshot, _ := json.Marshal(analysisResult)
+	if diff := cmp.Diff(string(expectedSnapshot)+"\n", string(snapshot)); diff != "" {
+		t.Errorf("archive() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	type args struct {
+		keys      []string
+		retention time.Duration
+	}
+	tests := []struct {
+		name         string
+		args         args
+		expectedKeys []string
+	}{
+		{
+			name: "snapshots older than the retention are deleted",
+			args: args{
+				keys: []string{
+					"snapshots/snapshot-20210301T120000Z.json.gz",
+					"snapshots/snapshot-20210325T120000Z.json.gz",
+					"snapshots/snapshot-20210331T120000Z.json.gz",
+					"snapshots/notes.txt",
+				},
+				retention: 7 * 24 * time.Hour,
+			},
+			expectedKeys: []string{
+				"snapshots/notes.txt",
+				"snapshots/snapshot-20210325T120000Z.json.gz",
+				"snapshots/snapshot-20210331T120000Z.json.gz",
+			},
+		},
+		{
+			name: "snapshots are kept forever without retention",
+			args: args{
+				keys:      []string{"snapshots/snapshot-20200101T120000Z.json.gz"},
+				retention: 0,
+			},
+			expectedKeys: []string{"snapshots/snapshot-20200101T120000Z.json.gz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{objects: make(map[string][]byte)}
+			for _, key := range tt.args.keys {
+				store.objects[key] = []byte{}
+			}
+			archiver := newArchiver(store, Options{Retention: tt.args.retention})
+			archiver.now = func() time.Time { return time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC) }
+			err := archiver.expire()
+			if err != nil {
+				t.Fatalf("expire() returned an error: %s", err)
+			}
+			keys, _ := store.List("")
+			sort.Strings(keys)
+			if diff := cmp.Diff(tt.expectedKeys, keys); diff != "" {
+				t.Errorf("expire() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package authoring
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/analyzer/utils"
+	"karto/routeports"
+	"karto/types"
+	"sort"
+)
+
+const (
+	DNSNamespace = "kube-system"
+	DNSPodLabel  = "k8s-app"
+	DNSPodValue  = "kube-dns"
+	DNSPort      = 53
+)
+
+var kubeAPIPorts = []int32{443, 6443}
+
+type routeGroup struct {
+	source resolvedEndpoint
+	target resolvedEndpoint
+	ports  routeports.Set
+}
+
+func Onboard(analysisResult types.AnalysisResult, namespace string) (Suggestion, error) {
+	suggestion := Suggestion{
+		Policies:      make([]*networkingv1.NetworkPolicy, 0),
+		Prerequisites: make([]string, 0),
+		Notes:         make([]string, 0),
+	}
+	if !namespaceExists(analysisResult.Namespaces, namespace) {
+		return suggestion, fmt.Errorf("unknown namespace %s", namespace)
+	}
+	suggestion.Policies = append(suggestion.Policies, DefaultDenyPolicy(namespace,
+		networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress), DNSEgressPolicy(namespace),
+		kubeAPIEgressPolicy(namespace))
+	suggestion.Policies = append(suggestion.Policies, intraNamespacePolicies(analysisResult, namespace)...)
+	if !hasNamespaceNameLabel(analysisResult.Namespaces, DNSNamespace) {
+		suggestion.Prerequisites = append(suggestion.Prerequisites, fmt.Sprintf(
+			"namespace %s must be labeled %s=%s for the DNS policy to match", DNSNamespace, namespaceNameLabel,
+			DNSNamespace))
+	}
+	suggestion.Notes = append(suggestion.Notes,
+		"the Kubernetes API policy allows egress on its usual ports to any destination, restrict it to the "+
+			"addresses of your API server when they are known",
+		"routes between pods of the namespace are kept as currently allowed, review them before applying")
+	return suggestion, nil
+}
+
+func namespaceExists(namespaces []*types.Namespace, name string) bool {
+	for _, namespace := range namespaces {
+		if namespace.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newPolicy(name string, namespace string, spec networkingv1.NetworkPolicySpec) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       spec,
+	}
+}
+
+func DefaultDenyPolicy(namespace string, policyTypes ...networkingv1.PolicyType) *networkingv1.NetworkPolicy {
+	name := "default-deny"
+	if len(policyTypes) == 1 {
+		name = policyNameOf(name, string(policyTypes[0]))
+	}
+	return newPolicy(name, namespace, networkingv1.NetworkPolicySpec{
+		PolicyTypes: policyTypes,
+	})
+}
+
+func DNSEgressPolicy(namespace string) *networkingv1.NetworkPolicy {
+	udp := corev1.ProtocolUDP
+	tcp := corev1.ProtocolTCP
+	dnsPort := intstr.FromInt(DNSPort)
+	return newPolicy("allow-dns-egress", namespace, networkingv1.NetworkPolicySpec{
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		Egress: []networkingv1.NetworkPolicyEgressRule{{
+			To: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
+					namespaceNameLabel: DNSNamespace}},
+				PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{DNSPodLabel: DNSPodValue}},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		}},
+	})
+}
+
+func kubeAPIEgressPolicy(namespace string) *networkingv1.NetworkPolicy {
+	ports := make([]networkingv1.NetworkPolicyPort, 0)
+	for _, port := range kubeAPIPorts {
+		ports = append(ports, toPolicyPorts(port, corev1.ProtocolTCP)...)
+	}
+	return newPolicy("allow-kube-api-egress", namespace, networkingv1.NetworkPolicySpec{
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		Egress:      []networkingv1.NetworkPolicyEgressRule{{Ports: ports}},
+	})
+}
+
+func intraNamespacePolicies(analysisResult types.AnalysisResult, namespace string) []*networkingv1.NetworkPolicy {
+	podLabels := make(map[types.PodRef]map[string]string)
+	for _, pod := range analysisResult.Pods {
+		podLabels[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = utils.SelectableLabels(pod.Labels)
+	}
+	groupsByKey := make(map[string]*routeGroup)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if allowedRoute.SourcePod.Namespace != namespace || allowedRoute.TargetPod.Namespace != namespace {
+			continue
+		}
+		source := toGroupEndpoint(namespace, podLabels[allowedRoute.SourcePod])
+		target := toGroupEndpoint(namespace, podLabels[allowedRoute.TargetPod])
+		key := source.name + "/" + target.name
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &routeGroup{source: source, target: target, ports: routeports.None()}
+			groupsByKey[key] = group
+		}
+		group.ports = routeports.Merge(group.ports, routeports.Of(allowedRoute))
+	}
+	keys := make([]string, 0)
+	for key := range groupsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ingressRulesByTarget := make(map[string][]networkingv1.NetworkPolicyIngressRule)
+	egressRulesBySource := make(map[string][]networkingv1.NetworkPolicyEgressRule)
+	endpointsByName := make(map[string]resolvedEndpoint)
+	for _, key := range keys {
+		group := groupsByKey[key]
+		ports := group.ports.NetworkPolicyPorts()
+		endpointsByName[group.source.name] = group.source
+		endpointsByName[group.target.name] = group.target
+		ingressRulesByTarget[group.target.name] = append(ingressRulesByTarget[group.target.name],
+			networkingv1.NetworkPolicyIngressRule{
+				From:  []networkingv1.NetworkPolicyPeer{peer(group.source, namespace)},
+				Ports: ports,
+			})
+		egressRulesBySource[group.source.name] = append(egressRulesBySource[group.source.name],
+			networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{peer(group.target, namespace)},
+				Ports: ports,
+			})
+	}
+	names := make([]string, 0)
+	for name := range endpointsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	policies := make([]*networkingv1.NetworkPolicy, 0)
+	for _, name := range names {
+		endpoint := endpointsByName[name]
+		if ingressRules, ok := ingressRulesByTarget[name]; ok {
+			policies = append(policies, newPolicy(policyNameOf("allow", name, "ingress"), namespace,
+				networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: endpoint.podLabels},
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+					Ingress:     ingressRules,
+				}))
+		}
+		if egressRules, ok := egressRulesBySource[name]; ok {
+			policies = append(policies, newPolicy(policyNameOf("allow", name, "egress"), namespace,
+				networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: endpoint.podLabels},
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+					Egress:      egressRules,
+				}))
+		}
+	}
+	return policies
+}
+
+func toGroupEndpoint(namespace string, podLabels map[string]string) resolvedEndpoint {
+	return resolvedEndpoint{
+		name:      endpointName(Endpoint{Namespace: namespace, PodLabels: podLabels}),
+		namespace: namespace,
+		podLabels: podLabels,
+	}
+}
@@ -0,0 +1,190 @@
+package authoring
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/types"
+	"testing"
+)
+
+func TestOnboard(t *testing.T) {
+	tests := []struct {
+		name                  string
+		analysisResult        types.AnalysisResult
+		namespace             string
+		expectedPolicyNames   []string
+		expectedPrerequisites []string
+		expectedError         string
+	}{
+		{
+			name: "the starter set always contains the default deny, DNS and Kubernetes API policies",
+			analysisResult: types.AnalysisResult{
+				Namespaces: []*types.Namespace{
+					{Name: "shop"},
+					{Name: "kube-system", Labels: map[string]string{namespaceNameLabel: "kube-system"}},
+				},
+			},
+			namespace:             "shop",
+			expectedPolicyNames:   []string{"default-deny", "allow-dns-egress", "allow-kube-api-egress"},
+			expectedPrerequisites: []string{},
+		},
+		{
+			name: "kube-system must be labeled with its name for the DNS policy to match",
+			analysisResult: types.AnalysisResult{
+				Namespaces: []*types.Namespace{{Name: "shop"}, {Name: "kube-system"}},
+			},
+			namespace:           "shop",
+			expectedPolicyNames: []string{"default-deny", "allow-dns-egress", "allow-kube-api-egress"},
+			expectedPrerequisites: []string{
+				"namespace kube-system must be labeled kubernetes.io/metadata.name=kube-system for the DNS policy to match",
+			},
+		},
+		{
+			name: "existing routes inside the namespace are allowed, other routes are ignored",
+			analysisResult: types.AnalysisResult{
+				Namespaces: []*types.Namespace{
+					{Name: "shop"},
+					{Name: "kube-system", Labels: map[string]string{namespaceNameLabel: "kube-system"}},
+				},
+				Pods: []*types.Pod{
+					{Name: "front-1", Namespace: "shop", Labels: map[string]string{"app": "front"}},
+					{Name: "api-1", Namespace: "shop", Labels: map[string]string{"app": "api"}},
+					{Name: "other-1", Namespace: "other", Labels: map[string]string{"app": "other"}},
+				},
+				AllowedRoutes: []*types.AllowedRoute{
+					{SourcePod: types.PodRef{Name: "front-1", Namespace: "shop"},
+						TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{8080}},
+					{SourcePod: types.PodRef{Name: "other-1", Namespace: "other"},
+						TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{8080}},
+				},
+			},
+			namespace: "shop",
+			expectedPolicyNames: []string{"default-deny", "allow-dns-egress", "allow-kube-api-egress", "allow-api-ingress",
+				"allow-front-egress"},
+			expectedPrerequisites: []string{},
+		},
+		{
+			name:           "an unknown namespace cannot be onboarded",
+			analysisResult: types.AnalysisResult{Namespaces: []*types.Namespace{{Name: "shop"}}},
+			namespace:      "unknown",
+			expectedError:  "unknown namespace unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion, err := Onboard(tt.analysisResult, tt.namespace)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Fatalf("Onboard() error = %v, expected %s", err, tt.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Onboard() unexpected error: %v", err)
+			}
+			policyNames := make([]string, 0)
+			for _, policy := range suggestion.Policies {
+				policyNames = append(policyNames, policy.Name)
+			}
+			if diff := cmp.Diff(tt.expectedPolicyNames, policyNames); diff != "" {
+				t.Errorf("Onboard() policies mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedPrerequisites, suggestion.Prerequisites); diff != "" {
+				t.Errorf("Onboard() prerequisites mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIntraNamespacePolicies(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port8080 := intstr.FromInt(8080)
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front-1", Namespace: "shop", Labels: map[string]string{"app": "front", "pod-template-hash": "a"}},
+			{Name: "front-2", Namespace: "shop", Labels: map[string]string{"app": "front", "pod-template-hash": "b"}},
+			{Name: "api-1", Namespace: "shop", Labels: map[string]string{"app": "api"}},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "front-1", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{8080}},
+			{SourcePod: types.PodRef{Name: "front-2", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{80}},
+		},
+	}
+	frontSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}}
+	apiSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	ports := []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}, {Protocol: &tcp, Port: &port8080}}
+	expectedPolicies := []*networkingv1.NetworkPolicy{
+		{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-api-ingress", Namespace: "shop"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: apiSelector,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &frontSelector}},
+					Ports: ports,
+				}},
+			},
+		},
+		{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-front-egress", Namespace: "shop"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: frontSelector,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To:    []networkingv1.NetworkPolicyPeer{{PodSelector: &apiSelector}},
+					Ports: ports,
+				}},
+			},
+		},
+	}
+	policies := intraNamespacePolicies(analysisResult, "shop")
+	if diff := cmp.Diff(expectedPolicies, policies); diff != "" {
+		t.Errorf("intraNamespacePolicies() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIntraNamespacePoliciesPortRanges(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port8000 := intstr.FromInt(8000)
+	endPort8100 := int32(8100)
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front-1", Namespace: "shop", Labels: map[string]string{"app": "front", "pod-template-hash": "a"}},
+			{Name: "front-2", Namespace: "shop", Labels: map[string]string{"app": "front", "pod-template-hash": "b"}},
+			{Name: "api-1", Namespace: "shop", Labels: map[string]string{"app": "api"}},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "front-1", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{},
+					PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}}},
+			{SourcePod: types.PodRef{Name: "front-2", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "api-1", Namespace: "shop"}, Ports: []int32{80},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{80}}}},
+		},
+	}
+	expectedPorts := []networkingv1.NetworkPolicyPort{
+		{Protocol: &tcp, Port: &port80},
+		{Protocol: &tcp, Port: &port8000, EndPort: &endPort8100},
+	}
+	policies := intraNamespacePolicies(analysisResult, "shop")
+	if len(policies) != 2 {
+		t.Fatalf("intraNamespacePolicies() returned %d policies, expected 2", len(policies))
+	}
+	if diff := cmp.Diff(expectedPorts, policies[0].Spec.Ingress[0].Ports); diff != "" {
+		t.Errorf("intraNamespacePolicies() ingress ports mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedPorts, policies[1].Spec.Egress[0].Ports); diff != "" {
+		t.Errorf("intraNamespacePolicies() egress ports mismatch (-want +got):\n%s", diff)
+	}
+}
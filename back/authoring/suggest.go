@@ -0,0 +1,303 @@
+package authoring
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/analyzer/utils"
+	"karto/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+var invalidNameCharacters = regexp.MustCompile("[^a-z0-9-]+")
+
+type Endpoint struct {
+	Pod       *types.PodRef     `json:"pod"`
+	Namespace string            `json:"namespace"`
+	PodLabels map[string]string `json:"podLabels"`
+}
+
+type Request struct {
+	Source   Endpoint `json:"source"`
+	Target   Endpoint `json:"target"`
+	Port     int32    `json:"port"`
+	Protocol string   `json:"protocol"`
+}
+
+type Suggestion struct {
+	Policies      []*networkingv1.NetworkPolicy `json:"policies"`
+	Prerequisites []string                      `json:"prerequisites"`
+	Notes         []string                      `json:"notes"`
+}
+
+type resolvedEndpoint struct {
+	name      string
+	namespace string
+	podLabels map[string]string
+	pods      []types.PodRef
+}
+
+func Suggest(analysisResult types.AnalysisResult, request Request) (Suggestion, error) {
+	suggestion := Suggestion{
+		Policies:      make([]*networkingv1.NetworkPolicy, 0),
+		Prerequisites: make([]string, 0),
+		Notes:         make([]string, 0),
+	}
+	if request.Port < 0 || request.Port > 65535 {
+		return suggestion, fmt.Errorf("invalid port %d", request.Port)
+	}
+	protocol, err := toProtocol(request.Protocol)
+	if err != nil {
+		return suggestion, err
+	}
+	source, err := resolve(analysisResult, request.Source, "source")
+	if err != nil {
+		return suggestion, err
+	}
+	target, err := resolve(analysisResult, request.Target, "target")
+	if err != nil {
+		return suggestion, err
+	}
+	if isAlreadyAllowed(analysisResult.AllowedRoutes, source, target, request.Port, protocol) {
+		suggestion.Notes = append(suggestion.Notes, "this flow is already allowed, no policy is needed")
+		return suggestion, nil
+	}
+	isolations := podIsolationsByPod(analysisResult.PodIsolations)
+	ports := toPolicyPorts(request.Port, protocol)
+	if anyIsolated(target.pods, isolations, true) {
+		suggestion.Policies = append(suggestion.Policies, ingressPolicy(source, target, ports))
+	} else {
+		suggestion.Notes = append(suggestion.Notes, "the target is not isolated for ingress, no ingress policy "+
+			"is needed")
+	}
+	if anyIsolated(source.pods, isolations, false) {
+		suggestion.Policies = append(suggestion.Policies, egressPolicy(source, target, ports))
+	} else {
+		suggestion.Notes = append(suggestion.Notes, "the source is not isolated for egress, no egress policy "+
+			"is needed")
+	}
+	if len(suggestion.Policies) > 0 && source.namespace != target.namespace {
+		for _, namespace := range []string{source.namespace, target.namespace} {
+			if !hasNamespaceNameLabel(analysisResult.Namespaces, namespace) {
+				suggestion.Prerequisites = append(suggestion.Prerequisites, fmt.Sprintf(
+					"namespace %s must be labeled %s=%s for the namespace selector to match", namespace,
+					namespaceNameLabel, namespace))
+			}
+		}
+	}
+	return suggestion, nil
+}
+
+func resolve(analysisResult types.AnalysisResult, endpoint Endpoint, role string) (resolvedEndpoint, error) {
+	if endpoint.Pod != nil {
+		for _, pod := range analysisResult.Pods {
+			if pod.Name == endpoint.Pod.Name && pod.Namespace == endpoint.Pod.Namespace {
+				podLabels := utils.SelectableLabels(pod.Labels)
+				return resolvedEndpoint{
+					name:      pod.Name,
+					namespace: pod.Namespace,
+					podLabels: podLabels,
+					pods:      matchingPods(analysisResult.Pods, pod.Namespace, podLabels),
+				}, nil
+			}
+		}
+		return resolvedEndpoint{}, fmt.Errorf("unknown %s pod %s/%s", role, endpoint.Pod.Namespace,
+			endpoint.Pod.Name)
+	}
+	if endpoint.Namespace == "" {
+		return resolvedEndpoint{}, fmt.Errorf("the %s must be a pod or a namespace with optional pod labels", role)
+	}
+	return resolvedEndpoint{
+		name:      endpointName(endpoint),
+		namespace: endpoint.Namespace,
+		podLabels: endpoint.PodLabels,
+		pods:      matchingPods(analysisResult.Pods, endpoint.Namespace, endpoint.PodLabels),
+	}, nil
+}
+
+func endpointName(endpoint Endpoint) string {
+	if len(endpoint.PodLabels) == 0 {
+		return endpoint.Namespace
+	}
+	keys := make([]string, 0)
+	for key := range endpoint.PodLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0)
+	for _, key := range keys {
+		values = append(values, endpoint.PodLabels[key])
+	}
+	return strings.Join(values, "-")
+}
+
+func matchingPods(pods []*types.Pod, namespace string, podLabels map[string]string) []types.PodRef {
+	result := make([]types.PodRef, 0)
+	for _, pod := range pods {
+		if pod.Namespace != namespace {
+			continue
+		}
+		matches := true
+		for key, value := range podLabels {
+			if actualValue, ok := pod.Labels[key]; !ok || actualValue != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+		}
+	}
+	return result
+}
+
+func isAlreadyAllowed(allowedRoutes []*types.AllowedRoute, source resolvedEndpoint, target resolvedEndpoint,
+	port int32, protocol corev1.Protocol) bool {
+	if len(source.pods) == 0 || len(target.pods) == 0 {
+		return false
+	}
+	allowedPairs := make(map[types.PodRef]map[types.PodRef]bool)
+	for _, allowedRoute := range allowedRoutes {
+		// Allowing all ports is only achieved by allowing all of them
+		if (port == 0 && allowedRoute.Ports != nil) || (port != 0 && !utils.AllowsPort(allowedRoute, port, protocol)) {
+			continue
+		}
+		if allowedPairs[allowedRoute.SourcePod] == nil {
+			allowedPairs[allowedRoute.SourcePod] = make(map[types.PodRef]bool)
+		}
+		allowedPairs[allowedRoute.SourcePod][allowedRoute.TargetPod] = true
+	}
+	for _, sourcePod := range source.pods {
+		for _, targetPod := range target.pods {
+			if sourcePod != targetPod && !allowedPairs[sourcePod][targetPod] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func podIsolationsByPod(podIsolations []*types.PodIsolation) map[types.PodRef]*types.PodIsolation {
+	result := make(map[types.PodRef]*types.PodIsolation)
+	for _, podIsolation := range podIsolations {
+		result[podIsolation.Pod] = podIsolation
+	}
+	return result
+}
+
+func anyIsolated(pods []types.PodRef, isolations map[types.PodRef]*types.PodIsolation, ingress bool) bool {
+	if len(pods) == 0 {
+		// Pods created later are likely to be isolated as well, it is safer to suggest the policy
+		return true
+	}
+	for _, pod := range pods {
+		isolation, ok := isolations[pod]
+		if !ok {
+			continue
+		}
+		if (ingress && isolation.IsIngressIsolated) || (!ingress && isolation.IsEgressIsolated) {
+			return true
+		}
+	}
+	return false
+}
+
+func toProtocol(protocol string) (corev1.Protocol, error) {
+	switch corev1.Protocol(strings.ToUpper(protocol)) {
+	case "", corev1.ProtocolTCP:
+		return corev1.ProtocolTCP, nil
+	case corev1.ProtocolUDP:
+		return corev1.ProtocolUDP, nil
+	case corev1.ProtocolSCTP:
+		return corev1.ProtocolSCTP, nil
+	default:
+		return "", fmt.Errorf("invalid protocol %s, expected TCP, UDP or SCTP", protocol)
+	}
+}
+
+func toPolicyPorts(port int32, protocol corev1.Protocol) []networkingv1.NetworkPolicyPort {
+	if port == 0 {
+		return nil
+	}
+	policyProtocol := protocol
+	policyPort := intstr.FromInt(int(port))
+	return []networkingv1.NetworkPolicyPort{{Protocol: &policyProtocol, Port: &policyPort}}
+}
+
+func ingressPolicy(source resolvedEndpoint, target resolvedEndpoint,
+	ports []networkingv1.NetworkPolicyPort) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policyName(source, target, ports, networkingv1.PolicyTypeIngress),
+			Namespace: target.namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: target.podLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: []networkingv1.NetworkPolicyPeer{peer(source, target.namespace)}, Ports: ports},
+			},
+		},
+	}
+}
+
+func egressPolicy(source resolvedEndpoint, target resolvedEndpoint,
+	ports []networkingv1.NetworkPolicyPort) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policyName(source, target, ports, networkingv1.PolicyTypeEgress),
+			Namespace: source.namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: source.podLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{To: []networkingv1.NetworkPolicyPeer{peer(target, source.namespace)}, Ports: ports},
+			},
+		},
+	}
+}
+
+func peer(endpoint resolvedEndpoint, policyNamespace string) networkingv1.NetworkPolicyPeer {
+	policyPeer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchLabels: endpoint.podLabels},
+	}
+	if endpoint.namespace != policyNamespace {
+		policyPeer.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{namespaceNameLabel: endpoint.namespace},
+		}
+	}
+	return policyPeer
+}
+
+func policyName(source resolvedEndpoint, target resolvedEndpoint, ports []networkingv1.NetworkPolicyPort,
+	policyType networkingv1.PolicyType) string {
+	parts := []string{"allow", source.name, "to", target.name}
+	if len(ports) > 0 {
+		parts = append(parts, ports[0].Port.String())
+	}
+	parts = append(parts, string(policyType))
+	return policyNameOf(parts...)
+}
+
+func policyNameOf(parts ...string) string {
+	name := invalidNameCharacters.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	return strings.Trim(name, "-")
+}
+
+func hasNamespaceNameLabel(namespaces []*types.Namespace, name string) bool {
+	for _, namespace := range namespaces {
+		if namespace.Name == name {
+			return namespace.Labels[namespaceNameLabel] == name
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package authoring
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/types"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	front := &types.Pod{Name: "front-1", Namespace: "shop",
+		Labels: map[string]string{"app": "front", "pod-template-hash": "abc"}}
+	api := &types.Pod{Name: "api-1", Namespace: "catalog", Labels: map[string]string{"app": "api"}}
+	frontRef := types.PodRef{Name: "front-1", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api-1", Namespace: "catalog"}
+	labeledNamespaces := []*types.Namespace{
+		{Name: "shop", Labels: map[string]string{namespaceNameLabel: "shop"}},
+		{Name: "catalog", Labels: map[string]string{namespaceNameLabel: "catalog"}},
+	}
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt(8080)
+	ingressPolicy := &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-front-1-to-api-1-8080-ingress", Namespace: "catalog"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{
+					PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}},
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "shop"}},
+				}},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+			}},
+		},
+	}
+	egressPolicy := &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-front-1-to-api-1-8080-egress", Namespace: "shop"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{{
+					PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "catalog"}},
+				}},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+			}},
+		},
+	}
+	request := Request{Source: Endpoint{Pod: &frontRef}, Target: Endpoint{Pod: &apiRef}, Port: 8080}
+	tests := []struct {
+		name               string
+		analysisResult     types.AnalysisResult
+		request            Request
+		expectedSuggestion Suggestion
+		expectedError      string
+	}{
+		{
+			name: "suggests ingress and egress policies between isolated pods",
+			analysisResult: types.AnalysisResult{
+				Namespaces: labeledNamespaces,
+				Pods:       []*types.Pod{front, api},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: true},
+					{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				},
+			},
+			request: request,
+			expectedSuggestion: Suggestion{
+				Policies:      []*networkingv1.NetworkPolicy{ingressPolicy, egressPolicy},
+				Prerequisites: []string{},
+				Notes:         []string{},
+			},
+		},
+		{
+			name: "only suggests the policies needed by the isolation state and namespace labels",
+			analysisResult: types.AnalysisResult{
+				Namespaces: []*types.Namespace{{Name: "shop", Labels: map[string]string{}}, labeledNamespaces[1]},
+				Pods:       []*types.Pod{front, api},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: false},
+					{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				},
+			},
+			request: request,
+			expectedSuggestion: Suggestion{
+				Policies: []*networkingv1.NetworkPolicy{ingressPolicy},
+				Prerequisites: []string{
+					"namespace shop must be labeled kubernetes.io/metadata.name=shop for the namespace selector to match",
+				},
+				Notes: []string{"the source is not isolated for egress, no egress policy is needed"},
+			},
+		},
+		{
+			name: "suggests nothing for an already allowed flow",
+			analysisResult: types.AnalysisResult{
+				Pods: []*types.Pod{front, api},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: true},
+					{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				},
+				AllowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: apiRef, Ports: []int32{8080}}},
+			},
+			request: request,
+			expectedSuggestion: Suggestion{
+				Policies:      []*networkingv1.NetworkPolicy{},
+				Prerequisites: []string{},
+				Notes:         []string{"this flow is already allowed, no policy is needed"},
+			},
+		},
+		{
+			name: "suggests nothing for a flow already allowed by a port range",
+			analysisResult: types.AnalysisResult{
+				Pods: []*types.Pod{front, api},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: true},
+					{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				},
+				AllowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: apiRef, Ports: []int32{},
+					PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}},
+			},
+			request: request,
+			expectedSuggestion: Suggestion{
+				Policies:      []*networkingv1.NetworkPolicy{},
+				Prerequisites: []string{},
+				Notes:         []string{"this flow is already allowed, no policy is needed"},
+			},
+		},
+		{
+			name: "suggests policies for a flow only allowed over another protocol",
+			analysisResult: types.AnalysisResult{
+				Namespaces: labeledNamespaces,
+				Pods:       []*types.Pod{front, api},
+				PodIsolations: []*types.PodIsolation{
+					{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: true},
+					{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				},
+				AllowedRoutes: []*types.AllowedRoute{{SourcePod: frontRef, TargetPod: apiRef, Ports: []int32{8080},
+					ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolUDP, Ports: []int32{8080}}}}},
+			},
+			request: request,
+			expectedSuggestion: Suggestion{
+				Policies:      []*networkingv1.NetworkPolicy{ingressPolicy, egressPolicy},
+				Prerequisites: []string{},
+				Notes:         []string{},
+			},
+		},
+		{
+			name:           "rejects unknown pods",
+			analysisResult: types.AnalysisResult{Pods: []*types.Pod{front}},
+			request:        request,
+			expectedError:  "unknown target pod catalog/api-1",
+		},
+		{
+			name:           "rejects unknown protocols",
+			analysisResult: types.AnalysisResult{Pods: []*types.Pod{front, api}},
+			request:        Request{Source: Endpoint{Pod: &frontRef}, Target: Endpoint{Pod: &apiRef}, Protocol: "ICMP"},
+			expectedError:  "invalid protocol ICMP, expected TCP, UDP or SCTP",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion, err := Suggest(tt.analysisResult, tt.request)
+			if err != nil || tt.expectedError != "" {
+				if diff := cmp.Diff(tt.expectedError, err.Error()); diff != "" {
+					t.Errorf("Suggest() error mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expectedSuggestion, suggestion); diff != "" {
+				t.Errorf("Suggest() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
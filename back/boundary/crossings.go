@@ -0,0 +1,108 @@
+package boundary
+
+import (
+	"karto/config"
+	"karto/types"
+	"sort"
+	"time"
+)
+
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Report is the evidence of the segmentation of the trust boundaries at the time of the analysis
+type Report struct {
+	AnalyzedAt time.Time         `json:"analyzedAt"`
+	Boundaries []*BoundaryReport `json:"boundaries"`
+}
+
+type BoundaryReport struct {
+	Name       string         `json:"name"`
+	MemberPods []types.PodRef `json:"memberPods"`
+	Inbound    int            `json:"inbound"`
+	Outbound   int            `json:"outbound"`
+	Crossings  []*Crossing    `json:"crossings"`
+}
+
+// Crossing is an allowed route between a pod of the boundary and a pod outside of it, along with the policies allowing
+// it, none on a side meaning that the pod of this side is not isolated in this direction
+type Crossing struct {
+	Direction       string                `json:"direction"`
+	SourcePod       types.PodRef          `json:"sourcePod"`
+	TargetPod       types.PodRef          `json:"targetPod"`
+	Ports           []int32               `json:"ports"`
+	EgressPolicies  []types.NetworkPolicy `json:"egressPolicies"`
+	IngressPolicies []types.NetworkPolicy `json:"ingressPolicies"`
+}
+
+// Build lists, for each boundary in the configured order, the allowed routes entering or leaving it, sorted by
+// direction then pods. The routes within a boundary or outside of it do not cross it.
+func Build(analysisResult types.AnalysisResult, boundaries []config.Boundary) Report {
+	report := Report{Boundaries: make([]*BoundaryReport, 0, len(boundaries))}
+	if analysisResult.Stats != nil {
+		report.AnalyzedAt = analysisResult.Stats.StartedAt
+	}
+	for _, boundary := range boundaries {
+		boundaryReport := &BoundaryReport{
+			Name:       boundary.Name,
+			MemberPods: make([]types.PodRef, 0),
+			Crossings:  make([]*Crossing, 0),
+		}
+		members := make(map[types.PodRef]bool)
+		for _, pod := range analysisResult.Pods {
+			if boundary.Contains(pod.Namespace, pod.Labels) {
+				podRef := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+				members[podRef] = true
+				boundaryReport.MemberPods = append(boundaryReport.MemberPods, podRef)
+			}
+		}
+		for _, allowedRoute := range analysisResult.AllowedRoutes {
+			sourceInside, targetInside := members[allowedRoute.SourcePod], members[allowedRoute.TargetPod]
+			if sourceInside == targetInside {
+				continue
+			}
+			direction := DirectionInbound
+			if sourceInside {
+				direction = DirectionOutbound
+				boundaryReport.Outbound++
+			} else {
+				boundaryReport.Inbound++
+			}
+			boundaryReport.Crossings = append(boundaryReport.Crossings, &Crossing{
+				Direction:       direction,
+				SourcePod:       allowedRoute.SourcePod,
+				TargetPod:       allowedRoute.TargetPod,
+				Ports:           allowedRoute.Ports,
+				EgressPolicies:  allowedRoute.EgressPolicies,
+				IngressPolicies: allowedRoute.IngressPolicies,
+			})
+		}
+		sort.Slice(boundaryReport.MemberPods, func(i, j int) bool {
+			return podLess(boundaryReport.MemberPods[i], boundaryReport.MemberPods[j])
+		})
+		sort.Slice(boundaryReport.Crossings, func(i, j int) bool {
+			return crossingLess(boundaryReport.Crossings[i], boundaryReport.Crossings[j])
+		})
+		report.Boundaries = append(report.Boundaries, boundaryReport)
+	}
+	return report
+}
+
+func crossingLess(crossing1 *Crossing, crossing2 *Crossing) bool {
+	if crossing1.Direction != crossing2.Direction {
+		return crossing1.Direction < crossing2.Direction
+	}
+	if crossing1.SourcePod != crossing2.SourcePod {
+		return podLess(crossing1.SourcePod, crossing2.SourcePod)
+	}
+	return podLess(crossing1.TargetPod, crossing2.TargetPod)
+}
+
+func podLess(pod1 types.PodRef, pod2 types.PodRef) bool {
+	if pod1.Namespace != pod2.Namespace {
+		return pod1.Namespace < pod2.Namespace
+	}
+	return pod1.Name < pod2.Name
+}
@@ -0,0 +1,106 @@
+package boundary
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	analyzedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	gateway := types.PodRef{Name: "gateway", Namespace: "payments"}
+	vault := types.PodRef{Name: "vault", Namespace: "payments"}
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	checkout := types.PodRef{Name: "checkout", Namespace: "shop"}
+	monitoring := types.PodRef{Name: "prometheus", Namespace: "monitoring"}
+	gatewayPolicy := types.NetworkPolicy{Name: "gateway", Namespace: "payments"}
+	checkoutPolicy := types.NetworkPolicy{Name: "checkout", Namespace: "shop"}
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "gateway", Namespace: "payments"},
+			{Name: "vault", Namespace: "payments"},
+			{Name: "front", Namespace: "shop"},
+			{Name: "checkout", Namespace: "shop", Labels: map[string]string{"scope": "pci"}},
+			{Name: "prometheus", Namespace: "monitoring"},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: front, TargetPod: checkout, Ports: []int32{8080},
+				IngressPolicies: []types.NetworkPolicy{checkoutPolicy}},
+			{SourcePod: checkout, TargetPod: gateway, Ports: []int32{443},
+				EgressPolicies:  []types.NetworkPolicy{checkoutPolicy},
+				IngressPolicies: []types.NetworkPolicy{gatewayPolicy}},
+			{SourcePod: gateway, TargetPod: vault},
+			{SourcePod: monitoring, TargetPod: gateway, Ports: []int32{9090},
+				IngressPolicies: []types.NetworkPolicy{gatewayPolicy}},
+			{SourcePod: monitoring, TargetPod: front},
+		},
+		Stats: &types.AnalysisStats{StartedAt: analyzedAt},
+	}
+	tests := []struct {
+		name           string
+		boundaries     []config.Boundary
+		expectedReport Report
+	}{
+		{
+			name: "routes between the members of a boundary and other pods cross it",
+			boundaries: []config.Boundary{{Name: "pci", Members: []config.PodSelector{
+				{Namespace: "payments"}, {PodLabels: map[string]string{"scope": "pci"}}}}},
+			expectedReport: Report{
+				AnalyzedAt: analyzedAt,
+				Boundaries: []*BoundaryReport{
+					{
+						Name:       "pci",
+						MemberPods: []types.PodRef{gateway, vault, checkout},
+						Inbound:    2,
+						Outbound:   0,
+						Crossings: []*Crossing{
+							{Direction: DirectionInbound, SourcePod: monitoring, TargetPod: gateway,
+								Ports: []int32{9090}, IngressPolicies: []types.NetworkPolicy{gatewayPolicy}},
+							{Direction: DirectionInbound, SourcePod: front, TargetPod: checkout,
+								Ports: []int32{8080}, IngressPolicies: []types.NetworkPolicy{checkoutPolicy}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "each boundary is reported on its own",
+			boundaries: []config.Boundary{
+				{Name: "payments", Members: []config.PodSelector{{Namespace: "payments"}}},
+				{Name: "nothing", Members: []config.PodSelector{{Namespace: "unknown"}}},
+			},
+			expectedReport: Report{
+				AnalyzedAt: analyzedAt,
+				Boundaries: []*BoundaryReport{
+					{
+						Name:       "payments",
+						MemberPods: []types.PodRef{gateway, vault},
+						Inbound:    2,
+						Crossings: []*Crossing{
+							{Direction: DirectionInbound, SourcePod: monitoring, TargetPod: gateway,
+								Ports: []int32{9090}, IngressPolicies: []types.NetworkPolicy{gatewayPolicy}},
+							{Direction: DirectionInbound, SourcePod: checkout, TargetPod: gateway,
+								Ports: []int32{443}, EgressPolicies: []types.NetworkPolicy{checkoutPolicy},
+								IngressPolicies: []types.NetworkPolicy{gatewayPolicy}},
+						},
+					},
+					{
+						Name:       "nothing",
+						MemberPods: []types.PodRef{},
+						Crossings:  []*Crossing{},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Build(analysisResult, tt.boundaries)
+			if diff := cmp.Diff(tt.expectedReport, report); diff != "" {
+				t.Errorf("Build() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
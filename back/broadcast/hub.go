@@ -0,0 +1,106 @@
+package broadcast
+
+import (
+	"karto/types"
+	"log"
+	"sync"
+)
+
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered result, for subscribers only interested in the latest one
+	DropOldest DropPolicy = iota
+	// DropNewest discards the incoming result, for subscribers processing results in order
+	DropNewest
+)
+
+type SubscriberOptions struct {
+	Name       string
+	BufferSize int
+	DropPolicy DropPolicy
+}
+
+type subscriber struct {
+	options SubscriberOptions
+	channel chan types.AnalysisResult
+	dropped int
+}
+
+// Hub hands each analysis result over to all its subscribers without ever waiting for them, so that a slow
+// subscriber cannot block the analysis
+type Hub struct {
+	mutex       sync.Mutex
+	subscribers []*subscriber
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make([]*subscriber, 0),
+	}
+}
+
+func (hub *Hub) Subscribe(options SubscriberOptions) <-chan types.AnalysisResult {
+	if options.BufferSize < 1 {
+		options.BufferSize = 1
+	}
+	subscriber := &subscriber{
+		options: options,
+		channel: make(chan types.AnalysisResult, options.BufferSize),
+	}
+	hub.mutex.Lock()
+	hub.subscribers = append(hub.subscribers, subscriber)
+	hub.mutex.Unlock()
+	return subscriber.channel
+}
+
+// Unsubscribe stops handing results over to a subscription, which is not closed so that its reader can still drain it
+func (hub *Hub) Unsubscribe(subscription <-chan types.AnalysisResult) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for i, subscriber := range hub.subscribers {
+		if subscriber.channel == subscription {
+			hub.subscribers = append(hub.subscribers[:i], hub.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (hub *Hub) Run(resultsChannel <-chan types.AnalysisResult) {
+	for analysisResult := range resultsChannel {
+		hub.Publish(analysisResult)
+	}
+}
+
+func (hub *Hub) Publish(analysisResult types.AnalysisResult) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for _, subscriber := range hub.subscribers {
+		subscriber.offer(analysisResult)
+	}
+}
+
+// The hub is the only sender, so a buffer slot freed by dropping the oldest result cannot be taken by another one
+func (subscriber *subscriber) offer(analysisResult types.AnalysisResult) {
+	select {
+	case subscriber.channel <- analysisResult:
+		return
+	default:
+	}
+	subscriber.dropped++
+	if subscriber.dropped == 1 || subscriber.dropped%100 == 0 {
+		log.Printf("Subscriber %s is too slow, %d analysis results were dropped so far\n",
+			subscriber.options.Name, subscriber.dropped)
+	}
+	if subscriber.options.DropPolicy == DropNewest {
+		return
+	}
+	select {
+	case <-subscriber.channel:
+	default:
+	}
+	select {
+	case subscriber.channel <- analysisResult:
+	default:
+	}
+}
@@ -0,0 +1,67 @@
+package broadcast
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	results := make([]types.AnalysisResult, 0)
+	for _, name := range []string{"pod1", "pod2", "pod3"} {
+		results = append(results, types.AnalysisResult{Pods: []*types.Pod{{Name: name, Namespace: "ns"}}})
+	}
+	tests := []struct {
+		name            string
+		options         SubscriberOptions
+		expectedResults []types.AnalysisResult
+	}{
+		{
+			name:            "a subscriber with a large enough buffer receives all results",
+			options:         SubscriberOptions{Name: "cache", BufferSize: 3, DropPolicy: DropOldest},
+			expectedResults: results,
+		},
+		{
+			name:            "a slow subscriber dropping the oldest results receives the latest ones",
+			options:         SubscriberOptions{Name: "archive", BufferSize: 2, DropPolicy: DropOldest},
+			expectedResults: results[1:],
+		},
+		{
+			name:            "a slow subscriber dropping the newest results receives the first ones",
+			options:         SubscriberOptions{Name: "notifier", BufferSize: 2, DropPolicy: DropNewest},
+			expectedResults: results[:2],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			subscription := hub.Subscribe(tt.options)
+			// Another subscriber which never reads must not prevent the results from being published
+			hub.Subscribe(SubscriberOptions{Name: "stuck"})
+			for _, analysisResult := range results {
+				hub.Publish(analysisResult)
+			}
+			received := make([]types.AnalysisResult, 0)
+			for len(subscription) > 0 {
+				received = append(received, <-subscription)
+			}
+			if diff := cmp.Diff(tt.expectedResults, received); diff != "" {
+				t.Errorf("Received results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe(SubscriberOptions{Name: "browser"})
+	other := hub.Subscribe(SubscriberOptions{Name: "cache"})
+	hub.Unsubscribe(subscription)
+	hub.Publish(types.AnalysisResult{})
+	if len(subscription) != 0 {
+		t.Errorf("Unsubscribe() kept handing results over to the subscription")
+	}
+	if len(other) != 1 {
+		t.Errorf("Unsubscribe() stopped handing results over to other subscriptions")
+	}
+}
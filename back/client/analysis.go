@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"karto/authoring"
+	"karto/boundary"
+	"karto/explain"
+	"karto/heatmap"
+	"karto/paths"
+	"karto/reachers"
+	"karto/routediff"
+	"karto/sampling"
+	"karto/types"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const SortByRisk = "risk"
+
+// RouteOptions filter and sort the allowed routes. A zero value keeps all of them in their original order.
+type RouteOptions struct {
+	Port int32
+	// PortRange is written as <start>-<end>
+	PortRange             string
+	Sort                  string
+	GroupSystemComponents bool
+}
+
+type RouteChanges struct {
+	DetectedAt time.Time      `json:"detectedAt"`
+	Routes     routediff.Diff `json:"routes"`
+}
+
+// NamespaceRoutes are partial when computed by an analysis still in progress, in which case they are not scored yet
+type NamespaceRoutes struct {
+	Namespace     string                `json:"namespace"`
+	Partial       bool                  `json:"partial"`
+	AllowedRoutes []*types.AllowedRoute `json:"allowedRoutes"`
+}
+
+type connectivityBatch struct {
+	Queries []types.ConnectivityQuery `json:"queries"`
+}
+
+type connectivityVerdicts struct {
+	Verdicts []types.ConnectivityVerdict `json:"verdicts"`
+}
+
+func (options RouteOptions) query() url.Values {
+	query := url.Values{}
+	if options.Port != 0 {
+		query.Set("port", strconv.Itoa(int(options.Port)))
+	}
+	if options.PortRange != "" {
+		query.Set("portRange", options.PortRange)
+	}
+	if options.Sort != "" {
+		query.Set("sort", options.Sort)
+	}
+	if options.GroupSystemComponents {
+		query.Set("groupSystemComponents", "true")
+	}
+	return query
+}
+
+func (client *Client) AnalysisResult(ctx context.Context, options RouteOptions) (types.AnalysisResult, error) {
+	var analysisResult types.AnalysisResult
+	err := client.getJSON(ctx, "/api/analysisResult", options.query(), &analysisResult)
+	return analysisResult, err
+}
+
+// NamespaceRoutes returns the routes towards the pods of a namespace, as soon as the analysis in progress computed them
+func (client *Client) NamespaceRoutes(ctx context.Context, namespace string,
+	options RouteOptions) (NamespaceRoutes, error) {
+	query := options.query()
+	query.Set("namespace", namespace)
+	var namespaceRoutes NamespaceRoutes
+	err := client.getJSON(ctx, "/api/routes/namespace", query, &namespaceRoutes)
+	return namespaceRoutes, err
+}
+
+// DesiredAnalysisResult is only available when the explorer analyzes a Git repository of manifests
+func (client *Client) DesiredAnalysisResult(ctx context.Context) (types.AnalysisResult, error) {
+	var analysisResult types.AnalysisResult
+	err := client.getJSON(ctx, "/api/desired/analysisResult", nil, &analysisResult)
+	return analysisResult, err
+}
+
+func (client *Client) Drift(ctx context.Context) (types.DriftReport, error) {
+	var driftReport types.DriftReport
+	err := client.getJSON(ctx, "/api/drift", nil, &driftReport)
+	return driftReport, err
+}
+
+func (client *Client) LastRunStats(ctx context.Context) (types.AnalysisStats, error) {
+	var stats types.AnalysisStats
+	err := client.getJSON(ctx, "/api/stats/lastRun", nil, &stats)
+	return stats, err
+}
+
+func (client *Client) Summary(ctx context.Context) (types.ResultSummary, error) {
+	var summary types.ResultSummary
+	err := client.getJSON(ctx, "/api/summary", nil, &summary)
+	return summary, err
+}
+
+func (client *Client) CheckConnectivity(ctx context.Context,
+	queries []types.ConnectivityQuery) ([]types.ConnectivityVerdict, error) {
+	var response connectivityVerdicts
+	err := client.postJSON(ctx, "/api/connectivity/batch", nil, connectivityBatch{Queries: queries}, http.StatusOK,
+		&response)
+	return response.Verdicts, err
+}
+
+// FindPaths references workloads as kind/name in the default namespace or as namespace/kind/name. A zero maxHops
+// keeps the default of the explorer.
+func (client *Client) FindPaths(ctx context.Context, from string, to string, maxHops int) (paths.Result, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	if maxHops > 0 {
+		query.Set("maxHops", strconv.Itoa(maxHops))
+	}
+	var result paths.Result
+	err := client.getJSON(ctx, "/api/paths", query, &result)
+	return result, err
+}
+
+// FindReachers references the pod by name in the default namespace or as namespace/name. A zero maxHops keeps the
+// default of the explorer.
+func (client *Client) FindReachers(ctx context.Context, pod string, maxHops int) (reachers.Result, error) {
+	query := url.Values{"pod": {pod}}
+	if maxHops > 0 {
+		query.Set("maxHops", strconv.Itoa(maxHops))
+	}
+	var result reachers.Result
+	err := client.getJSON(ctx, "/api/reachers", query, &result)
+	return result, err
+}
+
+// PolicyExceptions lists the exceptions of an owner, or of any owner when empty
+func (client *Client) PolicyExceptions(ctx context.Context, owner string,
+	staleOnly bool) ([]*types.PolicyException, error) {
+	query := url.Values{}
+	if owner != "" {
+		query.Set("owner", owner)
+	}
+	if staleOnly {
+		query.Set("stale", "true")
+	}
+	exceptions := make([]*types.PolicyException, 0)
+	err := client.getJSON(ctx, "/api/exceptions", query, &exceptions)
+	return exceptions, err
+}
+
+func (client *Client) RouteChanges(ctx context.Context) ([]*RouteChanges, error) {
+	routeChanges := make([]*RouteChanges, 0)
+	err := client.getJSON(ctx, "/api/changes", nil, &routeChanges)
+	return routeChanges, err
+}
+
+// SampleRoutes returns at most size routes picked with one of the sampling strategies. A zero size keeps the default
+// of the explorer, and a zero seed samples differently on each call.
+func (client *Client) SampleRoutes(ctx context.Context, size int, strategy string,
+	seed int64) (sampling.Sample, error) {
+	query := url.Values{}
+	if size > 0 {
+		query.Set("n", strconv.Itoa(size))
+	}
+	if strategy != "" {
+		query.Set("strategy", strategy)
+	}
+	if seed != 0 {
+		query.Set("seed", strconv.FormatInt(seed, 10))
+	}
+	var sample sampling.Sample
+	err := client.getJSON(ctx, "/api/routes/sample", query, &sample)
+	return sample, err
+}
+
+func (client *Client) Heatmap(ctx context.Context, groupBy string) (heatmap.Heatmap, error) {
+	query := url.Values{}
+	if groupBy != "" {
+		query.Set("groupBy", groupBy)
+	}
+	var result heatmap.Heatmap
+	err := client.getJSON(ctx, "/api/heatmap", query, &result)
+	return result, err
+}
+
+func (client *Client) BoundaryCrossings(ctx context.Context, boundaryName string) (boundary.Report, error) {
+	query := url.Values{}
+	if boundaryName != "" {
+		query.Set("boundary", boundaryName)
+	}
+	var report boundary.Report
+	err := client.getJSON(ctx, "/api/boundaries", query, &report)
+	return report, err
+}
+
+func (client *Client) SuggestPolicies(ctx context.Context, request authoring.Request) (authoring.Suggestion, error) {
+	var suggestion authoring.Suggestion
+	err := client.postJSON(ctx, "/api/authoring/suggest", nil, request, http.StatusOK, &suggestion)
+	return suggestion, err
+}
+
+func (client *Client) OnboardNamespace(ctx context.Context, namespace string) (authoring.Suggestion, error) {
+	var suggestion authoring.Suggestion
+	err := client.getJSON(ctx, "/api/authoring/onboarding", url.Values{"namespace": {namespace}}, &suggestion)
+	return suggestion, err
+}
+
+func (client *Client) TighteningSuggestions(ctx context.Context,
+	namespace string) ([]*types.TighteningSuggestion, error) {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	suggestions := make([]*types.TighteningSuggestion, 0)
+	err := client.getJSON(ctx, "/api/suggestions/tightening", query, &suggestions)
+	return suggestions, err
+}
+
+// ExplainPolicy accepts the manifest of a single network policy, in YAML or JSON
+func (client *Client) ExplainPolicy(ctx context.Context, policyManifest []byte) (explain.Explanation, error) {
+	request, err := client.newRequest(ctx, http.MethodPost, "/api/explain/policy", nil,
+		bytes.NewReader(policyManifest), contentTypeYAML)
+	if err != nil {
+		return explain.Explanation{}, err
+	}
+	var explanation explain.Explanation
+	err = client.doJSON(request, http.StatusOK, &explanation)
+	return explanation, err
+}
+
+func (client *Client) SimulateDeletion(ctx context.Context, selector string) (explain.DeletionImpact, error) {
+	var impact explain.DeletionImpact
+	err := client.getJSON(ctx, "/api/simulate/deleteAll", url.Values{"selector": {selector}}, &impact)
+	return impact, err
+}
+
+func (client *Client) SimulateDisruption(ctx context.Context, namespace string, name string) (
+	explain.DisruptionImpact, error) {
+	var impact explain.DisruptionImpact
+	err := client.getJSON(ctx, "/api/simulate/disruption", url.Values{"deployment": {namespace + "/" + name}},
+		&impact)
+	return impact, err
+}
+
+// ExplainRoute tells which policy rules decide the traffic from the source pod to the port of the target pod, 0
+// standing for any port
+func (client *Client) ExplainRoute(ctx context.Context, sourcePod types.PodRef, targetPod types.PodRef, port int32,
+	protocol string) (explain.RouteExplanation, error) {
+	query := url.Values{
+		"source":   {sourcePod.Namespace + "/" + sourcePod.Name},
+		"target":   {targetPod.Namespace + "/" + targetPod.Name},
+		"protocol": {protocol},
+	}
+	if port != 0 {
+		query.Set("port", strconv.Itoa(int(port)))
+	}
+	var explanation explain.RouteExplanation
+	err := client.getJSON(ctx, "/api/explain/route", query, &explanation)
+	return explanation, err
+}
+
+// RemediationOverlays returns the gzipped tarball of kustomize overlays, which the caller must close
+func (client *Client) RemediationOverlays(ctx context.Context, namespace string) (io.ReadCloser, error) {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	request, err := client.newRequest(ctx, http.MethodGet, "/api/remediations/overlay", query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeYAML = "application/yaml"
+)
+
+type Options struct {
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Token is sent as a bearer token, so that a rate limited client is identified by it rather than by its IP
+	Token string
+}
+
+// Client queries the API of a running Karto. All its methods are safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	token      string
+}
+
+// Error is returned when the API answers with an unexpected status code
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("karto API answered %d: %s", err.StatusCode, err.Message)
+}
+
+func New(baseURL string, options Options) (*Client, error) {
+	parsedURL, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %s, expected an http or https URL", baseURL)
+	}
+	httpClient := options.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    parsedURL,
+		httpClient: httpClient,
+		token:      options.Token,
+	}, nil
+}
+
+func (client *Client) newRequest(ctx context.Context, method string, path string, query url.Values,
+	body io.Reader, contentType string) (*http.Request, error) {
+	requestURL := *client.baseURL
+	requestURL.Path = client.baseURL.Path + path
+	if query != nil {
+		requestURL.RawQuery = query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if client.token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.token)
+	}
+	return request, nil
+}
+
+// send returns the response when its status code is the expected one, its body being left to the caller
+func (client *Client) send(request *http.Request, expectedStatusCode int) (*http.Response, error) {
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != expectedStatusCode {
+		defer closeBody(response)
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		return nil, &Error{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return response, nil
+}
+
+func (client *Client) getJSON(ctx context.Context, path string, query url.Values, result interface{}) error {
+	request, err := client.newRequest(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+	return client.doJSON(request, http.StatusOK, result)
+}
+
+func (client *Client) postJSON(ctx context.Context, path string, query url.Values, body interface{},
+	expectedStatusCode int, result interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := client.newRequest(ctx, http.MethodPost, path, query, bytes.NewReader(content), contentTypeJSON)
+	if err != nil {
+		return err
+	}
+	return client.doJSON(request, expectedStatusCode, result)
+}
+
+func (client *Client) doJSON(request *http.Request, expectedStatusCode int, result interface{}) error {
+	request.Header.Set("Accept", contentTypeJSON)
+	response, err := client.send(request, expectedStatusCode)
+	if err != nil {
+		return err
+	}
+	defer closeBody(response)
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (client *Client) delete(ctx context.Context, path string) error {
+	request, err := client.newRequest(ctx, http.MethodDelete, path, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	response, err := client.send(request, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	closeBody(response)
+	return nil
+}
+
+func closeBody(response *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+}
@@ -0,0 +1,359 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"karto/exportjob"
+	"karto/sampling"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type recordedRequest struct {
+	method        string
+	path          string
+	query         string
+	authorization string
+	contentType   string
+	body          string
+}
+
+type stubResponse struct {
+	statusCode int
+	body       string
+}
+
+func newStubServer(t *testing.T, response stubResponse, recorded *recordedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*recorded = recordedRequest{
+			method:        r.Method,
+			path:          r.URL.Path,
+			query:         r.URL.RawQuery,
+			authorization: r.Header.Get("Authorization"),
+			contentType:   r.Header.Get("Content-Type"),
+			body:          string(body),
+		}
+		w.WriteHeader(response.statusCode)
+		_, _ = w.Write([]byte(response.body))
+	}))
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{name: "http URL", baseURL: "http://karto:8000", wantErr: false},
+		{name: "https URL with a path", baseURL: "https://tools.example.com/karto/", wantErr: false},
+		{name: "URL without scheme", baseURL: "karto:8000", wantErr: true},
+		{name: "URL of another scheme", baseURL: "ftp://karto", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.baseURL, Options{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	expiresAt := time.Date(2021, time.May, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		call            func(client *Client) (interface{}, error)
+		response        stubResponse
+		expectedRequest recordedRequest
+		expected        interface{}
+		expectedErr     error
+	}{
+		{
+			name: "analysis result with route options",
+			call: func(client *Client) (interface{}, error) {
+				return client.AnalysisResult(context.Background(),
+					RouteOptions{Port: 443, Sort: SortByRisk, GroupSystemComponents: true})
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `{"pods":[{"name":"pod1","namespace":"ns"}]}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/analysisResult",
+				query: "groupSystemComponents=true&port=443&sort=risk", authorization: "Bearer secret"},
+			expected: types.AnalysisResult{
+				Pods: []*types.Pod{{Name: "pod1", Namespace: "ns"}},
+			},
+		},
+		{
+			name: "partial routes towards a namespace",
+			call: func(client *Client) (interface{}, error) {
+				return client.NamespaceRoutes(context.Background(), "shop", RouteOptions{Sort: SortByRisk})
+			},
+			response: stubResponse{statusCode: http.StatusOK,
+				body: `{"namespace":"shop","partial":true,"allowedRoutes":[]}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/routes/namespace",
+				query: "namespace=shop&sort=risk", authorization: "Bearer secret"},
+			expected: NamespaceRoutes{Namespace: "shop", Partial: true, AllowedRoutes: []*types.AllowedRoute{}},
+		},
+		{
+			name: "summary of the last analysis",
+			call: func(client *Client) (interface{}, error) {
+				return client.Summary(context.Background())
+			},
+			response: stubResponse{statusCode: http.StatusOK,
+				body: `{"pods":2,"allowedRoutes":1,"findingsBySeverity":{"high":1}}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/summary",
+				authorization: "Bearer secret"},
+			expected: types.ResultSummary{Pods: 2, AllowedRoutes: 1, FindingsBySeverity: map[string]int{"high": 1}},
+		},
+		{
+			name: "connectivity batch",
+			call: func(client *Client) (interface{}, error) {
+				return client.CheckConnectivity(context.Background(), []types.ConnectivityQuery{
+					{SourcePod: types.PodRef{Name: "pod1", Namespace: "ns"},
+						TargetPod: types.PodRef{Name: "pod2", Namespace: "ns"}, Port: 80, Protocol: corev1.ProtocolTCP},
+				})
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `{"verdicts":[{"allowed":true}]}`},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/connectivity/batch",
+				authorization: "Bearer secret", contentType: contentTypeJSON,
+				body: `{"queries":[{"sourcePod":{"name":"pod1","namespace":"ns"},` +
+					`"targetPod":{"name":"pod2","namespace":"ns"},"port":80,"protocol":"TCP"}]}`},
+			expected: []types.ConnectivityVerdict{{Allowed: true}},
+		},
+		{
+			name: "paths with the default maximum of hops",
+			call: func(client *Client) (interface{}, error) {
+				result, err := client.FindPaths(context.Background(), "deployment/front", "shop/deployment/db", 0)
+				return len(result.Paths), err
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `{"paths":[]}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/paths",
+				query: "from=deployment%2Ffront&to=shop%2Fdeployment%2Fdb", authorization: "Bearer secret"},
+			expected: 0,
+		},
+		{
+			name: "reachers within a maximum of hops",
+			call: func(client *Client) (interface{}, error) {
+				result, err := client.FindReachers(context.Background(), "shop/db-0", 1)
+				return len(result.Reachers), err
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `{"reachers":[{"hops":1}]}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/reachers",
+				query: "maxHops=1&pod=shop%2Fdb-0", authorization: "Bearer secret"},
+			expected: 1,
+		},
+		{
+			name: "stale exceptions of an owner",
+			call: func(client *Client) (interface{}, error) {
+				exceptions, err := client.PolicyExceptions(context.Background(), "payments", true)
+				return len(exceptions), err
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `[{"owner":"payments","stale":true}]`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/exceptions",
+				query: "owner=payments&stale=true", authorization: "Bearer secret"},
+			expected: 1,
+		},
+		{
+			name: "crossings of a trust boundary",
+			call: func(client *Client) (interface{}, error) {
+				report, err := client.BoundaryCrossings(context.Background(), "pci")
+				return len(report.Boundaries), err
+			},
+			response: stubResponse{statusCode: http.StatusOK,
+				body: `{"boundaries":[{"name":"pci","inbound":1,"outbound":0,"crossings":[]}]}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/boundaries",
+				query: "boundary=pci", authorization: "Bearer secret"},
+			expected: 1,
+		},
+		{
+			name: "random route sample",
+			call: func(client *Client) (interface{}, error) {
+				return client.SampleRoutes(context.Background(), 100, sampling.StrategyRandom, 42)
+			},
+			response: stubResponse{statusCode: http.StatusOK,
+				body: `{"strategy":"random","routes":[],"summary":{"totalRoutes":0}}`},
+			expectedRequest: recordedRequest{method: http.MethodGet, path: "/api/routes/sample",
+				query: "n=100&seed=42&strategy=random", authorization: "Bearer secret"},
+			expected: sampling.Sample{Strategy: "random", Routes: []*types.AllowedRoute{}},
+		},
+		{
+			name: "export start",
+			call: func(client *Client) (interface{}, error) {
+				return client.StartExport(context.Background(), ExportFormatParquetRoutes)
+			},
+			response: stubResponse{statusCode: http.StatusAccepted,
+				body: `{"id":"abc","format":"parquetRoutes","status":"running","progress":0}`},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/exports",
+				authorization: "Bearer secret", contentType: contentTypeJSON, body: `{"format":"parquetRoutes"}`},
+			expected: exportjob.Job{ID: "abc", Format: "parquetRoutes", Status: exportjob.StatusRunning},
+		},
+		{
+			name: "report export start with its format",
+			call: func(client *Client) (interface{}, error) {
+				return client.StartReportExport(context.Background(),
+					ReportFormat{TimeZone: "Europe/Paris", Numbers: "grouped"})
+			},
+			response: stubResponse{statusCode: http.StatusAccepted,
+				body: `{"id":"abc","format":"report","status":"running","progress":0}`},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/exports",
+				authorization: "Bearer secret", contentType: contentTypeJSON,
+				body: `{"format":"report","timeZone":"Europe/Paris","numbers":"grouped"}`},
+			expected: exportjob.Job{ID: "abc", Format: "report", Status: exportjob.StatusRunning},
+		},
+		{
+			name: "suppression creation",
+			call: func(client *Client) (interface{}, error) {
+				return client.CreateSuppression(context.Background(), "abc", "accepted risk", expiresAt)
+			},
+			response: stubResponse{statusCode: http.StatusCreated,
+				body: `{"fingerprint":"abc","reason":"accepted risk","expiresAt":"2021-05-01T00:00:00Z"}`},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/findings/suppressions",
+				authorization: "Bearer secret", contentType: contentTypeJSON,
+				body: `{"fingerprint":"abc","reason":"accepted risk","expiresAt":"2021-05-01T00:00:00Z"}`},
+			expected: &types.FindingSuppression{Fingerprint: "abc", Reason: "accepted risk", ExpiresAt: expiresAt},
+		},
+		{
+			name: "view deletion escapes the name",
+			call: func(client *Client) (interface{}, error) {
+				return nil, client.DeleteView(context.Background(), "my view")
+			},
+			response: stubResponse{statusCode: http.StatusNoContent},
+			expectedRequest: recordedRequest{method: http.MethodDelete, path: "/api/views/my view",
+				authorization: "Bearer secret"},
+			expected: nil,
+		},
+		{
+			name: "configuration import with replacement",
+			call: func(client *Client) (interface{}, error) {
+				return client.ImportConfiguration(context.Background(), Configuration{}, true)
+			},
+			response: stubResponse{statusCode: http.StatusOK, body: `{"views":0,"suppressions":0,"ignored":[]}`},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/configuration", query: "replace=true",
+				authorization: "Bearer secret", contentType: contentTypeJSON,
+				body: `{"views":null,"suppressions":null,"rules":null,"intents":null}`},
+			expected: ConfigurationImport{Ignored: []string{}},
+		},
+		{
+			name: "analysis refresh",
+			call: func(client *Client) (interface{}, error) {
+				return nil, client.Refresh(context.Background())
+			},
+			response:        stubResponse{statusCode: http.StatusAccepted},
+			expectedRequest: recordedRequest{method: http.MethodPost, path: "/api/refresh", authorization: "Bearer secret"},
+			expected:        nil,
+		},
+		{
+			name: "unexpected status code",
+			call: func(client *Client) (interface{}, error) {
+				return nil, client.DeleteSuppression(context.Background(), "unknown")
+			},
+			response: stubResponse{statusCode: http.StatusNotFound, body: "404 page not found\n"},
+			expectedRequest: recordedRequest{method: http.MethodDelete, path: "/api/findings/suppressions/unknown",
+				authorization: "Bearer secret"},
+			expected:    nil,
+			expectedErr: &Error{StatusCode: http.StatusNotFound, Message: "404 page not found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded recordedRequest
+			server := newStubServer(t, tt.response, &recorded)
+			defer server.Close()
+			client, err := New(server.URL, Options{Token: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tt.call(client)
+			if diff := cmp.Diff(tt.expectedErr, err, cmp.Comparer(sameError)); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRequest, recorded, cmp.AllowUnexported(recordedRequest{})); diff != "" {
+				t.Errorf("request mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, result); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientExportRoutes(t *testing.T) {
+	var recorded recordedRequest
+	body := `{"sourcePod":{"name":"pod1","namespace":"ns"},"targetPod":{"name":"pod2","namespace":"ns"}}` + "\n" +
+		`{"sourcePod":{"name":"pod2","namespace":"ns"},"targetPod":{"name":"pod3","namespace":"ns"}}` + "\n"
+	server := newStubServer(t, stubResponse{statusCode: http.StatusOK, body: body}, &recorded)
+	defer server.Close()
+	client, err := New(server.URL+"/", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := make([]string, 0)
+	err = client.ExportRoutes(context.Background(), RouteOptions{PortRange: "80-90"}, Page{Offset: 10, Limit: 2},
+		func(allowedRoute *types.AllowedRoute) error {
+			targets = append(targets, allowedRoute.TargetPod.Name)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRequest := recordedRequest{method: http.MethodGet, path: "/api/export/ndjson",
+		query: "limit=2&offset=10&portRange=80-90"}
+	if diff := cmp.Diff(expectedRequest, recorded, cmp.AllowUnexported(recordedRequest{})); diff != "" {
+		t.Errorf("request mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"pod2", "pod3"}, targets); diff != "" {
+		t.Errorf("ExportRoutes() streamed routes mismatch (-want +got):\n%s", diff)
+	}
+	stop := errors.New("stop")
+	count := 0
+	err = client.ExportRoutes(context.Background(), RouteOptions{}, Page{},
+		func(allowedRoute *types.AllowedRoute) error {
+			count++
+			return stop
+		})
+	if err != stop || count != 1 {
+		t.Errorf("ExportRoutes() = %v after %d routes, expected to stop after the first route", err, count)
+	}
+}
+
+func TestClientWatchResults(t *testing.T) {
+	var recorded recordedRequest
+	body := "event: analysisResult\ndata: {\"progress\":50}\n\n" +
+		"event: analysisResult\ndata: {\"complete\":true,\"progress\":100}\n\n"
+	server := newStubServer(t, stubResponse{statusCode: http.StatusOK, body: body}, &recorded)
+	defer server.Close()
+	client, err := New(server.URL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progresses := make([]int, 0)
+	err = client.WatchResults(context.Background(), func(analysisResult types.AnalysisResult) error {
+		progresses = append(progresses, analysisResult.Progress)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("/api/events", recorded.path); diff != "" {
+		t.Errorf("WatchResults() path mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{50, 100}, progresses); diff != "" {
+		t.Errorf("WatchResults() received results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func sameError(err1 error, err2 error) bool {
+	if err1 == nil || err2 == nil {
+		return err1 == err2
+	}
+	return err1.Error() == err2.Error()
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"io"
+	"karto/exportjob"
+	"net/http"
+)
+
+const (
+	exportJobsPath = "/api/exports"
+
+	ExportFormatNDJSON          = "ndjson"
+	ExportFormatParquetRoutes   = "parquetRoutes"
+	ExportFormatParquetFindings = "parquetFindings"
+	ExportFormatReport          = "report"
+	ExportFormatRemediations    = "remediations"
+)
+
+type exportJobRequest struct {
+	Format string `json:"format"`
+	ReportFormat
+}
+
+// ReportFormat localizes the dates and numbers of a report export, such as for stakeholders of another region
+type ReportFormat struct {
+	// TimeZone is a name of the IANA database, such as Europe/Paris
+	TimeZone string `json:"timeZone,omitempty"`
+	// DateLayout is a Go time layout, such as 02/01/2006 15:04 MST
+	DateLayout string `json:"dateLayout,omitempty"`
+	// Numbers is exact, grouped or compact, such as 12345, 12,345 or 12.3k
+	Numbers            string `json:"numbers,omitempty"`
+	ThousandsSeparator string `json:"thousandsSeparator,omitempty"`
+}
+
+// StartExport runs an export in the background on the current analysis result. Jobs are only visible with the token
+// which started them.
+func (client *Client) StartExport(ctx context.Context, format string) (exportjob.Job, error) {
+	var job exportjob.Job
+	err := client.postJSON(ctx, exportJobsPath, nil, exportJobRequest{Format: format}, http.StatusAccepted, &job)
+	return job, err
+}
+
+// StartReportExport runs an export of the text report with its dates and numbers formatted as requested
+func (client *Client) StartReportExport(ctx context.Context, reportFormat ReportFormat) (exportjob.Job, error) {
+	var job exportjob.Job
+	err := client.postJSON(ctx, exportJobsPath, nil, exportJobRequest{Format: ExportFormatReport,
+		ReportFormat: reportFormat}, http.StatusAccepted, &job)
+	return job, err
+}
+
+func (client *Client) ExportJobs(ctx context.Context) ([]exportjob.Job, error) {
+	jobs := make([]exportjob.Job, 0)
+	err := client.getJSON(ctx, exportJobsPath, nil, &jobs)
+	return jobs, err
+}
+
+func (client *Client) ExportJob(ctx context.Context, id string) (exportjob.Job, error) {
+	var job exportjob.Job
+	err := client.getJSON(ctx, exportJobsPath+"/"+id, nil, &job)
+	return job, err
+}
+
+// DownloadExport returns the output of a succeeded job, which the caller must close
+func (client *Client) DownloadExport(ctx context.Context, id string) (io.ReadCloser, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, exportJobsPath+"/"+id+"/download", nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// CancelExport stops a running job, or forgets a finished one
+func (client *Client) CancelExport(ctx context.Context, id string) error {
+	return client.delete(ctx, exportJobsPath+"/"+id)
+}
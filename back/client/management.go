@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"karto/config"
+	"karto/types"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	viewsPath         = "/api/views"
+	suppressionsPath  = "/api/findings/suppressions"
+	configurationPath = "/api/configuration"
+)
+
+// Configuration is the backup of the saved views, the suppressions, the rules and the intents of the explorer
+type Configuration struct {
+	Views        []*types.SavedView          `json:"views"`
+	Suppressions []*types.FindingSuppression `json:"suppressions"`
+	Rules        []config.Rule               `json:"rules"`
+	Intents      []config.Intent             `json:"intents"`
+}
+
+// ConfigurationImport counts the restored views and suppressions. Rules and intents are read from the configuration
+// file of the explorer, they are reported as ignored when they differ from the running ones.
+type ConfigurationImport struct {
+	Views        int      `json:"views"`
+	Suppressions int      `json:"suppressions"`
+	Ignored      []string `json:"ignored"`
+}
+
+type viewRequest struct {
+	Name     string          `json:"name"`
+	Controls json.RawMessage `json:"controls"`
+}
+
+type suppressionRequest struct {
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func (client *Client) ListViews(ctx context.Context) ([]*types.SavedView, error) {
+	views := make([]*types.SavedView, 0)
+	err := client.getJSON(ctx, viewsPath, nil, &views)
+	return views, err
+}
+
+func (client *Client) SaveView(ctx context.Context, name string, controls json.RawMessage) (*types.SavedView, error) {
+	view := &types.SavedView{}
+	err := client.postJSON(ctx, viewsPath, nil, viewRequest{Name: name, Controls: controls}, http.StatusCreated, view)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func (client *Client) DeleteView(ctx context.Context, name string) error {
+	return client.delete(ctx, viewsPath+"/"+name)
+}
+
+func (client *Client) ListSuppressions(ctx context.Context) ([]*types.FindingSuppression, error) {
+	suppressions := make([]*types.FindingSuppression, 0)
+	err := client.getJSON(ctx, suppressionsPath, nil, &suppressions)
+	return suppressions, err
+}
+
+func (client *Client) CreateSuppression(ctx context.Context, fingerprint string, reason string,
+	expiresAt time.Time) (*types.FindingSuppression, error) {
+	request := suppressionRequest{Fingerprint: fingerprint, Reason: reason, ExpiresAt: expiresAt}
+	suppression := &types.FindingSuppression{}
+	err := client.postJSON(ctx, suppressionsPath, nil, request, http.StatusCreated, suppression)
+	if err != nil {
+		return nil, err
+	}
+	return suppression, nil
+}
+
+func (client *Client) DeleteSuppression(ctx context.Context, fingerprint string) error {
+	return client.delete(ctx, suppressionsPath+"/"+fingerprint)
+}
+
+func (client *Client) ExportConfiguration(ctx context.Context) (Configuration, error) {
+	var configuration Configuration
+	err := client.getJSON(ctx, configurationPath, nil, &configuration)
+	return configuration, err
+}
+
+// ImportConfiguration restores views and suppressions. With replace, those missing from the configuration are deleted.
+func (client *Client) ImportConfiguration(ctx context.Context, configuration Configuration,
+	replace bool) (ConfigurationImport, error) {
+	query := url.Values{}
+	if replace {
+		query.Set("replace", "true")
+	}
+	var result ConfigurationImport
+	err := client.postJSON(ctx, configurationPath, query, configuration, http.StatusOK, &result)
+	return result, err
+}
+
+// Refresh requests an analysis of the latest cluster state, whose result is available once it completes
+func (client *Client) Refresh(ctx context.Context) error {
+	request, err := client.newRequest(ctx, http.MethodPost, "/api/refresh", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	response, err := client.send(request, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	closeBody(response)
+	return nil
+}
+
+// Health returns nil when the explorer is up
+func (client *Client) Health(ctx context.Context) error {
+	request, err := client.newRequest(ctx, http.MethodGet, "/health", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	closeBody(response)
+	return nil
+}
+
+// Metrics returns the metrics in the text format of Prometheus, they are only exposed when enabled on the explorer
+func (client *Client) Metrics(ctx context.Context) (string, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, "/metrics", nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(response)
+	text, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
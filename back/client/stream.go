@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"karto/types"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const ndjsonPath = "/api/export/ndjson"
+
+// Page selects a part of a streamed export. A zero limit streams all items from the offset.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+func (page Page) query() url.Values {
+	query := url.Values{}
+	if page.Offset > 0 {
+		query.Set("offset", strconv.Itoa(page.Offset))
+	}
+	if page.Limit > 0 {
+		query.Set("limit", strconv.Itoa(page.Limit))
+	}
+	return query
+}
+
+// ExportRoutes calls handle with each allowed route as it is streamed, without loading all of them in memory. It
+// stops at the first error returned by handle.
+func (client *Client) ExportRoutes(ctx context.Context, options RouteOptions, page Page,
+	handle func(allowedRoute *types.AllowedRoute) error) error {
+	query := options.query()
+	for key, values := range page.query() {
+		query[key] = values
+	}
+	return client.stream(ctx, ndjsonPath, query, func(decoder *json.Decoder) error {
+		allowedRoute := &types.AllowedRoute{}
+		err := decoder.Decode(allowedRoute)
+		if err != nil {
+			return err
+		}
+		return handle(allowedRoute)
+	})
+}
+
+func (client *Client) ExportPods(ctx context.Context, page Page, handle func(pod *types.Pod) error) error {
+	return client.stream(ctx, ndjsonPath+"/pods", page.query(), func(decoder *json.Decoder) error {
+		pod := &types.Pod{}
+		err := decoder.Decode(pod)
+		if err != nil {
+			return err
+		}
+		return handle(pod)
+	})
+}
+
+func (client *Client) ExportServices(ctx context.Context, page Page, handle func(service *types.Service) error) error {
+	return client.stream(ctx, ndjsonPath+"/services", page.query(), func(decoder *json.Decoder) error {
+		service := &types.Service{}
+		err := decoder.Decode(service)
+		if err != nil {
+			return err
+		}
+		return handle(service)
+	})
+}
+
+func (client *Client) ExportPolicies(ctx context.Context, page Page,
+	handle func(policy *types.NetworkPolicy) error) error {
+	return client.stream(ctx, ndjsonPath+"/policies", page.query(), func(decoder *json.Decoder) error {
+		policy := &types.NetworkPolicy{}
+		err := decoder.Decode(policy)
+		if err != nil {
+			return err
+		}
+		return handle(policy)
+	})
+}
+
+// WatchResults calls handle with the last analysis result, then with each new one, until ctx is done or handle
+// returns an error. A slow handler misses intermediate results rather than delaying the explorer.
+func (client *Client) WatchResults(ctx context.Context, handle func(analysisResult types.AnalysisResult) error) error {
+	request, err := client.newRequest(ctx, http.MethodGet, "/api/events", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	// The stream never ends, so it is closed without being drained
+	defer func() { _ = response.Body.Close() }()
+	reader := bufio.NewReader(response.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var analysisResult types.AnalysisResult
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &analysisResult)
+		if err != nil {
+			return err
+		}
+		err = handle(analysisResult)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (client *Client) stream(ctx context.Context, path string, query url.Values,
+	decodeNext func(decoder *json.Decoder) error) error {
+	request, err := client.newRequest(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+	response, err := client.send(request, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer closeBody(response)
+	decoder := json.NewDecoder(response.Body)
+	for {
+		err = decodeNext(decoder)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
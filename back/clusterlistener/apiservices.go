@@ -0,0 +1,47 @@
+package clusterlistener
+
+import (
+	"context"
+	"encoding/json"
+	"k8s.io/client-go/kubernetes"
+	"karto/types"
+	"log"
+)
+
+const apiServicesPath = "/apis/apiregistration.k8s.io/v1/apiservices"
+
+type apiServiceList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Service *types.ServiceRef `json:"service"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// The aggregated API services are listed through the raw REST client, client-go having no typed client for them.
+// They are only needed by a finding, so the analysis goes on without them when they cannot be listed.
+func listAPIServices(ctx context.Context, k8sClient kubernetes.Interface) []*types.APIService {
+	restClient := k8sClient.Discovery().RESTClient()
+	if restClient == nil {
+		return nil
+	}
+	content, err := restClient.Get().AbsPath(apiServicesPath).DoRaw(ctx)
+	if err != nil {
+		log.Printf("Unable to list the aggregated API services: %s\n", err)
+		return nil
+	}
+	var list apiServiceList
+	err = json.Unmarshal(content, &list)
+	if err != nil {
+		log.Printf("Unable to decode the aggregated API services: %s\n", err)
+		return nil
+	}
+	apiServices := make([]*types.APIService, 0)
+	for _, item := range list.Items {
+		apiServices = append(apiServices, &types.APIService{Name: item.Metadata.Name, Service: item.Spec.Service})
+	}
+	return apiServices
+}
@@ -1,103 +1,370 @@
 package clusterlistener
 
 import (
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
+	admissionregistrationinformers "k8s.io/client-go/informers/admissionregistration/v1"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	networkinginformersv1beta1 "k8s.io/client-go/informers/networking/v1beta1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/workqueue"
+	"karto/metrics"
 	"karto/types"
 	"log"
+	"reflect"
+	"sync/atomic"
 )
 
-func Listen(k8sConfigPath string, clusterStateChannel chan<- types.ClusterState) {
-	k8sClient := getK8sClient(k8sConfigPath)
+// Snapshots are listed again when a watch received a change while they were listed, at most this number of times
+const maxSnapshotAttempts = 5
+
+// clusterInformers are the informers whose caches make up the cluster state
+type clusterInformers struct {
+	namespaces         coreinformers.NamespaceInformer
+	nodes              coreinformers.NodeInformer
+	pods               coreinformers.PodInformer
+	services           coreinformers.ServiceInformer
+	ingresses          networkinginformersv1beta1.IngressInformer
+	replicaSets        appsinformers.ReplicaSetInformer
+	statefulSets       appsinformers.StatefulSetInformer
+	daemonSets         appsinformers.DaemonSetInformer
+	deployments        appsinformers.DeploymentInformer
+	policies           networkinginformers.NetworkPolicyInformer
+	validatingWebhooks admissionregistrationinformers.ValidatingWebhookConfigurationInformer
+	mutatingWebhooks   admissionregistrationinformers.MutatingWebhookConfigurationInformer
+	// orderedPolicies are the informers of the Calico and Cilium policies which can be watched, by kind
+	orderedPolicies map[string]orderedPolicyInformer
+	// events counts the changes notified by the informers
+	events *uint64
+}
+
+func Listen(k8sClient kubernetes.Interface, dynamicClient dynamic.Interface, policyChurn *metrics.PolicyChurn,
+	dataFreshness *metrics.DataFreshness, clusterStateChannel chan<- types.ClusterState) {
+	serverVersion, apiGroups := discoverServer(k8sClient)
 	analyzeQueue := workqueue.NewRateLimitingQueue(workqueue.DefaultItemBasedRateLimiter())
 	informerFactory := informers.NewSharedInformerFactory(k8sClient, 0)
-	namespacesInformer := informerFactory.Core().V1().Namespaces()
-	podInformer := informerFactory.Core().V1().Pods()
-	servicesInformer := informerFactory.Core().V1().Services()
-	ingressInformer := informerFactory.Networking().V1beta1().Ingresses()
-	replicaSetsInformer := informerFactory.Apps().V1().ReplicaSets()
-	statefulSetsInformer := informerFactory.Apps().V1().StatefulSets()
-	daemonSetsInformer := informerFactory.Apps().V1().DaemonSets()
-	deploymentsInformer := informerFactory.Apps().V1().Deployments()
-	policiesInformer := informerFactory.Networking().V1().NetworkPolicies()
+	dynamicInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	clusterInformers := newClusterInformers(informerFactory)
+	clusterInformers.orderedPolicies = newOrderedPolicyInformers(context.Background(), dynamicClient,
+		dynamicInformerFactory, apiGroups)
 	eventHandler := cache.ResourceEventHandlerFuncs{
-		AddFunc:    func(obj interface{}) { analyzeQueue.Add(nil) },
-		UpdateFunc: func(oldObj, newObj interface{}) { analyzeQueue.Add(nil) },
-		DeleteFunc: func(obj interface{}) { analyzeQueue.Add(nil) },
-	}
-	namespacesInformer.Informer().AddEventHandler(eventHandler)
-	podInformer.Informer().AddEventHandler(eventHandler)
-	servicesInformer.Informer().AddEventHandler(eventHandler)
-	ingressInformer.Informer().AddEventHandler(eventHandler)
-	replicaSetsInformer.Informer().AddEventHandler(eventHandler)
-	statefulSetsInformer.Informer().AddEventHandler(eventHandler)
-	daemonSetsInformer.Informer().AddEventHandler(eventHandler)
-	deploymentsInformer.Informer().AddEventHandler(eventHandler)
-	policiesInformer.Informer().AddEventHandler(eventHandler)
+		AddFunc:    func(obj interface{}) { clusterInformers.notify(analyzeQueue) },
+		UpdateFunc: func(oldObj, newObj interface{}) { clusterInformers.notify(analyzeQueue) },
+		DeleteFunc: func(obj interface{}) { clusterInformers.notify(analyzeQueue) },
+	}
+	for kind, informer := range clusterInformers.informers() {
+		informer.AddEventHandler(eventHandler)
+		if dataFreshness != nil {
+			err := dataFreshness.Track(kind, informer)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+	if policyChurn != nil {
+		clusterInformers.policies.Informer().AddEventHandler(policyChurn.EventHandler())
+	}
 	informerFactory.Start(wait.NeverStop)
+	dynamicInformerFactory.Start(wait.NeverStop)
 	informerFactory.WaitForCacheSync(wait.NeverStop)
+	dynamicInformerFactory.WaitForCacheSync(wait.NeverStop)
 	for {
 		obj, _ := analyzeQueue.Get()
-		namespaces, err := namespacesInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		pods, err := podInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		services, err := servicesInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		ingresses, err := ingressInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		replicaSets, err := replicaSetsInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		statefulSets, err := statefulSetsInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		daemonSets, err := daemonSetsInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		deployments, err := deploymentsInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		policies, err := policiesInformer.Lister().List(labels.Everything())
-		if err != nil {
-			panic(err.Error())
-		}
-		clusterStateChannel <- types.ClusterState{
-			Namespaces:      namespaces,
-			Pods:            pods,
-			Services:        services,
-			Ingresses:       ingresses,
-			ReplicaSets:     replicaSets,
-			StatefulSets:    statefulSets,
-			DaemonSets:      daemonSets,
-			Deployments:     deployments,
-			NetworkPolicies: policies,
-		}
+		clusterState := clusterInformers.snapshot()
+		clusterState.ServerVersion = serverVersion
+		clusterState.APIGroups = apiGroups
+		clusterState.APIServices = listAPIServices(context.Background(), k8sClient)
+		clusterStateChannel <- clusterState
 		analyzeQueue.Forget(obj)
 		analyzeQueue.Done(obj)
 	}
 }
 
-func getK8sClient(k8sClientConfig string) *kubernetes.Clientset {
+func newClusterInformers(informerFactory informers.SharedInformerFactory) clusterInformers {
+	return clusterInformers{
+		namespaces:         informerFactory.Core().V1().Namespaces(),
+		nodes:              informerFactory.Core().V1().Nodes(),
+		pods:               informerFactory.Core().V1().Pods(),
+		services:           informerFactory.Core().V1().Services(),
+		ingresses:          informerFactory.Networking().V1beta1().Ingresses(),
+		replicaSets:        informerFactory.Apps().V1().ReplicaSets(),
+		statefulSets:       informerFactory.Apps().V1().StatefulSets(),
+		daemonSets:         informerFactory.Apps().V1().DaemonSets(),
+		deployments:        informerFactory.Apps().V1().Deployments(),
+		policies:           informerFactory.Networking().V1().NetworkPolicies(),
+		validatingWebhooks: informerFactory.Admissionregistration().V1().ValidatingWebhookConfigurations(),
+		mutatingWebhooks:   informerFactory.Admissionregistration().V1().MutatingWebhookConfigurations(),
+		events:             new(uint64),
+	}
+}
+
+func (clusterInformers clusterInformers) notify(analyzeQueue workqueue.Interface) {
+	atomic.AddUint64(clusterInformers.events, 1)
+	analyzeQueue.Add(nil)
+}
+
+func (clusterInformers clusterInformers) informers() map[string]cache.SharedIndexInformer {
+	kindInformers := map[string]cache.SharedIndexInformer{
+		"namespaces":                      clusterInformers.namespaces.Informer(),
+		"nodes":                           clusterInformers.nodes.Informer(),
+		"pods":                            clusterInformers.pods.Informer(),
+		"services":                        clusterInformers.services.Informer(),
+		"ingresses":                       clusterInformers.ingresses.Informer(),
+		"replicaSets":                     clusterInformers.replicaSets.Informer(),
+		"statefulSets":                    clusterInformers.statefulSets.Informer(),
+		"daemonSets":                      clusterInformers.daemonSets.Informer(),
+		"deployments":                     clusterInformers.deployments.Informer(),
+		"networkPolicies":                 clusterInformers.policies.Informer(),
+		"validatingWebhookConfigurations": clusterInformers.validatingWebhooks.Informer(),
+		"mutatingWebhookConfigurations":   clusterInformers.mutatingWebhooks.Informer(),
+	}
+	for kind, orderedPolicyInformer := range clusterInformers.orderedPolicies {
+		kindInformers[kind] = orderedPolicyInformer.informer.Informer()
+	}
+	return kindInformers
+}
+
+// resourceVersions returns the last resourceVersion received by the watch of each kind
+func (clusterInformers clusterInformers) resourceVersions() map[string]string {
+	resourceVersions := make(map[string]string)
+	for kind, informer := range clusterInformers.informers() {
+		resourceVersions[kind] = informer.LastSyncResourceVersion()
+	}
+	return resourceVersions
+}
+
+// snapshot lists the caches again when their watches received or notified a change meanwhile, so that an analysis
+// does not mix objects listed before and after an update, such as a pod relabeled along with its policy. The
+// resourceVersions the snapshot was fenced with are recorded in it.
+func (clusterInformers clusterInformers) snapshot() types.ClusterState {
+	for attempt := 1; ; attempt++ {
+		events := atomic.LoadUint64(clusterInformers.events)
+		before := clusterInformers.resourceVersions()
+		clusterState := clusterInformers.list()
+		after := clusterInformers.resourceVersions()
+		consistent := reflect.DeepEqual(before, after) && events == atomic.LoadUint64(clusterInformers.events)
+		if consistent || attempt == maxSnapshotAttempts {
+			if !consistent {
+				log.Printf("The cluster kept changing during %d listings, analyzing the last one\n", attempt)
+			}
+			clusterState.ResourceVersions = after
+			return clusterState
+		}
+	}
+}
+
+func (clusterInformers clusterInformers) list() types.ClusterState {
+	namespaces, err := clusterInformers.namespaces.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	nodes, err := clusterInformers.nodes.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	pods, err := clusterInformers.pods.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	services, err := clusterInformers.services.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	ingresses, err := clusterInformers.ingresses.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	replicaSets, err := clusterInformers.replicaSets.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	statefulSets, err := clusterInformers.statefulSets.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	daemonSets, err := clusterInformers.daemonSets.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	deployments, err := clusterInformers.deployments.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	policies, err := clusterInformers.policies.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	validatingWebhooks, err := clusterInformers.validatingWebhooks.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	mutatingWebhooks, err := clusterInformers.mutatingWebhooks.Lister().List(labels.Everything())
+	if err != nil {
+		panic(err.Error())
+	}
+	return types.ClusterState{
+		Namespaces:                      namespaces,
+		Nodes:                           nodes,
+		Pods:                            pods,
+		Services:                        services,
+		Ingresses:                       ingresses,
+		ReplicaSets:                     replicaSets,
+		StatefulSets:                    statefulSets,
+		DaemonSets:                      daemonSets,
+		Deployments:                     deployments,
+		NetworkPolicies:                 policies,
+		ValidatingWebhookConfigurations: validatingWebhooks,
+		MutatingWebhookConfigurations:   mutatingWebhooks,
+		OrderedPolicies:                 clusterInformers.listOrderedPolicies(),
+	}
+}
+
+func Snapshot(k8sClient kubernetes.Interface) (types.ClusterState, error) {
+	ctx := context.Background()
+	listOptions := metav1.ListOptions{}
+	serverVersion, apiGroups := discoverServer(k8sClient)
+	clusterState := types.ClusterState{ServerVersion: serverVersion, APIGroups: apiGroups,
+		ResourceVersions: make(map[string]string)}
+	namespaces, err := k8sClient.CoreV1().Namespaces().List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["namespaces"] = namespaces.ResourceVersion
+	for i := range namespaces.Items {
+		clusterState.Namespaces = append(clusterState.Namespaces, &namespaces.Items[i])
+	}
+	nodes, err := k8sClient.CoreV1().Nodes().List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["nodes"] = nodes.ResourceVersion
+	for i := range nodes.Items {
+		clusterState.Nodes = append(clusterState.Nodes, &nodes.Items[i])
+	}
+	pods, err := k8sClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["pods"] = pods.ResourceVersion
+	for i := range pods.Items {
+		clusterState.Pods = append(clusterState.Pods, &pods.Items[i])
+	}
+	services, err := k8sClient.CoreV1().Services(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["services"] = services.ResourceVersion
+	for i := range services.Items {
+		clusterState.Services = append(clusterState.Services, &services.Items[i])
+	}
+	ingresses, err := k8sClient.NetworkingV1beta1().Ingresses(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["ingresses"] = ingresses.ResourceVersion
+	for i := range ingresses.Items {
+		clusterState.Ingresses = append(clusterState.Ingresses, &ingresses.Items[i])
+	}
+	replicaSets, err := k8sClient.AppsV1().ReplicaSets(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["replicaSets"] = replicaSets.ResourceVersion
+	for i := range replicaSets.Items {
+		clusterState.ReplicaSets = append(clusterState.ReplicaSets, &replicaSets.Items[i])
+	}
+	statefulSets, err := k8sClient.AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["statefulSets"] = statefulSets.ResourceVersion
+	for i := range statefulSets.Items {
+		clusterState.StatefulSets = append(clusterState.StatefulSets, &statefulSets.Items[i])
+	}
+	daemonSets, err := k8sClient.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["daemonSets"] = daemonSets.ResourceVersion
+	for i := range daemonSets.Items {
+		clusterState.DaemonSets = append(clusterState.DaemonSets, &daemonSets.Items[i])
+	}
+	deployments, err := k8sClient.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["deployments"] = deployments.ResourceVersion
+	for i := range deployments.Items {
+		clusterState.Deployments = append(clusterState.Deployments, &deployments.Items[i])
+	}
+	policies, err := k8sClient.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["networkPolicies"] = policies.ResourceVersion
+	for i := range policies.Items {
+		clusterState.NetworkPolicies = append(clusterState.NetworkPolicies, &policies.Items[i])
+	}
+	validatingWebhooks, err := k8sClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx,
+		listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["validatingWebhookConfigurations"] = validatingWebhooks.ResourceVersion
+	for i := range validatingWebhooks.Items {
+		clusterState.ValidatingWebhookConfigurations = append(clusterState.ValidatingWebhookConfigurations,
+			&validatingWebhooks.Items[i])
+	}
+	mutatingWebhooks, err := k8sClient.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx,
+		listOptions)
+	if err != nil {
+		return clusterState, err
+	}
+	clusterState.ResourceVersions["mutatingWebhookConfigurations"] = mutatingWebhooks.ResourceVersion
+	for i := range mutatingWebhooks.Items {
+		clusterState.MutatingWebhookConfigurations = append(clusterState.MutatingWebhookConfigurations,
+			&mutatingWebhooks.Items[i])
+	}
+	clusterState.APIServices = listAPIServices(ctx, k8sClient)
+	clusterState.OrderedPolicies = listOrderedPolicies(ctx, k8sClient, apiGroups)
+	return clusterState, nil
+}
+
+func discoverServer(k8sClient kubernetes.Interface) (*version.Info, []metav1.APIGroup) {
+	serverVersion, err := k8sClient.Discovery().ServerVersion()
+	if err != nil {
+		log.Printf("Unable to discover the server version, latest features are assumed: %s\n", err)
+		serverVersion = nil
+	}
+	apiGroupList, err := k8sClient.Discovery().ServerGroups()
+	if err != nil {
+		log.Printf("Unable to discover the server API groups: %s\n", err)
+		return serverVersion, nil
+	}
+	return serverVersion, apiGroupList.Groups
+}
+
+func NewK8sClient(k8sClientConfig string) kubernetes.Interface {
+	return kubernetes.NewForConfigOrDie(newRestConfig(k8sClientConfig))
+}
+
+func NewDynamicClient(k8sClientConfig string) dynamic.Interface {
+	return dynamic.NewForConfigOrDie(newRestConfig(k8sClientConfig))
+}
+
+func newRestConfig(k8sClientConfig string) *rest.Config {
 	var config *rest.Config
 	var err1InsideCluster, errOutsideCluster error
 	config, err1InsideCluster = rest.InClusterConfig()
@@ -108,6 +375,5 @@ func getK8sClient(k8sClientConfig string) *kubernetes.Clientset {
 			panic(errOutsideCluster.Error())
 		}
 	}
-	k8sClient := kubernetes.NewForConfigOrDie(config)
-	return k8sClient
+	return config
 }
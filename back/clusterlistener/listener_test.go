@@ -0,0 +1,102 @@
+package clusterlistener
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"karto/testutils"
+	"sort"
+	"testing"
+)
+
+func TestClusterInformersSnapshot(t *testing.T) {
+	pod := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build()
+	namespace := testutils.NewNamespaceBuilder().WithName("shop").Build()
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(pod, namespace), 0)
+	clusterInformers := newClusterInformers(informerFactory)
+	// Informers are only started by the factory once requested
+	clusterInformers.informers()
+	informerFactory.Start(wait.NeverStop)
+	informerFactory.WaitForCacheSync(wait.NeverStop)
+	clusterState := clusterInformers.snapshot()
+	if diff := cmp.Diff([]*corev1.Pod{pod}, clusterState.Pods); diff != "" {
+		t.Errorf("snapshot() pods mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*corev1.Namespace{namespace}, clusterState.Namespaces); diff != "" {
+		t.Errorf("snapshot() namespaces mismatch (-want +got):\n%s", diff)
+	}
+	kinds := make([]string, 0)
+	for kind := range clusterState.ResourceVersions {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	expectedKinds := []string{"daemonSets", "deployments", "ingresses", "mutatingWebhookConfigurations", "namespaces",
+		"networkPolicies", "nodes", "pods", "replicaSets", "services", "statefulSets",
+		"validatingWebhookConfigurations"}
+	if diff := cmp.Diff(expectedKinds, kinds); diff != "" {
+		t.Errorf("snapshot() fenced kinds mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClusterInformersSnapshotOrderedPolicies(t *testing.T) {
+	calicoPolicy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "crd.projectcalico.org/v1",
+		"kind":       "NetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "allow-front", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"order":    int64(10),
+			"selector": "app == 'front'",
+			"types":    []interface{}{"Ingress"},
+			"ingress":  []interface{}{map[string]interface{}{"action": "Allow"}},
+		},
+	}}
+	networkPolicies := schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1",
+		Resource: "networkpolicies"}
+	globalNetworkPolicies := schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1",
+		Resource: "globalnetworkpolicies"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			networkPolicies:       "NetworkPolicyList",
+			globalNetworkPolicies: "GlobalNetworkPolicyList",
+		}, calicoPolicy)
+	dynamicClient.PrependReactor("list", "globalnetworkpolicies",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.NewForbidden(globalNetworkPolicies.GroupResource(), "", nil)
+		})
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	dynamicInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	clusterInformers := newClusterInformers(informerFactory)
+	apiGroups := []metav1.APIGroup{{Name: "crd.projectcalico.org"}}
+	clusterInformers.orderedPolicies = newOrderedPolicyInformers(context.Background(), dynamicClient,
+		dynamicInformerFactory, apiGroups)
+	clusterInformers.informers()
+	informerFactory.Start(wait.NeverStop)
+	dynamicInformerFactory.Start(wait.NeverStop)
+	informerFactory.WaitForCacheSync(wait.NeverStop)
+	dynamicInformerFactory.WaitForCacheSync(wait.NeverStop)
+	clusterState := clusterInformers.snapshot()
+	policies := make([]string, 0)
+	for _, policy := range clusterState.OrderedPolicies {
+		policies = append(policies, policy.Kind+" "+policy.Namespace+"/"+policy.Name)
+	}
+	if diff := cmp.Diff([]string{"NetworkPolicy shop/allow-front"}, policies); diff != "" {
+		t.Errorf("snapshot() ordered policies mismatch (-want +got):\n%s", diff)
+	}
+	if _, found := clusterState.ResourceVersions["crd.projectcalico.org/networkpolicies"]; !found {
+		t.Errorf("snapshot() is not fenced with the resourceVersion of the Calico network policies")
+	}
+	if _, found := clusterState.ResourceVersions["crd.projectcalico.org/globalnetworkpolicies"]; found {
+		t.Errorf("snapshot() is fenced with the resourceVersion of the forbidden Calico global network policies")
+	}
+}
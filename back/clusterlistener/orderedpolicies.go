@@ -0,0 +1,144 @@
+package clusterlistener
+
+import (
+	"context"
+	"encoding/json"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"karto/orderedpolicy"
+	"log"
+	"sort"
+)
+
+type orderedPolicyResource struct {
+	group    string
+	version  string
+	resource string
+	kind     string
+	parse    func(content []byte, kind string) ([]*orderedpolicy.Policy, error)
+}
+
+var orderedPolicyResources = []orderedPolicyResource{
+	{"crd.projectcalico.org", "v1", "networkpolicies", "NetworkPolicy", orderedpolicy.ParseCalicoPolicies},
+	{"crd.projectcalico.org", "v1", "globalnetworkpolicies", "GlobalNetworkPolicy", orderedpolicy.ParseCalicoPolicies},
+	{"cilium.io", "v2", "ciliumnetworkpolicies", "CiliumNetworkPolicy", orderedpolicy.ParseCiliumPolicies},
+	{"cilium.io", "v2", "ciliumclusterwidenetworkpolicies", "CiliumClusterwideNetworkPolicy",
+		orderedpolicy.ParseCiliumPolicies},
+}
+
+func (resource orderedPolicyResource) groupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: resource.group, Version: resource.version, Resource: resource.resource}
+}
+
+// informerKind names the informer of the resource among the kinds of the cluster state
+func (resource orderedPolicyResource) informerKind() string {
+	return resource.group + "/" + resource.resource
+}
+
+type orderedPolicyInformer struct {
+	resource orderedPolicyResource
+	informer informers.GenericInformer
+}
+
+// The Calico and Cilium policies are watched when their API group is served and they can be listed. They are only
+// needed by the route explanations, so the analysis goes on without them otherwise, instead of waiting for caches
+// which would never sync.
+func newOrderedPolicyInformers(ctx context.Context, dynamicClient dynamic.Interface,
+	informerFactory dynamicinformer.DynamicSharedInformerFactory,
+	apiGroups []metav1.APIGroup) map[string]orderedPolicyInformer {
+	orderedPolicyInformers := make(map[string]orderedPolicyInformer)
+	for _, resource := range orderedPolicyResources {
+		if !groupServed(apiGroups, resource.group) {
+			continue
+		}
+		_, err := dynamicClient.Resource(resource.groupVersionResource()).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			log.Printf("Unable to list the %ss: %s\n", resource.kind, err)
+			continue
+		}
+		orderedPolicyInformers[resource.informerKind()] = orderedPolicyInformer{
+			resource: resource,
+			informer: informerFactory.ForResource(resource.groupVersionResource()),
+		}
+	}
+	return orderedPolicyInformers
+}
+
+// The cached objects are decoded as a list, the same way as when listed from the API server
+func (clusterInformers clusterInformers) listOrderedPolicies() []*orderedpolicy.Policy {
+	policies := make([]*orderedpolicy.Policy, 0)
+	for _, resource := range orderedPolicyResources {
+		orderedPolicyInformer, found := clusterInformers.orderedPolicies[resource.informerKind()]
+		if !found {
+			continue
+		}
+		objects, err := orderedPolicyInformer.informer.Lister().List(labels.Everything())
+		if err != nil {
+			panic(err.Error())
+		}
+		items := make([]map[string]interface{}, 0, len(objects))
+		for _, object := range objects {
+			items = append(items, object.(*unstructured.Unstructured).Object)
+		}
+		sort.Slice(items, func(i, j int) bool {
+			first, second := unstructured.Unstructured{Object: items[i]}, unstructured.Unstructured{Object: items[j]}
+			if first.GetNamespace() != second.GetNamespace() {
+				return first.GetNamespace() < second.GetNamespace()
+			}
+			return first.GetName() < second.GetName()
+		})
+		content, err := json.Marshal(map[string]interface{}{"items": items})
+		if err != nil {
+			panic(err.Error())
+		}
+		policies = append(policies, parseOrderedPolicies(resource, content)...)
+	}
+	return policies
+}
+
+// listOrderedPolicies lists the Calico and Cilium policies through the raw REST client when their API group is
+// served, for the one-off snapshots which do not watch the cluster
+func listOrderedPolicies(ctx context.Context, k8sClient kubernetes.Interface,
+	apiGroups []metav1.APIGroup) []*orderedpolicy.Policy {
+	restClient := k8sClient.Discovery().RESTClient()
+	if restClient == nil {
+		return nil
+	}
+	policies := make([]*orderedpolicy.Policy, 0)
+	for _, resource := range orderedPolicyResources {
+		if !groupServed(apiGroups, resource.group) {
+			continue
+		}
+		path := "/apis/" + resource.group + "/" + resource.version + "/" + resource.resource
+		content, err := restClient.Get().AbsPath(path).DoRaw(ctx)
+		if err != nil {
+			log.Printf("Unable to list the %ss: %s\n", resource.kind, err)
+			continue
+		}
+		policies = append(policies, parseOrderedPolicies(resource, content)...)
+	}
+	return policies
+}
+
+func parseOrderedPolicies(resource orderedPolicyResource, content []byte) []*orderedpolicy.Policy {
+	parsed, err := resource.parse(content, resource.kind)
+	if err != nil {
+		log.Printf("Unable to decode the %ss: %s\n", resource.kind, err)
+	}
+	return parsed
+}
+
+func groupServed(apiGroups []metav1.APIGroup, groupName string) bool {
+	for _, apiGroup := range apiGroups {
+		if apiGroup.Name == groupName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"karto/clusterlistener"
+	"karto/config"
+	"karto/explain"
+	"karto/manifest"
+	"karto/policytest"
+	"karto/routediff"
+	"log"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	explainPolicyCommand = "explain-policy"
+	compareCommand       = "compare"
+	generateTestsCommand = "generate-tests"
+	verifyCommand        = "verify"
+)
+
+func runExplainPolicy(args []string) {
+	flags := flag.NewFlagSet(explainPolicyCommand, flag.ExitOnError)
+	policyPath := flags.String("f", "", "path to the manifest of the network policy to explain")
+	k8sConfigPath := flags.String("kubeconfig", defaultK8sConfigPath(),
+		"(optional) absolute path to the kubeconfig file")
+	output := flags.String("o", "text", "(optional) output format, text or json")
+	_ = flags.Parse(args)
+	if *policyPath == "" {
+		log.Fatalln("a network policy manifest must be given with -f")
+	}
+	policyManifest, err := manifest.LoadFile(*policyPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(policyManifest.NetworkPolicies) != 1 {
+		log.Fatalf("%s must contain exactly one network policy, found %d\n", *policyPath,
+			len(policyManifest.NetworkPolicies))
+	}
+	clusterState, err := clusterlistener.Snapshot(clusterlistener.NewK8sClient(*k8sConfigPath))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	container := dependencyInjection(config.Config{})
+	explanation, err := explain.Explain(context.Background(), container.AnalysisScheduler, clusterState,
+		policyManifest.NetworkPolicies[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	writeOutput(*output, explanation, func() { explain.WriteText(os.Stdout, explanation) })
+}
+
+func runCompare(args []string) {
+	flags := flag.NewFlagSet(compareCommand, flag.ExitOnError)
+	beforePath := flags.String("before", "", "directory of the manifests before the change")
+	afterPath := flags.String("after", "", "directory of the manifests after the change")
+	output := flags.String("o", "text", "(optional) output format, text or json")
+	_ = flags.Parse(args)
+	if *beforePath == "" || *afterPath == "" {
+		log.Fatalln("both manifest directories must be given with -before and -after")
+	}
+	beforeClusterState, err := manifest.LoadDirectory(*beforePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	afterClusterState, err := manifest.LoadDirectory(*afterPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	analysisScheduler := dependencyInjection(config.Config{}).AnalysisScheduler
+	before := analysisScheduler.Analyze(manifest.Expand(beforeClusterState))
+	after := analysisScheduler.Analyze(manifest.Expand(afterClusterState))
+	diff := routediff.ComputeWithCauses(before, after)
+	writeOutput(*output, diff, func() {
+		fmt.Println("Connectivity changes:")
+		routediff.WriteText(os.Stdout, diff)
+	})
+}
+
+func runGenerateTests(args []string) {
+	flags := flag.NewFlagSet(generateTestsCommand, flag.ExitOnError)
+	namespace := flags.String("namespace", "", "namespace whose connectivity is locked in")
+	k8sConfigPath := flags.String("kubeconfig", defaultK8sConfigPath(),
+		"(optional) absolute path to the kubeconfig file")
+	format := flags.String("format", "yaml", "(optional) format of the test suite, yaml or go")
+	packageName := flags.String("package", "connectivity", "(optional) package of the Go test suite")
+	_ = flags.Parse(args)
+	if *namespace == "" {
+		log.Fatalln("a namespace must be given with -namespace")
+	}
+	clusterState, err := clusterlistener.Snapshot(clusterlistener.NewK8sClient(*k8sConfigPath))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	analysisResult := dependencyInjection(config.Config{}).AnalysisScheduler.Analyze(clusterState)
+	suite := policytest.Generate(analysisResult, *namespace)
+	switch *format {
+	case "yaml":
+		var content []byte
+		content, err = yaml.Marshal(suite)
+		if err == nil {
+			_, err = os.Stdout.Write(content)
+		}
+	case "go":
+		err = policytest.WriteGo(os.Stdout, suite, *packageName)
+	default:
+		log.Fatalf("unknown test suite format %s\n", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runVerify exits with 1 when expectations of the test suite are not met by the cluster
+func runVerify(args []string) {
+	flags := flag.NewFlagSet(verifyCommand, flag.ExitOnError)
+	suitePath := flags.String("f", "", "path to the test suite generated by "+generateTestsCommand)
+	k8sConfigPath := flags.String("kubeconfig", defaultK8sConfigPath(),
+		"(optional) absolute path to the kubeconfig file")
+	output := flags.String("o", "text", "(optional) output format, text or json")
+	_ = flags.Parse(args)
+	if *suitePath == "" {
+		log.Fatalln("a test suite must be given with -f")
+	}
+	suite, err := policytest.Load(*suitePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	clusterState, err := clusterlistener.Snapshot(clusterlistener.NewK8sClient(*k8sConfigPath))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	analysisResult := dependencyInjection(config.Config{}).AnalysisScheduler.Analyze(clusterState)
+	results := policytest.Check(analysisResult, suite)
+	failures := 0
+	for _, result := range results {
+		if !result.Passed {
+			failures++
+		}
+	}
+	writeOutput(*output, results, func() {
+		for _, result := range results {
+			if !result.Passed {
+				fmt.Printf("FAIL %s to %s: %s\n", result.Expectation.From, result.Expectation.To, result.Reason)
+			}
+		}
+		fmt.Printf("%d of %d expectations met\n", len(results)-failures, len(results))
+	})
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeOutput(output string, value interface{}, writeText func()) {
+	switch output {
+	case "text":
+		writeText()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	default:
+		log.Fatalf("unknown output format %s\n", output)
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Boundary is a trust boundary, such as the scope of a compliance standard, made of the pods matching any of its
+// members
+type Boundary struct {
+	Name    string        `json:"name"`
+	Members []PodSelector `json:"members"`
+}
+
+// Contains returns whether the pod matches one of the members of the boundary
+func (boundary Boundary) Contains(namespace string, labels map[string]string) bool {
+	for _, member := range boundary.Members {
+		if member.Matches(namespace, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateBoundaries(boundaries []Boundary) error {
+	names := make(map[string]bool)
+	for i, boundary := range boundaries {
+		if boundary.Name == "" {
+			return fmt.Errorf("boundary #%d has no name", i+1)
+		}
+		if names[boundary.Name] {
+			return fmt.Errorf("boundary %s is declared more than once", boundary.Name)
+		}
+		names[boundary.Name] = true
+		if len(boundary.Members) == 0 {
+			return fmt.Errorf("boundary %s must declare at least one member", boundary.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// ComplianceProfileCIS checks the network policies section of the CIS Kubernetes Benchmark
+	ComplianceProfileCIS = "cis"
+	// ComplianceProfileNSA checks the network separation recommendations of the NSA/CISA Kubernetes Hardening Guidance
+	ComplianceProfileNSA = "nsa"
+)
+
+// ComplianceConfig selects the built-in check sets of the hardening guidance run on every analysis
+type ComplianceConfig struct {
+	Profiles []string `json:"profiles"`
+}
+
+func (compliance ComplianceConfig) validate() error {
+	if len(compliance.Profiles) == 0 {
+		return fmt.Errorf("compliance must select at least one profile")
+	}
+	for _, profile := range compliance.Profiles {
+		if profile != ComplianceProfileCIS && profile != ComplianceProfileNSA {
+			return fmt.Errorf("compliance has an unknown profile %q, expected %s or %s", profile,
+				ComplianceProfileCIS, ComplianceProfileNSA)
+		}
+	}
+	return nil
+}
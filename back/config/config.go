@@ -0,0 +1,386 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"karto/cron"
+	"net"
+	"sigs.k8s.io/yaml"
+	"text/template"
+	"time"
+)
+
+const (
+	ReportModeSummary  = "summary"
+	ReportModeNewItems = "newItems"
+)
+
+var severities = map[string]bool{
+	"high":   true,
+	"medium": true,
+	"low":    true,
+}
+
+type Config struct {
+	Rules        []Rule              `json:"rules"`
+	Intents      []Intent            `json:"intents"`
+	Report       *ReportConfig       `json:"report"`
+	Store        *StoreConfig        `json:"store"`
+	Risk         *RiskConfig         `json:"risk"`
+	Exclusions   *ExclusionConfig    `json:"exclusions"`
+	Monitoring   *MonitoringConfig   `json:"monitoring"`
+	Messages     map[string]string   `json:"messages"`
+	Enrichment   *EnrichmentConfig   `json:"enrichment"`
+	Redaction    *RedactionConfig    `json:"redaction"`
+	HostPorts    *HostPortConfig     `json:"hostPorts"`
+	Network      *NetworkConfig      `json:"network"`
+	PolicyExpiry *PolicyExpiryConfig `json:"policyExpiry"`
+	Exceptions   *ExceptionConfig    `json:"exceptions"`
+	Boundaries   []Boundary          `json:"boundaries"`
+	Compliance   *ComplianceConfig   `json:"compliance"`
+}
+
+type Rule struct {
+	Name           string              `json:"name"`
+	Severity       string              `json:"severity"`
+	Message        string              `json:"message"`
+	DefaultDeny    *DefaultDenyRule    `json:"defaultDeny"`
+	ForbiddenRoute *ForbiddenRouteRule `json:"forbiddenRoute"`
+}
+
+type DefaultDenyRule struct {
+	Namespace   string   `json:"namespace"`
+	PolicyTypes []string `json:"policyTypes"`
+}
+
+type ForbiddenRouteRule struct {
+	SourceNamespace string            `json:"sourceNamespace"`
+	SourcePodLabels map[string]string `json:"sourcePodLabels"`
+	TargetNamespace string            `json:"targetNamespace"`
+	TargetPodLabels map[string]string `json:"targetPodLabels"`
+}
+
+type ReportConfig struct {
+	Schedule string             `json:"schedule"`
+	Mode     string             `json:"mode"`
+	Window   string             `json:"window"`
+	SMTP     *SMTPConfig        `json:"smtp"`
+	Webhook  *WebhookConfig     `json:"webhook"`
+	Teams    *ReportTeamsConfig `json:"teams"`
+}
+
+// ReportTeamsConfig sends each team the digest of the new items involving its workloads, on its own webhook
+type ReportTeamsConfig struct {
+	Label    string                   `json:"label"`
+	Webhooks map[string]WebhookConfig `json:"webhooks"`
+}
+
+type SMTPConfig struct {
+	Address  string   `json:"address"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Username string   `json:"username"`
+}
+
+type WebhookConfig struct {
+	URL string `json:"url"`
+}
+
+type StoreConfig struct {
+	Driver string `json:"driver"`
+	Path   string `json:"path"`
+	DSN    string `json:"dsn"`
+}
+
+type RiskConfig struct {
+	Weights        RiskWeights `json:"weights"`
+	SensitivePorts []int32     `json:"sensitivePorts"`
+}
+
+type RiskWeights struct {
+	CrossNamespace       int `json:"crossNamespace"`
+	SensitivePort        int `json:"sensitivePort"`
+	PrivilegedTarget     int `json:"privilegedTarget"`
+	InternetFacingSource int `json:"internetFacingSource"`
+}
+
+// ExclusionConfig lists the pairs of pods between which routes are not computed, to save time and noise on large
+// clusters
+type ExclusionConfig struct {
+	// SameDaemonSet excludes the pairs of pods of a same DaemonSet, such as the agents running on every node
+	SameDaemonSet bool `json:"sameDaemonSet"`
+	// Namespaces excludes the pairs of pods within each of these namespaces, routes leaving them are still computed
+	Namespaces []string `json:"namespaces"`
+}
+
+// HostPortConfig enables the analysis of the containers exposing host ports, which are reachable through their node
+// whatever the network policies selecting their pod
+type HostPortConfig struct {
+	// IgnoredNamespaces are those of the agents expected to expose host ports, such as ingress controllers
+	IgnoredNamespaces []string `json:"ignoredNamespaces"`
+}
+
+// PolicyExpiryConfig names the annotation holding the date, or time, after which a network policy granting a
+// temporary exception is flagged as expired
+type PolicyExpiryConfig struct {
+	Annotation string `json:"annotation"`
+}
+
+// DefaultPolicyExpiryAnnotation is the annotation of the expiry of network policies when none is configured
+const DefaultPolicyExpiryAnnotation = "karto.zenika.com/expires"
+
+// ExceptionConfig names the annotation marking a network policy as a temporary exception, whose value lists its owner
+// and ticket as owner=...,ticket=..., and the age after which exceptions are reported as stale
+type ExceptionConfig struct {
+	Annotation string `json:"annotation"`
+	MaxAge     string `json:"maxAge"`
+}
+
+const (
+	// DefaultExceptionAnnotation is the annotation of exceptions when none is configured
+	DefaultExceptionAnnotation = "karto.zenika.com/exception"
+	DefaultExceptionMaxAge     = 30 * 24 * time.Hour
+)
+
+// NetworkConfig declares the address ranges of the cluster, those which are not declared being detected from the
+// nodes and the control plane pods
+type NetworkConfig struct {
+	PodCIDRs     []string `json:"podCIDRs"`
+	ServiceCIDRs []string `json:"serviceCIDRs"`
+}
+
+type MonitoringConfig struct {
+	Scrapers         []PodSelector `json:"scrapers"`
+	MetricsPortNames []string      `json:"metricsPortNames"`
+}
+
+// DefaultMetricsPortNames are the names of the container ports exposing metrics when none is configured
+var DefaultMetricsPortNames = []string{"metrics", "http-metrics"}
+
+// DefaultRiskConfig is used when no risk section is configured
+var DefaultRiskConfig = RiskConfig{
+	Weights: RiskWeights{
+		CrossNamespace:       1,
+		SensitivePort:        2,
+		PrivilegedTarget:     3,
+		InternetFacingSource: 2,
+	},
+	SensitivePorts: []int32{22, 2379, 3306, 5432, 6379, 9200, 10250, 27017},
+}
+
+func Load(path string) (Config, error) {
+	if path == "" {
+		return Config{}, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return Parse(content)
+}
+
+func Parse(content []byte) (Config, error) {
+	var config Config
+	err := yaml.UnmarshalStrict(content, &config)
+	if err != nil {
+		return Config{}, err
+	}
+	err = config.validate()
+	if err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (config Config) validate() error {
+	names := make(map[string]bool)
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule #%d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s is declared more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		if !severities[rule.Severity] {
+			return fmt.Errorf("rule %s has an invalid severity %q, expected high, medium or low", rule.Name,
+				rule.Severity)
+		}
+		if _, err := template.New(rule.Name).Parse(rule.Message); err != nil {
+			return fmt.Errorf("rule %s has a message which is not a valid template: %s", rule.Name, err)
+		}
+		if (rule.DefaultDeny == nil) == (rule.ForbiddenRoute == nil) {
+			return fmt.Errorf("rule %s must declare exactly one of defaultDeny or forbiddenRoute", rule.Name)
+		}
+		if rule.DefaultDeny != nil {
+			if rule.DefaultDeny.Namespace == "" {
+				return fmt.Errorf("rule %s has no namespace", rule.Name)
+			}
+			for _, policyType := range rule.DefaultDeny.PolicyTypes {
+				if policyType != "Ingress" && policyType != "Egress" {
+					return fmt.Errorf("rule %s has an invalid policy type %q, expected Ingress or Egress",
+						rule.Name, policyType)
+				}
+			}
+		}
+	}
+	if config.Report != nil {
+		err := config.Report.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Store != nil {
+		err := config.Store.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Risk != nil {
+		err := config.Risk.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Network != nil {
+		err := config.Network.validate()
+		if err != nil {
+			return err
+		}
+	}
+	err := validateBoundaries(config.Boundaries)
+	if err != nil {
+		return err
+	}
+	if config.Exceptions != nil {
+		err := config.Exceptions.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Compliance != nil {
+		err := config.Compliance.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Monitoring != nil {
+		err := config.Monitoring.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Enrichment != nil {
+		err := config.Enrichment.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if config.Redaction != nil {
+		err := config.Redaction.validate()
+		if err != nil {
+			return err
+		}
+	}
+	for code, message := range config.Messages {
+		if _, err := template.New(code).Parse(message); err != nil {
+			return fmt.Errorf("message %s is not a valid template: %s", code, err)
+		}
+	}
+	return nil
+}
+
+func (store StoreConfig) validate() error {
+	switch store.Driver {
+	case "memory", "postgres":
+		return nil
+	case "bolt":
+		if store.Path == "" {
+			return fmt.Errorf("store with the bolt driver has no path")
+		}
+		return nil
+	default:
+		return fmt.Errorf("store has an invalid driver %q, expected memory, bolt or postgres", store.Driver)
+	}
+}
+
+func (risk RiskConfig) validate() error {
+	weights := risk.Weights
+	if weights.CrossNamespace < 0 || weights.SensitivePort < 0 || weights.PrivilegedTarget < 0 ||
+		weights.InternetFacingSource < 0 {
+		return fmt.Errorf("risk weights must be positive")
+	}
+	for _, port := range risk.SensitivePorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("risk has an invalid sensitive port %d", port)
+		}
+	}
+	return nil
+}
+
+func (network NetworkConfig) validate() error {
+	for _, cidr := range append(append([]string{}, network.PodCIDRs...), network.ServiceCIDRs...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("network has an invalid CIDR %q", cidr)
+		}
+	}
+	return nil
+}
+
+func (exceptions ExceptionConfig) validate() error {
+	if exceptions.MaxAge != "" {
+		maxAge, err := time.ParseDuration(exceptions.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return fmt.Errorf("exceptions have an invalid maxAge %q, expected a positive duration such as 720h",
+				exceptions.MaxAge)
+		}
+	}
+	return nil
+}
+
+func (monitoring MonitoringConfig) validate() error {
+	if len(monitoring.Scrapers) == 0 {
+		return fmt.Errorf("monitoring must declare at least one scraper")
+	}
+	return nil
+}
+
+func (report ReportConfig) validate() error {
+	_, err := cron.Parse(report.Schedule)
+	if err != nil {
+		return fmt.Errorf("report has an %s", err)
+	}
+	if report.Mode != "" && report.Mode != ReportModeSummary && report.Mode != ReportModeNewItems {
+		return fmt.Errorf("report has an invalid mode %q, expected summary or newItems", report.Mode)
+	}
+	if report.Window != "" {
+		window, err := time.ParseDuration(report.Window)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("report has an invalid window %q, expected a positive duration such as 24h",
+				report.Window)
+		}
+	}
+	if report.SMTP == nil && report.Webhook == nil && report.Teams == nil {
+		return fmt.Errorf("report must declare at least one of smtp, webhook or teams")
+	}
+	if report.SMTP != nil && (report.SMTP.Address == "" || report.SMTP.From == "" || len(report.SMTP.To) == 0) {
+		return fmt.Errorf("report smtp must declare an address, a sender and at least one recipient")
+	}
+	if report.Webhook != nil && report.Webhook.URL == "" {
+		return fmt.Errorf("report webhook has no url")
+	}
+	if report.Teams != nil {
+		if report.Mode != ReportModeNewItems {
+			return fmt.Errorf("report teams require the newItems mode")
+		}
+		if report.Teams.Label == "" || len(report.Teams.Webhooks) == 0 {
+			return fmt.Errorf("report teams must declare a label and at least one webhook")
+		}
+		for team, webhook := range report.Teams.Webhooks {
+			if webhook.URL == "" {
+				return fmt.Errorf("report webhook of team %s has no url", team)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package config
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		expectedConfig Config
+		expectedError  string
+	}{
+		{
+			name: "parses custom rules",
+			content: `
+rules:
+  - name: prod-default-deny
+    severity: high
+    defaultDeny:
+      namespace: prod
+      policyTypes: [Ingress, Egress]
+  - name: no-dev-to-prod
+    severity: medium
+    message: dev must not reach prod
+    forbiddenRoute:
+      sourceNamespace: dev
+      targetNamespace: prod
+`,
+			expectedConfig: Config{
+				Rules: []Rule{
+					{Name: "prod-default-deny", Severity: "high", DefaultDeny: &DefaultDenyRule{Namespace: "prod",
+						PolicyTypes: []string{"Ingress", "Egress"}}},
+					{Name: "no-dev-to-prod", Severity: "medium", Message: "dev must not reach prod",
+						ForbiddenRoute: &ForbiddenRouteRule{SourceNamespace: "dev", TargetNamespace: "prod"}},
+				},
+			},
+		},
+		{
+			name: "parses the report schedule",
+			content: `
+report:
+  schedule: 0 8 * * 1
+  smtp:
+    address: smtp.example.com:587
+    from: karto@example.com
+    to: [security@example.com]
+    username: karto
+  webhook:
+    url: https://hooks.example.com/karto
+`,
+			expectedConfig: Config{
+				Report: &ReportConfig{Schedule: "0 8 * * 1",
+					SMTP: &SMTPConfig{Address: "smtp.example.com:587", From: "karto@example.com",
+						To: []string{"security@example.com"}, Username: "karto"},
+					Webhook: &WebhookConfig{URL: "https://hooks.example.com/karto"}},
+			},
+		},
+		{
+			name:          "rejects invalid report schedules",
+			content:       "report:\n  schedule: weekly\n  webhook:\n    url: https://hooks.example.com\n",
+			expectedError: "report has an invalid cron expression \"weekly\": expected 5 fields",
+		},
+		{
+			name:    "parses the new items mode of reports",
+			content: "report:\n  schedule: \"*/30 * * * *\"\n  mode: newItems\n  window: 24h\n  webhook:\n    url: u\n",
+			expectedConfig: Config{Report: &ReportConfig{Schedule: "*/30 * * * *", Mode: "newItems", Window: "24h",
+				Webhook: &WebhookConfig{URL: "u"}}},
+		},
+		{
+			name:          "rejects unknown report modes",
+			content:       "report:\n  schedule: 0 8 * * 1\n  mode: all\n  webhook:\n    url: u\n",
+			expectedError: "report has an invalid mode \"all\", expected summary or newItems",
+		},
+		{
+			name:          "rejects invalid report windows",
+			content:       "report:\n  schedule: 0 8 * * 1\n  window: 1d\n  webhook:\n    url: u\n",
+			expectedError: "report has an invalid window \"1d\", expected a positive duration such as 24h",
+		},
+		{
+			name:          "rejects reports sent nowhere",
+			content:       "report:\n  schedule: 0 8 * * 1\n",
+			expectedError: "report must declare at least one of smtp, webhook or teams",
+		},
+		{
+			name: "parses the teams of reports",
+			content: "report:\n  schedule: 0 8 * * 1\n  mode: newItems\n  teams:\n    label: team\n    webhooks:\n" +
+				"      payments:\n        url: u\n",
+			expectedConfig: Config{Report: &ReportConfig{Schedule: "0 8 * * 1", Mode: "newItems",
+				Teams: &ReportTeamsConfig{Label: "team", Webhooks: map[string]WebhookConfig{"payments": {URL: "u"}}}}},
+		},
+		{
+			name: "rejects teams outside of the new items mode",
+			content: "report:\n  schedule: 0 8 * * 1\n  teams:\n    label: team\n    webhooks:\n" +
+				"      payments:\n        url: u\n",
+			expectedError: "report teams require the newItems mode",
+		},
+		{
+			name:          "rejects invalid maximum ages of exceptions",
+			content:       "exceptions:\n  maxAge: 30d\n",
+			expectedError: "exceptions have an invalid maxAge \"30d\", expected a positive duration such as 720h",
+		},
+		{
+			name: "parses the trust boundaries",
+			content: "boundaries:\n  - name: pci\n    members:\n      - namespace: payments\n" +
+				"      - podLabels:\n          scope: pci\n",
+			expectedConfig: Config{Boundaries: []Boundary{{Name: "pci", Members: []PodSelector{
+				{Namespace: "payments"}, {PodLabels: map[string]string{"scope": "pci"}}}}}},
+		},
+		{
+			name:          "rejects trust boundaries without member",
+			content:       "boundaries:\n  - name: pci\n",
+			expectedError: "boundary pci must declare at least one member",
+		},
+		{
+			name:           "parses the compliance profiles",
+			content:        "compliance:\n  profiles: [cis, nsa]\n",
+			expectedConfig: Config{Compliance: &ComplianceConfig{Profiles: []string{"cis", "nsa"}}},
+		},
+		{
+			name:          "rejects unknown compliance profiles",
+			content:       "compliance:\n  profiles: [pci]\n",
+			expectedError: "compliance has an unknown profile \"pci\", expected cis or nsa",
+		},
+		{
+			name:           "parses the store",
+			content:        "store:\n  driver: bolt\n  path: /var/lib/karto/karto.db\n",
+			expectedConfig: Config{Store: &StoreConfig{Driver: "bolt", Path: "/var/lib/karto/karto.db"}},
+		},
+		{
+			name:          "rejects unknown store drivers",
+			content:       "store:\n  driver: redis\n",
+			expectedError: "store has an invalid driver \"redis\", expected memory, bolt or postgres",
+		},
+		{
+			name:          "rejects bolt stores without path",
+			content:       "store:\n  driver: bolt\n",
+			expectedError: "store with the bolt driver has no path",
+		},
+		{
+			name: "parses the risk weights",
+			content: "risk:\n  weights:\n    crossNamespace: 1\n    privilegedTarget: 5\n" +
+				"  sensitivePorts: [22, 5432]\n",
+			expectedConfig: Config{Risk: &RiskConfig{Weights: RiskWeights{CrossNamespace: 1, PrivilegedTarget: 5},
+				SensitivePorts: []int32{22, 5432}}},
+		},
+		{
+			name:          "rejects invalid sensitive ports",
+			content:       "risk:\n  sensitivePorts: [70000]\n",
+			expectedError: "risk has an invalid sensitive port 70000",
+		},
+		{
+			name:    "parses the pair exclusions",
+			content: "exclusions:\n  sameDaemonSet: true\n  namespaces: [kube-system]\n",
+			expectedConfig: Config{Exclusions: &ExclusionConfig{SameDaemonSet: true,
+				Namespaces: []string{"kube-system"}}},
+		},
+		{
+			name:    "parses the network ranges",
+			content: "network:\n  podCIDRs: [10.244.0.0/16]\n  serviceCIDRs: [10.96.0.0/12, fd00:10:96::/112]\n",
+			expectedConfig: Config{Network: &NetworkConfig{PodCIDRs: []string{"10.244.0.0/16"},
+				ServiceCIDRs: []string{"10.96.0.0/12", "fd00:10:96::/112"}}},
+		},
+		{
+			name:          "rejects invalid network ranges",
+			content:       "network:\n  podCIDRs: [10.244.0.0]\n",
+			expectedError: "network has an invalid CIDR \"10.244.0.0\"",
+		},
+		{
+			name: "parses the monitoring scrapers",
+			content: "monitoring:\n  scrapers:\n    - namespace: monitoring\n      podLabels:\n" +
+				"        app: prometheus\n  metricsPortNames: [prom]\n",
+			expectedConfig: Config{Monitoring: &MonitoringConfig{
+				Scrapers:         []PodSelector{{Namespace: "monitoring", PodLabels: map[string]string{"app": "prometheus"}}},
+				MetricsPortNames: []string{"prom"},
+			}},
+		},
+		{
+			name:          "rejects monitoring without scraper",
+			content:       "monitoring:\n  metricsPortNames: [prom]\n",
+			expectedError: "monitoring must declare at least one scraper",
+		},
+		{
+			name:           "parses the messages",
+			content:        "messages:\n  unused-network-policy: \"{{.policy}} is unused\"\n",
+			expectedConfig: Config{Messages: map[string]string{"unused-network-policy": "{{.policy}} is unused"}},
+		},
+		{
+			name:    "rejects invalid message templates",
+			content: "messages:\n  unused-network-policy: \"{{.policy\"\n",
+			expectedError: "message unused-network-policy is not a valid template: template: unused-network-policy:1: " +
+				"unclosed action",
+		},
+		{
+			name:          "rejects invalid rule message templates",
+			content:       "rules:\n  - name: r\n    severity: high\n    message: \"{{end}}\"\n",
+			expectedError: "rule r has a message which is not a valid template: template: r:1: unexpected {{end}}",
+		},
+		{
+			name:          "rejects unknown fields",
+			content:       "rules:\n  - name: r\n    severity: high\n    unknown: true\n",
+			expectedError: "error unmarshaling JSON: while decoding JSON: json: unknown field \"unknown\"",
+		},
+		{
+			name:          "rejects invalid severities",
+			content:       "rules:\n  - name: r\n    severity: critical\n    defaultDeny:\n      namespace: ns\n",
+			expectedError: "rule r has an invalid severity \"critical\", expected high, medium or low",
+		},
+		{
+			name:          "rejects rules without a condition",
+			content:       "rules:\n  - name: r\n    severity: high\n",
+			expectedError: "rule r must declare exactly one of defaultDeny or forbiddenRoute",
+		},
+		{
+			name: "parses the enrichment hooks",
+			content: "enrichment:\n  hooks:\n    - name: cmdb\n      url: https://cmdb.example.com/karto\n" +
+				"      kinds: [Service]\n      cacheTTL: 1h\n",
+			expectedConfig: Config{Enrichment: &EnrichmentConfig{Hooks: []EnrichmentHook{
+				{Name: "cmdb", URL: "https://cmdb.example.com/karto", Kinds: []string{"Service"}, CacheTTL: "1h"},
+			}}},
+		},
+		{
+			name:          "rejects enrichment hooks without an http url",
+			content:       "enrichment:\n  hooks:\n    - name: cmdb\n      url: cmdb.example.com\n",
+			expectedError: "enrichment hook cmdb has an invalid url \"cmdb.example.com\", expected an http or https URL",
+		},
+		{
+			name:          "rejects invalid enrichment timeouts",
+			content:       "enrichment:\n  hooks:\n    - name: cmdb\n      url: http://cmdb\n      timeout: 5\n",
+			expectedError: "enrichment hook cmdb has an invalid timeout \"5\", expected a positive duration such as 5s",
+		},
+		{
+			name: "parses the redaction profiles",
+			content: "redaction:\n  profiles:\n    - name: public\n      labels: [\"*.example.com/*\"]\n" +
+				"      annotations: true\n  roles:\n    - name: admins\n      tokenEnv: KARTO_ADMIN_TOKEN\n" +
+				"  defaultProfile: public\n",
+			expectedConfig: Config{Redaction: &RedactionConfig{
+				Profiles:       []RedactionProfile{{Name: "public", Labels: []string{"*.example.com/*"}, Annotations: true}},
+				Roles:          []RedactionRole{{Name: "admins", TokenEnv: "KARTO_ADMIN_TOKEN"}},
+				DefaultProfile: "public",
+			}},
+		},
+		{
+			name: "parses the features of the redaction roles",
+			content: "redaction:\n  roles:\n    - name: admins\n      tokenEnv: KARTO_ADMIN_TOKEN\n" +
+				"      features: [simulate, exports]\n  defaultFeatures: []\n",
+			expectedConfig: Config{Redaction: &RedactionConfig{
+				Roles: []RedactionRole{{Name: "admins", TokenEnv: "KARTO_ADMIN_TOKEN",
+					Features: []string{"simulate", "exports"}}},
+				DefaultFeatures: []string{},
+			}},
+		},
+		{
+			name: "rejects unknown features",
+			content: "redaction:\n  roles:\n    - name: admins\n      tokenEnv: KARTO_ADMIN_TOKEN\n" +
+				"      features: [delete]\n",
+			expectedError: "redaction role admins has an unknown feature \"delete\", expected simulate, authoring, " +
+				"exports or suppressions",
+		},
+		{
+			name: "rejects redaction roles of unknown profiles",
+			content: "redaction:\n  roles:\n    - name: devs\n      tokenEnv: KARTO_DEV_TOKEN\n" +
+				"      profile: internal\n",
+			expectedError: "redaction role devs has an unknown profile \"internal\"",
+		},
+		{
+			name: "rejects duplicated rule names",
+			content: "rules:\n  - name: r\n    severity: high\n    defaultDeny:\n      namespace: ns\n" +
+				"  - name: r\n    severity: high\n    defaultDeny:\n      namespace: ns\n",
+			expectedError: "rule r is declared more than once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Parse([]byte(tt.content))
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("Parse() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedConfig, config); diff != "" {
+				t.Errorf("Parse() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseIntents(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		expectedIntents []Intent
+		expectedError   string
+	}{
+		{
+			name: "parses intents",
+			content: `
+intents:
+  - purpose: front calls the API
+    source:
+      namespace: shop
+      podLabels:
+        app: front
+    target:
+      podLabels:
+        app: api
+`,
+			expectedIntents: []Intent{
+				{Purpose: "front calls the API",
+					Source: PodSelector{Namespace: "shop", PodLabels: map[string]string{"app": "front"}},
+					Target: PodSelector{PodLabels: map[string]string{"app": "api"}}},
+			},
+		},
+		{
+			name:          "rejects intents without purpose",
+			content:       "intents:\n  - source:\n      namespace: shop\n",
+			expectedError: "intent #1 has no purpose",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intents, err := ParseIntents([]byte(tt.content))
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("ParseIntents() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedIntents, intents); diff != "" {
+				t.Errorf("ParseIntents() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConnectivityRuleValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		rule          ConnectivityRule
+		expectedError string
+	}{
+		{
+			name: "accepts a rule expecting a denied route",
+			rule: ConnectivityRule{Name: "no-db", Namespace: "shop", Expect: ExpectDenied, Severity: "high"},
+		},
+		{
+			name: "rejects an unknown expectation",
+			rule: ConnectivityRule{Name: "no-db", Namespace: "shop", Expect: "maybe"},
+			expectedError: "connectivity rule shop/no-db has an invalid expectation \"maybe\", " +
+				"expected allowed or denied",
+		},
+		{
+			name: "rejects an unknown severity",
+			rule: ConnectivityRule{Name: "no-db", Namespace: "shop", Expect: ExpectAllowed, Severity: "critical"},
+			expectedError: "connectivity rule shop/no-db has an invalid severity \"critical\", " +
+				"expected high, medium or low",
+		},
+		{
+			name:          "rejects an invalid port",
+			rule:          ConnectivityRule{Name: "no-db", Namespace: "shop", Expect: ExpectAllowed, Port: 70000},
+			expectedError: "connectivity rule shop/no-db has an invalid port 70000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("Validate() error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLabelPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{pattern: "owner", key: "owner", expected: true},
+		{pattern: "owner", key: "owners", expected: false},
+		{pattern: "*.example.com/*", key: "cost.example.com/center", expected: true},
+		{pattern: "*.example.com/*", key: "costXexample.com/center", expected: false},
+		{pattern: "*", key: "app.kubernetes.io/name", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			if matches := LabelPattern(tt.pattern).MatchString(tt.key); matches != tt.expected {
+				t.Errorf("LabelPattern(%q) matches %q = %v, expected %v", tt.pattern, tt.key, matches, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+const (
+	ExpectAllowed = "allowed"
+	ExpectDenied  = "denied"
+)
+
+// ConnectivityRule is an expectation about the traffic between two groups of pods, declared by a tenant as a custom
+// resource. Its name and namespace are those of the resource.
+type ConnectivityRule struct {
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	Source    PodSelector `json:"source"`
+	Target    PodSelector `json:"target"`
+	Port      int32       `json:"port"`
+	Expect    string      `json:"expect"`
+	Severity  string      `json:"severity"`
+}
+
+func (rule ConnectivityRule) Validate() error {
+	if rule.Expect != ExpectAllowed && rule.Expect != ExpectDenied {
+		return fmt.Errorf("connectivity rule %s/%s has an invalid expectation %q, expected allowed or denied",
+			rule.Namespace, rule.Name, rule.Expect)
+	}
+	if rule.Severity != "" && !severities[rule.Severity] {
+		return fmt.Errorf("connectivity rule %s/%s has an invalid severity %q, expected high, medium or low",
+			rule.Namespace, rule.Name, rule.Severity)
+	}
+	if rule.Port < 0 || rule.Port > 65535 {
+		return fmt.Errorf("connectivity rule %s/%s has an invalid port %d", rule.Namespace, rule.Name, rule.Port)
+	}
+	return nil
+}
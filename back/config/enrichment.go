@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	EnrichmentKindPod     = "Pod"
+	EnrichmentKindService = "Service"
+)
+
+type EnrichmentConfig struct {
+	Hooks []EnrichmentHook `json:"hooks"`
+}
+
+// EnrichmentHook is an HTTP endpoint returning the external metadata of a pod or a service, such as its owner in a
+// CMDB, its cost center or its criticality
+type EnrichmentHook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Kinds are Pod and Service, both if empty
+	Kinds    []string `json:"kinds"`
+	CacheTTL string   `json:"cacheTTL"`
+	Timeout  string   `json:"timeout"`
+}
+
+func (enrichment EnrichmentConfig) validate() error {
+	names := make(map[string]bool)
+	for i, hook := range enrichment.Hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("enrichment hook #%d has no name", i+1)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("enrichment hook %s is declared more than once", hook.Name)
+		}
+		names[hook.Name] = true
+		hookURL, err := url.Parse(hook.URL)
+		if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") {
+			return fmt.Errorf("enrichment hook %s has an invalid url %q, expected an http or https URL", hook.Name,
+				hook.URL)
+		}
+		for _, kind := range hook.Kinds {
+			if kind != EnrichmentKindPod && kind != EnrichmentKindService {
+				return fmt.Errorf("enrichment hook %s has an invalid kind %q, expected Pod or Service", hook.Name,
+					kind)
+			}
+		}
+		if !isPositiveDuration(hook.CacheTTL) {
+			return fmt.Errorf("enrichment hook %s has an invalid cacheTTL %q, expected a positive duration such as 1h",
+				hook.Name, hook.CacheTTL)
+		}
+		if !isPositiveDuration(hook.Timeout) {
+			return fmt.Errorf("enrichment hook %s has an invalid timeout %q, expected a positive duration such as 5s",
+				hook.Name, hook.Timeout)
+		}
+	}
+	return nil
+}
+
+// Durations are optional, an empty one is left to its default
+func isPositiveDuration(value string) bool {
+	if value == "" {
+		return true
+	}
+	duration, err := time.ParseDuration(value)
+	return err == nil && duration > 0
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sigs.k8s.io/yaml"
+)
+
+type Intents struct {
+	Intents []Intent `json:"intents"`
+}
+
+type Intent struct {
+	Purpose string      `json:"purpose"`
+	Source  PodSelector `json:"source"`
+	Target  PodSelector `json:"target"`
+}
+
+type PodSelector struct {
+	Namespace string            `json:"namespace"`
+	PodLabels map[string]string `json:"podLabels"`
+}
+
+func (podSelector PodSelector) Matches(namespace string, labels map[string]string) bool {
+	if podSelector.Namespace != "" && podSelector.Namespace != namespace {
+		return false
+	}
+	for key, value := range podSelector.PodLabels {
+		if actualValue, ok := labels[key]; !ok || actualValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+func LoadIntents(path string) ([]Intent, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIntents(content)
+}
+
+func ParseIntents(content []byte) ([]Intent, error) {
+	var intents Intents
+	err := yaml.UnmarshalStrict(content, &intents)
+	if err != nil {
+		return nil, err
+	}
+	for i, intent := range intents.Intents {
+		if intent.Purpose == "" {
+			return nil, fmt.Errorf("intent #%d has no purpose", i+1)
+		}
+	}
+	return intents.Intents, nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	FeatureSimulate     = "simulate"
+	FeatureAuthoring    = "authoring"
+	FeatureExports      = "exports"
+	FeatureSuppressions = "suppressions"
+)
+
+var features = map[string]bool{
+	FeatureSimulate:     true,
+	FeatureAuthoring:    true,
+	FeatureExports:      true,
+	FeatureSuppressions: true,
+}
+
+// RedactionConfig hides parts of the API responses from broader audiences. Requests are redacted according to the
+// profile of the role of their bearer token, or to the default profile when their token matches no role. Features
+// are granted the same way.
+type RedactionConfig struct {
+	Profiles       []RedactionProfile `json:"profiles"`
+	Roles          []RedactionRole    `json:"roles"`
+	DefaultProfile string             `json:"defaultProfile"`
+	// DefaultFeatures are granted to the requests whose token matches no role, all of them when not declared
+	DefaultFeatures []string `json:"defaultFeatures"`
+}
+
+type RedactionProfile struct {
+	Name string `json:"name"`
+	// Labels are patterns of the keys of the labels to hide, in which * matches any sequence of characters
+	Labels      []string `json:"labels"`
+	Annotations bool     `json:"annotations"`
+	Images      bool     `json:"images"`
+}
+
+// RedactionRole is granted to the requests bearing the token read from an environment variable. A role without
+// profile sees full data.
+type RedactionRole struct {
+	Name     string `json:"name"`
+	TokenEnv string `json:"tokenEnv"`
+	Profile  string `json:"profile"`
+	// Features are those of simulate, authoring, exports and suppressions the role may use, all of them when not
+	// declared
+	Features []string `json:"features"`
+}
+
+// LabelPattern compiles a pattern of label keys into an anchored regular expression
+func LabelPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func (redaction RedactionConfig) validate() error {
+	profiles := make(map[string]bool)
+	for i, profile := range redaction.Profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("redaction profile #%d has no name", i+1)
+		}
+		if profiles[profile.Name] {
+			return fmt.Errorf("redaction profile %s is declared more than once", profile.Name)
+		}
+		profiles[profile.Name] = true
+	}
+	for i, role := range redaction.Roles {
+		if role.Name == "" || role.TokenEnv == "" {
+			return fmt.Errorf("redaction role #%d must declare a name and a tokenEnv", i+1)
+		}
+		if role.Profile != "" && !profiles[role.Profile] {
+			return fmt.Errorf("redaction role %s has an unknown profile %q", role.Name, role.Profile)
+		}
+		if err := validateFeatures(role.Features); err != nil {
+			return fmt.Errorf("redaction role %s has %s", role.Name, err)
+		}
+	}
+	if redaction.DefaultProfile != "" && !profiles[redaction.DefaultProfile] {
+		return fmt.Errorf("redaction has an unknown default profile %q", redaction.DefaultProfile)
+	}
+	if err := validateFeatures(redaction.DefaultFeatures); err != nil {
+		return fmt.Errorf("redaction default features have %s", err)
+	}
+	return nil
+}
+
+func validateFeatures(declaredFeatures []string) error {
+	for _, feature := range declaredFeatures {
+		if !features[feature] {
+			return fmt.Errorf("an unknown feature %q, expected simulate, authoring, exports or suppressions", feature)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+type fileWatcher struct {
+	read        func() ([]byte, error)
+	lastContent []byte
+	lastError   string
+}
+
+// Watch reads the configuration file periodically and sends its configuration each time its content changes. An
+// invalid configuration is logged and not sent, so that the running one is kept until the file is fixed.
+func Watch(path string, interval time.Duration, configurations chan<- Config) {
+	watcher := &fileWatcher{read: func() ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}}
+	// The first read is the configuration loaded on startup
+	watcher.poll()
+	for {
+		time.Sleep(interval)
+		configuration, changed := watcher.poll()
+		if changed {
+			log.Printf("Reloading the configuration from %s\n", path)
+			configurations <- configuration
+		}
+	}
+}
+
+// Errors are only logged when they change, such as while the file is being replaced
+func (watcher *fileWatcher) poll() (Config, bool) {
+	content, err := watcher.read()
+	if err == nil {
+		if watcher.lastContent != nil && bytes.Equal(content, watcher.lastContent) {
+			return Config{}, false
+		}
+		firstRead := watcher.lastContent == nil
+		watcher.lastContent = content
+		var configuration Config
+		configuration, err = Parse(content)
+		if err == nil {
+			watcher.lastError = ""
+			return configuration, !firstRead
+		}
+	}
+	if err.Error() != watcher.lastError {
+		log.Printf("Unable to reload the configuration, keeping the running one: %s\n", err)
+		watcher.lastError = err.Error()
+	}
+	return Config{}, false
+}
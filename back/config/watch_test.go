@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestFileWatcherPoll(t *testing.T) {
+	type read struct {
+		content string
+		err     error
+	}
+	tests := []struct {
+		name            string
+		reads           []read
+		expectedChanges []bool
+		expectedConfig  Config
+	}{
+		{
+			name:            "the first read is not a change",
+			reads:           []read{{content: "rules: []"}},
+			expectedChanges: []bool{false},
+		},
+		{
+			name: "a new content is a change",
+			reads: []read{{content: "{}"}, {content: "{}"},
+				{content: "exclusions:\n  namespaces: [kube-system]"}},
+			expectedChanges: []bool{false, false, true},
+			expectedConfig:  Config{Exclusions: &ExclusionConfig{Namespaces: []string{"kube-system"}}},
+		},
+		{
+			name: "invalid contents and read errors are not changes",
+			reads: []read{{content: "{}"}, {err: fmt.Errorf("no such file")}, {content: "unknown: true"},
+				{content: "unknown: true"}},
+			expectedChanges: []bool{false, false, false, false},
+		},
+		{
+			name: "a content fixed after an invalid one is a change",
+			reads: []read{{content: "{}"}, {content: "unknown: true"},
+				{content: "exclusions:\n  namespaces: [kube-system]"}},
+			expectedChanges: []bool{false, false, true},
+			expectedConfig:  Config{Exclusions: &ExclusionConfig{Namespaces: []string{"kube-system"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := tt.reads
+			watcher := &fileWatcher{read: func() ([]byte, error) {
+				next := reads[0]
+				reads = reads[1:]
+				return []byte(next.content), next.err
+			}}
+			changes := make([]bool, 0)
+			var lastConfig Config
+			for range tt.reads {
+				configuration, changed := watcher.poll()
+				changes = append(changes, changed)
+				if changed {
+					lastConfig = configuration
+				}
+			}
+			if diff := cmp.Diff(tt.expectedChanges, changes); diff != "" {
+				t.Errorf("poll() changes mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedConfig, lastConfig); diff != "" {
+				t.Errorf("poll() configuration mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
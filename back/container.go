@@ -2,11 +2,24 @@ package main
 
 import (
 	"karto/analyzer"
+	"karto/analyzer/capability"
+	"karto/analyzer/clusternetwork"
+	"karto/analyzer/extension"
+	"karto/analyzer/finding"
+	"karto/analyzer/hairpin"
 	"karto/analyzer/health"
 	"karto/analyzer/health/podhealth"
+	"karto/analyzer/hostport"
+	"karto/analyzer/intent"
+	"karto/analyzer/namespace"
+	"karto/analyzer/networkpolicy"
 	"karto/analyzer/pod"
+	"karto/analyzer/risk"
+	"karto/analyzer/system"
+	"karto/analyzer/tightening"
 	"karto/analyzer/traffic"
 	"karto/analyzer/traffic/allowedroute"
+	"karto/analyzer/traffic/enforcement"
 	"karto/analyzer/traffic/podisolation"
 	"karto/analyzer/workload"
 	"karto/analyzer/workload/daemonset"
@@ -15,17 +28,39 @@ import (
 	"karto/analyzer/workload/replicaset"
 	"karto/analyzer/workload/service"
 	"karto/analyzer/workload/statefulset"
+	"karto/config"
+	"karto/explain"
 )
 
 type Container struct {
-	AnalysisScheduler analyzer.AnalysisScheduler
+	AnalysisScheduler   analyzer.AnalysisScheduler
+	PolicyExplainer     explain.Explainer
+	reloadableScheduler *analyzer.ReloadableScheduler
 }
 
-func dependencyInjection() Container {
+func dependencyInjection(configuration config.Config) Container {
+	reloadableScheduler := analyzer.NewReloadableScheduler(newAnalysisScheduler(configuration))
+	policyExplainer := explain.NewExplainer(reloadableScheduler)
+	return Container{
+		AnalysisScheduler:   reloadableScheduler,
+		PolicyExplainer:     policyExplainer,
+		reloadableScheduler: reloadableScheduler,
+	}
+}
+
+// Reload analyzes with the analyzers of the configuration from now on, starting with the last analyzed cluster states
+func (container Container) Reload(configuration config.Config) {
+	container.reloadableScheduler.Reload(newAnalysisScheduler(configuration))
+}
+
+func newAnalysisScheduler(configuration config.Config) analyzer.AnalysisScheduler {
+	namespaceAnalyzer := namespace.NewAnalyzer()
 	podAnalyzer := pod.NewAnalyzer()
 	podIsolationAnalyzer := podisolation.NewAnalyzer()
 	allowedRouteAnalyzer := allowedroute.NewAnalyzer()
-	trafficAnalyzer := traffic.NewAnalyzer(podIsolationAnalyzer, allowedRouteAnalyzer)
+	enforcementAnalyzer := enforcement.NewAnalyzer()
+	trafficAnalyzer := traffic.NewAnalyzer(podIsolationAnalyzer, allowedRouteAnalyzer, enforcementAnalyzer,
+		configuration.Exclusions)
 	serviceAnalyzer := service.NewAnalyzer()
 	ingressAnalyzer := ingress.NewAnalyzer()
 	replicaSetAnalyzer := replicaset.NewAnalyzer()
@@ -36,8 +71,20 @@ func dependencyInjection() Container {
 		daemonSetAnalyzer, deploymentAnalyzer)
 	podHealthAnalyzer := podhealth.NewAnalyzer()
 	healthAnalyzer := health.NewAnalyzer(podHealthAnalyzer)
-	analysisScheduler := analyzer.NewAnalysisScheduler(podAnalyzer, trafficAnalyzer, workloadAnalyzer, healthAnalyzer)
-	return Container{
-		AnalysisScheduler: analysisScheduler,
-	}
+	capabilityAnalyzer := capability.NewAnalyzer()
+	findingAnalyzer := finding.NewAnalyzer(configuration.Rules, configuration.Intents, configuration.Monitoring,
+		configuration.PolicyExpiry, configuration.Compliance, configuration.Messages)
+	intentAnalyzer := intent.NewAnalyzer(configuration.Intents)
+	tighteningAnalyzer := tightening.NewAnalyzer()
+	policyAnalyzer := networkpolicy.NewAnalyzer(configuration.Exceptions)
+	systemAnalyzer := system.NewAnalyzer()
+	riskAnalyzer := risk.NewAnalyzer(configuration.Risk)
+	hostPortAnalyzer := hostport.NewAnalyzer(configuration.HostPorts)
+	hairpinAnalyzer := hairpin.NewAnalyzer()
+	networkAnalyzer := clusternetwork.NewAnalyzer(configuration.Network)
+	extensionAnalyzer := extension.NewAnalyzer(extension.Registered())
+	return analyzer.NewAnalysisScheduler(podAnalyzer, trafficAnalyzer, workloadAnalyzer, healthAnalyzer,
+		capabilityAnalyzer, findingAnalyzer, intentAnalyzer, namespaceAnalyzer,
+		tighteningAnalyzer, policyAnalyzer, systemAnalyzer, riskAnalyzer, hostPortAnalyzer,
+		hairpinAnalyzer, networkAnalyzer, extensionAnalyzer)
 }
@@ -0,0 +1,195 @@
+package crd
+
+import (
+	"context"
+	"encoding/json"
+	"k8s.io/client-go/kubernetes"
+	"karto/config"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"reflect"
+	"time"
+)
+
+const (
+	Group                 = "karto.zenika.com"
+	Version               = "v1alpha1"
+	kartoConfigsPath      = "/apis/" + Group + "/" + Version + "/kartoconfigs"
+	connectivityRulesPath = "/apis/" + Group + "/" + Version + "/connectivityrules"
+)
+
+type Options struct {
+	Enabled          bool
+	Interval         time.Duration
+	SuppressionStore *suppression.DeclaredStore
+}
+
+// Declarations are the intents, suppressions and connectivity rules declared by tenants as custom resources
+type Declarations struct {
+	Intents           []config.Intent
+	Suppressions      []*types.FindingSuppression
+	ConnectivityRules []config.ConnectivityRule
+}
+
+type objectMeta struct {
+	Name              string    `json:"name"`
+	Namespace         string    `json:"namespace"`
+	UID               string    `json:"uid"`
+	CreationTimestamp time.Time `json:"creationTimestamp"`
+}
+
+type kartoConfigList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Intents      []config.Intent             `json:"intents"`
+			Suppressions []*types.FindingSuppression `json:"suppressions"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type connectivityRuleList struct {
+	Items []struct {
+		Metadata objectMeta              `json:"metadata"`
+		Spec     config.ConnectivityRule `json:"spec"`
+		Status   *ruleStatus             `json:"status"`
+	} `json:"items"`
+}
+
+type watcher struct {
+	list              func() ([]byte, []byte, error)
+	lastDeclarations  Declarations
+	lastError         string
+	declarationsKnown bool
+}
+
+// Track adds the latest declarations to each cluster state, and sends the last cluster state again when they change
+// so that it is analyzed with them. The custom resources are listed periodically through the raw REST client, their
+// client not being part of client-go.
+func Track(k8sClient kubernetes.Interface, options Options, clusterStateChannel <-chan types.ClusterState,
+	declaredClusterStateChannel chan<- types.ClusterState) {
+	watcher := &watcher{list: func() ([]byte, []byte, error) {
+		return listCustomResources(context.Background(), k8sClient)
+	}}
+	declarationsChannel := make(chan Declarations)
+	go func() {
+		for {
+			declarations, changed := watcher.poll()
+			if changed {
+				declarationsChannel <- declarations
+			}
+			time.Sleep(options.Interval)
+		}
+	}()
+	var lastClusterState *types.ClusterState
+	declarations := Declarations{}
+	for {
+		select {
+		case clusterState := <-clusterStateChannel:
+			lastClusterState = &clusterState
+		case declarations = <-declarationsChannel:
+			if options.SuppressionStore != nil {
+				options.SuppressionStore.Declare(declarations.Suppressions)
+			}
+			if lastClusterState == nil {
+				continue
+			}
+		}
+		declaredClusterStateChannel <- declare(*lastClusterState, declarations)
+	}
+}
+
+func declare(clusterState types.ClusterState, declarations Declarations) types.ClusterState {
+	clusterState.Intents = declarations.Intents
+	clusterState.ConnectivityRules = declarations.ConnectivityRules
+	return clusterState
+}
+
+// Listing errors, such as the custom resource definitions not being installed, are only logged when they change
+func (watcher *watcher) poll() (Declarations, bool) {
+	kartoConfigs, connectivityRules, err := watcher.list()
+	if err == nil {
+		var declarations Declarations
+		declarations, err = decodeDeclarations(kartoConfigs, connectivityRules)
+		if err == nil {
+			watcher.lastError = ""
+			if watcher.declarationsKnown && reflect.DeepEqual(declarations, watcher.lastDeclarations) {
+				return declarations, false
+			}
+			watcher.lastDeclarations = declarations
+			watcher.declarationsKnown = true
+			return declarations, true
+		}
+	}
+	if err.Error() != watcher.lastError {
+		log.Printf("Unable to list the custom resources of %s: %s\n", Group, err)
+		watcher.lastError = err.Error()
+	}
+	return watcher.lastDeclarations, false
+}
+
+func listCustomResources(ctx context.Context, k8sClient kubernetes.Interface) ([]byte, []byte, error) {
+	restClient := k8sClient.Discovery().RESTClient()
+	kartoConfigs, err := restClient.Get().AbsPath(kartoConfigsPath).DoRaw(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	connectivityRules, err := restClient.Get().AbsPath(connectivityRulesPath).DoRaw(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kartoConfigs, connectivityRules, nil
+}
+
+// Invalid declarations are skipped rather than failing all the others, which belong to other tenants
+func decodeDeclarations(kartoConfigsContent []byte, connectivityRulesContent []byte) (Declarations, error) {
+	declarations := Declarations{
+		Intents:           make([]config.Intent, 0),
+		Suppressions:      make([]*types.FindingSuppression, 0),
+		ConnectivityRules: make([]config.ConnectivityRule, 0),
+	}
+	var kartoConfigs kartoConfigList
+	err := json.Unmarshal(kartoConfigsContent, &kartoConfigs)
+	if err != nil {
+		return declarations, err
+	}
+	var connectivityRules connectivityRuleList
+	err = json.Unmarshal(connectivityRulesContent, &connectivityRules)
+	if err != nil {
+		return declarations, err
+	}
+	for _, item := range kartoConfigs.Items {
+		for i, intent := range item.Spec.Intents {
+			if intent.Purpose == "" {
+				log.Printf("Ignoring intent #%d of KartoConfig %s/%s, which has no purpose\n", i+1,
+					item.Metadata.Namespace, item.Metadata.Name)
+				continue
+			}
+			declarations.Intents = append(declarations.Intents, intent)
+		}
+		for i, suppression := range item.Spec.Suppressions {
+			if suppression == nil || suppression.Fingerprint == "" || suppression.Reason == "" {
+				log.Printf("Ignoring suppression #%d of KartoConfig %s/%s, which requires a fingerprint and a "+
+					"reason\n", i+1, item.Metadata.Namespace, item.Metadata.Name)
+				continue
+			}
+			if suppression.CreatedAt.IsZero() {
+				suppression.CreatedAt = item.Metadata.CreationTimestamp
+			}
+			declarations.Suppressions = append(declarations.Suppressions, suppression)
+		}
+	}
+	for _, item := range connectivityRules.Items {
+		rule := item.Spec
+		rule.Name = item.Metadata.Name
+		rule.Namespace = item.Metadata.Namespace
+		err = rule.Validate()
+		if err != nil {
+			log.Printf("Ignoring invalid %s\n", err)
+			continue
+		}
+		declarations.ConnectivityRules = append(declarations.ConnectivityRules, rule)
+	}
+	return declarations, nil
+}
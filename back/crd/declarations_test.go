@@ -0,0 +1,91 @@
+package crd
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestDecodeDeclarations(t *testing.T) {
+	kartoConfigs := `{"items":[{"metadata":{"name":"shop","namespace":"shop",
+		"creationTimestamp":"2021-04-01T12:00:00Z"},"spec":{
+		"intents":[{"purpose":"front calls the API","source":{"podLabels":{"app":"front"}},
+			"target":{"podLabels":{"app":"api"}}},{"source":{"namespace":"shop"}}],
+		"suppressions":[{"fingerprint":"621a33e21f772146","reason":"accepted by the shop team"},{"reason":"why"}]}}]}`
+	connectivityRules := `{"items":[
+		{"metadata":{"name":"no-db","namespace":"shop"},"spec":{"expect":"denied",
+			"source":{"namespace":"dev"},"target":{"podLabels":{"app":"db"}}}},
+		{"metadata":{"name":"invalid","namespace":"shop"},"spec":{"expect":"maybe"}}]}`
+	declarations, err := decodeDeclarations([]byte(kartoConfigs), []byte(connectivityRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDeclarations := Declarations{
+		Intents: []config.Intent{
+			{Purpose: "front calls the API", Source: config.PodSelector{PodLabels: map[string]string{"app": "front"}},
+				Target: config.PodSelector{PodLabels: map[string]string{"app": "api"}}},
+		},
+		Suppressions: []*types.FindingSuppression{
+			{Fingerprint: "621a33e21f772146", Reason: "accepted by the shop team",
+				CreatedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		ConnectivityRules: []config.ConnectivityRule{
+			{Name: "no-db", Namespace: "shop", Expect: config.ExpectDenied, Source: config.PodSelector{Namespace: "dev"},
+				Target: config.PodSelector{PodLabels: map[string]string{"app": "db"}}},
+		},
+	}
+	if diff := cmp.Diff(expectedDeclarations, declarations); diff != "" {
+		t.Errorf("decodeDeclarations() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	emptyList := []byte(`{"items":[]}`)
+	ruleList := []byte(`{"items":[{"metadata":{"name":"no-db","namespace":"shop"},"spec":{"expect":"denied"}}]}`)
+	tests := []struct {
+		name            string
+		connectivity    []byte
+		err             error
+		expectedChanged bool
+		expectedRules   int
+	}{
+		{
+			name:            "the first declarations are a change",
+			connectivity:    emptyList,
+			expectedChanged: true,
+		},
+		{
+			name:         "identical declarations are not a change",
+			connectivity: emptyList,
+		},
+		{
+			name:            "a new connectivity rule is a change",
+			connectivity:    ruleList,
+			expectedChanged: true,
+			expectedRules:   1,
+		},
+		{
+			name:          "listing errors keep the last declarations",
+			err:           fmt.Errorf("the server could not find the requested resource"),
+			expectedRules: 1,
+		},
+	}
+	watcher := &watcher{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher.list = func() ([]byte, []byte, error) {
+				return emptyList, tt.connectivity, tt.err
+			}
+			declarations, changed := watcher.poll()
+			if diff := cmp.Diff(tt.expectedChanged, changed); diff != "" {
+				t.Errorf("poll() changed mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRules, len(declarations.ConnectivityRules)); diff != "" {
+				t.Errorf("poll() connectivity rules mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
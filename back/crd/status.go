@@ -0,0 +1,176 @@
+package crd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"karto/analyzer/finding"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	EventReasonViolated = "ConnectivityRuleViolated"
+	// Rules matching many pods may break their expectation for many routes, only the first ones are listed
+	violationsLimit = 20
+)
+
+type ruleStatus struct {
+	Satisfied          bool      `json:"satisfied"`
+	ViolationCount     int       `json:"violationCount"`
+	Violations         []string  `json:"violations"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+type statusReporter struct {
+	k8sClient        kubernetes.Interface
+	suppressionStore suppression.Store
+	listRules        func(ctx context.Context) ([]byte, error)
+	patchStatus      func(ctx context.Context, namespace string, name string, patch []byte) error
+	now              func() time.Time
+}
+
+// ReportStatus writes the evaluation of the connectivity rules by each analysis into their status, and emits a
+// warning event for each route newly breaking their expectation. Suppressed findings are not violations.
+func ReportStatus(k8sClient kubernetes.Interface, suppressionStore suppression.Store,
+	resultsChannel <-chan types.AnalysisResult) {
+	restClient := k8sClient.Discovery().RESTClient()
+	reporter := &statusReporter{
+		k8sClient:        k8sClient,
+		suppressionStore: suppressionStore,
+		listRules: func(ctx context.Context) ([]byte, error) {
+			return restClient.Get().AbsPath(connectivityRulesPath).DoRaw(ctx)
+		},
+		patchStatus: func(ctx context.Context, namespace string, name string, patch []byte) error {
+			return restClient.Patch(k8stypes.MergePatchType).
+				AbsPath("/apis", Group, Version, "namespaces", namespace, "connectivityrules", name, "status").
+				Body(patch).Do(ctx).Error()
+		},
+		now: time.Now,
+	}
+	for {
+		analysisResult := <-resultsChannel
+		err := reporter.report(context.Background(), analysisResult)
+		if err != nil {
+			log.Printf("Unable to report the status of the connectivity rules: %s\n", err)
+		}
+	}
+}
+
+func (reporter *statusReporter) report(ctx context.Context, analysisResult types.AnalysisResult) error {
+	content, err := reporter.listRules(ctx)
+	if err != nil {
+		return err
+	}
+	var rules connectivityRuleList
+	err = json.Unmarshal(content, &rules)
+	if err != nil {
+		return err
+	}
+	findings := analysisResult.Findings
+	if reporter.suppressionStore != nil {
+		suppressions, err := reporter.suppressionStore.List()
+		if err != nil {
+			return err
+		}
+		findings = suppression.Apply(findings, suppressions)
+	}
+	violationsByRule := make(map[string][]*types.Finding)
+	for _, ruleFinding := range findings {
+		if ruleFinding.Suppression != nil || (ruleFinding.Code != finding.CodeRouteExpectedAllowed &&
+			ruleFinding.Code != finding.CodeRouteExpectedDenied) {
+			continue
+		}
+		violationsByRule[ruleFinding.Rule] = append(violationsByRule[ruleFinding.Rule], ruleFinding)
+	}
+	for _, rule := range rules.Items {
+		violations := violationsByRule[rule.Metadata.Namespace+"/"+rule.Metadata.Name]
+		sort.Slice(violations, func(i, j int) bool {
+			return violationOf(violations[i]) < violationOf(violations[j])
+		})
+		status := reporter.statusOf(rule.Status, violations)
+		if rule.Status != nil && reflect.DeepEqual(*rule.Status, status) {
+			continue
+		}
+		patch, err := json.Marshal(map[string]ruleStatus{"status": status})
+		if err != nil {
+			return err
+		}
+		err = reporter.patchStatus(ctx, rule.Metadata.Namespace, rule.Metadata.Name, patch)
+		if err != nil {
+			return err
+		}
+		reporter.emitEvents(ctx, rule.Metadata, rule.Status, violations)
+	}
+	return nil
+}
+
+func (reporter *statusReporter) statusOf(previousStatus *ruleStatus, violations []*types.Finding) ruleStatus {
+	status := ruleStatus{
+		Satisfied:          len(violations) == 0,
+		ViolationCount:     len(violations),
+		Violations:         make([]string, 0),
+		LastTransitionTime: reporter.now().UTC().Truncate(time.Second),
+	}
+	for i, violation := range violations {
+		if i == violationsLimit {
+			break
+		}
+		status.Violations = append(status.Violations, violationOf(violation))
+	}
+	if previousStatus != nil && previousStatus.Satisfied == status.Satisfied {
+		status.LastTransitionTime = previousStatus.LastTransitionTime
+	}
+	return status
+}
+
+// Events are best effort, the status holding the evaluation anyway
+func (reporter *statusReporter) emitEvents(ctx context.Context, rule objectMeta, previousStatus *ruleStatus,
+	violations []*types.Finding) {
+	known := make(map[string]bool)
+	if previousStatus != nil {
+		for _, violation := range previousStatus.Violations {
+			known[violation] = true
+		}
+	}
+	now := metav1.NewTime(reporter.now())
+	for i, violation := range violations {
+		if i == violationsLimit {
+			break
+		}
+		if known[violationOf(violation)] {
+			continue
+		}
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: rule.Name + ".", Namespace: rule.Namespace},
+			InvolvedObject: corev1.ObjectReference{APIVersion: Group + "/" + Version, Kind: "ConnectivityRule",
+				Name: rule.Name, Namespace: rule.Namespace, UID: k8stypes.UID(rule.UID)},
+			Reason:         EventReasonViolated,
+			Message:        violation.Message,
+			Type:           corev1.EventTypeWarning,
+			Source:         corev1.EventSource{Component: "karto"},
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+		}
+		_, err := reporter.k8sClient.CoreV1().Events(rule.Namespace).Create(ctx, event, metav1.CreateOptions{})
+		if err != nil {
+			log.Printf("Unable to emit an event for connectivity rule %s/%s: %s\n", rule.Namespace, rule.Name, err)
+			return
+		}
+	}
+}
+
+func violationOf(violation *types.Finding) string {
+	parameters := violation.Parameters
+	return fmt.Sprintf("%s/%s -> %s/%s", parameters["sourceNamespace"], parameters["sourcePod"],
+		parameters["targetNamespace"], parameters["targetPod"])
+}
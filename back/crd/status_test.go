@@ -0,0 +1,93 @@
+package crd
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"karto/analyzer/finding"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestReportStatus(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	dbRef := types.PodRef{Name: "db", Namespace: "shop"}
+	adminRef := types.PodRef{Name: "admin", Namespace: "shop"}
+	violationOfRoute := func(source types.PodRef) *types.Finding {
+		return finding.NewRouteFinding("shop/no-db", finding.SeverityMedium, source, dbRef,
+			finding.CodeRouteExpectedDenied, map[string]string{"sourceNamespace": source.Namespace,
+				"sourcePod": source.Name, "targetNamespace": "shop", "targetPod": "db", "rule": "shop/no-db"})
+	}
+	tests := []struct {
+		name            string
+		rules           string
+		findings        []*types.Finding
+		expectedPatches []string
+		expectedEvents  []string
+	}{
+		{
+			name:     "a satisfied rule without status is given one",
+			rules:    `{"items":[{"metadata":{"name":"no-db","namespace":"shop"},"spec":{"expect":"denied"}}]}`,
+			findings: []*types.Finding{},
+			expectedPatches: []string{`{"status":{"satisfied":true,"violationCount":0,"violations":[],` +
+				`"lastTransitionTime":"2021-04-01T12:00:00Z"}}`},
+		},
+		{
+			name: "only new violations are reported as events",
+			rules: `{"items":[{"metadata":{"name":"no-db","namespace":"shop"},"spec":{"expect":"denied"},` +
+				`"status":{"satisfied":false,"violationCount":1,"violations":["shop/front -> shop/db"],` +
+				`"lastTransitionTime":"2021-03-01T12:00:00Z"}}]}`,
+			findings: []*types.Finding{violationOfRoute(frontRef), violationOfRoute(adminRef)},
+			expectedPatches: []string{`{"status":{"satisfied":false,"violationCount":2,"violations":` +
+				`["shop/admin -\u003e shop/db","shop/front -\u003e shop/db"],` +
+				`"lastTransitionTime":"2021-03-01T12:00:00Z"}}`},
+			expectedEvents: []string{"traffic from pod shop/admin to pod shop/db is allowed but connectivity rule " +
+				"shop/no-db expects it denied"},
+		},
+		{
+			name: "an unchanged status is not patched",
+			rules: `{"items":[{"metadata":{"name":"no-db","namespace":"shop"},"spec":{"expect":"denied"},` +
+				`"status":{"satisfied":false,"violationCount":1,"violations":["shop/front -> shop/db"],` +
+				`"lastTransitionTime":"2021-03-01T12:00:00Z"}}]}`,
+			findings: []*types.Finding{violationOfRoute(frontRef)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset()
+			patches := make([]string, 0)
+			reporter := &statusReporter{
+				k8sClient: k8sClient,
+				listRules: func(ctx context.Context) ([]byte, error) {
+					return []byte(tt.rules), nil
+				},
+				patchStatus: func(ctx context.Context, namespace string, name string, patch []byte) error {
+					patches = append(patches, string(patch))
+					return nil
+				},
+				now: func() time.Time { return now },
+			}
+			err := reporter.report(context.Background(), types.AnalysisResult{Findings: tt.findings})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(append(make([]string, 0), tt.expectedPatches...), patches); diff != "" {
+				t.Errorf("report() patches mismatch (-want +got):\n%s", diff)
+			}
+			events, err := k8sClient.CoreV1().Events("shop").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := make([]string, 0)
+			for _, event := range events.Items {
+				messages = append(messages, event.Message)
+			}
+			if diff := cmp.Diff(append(make([]string, 0), tt.expectedEvents...), messages); diff != "" {
+				t.Errorf("report() events mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
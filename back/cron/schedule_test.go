@@ -0,0 +1,104 @@
+package cron
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	type args struct {
+		expression string
+		after      time.Time
+	}
+	// 2021-04-01 is a Thursday
+	after := time.Date(2021, time.April, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name         string
+		args         args
+		expectedNext time.Time
+	}{
+		{
+			name:         "every minute",
+			args:         args{expression: "* * * * *", after: after},
+			expectedNext: time.Date(2021, time.April, 1, 12, 31, 0, 0, time.UTC),
+		},
+		{
+			name:         "weekly on monday morning",
+			args:         args{expression: "0 8 * * 1", after: after},
+			expectedNext: time.Date(2021, time.April, 5, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "sunday can be written 7",
+			args:         args{expression: "0 0 * * 7", after: after},
+			expectedNext: time.Date(2021, time.April, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "steps, ranges and lists",
+			args:         args{expression: "*/20 9-17/4 * 4,5 *", after: after},
+			expectedNext: time.Date(2021, time.April, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "a restricted day of month or day of week matches",
+			args:         args{expression: "0 0 15 * 5", after: after},
+			expectedNext: time.Date(2021, time.April, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "a day which never happens does not match",
+			args:         args{expression: "0 0 30 2 *", after: after},
+			expectedNext: time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.args.expression)
+			if err != nil {
+				t.Fatalf("Parse() returned an error: %s", err)
+			}
+			if diff := cmp.Diff(tt.expectedNext, schedule.Next(tt.args.after)); diff != "" {
+				t.Errorf("Next() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		expression    string
+		expectedError string
+	}{
+		{
+			name:          "five fields are expected",
+			expression:    "0 8 * *",
+			expectedError: "invalid cron expression \"0 8 * *\": expected 5 fields",
+		},
+		{
+			name:          "values are bounded",
+			expression:    "0 24 * * *",
+			expectedError: "invalid cron expression \"0 24 * * *\": hour field \"24\" is out of range 0-23",
+		},
+		{
+			name:          "steps are positive",
+			expression:    "*/0 * * * *",
+			expectedError: "invalid cron expression \"*/0 * * * *\": invalid step in minute field \"*/0\"",
+		},
+		{
+			name:          "values are numbers",
+			expression:    "0 8 * * mon",
+			expectedError: "invalid cron expression \"0 8 * * mon\": invalid day of week field \"mon\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("Parse() error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package drift
+
+import (
+	"karto/routeports"
+	"karto/types"
+	"sort"
+	"time"
+)
+
+type workloadRoute struct {
+	source types.ResourceRef
+	target types.ResourceRef
+}
+
+type policyRef struct {
+	name      string
+	namespace string
+}
+
+type routePorts struct {
+	ports           routeports.Set
+	ingressPolicies map[policyRef]types.NetworkPolicy
+	egressPolicies  map[policyRef]types.NetworkPolicy
+}
+
+func Track(liveResultsChannel <-chan types.AnalysisResult, desiredResultsChannel <-chan types.AnalysisResult,
+	driftedResultsChannel chan<- types.AnalysisResult, trackedDesiredResultsChannel chan<- types.AnalysisResult) {
+	var lastLiveResult, lastDesiredResult *types.AnalysisResult
+	for {
+		select {
+		case liveResult := <-liveResultsChannel:
+			lastLiveResult = &liveResult
+		case desiredResult := <-desiredResultsChannel:
+			lastDesiredResult = &desiredResult
+			trackedDesiredResultsChannel <- desiredResult
+			if lastLiveResult == nil {
+				continue
+			}
+		}
+		liveResult := *lastLiveResult
+		if lastDesiredResult != nil {
+			liveResult.Drift = Compute(liveResult, *lastDesiredResult)
+		}
+		driftedResultsChannel <- liveResult
+	}
+}
+
+func Compute(liveResult types.AnalysisResult, desiredResult types.AnalysisResult) *types.DriftReport {
+	liveRoutes := workloadRoutes(liveResult)
+	desiredRoutes := workloadRoutes(desiredResult)
+	return &types.DriftReport{
+		LiveOnly:    routesOnlyIn(liveRoutes, desiredRoutes),
+		DesiredOnly: routesOnlyIn(desiredRoutes, liveRoutes),
+		ComputedAt:  time.Now(),
+	}
+}
+
+// Pods of a same workload have different names in the live cluster and in the manifests, routes are therefore
+// compared between workloads
+func workloadRoutes(analysisResult types.AnalysisResult) map[workloadRoute]*routePorts {
+	workloads := PodWorkloads(analysisResult)
+	routes := make(map[workloadRoute]*routePorts)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		key := workloadRoute{source: workloads(allowedRoute.SourcePod), target: workloads(allowedRoute.TargetPod)}
+		route, ok := routes[key]
+		if !ok {
+			route = &routePorts{
+				ports:           routeports.None(),
+				ingressPolicies: make(map[policyRef]types.NetworkPolicy),
+				egressPolicies:  make(map[policyRef]types.NetworkPolicy),
+			}
+			routes[key] = route
+		}
+		route.ports = routeports.Merge(route.ports, routeports.Of(allowedRoute))
+		for _, policy := range allowedRoute.IngressPolicies {
+			route.ingressPolicies[policyRef{name: policy.Name, namespace: policy.Namespace}] = policy
+		}
+		for _, policy := range allowedRoute.EgressPolicies {
+			route.egressPolicies[policyRef{name: policy.Name, namespace: policy.Namespace}] = policy
+		}
+	}
+	return routes
+}
+
+func PodWorkloads(analysisResult types.AnalysisResult) func(podRef types.PodRef) types.ResourceRef {
+	workloadsByPod := make(map[types.PodRef]types.ResourceRef)
+	workloadsByName := make(map[types.PodRef]types.ResourceRef)
+	addWorkload := func(workloadRef types.ResourceRef, targetPods []types.PodRef) {
+		workloadsByName[types.PodRef{Name: workloadRef.Name, Namespace: workloadRef.Namespace}] = workloadRef
+		for _, podRef := range targetPods {
+			workloadsByPod[podRef] = workloadRef
+		}
+	}
+	replicaSetOwners := make(map[types.ReplicaSetRef]types.ResourceRef)
+	for _, deployment := range analysisResult.Deployments {
+		deploymentRef := types.ResourceRef{Kind: "Deployment", Name: deployment.Name, Namespace: deployment.Namespace}
+		for _, replicaSetRef := range deployment.TargetReplicaSets {
+			replicaSetOwners[replicaSetRef] = deploymentRef
+		}
+	}
+	for _, replicaSet := range analysisResult.ReplicaSets {
+		workloadRef, ok := replicaSetOwners[types.ReplicaSetRef{Name: replicaSet.Name, Namespace: replicaSet.Namespace}]
+		if !ok {
+			workloadRef = types.ResourceRef{Kind: "ReplicaSet", Name: replicaSet.Name, Namespace: replicaSet.Namespace}
+		}
+		addWorkload(workloadRef, replicaSet.TargetPods)
+	}
+	for _, statefulSet := range analysisResult.StatefulSets {
+		addWorkload(types.ResourceRef{Kind: "StatefulSet", Name: statefulSet.Name, Namespace: statefulSet.Namespace},
+			statefulSet.TargetPods)
+	}
+	for _, daemonSet := range analysisResult.DaemonSets {
+		addWorkload(types.ResourceRef{Kind: "DaemonSet", Name: daemonSet.Name, Namespace: daemonSet.Namespace},
+			daemonSet.TargetPods)
+	}
+	for _, deployment := range analysisResult.Deployments {
+		addWorkload(types.ResourceRef{Kind: "Deployment", Name: deployment.Name, Namespace: deployment.Namespace}, nil)
+	}
+	return func(podRef types.PodRef) types.ResourceRef {
+		if workloadRef, ok := workloadsByPod[podRef]; ok {
+			return workloadRef
+		}
+		// Pods built from the templates of static manifests are named after their workload
+		if workloadRef, ok := workloadsByName[podRef]; ok {
+			return workloadRef
+		}
+		return types.ResourceRef{Kind: "Pod", Name: podRef.Name, Namespace: podRef.Namespace}
+	}
+}
+
+func routesOnlyIn(routes map[workloadRoute]*routePorts,
+	otherRoutes map[workloadRoute]*routePorts) []*types.RouteDrift {
+	drifts := make([]*types.RouteDrift, 0)
+	for key, route := range routes {
+		ports := route.ports
+		if otherRoute, ok := otherRoutes[key]; ok {
+			ports = routeports.Subtract(route.ports, otherRoute.ports)
+			if ports.IsEmpty() {
+				continue
+			}
+		}
+		drifts = append(drifts, &types.RouteDrift{
+			Source:          key.source,
+			Target:          key.target,
+			Ports:           ports.Ports,
+			PortRanges:      ports.PortRanges,
+			ProtocolPorts:   ports.ProtocolPorts,
+			IngressPolicies: sortedPolicies(route.ingressPolicies),
+			EgressPolicies:  sortedPolicies(route.egressPolicies),
+		})
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Source != drifts[j].Source {
+			return resourceLess(drifts[i].Source, drifts[j].Source)
+		}
+		return resourceLess(drifts[i].Target, drifts[j].Target)
+	})
+	return drifts
+}
+
+func sortedPolicies(policiesByRef map[policyRef]types.NetworkPolicy) []types.NetworkPolicy {
+	policies := make([]types.NetworkPolicy, 0)
+	for _, policy := range policiesByRef {
+		policies = append(policies, policy)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies
+}
+
+func resourceLess(resource1 types.ResourceRef, resource2 types.ResourceRef) bool {
+	if resource1.Namespace != resource2.Namespace {
+		return resource1.Namespace < resource2.Namespace
+	}
+	if resource1.Name != resource2.Name {
+		return resource1.Name < resource2.Name
+	}
+	return resource1.Kind < resource2.Kind
+}
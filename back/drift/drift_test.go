@@ -0,0 +1,98 @@
+package drift
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	frontPod1 := types.PodRef{Name: "front-5d8f-abc", Namespace: "shop"}
+	frontPod2 := types.PodRef{Name: "front-5d8f-def", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-0", Namespace: "shop"}
+	debugPod := types.PodRef{Name: "debug", Namespace: "shop"}
+	front := types.ResourceRef{Kind: "Deployment", Name: "front", Namespace: "shop"}
+	api := types.ResourceRef{Kind: "StatefulSet", Name: "api", Namespace: "shop"}
+	debug := types.ResourceRef{Kind: "Pod", Name: "debug", Namespace: "shop"}
+	apiPolicy := types.NetworkPolicy{Name: "api", Namespace: "shop"}
+	liveResult := types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: frontPod1, TargetPod: apiPod, Ports: []int32{80, 8080},
+				IngressPolicies: []types.NetworkPolicy{apiPolicy}},
+			{SourcePod: frontPod2, TargetPod: apiPod, Ports: []int32{80},
+				IngressPolicies: []types.NetworkPolicy{apiPolicy}},
+			{SourcePod: debugPod, TargetPod: apiPod, Ports: []int32{80},
+				IngressPolicies: []types.NetworkPolicy{apiPolicy}},
+		},
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front-5d8f", Namespace: "shop", TargetPods: []types.PodRef{frontPod1, frontPod2}},
+		},
+		Deployments: []*types.Deployment{
+			{Name: "front", Namespace: "shop",
+				TargetReplicaSets: []types.ReplicaSetRef{{Name: "front-5d8f", Namespace: "shop"}}},
+		},
+		StatefulSets: []*types.StatefulSet{
+			{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+		},
+	}
+	desiredResult := types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "front", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "api", Namespace: "shop"}, Ports: []int32{80},
+				IngressPolicies: []types.NetworkPolicy{apiPolicy}},
+			{SourcePod: types.PodRef{Name: "api", Namespace: "shop"},
+				TargetPod: types.PodRef{Name: "front", Namespace: "shop"}, Ports: nil},
+		},
+		Deployments:  []*types.Deployment{{Name: "front", Namespace: "shop"}},
+		StatefulSets: []*types.StatefulSet{{Name: "api", Namespace: "shop"}},
+	}
+	expectedReport := &types.DriftReport{
+		LiveOnly: []*types.RouteDrift{
+			{Source: debug, Target: api, Ports: []int32{80}, IngressPolicies: []types.NetworkPolicy{apiPolicy},
+				EgressPolicies: []types.NetworkPolicy{}},
+			{Source: front, Target: api, Ports: []int32{8080}, IngressPolicies: []types.NetworkPolicy{apiPolicy},
+				EgressPolicies: []types.NetworkPolicy{}},
+		},
+		DesiredOnly: []*types.RouteDrift{
+			{Source: api, Target: front, Ports: nil, IngressPolicies: []types.NetworkPolicy{},
+				EgressPolicies: []types.NetworkPolicy{}},
+		},
+	}
+	report := Compute(liveResult, desiredResult)
+	if diff := cmp.Diff(expectedReport, report, cmpopts.IgnoreFields(types.DriftReport{}, "ComputedAt")); diff != "" {
+		t.Errorf("Compute() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestComputePortRanges(t *testing.T) {
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	api := types.PodRef{Name: "api", Namespace: "shop"}
+	rangedRoute := func(endPort int32) *types.AllowedRoute {
+		portRanges := []types.PortRange{{Port: 8000, EndPort: endPort}}
+		return &types.AllowedRoute{SourcePod: front, TargetPod: api, Ports: []int32{}, PortRanges: portRanges,
+			ProtocolPorts: []types.ProtocolPorts{
+				{Protocol: corev1.ProtocolTCP, Ports: []int32{}, PortRanges: portRanges},
+			}}
+	}
+	liveResult := types.AnalysisResult{AllowedRoutes: []*types.AllowedRoute{rangedRoute(8100)}}
+	desiredResult := types.AnalysisResult{AllowedRoutes: []*types.AllowedRoute{rangedRoute(8050)}}
+	expectedReport := &types.DriftReport{
+		LiveOnly: []*types.RouteDrift{
+			{Source: types.ResourceRef{Kind: "Pod", Name: "front", Namespace: "shop"},
+				Target: types.ResourceRef{Kind: "Pod", Name: "api", Namespace: "shop"}, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8051, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{
+					{Protocol: corev1.ProtocolTCP, Ports: []int32{},
+						PortRanges: []types.PortRange{{Port: 8051, EndPort: 8100}}},
+				},
+				IngressPolicies: []types.NetworkPolicy{}, EgressPolicies: []types.NetworkPolicy{}},
+		},
+		DesiredOnly: []*types.RouteDrift{},
+	}
+	report := Compute(liveResult, desiredResult)
+	if diff := cmp.Diff(expectedReport, report, cmpopts.IgnoreFields(types.DriftReport{}, "ComputedAt")); diff != "" {
+		t.Errorf("Compute() result mismatch (-want +got):\n%s", diff)
+	}
+}
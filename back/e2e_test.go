@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"karto/client"
+	"karto/config"
+	"karto/exposition"
+	"karto/manifest"
+	"karto/trigger"
+	"karto/types"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+const resultTimeout = 10 * time.Second
+
+var errResultFound = errors.New("result found")
+
+// pipeline runs the analysis of the explorer against a fake cluster loaded from a fixture, and serves its API
+type pipeline struct {
+	k8sClient *fake.Clientset
+	apiClient *client.Client
+}
+
+func startPipeline(t *testing.T, fixture string) pipeline {
+	return startPipelineWithTimeout(t, fixture, 0)
+}
+
+// startPipelineWithTimeout starts the pipeline with the API requests abandoned after the timeout, 0 to disable it
+func startPipelineWithTimeout(t *testing.T, fixture string, requestTimeout time.Duration) pipeline {
+	clusterState, err := manifest.LoadFile(filepath.Join("testdata", "e2e", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewSimpleClientset(objectsOf(clusterState)...)
+	configuration, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := dependencyInjection(configuration)
+	cmd := commandLine{
+		trigger: trigger.Options{Mode: trigger.ModeWatch},
+		exposition: exposition.Options{DisableFrontend: true, PolicyExplainer: container.PolicyExplainer,
+			RequestTimeout: requestTimeout},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	analysisResultsChannel := analyzeCluster(k8sClient, dynamicClient, container, &cmd)
+	server := httptest.NewServer(exposition.Handler(analysisResultsChannel, cmd.exposition))
+	t.Cleanup(server.Close)
+	apiClient, err := client.New(server.URL, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pipeline{k8sClient: k8sClient, apiClient: apiClient}
+}
+
+// waitForResult returns the first complete analysis result served by the API which satisfies the condition
+func (pipeline pipeline) waitForResult(t *testing.T,
+	condition func(analysisResult types.AnalysisResult) bool) types.AnalysisResult {
+	ctx, cancel := context.WithTimeout(context.Background(), resultTimeout)
+	defer cancel()
+	var found types.AnalysisResult
+	err := pipeline.apiClient.WatchResults(ctx, func(analysisResult types.AnalysisResult) error {
+		if !analysisResult.Complete || !condition(analysisResult) {
+			return nil
+		}
+		found = analysisResult
+		return errResultFound
+	})
+	if err != errResultFound {
+		t.Fatalf("no analysis result satisfied the condition: %v", err)
+	}
+	return found
+}
+
+func objectsOf(clusterState types.ClusterState) []runtime.Object {
+	objects := make([]runtime.Object, 0)
+	for _, namespace := range clusterState.Namespaces {
+		objects = append(objects, namespace)
+	}
+	for _, node := range clusterState.Nodes {
+		objects = append(objects, node)
+	}
+	for _, pod := range clusterState.Pods {
+		objects = append(objects, pod)
+	}
+	for _, service := range clusterState.Services {
+		objects = append(objects, service)
+	}
+	for _, ingress := range clusterState.Ingresses {
+		objects = append(objects, ingress)
+	}
+	for _, replicaSet := range clusterState.ReplicaSets {
+		objects = append(objects, replicaSet)
+	}
+	for _, statefulSet := range clusterState.StatefulSets {
+		objects = append(objects, statefulSet)
+	}
+	for _, daemonSet := range clusterState.DaemonSets {
+		objects = append(objects, daemonSet)
+	}
+	for _, deployment := range clusterState.Deployments {
+		objects = append(objects, deployment)
+	}
+	for _, policy := range clusterState.NetworkPolicies {
+		objects = append(objects, policy)
+	}
+	for _, webhook := range clusterState.ValidatingWebhookConfigurations {
+		objects = append(objects, webhook)
+	}
+	for _, webhook := range clusterState.MutatingWebhookConfigurations {
+		objects = append(objects, webhook)
+	}
+	return objects
+}
+
+// routesOf describes the allowed routes as sorted source > target:ports strings, so that expectations stay readable
+func routesOf(analysisResult types.AnalysisResult) []string {
+	routes := make([]string, 0, len(analysisResult.AllowedRoutes))
+	for _, route := range analysisResult.AllowedRoutes {
+		ports := "*"
+		if route.Ports != nil {
+			ports = fmt.Sprint(route.Ports)
+		}
+		routes = append(routes, fmt.Sprintf("%s/%s > %s/%s:%s", route.SourcePod.Namespace, route.SourcePod.Name,
+			route.TargetPod.Namespace, route.TargetPod.Name, ports))
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name           string
+		fixture        string
+		expectedPods   int
+		expectedRoutes []string
+	}{
+		{
+			name:         "pods without policies are allowed to reach each other on all ports",
+			fixture:      "default-allow.yaml",
+			expectedPods: 2,
+			expectedRoutes: []string{
+				"shop/back > shop/front:*",
+				"shop/front > shop/back:*",
+			},
+		},
+		{
+			name:         "isolated pods are only reached by the peers and ports their policies allow",
+			fixture:      "isolated-backend.yaml",
+			expectedPods: 3,
+			expectedRoutes: []string{
+				"shop/back > shop/db:[5432]",
+				"shop/back > shop/front:*",
+				"shop/db > shop/front:*",
+				"shop/front > shop/back:[8080]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := startPipeline(t, tt.fixture)
+			analysisResult := pipeline.waitForResult(t, func(types.AnalysisResult) bool { return true })
+			if len(analysisResult.Pods) != tt.expectedPods {
+				t.Errorf("pipeline analyzed %d pods, expected %d", len(analysisResult.Pods), tt.expectedPods)
+			}
+			if diff := cmp.Diff(tt.expectedRoutes, routesOf(analysisResult)); diff != "" {
+				t.Errorf("pipeline routes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPipelineAnalyzesChanges(t *testing.T) {
+	pipeline := startPipeline(t, "isolated-backend.yaml")
+	pipeline.waitForResult(t, func(analysisResult types.AnalysisResult) bool {
+		return len(analysisResult.NetworkPolicies) == 2
+	})
+	err := pipeline.k8sClient.NetworkingV1().NetworkPolicies("shop").Delete(context.Background(), "back",
+		metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysisResult := pipeline.waitForResult(t, func(analysisResult types.AnalysisResult) bool {
+		return len(analysisResult.NetworkPolicies) == 1
+	})
+	expectedRoutes := []string{
+		"shop/back > shop/db:[5432]",
+		"shop/back > shop/front:*",
+		"shop/db > shop/back:*",
+		"shop/db > shop/front:*",
+		"shop/front > shop/back:*",
+	}
+	if diff := cmp.Diff(expectedRoutes, routesOf(analysisResult)); diff != "" {
+		t.Errorf("pipeline routes mismatch after deleting a policy (-want +got):\n%s", diff)
+	}
+}
+
+func TestPipelineStreamsResultsPastRequestTimeout(t *testing.T) {
+	requestTimeout := 100 * time.Millisecond
+	pipeline := startPipelineWithTimeout(t, "isolated-backend.yaml", requestTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), resultTimeout)
+	defer cancel()
+	deleted := false
+	err := pipeline.apiClient.WatchResults(ctx, func(analysisResult types.AnalysisResult) error {
+		if !analysisResult.Complete {
+			return nil
+		}
+		if len(analysisResult.NetworkPolicies) == 1 {
+			return errResultFound
+		}
+		if deleted {
+			return nil
+		}
+		// The policy is only deleted once the stream has outlived the request timeout
+		time.Sleep(3 * requestTimeout)
+		deleted = true
+		return pipeline.k8sClient.NetworkingV1().NetworkPolicies("shop").Delete(context.Background(), "back",
+			metav1.DeleteOptions{})
+	})
+	if err != errResultFound {
+		t.Fatalf("the stream did not push the result analyzed after the request timeout: %v", err)
+	}
+}
+
+func TestPipelineExportsPastRequestTimeout(t *testing.T) {
+	pipeline := startPipelineWithTimeout(t, "isolated-backend.yaml", time.Nanosecond)
+	pipeline.waitForResult(t, func(types.AnalysisResult) bool { return true })
+	pods := make([]string, 0)
+	err := pipeline.apiClient.ExportPods(context.Background(), client.Page{}, func(pod *types.Pod) error {
+		pods = append(pods, pod.Namespace+"/"+pod.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pods)
+	if diff := cmp.Diff([]string{"shop/back", "shop/db", "shop/front"}, pods); diff != "" {
+		t.Errorf("exported pods mismatch with a request timeout shorter than the export (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,178 @@
+package enrichment
+
+import (
+	"karto/config"
+	"karto/types"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL = time.Hour
+	concurrency     = 8
+)
+
+type cacheEntry struct {
+	metadata  map[string]string
+	fetchedAt time.Time
+}
+
+type boundHook struct {
+	name     string
+	kinds    map[string]bool
+	hook     Hook
+	cacheTTL time.Duration
+	mutex    sync.Mutex
+	cache    map[string]cacheEntry
+	failures int
+	lastErr  error
+}
+
+type enricher struct {
+	hooks []*boundHook
+	now   func() time.Time
+}
+
+// Track attaches the metadata returned by the enrichment hooks to the pods and services of each analysis result.
+// Answers are cached, so that each resource is only fetched again once its cache TTL has elapsed.
+func Track(enrichmentConfig config.EnrichmentConfig, resultsChannel <-chan types.AnalysisResult,
+	enrichedResultsChannel chan<- types.AnalysisResult) {
+	enricher := newEnricher(enrichmentConfig.Hooks, NewHTTPHook)
+	for {
+		analysisResult := <-resultsChannel
+		enrichedResultsChannel <- enricher.enrich(analysisResult)
+	}
+}
+
+func newEnricher(hookConfigs []config.EnrichmentHook, newHook func(hookConfig config.EnrichmentHook) Hook) *enricher {
+	hooks := make([]*boundHook, 0, len(hookConfigs))
+	for _, hookConfig := range hookConfigs {
+		kinds := map[string]bool{config.EnrichmentKindPod: true, config.EnrichmentKindService: true}
+		if len(hookConfig.Kinds) > 0 {
+			kinds = make(map[string]bool)
+			for _, kind := range hookConfig.Kinds {
+				kinds[kind] = true
+			}
+		}
+		cacheTTL := defaultCacheTTL
+		if hookConfig.CacheTTL != "" {
+			cacheTTL, _ = time.ParseDuration(hookConfig.CacheTTL)
+		}
+		hooks = append(hooks, &boundHook{
+			name:     hookConfig.Name,
+			kinds:    kinds,
+			hook:     newHook(hookConfig),
+			cacheTTL: cacheTTL,
+			cache:    make(map[string]cacheEntry),
+		})
+	}
+	return &enricher{
+		hooks: hooks,
+		now:   time.Now,
+	}
+}
+
+func (enricher *enricher) enrich(analysisResult types.AnalysisResult) types.AnalysisResult {
+	resources := make([]Resource, 0, len(analysisResult.Pods)+len(analysisResult.Services))
+	for _, pod := range analysisResult.Pods {
+		resources = append(resources, Resource{Kind: config.EnrichmentKindPod, Name: pod.Name,
+			Namespace: pod.Namespace, Labels: pod.Labels})
+	}
+	for _, service := range analysisResult.Services {
+		resources = append(resources, Resource{Kind: config.EnrichmentKindService, Name: service.Name,
+			Namespace: service.Namespace})
+	}
+	metadata := make([]map[string]string, len(resources))
+	var waitGroup sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for i := range resources {
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer waitGroup.Done()
+			metadata[i] = enricher.metadataOf(resources[i])
+			<-semaphore
+		}(i)
+	}
+	waitGroup.Wait()
+	enricher.sweep(resources)
+	// Results are shared with other consumers, pods and services are therefore copied rather than modified
+	pods := make([]*types.Pod, 0, len(analysisResult.Pods))
+	for i, pod := range analysisResult.Pods {
+		enrichedPod := *pod
+		enrichedPod.Enrichment = metadata[i]
+		pods = append(pods, &enrichedPod)
+	}
+	services := make([]*types.Service, 0, len(analysisResult.Services))
+	for i, service := range analysisResult.Services {
+		enrichedService := *service
+		enrichedService.Enrichment = metadata[len(pods)+i]
+		services = append(services, &enrichedService)
+	}
+	analysisResult.Pods = pods
+	analysisResult.Services = services
+	return analysisResult
+}
+
+// Hooks are merged in their configured order, so that a later hook overrides the keys of an earlier one
+func (enricher *enricher) metadataOf(resource Resource) map[string]string {
+	var merged map[string]string
+	for _, hook := range enricher.hooks {
+		if !hook.kinds[resource.Kind] {
+			continue
+		}
+		for key, value := range hook.metadataOf(resource, enricher.now()) {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// A failing hook keeps serving its last answer, even past its TTL, rather than dropping the metadata of the resource
+func (hook *boundHook) metadataOf(resource Resource, now time.Time) map[string]string {
+	key := keyOf(resource)
+	hook.mutex.Lock()
+	entry, cached := hook.cache[key]
+	hook.mutex.Unlock()
+	if cached && now.Sub(entry.fetchedAt) < hook.cacheTTL {
+		return entry.metadata
+	}
+	metadata, err := hook.hook.Enrich(resource)
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	if err != nil {
+		hook.failures++
+		hook.lastErr = err
+		return entry.metadata
+	}
+	hook.cache[key] = cacheEntry{metadata: metadata, fetchedAt: now}
+	return metadata
+}
+
+// Failures are reported once per analysis and hook, and the cache of resources which no longer exist is dropped
+func (enricher *enricher) sweep(resources []Resource) {
+	keys := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		keys[keyOf(resource)] = true
+	}
+	for _, hook := range enricher.hooks {
+		if hook.failures > 0 {
+			log.Printf("Enrichment hook %s failed for %d resources: %s\n", hook.name, hook.failures, hook.lastErr)
+			hook.failures = 0
+			hook.lastErr = nil
+		}
+		for key := range hook.cache {
+			if !keys[key] {
+				delete(hook.cache, key)
+			}
+		}
+	}
+}
+
+func keyOf(resource Resource) string {
+	return resource.Kind + "/" + resource.Namespace + "/" + resource.Name
+}
@@ -0,0 +1,97 @@
+package enrichment
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeHook struct {
+	mutex    sync.Mutex
+	metadata map[string]map[string]string
+	err      error
+	calls    []string
+}
+
+func (hook *fakeHook) Enrich(resource Resource) (map[string]string, error) {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	hook.calls = append(hook.calls, keyOf(resource))
+	if hook.err != nil {
+		return nil, hook.err
+	}
+	return hook.metadata[keyOf(resource)], nil
+}
+
+func TestEnrich(t *testing.T) {
+	cmdb := &fakeHook{metadata: map[string]map[string]string{
+		"Pod/shop/front":     {"owner": "team-shop", "criticality": "low"},
+		"Service/shop/front": {"owner": "team-shop"},
+	}}
+	costs := &fakeHook{metadata: map[string]map[string]string{
+		"Pod/shop/front": {"costCenter": "42", "criticality": "high"},
+	}}
+	hooks := map[string]Hook{"cmdb": cmdb, "costs": costs}
+	enricher := newEnricher([]config.EnrichmentHook{
+		{Name: "cmdb", CacheTTL: "10m"},
+		{Name: "costs", Kinds: []string{config.EnrichmentKindPod}},
+	}, func(hookConfig config.EnrichmentHook) Hook {
+		return hooks[hookConfig.Name]
+	})
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	enricher.now = func() time.Time { return now }
+	front := &types.Pod{Name: "front", Namespace: "shop", Labels: map[string]string{"app": "front"}}
+	frontService := &types.Service{Name: "front", Namespace: "shop",
+		TargetPods: []types.PodRef{{Name: "front", Namespace: "shop"}}}
+	analysisResult := types.AnalysisResult{
+		Pods:     []*types.Pod{front},
+		Services: []*types.Service{frontService},
+	}
+	expectedAnalysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{{Name: "front", Namespace: "shop", Labels: map[string]string{"app": "front"},
+			Enrichment: map[string]string{"owner": "team-shop", "costCenter": "42", "criticality": "high"}}},
+		Services: []*types.Service{{Name: "front", Namespace: "shop",
+			TargetPods: []types.PodRef{{Name: "front", Namespace: "shop"}},
+			Enrichment: map[string]string{"owner": "team-shop"}}},
+	}
+	if diff := cmp.Diff(expectedAnalysisResult, enricher.enrich(analysisResult)); diff != "" {
+		t.Errorf("enrich() result mismatch (-want +got):\n%s", diff)
+	}
+	if front.Enrichment != nil || frontService.Enrichment != nil {
+		t.Errorf("enrich() modified the shared analysis result")
+	}
+	// Cached answers are served until their TTL elapses, and kept when the hook fails afterwards
+	now = now.Add(5 * time.Minute)
+	enricher.enrich(analysisResult)
+	now = now.Add(time.Hour)
+	cmdb.err = errors.New("unavailable")
+	if diff := cmp.Diff(expectedAnalysisResult, enricher.enrich(analysisResult)); diff != "" {
+		t.Errorf("enrich() result mismatch with a failing hook (-want +got):\n%s", diff)
+	}
+	expectedCalls := []string{"Pod/shop/front", "Service/shop/front", "Pod/shop/front", "Service/shop/front"}
+	if diff := cmp.Diff(expectedCalls, cmdb.calls, sortedStrings); diff != "" {
+		t.Errorf("cmdb hook calls mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Pod/shop/front", "Pod/shop/front"}, costs.calls); diff != "" {
+		t.Errorf("costs hook calls mismatch (-want +got):\n%s", diff)
+	}
+	// Resources which no longer exist are forgotten
+	enricher.enrich(types.AnalysisResult{})
+	for _, hook := range enricher.hooks {
+		if len(hook.cache) != 0 {
+			t.Errorf("hook %s still caches %d resources", hook.name, len(hook.cache))
+		}
+	}
+}
+
+var sortedStrings = cmp.Transformer("sort", func(values []string) map[string]int {
+	counts := make(map[string]int)
+	for _, value := range values {
+		counts[value]++
+	}
+	return counts
+})
@@ -0,0 +1,74 @@
+package enrichment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"karto/config"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+// Resource is sent to the hooks to identify the pod or the service to enrich
+type Resource struct {
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// Hook returns the external metadata of a resource, or nil when it knows nothing about it
+type Hook interface {
+	Enrich(resource Resource) (map[string]string, error)
+}
+
+type httpHook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPHook posts the resource as JSON to the URL of the hook, which answers with a JSON object of strings, or with
+// 404 when it knows nothing about the resource
+func NewHTTPHook(hookConfig config.EnrichmentHook) Hook {
+	timeout := defaultTimeout
+	if hookConfig.Timeout != "" {
+		// Durations are validated with the configuration
+		timeout, _ = time.ParseDuration(hookConfig.Timeout)
+	}
+	return httpHook{
+		url:        hookConfig.URL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (hook httpHook) Enrich(resource Resource) (map[string]string, error) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	response, err := hook.httpClient.Post(hook.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hook %s answered %s", hook.url, response.Status)
+	}
+	var metadata map[string]string
+	err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("hook %s answered an invalid object: %s", hook.url, err)
+	}
+	return metadata, nil
+}
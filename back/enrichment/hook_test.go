@@ -0,0 +1,70 @@
+package enrichment
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"karto/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHookEnrich(t *testing.T) {
+	tests := []struct {
+		name             string
+		statusCode       int
+		responseBody     string
+		expectedMetadata map[string]string
+		expectedError    string
+	}{
+		{
+			name:             "returns the answered metadata",
+			statusCode:       http.StatusOK,
+			responseBody:     `{"owner":"team-shop","costCenter":"42"}`,
+			expectedMetadata: map[string]string{"owner": "team-shop", "costCenter": "42"},
+		},
+		{
+			name:             "returns no metadata for unknown resources",
+			statusCode:       http.StatusNotFound,
+			expectedMetadata: nil,
+		},
+		{
+			name:          "fails on other status codes",
+			statusCode:    http.StatusInternalServerError,
+			expectedError: "answered 500 Internal Server Error",
+		},
+		{
+			name:          "fails on invalid objects",
+			statusCode:    http.StatusOK,
+			responseBody:  `{"replicas":3}`,
+			expectedError: "answered an invalid object",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestBody string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, _ := ioutil.ReadAll(r.Body)
+				requestBody = string(content)
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.responseBody))
+			}))
+			defer server.Close()
+			hook := NewHTTPHook(config.EnrichmentHook{Name: "cmdb", URL: server.URL})
+			metadata, err := hook.Enrich(Resource{Kind: "Pod", Name: "front", Namespace: "shop",
+				Labels: map[string]string{"app": "front"}})
+			if (err != nil) != (tt.expectedError != "") ||
+				(err != nil && !strings.Contains(err.Error(), tt.expectedError)) {
+				t.Errorf("Enrich() error = %v, expected an error containing %q", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expectedMetadata, metadata); diff != "" {
+				t.Errorf("Enrich() result mismatch (-want +got):\n%s", diff)
+			}
+			expectedRequestBody := `{"kind":"Pod","name":"front","namespace":"shop","labels":{"app":"front"}}`
+			if requestBody != expectedRequestBody {
+				t.Errorf("Enrich() sent %s, expected %s", requestBody, expectedRequestBody)
+			}
+		})
+	}
+}
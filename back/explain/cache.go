@@ -0,0 +1,75 @@
+package explain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/types"
+	"sort"
+	"strings"
+)
+
+const maxCachedSimulations = 100
+
+// simulationCache memoizes the simulations run against a snapshot of the cluster, so that the same manifests
+// submitted again, for example by repeated CI runs of a pull request, are answered without any analysis
+type simulationCache struct {
+	snapshotVersion string
+	explanations    map[string]Explanation
+	deletionImpacts map[string]DeletionImpact
+	// disruptionImpacts are keyed by the namespace and name of the deployment
+	disruptionImpacts map[string]DisruptionImpact
+}
+
+func newSimulationCache(snapshotVersion string) *simulationCache {
+	return &simulationCache{
+		snapshotVersion:   snapshotVersion,
+		explanations:      make(map[string]Explanation),
+		deletionImpacts:   make(map[string]DeletionImpact),
+		disruptionImpacts: make(map[string]DisruptionImpact),
+	}
+}
+
+// snapshotVersion identifies a cluster state by its resourceVersions. States without any, such as those read from
+// manifests, get no version and are never deemed unchanged.
+func snapshotVersion(clusterState types.ClusterState) string {
+	kinds := make([]string, 0, len(clusterState.ResourceVersions))
+	for kind, resourceVersion := range clusterState.ResourceVersions {
+		kinds = append(kinds, kind+"="+resourceVersion)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ",")
+}
+
+func manifestHash(policy *networkingv1.NetworkPolicy) (string, bool) {
+	content, err := json.Marshal(policy)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), true
+}
+
+// The cache is emptied once full rather than evicting its entries one by one, simulations being rarely repeated
+// beyond a few pull requests
+func (cache *simulationCache) putExplanation(key string, explanation Explanation) {
+	if len(cache.explanations) >= maxCachedSimulations {
+		cache.explanations = make(map[string]Explanation)
+	}
+	cache.explanations[key] = explanation
+}
+
+func (cache *simulationCache) putDeletionImpact(key string, impact DeletionImpact) {
+	if len(cache.deletionImpacts) >= maxCachedSimulations {
+		cache.deletionImpacts = make(map[string]DeletionImpact)
+	}
+	cache.deletionImpacts[key] = impact
+}
+
+func (cache *simulationCache) putDisruptionImpact(key string, impact DisruptionImpact) {
+	if len(cache.disruptionImpacts) >= maxCachedSimulations {
+		cache.disruptionImpacts = make(map[string]DisruptionImpact)
+	}
+	cache.disruptionImpacts[key] = impact
+}
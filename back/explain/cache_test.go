@@ -0,0 +1,67 @@
+package explain
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+type countingAnalysisScheduler struct {
+	mockAnalysisScheduler
+	analyses *int
+}
+
+func (mock countingAnalysisScheduler) Analyze(clusterState types.ClusterState) types.AnalysisResult {
+	*mock.analyses++
+	return mock.mockAnalysisScheduler.Analyze(clusterState)
+}
+
+func TestSimulationCache(t *testing.T) {
+	analyses := 0
+	explainer := NewExplainer(countingAnalysisScheduler{analyses: &analyses})
+	clusterStates := make(chan types.ClusterState)
+	trackedClusterStates := make(chan types.ClusterState)
+	go explainer.Track(clusterStates, trackedClusterStates)
+	track := func(resourceVersions map[string]string) {
+		clusterStates <- types.ClusterState{ResourceVersions: resourceVersions}
+		<-trackedClusterStates
+	}
+	policy := testutils.NewNetworkPolicyBuilder().WithName("api").WithNamespace("shop").Build()
+	selector, _ := labels.Parse("app.kubernetes.io/instance=shop")
+	simulate := func() {
+		_, err := explainer.ExplainPolicy(context.Background(), policy)
+		if err != nil {
+			t.Fatalf("ExplainPolicy() unexpected error: %s", err)
+		}
+		_, err = explainer.SimulateDeletion(context.Background(), selector)
+		if err != nil {
+			t.Fatalf("SimulateDeletion() unexpected error: %s", err)
+		}
+	}
+	steps := []struct {
+		name             string
+		resourceVersions map[string]string
+		expectedAnalyses int
+	}{
+		{name: "simulations are run on a new snapshot",
+			resourceVersions: map[string]string{"pods": "1"}, expectedAnalyses: 4},
+		{name: "simulations are cached while the snapshot is unchanged",
+			resourceVersions: map[string]string{"pods": "1"}, expectedAnalyses: 4},
+		{name: "simulations are run again once the snapshot changes",
+			resourceVersions: map[string]string{"pods": "2"}, expectedAnalyses: 8},
+		{name: "simulations are run again on a snapshot without version",
+			resourceVersions: nil, expectedAnalyses: 12},
+		{name: "snapshots without version are never deemed unchanged",
+			resourceVersions: nil, expectedAnalyses: 16},
+	}
+	for _, step := range steps {
+		track(step.resourceVersions)
+		simulate()
+		simulate()
+		if analyses != step.expectedAnalyses {
+			t.Errorf("%s: %d analyses, expected %d", step.name, analyses, step.expectedAnalyses)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"karto/analyzer"
+	"karto/routediff"
+	"karto/types"
+	"sort"
+)
+
+var ErrUnknownDeployment = errors.New("unknown deployment")
+
+type DisruptionImpact struct {
+	Deployment  types.ResourceRef `json:"deployment"`
+	RemovedPods []types.PodRef    `json:"removedPods"`
+	Routes      routediff.Diff    `json:"routes"`
+	// ServiceBackings are the services losing target pods, those left without any being single points of failure
+	ServiceBackings []*ServiceBacking `json:"serviceBackings"`
+}
+
+type ServiceBacking struct {
+	Service       types.ServiceRef `json:"service"`
+	LostPods      []types.PodRef   `json:"lostPods"`
+	RemainingPods []types.PodRef   `json:"remainingPods"`
+}
+
+func (explainer *explainerImpl) SimulateDisruption(ctx context.Context, namespace string, name string) (
+	DisruptionImpact, error) {
+	key := namespace + "/" + name
+	explainer.mutex.RLock()
+	lastClusterState := explainer.lastClusterState
+	cache := explainer.cache
+	impact, ok := cache.disruptionImpacts[key]
+	explainer.mutex.RUnlock()
+	if lastClusterState == nil {
+		return DisruptionImpact{}, fmt.Errorf("the cluster state is not known yet")
+	}
+	if ok {
+		return impact, nil
+	}
+	impact, err := SimulateDisruption(ctx, explainer.analysisScheduler, *lastClusterState, namespace, name)
+	if err == nil {
+		explainer.mutex.Lock()
+		cache.putDisruptionImpact(key, impact)
+		explainer.mutex.Unlock()
+	}
+	return impact, err
+}
+
+// SimulateDisruption previews the loss of every pod of the deployment, as during an outage or a scale to zero, the
+// pods being those owned by its replica sets
+func SimulateDisruption(ctx context.Context, analysisScheduler analyzer.AnalysisScheduler,
+	clusterState types.ClusterState, namespace string, name string) (DisruptionImpact, error) {
+	var deploymentUID k8stypes.UID
+	for _, deployment := range clusterState.Deployments {
+		if deployment.Namespace == namespace && deployment.Name == name {
+			deploymentUID = deployment.UID
+		}
+	}
+	if deploymentUID == "" {
+		return DisruptionImpact{}, fmt.Errorf("%w %s/%s", ErrUnknownDeployment, namespace, name)
+	}
+	replicaSetUIDs := make(map[k8stypes.UID]bool)
+	for _, replicaSet := range clusterState.ReplicaSets {
+		if replicaSet.Namespace == namespace && ownedBy(replicaSet.OwnerReferences, deploymentUID) {
+			replicaSetUIDs[replicaSet.UID] = true
+		}
+	}
+	afterClusterState := clusterState
+	afterClusterState.Pods = make([]*corev1.Pod, 0, len(clusterState.Pods))
+	removedPods := make([]types.PodRef, 0)
+	for _, pod := range clusterState.Pods {
+		if pod.Namespace == namespace && ownedByAny(pod, replicaSetUIDs) {
+			removedPods = append(removedPods, types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+			continue
+		}
+		afterClusterState.Pods = append(afterClusterState.Pods, pod)
+	}
+	sortPodRefs(removedPods)
+	before, after, err := analyzeBeforeAndAfter(ctx, analysisScheduler, clusterState, afterClusterState)
+	if err != nil {
+		return DisruptionImpact{}, err
+	}
+	return DisruptionImpact{
+		Deployment:      types.ResourceRef{Kind: "Deployment", Name: name, Namespace: namespace},
+		RemovedPods:     removedPods,
+		Routes:          routediff.Compute(before.AllowedRoutes, after.AllowedRoutes),
+		ServiceBackings: lostServiceBackings(before.Services, after.Services),
+	}, nil
+}
+
+func ownedBy(ownerReferences []metav1.OwnerReference, ownerUID k8stypes.UID) bool {
+	for _, ownerReference := range ownerReferences {
+		if ownerReference.UID == ownerUID {
+			return true
+		}
+	}
+	return false
+}
+
+func ownedByAny(pod *corev1.Pod, ownerUIDs map[k8stypes.UID]bool) bool {
+	for _, ownerReference := range pod.OwnerReferences {
+		if ownerUIDs[ownerReference.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+func lostServiceBackings(before []*types.Service, after []*types.Service) []*ServiceBacking {
+	targetPodsAfter := make(map[types.ServiceRef]map[types.PodRef]bool)
+	for _, service := range after {
+		targetPods := make(map[types.PodRef]bool)
+		for _, podRef := range service.TargetPods {
+			targetPods[podRef] = true
+		}
+		targetPodsAfter[types.ServiceRef{Name: service.Name, Namespace: service.Namespace}] = targetPods
+	}
+	serviceBackings := make([]*ServiceBacking, 0)
+	for _, service := range before {
+		serviceRef := types.ServiceRef{Name: service.Name, Namespace: service.Namespace}
+		serviceBacking := &ServiceBacking{
+			Service:       serviceRef,
+			LostPods:      make([]types.PodRef, 0),
+			RemainingPods: make([]types.PodRef, 0),
+		}
+		for _, podRef := range service.TargetPods {
+			if targetPodsAfter[serviceRef][podRef] {
+				serviceBacking.RemainingPods = append(serviceBacking.RemainingPods, podRef)
+			} else {
+				serviceBacking.LostPods = append(serviceBacking.LostPods, podRef)
+			}
+		}
+		if len(serviceBacking.LostPods) == 0 {
+			continue
+		}
+		sortPodRefs(serviceBacking.LostPods)
+		sortPodRefs(serviceBacking.RemainingPods)
+		serviceBackings = append(serviceBackings, serviceBacking)
+	}
+	sort.Slice(serviceBackings, func(i, j int) bool {
+		if serviceBackings[i].Service.Namespace != serviceBackings[j].Service.Namespace {
+			return serviceBackings[i].Service.Namespace < serviceBackings[j].Service.Namespace
+		}
+		return serviceBackings[i].Service.Name < serviceBackings[j].Service.Name
+	})
+	return serviceBackings
+}
@@ -0,0 +1,238 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/analyzer"
+	"karto/analyzer/utils"
+	"karto/routediff"
+	"karto/types"
+	"sort"
+	"sync"
+)
+
+type Explanation struct {
+	Policy       types.NetworkPolicy `json:"policy"`
+	Replaces     bool                `json:"replaces"`
+	SelectedPods []types.PodRef      `json:"selectedPods"`
+	IngressRules []*RuleExplanation  `json:"ingressRules"`
+	EgressRules  []*RuleExplanation  `json:"egressRules"`
+	Routes       routediff.Diff      `json:"routes"`
+}
+
+type RuleExplanation struct {
+	AllPeers bool           `json:"allPeers"`
+	Pods     []types.PodRef `json:"pods"`
+	IPBlocks []string       `json:"ipBlocks"`
+	Ports    []string       `json:"ports"`
+}
+
+type Explainer interface {
+	Track(clusterStateChannel <-chan types.ClusterState, trackedClusterStateChannel chan<- types.ClusterState)
+	ExplainPolicy(ctx context.Context, policy *networkingv1.NetworkPolicy) (Explanation, error)
+	SimulateDeletion(ctx context.Context, selector labels.Selector) (DeletionImpact, error)
+	SimulateDisruption(ctx context.Context, namespace string, name string) (DisruptionImpact, error)
+	ExplainRoute(ctx context.Context, sourcePod types.PodRef, targetPod types.PodRef, port int32,
+		protocol corev1.Protocol) (RouteExplanation, error)
+}
+
+type explainerImpl struct {
+	analysisScheduler analyzer.AnalysisScheduler
+	mutex             sync.RWMutex
+	lastClusterState  *types.ClusterState
+	cache             *simulationCache
+}
+
+func NewExplainer(analysisScheduler analyzer.AnalysisScheduler) Explainer {
+	return &explainerImpl{
+		analysisScheduler: analysisScheduler,
+		cache:             newSimulationCache(""),
+	}
+}
+
+func (explainer *explainerImpl) Track(clusterStateChannel <-chan types.ClusterState,
+	trackedClusterStateChannel chan<- types.ClusterState) {
+	for {
+		clusterState := <-clusterStateChannel
+		explainer.mutex.Lock()
+		explainer.lastClusterState = &clusterState
+		version := snapshotVersion(clusterState)
+		if version == "" || version != explainer.cache.snapshotVersion {
+			explainer.cache = newSimulationCache(version)
+		}
+		explainer.mutex.Unlock()
+		trackedClusterStateChannel <- clusterState
+	}
+}
+
+func (explainer *explainerImpl) ExplainPolicy(ctx context.Context, policy *networkingv1.NetworkPolicy) (Explanation,
+	error) {
+	key, cacheable := manifestHash(policy)
+	explainer.mutex.RLock()
+	lastClusterState := explainer.lastClusterState
+	cache := explainer.cache
+	explanation, ok := cache.explanations[key]
+	explainer.mutex.RUnlock()
+	if lastClusterState == nil {
+		return Explanation{}, fmt.Errorf("the cluster state is not known yet")
+	}
+	if cacheable && ok {
+		return explanation, nil
+	}
+	explanation, err := Explain(ctx, explainer.analysisScheduler, *lastClusterState, policy)
+	if err == nil && cacheable {
+		explainer.mutex.Lock()
+		cache.putExplanation(key, explanation)
+		explainer.mutex.Unlock()
+	}
+	return explanation, err
+}
+
+// Explain is aborted between its analyses when the context is done, the caller not waiting for it anymore
+func Explain(ctx context.Context, analysisScheduler analyzer.AnalysisScheduler, clusterState types.ClusterState,
+	policy *networkingv1.NetworkPolicy) (Explanation, error) {
+	policy = policy.DeepCopy()
+	if policy.Namespace == "" {
+		policy.Namespace = metav1.NamespaceDefault
+	}
+	afterClusterState := clusterState
+	afterClusterState.NetworkPolicies = make([]*networkingv1.NetworkPolicy, 0)
+	replaces := false
+	for _, existingPolicy := range clusterState.NetworkPolicies {
+		if existingPolicy.Name == policy.Name && existingPolicy.Namespace == policy.Namespace {
+			// Explaining an existing policy shows the effect of its new version
+			replaces = true
+			continue
+		}
+		afterClusterState.NetworkPolicies = append(afterClusterState.NetworkPolicies, existingPolicy)
+	}
+	afterClusterState.NetworkPolicies = append(afterClusterState.NetworkPolicies, policy)
+	before, after, err := analyzeBeforeAndAfter(ctx, analysisScheduler, clusterState, afterClusterState)
+	if err != nil {
+		return Explanation{}, err
+	}
+	explanation := Explanation{
+		Policy:       types.NetworkPolicy{Name: policy.Name, Namespace: policy.Namespace, Labels: policy.Labels},
+		Replaces:     replaces,
+		SelectedPods: selectedPods(policy, clusterState.Pods),
+		IngressRules: make([]*RuleExplanation, 0),
+		EgressRules:  make([]*RuleExplanation, 0),
+		Routes:       routediff.Compute(before.AllowedRoutes, after.AllowedRoutes),
+	}
+	for _, ingressRule := range policy.Spec.Ingress {
+		explanation.IngressRules = append(explanation.IngressRules, explainRule(policy.Namespace, ingressRule.From,
+			ingressRule.Ports, clusterState))
+	}
+	for _, egressRule := range policy.Spec.Egress {
+		explanation.EgressRules = append(explanation.EgressRules, explainRule(policy.Namespace, egressRule.To,
+			egressRule.Ports, clusterState))
+	}
+	return explanation, nil
+}
+
+func analyzeBeforeAndAfter(ctx context.Context, analysisScheduler analyzer.AnalysisScheduler,
+	beforeClusterState types.ClusterState, afterClusterState types.ClusterState) (types.AnalysisResult,
+	types.AnalysisResult, error) {
+	if err := ctx.Err(); err != nil {
+		return types.AnalysisResult{}, types.AnalysisResult{}, err
+	}
+	before := analysisScheduler.Analyze(beforeClusterState)
+	if err := ctx.Err(); err != nil {
+		return types.AnalysisResult{}, types.AnalysisResult{}, err
+	}
+	after := analysisScheduler.Analyze(afterClusterState)
+	return before, after, ctx.Err()
+}
+
+func selectedPods(policy *networkingv1.NetworkPolicy, pods []*corev1.Pod) []types.PodRef {
+	result := make([]types.PodRef, 0)
+	for _, pod := range pods {
+		if pod.Namespace == policy.Namespace && utils.SelectorMatches(pod.Labels, policy.Spec.PodSelector) {
+			result = append(result, types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+		}
+	}
+	sortPodRefs(result)
+	return result
+}
+
+func explainRule(policyNamespace string, peers []networkingv1.NetworkPolicyPeer,
+	ports []networkingv1.NetworkPolicyPort, clusterState types.ClusterState) *RuleExplanation {
+	ruleExplanation := &RuleExplanation{
+		AllPeers: len(peers) == 0,
+		Pods:     make([]types.PodRef, 0),
+		IPBlocks: make([]string, 0),
+		Ports:    make([]string, 0),
+	}
+	matchedPods := make(map[types.PodRef]bool)
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			ipBlock := peer.IPBlock.CIDR
+			if len(peer.IPBlock.Except) > 0 {
+				ipBlock = fmt.Sprintf("%s except %v", ipBlock, peer.IPBlock.Except)
+			}
+			ruleExplanation.IPBlocks = append(ruleExplanation.IPBlocks, ipBlock)
+			continue
+		}
+		for _, pod := range clusterState.Pods {
+			if peerMatches(policyNamespace, peer, pod, clusterState.Namespaces) {
+				matchedPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+			}
+		}
+	}
+	for podRef := range matchedPods {
+		ruleExplanation.Pods = append(ruleExplanation.Pods, podRef)
+	}
+	sortPodRefs(ruleExplanation.Pods)
+	for _, port := range ports {
+		ruleExplanation.Ports = append(ruleExplanation.Ports, formatPort(port))
+	}
+	return ruleExplanation
+}
+
+func peerMatches(policyNamespace string, peer networkingv1.NetworkPolicyPeer, pod *corev1.Pod,
+	namespaces []*corev1.Namespace) bool {
+	if peer.NamespaceSelector == nil {
+		if pod.Namespace != policyNamespace {
+			return false
+		}
+	} else if !utils.SelectorMatches(namespaceLabels(pod.Namespace, namespaces), *peer.NamespaceSelector) {
+		return false
+	}
+	return peer.PodSelector == nil || utils.SelectorMatches(pod.Labels, *peer.PodSelector)
+}
+
+func namespaceLabels(namespaceName string, namespaces []*corev1.Namespace) map[string]string {
+	for _, namespace := range namespaces {
+		if namespace.Name == namespaceName {
+			return namespace.Labels
+		}
+	}
+	return nil
+}
+
+func formatPort(port networkingv1.NetworkPolicyPort) string {
+	protocol := corev1.ProtocolTCP
+	if port.Protocol != nil {
+		protocol = *port.Protocol
+	}
+	if port.Port == nil {
+		return fmt.Sprintf("%s/*", protocol)
+	}
+	if port.EndPort != nil {
+		return fmt.Sprintf("%s/%s-%d", protocol, port.Port.String(), *port.EndPort)
+	}
+	return fmt.Sprintf("%s/%s", protocol, port.Port.String())
+}
+
+func sortPodRefs(podRefs []types.PodRef) {
+	sort.Slice(podRefs, func(i, j int) bool {
+		if podRefs[i].Namespace != podRefs[j].Namespace {
+			return podRefs[i].Namespace < podRefs[j].Namespace
+		}
+		return podRefs[i].Name < podRefs[j].Name
+	})
+}
@@ -0,0 +1,121 @@
+package explain
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/routediff"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+type mockAnalysisScheduler struct {
+	allowedRoutesByPolicyCount map[int][]*types.AllowedRoute
+	podIsolationsByPolicyCount map[int][]*types.PodIsolation
+	// analysisResultsByPodCount answers the simulations removing pods rather than policies
+	analysisResultsByPodCount map[int]types.AnalysisResult
+}
+
+func (mock mockAnalysisScheduler) AnalyzeOnClusterStateChange(<-chan types.ClusterState,
+	chan<- types.AnalysisResult, chan<- types.NamespaceRoutes) {
+}
+
+func (mock mockAnalysisScheduler) Analyze(clusterState types.ClusterState) types.AnalysisResult {
+	if analysisResult, ok := mock.analysisResultsByPodCount[len(clusterState.Pods)]; ok {
+		return analysisResult
+	}
+	return types.AnalysisResult{
+		AllowedRoutes: mock.allowedRoutesByPolicyCount[len(clusterState.NetworkPolicies)],
+		PodIsolations: mock.podIsolationsByPolicyCount[len(clusterState.NetworkPolicies)],
+	}
+}
+
+func TestExplain(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").WithLabel("app", "api").Build()
+	other := testutils.NewPodBuilder().WithName("other").WithNamespace("other").WithLabel("app", "front").Build()
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api", Namespace: "shop"}
+	otherRef := types.PodRef{Name: "other", Namespace: "other"}
+	policy := testutils.NewNetworkPolicyBuilder().WithName("api").WithNamespace("shop").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "api").Build()).
+		WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "front").Build()},
+				{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+			},
+		}).Build()
+	clusterState := types.ClusterState{
+		Namespaces: []*corev1.Namespace{
+			testutils.NewNamespaceBuilder().WithName("shop").Build(),
+			testutils.NewNamespaceBuilder().WithName("other").Build(),
+		},
+		Pods: []*corev1.Pod{front, api, other},
+	}
+	analysisScheduler := mockAnalysisScheduler{allowedRoutesByPolicyCount: map[int][]*types.AllowedRoute{
+		0: {
+			{SourcePod: frontRef, TargetPod: apiRef},
+			{SourcePod: otherRef, TargetPod: apiRef},
+		},
+		1: {
+			{SourcePod: frontRef, TargetPod: apiRef},
+		},
+	}}
+	expectedExplanation := Explanation{
+		Policy:       types.NetworkPolicy{Name: "api", Namespace: "shop", Labels: map[string]string{}},
+		Replaces:     false,
+		SelectedPods: []types.PodRef{apiRef},
+		IngressRules: []*RuleExplanation{{
+			AllPeers: false,
+			// A peer without namespace selector only matches pods of the policy's namespace
+			Pods:     []types.PodRef{frontRef},
+			IPBlocks: []string{"10.0.0.0/8"},
+			Ports:    []string{},
+		}},
+		EgressRules: []*RuleExplanation{},
+		Routes: routediff.Diff{
+			Added:   []*types.AllowedRoute{},
+			Removed: []*types.AllowedRoute{{SourcePod: otherRef, TargetPod: apiRef}},
+			Changed: []*routediff.Change{},
+			Causes:  []*routediff.Cause{},
+		},
+	}
+	explanation, err := Explain(context.Background(), analysisScheduler, clusterState, policy)
+	if err != nil {
+		t.Fatalf("Explain() unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(expectedExplanation, explanation); diff != "" {
+		t.Errorf("Explain() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExplainReplacesExistingPolicy(t *testing.T) {
+	existingPolicy := testutils.NewNetworkPolicyBuilder().WithName("api").WithNamespace("shop").Build()
+	newPolicy := testutils.NewNetworkPolicyBuilder().WithName("api").WithNamespace("shop").Build()
+	clusterState := types.ClusterState{NetworkPolicies: []*networkingv1.NetworkPolicy{existingPolicy}}
+	explanation, _ := Explain(context.Background(), mockAnalysisScheduler{}, clusterState, newPolicy)
+	if diff := cmp.Diff(true, explanation.Replaces); diff != "" {
+		t.Errorf("Explain() replaces mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExplainPolicyWithoutClusterState(t *testing.T) {
+	explainer := NewExplainer(mockAnalysisScheduler{})
+	_, err := explainer.ExplainPolicy(context.Background(), testutils.NewNetworkPolicyBuilder().WithName("api").Build())
+	if err == nil {
+		t.Errorf("ExplainPolicy() expected an error when the cluster state is not known yet")
+	}
+}
+
+func TestExplainCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Explain(ctx, mockAnalysisScheduler{}, types.ClusterState{},
+		testutils.NewNetworkPolicyBuilder().WithName("api").Build())
+	if err != context.Canceled {
+		t.Errorf("Explain() expected the cancellation of the context, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"karto/orderedpolicy"
+	"karto/types"
+)
+
+var ErrUnknownPod = errors.New("unknown pod")
+
+// RouteExplanation tells which policy rule decides the traffic of a route in each direction, as evaluated by the
+// policy engine of the cluster
+type RouteExplanation struct {
+	SourcePod types.PodRef              `json:"sourcePod"`
+	TargetPod types.PodRef              `json:"targetPod"`
+	Port      int32                     `json:"port"`
+	Protocol  corev1.Protocol           `json:"protocol"`
+	Allowed   bool                      `json:"allowed"`
+	Egress    *orderedpolicy.Evaluation `json:"egress"`
+	Ingress   *orderedpolicy.Evaluation `json:"ingress"`
+}
+
+func (explainer *explainerImpl) ExplainRoute(ctx context.Context, sourcePod types.PodRef, targetPod types.PodRef,
+	port int32, protocol corev1.Protocol) (RouteExplanation, error) {
+	explainer.mutex.RLock()
+	lastClusterState := explainer.lastClusterState
+	explainer.mutex.RUnlock()
+	if lastClusterState == nil {
+		return RouteExplanation{}, fmt.Errorf("the cluster state is not known yet")
+	}
+	if err := ctx.Err(); err != nil {
+		return RouteExplanation{}, err
+	}
+	return ExplainRoute(*lastClusterState, sourcePod, targetPod, port, protocol)
+}
+
+// ExplainRoute evaluates the policies for the traffic from the source pod to the port of the target pod, 0 standing
+// for any port. The Calico policies are evaluated when there are any, then the Cilium ones, the Kubernetes network
+// policies being evaluated along with them, or alone otherwise.
+func ExplainRoute(clusterState types.ClusterState, sourcePod types.PodRef, targetPod types.PodRef, port int32,
+	protocol corev1.Protocol) (RouteExplanation, error) {
+	source := findPod(clusterState.Pods, sourcePod)
+	if source == nil {
+		return RouteExplanation{}, fmt.Errorf("%w %s/%s", ErrUnknownPod, sourcePod.Namespace, sourcePod.Name)
+	}
+	target := findPod(clusterState.Pods, targetPod)
+	if target == nil {
+		return RouteExplanation{}, fmt.Errorf("%w %s/%s", ErrUnknownPod, targetPod.Namespace, targetPod.Name)
+	}
+	policies := make([]*orderedpolicy.Policy, 0, len(clusterState.OrderedPolicies)+len(clusterState.NetworkPolicies))
+	engine := orderedpolicy.EngineKubernetes
+	for _, policy := range clusterState.OrderedPolicies {
+		if policy.Engine == orderedpolicy.EngineCalico || engine == orderedpolicy.EngineKubernetes {
+			engine = policy.Engine
+		}
+		policies = append(policies, policy)
+	}
+	for _, networkPolicy := range clusterState.NetworkPolicies {
+		policies = append(policies, orderedpolicy.FromNetworkPolicy(networkPolicy))
+	}
+	traffic := orderedpolicy.Traffic{Source: source, Target: target, Port: port, Protocol: protocol}
+	egress := orderedpolicy.Evaluate(engine, policies, orderedpolicy.DirectionEgress, traffic, clusterState.Namespaces)
+	ingress := orderedpolicy.Evaluate(engine, policies, orderedpolicy.DirectionIngress, traffic,
+		clusterState.Namespaces)
+	return RouteExplanation{
+		SourcePod: sourcePod,
+		TargetPod: targetPod,
+		Port:      port,
+		Protocol:  protocol,
+		Allowed:   egress.Allowed() && ingress.Allowed(),
+		Egress:    egress,
+		Ingress:   ingress,
+	}, nil
+}
+
+func findPod(pods []*corev1.Pod, podRef types.PodRef) *corev1.Pod {
+	for _, pod := range pods {
+		if pod.Namespace == podRef.Namespace && pod.Name == podRef.Name {
+			return pod
+		}
+	}
+	return nil
+}
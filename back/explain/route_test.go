@@ -0,0 +1,131 @@
+package explain
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/orderedpolicy"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestExplainRoute(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").WithLabel("app", "api").Build()
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api", Namespace: "shop"}
+	allowFront := testutils.NewNetworkPolicyBuilder().WithName("allow-front").WithNamespace("shop").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "api").Build()).
+		WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "front").Build()},
+			},
+		}).Build()
+	calicoPolicies, err := orderedpolicy.ParseCalicoPolicies([]byte(`{"items": [
+		{"metadata": {"name": "deny-front"}, "spec": {"order": 100, "selector": "app == 'api'",
+			"ingress": [{"action": "Deny", "source": {"selector": "app == 'front'"}}]}}
+	]}`), "GlobalNetworkPolicy")
+	if err != nil {
+		t.Fatalf("ParseCalicoPolicies() error = %v", err)
+	}
+	order := func(value float64) *float64 {
+		return &value
+	}
+	denyFrontRef := orderedpolicy.PolicyRef{Engine: orderedpolicy.EngineCalico, Kind: "GlobalNetworkPolicy",
+		Name: "deny-front", Order: order(100)}
+	allowFrontRef := orderedpolicy.PolicyRef{Engine: orderedpolicy.EngineKubernetes, Kind: "NetworkPolicy",
+		Name: "allow-front", Namespace: "shop", Order: order(orderedpolicy.KubernetesOrder)}
+	tests := []struct {
+		name                string
+		clusterState        types.ClusterState
+		expectedExplanation RouteExplanation
+	}{
+		{
+			name: "a Calico deny of lower order than a Kubernetes network policy denies the route",
+			clusterState: types.ClusterState{
+				Pods:            []*corev1.Pod{front, api},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{allowFront},
+				OrderedPolicies: calicoPolicies,
+			},
+			expectedExplanation: RouteExplanation{
+				SourcePod: frontRef,
+				TargetPod: apiRef,
+				Port:      80,
+				Protocol:  corev1.ProtocolTCP,
+				Allowed:   false,
+				Egress: &orderedpolicy.Evaluation{
+					Engine:           orderedpolicy.EngineCalico,
+					Direction:        orderedpolicy.DirectionEgress,
+					Policies:         []orderedpolicy.PolicyRef{},
+					ShadowedRules:    []orderedpolicy.RuleMatch{},
+					UnsupportedRules: []orderedpolicy.RuleMatch{},
+					Verdict:          orderedpolicy.VerdictNone,
+				},
+				Ingress: &orderedpolicy.Evaluation{
+					Engine:     orderedpolicy.EngineCalico,
+					Direction:  orderedpolicy.DirectionIngress,
+					Policies:   []orderedpolicy.PolicyRef{denyFrontRef, allowFrontRef},
+					FirstMatch: &orderedpolicy.RuleMatch{Policy: denyFrontRef, Section: "ingress", Action: "Deny"},
+					ShadowedRules: []orderedpolicy.RuleMatch{
+						{Policy: allowFrontRef, Section: "ingress", Action: "Allow"},
+					},
+					UnsupportedRules: []orderedpolicy.RuleMatch{},
+					Verdict:          orderedpolicy.VerdictDeny,
+				},
+			},
+		},
+		{
+			name: "the Kubernetes network policies are evaluated alone without other engine policies",
+			clusterState: types.ClusterState{
+				Pods:            []*corev1.Pod{front, api},
+				NetworkPolicies: []*networkingv1.NetworkPolicy{allowFront},
+			},
+			expectedExplanation: RouteExplanation{
+				SourcePod: frontRef,
+				TargetPod: apiRef,
+				Port:      80,
+				Protocol:  corev1.ProtocolTCP,
+				Allowed:   true,
+				Egress: &orderedpolicy.Evaluation{
+					Engine:           orderedpolicy.EngineKubernetes,
+					Direction:        orderedpolicy.DirectionEgress,
+					Policies:         []orderedpolicy.PolicyRef{},
+					ShadowedRules:    []orderedpolicy.RuleMatch{},
+					UnsupportedRules: []orderedpolicy.RuleMatch{},
+					Verdict:          orderedpolicy.VerdictNone,
+				},
+				Ingress: &orderedpolicy.Evaluation{
+					Engine:           orderedpolicy.EngineKubernetes,
+					Direction:        orderedpolicy.DirectionIngress,
+					Policies:         []orderedpolicy.PolicyRef{allowFrontRef},
+					FirstMatch:       &orderedpolicy.RuleMatch{Policy: allowFrontRef, Section: "ingress", Action: "Allow"},
+					ShadowedRules:    []orderedpolicy.RuleMatch{},
+					UnsupportedRules: []orderedpolicy.RuleMatch{},
+					Verdict:          orderedpolicy.VerdictAllow,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation, err := ExplainRoute(tt.clusterState, frontRef, apiRef, 80, corev1.ProtocolTCP)
+			if err != nil {
+				t.Fatalf("ExplainRoute() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.expectedExplanation, explanation); diff != "" {
+				t.Errorf("ExplainRoute() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExplainRouteUnknownPod(t *testing.T) {
+	_, err := ExplainRoute(types.ClusterState{}, types.PodRef{Name: "front", Namespace: "shop"},
+		types.PodRef{Name: "api", Namespace: "shop"}, 80, corev1.ProtocolTCP)
+	if !errors.Is(err, ErrUnknownPod) {
+		t.Errorf("ExplainRoute() error = %v, expected %v", err, ErrUnknownPod)
+	}
+}
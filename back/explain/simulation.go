@@ -0,0 +1,95 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/analyzer"
+	"karto/routediff"
+	"karto/types"
+	"sort"
+)
+
+type DeletionImpact struct {
+	Selector                   string                `json:"selector"`
+	DeletedPolicies            []types.NetworkPolicy `json:"deletedPolicies"`
+	PodsLosingIngressIsolation []types.PodRef        `json:"podsLosingIngressIsolation"`
+	PodsLosingEgressIsolation  []types.PodRef        `json:"podsLosingEgressIsolation"`
+	Routes                     routediff.Diff        `json:"routes"`
+}
+
+func (explainer *explainerImpl) SimulateDeletion(ctx context.Context, selector labels.Selector) (DeletionImpact,
+	error) {
+	explainer.mutex.RLock()
+	lastClusterState := explainer.lastClusterState
+	cache := explainer.cache
+	impact, ok := cache.deletionImpacts[selector.String()]
+	explainer.mutex.RUnlock()
+	if lastClusterState == nil {
+		return DeletionImpact{}, fmt.Errorf("the cluster state is not known yet")
+	}
+	if ok {
+		return impact, nil
+	}
+	impact, err := SimulateDeletion(ctx, explainer.analysisScheduler, *lastClusterState, selector)
+	if err == nil {
+		explainer.mutex.Lock()
+		cache.putDeletionImpact(selector.String(), impact)
+		explainer.mutex.Unlock()
+	}
+	return impact, err
+}
+
+// SimulateDeletion previews the removal of every policy whose labels match the selector, such as all the policies
+// of a Helm release
+func SimulateDeletion(ctx context.Context, analysisScheduler analyzer.AnalysisScheduler,
+	clusterState types.ClusterState, selector labels.Selector) (DeletionImpact, error) {
+	afterClusterState := clusterState
+	afterClusterState.NetworkPolicies = make([]*networkingv1.NetworkPolicy, 0)
+	deletedPolicies := make([]types.NetworkPolicy, 0)
+	for _, policy := range clusterState.NetworkPolicies {
+		if selector.Matches(labels.Set(policy.Labels)) {
+			deletedPolicies = append(deletedPolicies, types.NetworkPolicy{Name: policy.Name,
+				Namespace: policy.Namespace, Labels: policy.Labels})
+			continue
+		}
+		afterClusterState.NetworkPolicies = append(afterClusterState.NetworkPolicies, policy)
+	}
+	sort.Slice(deletedPolicies, func(i, j int) bool {
+		if deletedPolicies[i].Namespace != deletedPolicies[j].Namespace {
+			return deletedPolicies[i].Namespace < deletedPolicies[j].Namespace
+		}
+		return deletedPolicies[i].Name < deletedPolicies[j].Name
+	})
+	before, after, err := analyzeBeforeAndAfter(ctx, analysisScheduler, clusterState, afterClusterState)
+	if err != nil {
+		return DeletionImpact{}, err
+	}
+	isolationsAfter := make(map[types.PodRef]*types.PodIsolation)
+	for _, podIsolation := range after.PodIsolations {
+		isolationsAfter[podIsolation.Pod] = podIsolation
+	}
+	impact := DeletionImpact{
+		Selector:                   selector.String(),
+		DeletedPolicies:            deletedPolicies,
+		PodsLosingIngressIsolation: make([]types.PodRef, 0),
+		PodsLosingEgressIsolation:  make([]types.PodRef, 0),
+		Routes:                     routediff.Compute(before.AllowedRoutes, after.AllowedRoutes),
+	}
+	for _, podIsolation := range before.PodIsolations {
+		isolationAfter, ok := isolationsAfter[podIsolation.Pod]
+		if !ok {
+			continue
+		}
+		if podIsolation.IsIngressIsolated && !isolationAfter.IsIngressIsolated {
+			impact.PodsLosingIngressIsolation = append(impact.PodsLosingIngressIsolation, podIsolation.Pod)
+		}
+		if podIsolation.IsEgressIsolated && !isolationAfter.IsEgressIsolated {
+			impact.PodsLosingEgressIsolation = append(impact.PodsLosingEgressIsolation, podIsolation.Pod)
+		}
+	}
+	sortPodRefs(impact.PodsLosingIngressIsolation)
+	sortPodRefs(impact.PodsLosingEgressIsolation)
+	return impact, nil
+}
@@ -0,0 +1,137 @@
+package explain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/routediff"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestSimulateDeletion(t *testing.T) {
+	frontRef := types.PodRef{Name: "front", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api", Namespace: "shop"}
+	clusterState := types.ClusterState{
+		Pods: []*corev1.Pod{
+			testutils.NewPodBuilder().WithName("front").WithNamespace("shop").Build(),
+			testutils.NewPodBuilder().WithName("api").WithNamespace("shop").Build(),
+		},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{
+			testutils.NewNetworkPolicyBuilder().WithName("deny-all").WithNamespace("shop").
+				WithLabel("app.kubernetes.io/instance", "shop").Build(),
+			testutils.NewNetworkPolicyBuilder().WithName("allow-api").WithNamespace("shop").
+				WithLabel("app.kubernetes.io/instance", "shop").Build(),
+			testutils.NewNetworkPolicyBuilder().WithName("platform").WithNamespace("shop").Build(),
+		},
+	}
+	openedRoute := &types.AllowedRoute{SourcePod: apiRef, TargetPod: frontRef}
+	analysisScheduler := mockAnalysisScheduler{
+		allowedRoutesByPolicyCount: map[int][]*types.AllowedRoute{
+			3: {{SourcePod: frontRef, TargetPod: apiRef}},
+			1: {{SourcePod: frontRef, TargetPod: apiRef}, openedRoute},
+		},
+		podIsolationsByPolicyCount: map[int][]*types.PodIsolation{
+			3: {
+				{Pod: frontRef, IsIngressIsolated: true, IsEgressIsolated: true},
+				{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: false},
+			},
+			1: {
+				{Pod: frontRef, IsIngressIsolated: false, IsEgressIsolated: true},
+				{Pod: apiRef, IsIngressIsolated: true, IsEgressIsolated: false},
+			},
+		},
+	}
+	selector, _ := labels.Parse("app.kubernetes.io/instance=shop")
+	impact, err := SimulateDeletion(context.Background(), analysisScheduler, clusterState, selector)
+	if err != nil {
+		t.Fatalf("SimulateDeletion() unexpected error: %s", err)
+	}
+	expectedImpact := DeletionImpact{
+		Selector: "app.kubernetes.io/instance=shop",
+		DeletedPolicies: []types.NetworkPolicy{
+			{Name: "allow-api", Namespace: "shop", Labels: map[string]string{"app.kubernetes.io/instance": "shop"}},
+			{Name: "deny-all", Namespace: "shop", Labels: map[string]string{"app.kubernetes.io/instance": "shop"}},
+		},
+		PodsLosingIngressIsolation: []types.PodRef{frontRef},
+		PodsLosingEgressIsolation:  []types.PodRef{},
+		Routes: routediff.Diff{
+			Added:   []*types.AllowedRoute{openedRoute},
+			Removed: []*types.AllowedRoute{},
+			Changed: []*routediff.Change{},
+			Causes:  []*routediff.Cause{},
+		},
+	}
+	if diff := cmp.Diff(expectedImpact, impact); diff != "" {
+		t.Errorf("SimulateDeletion() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSimulateDisruption(t *testing.T) {
+	front1Ref := types.PodRef{Name: "front-1", Namespace: "shop"}
+	front2Ref := types.PodRef{Name: "front-2", Namespace: "shop"}
+	apiRef := types.PodRef{Name: "api", Namespace: "shop"}
+	clusterState := types.ClusterState{
+		Deployments: []*appsv1.Deployment{
+			testutils.NewDeploymentBuilder().WithName("front").WithNamespace("shop").WithUID("front").Build(),
+		},
+		ReplicaSets: []*appsv1.ReplicaSet{
+			testutils.NewReplicaSetBuilder().WithName("front-1234").WithNamespace("shop").WithUID("front-1234").
+				WithOwnerUID("front").Build(),
+		},
+		Pods: []*corev1.Pod{
+			testutils.NewPodBuilder().WithName("front-1").WithNamespace("shop").WithOwnerUID("front-1234").Build(),
+			testutils.NewPodBuilder().WithName("front-2").WithNamespace("shop").WithOwnerUID("front-1234").Build(),
+			testutils.NewPodBuilder().WithName("api").WithNamespace("shop").Build(),
+		},
+	}
+	lostRoute := &types.AllowedRoute{SourcePod: front1Ref, TargetPod: apiRef}
+	analysisScheduler := mockAnalysisScheduler{
+		analysisResultsByPodCount: map[int]types.AnalysisResult{
+			3: {
+				AllowedRoutes: []*types.AllowedRoute{lostRoute},
+				Services: []*types.Service{
+					{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{front1Ref, front2Ref}},
+					{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiRef}},
+				},
+			},
+			1: {
+				AllowedRoutes: []*types.AllowedRoute{},
+				Services: []*types.Service{
+					{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{}},
+					{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiRef}},
+				},
+			},
+		},
+	}
+	impact, err := SimulateDisruption(context.Background(), analysisScheduler, clusterState, "shop", "front")
+	if err != nil {
+		t.Fatalf("SimulateDisruption() unexpected error: %s", err)
+	}
+	expectedImpact := DisruptionImpact{
+		Deployment:  types.ResourceRef{Kind: "Deployment", Name: "front", Namespace: "shop"},
+		RemovedPods: []types.PodRef{front1Ref, front2Ref},
+		Routes: routediff.Diff{
+			Added:   []*types.AllowedRoute{},
+			Removed: []*types.AllowedRoute{lostRoute},
+			Changed: []*routediff.Change{},
+			Causes:  []*routediff.Cause{},
+		},
+		ServiceBackings: []*ServiceBacking{
+			{Service: types.ServiceRef{Name: "front", Namespace: "shop"}, LostPods: []types.PodRef{front1Ref, front2Ref},
+				RemainingPods: []types.PodRef{}},
+		},
+	}
+	if diff := cmp.Diff(expectedImpact, impact); diff != "" {
+		t.Errorf("SimulateDisruption() result mismatch (-want +got):\n%s", diff)
+	}
+	_, err = SimulateDisruption(context.Background(), analysisScheduler, clusterState, "shop", "api")
+	if !errors.Is(err, ErrUnknownDeployment) {
+		t.Errorf("SimulateDisruption() error = %v, expected %v", err, ErrUnknownDeployment)
+	}
+}
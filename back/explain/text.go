@@ -0,0 +1,52 @@
+package explain
+
+import (
+	"fmt"
+	"io"
+	"karto/routediff"
+	"karto/types"
+	"strings"
+)
+
+func WriteText(w io.Writer, explanation Explanation) {
+	fmt.Fprintf(w, "Policy %s/%s", explanation.Policy.Namespace, explanation.Policy.Name)
+	if explanation.Replaces {
+		fmt.Fprint(w, " (replaces the existing policy)")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Selected pods: %s\n", formatPodRefs(explanation.SelectedPods))
+	writeRules(w, "Ingress", "from", explanation.IngressRules)
+	writeRules(w, "Egress", "to", explanation.EgressRules)
+	fmt.Fprintln(w, "Route changes:")
+	routediff.WriteText(w, explanation.Routes)
+}
+
+func writeRules(w io.Writer, direction string, preposition string, rules []*RuleExplanation) {
+	for i, rule := range rules {
+		peers := make([]string, 0)
+		if rule.AllPeers {
+			peers = append(peers, "anywhere")
+		} else {
+			if len(rule.Pods) > 0 || len(rule.IPBlocks) == 0 {
+				peers = append(peers, formatPodRefs(rule.Pods))
+			}
+			peers = append(peers, rule.IPBlocks...)
+		}
+		ports := "all ports"
+		if len(rule.Ports) > 0 {
+			ports = strings.Join(rule.Ports, ", ")
+		}
+		fmt.Fprintf(w, "%s rule %d: %s %s on %s\n", direction, i+1, preposition, strings.Join(peers, ", "), ports)
+	}
+}
+
+func formatPodRefs(podRefs []types.PodRef) string {
+	if len(podRefs) == 0 {
+		return "no pod"
+	}
+	formattedPodRefs := make([]string, 0)
+	for _, podRef := range podRefs {
+		formattedPodRefs = append(formattedPodRefs, podRef.Namespace+"/"+podRef.Name)
+	}
+	return strings.Join(formattedPodRefs, ", ")
+}
@@ -0,0 +1,204 @@
+package exportjob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var ErrTooManyJobs = errors.New("too many export jobs are running")
+
+// Job is the state of an export, its output only being available once it succeeded
+type Job struct {
+	ID         string     `json:"id"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Progress   float64    `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+type Output struct {
+	ContentType string
+	FileName    string
+	Content     []byte
+}
+
+// Runner produces the output of an export, reporting its progress as a number of done items out of a total. It must
+// give up as soon as the context is canceled.
+type Runner func(ctx context.Context, progress func(done int, total int)) (Output, error)
+
+type Options struct {
+	// MaxRunningJobs is the maximum of jobs running at the same time, for all owners
+	MaxRunningJobs int
+	// Retention is how long finished jobs and their output are kept
+	Retention time.Duration
+}
+
+type entry struct {
+	owner  string
+	job    Job
+	output Output
+	cancel context.CancelFunc
+}
+
+// Manager runs exports in the background. Jobs are only visible to their owner, so that an export made with a
+// redaction profile cannot be downloaded with another one.
+type Manager struct {
+	options Options
+	mutex   sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+func NewManager(options Options) *Manager {
+	return &Manager{
+		options: options,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+func (manager *Manager) Start(owner string, format string, runner Runner) (Job, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.expire()
+	running := 0
+	for _, entry := range manager.entries {
+		if entry.job.Status == StatusRunning {
+			running++
+		}
+	}
+	if running >= manager.options.MaxRunningJobs {
+		return Job{}, ErrTooManyJobs
+	}
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &entry{
+		owner:  owner,
+		job:    Job{ID: id, Format: format, Status: StatusRunning, CreatedAt: manager.now().UTC()},
+		cancel: cancel,
+	}
+	manager.entries[id] = entry
+	go manager.run(ctx, entry, runner)
+	return entry.job, nil
+}
+
+func (manager *Manager) run(ctx context.Context, entry *entry, runner Runner) {
+	output, err := runner(ctx, func(done int, total int) {
+		if total <= 0 {
+			return
+		}
+		manager.mutex.Lock()
+		entry.job.Progress = float64(done) / float64(total)
+		manager.mutex.Unlock()
+	})
+	entry.cancel()
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	finishedAt := manager.now().UTC()
+	entry.job.FinishedAt = &finishedAt
+	if err != nil {
+		entry.job.Status = StatusFailed
+		entry.job.Error = err.Error()
+		return
+	}
+	entry.job.Status = StatusSucceeded
+	entry.job.Progress = 1
+	entry.output = output
+}
+
+// List returns the jobs of the owner, oldest first
+func (manager *Manager) List(owner string) []Job {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.expire()
+	jobs := make([]Job, 0)
+	for _, entry := range manager.entries {
+		if entry.owner == owner {
+			jobs = append(jobs, entry.job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+func (manager *Manager) Get(owner string, id string) (Job, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	entry, ok := manager.entryOf(owner, id)
+	if !ok {
+		return Job{}, false
+	}
+	return entry.job, true
+}
+
+// Output returns the job along with its output, which is empty unless the job succeeded
+func (manager *Manager) Output(owner string, id string) (Job, Output, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	entry, ok := manager.entryOf(owner, id)
+	if !ok {
+		return Job{}, Output{}, false
+	}
+	return entry.job, entry.output, true
+}
+
+// Cancel stops the job if it is still running, and forgets it
+func (manager *Manager) Cancel(owner string, id string) bool {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	entry, ok := manager.entryOf(owner, id)
+	if !ok {
+		return false
+	}
+	entry.cancel()
+	delete(manager.entries, id)
+	return true
+}
+
+func (manager *Manager) entryOf(owner string, id string) (*entry, bool) {
+	manager.expire()
+	entry, ok := manager.entries[id]
+	if !ok || entry.owner != owner {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (manager *Manager) expire() {
+	now := manager.now()
+	for id, entry := range manager.entries {
+		if entry.job.FinishedAt != nil && now.Sub(*entry.job.FinishedAt) > manager.options.Retention {
+			delete(manager.entries, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	bytes := make([]byte, 16)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,115 @@
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	manager := NewManager(Options{MaxRunningJobs: 1, Retention: time.Hour})
+	progressed := make(chan bool)
+	finish := make(chan bool)
+	job, err := manager.Start("alice", "report", func(ctx context.Context, progress func(int, int)) (Output, error) {
+		progress(1, 4)
+		progressed <- true
+		<-finish
+		return Output{ContentType: "text/plain", FileName: "report.txt", Content: []byte("report")}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-progressed
+	running, ok := manager.Get("alice", job.ID)
+	if !ok || running.Status != StatusRunning || running.Progress != 0.25 {
+		t.Errorf("Get() = %+v, %v, expected a running job at 25%%", running, ok)
+	}
+	if _, ok := manager.Get("bob", job.ID); ok {
+		t.Errorf("Get() found the job of another owner")
+	}
+	_, err = manager.Start("bob", "report", nil)
+	if err != ErrTooManyJobs {
+		t.Errorf("Start() error = %v, expected %v", err, ErrTooManyJobs)
+	}
+	finish <- true
+	succeeded := waitForCompletion(t, manager, "alice", job.ID)
+	if succeeded.Status != StatusSucceeded || succeeded.Progress != 1 {
+		t.Errorf("Get() = %+v, expected a succeeded job", succeeded)
+	}
+	_, output, _ := manager.Output("alice", job.ID)
+	expectedOutput := Output{ContentType: "text/plain", FileName: "report.txt", Content: []byte("report")}
+	if diff := cmp.Diff(expectedOutput, output); diff != "" {
+		t.Errorf("Output() result mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Job{succeeded}, manager.List("alice")); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Job{}, manager.List("bob")); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	manager.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, ok := manager.Get("alice", job.ID); ok {
+		t.Errorf("Get() found a job finished for longer than the retention")
+	}
+}
+
+func TestManagerFailure(t *testing.T) {
+	manager := NewManager(Options{MaxRunningJobs: 1, Retention: time.Hour})
+	job, err := manager.Start("", "ndjson", func(ctx context.Context, progress func(int, int)) (Output, error) {
+		return Output{}, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := waitForCompletion(t, manager, "", job.ID)
+	if failed.Status != StatusFailed || failed.Error != "boom" {
+		t.Errorf("Get() = %+v, expected a failed job", failed)
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	manager := NewManager(Options{MaxRunningJobs: 1, Retention: time.Hour})
+	canceled := make(chan error)
+	job, err := manager.Start("", "ndjson", func(ctx context.Context, progress func(int, int)) (Output, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return Output{}, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.Cancel("other", job.ID) {
+		t.Errorf("Cancel() canceled the job of another owner")
+	}
+	if !manager.Cancel("", job.ID) {
+		t.Errorf("Cancel() did not find the job")
+	}
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("runner context error = %v, expected %v", err, context.Canceled)
+	}
+	if _, ok := manager.Get("", job.ID); ok {
+		t.Errorf("Get() found a canceled job")
+	}
+	if _, err := manager.Start("", "ndjson", func(context.Context, func(int, int)) (Output, error) {
+		return Output{}, nil
+	}); err != nil {
+		t.Errorf("Start() error = %v after a cancellation, expected none", err)
+	}
+}
+
+func waitForCompletion(t *testing.T, manager *Manager, owner string, id string) Job {
+	for i := 0; i < 100; i++ {
+		job, ok := manager.Get(owner, id)
+		if !ok {
+			t.Fatalf("Get() did not find job %s", id)
+		}
+		if job.Status != StatusRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not complete", id)
+	return Job{}
+}
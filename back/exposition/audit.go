@@ -0,0 +1,159 @@
+package exposition
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"karto/store"
+	"karto/types"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	auditPath       = "/api/audit"
+	auditCollection = "audit"
+	maxAuditEntries = 1000
+	anonymousCaller = "anonymous"
+)
+
+// auditLog records the mutating calls of the API in a store, keyed so that their keys sort chronologically. Only the
+// latest entries are kept.
+type auditLog struct {
+	mutex    sync.Mutex
+	store    store.Store
+	sequence int
+	now      func() time.Time
+}
+
+func newAuditLog(store store.Store) *auditLog {
+	return &auditLog{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// audited records the requests which are neither GET nor HEAD once they are served, including the refused ones. The
+// caller is the redaction role of the bearer token, and the payload is only kept as a hash.
+func (auditLog *auditLog) audited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Oversized payloads are read one byte past the limit, so that the handler still rejects them
+		payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBytes+1))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid payload: %s", err), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		entry := &types.AuditEntry{
+			Caller:        callerOf(r),
+			RemoteAddress: r.RemoteAddr,
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			StatusCode:    recorder.statusCode,
+		}
+		if len(payload) > 0 {
+			hash := sha256.Sum256(payload)
+			entry.PayloadHash = hex.EncodeToString(hash[:])
+		}
+		err = auditLog.record(entry)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+func callerOf(r *http.Request) string {
+	role, _ := r.Context().Value(roleContextKey{}).(string)
+	if role == "" {
+		return anonymousCaller
+	}
+	return role
+}
+
+func (auditLog *auditLog) record(entry *types.AuditEntry) error {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+	entry.Time = auditLog.now().UTC()
+	auditLog.sequence++
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%020d-%010d", entry.Time.UnixNano(), auditLog.sequence)
+	err = auditLog.store.Put(auditCollection, key, content)
+	if err != nil {
+		return err
+	}
+	keys, err := auditLog.store.Keys(auditCollection)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(keys)-maxAuditEntries; i++ {
+		_, err = auditLog.store.Delete(auditCollection, keys[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (auditLog *auditLog) list() ([]*types.AuditEntry, error) {
+	keys, err := auditLog.store.Keys(auditCollection)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*types.AuditEntry, 0)
+	for _, key := range keys {
+		content, ok, err := auditLog.store.Get(auditCollection, key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		entry := &types.AuditEntry{}
+		err = json.Unmarshal(content, entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (handler *handler) listAuditEntries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := handler.auditLog.list()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, entries)
+}
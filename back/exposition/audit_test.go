@@ -0,0 +1,86 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/store"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		token  string
+		body   string
+	}
+	redactionConfig := config.RedactionConfig{
+		Roles: []config.RedactionRole{{Name: "developers", TokenEnv: "DEV_TOKEN"}},
+	}
+	apiRedactor, err := newRedactor(redactionConfig, func(string) string { return "dev-secret" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	apiHandler := newHandler(suppression.NewMemoryStore())
+	apiHandler.auditLog.now = func() time.Time { return now }
+	mux := http.NewServeMux()
+	mux.Handle(viewsPath, apiHandler.auditLog.audited(http.HandlerFunc(apiHandler.handleViews)))
+	mux.Handle(viewsPath+"/", apiHandler.auditLog.audited(http.HandlerFunc(apiHandler.deleteView)))
+	mux.Handle(auditPath, http.HandlerFunc(apiHandler.listAuditEntries))
+	requests := []request{
+		{method: "POST", path: viewsPath, token: "dev-secret", body: "{\"name\":\"shop\",\"controls\":{}}"},
+		{method: "GET", path: viewsPath, token: "dev-secret"},
+		{method: "DELETE", path: viewsPath + "/unknown"},
+	}
+	for _, request := range requests {
+		httpRequest := httptest.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		httpRequest.RemoteAddr = "10.0.0.1:40000"
+		if request.token != "" {
+			httpRequest.Header.Set("Authorization", "Bearer "+request.token)
+		}
+		withRedaction(mux, apiRedactor).ServeHTTP(httptest.NewRecorder(), httpRequest)
+	}
+	entries, err := apiHandler.auditLog.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEntries := []*types.AuditEntry{
+		{Time: now, Caller: "developers", RemoteAddress: "10.0.0.1:40000", Method: "POST", Path: viewsPath,
+			PayloadHash: "85286183495d944bbe67157d8019a099c7ca106d33704be1a3b251411f4d88ac", StatusCode: 201},
+		{Time: now, Caller: "anonymous", RemoteAddress: "10.0.0.1:40000", Method: "DELETE",
+			Path: viewsPath + "/unknown", StatusCode: 404},
+	}
+	if diff := cmp.Diff(expectedEntries, entries); diff != "" {
+		t.Errorf("list() result mismatch (-want +got):\n%s", diff)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", auditPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Response status code = %d, expected %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAuditLogRetention(t *testing.T) {
+	auditLog := newAuditLog(store.NewMemoryStore())
+	for i := 0; i < maxAuditEntries+5; i++ {
+		err := auditLog.record(&types.AuditEntry{Path: "/api/refresh", StatusCode: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := auditLog.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxAuditEntries || entries[0].StatusCode != 5 {
+		t.Errorf("list() kept %d entries from %d, expected %d from 5", len(entries), entries[0].StatusCode,
+			maxAuditEntries)
+	}
+}
@@ -0,0 +1,123 @@
+package exposition
+
+import (
+	"encoding/json"
+	"fmt"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/authoring"
+	"karto/types"
+	"log"
+	"net/http"
+	"sigs.k8s.io/yaml"
+)
+
+func (handler *handler) suggestPolicies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var request authoring.Request
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid suggestion request: %s", err), http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	suggestion, err := authoring.Suggest(handler.lastAnalysisResult, request)
+	handler.mutex.RUnlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid suggestion request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if acceptsYAML(r) {
+		// Manifests can then be piped straight into kubectl
+		writeManifests(w, r, suggestion.Policies)
+		return
+	}
+	writeResponse(w, r, suggestion)
+}
+
+func writeManifests(w http.ResponseWriter, r *http.Request, manifests interface{}) {
+	manifestsJSON, err := json.Marshal(manifests)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	var documents []json.RawMessage
+	err = json.Unmarshal(manifestsJSON, &documents)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	body := make([]byte, 0)
+	for _, document := range documents {
+		if profile := redactionOf(r); profile != nil {
+			document, err = profile.redact(document)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+		documentYAML, err := yaml.JSONToYAML(document)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		body = append(body, []byte("---\n")...)
+		body = append(body, documentYAML...)
+	}
+	w.Header().Set("Content-Type", contentTypeYAML)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (handler *handler) tighteningSuggestions(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	handler.mutex.RLock()
+	suggestions := make([]*types.TighteningSuggestion, 0)
+	for _, suggestion := range handler.lastAnalysisResult.TighteningSuggestions {
+		if namespace == "" || suggestion.Policy.Namespace == namespace {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	handler.mutex.RUnlock()
+	if acceptsYAML(r) {
+		policies := make([]*networkingv1.NetworkPolicy, 0)
+		for _, suggestion := range suggestions {
+			policies = append(policies, suggestion.SuggestedPolicy)
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename=\"tightened-policies.yaml\"")
+		writeManifests(w, r, policies)
+		return
+	}
+	writeResponse(w, r, suggestions)
+}
+
+func (handler *handler) onboardNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		http.Error(w, "missing namespace query parameter", http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	suggestion, err := authoring.Onboard(handler.lastAnalysisResult, namespace)
+	handler.mutex.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if acceptsYAML(r) {
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"%s-onboarding-policies.yaml\"", namespace))
+		writeManifests(w, r, suggestion.Policies)
+		return
+	}
+	writeResponse(w, r, suggestion)
+}
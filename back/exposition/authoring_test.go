@@ -0,0 +1,57 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"karto/types"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuggestPolicies(t *testing.T) {
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front", Namespace: "ns", Labels: map[string]string{"app": "front"}},
+			{Name: "api", Namespace: "ns", Labels: map[string]string{"app": "api"}},
+		},
+		PodIsolations: []*types.PodIsolation{
+			{Pod: types.PodRef{Name: "api", Namespace: "ns"}, IsIngressIsolated: true},
+		},
+	}
+	body := "{\"source\":{\"pod\":{\"name\":\"front\",\"namespace\":\"ns\"}}," +
+		"\"target\":{\"pod\":{\"name\":\"api\",\"namespace\":\"ns\"}},\"port\":80}"
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.lastAnalysisResult = analysisResult
+	request := httptest.NewRequest("POST", "/api/authoring/suggest", strings.NewReader(body))
+	request.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+	handler.suggestPolicies(w, request)
+	expectedBody := "---\n" +
+		"apiVersion: networking.k8s.io/v1\n" +
+		"kind: NetworkPolicy\n" +
+		"metadata:\n" +
+		"  creationTimestamp: null\n" +
+		"  name: allow-front-to-api-80-ingress\n" +
+		"  namespace: ns\n" +
+		"spec:\n" +
+		"  ingress:\n" +
+		"  - from:\n" +
+		"    - podSelector:\n" +
+		"        matchLabels:\n" +
+		"          app: front\n" +
+		"    ports:\n" +
+		"    - port: 80\n" +
+		"      protocol: TCP\n" +
+		"  podSelector:\n" +
+		"    matchLabels:\n" +
+		"      app: api\n" +
+		"  policyTypes:\n" +
+		"  - Ingress\n"
+	if diff := cmp.Diff(200, w.Code); diff != "" {
+		t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedBody, w.Body.String()); diff != "" {
+		t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+	}
+}
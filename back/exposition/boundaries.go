@@ -0,0 +1,30 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/boundary"
+	"karto/config"
+	"net/http"
+)
+
+// boundaryCrossings serves the allowed routes crossing the configured trust boundaries, optionally only one of them
+func (handler *handler) boundaryCrossings(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	boundaries := handler.boundaries
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	if name := r.URL.Query().Get("boundary"); name != "" {
+		var selected []config.Boundary
+		for _, trustBoundary := range boundaries {
+			if trustBoundary.Name == name {
+				selected = []config.Boundary{trustBoundary}
+			}
+		}
+		if selected == nil {
+			http.Error(w, fmt.Sprintf("unknown boundary %s", name), http.StatusNotFound)
+			return
+		}
+		boundaries = selected
+	}
+	writeResponse(w, r, boundary.Build(analysisResult, boundaries))
+}
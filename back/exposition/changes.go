@@ -0,0 +1,40 @@
+package exposition
+
+import (
+	"karto/routediff"
+	"karto/types"
+	"net/http"
+	"time"
+)
+
+const maxRouteChanges = 50
+
+type routeChanges struct {
+	DetectedAt time.Time      `json:"detectedAt"`
+	Routes     routediff.Diff `json:"routes"`
+}
+
+// The changes of routes between consecutive analysis results are kept with their likely causes, most recent first.
+// Only the goroutine keeping the handler updated writes its fields, which it can therefore read without locking.
+func (handler *handler) nextRouteChanges(analysisResult types.AnalysisResult, detectedAt time.Time) []*routeChanges {
+	if !handler.analyzed {
+		// The first analysis result is the baseline
+		return handler.routeChanges
+	}
+	diff := routediff.ComputeWithCauses(handler.lastAnalysisResult, analysisResult)
+	if diff.IsEmpty() {
+		return handler.routeChanges
+	}
+	changes := append([]*routeChanges{{DetectedAt: detectedAt, Routes: diff}}, handler.routeChanges...)
+	if len(changes) > maxRouteChanges {
+		changes = changes[:maxRouteChanges]
+	}
+	return changes
+}
+
+func (handler *handler) listRouteChanges(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	changes := handler.routeChanges
+	handler.mutex.RUnlock()
+	writeResponse(w, r, changes)
+}
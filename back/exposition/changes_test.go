@@ -0,0 +1,56 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/routediff"
+	"karto/types"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListRouteChanges(t *testing.T) {
+	podA := types.PodRef{Name: "a", Namespace: "ns"}
+	podB := types.PodRef{Name: "b", Namespace: "ns"}
+	route := &types.AllowedRoute{SourcePod: podA, TargetPod: podB}
+	withRoute := types.AnalysisResult{
+		Pods:          []*types.Pod{{Name: "a", Namespace: "ns"}, {Name: "b", Namespace: "ns"}},
+		AllowedRoutes: []*types.AllowedRoute{route},
+	}
+	withoutPodB := types.AnalysisResult{Pods: []*types.Pod{{Name: "a", Namespace: "ns"}}}
+	detectedAt := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	resultsChannel := make(chan types.AnalysisResult)
+	handler := newHandler(nil)
+	go handler.keepUpdated(resultsChannel)
+	resultsChannel <- withRoute
+	resultsChannel <- withRoute
+	resultsChannel <- withoutPodB
+	// The handler is updated once the next result is received
+	resultsChannel <- withoutPodB
+	handler.mutex.RLock()
+	changes := handler.routeChanges
+	handler.mutex.RUnlock()
+	expectedChanges := []*routeChanges{
+		{
+			DetectedAt: detectedAt,
+			Routes: routediff.Diff{
+				Added:   []*types.AllowedRoute{},
+				Removed: []*types.AllowedRoute{route},
+				Changed: []*routediff.Change{},
+				Causes: []*routediff.Cause{{SourcePod: podA, TargetPod: podB, Kind: routediff.CausePodDeleted,
+					Resource: types.ResourceRef{Kind: "Pod", Name: "b", Namespace: "ns"}}},
+			},
+		},
+	}
+	for _, change := range changes {
+		change.DetectedAt = detectedAt
+	}
+	if diff := cmp.Diff(expectedChanges, changes); diff != "" {
+		t.Errorf("keepUpdated() route changes mismatch (-want +got):\n%s", diff)
+	}
+	w := httptest.NewRecorder()
+	handler.listRouteChanges(w, httptest.NewRequest("GET", "/api/changes", nil))
+	if diff := cmp.Diff(200, w.Code); diff != "" {
+		t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,182 @@
+package exposition
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"karto/config"
+	"karto/types"
+	"log"
+	"net/http"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type configurationBackup struct {
+	Views        []*types.SavedView          `json:"views"`
+	Suppressions []*types.FindingSuppression `json:"suppressions"`
+	Rules        []config.Rule               `json:"rules"`
+	Intents      []config.Intent             `json:"intents"`
+}
+
+type configurationImport struct {
+	Views        int      `json:"views"`
+	Suppressions int      `json:"suppressions"`
+	Ignored      []string `json:"ignored"`
+}
+
+func (handler *handler) handleConfiguration(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handler.exportConfiguration(w, r)
+	case http.MethodPost:
+		handler.importConfiguration(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *handler) exportConfiguration(w http.ResponseWriter, r *http.Request) {
+	views, err := handler.listViews()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	handler.mutex.RLock()
+	backup := configurationBackup{
+		Views:        views,
+		Suppressions: suppressions,
+		Rules:        append(make([]config.Rule, 0), handler.rules...),
+		Intents:      append(make([]config.Intent, 0), handler.intents...),
+	}
+	handler.mutex.RUnlock()
+	writeResponse(w, r, backup)
+}
+
+// Views and suppressions are restored, while rules and intents, read from the configuration file, are only reported as
+// ignored when they differ from the running ones. With ?replace=true, views and suppressions missing
+// from the document are deleted.
+func (handler *handler) importConfiguration(w http.ResponseWriter, r *http.Request) {
+	backup, err := parseConfigurationBackup(w, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid configuration: %s", err), http.StatusBadRequest)
+		return
+	}
+	replace, _ := strconv.ParseBool(r.URL.Query().Get("replace"))
+	if replace {
+		err = handler.clearConfiguration()
+	}
+	if err == nil {
+		err = handler.restoreConfiguration(backup)
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	result := configurationImport{Views: len(backup.Views), Suppressions: len(backup.Suppressions),
+		Ignored: make([]string, 0)}
+	handler.mutex.RLock()
+	rules, intents := handler.rules, handler.intents
+	handler.mutex.RUnlock()
+	if (len(backup.Rules) > 0 || len(rules) > 0) && !reflect.DeepEqual(backup.Rules, rules) {
+		result.Ignored = append(result.Ignored, "rules")
+	}
+	if (len(backup.Intents) > 0 || len(intents) > 0) && !reflect.DeepEqual(backup.Intents, intents) {
+		result.Ignored = append(result.Ignored, "intents")
+	}
+	writeResponse(w, r, result)
+}
+
+// Documents kept in version control are usually written in YAML, which is accepted as well as JSON
+func parseConfigurationBackup(w http.ResponseWriter, r *http.Request) (configurationBackup, error) {
+	var backup configurationBackup
+	content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		return backup, err
+	}
+	content, err = yaml.YAMLToJSON(content)
+	if err != nil {
+		return backup, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&backup)
+	if err != nil {
+		return backup, err
+	}
+	for _, view := range backup.Views {
+		if view == nil || view.Name == "" || strings.Contains(view.Name, "/") || len(view.Controls) == 0 {
+			return backup, fmt.Errorf("views require a name without slash and controls")
+		}
+	}
+	for _, suppression := range backup.Suppressions {
+		if suppression == nil || suppression.Fingerprint == "" || suppression.Reason == "" {
+			return backup, fmt.Errorf("suppressions require a fingerprint and a reason")
+		}
+	}
+	return backup, nil
+}
+
+func (handler *handler) clearConfiguration() error {
+	names, err := handler.viewStore.Keys(viewsCollection)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		_, err = handler.viewStore.Delete(viewsCollection, name)
+		if err != nil {
+			return err
+		}
+	}
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		return err
+	}
+	for _, suppression := range suppressions {
+		_, err = handler.suppressionStore.Delete(suppression.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (handler *handler) restoreConfiguration(backup configurationBackup) error {
+	now := time.Now().UTC()
+	for _, view := range backup.Views {
+		if view.SavedAt.IsZero() {
+			view.SavedAt = now
+		}
+		content, err := json.Marshal(view)
+		if err != nil {
+			return err
+		}
+		err = handler.viewStore.Put(viewsCollection, view.Name, content)
+		if err != nil {
+			return err
+		}
+	}
+	for _, suppression := range backup.Suppressions {
+		if suppression.CreatedAt.IsZero() {
+			suppression.CreatedAt = now
+		}
+		err := handler.suppressionStore.Save(suppression)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package exposition
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/suppression"
+	"karto/types"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleConfiguration(t *testing.T) {
+	rules := []config.Rule{{Name: "r", Severity: "high", DefaultDeny: &config.DefaultDenyRule{Namespace: "ns"}}}
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		body                 string
+		expectedStatusCode   int
+		expectedBody         string
+		expectedViews        []string
+		expectedSuppressions []string
+	}{
+		{
+			name:   "imports a YAML document next to the existing configuration",
+			method: "POST",
+			path:   "/api/configuration",
+			body: "views:\n  - name: shop\n    controls: {namespaces: [shop]}\n" +
+				"suppressions:\n  - fingerprint: new\n    reason: accepted\n    expiresAt: " + expiresAt + "\n" +
+				"rules:\n  - name: r\n    severity: high\n    defaultDeny:\n      namespace: ns\n",
+			expectedStatusCode:   200,
+			expectedBody:         "{\"views\":1,\"suppressions\":1,\"ignored\":[]}\n",
+			expectedViews:        []string{"existing", "shop"},
+			expectedSuppressions: []string{"new", "old"},
+		},
+		{
+			name:                 "replaces the existing configuration and reports differing rules as ignored",
+			method:               "POST",
+			path:                 "/api/configuration?replace=true",
+			body:                 "{\"views\":[{\"name\":\"shop\",\"controls\":{}}],\"rules\":[]}",
+			expectedStatusCode:   200,
+			expectedBody:         "{\"views\":1,\"suppressions\":0,\"ignored\":[\"rules\"]}\n",
+			expectedViews:        []string{"shop"},
+			expectedSuppressions: []string{},
+		},
+		{
+			name:                 "rejects an invalid document without importing anything",
+			method:               "POST",
+			path:                 "/api/configuration?replace=true",
+			body:                 "{\"views\":[{\"name\":\"shop\",\"controls\":{}}],\"suppressions\":[{\"fingerprint\":\"x\"}]}",
+			expectedStatusCode:   400,
+			expectedViews:        []string{"existing"},
+			expectedSuppressions: []string{"old"},
+		},
+		{
+			name:                 "rejects unknown fields",
+			method:               "POST",
+			path:                 "/api/configuration",
+			body:                 "{\"tenants\":[]}",
+			expectedStatusCode:   400,
+			expectedViews:        []string{"existing"},
+			expectedSuppressions: []string{"old"},
+		},
+		{
+			name:                 "rejects other methods",
+			method:               "DELETE",
+			path:                 "/api/configuration",
+			expectedStatusCode:   405,
+			expectedViews:        []string{"existing"},
+			expectedSuppressions: []string{"old"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestConfigurationHandler(rules, expiresAt)
+			w := httptest.NewRecorder()
+			handler.handleConfiguration(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s\n%s", diff, w.Body.String())
+			}
+			if tt.expectedBody != "" {
+				if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+					t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+				}
+			}
+			views, _ := handler.listViews()
+			viewNames := make([]string, 0)
+			for _, view := range views {
+				viewNames = append(viewNames, view.Name)
+			}
+			if diff := cmp.Diff(tt.expectedViews, viewNames); diff != "" {
+				t.Errorf("Views mismatch (-want +got):\n%s", diff)
+			}
+			suppressions, _ := handler.suppressionStore.List()
+			fingerprints := make([]string, 0)
+			for _, suppression := range suppressions {
+				fingerprints = append(fingerprints, suppression.Fingerprint)
+			}
+			if diff := cmp.Diff(tt.expectedSuppressions, fingerprints); diff != "" {
+				t.Errorf("Suppressions mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExportConfiguration(t *testing.T) {
+	rules := []config.Rule{{Name: "r", Severity: "high", DefaultDeny: &config.DefaultDenyRule{Namespace: "ns"}}}
+	handler := newTestConfigurationHandler(rules, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	w := httptest.NewRecorder()
+	handler.handleConfiguration(w, httptest.NewRequest("GET", "/api/configuration", nil))
+	var backup configurationBackup
+	err := json.Unmarshal(w.Body.Bytes(), &backup)
+	if err != nil {
+		t.Fatalf("Unable to decode the exported configuration: %s", err)
+	}
+	if diff := cmp.Diff("existing", backup.Views[0].Name); diff != "" {
+		t.Errorf("Exported view mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("old", backup.Suppressions[0].Fingerprint); diff != "" {
+		t.Errorf("Exported suppression mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(rules, backup.Rules); diff != "" {
+		t.Errorf("Exported rules mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]config.Intent{}, backup.Intents); diff != "" {
+		t.Errorf("Exported intents mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func newTestConfigurationHandler(rules []config.Rule, expiresAt string) *handler {
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.rules = rules
+	handler.handleViews(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/views",
+		strings.NewReader("{\"name\":\"existing\",\"controls\":{\"autoRefresh\":true}}")))
+	expiration, _ := time.Parse(time.RFC3339, expiresAt)
+	_ = handler.suppressionStore.Save(&types.FindingSuppression{Fingerprint: "old", Reason: "accepted",
+		ExpiresAt: expiration})
+	return handler
+}
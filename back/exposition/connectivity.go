@@ -0,0 +1,84 @@
+package exposition
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"karto/analyzer/utils"
+	"karto/types"
+	"net/http"
+	"strings"
+)
+
+const maxConnectivityQueries = 1000
+
+// validProtocols are those of network policies, the empty protocol standing for TCP
+var validProtocols = map[corev1.Protocol]bool{"": true, corev1.ProtocolTCP: true, corev1.ProtocolUDP: true,
+	corev1.ProtocolSCTP: true}
+
+type connectivityBatchRequest struct {
+	Queries []types.ConnectivityQuery `json:"queries"`
+}
+
+type connectivityBatchResponse struct {
+	Verdicts []types.ConnectivityVerdict `json:"verdicts"`
+}
+
+func (handler *handler) checkConnectivityBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var request connectivityBatchRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid connectivity request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(request.Queries) > maxConnectivityQueries {
+		http.Error(w, fmt.Sprintf("too many queries, at most %d are allowed", maxConnectivityQueries),
+			http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	verdicts := checkConnectivity(handler.lastAnalysisResult, request.Queries)
+	handler.mutex.RUnlock()
+	writeResponse(w, r, connectivityBatchResponse{Verdicts: verdicts})
+}
+
+func checkConnectivity(analysisResult types.AnalysisResult,
+	queries []types.ConnectivityQuery) []types.ConnectivityVerdict {
+	knownPods := make(map[types.PodRef]bool)
+	for _, pod := range analysisResult.Pods {
+		knownPods[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = true
+	}
+	routesBySourceAndTarget := make(map[types.PodRef]map[types.PodRef]*types.AllowedRoute)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if routesBySourceAndTarget[allowedRoute.SourcePod] == nil {
+			routesBySourceAndTarget[allowedRoute.SourcePod] = make(map[types.PodRef]*types.AllowedRoute)
+		}
+		routesBySourceAndTarget[allowedRoute.SourcePod][allowedRoute.TargetPod] = allowedRoute
+	}
+	verdicts := make([]types.ConnectivityVerdict, 0)
+	for _, query := range queries {
+		verdict := types.ConnectivityVerdict{Query: query}
+		if !knownPods[query.SourcePod] {
+			verdict.Error = fmt.Sprintf("unknown source pod %s/%s", query.SourcePod.Namespace, query.SourcePod.Name)
+		} else if !knownPods[query.TargetPod] {
+			verdict.Error = fmt.Sprintf("unknown target pod %s/%s", query.TargetPod.Namespace, query.TargetPod.Name)
+		} else if protocol := corev1.Protocol(strings.ToUpper(string(query.Protocol))); !validProtocols[protocol] {
+			verdict.Error = fmt.Sprintf("invalid protocol %s, expected TCP, UDP or SCTP", query.Protocol)
+		} else {
+			allowedRoute := routesBySourceAndTarget[query.SourcePod][query.TargetPod]
+			verdict.Allowed = allowedRoute != nil && allowsPort(allowedRoute, query.Port, protocol)
+		}
+		verdicts = append(verdicts, verdict)
+	}
+	return verdicts
+}
+
+func allowsPort(allowedRoute *types.AllowedRoute, port int32, protocol corev1.Protocol) bool {
+	// A zero port means any port is acceptable
+	return port == 0 || utils.AllowsPort(allowedRoute, port, protocol)
+}
@@ -0,0 +1,123 @@
+package exposition
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/suppression"
+	"karto/types"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckConnectivityBatch(t *testing.T) {
+	pod1 := &types.Pod{Name: "pod1", Namespace: "ns"}
+	pod2 := &types.Pod{Name: "pod2", Namespace: "ns"}
+	pod3 := &types.Pod{Name: "pod3", Namespace: "ns"}
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	podRef3 := types.PodRef{Name: "pod3", Namespace: "ns"}
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{pod1, pod2, pod3},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{80, 443}},
+			{SourcePod: podRef2, TargetPod: podRef3, Ports: nil},
+			{SourcePod: podRef3, TargetPod: podRef1, Ports: []int32{53},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolUDP, Ports: []int32{53}}}},
+		},
+	}
+	tests := []struct {
+		name               string
+		method             string
+		body               string
+		expectedStatusCode int
+		expectedVerdicts   []types.ConnectivityVerdict
+	}{
+		{
+			name:   "returns a verdict for each query",
+			method: "POST",
+			body: "{\"queries\":[" +
+				"{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"port\":443}," +
+				"{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"port\":8080}," +
+				"{\"sourcePod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod3\",\"namespace\":\"ns\"},\"port\":8080}," +
+				"{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod3\",\"namespace\":\"ns\"}}," +
+				"{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}}," +
+				"{\"sourcePod\":{\"name\":\"unknown\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"port\":80}" +
+				"]}",
+			expectedStatusCode: 200,
+			expectedVerdicts: []types.ConnectivityVerdict{
+				{Query: types.ConnectivityQuery{SourcePod: podRef1, TargetPod: podRef2, Port: 443}, Allowed: true},
+				{Query: types.ConnectivityQuery{SourcePod: podRef1, TargetPod: podRef2, Port: 8080}, Allowed: false},
+				{Query: types.ConnectivityQuery{SourcePod: podRef2, TargetPod: podRef3, Port: 8080}, Allowed: true},
+				{Query: types.ConnectivityQuery{SourcePod: podRef1, TargetPod: podRef3, Port: 0}, Allowed: false},
+				{Query: types.ConnectivityQuery{SourcePod: podRef1, TargetPod: podRef2, Port: 0}, Allowed: true},
+				{Query: types.ConnectivityQuery{SourcePod: types.PodRef{Name: "unknown", Namespace: "ns"},
+					TargetPod: podRef2, Port: 80}, Allowed: false, Error: "unknown source pod ns/unknown"},
+			},
+		},
+		{
+			name:   "checks the port of the queried protocol",
+			method: "POST",
+			body: "{\"queries\":[" +
+				"{\"sourcePod\":{\"name\":\"pod3\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"port\":53,\"protocol\":\"udp\"}," +
+				"{\"sourcePod\":{\"name\":\"pod3\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"port\":53}," +
+				"{\"sourcePod\":{\"name\":\"pod3\",\"namespace\":\"ns\"}," +
+				"\"targetPod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"port\":53,\"protocol\":\"ICMP\"}" +
+				"]}",
+			expectedStatusCode: 200,
+			expectedVerdicts: []types.ConnectivityVerdict{
+				{Query: types.ConnectivityQuery{SourcePod: podRef3, TargetPod: podRef1, Port: 53, Protocol: "udp"},
+					Allowed: true},
+				{Query: types.ConnectivityQuery{SourcePod: podRef3, TargetPod: podRef1, Port: 53}, Allowed: false},
+				{Query: types.ConnectivityQuery{SourcePod: podRef3, TargetPod: podRef1, Port: 53, Protocol: "ICMP"},
+					Allowed: false, Error: "invalid protocol ICMP, expected TCP, UDP or SCTP"},
+			},
+		},
+		{
+			name:               "rejects malformed requests",
+			method:             "POST",
+			body:               "{\"queries\":",
+			expectedStatusCode: 400,
+			expectedVerdicts:   nil,
+		},
+		{
+			name:               "only accepts POST requests",
+			method:             "GET",
+			body:               "",
+			expectedStatusCode: 405,
+			expectedVerdicts:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.lastAnalysisResult = analysisResult
+			w := httptest.NewRecorder()
+			handler.checkConnectivityBatch(w, httptest.NewRequest(tt.method, "/api/connectivity/batch",
+				strings.NewReader(tt.body)))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if tt.expectedVerdicts == nil {
+				return
+			}
+			var response connectivityBatchResponse
+			err := json.NewDecoder(w.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("Unable to decode response: %s", err)
+			}
+			if diff := cmp.Diff(tt.expectedVerdicts, response.Verdicts); diff != "" {
+				t.Errorf("checkConnectivityBatch() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package exposition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type EncodingOptions struct {
+	OmitEmpty bool
+}
+
+type encoding struct {
+	omitEmpty    bool
+	trimPolicies bool
+}
+
+type encodingContextKey struct{}
+
+var referenceFields = map[string]bool{"name": true, "namespace": true}
+
+var policyFields = map[string]bool{"ingressPolicies": true, "egressPolicies": true}
+
+func withEncoding(next http.Handler, options EncodingOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), encodingContextKey{}, options)))
+	})
+}
+
+// The server-wide default can be overridden per request, with ?omitEmpty and ?verbose
+func encodingOf(r *http.Request) encoding {
+	options, _ := r.Context().Value(encodingContextKey{}).(EncodingOptions)
+	result := encoding{omitEmpty: options.OmitEmpty}
+	query := r.URL.Query()
+	if omitEmpty, err := strconv.ParseBool(query.Get("omitEmpty")); err == nil {
+		result.omitEmpty = omitEmpty
+	}
+	if verbose, err := strconv.ParseBool(query.Get("verbose")); err == nil {
+		result.trimPolicies = !verbose
+	}
+	return result
+}
+
+func (encoding encoding) isDefault() bool {
+	return !encoding.omitEmpty && !encoding.trimPolicies
+}
+
+// Members are rewritten in their original order, so that compacted responses only differ by what they leave out
+func (encoding encoding) compact(value json.RawMessage, trimToReference bool) (json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+		return value, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	openingToken, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	isObject := openingToken == json.Delim('{')
+	var buffer bytes.Buffer
+	buffer.WriteByte(value[0])
+	written := 0
+	for decoder.More() {
+		key := ""
+		if isObject {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key = keyToken.(string)
+		}
+		var member json.RawMessage
+		err = decoder.Decode(&member)
+		if err != nil {
+			return nil, err
+		}
+		if isObject && trimToReference && !referenceFields[key] {
+			continue
+		}
+		// Policies of routes are trimmed to their reference, and so are the elements of these policy arrays
+		member, err = encoding.compact(member, (!isObject && trimToReference) ||
+			(isObject && encoding.trimPolicies && policyFields[key]))
+		if err != nil {
+			return nil, err
+		}
+		if isObject && encoding.omitEmpty && isEmpty(member) {
+			continue
+		}
+		if written > 0 {
+			buffer.WriteByte(',')
+		}
+		if isObject {
+			keyJSON, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(keyJSON)
+			buffer.WriteByte(':')
+		}
+		buffer.Write(member)
+		written++
+	}
+	if isObject {
+		buffer.WriteByte('}')
+	} else {
+		buffer.WriteByte(']')
+	}
+	return buffer.Bytes(), nil
+}
+
+func isEmpty(value json.RawMessage) bool {
+	switch string(value) {
+	case "null", "[]", "{}":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/authoring"
+	"karto/explain"
+	"karto/paths"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteResponseEncoding(t *testing.T) {
+	type args struct {
+		url     string
+		options EncodingOptions
+		accept  string
+	}
+	value := types.AnalysisResult{
+		Pods: []*types.Pod{{Name: "pod1", Namespace: "ns", Labels: map[string]string{}}},
+		AllowedRoutes: []*types.AllowedRoute{{
+			SourcePod:       types.PodRef{Name: "pod1", Namespace: "ns"},
+			TargetPod:       types.PodRef{Name: "pod2", Namespace: "ns"},
+			IngressPolicies: []types.NetworkPolicy{{Name: "policy1", Namespace: "ns", Labels: map[string]string{"a": "b"}}},
+			EgressPolicies:  []types.NetworkPolicy{},
+			Ports:           []int32{80},
+		}},
+	}
+	tests := []struct {
+		name         string
+		args         args
+		expectedBody string
+	}{
+		{
+			name: "omits null values and empty collections when requested",
+			args: args{url: "/api/analysisResult?omitEmpty=true"},
+			expectedBody: "{\"pods\":[{\"name\":\"pod1\",\"namespace\":\"ns\",\"hostPID\":false,\"hostIPC\":false," +
+				"\"privileged\":false,\"zone\":\"\"}],\"allowedRoutes\":[{\"sourcePod\":{\"name\":\"pod1\"," +
+				"\"namespace\":\"ns\"},\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}," +
+				"\"ingressPolicies\":[{\"name\":\"policy1\",\"namespace\":\"ns\",\"labels\":{\"a\":\"b\"}}]," +
+				"\"ports\":[80],\"riskScore\":0}],\"capabilities\":{\"serverVersion\":\"\",\"sctp\":false,\"endPort\":false," +
+				"\"adminNetworkPolicy\":false,\"calicoPolicies\":false,\"ciliumPolicies\":false}," +
+				"\"complete\":false,\"progress\":0}\n",
+		},
+		{
+			name: "omits empty collections by default when configured",
+			args: args{url: "/api/analysisResult?verbose=false", options: EncodingOptions{OmitEmpty: true}},
+			expectedBody: "{\"pods\":[{\"name\":\"pod1\",\"namespace\":\"ns\",\"hostPID\":false,\"hostIPC\":false," +
+				"\"privileged\":false,\"zone\":\"\"}],\"allowedRoutes\":[{\"sourcePod\":{\"name\":\"pod1\"," +
+				"\"namespace\":\"ns\"},\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}," +
+				"\"ingressPolicies\":[{\"name\":\"policy1\",\"namespace\":\"ns\"}],\"ports\":[80],\"riskScore\":0}]," +
+				"\"capabilities\":{\"serverVersion\":\"\",\"sctp\":false,\"endPort\":false," +
+				"\"adminNetworkPolicy\":false,\"calicoPolicies\":false,\"ciliumPolicies\":false}," +
+				"\"complete\":false,\"progress\":0}\n",
+		},
+		{
+			name: "trims route policies to references in YAML too",
+			args: args{url: "/api/analysisResult?verbose=false&omitEmpty=true", accept: "application/yaml"},
+			expectedBody: "allowedRoutes:\n- ingressPolicies:\n  - name: policy1\n    namespace: ns\n  ports:\n  - 80\n" +
+				"  riskScore: 0\n" +
+				"  sourcePod:\n    name: pod1\n    namespace: ns\n  targetPod:\n    name: pod2\n    namespace: ns\n" +
+				"capabilities:\n  adminNetworkPolicy: false\n  calicoPolicies: false\n  ciliumPolicies: false\n" +
+				"  endPort: false\n  sctp: false\n  serverVersion: \"\"\ncomplete: false\npods:\n- hostIPC: false\n" +
+				"  hostPID: false\n  name: pod1\n  namespace: ns\n  privileged: false\n  zone: \"\"\nprogress: 0\n",
+		},
+		{
+			name: "query parameters override the configured default",
+			args: args{url: "/api/analysisResult?verbose=true&omitEmpty=false", options: EncodingOptions{OmitEmpty: true}},
+			expectedBody: "{\"namespaces\":null,\"pods\":[{\"name\":\"pod1\",\"namespace\":\"ns\",\"labels\":{}" +
+				",\"hostPID\":false,\"hostIPC\":false,\"privileged\":false,\"zone\":\"\",\"enrichment\":null}]," +
+				"\"podIsolations\":null,\"allowedRoutes\":[{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"\"egressPolicies\":[],\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"ingressPolicies\":" +
+				"[{\"name\":\"policy1\",\"namespace\":\"ns\",\"labels\":{\"a\":\"b\"}}],\"ports\":[80],\"portRanges\":null," +
+				"\"protocolPorts\":null,\"warnings\":null,\"sameNode\":null,\"intents\":null,\"riskScore\":0," +
+				"\"firstSeen\":null,\"lastSeen\":null}]," +
+				"\"networkPolicies\":null,\"policyExceptions\":null,\"services\":null,\"ingresses\":null," +
+				"\"replicaSets\":null,\"statefulSets\":null,\"daemonSets\":null,\"deployments\":null," +
+				"\"podHealths\":null,\"systemComponents\":null,\"hostPortExposures\":null," +
+				"\"hairpinRoutes\":null,\"network\":null,\"ipBlocks\":null,\"internetAccesses\":null," +
+				"\"capabilities\":{\"serverVersion\":\"\"," +
+				"\"sctp\":false,\"endPort\":false,\"adminNetworkPolicy\":false,\"calicoPolicies\":false," +
+				"\"ciliumPolicies\":false},\"routeVerifications\":null,\"findings\":null,\"compliance\":null," +
+				"\"tighteningSuggestions\":null,\"drift\":null,\"extensions\":null,\"stats\":null," +
+				"\"complete\":false,\"progress\":0}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withEncoding(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeResponse(w, r, value)
+			}), tt.args.options)
+			request := httptest.NewRequest("GET", tt.args.url, nil)
+			request.Header.Set("Accept", tt.args.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONFieldCasing(t *testing.T) {
+	camelCase := regexp.MustCompile("^[a-z][a-zA-Z0-9]*$")
+	visited := make(map[reflect.Type]bool)
+	var check func(valueType reflect.Type)
+	check = func(valueType reflect.Type) {
+		for valueType.Kind() == reflect.Ptr || valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Map {
+			valueType = valueType.Elem()
+		}
+		// Kubernetes objects follow their own conventions
+		if valueType.Kind() != reflect.Struct || visited[valueType] || strings.HasPrefix(valueType.PkgPath(), "k8s.io") {
+			return
+		}
+		visited[valueType] = true
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !camelCase.MatchString(name) {
+				t.Errorf("%s.%s has JSON name %q, which is not camelCase", valueType.Name(), field.Name, name)
+			}
+			check(field.Type)
+		}
+	}
+	for _, value := range []interface{}{types.AnalysisResult{}, types.ConnectivityVerdict{}, types.SavedView{},
+		types.FindingSuppression{}, paths.Result{}, explain.Explanation{}, authoring.Suggestion{},
+		connectivityBatchResponse{}, configurationBackup{}, configurationImport{}, routeChanges{}} {
+		check(reflect.TypeOf(value))
+	}
+}
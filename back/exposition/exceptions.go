@@ -0,0 +1,37 @@
+package exposition
+
+import (
+	"karto/types"
+	"net/http"
+	"strconv"
+)
+
+// listExceptions serves the network policies annotated as temporary exceptions, optionally only those of an owner or
+// the stale ones
+func (handler *handler) listExceptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	staleOnly := false
+	if rawStale := query.Get("stale"); rawStale != "" {
+		var err error
+		staleOnly, err = strconv.ParseBool(rawStale)
+		if err != nil {
+			http.Error(w, "stale must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+	handler.mutex.RLock()
+	exceptions := handler.lastAnalysisResult.PolicyExceptions
+	handler.mutex.RUnlock()
+	writeResponse(w, r, filterExceptions(exceptions, query.Get("owner"), staleOnly))
+}
+
+func filterExceptions(exceptions []*types.PolicyException, owner string, staleOnly bool) []*types.PolicyException {
+	result := make([]*types.PolicyException, 0)
+	for _, exception := range exceptions {
+		if (owner != "" && exception.Owner != owner) || (staleOnly && !exception.Stale) {
+			continue
+		}
+		result = append(result, exception)
+	}
+	return result
+}
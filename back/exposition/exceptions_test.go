@@ -0,0 +1,44 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestFilterExceptions(t *testing.T) {
+	paymentsException := &types.PolicyException{Policy: types.NetworkPolicy{Name: "legacy-db", Namespace: "shop"},
+		Owner: "payments", Ticket: "SEC-42", Stale: true}
+	webException := &types.PolicyException{Policy: types.NetworkPolicy{Name: "debug", Namespace: "web"},
+		Owner: "web"}
+	exceptions := []*types.PolicyException{paymentsException, webException}
+	tests := []struct {
+		name               string
+		owner              string
+		staleOnly          bool
+		expectedExceptions []*types.PolicyException
+	}{
+		{
+			name:               "every exception is listed without filter",
+			expectedExceptions: []*types.PolicyException{paymentsException, webException},
+		},
+		{
+			name:               "exceptions can be filtered by owner",
+			owner:              "web",
+			expectedExceptions: []*types.PolicyException{webException},
+		},
+		{
+			name:               "stale exceptions can be listed alone",
+			staleOnly:          true,
+			expectedExceptions: []*types.PolicyException{paymentsException},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterExceptions(exceptions, tt.owner, tt.staleOnly)
+			if diff := cmp.Diff(tt.expectedExceptions, result); diff != "" {
+				t.Errorf("filterExceptions() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
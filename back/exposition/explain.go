@@ -0,0 +1,120 @@
+package exposition
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/explain"
+	"karto/manifest"
+	"net/http"
+	"strings"
+)
+
+func (handler *handler) explainPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid policy manifest: %s", err), http.StatusBadRequest)
+		return
+	}
+	policyManifest, err := manifest.Parse(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid policy manifest: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(policyManifest.NetworkPolicies) != 1 {
+		http.Error(w, fmt.Sprintf("the manifest must contain exactly one network policy, found %d",
+			len(policyManifest.NetworkPolicies)), http.StatusBadRequest)
+		return
+	}
+	explanation, err := handler.policyExplainer.ExplainPolicy(r.Context(), policyManifest.NetworkPolicies[0])
+	if err != nil {
+		writeUnavailable(w, r, err)
+		return
+	}
+	writeResponse(w, r, explanation)
+}
+
+func (handler *handler) simulateDeletion(w http.ResponseWriter, r *http.Request) {
+	rawSelector := r.URL.Query().Get("selector")
+	if rawSelector == "" {
+		http.Error(w, "missing selector query parameter", http.StatusBadRequest)
+		return
+	}
+	selector, err := labels.Parse(rawSelector)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid selector: %s", err), http.StatusBadRequest)
+		return
+	}
+	impact, err := handler.policyExplainer.SimulateDeletion(r.Context(), selector)
+	if err != nil {
+		writeUnavailable(w, r, err)
+		return
+	}
+	writeResponse(w, r, impact)
+}
+
+func (handler *handler) simulateDisruption(w http.ResponseWriter, r *http.Request) {
+	rawDeployment := r.URL.Query().Get("deployment")
+	if rawDeployment == "" {
+		http.Error(w, "missing deployment query parameter", http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(rawDeployment, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, fmt.Sprintf("invalid deployment %s: expected <namespace>/<name>", rawDeployment),
+			http.StatusBadRequest)
+		return
+	}
+	impact, err := handler.policyExplainer.SimulateDisruption(r.Context(), parts[0], parts[1])
+	if errors.Is(err, explain.ErrUnknownDeployment) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeUnavailable(w, r, err)
+		return
+	}
+	writeResponse(w, r, impact)
+}
+
+func (handler *handler) explainRoute(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	sourcePod, err := podRefOf(query.Get("source"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid source query parameter: %s", err), http.StatusBadRequest)
+		return
+	}
+	targetPod, err := podRefOf(query.Get("target"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid target query parameter: %s", err), http.StatusBadRequest)
+		return
+	}
+	var port int32
+	if rawPort := query.Get("port"); rawPort != "" {
+		if port, err = parsePort(rawPort); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	protocol := corev1.ProtocolTCP
+	if rawProtocol := query.Get("protocol"); rawProtocol != "" {
+		protocol = corev1.Protocol(strings.ToUpper(rawProtocol))
+	}
+	explanation, err := handler.policyExplainer.ExplainRoute(r.Context(), sourcePod, targetPod, port, protocol)
+	if errors.Is(err, explain.ErrUnknownPod) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeUnavailable(w, r, err)
+		return
+	}
+	writeResponse(w, r, explanation)
+}
@@ -0,0 +1,260 @@
+package exposition
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"karto/explain"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockPolicyExplainer struct{}
+
+func (mock mockPolicyExplainer) Track(<-chan types.ClusterState, chan<- types.ClusterState) {
+}
+
+func (mock mockPolicyExplainer) ExplainPolicy(ctx context.Context, policy *networkingv1.NetworkPolicy) (
+	explain.Explanation, error) {
+	if err := ctx.Err(); err != nil {
+		return explain.Explanation{}, err
+	}
+	return explain.Explanation{Policy: types.NetworkPolicy{Name: policy.Name, Namespace: policy.Namespace}}, nil
+}
+
+func (mock mockPolicyExplainer) SimulateDeletion(ctx context.Context, selector labels.Selector) (
+	explain.DeletionImpact, error) {
+	if err := ctx.Err(); err != nil {
+		return explain.DeletionImpact{}, err
+	}
+	return explain.DeletionImpact{Selector: selector.String()}, nil
+}
+
+func (mock mockPolicyExplainer) SimulateDisruption(ctx context.Context, namespace string, name string) (
+	explain.DisruptionImpact, error) {
+	if name == "unknown" {
+		return explain.DisruptionImpact{}, fmt.Errorf("%w %s/%s", explain.ErrUnknownDeployment, namespace, name)
+	}
+	return explain.DisruptionImpact{Deployment: types.ResourceRef{Kind: "Deployment", Name: name,
+		Namespace: namespace}}, nil
+}
+
+func (mock mockPolicyExplainer) ExplainRoute(ctx context.Context, sourcePod types.PodRef, targetPod types.PodRef,
+	port int32, protocol corev1.Protocol) (explain.RouteExplanation, error) {
+	if targetPod.Name == "unknown" {
+		return explain.RouteExplanation{}, fmt.Errorf("%w %s/%s", explain.ErrUnknownPod, targetPod.Namespace,
+			targetPod.Name)
+	}
+	return explain.RouteExplanation{SourcePod: sourcePod, TargetPod: targetPod, Port: port, Protocol: protocol,
+		Allowed: true}, nil
+}
+
+func TestExplainPolicy(t *testing.T) {
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "a policy manifest is explained",
+			body: "apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n  name: api\n" +
+				"  namespace: shop\nspec:\n  podSelector: {}\n",
+			expectedStatusCode: 200,
+			expectedBody: "{\"policy\":{\"name\":\"api\",\"namespace\":\"shop\",\"labels\":null},\"replaces\":false," +
+				"\"selectedPods\":null,\"ingressRules\":null,\"egressRules\":null," +
+				"\"routes\":{\"added\":null,\"removed\":null,\"changed\":null,\"causes\":null}}\n",
+		},
+		{
+			name:               "a manifest without network policy is rejected",
+			body:               "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n",
+			expectedStatusCode: 400,
+			expectedBody:       "the manifest must contain exactly one network policy, found 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.policyExplainer = mockPolicyExplainer{}
+			w := httptest.NewRecorder()
+			handler.explainPolicy(w, httptest.NewRequest("POST", "/api/explain/policy", strings.NewReader(tt.body)))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimulateDeletion(t *testing.T) {
+	tests := []struct {
+		name               string
+		url                string
+		timeout            time.Duration
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "the deletion of the policies matching the selector is simulated",
+			url:                "/api/simulate/deleteAll?selector=app.kubernetes.io/instance%3Dshop",
+			expectedStatusCode: 200,
+			expectedBody: "{\"selector\":\"app.kubernetes.io/instance=shop\",\"deletedPolicies\":null," +
+				"\"podsLosingIngressIsolation\":null,\"podsLosingEgressIsolation\":null," +
+				"\"routes\":{\"added\":null,\"removed\":null,\"changed\":null,\"causes\":null}}\n",
+		},
+		{
+			name:               "a selector is required",
+			url:                "/api/simulate/deleteAll",
+			expectedStatusCode: 400,
+			expectedBody:       "missing selector query parameter\n",
+		},
+		{
+			name:               "an invalid selector is rejected",
+			url:                "/api/simulate/deleteAll?selector=app%3D%3D%3D",
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "a simulation exceeding the request timeout is abandoned",
+			url:                "/api/simulate/deleteAll?selector=app%3Dfront",
+			timeout:            time.Nanosecond,
+			expectedStatusCode: 503,
+			expectedBody:       "the request timed out\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.policyExplainer = mockPolicyExplainer{}
+			w := httptest.NewRecorder()
+			withTimeout(http.HandlerFunc(handler.simulateDeletion), tt.timeout).ServeHTTP(w,
+				httptest.NewRequest("GET", tt.url, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if tt.expectedBody == "" {
+				return
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimulateDisruption(t *testing.T) {
+	tests := []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "the disruption of the deployment is simulated",
+			url:                "/api/simulate/disruption?deployment=shop/front",
+			expectedStatusCode: 200,
+			expectedBody: "{\"deployment\":{\"kind\":\"Deployment\",\"name\":\"front\",\"namespace\":\"shop\"}," +
+				"\"removedPods\":null,\"routes\":{\"added\":null,\"removed\":null,\"changed\":null," +
+				"\"causes\":null},\"serviceBackings\":null}\n",
+		},
+		{
+			name:               "a deployment is required",
+			url:                "/api/simulate/disruption",
+			expectedStatusCode: 400,
+			expectedBody:       "missing deployment query parameter\n",
+		},
+		{
+			name:               "a deployment without namespace is rejected",
+			url:                "/api/simulate/disruption?deployment=front",
+			expectedStatusCode: 400,
+			expectedBody:       "invalid deployment front: expected <namespace>/<name>\n",
+		},
+		{
+			name:               "an unknown deployment is not found",
+			url:                "/api/simulate/disruption?deployment=shop/unknown",
+			expectedStatusCode: 404,
+			expectedBody:       "unknown deployment shop/unknown\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.policyExplainer = mockPolicyExplainer{}
+			w := httptest.NewRecorder()
+			handler.simulateDisruption(w, httptest.NewRequest("GET", tt.url, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExplainRoute(t *testing.T) {
+	tests := []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "the route is explained for the port and protocol",
+			url:                "/api/explain/route?source=shop/front&target=shop/api&port=53&protocol=udp",
+			expectedStatusCode: 200,
+			expectedBody: "{\"sourcePod\":{\"name\":\"front\",\"namespace\":\"shop\"}," +
+				"\"targetPod\":{\"name\":\"api\",\"namespace\":\"shop\"},\"port\":53,\"protocol\":\"UDP\"," +
+				"\"allowed\":true,\"egress\":null,\"ingress\":null}\n",
+		},
+		{
+			name:               "the port and protocol default to any TCP port",
+			url:                "/api/explain/route?source=shop/front&target=shop/api",
+			expectedStatusCode: 200,
+			expectedBody: "{\"sourcePod\":{\"name\":\"front\",\"namespace\":\"shop\"}," +
+				"\"targetPod\":{\"name\":\"api\",\"namespace\":\"shop\"},\"port\":0,\"protocol\":\"TCP\"," +
+				"\"allowed\":true,\"egress\":null,\"ingress\":null}\n",
+		},
+		{
+			name:               "a target is required",
+			url:                "/api/explain/route?source=shop/front",
+			expectedStatusCode: 400,
+			expectedBody:       "invalid target query parameter: a pod is required\n",
+		},
+		{
+			name:               "an invalid port is rejected",
+			url:                "/api/explain/route?source=shop/front&target=shop/api&port=http",
+			expectedStatusCode: 400,
+			expectedBody:       "ports must be integers between 1 and 65535\n",
+		},
+		{
+			name:               "an unknown pod is not found",
+			url:                "/api/explain/route?source=shop/front&target=shop/unknown",
+			expectedStatusCode: 404,
+			expectedBody:       "unknown pod shop/unknown\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.policyExplainer = mockPolicyExplainer{}
+			w := httptest.NewRecorder()
+			handler.explainRoute(w, httptest.NewRequest("GET", tt.url, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package exposition
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+const (
+	contentTypeNDJSON   = "application/x-ndjson"
+	ndjsonPath          = "/api/export/ndjson"
+	ndjsonFlushInterval = 100
+)
+
+func (handler *handler) exportRoutes(w http.ResponseWriter, r *http.Request) {
+	filters, err := portFiltersOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	routeSort, err := routeSortOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	routes := handler.lastAnalysisResult.AllowedRoutes
+	handler.mutex.RUnlock()
+	writeNDJSON(w, r, sortRoutes(filterRoutes(routes, filters), routeSort))
+}
+
+func (handler *handler) exportPods(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	pods := handler.lastAnalysisResult.Pods
+	handler.mutex.RUnlock()
+	writeNDJSON(w, r, pods)
+}
+
+func (handler *handler) exportServices(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	services := handler.lastAnalysisResult.Services
+	handler.mutex.RUnlock()
+	writeNDJSON(w, r, services)
+}
+
+func (handler *handler) exportPolicies(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	policies := handler.lastAnalysisResult.NetworkPolicies
+	handler.mutex.RUnlock()
+	writeNDJSON(w, r, policies)
+}
+
+// Analysis results are replaced and never mutated, so the items can be streamed without holding the lock
+func writeNDJSON(w http.ResponseWriter, r *http.Request, items interface{}) {
+	offset, limit, ok := pageOf(r)
+	if !ok {
+		http.Error(w, "offset and limit must be positive integers", http.StatusBadRequest)
+		return
+	}
+	values := reflect.ValueOf(items)
+	end := values.Len()
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.Header().Set("X-Total-Count", strconv.Itoa(values.Len()))
+	flusher, canFlush := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	profile := redactionOf(r)
+	for i := offset; i < end; i++ {
+		if err := r.Context().Err(); err != nil {
+			log.Println(err)
+			return
+		}
+		item := values.Index(i).Interface()
+		if profile != nil {
+			redactedItem, err := redactItem(profile, item)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			item = redactedItem
+		}
+		err := encoder.Encode(item)
+		if err != nil {
+			// The client went away, the status code has already been sent
+			log.Println(err)
+			return
+		}
+		if canFlush && (i-offset+1)%ndjsonFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+func pageOf(r *http.Request) (int, int, bool) {
+	offset, ok := positiveQueryParam(r, "offset")
+	if !ok {
+		return 0, 0, false
+	}
+	limit, ok := positiveQueryParam(r, "limit")
+	if !ok {
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+func positiveQueryParam(r *http.Request, name string) (int, bool) {
+	rawValue := r.URL.Query().Get(name)
+	if rawValue == "" {
+		return 0, true
+	}
+	value, err := strconv.Atoi(rawValue)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+func redactItem(profile *redactionProfile, item interface{}) (json.RawMessage, error) {
+	content, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	return profile.redact(content)
+}
@@ -0,0 +1,140 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportNDJSON(t *testing.T) {
+	type args struct {
+		url    string
+		export func(handler *handler, w http.ResponseWriter, r *http.Request)
+	}
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "pod1", Namespace: "ns", Labels: map[string]string{"app": "front"}},
+			{Name: "pod2", Namespace: "ns", Labels: map[string]string{"app": "api"}},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{80}},
+			{SourcePod: podRef2, TargetPod: podRef1},
+		},
+		Services: []*types.Service{
+			{Name: "svc", Namespace: "ns", TargetPods: []types.PodRef{podRef2}, Routing: "cluster"},
+		},
+		NetworkPolicies: []*types.NetworkPolicy{
+			{Name: "policy", Namespace: "ns"},
+		},
+	}
+	tests := []struct {
+		name               string
+		args               args
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "streams one route per line",
+			args: args{
+				url:    ndjsonPath,
+				export: (*handler).exportRoutes,
+			},
+			expectedStatusCode: 200,
+			expectedBody: "{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"egressPolicies\":null," +
+				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"ingressPolicies\":null,\"ports\":[80]," +
+				"\"portRanges\":null,\"protocolPorts\":null,\"warnings\":null,\"sameNode\":null,\"intents\":null," +
+				"\"riskScore\":0,\"firstSeen\":null,\"lastSeen\":null}\n" +
+				"{\"sourcePod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"egressPolicies\":null," +
+				"\"targetPod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"ingressPolicies\":null,\"ports\":null," +
+				"\"portRanges\":null,\"protocolPorts\":null,\"warnings\":null,\"sameNode\":null,\"intents\":null," +
+				"\"riskScore\":0,\"firstSeen\":null,\"lastSeen\":null}\n",
+		},
+		{
+			name: "streams routes allowed on a port, including those allowed on all ports",
+			args: args{
+				url:    ndjsonPath + "?port=443",
+				export: (*handler).exportRoutes,
+			},
+			expectedStatusCode: 200,
+			expectedBody: "{\"sourcePod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"egressPolicies\":null," +
+				"\"targetPod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"ingressPolicies\":null,\"ports\":null," +
+				"\"portRanges\":null,\"protocolPorts\":null,\"warnings\":null,\"sameNode\":null,\"intents\":null," +
+				"\"riskScore\":0,\"firstSeen\":null,\"lastSeen\":null}\n",
+		},
+		{
+			name: "an invalid port range is rejected",
+			args: args{
+				url:    ndjsonPath + "?portRange=9000-8000",
+				export: (*handler).exportRoutes,
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "invalid port range 9000-8000: start is greater than end\n",
+		},
+		{
+			name: "streams a page of pods",
+			args: args{
+				url:    ndjsonPath + "/pods?offset=1&limit=5",
+				export: (*handler).exportPods,
+			},
+			expectedStatusCode: 200,
+			expectedBody: "{\"name\":\"pod2\",\"namespace\":\"ns\",\"labels\":{\"app\":\"api\"}" +
+				",\"hostPID\":false,\"hostIPC\":false,\"privileged\":false,\"zone\":\"\",\"enrichment\":null}\n",
+		},
+		{
+			name: "streams services",
+			args: args{
+				url:    ndjsonPath + "/services",
+				export: (*handler).exportServices,
+			},
+			expectedStatusCode: 200,
+			expectedBody: "{\"name\":\"svc\",\"namespace\":\"ns\"," +
+				"\"targetPods\":[{\"name\":\"pod2\",\"namespace\":\"ns\"}],\"routing\":\"cluster\",\"enrichment\":null}\n",
+		},
+		{
+			name: "streams policies",
+			args: args{
+				url:    ndjsonPath + "/policies?limit=1",
+				export: (*handler).exportPolicies,
+			},
+			expectedStatusCode: 200,
+			expectedBody:       "{\"name\":\"policy\",\"namespace\":\"ns\",\"labels\":null}\n",
+		},
+		{
+			name: "an offset past the end streams nothing",
+			args: args{
+				url:    ndjsonPath + "/pods?offset=10",
+				export: (*handler).exportPods,
+			},
+			expectedStatusCode: 200,
+			expectedBody:       "",
+		},
+		{
+			name: "an invalid page is rejected",
+			args: args{
+				url:    ndjsonPath + "?limit=-1",
+				export: (*handler).exportRoutes,
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "offset and limit must be positive integers\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.lastAnalysisResult = analysisResult
+			w := httptest.NewRecorder()
+			tt.args.export(handler, w, httptest.NewRequest("GET", tt.args.url, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package exposition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"karto/analytics"
+	"karto/exportjob"
+	"karto/parquet"
+	"karto/remediation"
+	"karto/report"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	// The zones of the report exports are resolved without the zoneinfo of the system, the image having none
+	_ "time/tzdata"
+)
+
+const (
+	exportJobsPath         = "/api/exports"
+	maxRunningExportJobs   = 2
+	exportJobRetention     = time.Hour
+	exportFormatNDJSON     = "ndjson"
+	exportFormatRoutes     = "parquetRoutes"
+	exportFormatFindings   = "parquetFindings"
+	exportFormatReport     = "report"
+	exportFormatOverlays   = "remediations"
+	exportProgressInterval = 100
+)
+
+var exportFormats = []string{exportFormatNDJSON, exportFormatRoutes, exportFormatFindings, exportFormatReport,
+	exportFormatOverlays}
+
+// The time zone, a name of the IANA database, the date layout and the numbers only apply to the report format
+type exportJobRequest struct {
+	Format             string `json:"format"`
+	TimeZone           string `json:"timeZone"`
+	DateLayout         string `json:"dateLayout"`
+	Numbers            string `json:"numbers"`
+	ThousandsSeparator string `json:"thousandsSeparator"`
+}
+
+func (handler *handler) handleExportJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeResponse(w, r, handler.exportJobs.List(exportJobOwnerOf(r)))
+	case http.MethodPost:
+		handler.startExportJob(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *handler) startExportJob(w http.ResponseWriter, r *http.Request) {
+	var request exportJobRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid export: %s", err), http.StatusBadRequest)
+		return
+	}
+	textFormat, err := textFormatOf(request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid export: %s", err), http.StatusBadRequest)
+		return
+	}
+	// Like their synchronous endpoint, overlays must carry the real selectors to be applied
+	if request.Format == exportFormatOverlays && redactionOf(r) != nil {
+		http.Error(w, "remediation overlays are not available with a redaction profile", http.StatusForbidden)
+		return
+	}
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	analysisResult.Findings = suppression.Apply(analysisResult.Findings, suppressions)
+	runner, ok := exportRunnerOf(request.Format, analysisResult, redactionOf(r), textFormat)
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid export: format must be one of %s", strings.Join(exportFormats, ", ")),
+			http.StatusBadRequest)
+		return
+	}
+	job, err := handler.exportJobs.Start(exportJobOwnerOf(r), request.Format, runner)
+	if errors.Is(err, exportjob.ErrTooManyJobs) {
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", exportJobsPath+"/"+job.ID)
+	writeResponseWithStatus(w, r, http.StatusAccepted, job)
+}
+
+// handleExportJob serves a job at /api/exports/{id}, its output at /api/exports/{id}/download, and cancels it on
+// deletion
+func (handler *handler) handleExportJob(w http.ResponseWriter, r *http.Request) {
+	owner := exportJobOwnerOf(r)
+	id := strings.TrimPrefix(r.URL.Path, exportJobsPath+"/")
+	if strings.HasSuffix(id, "/download") {
+		handler.downloadExport(w, r, owner, strings.TrimSuffix(id, "/download"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		job, ok := handler.exportJobs.Get(owner, id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeResponse(w, r, job)
+	case http.MethodDelete:
+		if !handler.exportJobs.Cancel(owner, id) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *handler) downloadExport(w http.ResponseWriter, r *http.Request, owner string, id string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	job, output, ok := handler.exportJobs.Output(owner, id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if job.Status != exportjob.StatusSucceeded {
+		http.Error(w, fmt.Sprintf("the export is %s", job.Status), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", output.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", output.FileName))
+	_, err := w.Write(output.Content)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Jobs belong to the token which started them, or are shared by anonymous clients
+func exportJobOwnerOf(r *http.Request) string {
+	if tokenHash, ok := bearerTokenHash(r); ok {
+		return tokenHash
+	}
+	return ""
+}
+
+// Runners work on the analysis result of the time the job was started, and with the redaction of its request
+func exportRunnerOf(format string, analysisResult types.AnalysisResult, profile *redactionProfile,
+	textFormat report.TextFormat) (exportjob.Runner, bool) {
+	switch format {
+	case exportFormatNDJSON:
+		return func(ctx context.Context, progress func(int, int)) (exportjob.Output, error) {
+			var content bytes.Buffer
+			encoder := json.NewEncoder(&content)
+			for i, allowedRoute := range analysisResult.AllowedRoutes {
+				if err := exportProgress(ctx, progress, i, len(analysisResult.AllowedRoutes)); err != nil {
+					return exportjob.Output{}, err
+				}
+				var item interface{} = allowedRoute
+				if profile != nil {
+					redactedItem, err := redactItem(profile, allowedRoute)
+					if err != nil {
+						return exportjob.Output{}, err
+					}
+					item = redactedItem
+				}
+				err := encoder.Encode(item)
+				if err != nil {
+					return exportjob.Output{}, err
+				}
+			}
+			return exportjob.Output{ContentType: contentTypeNDJSON, FileName: "karto-routes.ndjson",
+				Content: content.Bytes()}, nil
+		}, true
+	case exportFormatRoutes:
+		return func(ctx context.Context, progress func(int, int)) (exportjob.Output, error) {
+			analyzedAt := time.Now().UTC()
+			rows := make([][]interface{}, 0, len(analysisResult.AllowedRoutes))
+			for i, allowedRoute := range analysisResult.AllowedRoutes {
+				if err := exportProgress(ctx, progress, i, len(analysisResult.AllowedRoutes)); err != nil {
+					return exportjob.Output{}, err
+				}
+				rows = append(rows, analytics.RouteRow(analyzedAt, allowedRoute))
+			}
+			return parquetOutput("karto-routes.parquet", analytics.RouteColumns, rows)
+		}, true
+	case exportFormatFindings:
+		return func(ctx context.Context, progress func(int, int)) (exportjob.Output, error) {
+			analyzedAt := time.Now().UTC()
+			rows := make([][]interface{}, 0, len(analysisResult.Findings))
+			for i, finding := range analysisResult.Findings {
+				if err := exportProgress(ctx, progress, i, len(analysisResult.Findings)); err != nil {
+					return exportjob.Output{}, err
+				}
+				rows = append(rows, analytics.FindingRow(analyzedAt, finding))
+			}
+			return parquetOutput("karto-findings.parquet", analytics.FindingColumns, rows)
+		}, true
+	case exportFormatReport:
+		return func(ctx context.Context, progress func(int, int)) (exportjob.Output, error) {
+			var content bytes.Buffer
+			err := report.WriteFormattedText(&content, report.Summarize(analysisResult, time.Now().UTC()), textFormat)
+			if err != nil {
+				return exportjob.Output{}, err
+			}
+			return exportjob.Output{ContentType: "text/plain; charset=utf-8", FileName: "karto-report.txt",
+				Content: content.Bytes()}, nil
+		}, true
+	case exportFormatOverlays:
+		return func(ctx context.Context, progress func(int, int)) (exportjob.Output, error) {
+			var content bytes.Buffer
+			err := remediation.WriteOverlays(&content, analysisResult.Findings)
+			if err != nil {
+				return exportjob.Output{}, err
+			}
+			return exportjob.Output{ContentType: "application/gzip", FileName: "karto-remediations.tar.gz",
+				Content: content.Bytes()}, nil
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+func textFormatOf(request exportJobRequest) (report.TextFormat, error) {
+	textFormat := report.TextFormat{
+		DateLayout:         request.DateLayout,
+		Numbers:            request.Numbers,
+		ThousandsSeparator: request.ThousandsSeparator,
+	}
+	if !report.ValidNumbers(request.Numbers) {
+		return report.TextFormat{}, fmt.Errorf("numbers must be one of %s, %s, %s", report.NumbersExact,
+			report.NumbersGrouped, report.NumbersCompact)
+	}
+	if request.TimeZone != "" {
+		location, err := time.LoadLocation(request.TimeZone)
+		if err != nil {
+			return report.TextFormat{}, fmt.Errorf("unknown time zone %s", request.TimeZone)
+		}
+		textFormat.Location = location
+	}
+	return textFormat, nil
+}
+
+// exportProgress reports the progress every few items, and stops the export once the job is canceled
+func exportProgress(ctx context.Context, progress func(int, int), done int, total int) error {
+	if done%exportProgressInterval != 0 {
+		return nil
+	}
+	progress(done, total)
+	return ctx.Err()
+}
+
+func parquetOutput(fileName string, columns []parquet.Column, rows [][]interface{}) (exportjob.Output, error) {
+	var content bytes.Buffer
+	err := parquet.Write(&content, columns, rows)
+	if err != nil {
+		return exportjob.Output{}, err
+	}
+	return exportjob.Output{ContentType: "application/vnd.apache.parquet", FileName: fileName,
+		Content: content.Bytes()}, nil
+}
@@ -0,0 +1,139 @@
+package exposition
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"karto/exportjob"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportJobs(t *testing.T) {
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.lastAnalysisResult.AllowedRoutes = []*types.AllowedRoute{
+		{SourcePod: types.PodRef{Name: "pod1", Namespace: "ns"}, TargetPod: types.PodRef{Name: "pod2", Namespace: "ns"}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(exportJobsPath, handler.handleExportJobs)
+	mux.HandleFunc(exportJobsPath+"/", handler.handleExportJob)
+	serve := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, request)
+		return w
+	}
+
+	w := serve("POST", "/api/exports", "{\"format\":\"dot\"}", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown format status code = %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+	w = serve("POST", "/api/exports", "{\"format\":\"report\",\"timeZone\":\"Mars/Olympus\"}", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown time zone status code = %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+	w = serve("POST", "/api/exports", "{\"format\":\"ndjson\"}", "alice")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Start status code = %d, expected %d: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	var job exportjob.Job
+	err := json.Unmarshal(w.Body.Bytes(), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location := w.Header().Get("Location"); location != "/api/exports/"+job.ID {
+		t.Errorf("Location = %s, expected the path of the job", location)
+	}
+	for i := 0; i < 100 && job.Status == exportjob.StatusRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+		err = json.Unmarshal(serve("GET", "/api/exports/"+job.ID, "", "alice").Body.Bytes(), &job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Status != exportjob.StatusSucceeded {
+		t.Fatalf("Job = %+v, expected it to succeed", job)
+	}
+	if w = serve("GET", "/api/exports/"+job.ID, "", "bob"); w.Code != http.StatusNotFound {
+		t.Errorf("Job of another token status code = %d, expected %d", w.Code, http.StatusNotFound)
+	}
+	w = serve("GET", "/api/exports/"+job.ID+"/download", "", "alice")
+	expectedBody := "{\"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"},\"egressPolicies\":null," +
+		"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"},\"ingressPolicies\":null,\"ports\":null," +
+		"\"portRanges\":null,\"protocolPorts\":null,\"warnings\":null,\"sameNode\":null,\"intents\":null," +
+		"\"riskScore\":0,\"firstSeen\":null,\"lastSeen\":null}\n"
+	if diff := cmp.Diff(expectedBody, w.Body.String()); diff != "" {
+		t.Errorf("Download body mismatch (-want +got):\n%s", diff)
+	}
+	disposition := w.Header().Get("Content-Disposition")
+	if disposition != "attachment; filename=\"karto-routes.ndjson\"" {
+		t.Errorf("Content-Disposition = %s, expected the file name of the export", disposition)
+	}
+	if w = serve("DELETE", "/api/exports/"+job.ID, "", "alice"); w.Code != http.StatusNoContent {
+		t.Errorf("Deletion status code = %d, expected %d", w.Code, http.StatusNoContent)
+	}
+	if w = serve("GET", "/api/exports/"+job.ID+"/download", "", "alice"); w.Code != http.StatusNotFound {
+		t.Errorf("Download of a deleted job status code = %d, expected %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestTextFormatOf(t *testing.T) {
+	tests := []struct {
+		name                string
+		request             exportJobRequest
+		expectedLocation    string
+		expectedNumbers     string
+		expectedErrorPrefix string
+	}{
+		{
+			name:             "no option formats as the scheduled reports",
+			request:          exportJobRequest{Format: exportFormatReport},
+			expectedLocation: "",
+		},
+		{
+			name:             "the time zone is resolved",
+			request:          exportJobRequest{Format: exportFormatReport, TimeZone: "Asia/Tokyo", Numbers: "compact"},
+			expectedLocation: "Asia/Tokyo",
+			expectedNumbers:  "compact",
+		},
+		{
+			name:                "unknown time zones are refused",
+			request:             exportJobRequest{Format: exportFormatReport, TimeZone: "Mars/Olympus"},
+			expectedErrorPrefix: "unknown time zone Mars/Olympus",
+		},
+		{
+			name:                "unknown numbers are refused",
+			request:             exportJobRequest{Format: exportFormatReport, Numbers: "roman"},
+			expectedErrorPrefix: "numbers must be one of",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			textFormat, err := textFormatOf(tt.request)
+			if tt.expectedErrorPrefix != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectedErrorPrefix) {
+					t.Errorf("textFormatOf() error = %v, expected %s", err, tt.expectedErrorPrefix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("textFormatOf() returned an error: %s", err)
+			}
+			location := ""
+			if textFormat.Location != nil {
+				location = textFormat.Location.String()
+			}
+			if location != tt.expectedLocation || textFormat.Numbers != tt.expectedNumbers {
+				t.Errorf("textFormatOf() = %s %s, expected %s %s", location, textFormat.Numbers,
+					tt.expectedLocation, tt.expectedNumbers)
+			}
+		})
+	}
+}
@@ -2,57 +2,210 @@ package exposition
 
 import (
 	"embed"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
+	"karto/analyzer/system"
+	"karto/broadcast"
+	"karto/config"
+	"karto/explain"
+	"karto/exportjob"
+	"karto/metrics"
+	"karto/preflight"
+	"karto/store"
+	"karto/suppression"
 	"karto/types"
 	"log"
 	"net/http"
+	"os"
 	"sync"
+	"time"
 )
 
+const maxRequestBytes = 1 << 20
+
 //go:embed frontend
 var embeddedFrontend embed.FS
 
 type handler struct {
 	mutex              sync.RWMutex
 	lastAnalysisResult types.AnalysisResult
+	suppressionStore   suppression.Store
+	policyExplainer    explain.Explainer
+	viewStore          store.Store
+	auditLog           *auditLog
+	rules              []config.Rule
+	intents            []config.Intent
+	boundaries         []config.Boundary
+	analyzed           bool
+	routeChanges       []*routeChanges
+	exportJobs         *exportjob.Manager
+	// shards hold the routes towards each namespace computed by the analysis in progress
+	shards map[string]types.NamespaceRoutes
+	// pushHub hands new analysis results over to the clients of server-sent events
+	pushHub       *broadcast.Hub
+	refreshes     chan<- struct{}
+	dataFreshness *metrics.DataFreshness
+	// preflightFindings report the permissions missing to the service account, along with those of each analysis
+	preflightFindings []*types.Finding
 }
 
-func newHandler() *handler {
+func newHandler(suppressionStore suppression.Store) *handler {
 	handler := &handler{
+		suppressionStore: suppressionStore,
+		viewStore:        store.NewMemoryStore(),
+		auditLog:         newAuditLog(store.NewMemoryStore()),
 		lastAnalysisResult: types.AnalysisResult{
-			Pods:          make([]*types.Pod, 0),
-			PodIsolations: make([]*types.PodIsolation, 0),
-			AllowedRoutes: make([]*types.AllowedRoute, 0),
-			Services:      make([]*types.Service, 0),
-			Ingresses:     make([]*types.Ingress, 0),
-			ReplicaSets:   make([]*types.ReplicaSet, 0),
-			StatefulSets:  make([]*types.StatefulSet, 0),
-			DaemonSets:    make([]*types.DaemonSet, 0),
-			Deployments:   make([]*types.Deployment, 0),
-			PodHealths:    make([]*types.PodHealth, 0),
+			Namespaces:            make([]*types.Namespace, 0),
+			Pods:                  make([]*types.Pod, 0),
+			PodIsolations:         make([]*types.PodIsolation, 0),
+			AllowedRoutes:         make([]*types.AllowedRoute, 0),
+			NetworkPolicies:       make([]*types.NetworkPolicy, 0),
+			PolicyExceptions:      make([]*types.PolicyException, 0),
+			Services:              make([]*types.Service, 0),
+			Ingresses:             make([]*types.Ingress, 0),
+			ReplicaSets:           make([]*types.ReplicaSet, 0),
+			StatefulSets:          make([]*types.StatefulSet, 0),
+			DaemonSets:            make([]*types.DaemonSet, 0),
+			Deployments:           make([]*types.Deployment, 0),
+			PodHealths:            make([]*types.PodHealth, 0),
+			SystemComponents:      make([]*types.SystemComponent, 0),
+			HostPortExposures:     make([]*types.HostPortExposure, 0),
+			HairpinRoutes:         make([]*types.HairpinRoute, 0),
+			IPBlocks:              make([]*types.IPBlockScope, 0),
+			InternetAccesses:      make([]*types.InternetAccess, 0),
+			RouteVerifications:    make([]*types.RouteVerification, 0),
+			Findings:              make([]*types.Finding, 0),
+			Compliance:            make([]*types.ComplianceProfile, 0),
+			TighteningSuggestions: make([]*types.TighteningSuggestion, 0),
 		},
+		routeChanges: make([]*routeChanges, 0),
+		shards:       make(map[string]types.NamespaceRoutes),
+		pushHub:      broadcast.NewHub(),
+		exportJobs: exportjob.NewManager(exportjob.Options{MaxRunningJobs: maxRunningExportJobs,
+			Retention: exportJobRetention}),
 	}
 	return handler
 }
 
 func (handler *handler) keepUpdated(resultsChannel <-chan types.AnalysisResult) {
 	for {
-		newResults := <-resultsChannel
+		newResults := handler.withPreflightFindings(<-resultsChannel)
+		routeChanges := handler.nextRouteChanges(newResults, time.Now())
 		handler.mutex.Lock()
 		handler.lastAnalysisResult = newResults
+		handler.routeChanges = routeChanges
+		handler.analyzed = true
+		handler.dropOutdatedShards()
 		handler.mutex.Unlock()
+		handler.pushHub.Publish(newResults)
 	}
 }
 
-func (handler *handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	view, err := resultViewOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	handler.mutex.RLock()
-	defer handler.mutex.RUnlock()
-	err := json.NewEncoder(w).Encode(handler.lastAnalysisResult)
+	analysisResult := handler.lastAnalysisResult
+	if !handler.analyzed {
+		analysisResult = partialAnalysisResult(analysisResult, handler.shards)
+	}
+	handler.mutex.RUnlock()
+	analysisResult, err = handler.render(analysisResult, view)
+	if errors.Is(err, errNotRooted) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, analysisResult)
+}
+
+var errNotRooted = errors.New("no analyzed pod belongs to")
+
+// resultView holds the query parameters shaping the analysis result served to a client
+type resultView struct {
+	filters               []portFilter
+	routeSort             string
+	rootFilter            *rootFilter
+	groupSystemComponents bool
+}
+
+func resultViewOf(r *http.Request) (resultView, error) {
+	filters, err := portFiltersOf(r)
+	if err != nil {
+		return resultView{}, err
+	}
+	routeSort, err := routeSortOf(r)
+	if err != nil {
+		return resultView{}, err
+	}
+	rootFilter, err := rootFilterOf(r)
+	if err != nil {
+		return resultView{}, err
 	}
+	return resultView{
+		filters:               filters,
+		routeSort:             routeSort,
+		rootFilter:            rootFilter,
+		groupSystemComponents: r.URL.Query().Get("groupSystemComponents") == "true",
+	}, nil
+}
+
+// render applies the suppressions and the view to the analysis result, as served by the API and pushed to clients
+func (handler *handler) render(analysisResult types.AnalysisResult, view resultView) (types.AnalysisResult,
+	error) {
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		return types.AnalysisResult{}, err
+	}
+	analysisResult.Findings = suppression.Apply(analysisResult.Findings, suppressions)
+	if handler.dataFreshness != nil {
+		analysisResult.DataFreshness = handler.dataFreshness.Snapshot()
+	}
+	analysisResult.AllowedRoutes = filterRoutes(analysisResult.AllowedRoutes, view.filters)
+	if view.rootFilter != nil {
+		var rooted bool
+		analysisResult, rooted = view.rootFilter.prune(analysisResult)
+		if !rooted {
+			root := view.rootFilter.root
+			return types.AnalysisResult{}, fmt.Errorf("%w %s/%s/%s", errNotRooted, root.Namespace, root.Kind,
+				root.Name)
+		}
+	}
+	analysisResult.AllowedRoutes = sortRoutes(analysisResult.AllowedRoutes, view.routeSort)
+	if view.groupSystemComponents {
+		analysisResult = system.Group(analysisResult)
+	}
+	return analysisResult, nil
+}
+
+func (handler *handler) driftReport(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	driftReport := handler.lastAnalysisResult.Drift
+	handler.mutex.RUnlock()
+	if driftReport == nil {
+		http.Error(w, "the desired state has not been analyzed yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeResponse(w, r, driftReport)
+}
+
+// Metrics are written in the text format of Prometheus, and are not rate limited so that scrapes never fail
+func metricsHandler(policyChurn *metrics.PolicyChurn) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		err := policyChurn.WriteText(w)
+		if err != nil {
+			log.Println(err)
+		}
+	})
 }
 
 func healthCheck(w http.ResponseWriter, _ *http.Request) {
@@ -62,18 +215,154 @@ func healthCheck(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func Expose(address string, resultsChannel <-chan types.AnalysisResult) {
-	frontendDir, _ := fs.Sub(embeddedFrontend, "frontend")
-	frontendHandler := http.FileServer(http.FS(frontendDir))
-	apiHandler := newHandler()
-	go apiHandler.keepUpdated(resultsChannel)
-	mux := http.NewServeMux()
-	mux.Handle("/", frontendHandler)
-	mux.Handle("/api/analysisResult", apiHandler)
-	mux.HandleFunc("/health", healthCheck)
+type Options struct {
+	DisableFrontend  bool
+	RateLimit        RateLimitOptions
+	Simulation       SimulationOptions
+	Encoding         EncodingOptions
+	RequestTimeout   time.Duration
+	SuppressionStore suppression.Store
+	ViewStore        store.Store
+	AuditStore       store.Store
+	PolicyExplainer  explain.Explainer
+	DesiredResults   <-chan types.AnalysisResult
+	NamespaceRoutes  <-chan types.NamespaceRoutes
+	Rules            []config.Rule
+	Intents          []config.Intent
+	Boundaries       []config.Boundary
+	PolicyChurn      *metrics.PolicyChurn
+	DataFreshness    *metrics.DataFreshness
+	Redaction        *config.RedactionConfig
+	// Configurations receives the configurations reloaded from the file, replacing the rules, intents, boundaries and
+	// redaction above
+	Configurations <-chan config.Config
+	// Preflight holds the permissions checked on startup, the instance being reported ready when none is missing
+	Preflight *preflight.Report
+	// Refreshes receives the analyses requested through the API, which is only exposed when set
+	Refreshes chan<- struct{}
+}
+
+func Expose(address string, resultsChannel <-chan types.AnalysisResult, options Options) {
 	log.Printf("Listening to incoming requests on %s...\n", address)
-	err := http.ListenAndServe(address, mux)
+	err := http.ListenAndServe(address, Handler(resultsChannel, options))
 	if err != nil {
 		log.Fatalln(err)
 	}
 }
+
+// Handler serves the API and the frontend from the analysis results, as exposed on the address given to Expose
+func Handler(resultsChannel <-chan types.AnalysisResult, options Options) http.Handler {
+	suppressionStore := options.SuppressionStore
+	if suppressionStore == nil {
+		suppressionStore = suppression.NewMemoryStore()
+	}
+	apiHandler := newHandler(suppressionStore)
+	apiHandler.policyExplainer = options.PolicyExplainer
+	apiHandler.rules = options.Rules
+	apiHandler.intents = options.Intents
+	apiHandler.boundaries = options.Boundaries
+	apiHandler.refreshes = options.Refreshes
+	apiHandler.dataFreshness = options.DataFreshness
+	if options.Preflight != nil {
+		apiHandler.preflightFindings = options.Preflight.Findings()
+		apiHandler.lastAnalysisResult = apiHandler.withPreflightFindings(apiHandler.lastAnalysisResult)
+	}
+	if options.ViewStore != nil {
+		apiHandler.viewStore = options.ViewStore
+	}
+	if options.AuditStore != nil {
+		apiHandler.auditLog = newAuditLog(options.AuditStore)
+	}
+	audited := apiHandler.auditLog.audited
+	go apiHandler.keepUpdated(resultsChannel)
+	if options.NamespaceRoutes != nil {
+		go apiHandler.keepShardsUpdated(options.NamespaceRoutes)
+	}
+	apiRateLimiter := newRateLimiter(options.RateLimit)
+	mux := http.NewServeMux()
+	routes := newRouteTimeouts(mux, options.RequestTimeout)
+	if !options.DisableFrontend {
+		frontendDir, _ := fs.Sub(embeddedFrontend, "frontend")
+		mux.Handle("/", newFrontendHandler(frontendDir))
+	}
+	mux.Handle("/api/analysisResult", apiRateLimiter.limit(apiHandler))
+	if options.DesiredResults != nil {
+		desiredHandler := newHandler(suppressionStore)
+		go desiredHandler.keepUpdated(options.DesiredResults)
+		mux.Handle("/api/desired/analysisResult", apiRateLimiter.limit(desiredHandler))
+		mux.Handle("/api/drift", apiRateLimiter.limit(http.HandlerFunc(apiHandler.driftReport)))
+	}
+	if options.Refreshes != nil {
+		mux.Handle(refreshPath, apiRateLimiter.limit(audited(http.HandlerFunc(apiHandler.requestRefresh))))
+	}
+	mux.Handle("/api/stats/lastRun", apiRateLimiter.limit(http.HandlerFunc(apiHandler.lastRunStats)))
+	mux.Handle("/api/summary", apiRateLimiter.limit(http.HandlerFunc(apiHandler.resultSummary)))
+	mux.Handle("/api/connectivity/batch",
+		apiRateLimiter.limit(http.HandlerFunc(apiHandler.checkConnectivityBatch)))
+	mux.Handle("/api/paths", apiRateLimiter.limit(http.HandlerFunc(apiHandler.findPaths)))
+	mux.Handle("/api/reachers", apiRateLimiter.limit(http.HandlerFunc(apiHandler.findReachers)))
+	mux.Handle("/api/exceptions", apiRateLimiter.limit(http.HandlerFunc(apiHandler.listExceptions)))
+	mux.Handle("/api/changes", apiRateLimiter.limit(http.HandlerFunc(apiHandler.listRouteChanges)))
+	routes.handleStreaming(eventsPath, apiRateLimiter.limit(http.HandlerFunc(apiHandler.pushResults)))
+	mux.Handle("/api/routes/namespace", apiRateLimiter.limit(http.HandlerFunc(apiHandler.listNamespaceRoutes)))
+	mux.Handle("/api/routes/sample", apiRateLimiter.limit(http.HandlerFunc(apiHandler.sampleRoutes)))
+	mux.Handle("/api/heatmap", apiRateLimiter.limit(http.HandlerFunc(apiHandler.buildHeatmap)))
+	mux.Handle("/api/boundaries", apiRateLimiter.limit(http.HandlerFunc(apiHandler.boundaryCrossings)))
+	mux.Handle("/api/authoring/suggest", apiRateLimiter.limit(requireFeature(config.FeatureAuthoring,
+		http.HandlerFunc(apiHandler.suggestPolicies))))
+	mux.Handle("/api/authoring/onboarding", apiRateLimiter.limit(requireFeature(config.FeatureAuthoring,
+		http.HandlerFunc(apiHandler.onboardNamespace))))
+	if options.PolicyExplainer != nil {
+		simulationLimiter := newSimulationLimiter(options.Simulation)
+		mux.Handle("/api/explain/policy", apiRateLimiter.limit(audited(requireFeature(config.FeatureSimulate,
+			simulationLimiter.limit(http.HandlerFunc(apiHandler.explainPolicy))))))
+		mux.Handle("/api/simulate/deleteAll", apiRateLimiter.limit(audited(requireFeature(config.FeatureSimulate,
+			simulationLimiter.limit(http.HandlerFunc(apiHandler.simulateDeletion))))))
+		mux.Handle("/api/simulate/disruption", apiRateLimiter.limit(audited(requireFeature(config.FeatureSimulate,
+			simulationLimiter.limit(http.HandlerFunc(apiHandler.simulateDisruption))))))
+		mux.Handle("/api/explain/route", apiRateLimiter.limit(http.HandlerFunc(apiHandler.explainRoute)))
+	}
+	mux.Handle("/api/suggestions/tightening",
+		apiRateLimiter.limit(http.HandlerFunc(apiHandler.tighteningSuggestions)))
+	mux.Handle("/api/remediations/overlay",
+		apiRateLimiter.limit(http.HandlerFunc(apiHandler.remediationOverlays)))
+	routes.handleStreaming(ndjsonPath, apiRateLimiter.limit(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.exportRoutes))))
+	routes.handleStreaming(ndjsonPath+"/pods", apiRateLimiter.limit(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.exportPods))))
+	routes.handleStreaming(ndjsonPath+"/services", apiRateLimiter.limit(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.exportServices))))
+	routes.handleStreaming(ndjsonPath+"/policies", apiRateLimiter.limit(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.exportPolicies))))
+	mux.Handle(exportJobsPath, apiRateLimiter.limit(audited(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.handleExportJobs)))))
+	mux.Handle(exportJobsPath+"/", apiRateLimiter.limit(audited(requireFeature(config.FeatureExports,
+		http.HandlerFunc(apiHandler.handleExportJob)))))
+	mux.Handle(viewsPath, apiRateLimiter.limit(audited(http.HandlerFunc(apiHandler.handleViews))))
+	mux.Handle(viewsPath+"/", apiRateLimiter.limit(audited(http.HandlerFunc(apiHandler.deleteView))))
+	// Importing a configuration restores or replaces the suppressions
+	mux.Handle("/api/configuration", apiRateLimiter.limit(audited(requireFeatureToModify(config.FeatureSuppressions,
+		http.HandlerFunc(apiHandler.handleConfiguration)))))
+	mux.Handle(suppressionsPath, apiRateLimiter.limit(audited(requireFeatureToModify(config.FeatureSuppressions,
+		http.HandlerFunc(apiHandler.handleSuppressions)))))
+	mux.Handle(suppressionsPath+"/", apiRateLimiter.limit(audited(requireFeatureToModify(config.FeatureSuppressions,
+		http.HandlerFunc(apiHandler.deleteSuppression)))))
+	mux.Handle(auditPath, apiRateLimiter.limit(http.HandlerFunc(apiHandler.listAuditEntries)))
+	mux.HandleFunc("/health", healthCheck)
+	mux.HandleFunc("/readyz", readinessCheck(options.Preflight))
+	if options.PolicyChurn != nil {
+		mux.Handle("/metrics", metricsHandler(options.PolicyChurn))
+	}
+	redaction := &reloadableRedaction{}
+	if options.Redaction != nil {
+		var err error
+		redaction.redactor, err = newRedactor(*options.Redaction, os.Getenv)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if options.Configurations != nil {
+		go apiHandler.keepConfigured(options.Configurations, redaction)
+	}
+	return withDataFreshness(withEncoding(redaction.wrap(routes), options.Encoding), options.DataFreshness)
+}
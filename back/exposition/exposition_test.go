@@ -3,9 +3,13 @@ package exposition
 import (
 	"github.com/google/go-cmp/cmp"
 	"io/ioutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/metrics"
 	"karto/types"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
@@ -17,6 +21,8 @@ func TestExpose(t *testing.T) {
 		endPoint       string
 		analysisResult types.AnalysisResult
 	}
+	namespace := &types.Namespace{Name: "ns", Labels: map[string]string{"k0": "v0"}, PodCount: 2,
+		IngressIsolatedPods: 1, EgressIsolatedPods: 1, DefaultDenyIngress: true, DefaultDenyEgress: false}
 	pod1 := &types.Pod{Name: "pod1", Namespace: "ns", Labels: map[string]string{"k1": "v1"}}
 	pod2 := &types.Pod{Name: "pod2", Namespace: "ns", Labels: map[string]string{"k2": "v2"}}
 	podRef1 := types.PodRef{Name: pod1.Name, Namespace: pod1.Namespace}
@@ -26,9 +32,10 @@ func TestExpose(t *testing.T) {
 	networkPolicy1 := types.NetworkPolicy{Name: "eg", Namespace: "ns", Labels: map[string]string{"k3": "v3"}}
 	networkPolicy2 := types.NetworkPolicy{Name: "in", Namespace: "ns", Labels: map[string]string{"k4": "v4"}}
 	allowedRoute := &types.AllowedRoute{SourcePod: podRef1, EgressPolicies: []types.NetworkPolicy{networkPolicy1},
-		TargetPod: podRef2, IngressPolicies: []types.NetworkPolicy{networkPolicy2}, Ports: []int32{80, 443}}
-	service1 := &types.Service{Name: "svc1", Namespace: "ns", TargetPods: []types.PodRef{podRef1}}
-	service2 := &types.Service{Name: "svc2", Namespace: "ns", TargetPods: []types.PodRef{podRef2}}
+		TargetPod: podRef2, IngressPolicies: []types.NetworkPolicy{networkPolicy2}, Ports: []int32{80, 443},
+		Warnings: []string{"warning"}, Intents: []string{"purpose"}, RiskScore: 3}
+	service1 := &types.Service{Name: "svc1", Namespace: "ns", TargetPods: []types.PodRef{podRef1}, Routing: "cluster"}
+	service2 := &types.Service{Name: "svc2", Namespace: "ns", TargetPods: []types.PodRef{podRef2}, Routing: "node"}
 	serviceRef1 := types.ServiceRef{Name: "svc1", Namespace: "ns"}
 	serviceRef2 := types.ServiceRef{Name: "svc2", Namespace: "ns"}
 	ingress1 := &types.Ingress{Name: "ing1", Namespace: "ns",
@@ -51,6 +58,19 @@ func TestExpose(t *testing.T) {
 		ContainersWithoutRestart: 1}
 	podHealth2 := &types.PodHealth{Pod: podRef2, Containers: 2, ContainersRunning: 1, ContainersReady: 0,
 		ContainersWithoutRestart: 2}
+	systemComponent := &types.SystemComponent{Name: "kube-dns", Namespace: "ns", Pods: []types.PodRef{podRef1}}
+	capabilities := types.ClusterCapabilities{ServerVersion: "1.21.0", SCTP: true, EndPort: false,
+		AdminNetworkPolicy: false}
+	routeVerification := &types.RouteVerification{SourcePod: podRef1, TargetPod: podRef2, Port: 80, Allowed: true,
+		Reachable: true, Status: "confirmed", Message: "",
+		VerifiedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)}
+	finding := &types.Finding{Fingerprint: "abc", Rule: "rule", Severity: "low",
+		Resource: types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns"}, Message: "msg"}
+	tighteningSuggestion := &types.TighteningSuggestion{Policy: networkPolicy2, Reasons: []string{"reason"},
+		SuggestedPolicy: &networkingv1.NetworkPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: "in", Namespace: "ns"},
+		}}
 	tests := []struct {
 		name         string
 		args         args
@@ -69,22 +89,44 @@ func TestExpose(t *testing.T) {
 			args: args{
 				endPoint: "/api/analysisResult",
 				analysisResult: types.AnalysisResult{
-					Pods:          []*types.Pod{pod1, pod2},
-					PodIsolations: []*types.PodIsolation{podIsolation1, podIsolation2},
-					AllowedRoutes: []*types.AllowedRoute{allowedRoute},
-					Services:      []*types.Service{service1, service2},
-					Ingresses:     []*types.Ingress{ingress1, ingress2},
-					ReplicaSets:   []*types.ReplicaSet{replicaSet1, replicaSet2},
-					StatefulSets:  []*types.StatefulSet{statefulSet1, statefulSet2},
-					DaemonSets:    []*types.DaemonSet{daemonSet1, daemonSet2},
-					Deployments:   []*types.Deployment{deployment1, deployment2},
-					PodHealths:    []*types.PodHealth{podHealth1, podHealth2},
+					Namespaces:            []*types.Namespace{namespace},
+					Pods:                  []*types.Pod{pod1, pod2},
+					PodIsolations:         []*types.PodIsolation{podIsolation1, podIsolation2},
+					AllowedRoutes:         []*types.AllowedRoute{allowedRoute},
+					NetworkPolicies:       []*types.NetworkPolicy{&networkPolicy1, &networkPolicy2},
+					Services:              []*types.Service{service1, service2},
+					Ingresses:             []*types.Ingress{ingress1, ingress2},
+					ReplicaSets:           []*types.ReplicaSet{replicaSet1, replicaSet2},
+					StatefulSets:          []*types.StatefulSet{statefulSet1, statefulSet2},
+					DaemonSets:            []*types.DaemonSet{daemonSet1, daemonSet2},
+					Deployments:           []*types.Deployment{deployment1, deployment2},
+					PodHealths:            []*types.PodHealth{podHealth1, podHealth2},
+					SystemComponents:      []*types.SystemComponent{systemComponent},
+					Capabilities:          capabilities,
+					RouteVerifications:    []*types.RouteVerification{routeVerification},
+					Findings:              []*types.Finding{finding},
+					TighteningSuggestions: []*types.TighteningSuggestion{tighteningSuggestion},
+					Complete:              true,
+					Progress:              100,
 				},
 			},
 			expectedBody: "{" +
+				"\"namespaces\":[" +
+				"    {" +
+				"        \"name\":\"ns\"," +
+				"        \"labels\":{\"k0\":\"v0\"}," +
+				"        \"podCount\":2," +
+				"        \"ingressIsolatedPods\":1," +
+				"        \"egressIsolatedPods\":1," +
+				"        \"defaultDenyIngress\":true," +
+				"        \"defaultDenyEgress\":false" +
+				"    }" +
+				"]," +
 				"\"pods\":[" +
-				"    {\"name\":\"pod1\",\"namespace\":\"ns\",\"labels\":{\"k1\":\"v1\"}}," +
-				"    {\"name\":\"pod2\",\"namespace\":\"ns\",\"labels\":{\"k2\":\"v2\"}}" +
+				"    {\"name\":\"pod1\",\"namespace\":\"ns\",\"labels\":{\"k1\":\"v1\"}," +
+				"     \"hostPID\":false,\"hostIPC\":false,\"privileged\":false,\"zone\":\"\",\"enrichment\":null}," +
+				"    {\"name\":\"pod2\",\"namespace\":\"ns\",\"labels\":{\"k2\":\"v2\"}," +
+				"     \"hostPID\":false,\"hostIPC\":false,\"privileged\":false,\"zone\":\"\",\"enrichment\":null}" +
 				"]," +
 				"\"podIsolations\":[" +
 				"    {" +
@@ -104,30 +146,45 @@ func TestExpose(t *testing.T) {
 				"\"egressPolicies\":[{\"name\":\"eg\",\"namespace\":\"ns\",\"labels\":{\"k3\":\"v3\"}}]," +
 				"\"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}," +
 				"\"ingressPolicies\":[{\"name\":\"in\",\"namespace\":\"ns\",\"labels\":{\"k4\":\"v4\"}}]," +
-				"\"ports\":[80,443]" +
+				"\"ports\":[80,443]," +
+				"\"portRanges\":null,\"protocolPorts\":null,\"warnings\":[\"warning\"]," +
+				"\"sameNode\":null," +
+				"\"intents\":[\"purpose\"]," +
+				"\"riskScore\":3," +
+				"\"firstSeen\":null," +
+				"\"lastSeen\":null" +
 				"    }" +
 				"]," +
+				"\"networkPolicies\":[" +
+				"    {\"name\":\"eg\",\"namespace\":\"ns\",\"labels\":{\"k3\":\"v3\"}}," +
+				"    {\"name\":\"in\",\"namespace\":\"ns\",\"labels\":{\"k4\":\"v4\"}}" +
+				"]," +
+				"\"policyExceptions\":null," +
 				"\"services\":[" +
 				"    {" +
 				"        \"name\":\"svc1\"," +
 				"        \"namespace\":\"ns\"," +
-				"        \"targetPods\":[{\"name\":\"pod1\",\"namespace\":\"ns\"}]" +
+				"        \"targetPods\":[{\"name\":\"pod1\",\"namespace\":\"ns\"}]," +
+				"        \"routing\":\"cluster\",\"enrichment\":null" +
 				"    }," +
 				"    {" +
 				"        \"name\":\"svc2\"," +
 				"        \"namespace\":\"ns\"," +
-				"        \"targetPods\":[{\"name\":\"pod2\",\"namespace\":\"ns\"}]" +
+				"        \"targetPods\":[{\"name\":\"pod2\",\"namespace\":\"ns\"}]," +
+				"        \"routing\":\"node\",\"enrichment\":null" +
 				"    }" +
 				"]," +
 				"\"ingresses\":[" +
 				"    {" +
 				"        \"name\":\"ing1\"," +
 				"        \"namespace\":\"ns\"," +
+				"        \"ingressClass\":\"\"," +
 				"        \"targetServices\":[{\"name\":\"svc1\",\"namespace\":\"ns\"}]" +
 				"    }," +
 				"    {" +
 				"        \"name\":\"ing2\"," +
 				"        \"namespace\":\"ns\"," +
+				"        \"ingressClass\":\"\"," +
 				"        \"targetServices\":[{\"name\":\"svc2\",\"namespace\":\"ns\"}]" +
 				"    }" +
 				"]," +
@@ -194,15 +251,139 @@ func TestExpose(t *testing.T) {
 				"        \"containersReady\":0," +
 				"        \"containersWithoutRestart\":2" +
 				"    }" +
-				"]" +
+				"]," +
+				"\"systemComponents\":[" +
+				"    {\"name\":\"kube-dns\",\"namespace\":\"ns\",\"pods\":[{\"name\":\"pod1\",\"namespace\":\"ns\"}]}" +
+				"]," +
+				"\"hostPortExposures\":null," +
+				"\"hairpinRoutes\":null," +
+				"\"network\":null," +
+				"\"ipBlocks\":null," +
+				"\"internetAccesses\":null," +
+				"\"capabilities\":{" +
+				"    \"serverVersion\":\"1.21.0\"," +
+				"    \"sctp\":true," +
+				"    \"endPort\":false," +
+				"    \"adminNetworkPolicy\":false," +
+				"    \"calicoPolicies\":false," +
+				"    \"ciliumPolicies\":false" +
+				"}," +
+				"\"routeVerifications\":[" +
+				"    {" +
+				"        \"sourcePod\":{\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"        \"targetPod\":{\"name\":\"pod2\",\"namespace\":\"ns\"}," +
+				"        \"port\":80," +
+				"        \"allowed\":true," +
+				"        \"reachable\":true," +
+				"        \"status\":\"confirmed\"," +
+				"        \"message\":\"\"," +
+				"        \"verifiedAt\":\"2021-04-01T12:00:00Z\"" +
+				"    }" +
+				"]," +
+				"\"findings\":[" +
+				"    {" +
+				"        \"fingerprint\":\"abc\"," +
+				"        \"rule\":\"rule\"," +
+				"        \"severity\":\"low\"," +
+				"        \"resource\":{\"kind\":\"Pod\",\"name\":\"pod1\",\"namespace\":\"ns\"}," +
+				"        \"peer\":null," +
+				"        \"message\":\"msg\"," +
+				"        \"code\":\"\"," +
+				"        \"parameters\":null," +
+				"        \"remediation\":null," +
+				"        \"suppression\":null" +
+				"    }" +
+				"]," +
+				"\"compliance\":null," +
+				"\"tighteningSuggestions\":[" +
+				"    {" +
+				"        \"policy\":{\"name\":\"in\",\"namespace\":\"ns\",\"labels\":{\"k4\":\"v4\"}}," +
+				"        \"reasons\":[\"reason\"]," +
+				"        \"suggestedPolicy\":{" +
+				"            \"kind\":\"NetworkPolicy\"," +
+				"            \"apiVersion\":\"networking.k8s.io/v1\"," +
+				"            \"metadata\":{\"name\":\"in\",\"namespace\":\"ns\",\"creationTimestamp\":null}," +
+				"            \"spec\":{\"podSelector\":{}}" +
+				"        }" +
+				"    }" +
+				"]," +
+				"\"drift\":null," +
+				"\"extensions\":null," +
+				"\"stats\":null," +
+				"\"complete\":true," +
+				"\"progress\":100" +
 				"}\n",
 		},
+		{
+			name: "exposes the heatmap of the routes between groups of pods",
+			args: args{
+				endPoint: "/api/heatmap?groupBy=namespace",
+				analysisResult: types.AnalysisResult{
+					Pods:          []*types.Pod{pod1, pod2},
+					AllowedRoutes: []*types.AllowedRoute{allowedRoute},
+				},
+			},
+			expectedBody: "{\"groupBy\":\"namespace\",\"groups\":[\"ns\"],\"routes\":[[1]],\"maxRiskScores\":[[3]]}\n",
+		},
+		{
+			name: "exposes the statistics of the last analysis",
+			args: args{
+				endPoint: "/api/stats/lastRun",
+				analysisResult: types.AnalysisResult{
+					Stats: &types.AnalysisStats{StartedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC),
+						DurationMs: 12.5, Stages: []*types.StageStats{{Name: "routes", DurationMs: 10}},
+						Counts: types.ObjectCounts{Pods: 2, AllowedRoutes: 1}},
+				},
+			},
+			expectedBody: "{\"startedAt\":\"2021-04-01T12:00:00Z\",\"durationMs\":12.5," +
+				"\"stages\":[{\"name\":\"routes\",\"durationMs\":10}],\"counts\":{\"namespaces\":0,\"pods\":2," +
+				"\"networkPolicies\":0,\"services\":0,\"ingresses\":0,\"workloads\":0,\"allowedRoutes\":1," +
+				"\"findings\":0,\"excludedPairs\":0,\"mergedRoutes\":0}}\n",
+		},
+		{
+			name: "exposes the summary of the last analysis",
+			args: args{
+				endPoint: "/api/summary",
+				analysisResult: types.AnalysisResult{
+					Namespaces: []*types.Namespace{{Name: "ns", DefaultDenyIngress: true}, {Name: "other"}},
+					Pods:       []*types.Pod{pod1, pod2},
+					PodIsolations: []*types.PodIsolation{
+						{Pod: types.PodRef{Name: pod1.Name, Namespace: pod1.Namespace}, IsIngressIsolated: true},
+						{Pod: types.PodRef{Name: pod2.Name, Namespace: pod2.Namespace}},
+					},
+					AllowedRoutes: []*types.AllowedRoute{allowedRoute, {
+						SourcePod: types.PodRef{Name: "pod3", Namespace: "other"},
+						TargetPod: types.PodRef{Name: pod1.Name, Namespace: pod1.Namespace}},
+					},
+					Findings: []*types.Finding{{Fingerprint: "a", Severity: "high"}, {Fingerprint: "b", Severity: "low"}},
+					Stats: &types.AnalysisStats{StartedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC),
+						ResourceVersions: map[string]string{"pods": "1200"}},
+				},
+			},
+			expectedBody: "{\"pods\":2,\"ingressIsolatedPods\":1,\"egressIsolatedPods\":0,\"namespaces\":2," +
+				"\"defaultDenyIngressNamespaces\":1,\"defaultDenyEgressNamespaces\":0,\"allowedRoutes\":2," +
+				"\"crossNamespaceRoutes\":1,\"findingsBySeverity\":{\"high\":1,\"low\":1,\"medium\":0}," +
+				"\"suppressedFindings\":0,\"analyzedAt\":\"2021-04-01T12:00:00Z\"," +
+				"\"resourceVersions\":{\"pods\":\"1200\"}}\n",
+		},
+		{
+			name: "exposes a sample of the routes with statistics on all of them",
+			args: args{
+				endPoint: "/api/routes/sample?n=1&strategy=crossNamespace",
+				analysisResult: types.AnalysisResult{
+					AllowedRoutes: []*types.AllowedRoute{allowedRoute},
+				},
+			},
+			expectedBody: "{\"strategy\":\"crossNamespace\",\"routes\":[]," +
+				"\"summary\":{\"totalRoutes\":1,\"sampledRoutes\":0,\"crossNamespaceRoutes\":0,\"namespaces\":1," +
+				"\"maxRiskScore\":3,\"averageRiskScore\":3}}\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			address := "localhost:" + strconv.Itoa(findAvailablePort())
 			resultsChannel := make(chan types.AnalysisResult)
-			go Expose(address, resultsChannel)
+			go Expose(address, resultsChannel, Options{})
 			resultsChannel <- tt.args.analysisResult
 			time.Sleep(10 * time.Millisecond)
 			response, _ := http.Get("http://" + address + tt.args.endPoint)
@@ -230,3 +411,15 @@ func findAvailablePort() int {
 	}()
 	return listener.Addr().(*net.TCPAddr).Port
 }
+
+func TestMetricsHandler(t *testing.T) {
+	policyChurn := metrics.NewPolicyChurn()
+	w := httptest.NewRecorder()
+	metricsHandler(policyChurn).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if diff := cmp.Diff("text/plain; version=0.0.4", w.Header().Get("Content-Type")); diff != "" {
+		t.Errorf("Content type mismatch (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(w.Body.String(), "# TYPE karto_network_policy_changes_total counter\n") {
+		t.Errorf("Response body lacks the network policy changes: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,47 @@
+package exposition
+
+import (
+	"karto/metrics"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	freshnessSynced = "synced"
+	freshnessStale  = "stale"
+)
+
+// withDataFreshness tells in headers of every API response whether the watches of the cluster are synced, listing
+// the stale kinds otherwise, and when the last change was received
+func withDataFreshness(next http.Handler, dataFreshness *metrics.DataFreshness) http.Handler {
+	if dataFreshness == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			staleKinds := make([]string, 0)
+			var lastEvent time.Time
+			for kind, resourceFreshness := range dataFreshness.Snapshot() {
+				if !resourceFreshness.Synced {
+					staleKinds = append(staleKinds, kind)
+				}
+				if resourceFreshness.LastEvent.After(lastEvent) {
+					lastEvent = resourceFreshness.LastEvent
+				}
+			}
+			sort.Strings(staleKinds)
+			if len(staleKinds) == 0 {
+				w.Header().Set("X-Data-Freshness", freshnessSynced)
+			} else {
+				w.Header().Set("X-Data-Freshness", freshnessStale)
+				w.Header().Set("X-Stale-Resources", strings.Join(staleKinds, ","))
+			}
+			if !lastEvent.IsZero() {
+				w.Header().Set("X-Last-Event", lastEvent.UTC().Format(time.RFC3339))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
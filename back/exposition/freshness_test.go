@@ -0,0 +1,59 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"karto/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithDataFreshness(t *testing.T) {
+	dataFreshness := metrics.NewDataFreshness()
+	// The informer is never started, hence never synced
+	informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Pods().Informer()
+	err := dataFreshness.Track("pods", informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := withDataFreshness(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), dataFreshness)
+	tests := []struct {
+		name            string
+		path            string
+		expectedHeaders map[string]string
+	}{
+		{
+			name: "API responses tell the stale kinds",
+			path: "/api/analysisResult",
+			expectedHeaders: map[string]string{
+				"X-Data-Freshness":  "stale",
+				"X-Stale-Resources": "pods",
+				"X-Last-Event":      "",
+			},
+		},
+		{
+			name: "frontend responses are left untouched",
+			path: "/index.html",
+			expectedHeaders: map[string]string{
+				"X-Data-Freshness":  "",
+				"X-Stale-Resources": "",
+				"X-Last-Event":      "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			headers := make(map[string]string)
+			for name := range tt.expectedHeaders {
+				headers[name] = w.Header().Get(name)
+			}
+			if diff := cmp.Diff(tt.expectedHeaders, headers); diff != "" {
+				t.Errorf("Response headers mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
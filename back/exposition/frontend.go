@@ -0,0 +1,63 @@
+package exposition
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	indexFile             = "index.html"
+	staticAssetsDirectory = "static/"
+	apiPathPrefix         = "api/"
+	noCache               = "no-cache"
+	immutableCache        = "public, max-age=31536000, immutable"
+)
+
+type frontendHandler struct {
+	files      fs.FS
+	fileServer http.Handler
+}
+
+func newFrontendHandler(files fs.FS) http.Handler {
+	return frontendHandler{
+		files:      files,
+		fileServer: http.FileServer(http.FS(files)),
+	}
+}
+
+func (handler frontendHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filePath := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if strings.HasPrefix(filePath, apiPathPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	if filePath == "" || !handler.fileExists(filePath) {
+		// Unknown routes are handled client side by the single page application
+		filePath = indexFile
+	}
+	if strings.HasPrefix(filePath, staticAssetsDirectory) {
+		// Static assets have a content hash in their name
+		w.Header().Set("Cache-Control", immutableCache)
+	} else {
+		w.Header().Set("Cache-Control", noCache)
+	}
+	fileRequest := new(http.Request)
+	*fileRequest = *r
+	fileRequest.URL = new(url.URL)
+	*fileRequest.URL = *r.URL
+	if filePath == indexFile {
+		// The file server redirects explicit requests to the index file
+		fileRequest.URL.Path = "/"
+	} else {
+		fileRequest.URL.Path = "/" + filePath
+	}
+	handler.fileServer.ServeHTTP(w, fileRequest)
+}
+
+func (handler frontendHandler) fileExists(filePath string) bool {
+	fileInfo, err := fs.Stat(handler.files, filePath)
+	return err == nil && !fileInfo.IsDir()
+}
@@ -0,0 +1,74 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFrontendHandler(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":           {Data: []byte("index")},
+		"favicon.ico":          {Data: []byte("icon")},
+		"static/js/main.1a.js": {Data: []byte("script")},
+	}
+	tests := []struct {
+		name                 string
+		path                 string
+		expectedStatusCode   int
+		expectedBody         string
+		expectedCacheControl string
+	}{
+		{
+			name:                 "index is served on root without caching",
+			path:                 "/",
+			expectedStatusCode:   200,
+			expectedBody:         "index",
+			expectedCacheControl: "no-cache",
+		},
+		{
+			name:                 "existing files are served without caching",
+			path:                 "/favicon.ico",
+			expectedStatusCode:   200,
+			expectedBody:         "icon",
+			expectedCacheControl: "no-cache",
+		},
+		{
+			name:                 "static assets are cached indefinitely",
+			path:                 "/static/js/main.1a.js",
+			expectedStatusCode:   200,
+			expectedBody:         "script",
+			expectedCacheControl: "public, max-age=31536000, immutable",
+		},
+		{
+			name:                 "unknown routes fall back to the index",
+			path:                 "/workloads/ns/pod",
+			expectedStatusCode:   200,
+			expectedBody:         "index",
+			expectedCacheControl: "no-cache",
+		},
+		{
+			name:                 "unknown API routes do not fall back to the index",
+			path:                 "/api/unknown",
+			expectedStatusCode:   404,
+			expectedBody:         "404 page not found\n",
+			expectedCacheControl: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newFrontendHandler(files).ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCacheControl, w.Header().Get("Cache-Control")); diff != "" {
+				t.Errorf("Cache-Control header mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package exposition
+
+import (
+	"karto/heatmap"
+	"net/http"
+)
+
+func (handler *handler) buildHeatmap(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy == "" {
+		groupBy = heatmap.GroupByNamespace
+	}
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	result, err := heatmap.Build(analysisResult, groupBy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeResponse(w, r, result)
+}
@@ -0,0 +1,97 @@
+package exposition
+
+import (
+	"karto/types"
+	"net/http"
+	"sort"
+)
+
+// namespaceRoutes are the routes towards the pods of a namespace. They are partial when they come from an analysis
+// still in progress, in which case they are not scored nor matched against intents yet.
+type namespaceRoutes struct {
+	Namespace     string                `json:"namespace"`
+	Partial       bool                  `json:"partial"`
+	AllowedRoutes []*types.AllowedRoute `json:"allowedRoutes"`
+}
+
+func (handler *handler) keepShardsUpdated(shardsChannel <-chan types.NamespaceRoutes) {
+	for {
+		shard := <-shardsChannel
+		handler.mutex.Lock()
+		handler.shards[shard.Namespace] = shard
+		handler.mutex.Unlock()
+	}
+}
+
+// dropOutdatedShards must be called with the lock held, once the analysis result is updated
+func (handler *handler) dropOutdatedShards() {
+	for namespace, shard := range handler.shards {
+		if !isNewerShard(shard, handler.lastAnalysisResult) {
+			delete(handler.shards, namespace)
+		}
+	}
+}
+
+func isNewerShard(shard types.NamespaceRoutes, analysisResult types.AnalysisResult) bool {
+	return analysisResult.Stats == nil || shard.AnalysisStartedAt.After(analysisResult.Stats.StartedAt)
+}
+
+// partialAnalysisResult completes the analysis result served before the first analysis completes with the pods and
+// routes of the namespaces already computed
+func partialAnalysisResult(analysisResult types.AnalysisResult,
+	shards map[string]types.NamespaceRoutes) types.AnalysisResult {
+	namespaces := make([]string, 0, len(shards))
+	for namespace := range shards {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	analysisResult.Pods = make([]*types.Pod, 0)
+	analysisResult.PodIsolations = make([]*types.PodIsolation, 0)
+	analysisResult.AllowedRoutes = make([]*types.AllowedRoute, 0)
+	for _, namespace := range namespaces {
+		shard := shards[namespace]
+		analysisResult.Pods = append(analysisResult.Pods, shard.Pods...)
+		analysisResult.PodIsolations = append(analysisResult.PodIsolations, shard.PodIsolations...)
+		analysisResult.AllowedRoutes = append(analysisResult.AllowedRoutes, shard.AllowedRoutes...)
+		if shard.Progress > analysisResult.Progress {
+			analysisResult.Progress = shard.Progress
+		}
+	}
+	return analysisResult
+}
+
+func (handler *handler) listNamespaceRoutes(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		http.Error(w, "namespace is required", http.StatusBadRequest)
+		return
+	}
+	filters, err := portFiltersOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	routeSort, err := routeSortOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	shard, ok := handler.shards[namespace]
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	result := namespaceRoutes{Namespace: namespace}
+	if ok && isNewerShard(shard, analysisResult) {
+		result.Partial = true
+		result.AllowedRoutes = shard.AllowedRoutes
+	} else {
+		result.AllowedRoutes = make([]*types.AllowedRoute, 0)
+		for _, allowedRoute := range analysisResult.AllowedRoutes {
+			if allowedRoute.TargetPod.Namespace == namespace {
+				result.AllowedRoutes = append(result.AllowedRoutes, allowedRoute)
+			}
+		}
+	}
+	result.AllowedRoutes = sortRoutes(filterRoutes(result.AllowedRoutes, filters), routeSort)
+	writeResponse(w, r, result)
+}
@@ -0,0 +1,152 @@
+package exposition
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListNamespaceRoutes(t *testing.T) {
+	analyzedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	scoredRoute := &types.AllowedRoute{SourcePod: types.PodRef{Name: "front", Namespace: "shop"},
+		TargetPod: types.PodRef{Name: "db", Namespace: "data"}, Ports: []int32{5432}, RiskScore: 3}
+	otherRoute := &types.AllowedRoute{SourcePod: types.PodRef{Name: "db", Namespace: "data"},
+		TargetPod: types.PodRef{Name: "front", Namespace: "shop"}}
+	shardRoute := &types.AllowedRoute{SourcePod: types.PodRef{Name: "api", Namespace: "shop"},
+		TargetPod: types.PodRef{Name: "db", Namespace: "data"}, Ports: []int32{5432}}
+	tests := []struct {
+		name               string
+		query              string
+		shards             []types.NamespaceRoutes
+		expectedStatusCode int
+		expectedRoutes     namespaceRoutes
+	}{
+		{
+			name:               "routes towards the namespace in the last analysis result",
+			query:              "?namespace=data",
+			expectedStatusCode: http.StatusOK,
+			expectedRoutes: namespaceRoutes{Namespace: "data",
+				AllowedRoutes: []*types.AllowedRoute{scoredRoute}},
+		},
+		{
+			name:  "routes of the shard of an analysis in progress",
+			query: "?namespace=data",
+			shards: []types.NamespaceRoutes{{Namespace: "data", AnalysisStartedAt: analyzedAt.Add(time.Minute),
+				AllowedRoutes: []*types.AllowedRoute{scoredRoute, shardRoute}}},
+			expectedStatusCode: http.StatusOK,
+			expectedRoutes: namespaceRoutes{Namespace: "data", Partial: true,
+				AllowedRoutes: []*types.AllowedRoute{scoredRoute, shardRoute}},
+		},
+		{
+			name:  "shards older than the last analysis result are ignored",
+			query: "?namespace=data&port=5432",
+			shards: []types.NamespaceRoutes{{Namespace: "data", AnalysisStartedAt: analyzedAt,
+				AllowedRoutes: []*types.AllowedRoute{shardRoute}}},
+			expectedStatusCode: http.StatusOK,
+			expectedRoutes: namespaceRoutes{Namespace: "data",
+				AllowedRoutes: []*types.AllowedRoute{scoredRoute}},
+		},
+		{
+			name:               "namespace is required",
+			query:              "",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			handler.lastAnalysisResult.AllowedRoutes = []*types.AllowedRoute{scoredRoute, otherRoute}
+			handler.lastAnalysisResult.Stats = &types.AnalysisStats{StartedAt: analyzedAt}
+			shardsChannel := make(chan types.NamespaceRoutes)
+			go handler.keepShardsUpdated(shardsChannel)
+			for _, shard := range tt.shards {
+				shardsChannel <- shard
+			}
+			// Sent once more, so that the previous shards were stored when it is received
+			shardsChannel <- types.NamespaceRoutes{Namespace: "other"}
+			w := httptest.NewRecorder()
+			handler.listNamespaceRoutes(w, httptest.NewRequest("GET", "/api/routes/namespace"+tt.query, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Fatalf("Status code mismatch (-want +got):\n%s", diff)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var routes namespaceRoutes
+			err := json.Unmarshal(w.Body.Bytes(), &routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedRoutes, routes); diff != "" {
+				t.Errorf("listNamespaceRoutes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDropOutdatedShards(t *testing.T) {
+	analyzedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.shards["old"] = types.NamespaceRoutes{Namespace: "old", AnalysisStartedAt: analyzedAt}
+	handler.shards["new"] = types.NamespaceRoutes{Namespace: "new", AnalysisStartedAt: analyzedAt.Add(time.Second)}
+	handler.lastAnalysisResult.Stats = &types.AnalysisStats{StartedAt: analyzedAt}
+	handler.dropOutdatedShards()
+	if _, ok := handler.shards["old"]; ok {
+		t.Errorf("dropOutdatedShards() kept the shard of the last analysis")
+	}
+	if _, ok := handler.shards["new"]; !ok {
+		t.Errorf("dropOutdatedShards() dropped the shard of the analysis in progress")
+	}
+}
+
+func TestPartialAnalysisResult(t *testing.T) {
+	startedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	front := &types.Pod{Name: "front", Namespace: "shop", Labels: map[string]string{}}
+	db := &types.Pod{Name: "db", Namespace: "data", Labels: map[string]string{}}
+	route := &types.AllowedRoute{SourcePod: types.PodRef{Name: "front", Namespace: "shop"},
+		TargetPod: types.PodRef{Name: "db", Namespace: "data"}}
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.shards["shop"] = types.NamespaceRoutes{Namespace: "shop", AnalysisStartedAt: startedAt,
+		Pods: []*types.Pod{front}, PodIsolations: []*types.PodIsolation{}, AllowedRoutes: []*types.AllowedRoute{},
+		Progress: 100}
+	handler.shards["data"] = types.NamespaceRoutes{Namespace: "data", AnalysisStartedAt: startedAt,
+		Pods: []*types.Pod{db}, PodIsolations: []*types.PodIsolation{}, AllowedRoutes: []*types.AllowedRoute{route},
+		Progress: 50}
+	serve := func() types.AnalysisResult {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/analysisResult", nil))
+		var analysisResult types.AnalysisResult
+		err := json.Unmarshal(w.Body.Bytes(), &analysisResult)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return analysisResult
+	}
+	analysisResult := serve()
+	if analysisResult.Complete || analysisResult.Progress != 100 {
+		t.Errorf("Partial result complete = %v with progress %d, expected false with progress 100",
+			analysisResult.Complete, analysisResult.Progress)
+	}
+	if diff := cmp.Diff([]*types.Pod{db, front}, analysisResult.Pods); diff != "" {
+		t.Errorf("Partial result pods mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*types.AllowedRoute{route}, analysisResult.AllowedRoutes); diff != "" {
+		t.Errorf("Partial result routes mismatch (-want +got):\n%s", diff)
+	}
+	resultsChannel := make(chan types.AnalysisResult)
+	go handler.keepUpdated(resultsChannel)
+	resultsChannel <- types.AnalysisResult{Pods: []*types.Pod{}, Complete: true, Progress: 100,
+		Stats: &types.AnalysisStats{StartedAt: startedAt}}
+	// Sent once more, so that the first result was stored when it is received
+	resultsChannel <- types.AnalysisResult{Pods: []*types.Pod{}, Complete: true, Progress: 100,
+		Stats: &types.AnalysisStats{StartedAt: startedAt}}
+	analysisResult = serve()
+	if !analysisResult.Complete || len(analysisResult.Pods) != 0 {
+		t.Errorf("Result = %+v, expected the complete result once published", analysisResult)
+	}
+}
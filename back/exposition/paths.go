@@ -0,0 +1,70 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/paths"
+	"karto/types"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultPathNamespace = "default"
+
+var pathKinds = map[string]string{
+	"deployment":  "Deployment",
+	"statefulset": "StatefulSet",
+	"daemonset":   "DaemonSet",
+	"replicaset":  "ReplicaSet",
+	"pod":         "Pod",
+}
+
+func (handler *handler) findPaths(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := workloadRefOf(query.Get("from"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid from query parameter: %s", err), http.StatusBadRequest)
+		return
+	}
+	to, err := workloadRefOf(query.Get("to"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid to query parameter: %s", err), http.StatusBadRequest)
+		return
+	}
+	maxHops := paths.DefaultMaxHops
+	if rawMaxHops := query.Get("maxHops"); rawMaxHops != "" {
+		maxHops, err = strconv.Atoi(rawMaxHops)
+		if err != nil || maxHops < 1 {
+			http.Error(w, "maxHops must be a strictly positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	result, err := paths.Find(r.Context(), analysisResult, from, to, maxHops)
+	if err != nil {
+		writeContextError(w, err)
+		return
+	}
+	writeResponse(w, r, result)
+}
+
+// Workloads are referenced as kind/name in the default namespace, or as namespace/kind/name
+func workloadRefOf(rawRef string) (types.ResourceRef, error) {
+	if rawRef == "" {
+		return types.ResourceRef{}, fmt.Errorf("a workload is required")
+	}
+	parts := strings.Split(rawRef, "/")
+	if len(parts) == 2 {
+		parts = append([]string{defaultPathNamespace}, parts...)
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return types.ResourceRef{}, fmt.Errorf("expected kind/name or namespace/kind/name, got %s", rawRef)
+	}
+	kind, ok := pathKinds[strings.ToLower(parts[1])]
+	if !ok {
+		return types.ResourceRef{}, fmt.Errorf("unsupported kind %s", parts[1])
+	}
+	return types.ResourceRef{Kind: kind, Name: parts[2], Namespace: parts[0]}, nil
+}
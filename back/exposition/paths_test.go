@@ -0,0 +1,52 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestWorkloadRefOf(t *testing.T) {
+	tests := []struct {
+		name          string
+		rawRef        string
+		expectedRef   types.ResourceRef
+		expectedError string
+	}{
+		{
+			name:        "a workload without namespace is in the default one",
+			rawRef:      "deployment/front",
+			expectedRef: types.ResourceRef{Kind: "Deployment", Name: "front", Namespace: "default"},
+		},
+		{
+			name:        "a workload can be referenced in its namespace",
+			rawRef:      "shop/StatefulSet/db",
+			expectedRef: types.ResourceRef{Kind: "StatefulSet", Name: "db", Namespace: "shop"},
+		},
+		{
+			name:          "an unsupported kind is rejected",
+			rawRef:        "service/front",
+			expectedError: "unsupported kind service",
+		},
+		{
+			name:          "a malformed reference is rejected",
+			rawRef:        "front",
+			expectedError: "expected kind/name or namespace/kind/name, got front",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := workloadRefOf(tt.rawRef)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("workloadRefOf() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRef, ref); diff != "" {
+				t.Errorf("workloadRefOf() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
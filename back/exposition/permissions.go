@@ -0,0 +1,46 @@
+package exposition
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// featureSet returns nil, granting every feature, when the features are not declared
+func featureSet(features []string) map[string]bool {
+	if features == nil {
+		return nil
+	}
+	result := make(map[string]bool)
+	for _, feature := range features {
+		result[feature] = true
+	}
+	return result
+}
+
+// featureGranted grants every feature to the requests without role, when no redaction is configured
+func featureGranted(r *http.Request, feature string) bool {
+	features, _ := r.Context().Value(featuresContextKey{}).(map[string]bool)
+	return features == nil || features[feature]
+}
+
+func requireFeature(feature string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !featureGranted(r, feature) {
+			http.Error(w, fmt.Sprintf("the %s feature is not granted to this token", feature), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireFeatureToModify lets every request read, only those granted the feature modify
+func requireFeatureToModify(feature string, next http.Handler) http.Handler {
+	restricted := requireFeature(feature, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		restricted.ServeHTTP(w, r)
+	})
+}
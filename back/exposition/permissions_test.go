@@ -0,0 +1,130 @@
+package exposition
+
+import (
+	"karto/config"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRequireFeature(t *testing.T) {
+	type args struct {
+		method string
+		path   string
+		token  string
+	}
+	redactionConfig := config.RedactionConfig{
+		Roles: []config.RedactionRole{
+			{Name: "admins", TokenEnv: "ADMIN_TOKEN"},
+			{Name: "developers", TokenEnv: "DEV_TOKEN", Features: []string{config.FeatureSimulate}},
+		},
+		DefaultFeatures: []string{},
+	}
+	tokens := map[string]string{"ADMIN_TOKEN": "admin-secret", "DEV_TOKEN": "dev-secret"}
+	apiRedactor, err := newRedactor(redactionConfig, func(key string) string { return tokens[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("/simulate", requireFeature(config.FeatureSimulate, ok))
+	mux.Handle("/exports", requireFeature(config.FeatureExports, ok))
+	mux.Handle("/suppressions", requireFeatureToModify(config.FeatureSuppressions, ok))
+	tests := []struct {
+		name               string
+		args               args
+		withoutRedaction   bool
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "grants the declared features of a role",
+			args:               args{method: "GET", path: "/simulate", token: "dev-secret"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "refuses the features not declared by a role",
+			args:               args{method: "GET", path: "/exports", token: "dev-secret"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedBody:       "the exports feature is not granted to this token\n",
+		},
+		{
+			name:               "grants every feature to roles without features",
+			args:               args{method: "GET", path: "/exports", token: "admin-secret"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "grants the default features to requests without token",
+			args:               args{method: "GET", path: "/simulate"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedBody:       "the simulate feature is not granted to this token\n",
+		},
+		{
+			name:               "lets requests read without the feature",
+			args:               args{method: "GET", path: "/suppressions", token: "dev-secret"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "refuses modifications without the feature",
+			args:               args{method: "POST", path: "/suppressions", token: "dev-secret"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedBody:       "the suppressions feature is not granted to this token\n",
+		},
+		{
+			name:               "grants every feature without redaction",
+			args:               args{method: "POST", path: "/suppressions"},
+			withoutRedaction:   true,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.args.method, tt.args.path, nil)
+			if tt.args.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.args.token)
+			}
+			w := httptest.NewRecorder()
+			if tt.withoutRedaction {
+				mux.ServeHTTP(w, request)
+			} else {
+				withRedaction(mux, apiRedactor).ServeHTTP(w, request)
+			}
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("Response status code = %d, expected %d", w.Code, tt.expectedStatusCode)
+			}
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Response body = %q, expected %q", w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestConfigurationImportRequiresSuppressions(t *testing.T) {
+	err := os.Setenv("KARTO_TEST_DEV_TOKEN", "dev-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("KARTO_TEST_DEV_TOKEN")
+	redactionConfig := config.RedactionConfig{
+		Roles: []config.RedactionRole{{Name: "developers", TokenEnv: "KARTO_TEST_DEV_TOKEN",
+			Features: []string{config.FeatureSimulate}}},
+		DefaultFeatures: []string{},
+	}
+	handler := Handler(make(chan types.AnalysisResult), Options{DisableFrontend: true, Redaction: &redactionConfig})
+	for _, method := range []string{"GET", "POST"} {
+		request := httptest.NewRequest(method, "/api/configuration", strings.NewReader("suppressions: []\n"))
+		request.Header.Set("Authorization", "Bearer dev-secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		expectedStatusCode := http.StatusOK
+		if method == "POST" {
+			expectedStatusCode = http.StatusForbidden
+		}
+		if w.Code != expectedStatusCode {
+			t.Errorf("%s response status code = %d, expected %d", method, w.Code, expectedStatusCode)
+		}
+	}
+}
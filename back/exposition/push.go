@@ -0,0 +1,90 @@
+package exposition
+
+import (
+	"errors"
+	"fmt"
+	"karto/broadcast"
+	"karto/types"
+	"log"
+	"net/http"
+)
+
+const (
+	eventsPath = "/api/events"
+	// pushQueueSize bounds the results queued for a push client, the oldest ones being dropped when it is slow
+	pushQueueSize = 2
+)
+
+// pushResults streams each new analysis result as a server-sent event, rendered with the suppressions and the query
+// parameters of the request as served by the API. Results queued while the client was busy reading are coalesced
+// into the latest one, so that the server never holds more than a few results per client.
+func (handler *handler) pushResults(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	view, err := resultViewOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscription := handler.pushHub.Subscribe(broadcast.SubscriberOptions{Name: "push client " + r.RemoteAddr,
+		BufferSize: pushQueueSize, DropPolicy: broadcast.DropOldest})
+	defer handler.pushHub.Unsubscribe(subscription)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	analyzed := handler.analyzed
+	handler.mutex.RUnlock()
+	if analyzed && handler.writeEvent(w, r, analysisResult, view) != nil {
+		return
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case analysisResult = <-subscription:
+			analysisResult = latestResult(analysisResult, subscription)
+			if handler.writeEvent(w, r, analysisResult, view) != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func latestResult(analysisResult types.AnalysisResult,
+	subscription <-chan types.AnalysisResult) types.AnalysisResult {
+	for {
+		select {
+		case analysisResult = <-subscription:
+		default:
+			return analysisResult
+		}
+	}
+}
+
+// writeEvent skips the results without any pod of the root of the view, which may show up in later results
+func (handler *handler) writeEvent(w http.ResponseWriter, r *http.Request, analysisResult types.AnalysisResult,
+	view resultView) error {
+	analysisResult, err := handler.render(analysisResult, view)
+	if errors.Is(err, errNotRooted) {
+		return nil
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	body, err := marshalResponse(r, analysisResult)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	// The body is written on a single line, ending with the newline added when marshalling it
+	_, err = fmt.Fprintf(w, "event: analysisResult\ndata: %s\n", body)
+	return err
+}
@@ -0,0 +1,119 @@
+package exposition
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushResults(t *testing.T) {
+	handler := newHandler(suppression.NewMemoryStore())
+	handler.lastAnalysisResult = types.AnalysisResult{Pods: []*types.Pod{{Name: "pod1", Namespace: "ns"}}}
+	handler.analyzed = true
+	server := httptest.NewServer(http.HandlerFunc(handler.pushResults))
+	defer server.Close()
+	response, err := http.Get(server.URL + "?omitEmpty=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if diff := cmp.Diff("text/event-stream", response.Header.Get("Content-Type")); diff != "" {
+		t.Errorf("Content type mismatch (-want +got):\n%s", diff)
+	}
+	reader := bufio.NewReader(response.Body)
+	readEvent := func() string {
+		event := ""
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return event
+			}
+			event += line
+		}
+	}
+	expectedEvent := "event: analysisResult\ndata: {\"pods\":[{\"name\":\"pod1\",\"namespace\":\"ns\",\"hostPID\":false," +
+		"\"hostIPC\":false,\"privileged\":false,\"zone\":\"\"}],\"capabilities\":{\"serverVersion\":\"\"," +
+		"\"sctp\":false,\"endPort\":false,\"adminNetworkPolicy\":false,\"calicoPolicies\":false," +
+		"\"ciliumPolicies\":false},\"complete\":false,\"progress\":0}\n"
+	if diff := cmp.Diff(expectedEvent, readEvent()); diff != "" {
+		t.Errorf("Initial event mismatch (-want +got):\n%s", diff)
+	}
+	handler.pushHub.Publish(types.AnalysisResult{Complete: true, Progress: 100})
+	if event := readEvent(); !strings.Contains(event, "\"complete\":true") {
+		t.Errorf("Event = %s, expected the published result", event)
+	}
+}
+
+func TestPushResultsRendersLikeTheAPI(t *testing.T) {
+	suppressionStore := suppression.NewMemoryStore()
+	err := suppressionStore.Save(&types.FindingSuppression{Fingerprint: "fp", Reason: "accepted risk",
+		ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newHandler(suppressionStore)
+	server := httptest.NewServer(http.HandlerFunc(handler.pushResults))
+	defer server.Close()
+	response, err := http.Get(server.URL + "?port=80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	handler.pushHub.Publish(types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "a", Namespace: "ns"}, TargetPod: types.PodRef{Name: "b", Namespace: "ns"},
+				Ports: []int32{80}},
+			{SourcePod: types.PodRef{Name: "a", Namespace: "ns"}, TargetPod: types.PodRef{Name: "c", Namespace: "ns"},
+				Ports: []int32{443}},
+		},
+		Findings: []*types.Finding{{Fingerprint: "fp"}},
+		Complete: true,
+	})
+	reader := bufio.NewReader(response.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var analysisResult types.AnalysisResult
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &analysisResult); err != nil {
+			t.Fatal(err)
+		}
+		if len(analysisResult.AllowedRoutes) != 1 || analysisResult.AllowedRoutes[0].TargetPod.Name != "b" {
+			t.Errorf("Pushed routes = %+v, expected those filtered by the port of the request",
+				analysisResult.AllowedRoutes)
+		}
+		if analysisResult.Findings[0].Suppression == nil {
+			t.Errorf("Pushed finding = %+v, expected it to be suppressed", analysisResult.Findings[0])
+		}
+		return
+	}
+}
+
+func TestLatestResult(t *testing.T) {
+	subscription := make(chan types.AnalysisResult, pushQueueSize)
+	subscription <- types.AnalysisResult{Progress: 50}
+	subscription <- types.AnalysisResult{Progress: 100}
+	analysisResult := latestResult(types.AnalysisResult{Progress: 0}, subscription)
+	if analysisResult.Progress != 100 || len(subscription) != 0 {
+		t.Errorf("latestResult() = %+v with %d results left, expected the last queued result",
+			analysisResult, len(subscription))
+	}
+}
@@ -0,0 +1,112 @@
+package exposition
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/time/rate"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	clientLimiterIdleTimeout = 5 * time.Minute
+	bearerPrefix             = "Bearer "
+)
+
+type RateLimitOptions struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type rateLimiter struct {
+	options     RateLimitOptions
+	mutex       sync.Mutex
+	clients     map[string]*clientLimiter
+	lastCleanup time.Time
+}
+
+func newRateLimiter(options RateLimitOptions) *rateLimiter {
+	return &rateLimiter{
+		options:     options,
+		clients:     make(map[string]*clientLimiter),
+		lastCleanup: time.Now(),
+	}
+}
+
+func (rateLimiter *rateLimiter) limit(next http.Handler) http.Handler {
+	if rateLimiter.options.RequestsPerSecond <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rateLimiter.allow(rateLimiter.clientKey(r)) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rateLimiter *rateLimiter) allow(clientKey string) bool {
+	rateLimiter.mutex.Lock()
+	defer rateLimiter.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(rateLimiter.lastCleanup) > clientLimiterIdleTimeout {
+		for key, client := range rateLimiter.clients {
+			if now.Sub(client.lastSeen) > clientLimiterIdleTimeout {
+				delete(rateLimiter.clients, key)
+			}
+		}
+		rateLimiter.lastCleanup = now
+	}
+	client, ok := rateLimiter.clients[clientKey]
+	if !ok {
+		burst := rateLimiter.options.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		client = &clientLimiter{
+			limiter: rate.NewLimiter(rate.Limit(rateLimiter.options.RequestsPerSecond), burst),
+		}
+		rateLimiter.clients[clientKey] = client
+	}
+	client.lastSeen = now
+	return client.limiter.AllowN(now, 1)
+}
+
+// Clients bearing the token of a redaction role are limited by token, as they may share an IP behind a proxy. Other
+// tokens are ignored, so that rotating made-up tokens neither escapes the limit nor grows the clients without bound.
+func (rateLimiter *rateLimiter) clientKey(r *http.Request) string {
+	if role, _ := r.Context().Value(roleContextKey{}).(string); role != "" {
+		if tokenHash, ok := bearerTokenHash(r); ok {
+			return "token:" + tokenHash
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Tokens are only kept hashed in memory
+func bearerTokenHash(r *http.Request) (string, bool) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return "", false
+	}
+	return hashToken(strings.TrimPrefix(authorization, bearerPrefix)), true
+}
+
+func hashToken(token string) string {
+	tokenHash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(tokenHash[:])
+}
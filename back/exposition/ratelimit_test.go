@@ -0,0 +1,101 @@
+package exposition
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type request struct {
+		remoteAddr    string
+		authorization string
+		// role is the redaction role the token was resolved to, if any
+		role string
+	}
+	tests := []struct {
+		name                string
+		options             RateLimitOptions
+		requests            []request
+		expectedStatusCodes []int
+	}{
+		{
+			name:    "requests are not limited when rate limiting is disabled",
+			options: RateLimitOptions{RequestsPerSecond: 0, Burst: 1},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"},
+			},
+			expectedStatusCodes: []int{200, 200, 200},
+		},
+		{
+			name:    "requests above the burst of a same IP are rejected",
+			options: RateLimitOptions{RequestsPerSecond: 0.001, Burst: 2},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:5678"}, {remoteAddr: "10.0.0.1:1234"},
+				{remoteAddr: "10.0.0.2:1234"},
+			},
+			expectedStatusCodes: []int{200, 200, 429, 200},
+		},
+		{
+			name:    "clients with the token of a role are limited by token rather than by IP",
+			options: RateLimitOptions{RequestsPerSecond: 0.001, Burst: 1},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer token1", role: "admins"},
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer token2", role: "viewers"},
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer token1", role: "admins"},
+			},
+			expectedStatusCodes: []int{200, 200, 429},
+		},
+		{
+			name:    "clients rotating unknown tokens are limited by IP",
+			options: RateLimitOptions{RequestsPerSecond: 0.001, Burst: 1},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer made-up1"},
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer made-up2"},
+				{remoteAddr: "10.0.0.1:1234", authorization: "Bearer made-up3"},
+			},
+			expectedStatusCodes: []int{200, 429, 429},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newRateLimiter(tt.options).limit(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			statusCodes := make([]int, 0)
+			for _, request := range tt.requests {
+				r := httptest.NewRequest("GET", "/api/analysisResult", nil)
+				r.RemoteAddr = request.remoteAddr
+				if request.authorization != "" {
+					r.Header.Set("Authorization", request.authorization)
+				}
+				if request.role != "" {
+					r = r.WithContext(context.WithValue(r.Context(), roleContextKey{}, request.role))
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				statusCodes = append(statusCodes, w.Code)
+			}
+			if diff := cmp.Diff(tt.expectedStatusCodes, statusCodes); diff != "" {
+				t.Errorf("status codes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRateLimiterKeepsOneClientForUnknownTokens(t *testing.T) {
+	rateLimiter := newRateLimiter(RateLimitOptions{RequestsPerSecond: 1, Burst: 1})
+	handler := rateLimiter.limit(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	for i := 0; i < 100; i++ {
+		r := httptest.NewRequest("GET", "/api/analysisResult", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer made-up%d", i))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if len(rateLimiter.clients) != 1 {
+		t.Errorf("rate limiter tracks %d clients, expected a single one for the IP", len(rateLimiter.clients))
+	}
+}
@@ -0,0 +1,51 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/reachers"
+	"karto/types"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (handler *handler) findReachers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pod, err := podRefOf(query.Get("pod"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid pod query parameter: %s", err), http.StatusBadRequest)
+		return
+	}
+	maxHops := reachers.DefaultMaxHops
+	if rawMaxHops := query.Get("maxHops"); rawMaxHops != "" {
+		maxHops, err = strconv.Atoi(rawMaxHops)
+		if err != nil || maxHops < 1 {
+			http.Error(w, "maxHops must be a strictly positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	handler.mutex.RUnlock()
+	result, err := reachers.Find(r.Context(), analysisResult, pod, maxHops)
+	if err != nil {
+		writeContextError(w, err)
+		return
+	}
+	writeResponse(w, r, result)
+}
+
+// Pods are referenced by name in the default namespace, or as namespace/name
+func podRefOf(rawRef string) (types.PodRef, error) {
+	if rawRef == "" {
+		return types.PodRef{}, fmt.Errorf("a pod is required")
+	}
+	parts := strings.Split(rawRef, "/")
+	if len(parts) == 1 {
+		parts = append([]string{defaultPathNamespace}, parts...)
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.PodRef{}, fmt.Errorf("expected name or namespace/name, got %s", rawRef)
+	}
+	return types.PodRef{Name: parts[1], Namespace: parts[0]}, nil
+}
@@ -0,0 +1,52 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestPodRefOf(t *testing.T) {
+	tests := []struct {
+		name          string
+		rawRef        string
+		expectedRef   types.PodRef
+		expectedError string
+	}{
+		{
+			name:        "a pod without namespace is in the default one",
+			rawRef:      "front",
+			expectedRef: types.PodRef{Name: "front", Namespace: "default"},
+		},
+		{
+			name:        "a pod can be referenced in its namespace",
+			rawRef:      "shop/db-0",
+			expectedRef: types.PodRef{Name: "db-0", Namespace: "shop"},
+		},
+		{
+			name:          "a missing pod is rejected",
+			rawRef:        "",
+			expectedError: "a pod is required",
+		},
+		{
+			name:          "a malformed reference is rejected",
+			rawRef:        "shop/pod/db-0",
+			expectedError: "expected name or namespace/name, got shop/pod/db-0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := podRefOf(tt.rawRef)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("podRefOf() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRef, ref); diff != "" {
+				t.Errorf("podRefOf() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
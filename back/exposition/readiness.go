@@ -0,0 +1,38 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/preflight"
+	"karto/types"
+	"net/http"
+	"strings"
+)
+
+// readinessCheck only reports the instance ready once its service account holds the permissions of the enabled
+// features, detailing the missing ones otherwise
+func readinessCheck(preflightReport *preflight.Report) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if preflightReport == nil || len(preflightReport.Missing) == 0 {
+			healthCheck(w, nil)
+			return
+		}
+		lines := []string{"missing permissions:"}
+		for _, permission := range preflightReport.Missing {
+			lines = append(lines, fmt.Sprintf("- cannot %s %s, required by feature %s", permission.Verb,
+				preflight.ResourceOf(permission), permission.Feature))
+		}
+		http.Error(w, strings.Join(lines, "\n"), http.StatusServiceUnavailable)
+	}
+}
+
+// withPreflightFindings adds the missing permissions to the findings of the analysis, without modifying the result
+// shared with the other consumers
+func (handler *handler) withPreflightFindings(analysisResult types.AnalysisResult) types.AnalysisResult {
+	if len(handler.preflightFindings) == 0 {
+		return analysisResult
+	}
+	findings := make([]*types.Finding, 0, len(handler.preflightFindings)+len(analysisResult.Findings))
+	findings = append(findings, handler.preflightFindings...)
+	analysisResult.Findings = append(findings, analysisResult.Findings...)
+	return analysisResult
+}
@@ -0,0 +1,70 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/preflight"
+	"karto/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	tests := []struct {
+		name               string
+		preflightReport    *preflight.Report
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "an instance whose permissions were not checked is ready",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "OK\n",
+		},
+		{
+			name:               "an instance holding every permission is ready",
+			preflightReport:    &preflight.Report{Missing: []preflight.Permission{}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "OK\n",
+		},
+		{
+			name: "an instance missing permissions is not ready and lists them",
+			preflightReport: &preflight.Report{Missing: []preflight.Permission{
+				{Feature: preflight.FeatureAnalysis, Verb: "watch", Group: "networking.k8s.io",
+					Resource: "networkpolicies"},
+				{Feature: preflight.FeatureVerification, Verb: "create", Resource: "pods"},
+			}},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody: "missing permissions:\n" +
+				"- cannot watch networkpolicies.networking.k8s.io, required by feature analysis\n" +
+				"- cannot create pods, required by feature verify\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			readinessCheck(tt.preflightReport).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithPreflightFindings(t *testing.T) {
+	preflightFinding := &types.Finding{Rule: "missing-permission"}
+	analysisFinding := &types.Finding{Rule: "unused-network-policy"}
+	analysisFindings := make([]*types.Finding, 1, 2)
+	analysisFindings[0] = analysisFinding
+	handler := &handler{preflightFindings: []*types.Finding{preflightFinding}}
+	analysisResult := handler.withPreflightFindings(types.AnalysisResult{Findings: analysisFindings})
+	if diff := cmp.Diff([]*types.Finding{preflightFinding, analysisFinding}, analysisResult.Findings); diff != "" {
+		t.Errorf("withPreflightFindings() result mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*types.Finding{analysisFinding}, analysisFindings); diff != "" {
+		t.Errorf("withPreflightFindings() modified the findings of the analysis (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,183 @@
+package exposition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"karto/config"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "redacted"
+
+type redactionProfile struct {
+	labels      []*regexp.Regexp
+	annotations bool
+	images      bool
+}
+
+// redactor resolves the role of each request into its redaction profile and the features granted to it
+type redactor struct {
+	profilesByToken map[string]*redactionProfile
+	defaultProfile  *redactionProfile
+	featuresByToken map[string]map[string]bool
+	defaultFeatures map[string]bool
+	rolesByToken    map[string]string
+}
+
+type redactionContextKey struct{}
+
+type featuresContextKey struct{}
+
+type roleContextKey struct{}
+
+// The tokens of the roles are read from the environment, so that the configuration file holds no secret
+func newRedactor(redactionConfig config.RedactionConfig, getenv func(key string) string) (*redactor, error) {
+	profiles := make(map[string]*redactionProfile)
+	for _, profileConfig := range redactionConfig.Profiles {
+		profile := &redactionProfile{
+			annotations: profileConfig.Annotations,
+			images:      profileConfig.Images,
+		}
+		for _, pattern := range profileConfig.Labels {
+			profile.labels = append(profile.labels, config.LabelPattern(pattern))
+		}
+		profiles[profileConfig.Name] = profile
+	}
+	profilesByToken := make(map[string]*redactionProfile)
+	featuresByToken := make(map[string]map[string]bool)
+	rolesByToken := make(map[string]string)
+	for _, role := range redactionConfig.Roles {
+		token := getenv(role.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("the token of the redaction role %s is not set in %s", role.Name, role.TokenEnv)
+		}
+		// A role without profile is mapped to a nil profile, which sees full data
+		profilesByToken[hashToken(token)] = profiles[role.Profile]
+		featuresByToken[hashToken(token)] = featureSet(role.Features)
+		rolesByToken[hashToken(token)] = role.Name
+	}
+	return &redactor{
+		profilesByToken: profilesByToken,
+		defaultProfile:  profiles[redactionConfig.DefaultProfile],
+		featuresByToken: featuresByToken,
+		defaultFeatures: featureSet(redactionConfig.DefaultFeatures),
+		rolesByToken:    rolesByToken,
+	}, nil
+}
+
+func withRedaction(next http.Handler, redactor *redactor) http.Handler {
+	if redactor == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile := redactor.defaultProfile
+		features := redactor.defaultFeatures
+		role := ""
+		if tokenHash, ok := bearerTokenHash(r); ok {
+			if roleProfile, known := redactor.profilesByToken[tokenHash]; known {
+				profile = roleProfile
+				features = redactor.featuresByToken[tokenHash]
+				role = redactor.rolesByToken[tokenHash]
+			}
+		}
+		ctx := context.WithValue(r.Context(), redactionContextKey{}, profile)
+		ctx = context.WithValue(ctx, featuresContextKey{}, features)
+		ctx = context.WithValue(ctx, roleContextKey{}, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// redactionOf returns nil when the request sees full data
+func redactionOf(r *http.Request) *redactionProfile {
+	profile, _ := r.Context().Value(redactionContextKey{}).(*redactionProfile)
+	return profile
+}
+
+// Labels are found in any member named labels or ending with Labels, such as the podLabels of intents or the
+// matchLabels of selectors, and image names in any member named image
+func (profile *redactionProfile) redact(value json.RawMessage) (json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+		return value, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	openingToken, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	isObject := openingToken == json.Delim('{')
+	var buffer bytes.Buffer
+	buffer.WriteByte(value[0])
+	written := 0
+	for decoder.More() {
+		key := ""
+		if isObject {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key = keyToken.(string)
+		}
+		var member json.RawMessage
+		err = decoder.Decode(&member)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case isObject && profile.annotations && key == "annotations":
+			continue
+		case isObject && profile.images && key == "image" && len(member) > 0 && member[0] == '"':
+			member = json.RawMessage(`"` + redactedValue + `"`)
+		case isObject && (key == "labels" || strings.HasSuffix(key, "Labels")) && len(member) > 0 && member[0] == '{':
+			member, err = profile.redactLabels(member)
+		default:
+			member, err = profile.redact(member)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if written > 0 {
+			buffer.WriteByte(',')
+		}
+		if isObject {
+			keyJSON, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(keyJSON)
+			buffer.WriteByte(':')
+		}
+		buffer.Write(member)
+		written++
+	}
+	if isObject {
+		buffer.WriteByte('}')
+	} else {
+		buffer.WriteByte(']')
+	}
+	return buffer.Bytes(), nil
+}
+
+func (profile *redactionProfile) redactLabels(value json.RawMessage) (json.RawMessage, error) {
+	if len(profile.labels) == 0 {
+		return value, nil
+	}
+	var labels map[string]json.RawMessage
+	err := json.Unmarshal(value, &labels)
+	if err != nil {
+		return nil, err
+	}
+	for key := range labels {
+		for _, pattern := range profile.labels {
+			if pattern.MatchString(key) {
+				delete(labels, key)
+				break
+			}
+		}
+	}
+	return json.Marshal(labels)
+}
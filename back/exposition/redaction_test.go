@@ -0,0 +1,120 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRedaction(t *testing.T) {
+	type args struct {
+		path  string
+		token string
+	}
+	value := map[string]interface{}{
+		"pods": []map[string]interface{}{{"name": "pod1",
+			"labels": map[string]string{"app": "front", "cost.example.com/center": "42"}}},
+		"manifest": map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]string{"note": "internal"}},
+			"spec": map[string]interface{}{
+				"podSelector": map[string]interface{}{"matchLabels": map[string]string{"owner": "alice"}},
+				"containers":  []map[string]string{{"image": "registry.example.com/front:1.2"}},
+			},
+		},
+	}
+	redactionConfig := config.RedactionConfig{
+		Profiles: []config.RedactionProfile{
+			{Name: "public", Labels: []string{"*.example.com/*", "owner"}, Annotations: true, Images: true},
+			{Name: "internal", Labels: []string{"owner"}},
+		},
+		Roles: []config.RedactionRole{
+			{Name: "admins", TokenEnv: "ADMIN_TOKEN"},
+			{Name: "developers", TokenEnv: "DEV_TOKEN", Profile: "internal"},
+		},
+		DefaultProfile: "public",
+	}
+	tokens := map[string]string{"ADMIN_TOKEN": "admin-secret", "DEV_TOKEN": "dev-secret"}
+	apiRedactor, err := newRedactor(redactionConfig, func(key string) string { return tokens[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/value", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, r, value)
+	})
+	mux.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		writeNDJSON(w, r, value["pods"])
+	})
+	mux.HandleFunc("/overlays", newHandler(nil).remediationOverlays)
+	tests := []struct {
+		name               string
+		args               args
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "redacts requests without token with the default profile",
+			args:               args{path: "/value"},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "{\"manifest\":{\"metadata\":{},\"spec\":{\"containers\":[{\"image\":\"redacted\"}]," +
+				"\"podSelector\":{\"matchLabels\":{}}}},\"pods\":[{\"labels\":{\"app\":\"front\"},\"name\":\"pod1\"}]}\n",
+		},
+		{
+			name:               "redacts requests of a role with its profile",
+			args:               args{path: "/value", token: "dev-secret"},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "{\"manifest\":{\"metadata\":{\"annotations\":{\"note\":\"internal\"}}," +
+				"\"spec\":{\"containers\":[{\"image\":\"registry.example.com/front:1.2\"}]," +
+				"\"podSelector\":{\"matchLabels\":{}}}},\"pods\":[{\"labels\":{\"app\":\"front\"," +
+				"\"cost.example.com/center\":\"42\"},\"name\":\"pod1\"}]}\n",
+		},
+		{
+			name:               "serves full data to roles without profile",
+			args:               args{path: "/value", token: "admin-secret"},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "{\"manifest\":{\"metadata\":{\"annotations\":{\"note\":\"internal\"}}," +
+				"\"spec\":{\"containers\":[{\"image\":\"registry.example.com/front:1.2\"}]," +
+				"\"podSelector\":{\"matchLabels\":{\"owner\":\"alice\"}}}},\"pods\":[{\"labels\":{\"app\":\"front\"," +
+				"\"cost.example.com/center\":\"42\"},\"name\":\"pod1\"}]}\n",
+		},
+		{
+			name:               "redacts unknown tokens with the default profile",
+			args:               args{path: "/export", token: "guessed"},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "{\"labels\":{\"app\":\"front\"},\"name\":\"pod1\"}\n",
+		},
+		{
+			name:               "refuses remediation overlays to redacted requests",
+			args:               args{path: "/overlays"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedBody:       "remediation overlays are not available with a redaction profile\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", tt.args.path, nil)
+			if tt.args.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.args.token)
+			}
+			w := httptest.NewRecorder()
+			withRedaction(mux, apiRedactor).ServeHTTP(w, request)
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("Response status code = %d, expected %d", w.Code, tt.expectedStatusCode)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewRedactorWithoutToken(t *testing.T) {
+	redactionConfig := config.RedactionConfig{Roles: []config.RedactionRole{{Name: "admins", TokenEnv: "ADMIN_TOKEN"}}}
+	_, err := newRedactor(redactionConfig, func(string) string { return "" })
+	expectedError := "the token of the redaction role admins is not set in ADMIN_TOKEN"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("newRedactor() error = %v, expected %s", err, expectedError)
+	}
+}
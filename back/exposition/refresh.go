@@ -0,0 +1,20 @@
+package exposition
+
+import "net/http"
+
+const refreshPath = "/api/refresh"
+
+// requestRefresh asks for an analysis of the latest cluster state. Requests made while one is already pending are
+// merged into it.
+func (handler *handler) requestRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	select {
+	case handler.refreshes <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,56 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestRefresh(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		pendingRefresh     bool
+		expectedStatusCode int
+		expectedRefreshes  int
+	}{
+		{
+			name:               "a refresh is requested",
+			method:             "POST",
+			expectedStatusCode: 202,
+			expectedRefreshes:  1,
+		},
+		{
+			name:               "a refresh is merged into the pending one",
+			method:             "POST",
+			pendingRefresh:     true,
+			expectedStatusCode: 202,
+			expectedRefreshes:  1,
+		},
+		{
+			name:               "a refresh must be posted",
+			method:             "GET",
+			expectedStatusCode: 405,
+			expectedRefreshes:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			refreshes := make(chan struct{}, 1)
+			if tt.pendingRefresh {
+				refreshes <- struct{}{}
+			}
+			handler.refreshes = refreshes
+			w := httptest.NewRecorder()
+			handler.requestRefresh(w, httptest.NewRequest(tt.method, refreshPath, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRefreshes, len(refreshes)); diff != "" {
+				t.Errorf("Pending refreshes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
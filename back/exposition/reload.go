@@ -0,0 +1,56 @@
+package exposition
+
+import (
+	"karto/config"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// reloadableRedaction redacts the requests with the redactor of the last configuration, none meaning full data
+type reloadableRedaction struct {
+	mutex    sync.RWMutex
+	redactor *redactor
+}
+
+func (redaction *reloadableRedaction) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redaction.mutex.RLock()
+		apiRedactor := redaction.redactor
+		redaction.mutex.RUnlock()
+		withRedaction(next, apiRedactor).ServeHTTP(w, r)
+	})
+}
+
+func (handler *handler) keepConfigured(configurations <-chan config.Config, redaction *reloadableRedaction) {
+	for configuration := range configurations {
+		err := handler.configure(configuration, redaction, os.Getenv)
+		if err != nil {
+			log.Printf("Unable to reload the configuration of the API, keeping the running one: %s\n", err)
+		}
+	}
+}
+
+// configure applies the rules, intents, boundaries and redaction of a reloaded configuration. Nothing is applied
+// when the token of a redaction role is not set, so that the API never falls back to full data.
+func (handler *handler) configure(configuration config.Config, redaction *reloadableRedaction,
+	getenv func(key string) string) error {
+	var apiRedactor *redactor
+	if configuration.Redaction != nil {
+		var err error
+		apiRedactor, err = newRedactor(*configuration.Redaction, getenv)
+		if err != nil {
+			return err
+		}
+	}
+	handler.mutex.Lock()
+	handler.rules = configuration.Rules
+	handler.intents = configuration.Intents
+	handler.boundaries = configuration.Boundaries
+	handler.mutex.Unlock()
+	redaction.mutex.Lock()
+	redaction.redactor = apiRedactor
+	redaction.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/suppression"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("/exports", requireFeature(config.FeatureExports, ok))
+	exportStatus := func(redaction *reloadableRedaction) int {
+		w := httptest.NewRecorder()
+		redaction.wrap(mux).ServeHTTP(w, httptest.NewRequest("GET", "/exports", nil))
+		return w.Code
+	}
+	handler := newHandler(suppression.NewMemoryStore())
+	redaction := &reloadableRedaction{}
+	tokens := map[string]string{"ADMIN_TOKEN": "admin-secret"}
+	getenv := func(key string) string { return tokens[key] }
+	restricted := config.Config{
+		Boundaries: []config.Boundary{{Name: "pci", Members: []config.PodSelector{{Namespace: "payments"}}}},
+		Redaction: &config.RedactionConfig{
+			Roles:           []config.RedactionRole{{Name: "admins", TokenEnv: "ADMIN_TOKEN"}},
+			DefaultFeatures: []string{},
+		},
+	}
+	if status := exportStatus(redaction); status != http.StatusOK {
+		t.Errorf("exports status before reload = %d, expected %d", status, http.StatusOK)
+	}
+	if err := handler.configure(restricted, redaction, getenv); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(restricted.Boundaries, handler.boundaries); diff != "" {
+		t.Errorf("configure() boundaries mismatch (-want +got):\n%s", diff)
+	}
+	if status := exportStatus(redaction); status != http.StatusForbidden {
+		t.Errorf("exports status after reload = %d, expected %d", status, http.StatusForbidden)
+	}
+	missingToken := config.Config{Redaction: &config.RedactionConfig{
+		Roles: []config.RedactionRole{{Name: "auditors", TokenEnv: "AUDIT_TOKEN"}},
+	}}
+	if err := handler.configure(missingToken, redaction, getenv); err == nil {
+		t.Errorf("configure() expected an error for the missing token")
+	}
+	if diff := cmp.Diff(restricted.Boundaries, handler.boundaries); diff != "" {
+		t.Errorf("configure() boundaries after a failed reload mismatch (-want +got):\n%s", diff)
+	}
+	if status := exportStatus(redaction); status != http.StatusForbidden {
+		t.Errorf("exports status after a failed reload = %d, expected %d", status, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,46 @@
+package exposition
+
+import (
+	"bytes"
+	"karto/remediation"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"net/http"
+)
+
+func (handler *handler) remediationOverlays(w http.ResponseWriter, r *http.Request) {
+	// Overlays must carry the real selectors to be applied, so they cannot be redacted
+	if redactionOf(r) != nil {
+		http.Error(w, "remediation overlays are not available with a redaction profile", http.StatusForbidden)
+		return
+	}
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	handler.mutex.RLock()
+	findings := make([]*types.Finding, 0)
+	for _, finding := range handler.lastAnalysisResult.Findings {
+		if namespace == "" || (finding.Remediation != nil && finding.Remediation.Resource.Namespace == namespace) {
+			findings = append(findings, finding)
+		}
+	}
+	handler.mutex.RUnlock()
+	var body bytes.Buffer
+	err = remediation.WriteOverlays(&body, suppression.Apply(findings, suppressions))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"karto-remediations.tar.gz\"")
+	_, err = w.Write(body.Bytes())
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,85 @@
+package exposition
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeYAML = "application/yaml"
+)
+
+var yamlMediaTypes = map[string]bool{
+	contentTypeYAML:      true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+	"text/x-yaml":        true,
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, value interface{}) {
+	writeResponseWithStatus(w, r, http.StatusOK, value)
+}
+
+func writeResponseWithStatus(w http.ResponseWriter, r *http.Request, statusCode int, value interface{}) {
+	body, err := marshalResponse(r, value)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	contentType := contentTypeJSON
+	if acceptsYAML(r) {
+		contentType = contentTypeYAML
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func marshalResponse(r *http.Request, value interface{}) ([]byte, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if profile := redactionOf(r); profile != nil {
+		body, err = profile.redact(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if encoding := encodingOf(r); !encoding.isDefault() {
+		body, err = encoding.compact(body, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if acceptsYAML(r) {
+		// Field names are the JSON ones, so that both representations stay interchangeable
+		return yaml.JSONToYAML(body)
+	}
+	return append(body, '\n'), nil
+}
+
+func acceptsYAML(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if mediaType == contentTypeJSON {
+			return false
+		}
+		if yamlMediaTypes[mediaType] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteResponse(t *testing.T) {
+	value := types.PodRef{Name: "pod1", Namespace: "ns"}
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "writes JSON by default",
+			accept:              "",
+			expectedContentType: "application/json",
+			expectedBody:        "{\"name\":\"pod1\",\"namespace\":\"ns\"}\n",
+		},
+		{
+			name:                "writes YAML when requested",
+			accept:              "application/yaml",
+			expectedContentType: "application/yaml",
+			expectedBody:        "name: pod1\nnamespace: ns\n",
+		},
+		{
+			name:                "accepts alternative YAML media types with parameters",
+			accept:              "text/html, text/x-yaml; charset=utf-8",
+			expectedContentType: "application/yaml",
+			expectedBody:        "name: pod1\nnamespace: ns\n",
+		},
+		{
+			name:                "prefers JSON when listed first",
+			accept:              "application/json, application/yaml",
+			expectedContentType: "application/json",
+			expectedBody:        "{\"name\":\"pod1\",\"namespace\":\"ns\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/api/analysisResult", nil)
+			request.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			writeResponse(w, request, value)
+			if diff := cmp.Diff(tt.expectedContentType, w.Header().Get("Content-Type")); diff != "" {
+				t.Errorf("Content-Type header mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedBody, w.Body.String()); diff != "" {
+				t.Errorf("Response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/drift"
+	"karto/paths"
+	"karto/types"
+	"net/http"
+	"strconv"
+)
+
+const (
+	directionOutbound = "outbound"
+	directionInbound  = "inbound"
+	directionBoth     = "both"
+)
+
+type rootFilter struct {
+	root      types.ResourceRef
+	direction string
+	maxHops   int
+}
+
+// rootFilterOf returns nil when the result is not rooted at a workload
+func rootFilterOf(r *http.Request) (*rootFilter, error) {
+	query := r.URL.Query()
+	rawRoot := query.Get("rootedAt")
+	if rawRoot == "" {
+		return nil, nil
+	}
+	root, err := workloadRefOf(rawRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rootedAt query parameter: %s", err)
+	}
+	filter := &rootFilter{root: root, direction: directionOutbound, maxHops: paths.DefaultMaxHops}
+	if direction := query.Get("direction"); direction != "" {
+		if direction != directionOutbound && direction != directionInbound && direction != directionBoth {
+			return nil, fmt.Errorf("invalid direction %s: expected %s, %s or %s", direction, directionOutbound,
+				directionInbound, directionBoth)
+		}
+		filter.direction = direction
+	}
+	if rawMaxHops := query.Get("maxHops"); rawMaxHops != "" {
+		filter.maxHops, err = strconv.Atoi(rawMaxHops)
+		if err != nil || filter.maxHops < 1 {
+			return nil, fmt.Errorf("maxHops must be a strictly positive integer")
+		}
+	}
+	return filter, nil
+}
+
+// prune keeps the routes taken within maxHops from the pods of the root (outbound) or towards them (inbound), along
+// with the pods they connect. The other sections of the result are left whole. It returns false when the root has no
+// analyzed pod.
+func (filter rootFilter) prune(analysisResult types.AnalysisResult) (types.AnalysisResult, bool) {
+	roots := filter.rootPods(analysisResult)
+	if len(roots) == 0 {
+		return analysisResult, false
+	}
+	kept := make(map[*types.AllowedRoute]bool)
+	visited := make(map[types.PodRef]bool)
+	for podRef := range roots {
+		visited[podRef] = true
+	}
+	if filter.direction != directionInbound {
+		filter.walk(analysisResult.AllowedRoutes, roots, visited, kept, outboundEnds)
+	}
+	if filter.direction != directionOutbound {
+		filter.walk(analysisResult.AllowedRoutes, roots, visited, kept, inboundEnds)
+	}
+	pruned := analysisResult
+	pruned.AllowedRoutes = make([]*types.AllowedRoute, 0)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if kept[allowedRoute] {
+			pruned.AllowedRoutes = append(pruned.AllowedRoutes, allowedRoute)
+		}
+	}
+	pruned.Pods = make([]*types.Pod, 0)
+	for _, pod := range analysisResult.Pods {
+		if visited[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] {
+			pruned.Pods = append(pruned.Pods, pod)
+		}
+	}
+	pruned.PodIsolations = make([]*types.PodIsolation, 0)
+	for _, podIsolation := range analysisResult.PodIsolations {
+		if visited[podIsolation.Pod] {
+			pruned.PodIsolations = append(pruned.PodIsolations, podIsolation)
+		}
+	}
+	return pruned, true
+}
+
+func outboundEnds(allowedRoute *types.AllowedRoute) (types.PodRef, types.PodRef) {
+	return allowedRoute.SourcePod, allowedRoute.TargetPod
+}
+
+func inboundEnds(allowedRoute *types.AllowedRoute) (types.PodRef, types.PodRef) {
+	return allowedRoute.TargetPod, allowedRoute.SourcePod
+}
+
+// walk follows the routes breadth first, from their end given first by ends to the other one
+func (filter rootFilter) walk(allowedRoutes []*types.AllowedRoute, roots map[types.PodRef]bool,
+	visited map[types.PodRef]bool, kept map[*types.AllowedRoute]bool,
+	ends func(route *types.AllowedRoute) (types.PodRef, types.PodRef)) {
+	routesByPod := make(map[types.PodRef][]*types.AllowedRoute)
+	for _, allowedRoute := range allowedRoutes {
+		from, _ := ends(allowedRoute)
+		routesByPod[from] = append(routesByPod[from], allowedRoute)
+	}
+	reached := make(map[types.PodRef]bool)
+	frontier := make([]types.PodRef, 0)
+	for podRef := range roots {
+		reached[podRef] = true
+		frontier = append(frontier, podRef)
+	}
+	for hop := 0; hop < filter.maxHops && len(frontier) > 0; hop++ {
+		next := make([]types.PodRef, 0)
+		for _, podRef := range frontier {
+			for _, allowedRoute := range routesByPod[podRef] {
+				kept[allowedRoute] = true
+				_, to := ends(allowedRoute)
+				visited[to] = true
+				if !reached[to] {
+					reached[to] = true
+					next = append(next, to)
+				}
+			}
+		}
+		frontier = next
+	}
+}
+
+// rootPods are the pods of the root workload, or the root pod itself
+func (filter rootFilter) rootPods(analysisResult types.AnalysisResult) map[types.PodRef]bool {
+	podWorkloads := drift.PodWorkloads(analysisResult)
+	replicaSetPods := make(map[types.PodRef]bool)
+	for _, replicaSet := range analysisResult.ReplicaSets {
+		if filter.root == (types.ResourceRef{Kind: "ReplicaSet", Name: replicaSet.Name,
+			Namespace: replicaSet.Namespace}) {
+			for _, podRef := range replicaSet.TargetPods {
+				replicaSetPods[podRef] = true
+			}
+		}
+	}
+	roots := make(map[types.PodRef]bool)
+	for _, pod := range analysisResult.Pods {
+		podRef := types.PodRef{Name: pod.Name, Namespace: pod.Namespace}
+		isRoot := filter.root == types.ResourceRef{Kind: "Pod", Name: pod.Name, Namespace: pod.Namespace}
+		if isRoot || replicaSetPods[podRef] || podWorkloads(podRef) == filter.root {
+			roots[podRef] = true
+		}
+	}
+	return roots
+}
@@ -0,0 +1,101 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRootFilter(t *testing.T) {
+	type args struct {
+		url string
+	}
+	pod := func(name string) *types.Pod {
+		return &types.Pod{Name: name, Namespace: "shop"}
+	}
+	podRef := func(name string) types.PodRef {
+		return types.PodRef{Name: name, Namespace: "shop"}
+	}
+	route := func(source string, target string) *types.AllowedRoute {
+		return &types.AllowedRoute{SourcePod: podRef(source), TargetPod: podRef(target)}
+	}
+	frontToAPI, apiToDB, dbToBackup, probeToFront := route("front", "api"), route("api", "db"),
+		route("db", "backup"), route("probe", "front")
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{pod("front"), pod("api"), pod("db"), pod("backup"), pod("probe")},
+		PodIsolations: []*types.PodIsolation{
+			{Pod: podRef("front")}, {Pod: podRef("api")}, {Pod: podRef("db")}, {Pod: podRef("backup")},
+			{Pod: podRef("probe")},
+		},
+		AllowedRoutes: []*types.AllowedRoute{frontToAPI, apiToDB, dbToBackup, probeToFront},
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front-5d4f", Namespace: "shop", TargetPods: []types.PodRef{podRef("front")}},
+		},
+		Deployments: []*types.Deployment{
+			{Name: "front", Namespace: "shop", TargetReplicaSets: []types.ReplicaSetRef{
+				{Name: "front-5d4f", Namespace: "shop"}}},
+		},
+	}
+	tests := []struct {
+		name           string
+		args           args
+		expectedPods   []string
+		expectedRoutes []*types.AllowedRoute
+		expectedError  string
+	}{
+		{
+			name:           "routes are followed from a pod within the maximum number of hops",
+			args:           args{url: "/api/analysisResult?rootedAt=shop/pod/front&maxHops=2"},
+			expectedPods:   []string{"front", "api", "db"},
+			expectedRoutes: []*types.AllowedRoute{frontToAPI, apiToDB},
+		},
+		{
+			name:           "routes are followed towards the pods of a workload",
+			args:           args{url: "/api/analysisResult?rootedAt=shop/deployment/front&direction=inbound"},
+			expectedPods:   []string{"front", "probe"},
+			expectedRoutes: []*types.AllowedRoute{probeToFront},
+		},
+		{
+			name:           "routes are followed both ways",
+			args:           args{url: "/api/analysisResult?rootedAt=shop/pod/db&direction=both&maxHops=1"},
+			expectedPods:   []string{"api", "db", "backup"},
+			expectedRoutes: []*types.AllowedRoute{apiToDB, dbToBackup},
+		},
+		{
+			name:          "an unknown direction is rejected",
+			args:          args{url: "/api/analysisResult?rootedAt=shop/pod/db&direction=up"},
+			expectedError: "invalid direction up: expected outbound, inbound or both",
+		},
+		{
+			name:          "a root without kind is rejected",
+			args:          args{url: "/api/analysisResult?rootedAt=db"},
+			expectedError: "invalid rootedAt query parameter: expected kind/name or namespace/kind/name, got db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := rootFilterOf(httptest.NewRequest("GET", tt.args.url, nil))
+			if err != nil {
+				if diff := cmp.Diff(tt.expectedError, err.Error()); diff != "" {
+					t.Errorf("rootFilterOf() error mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expectedError, ""); diff != "" {
+				t.Errorf("rootFilterOf() error mismatch (-want +got):\n%s", diff)
+			}
+			pruned, _ := filter.prune(analysisResult)
+			pods := make([]string, 0)
+			for _, pod := range pruned.Pods {
+				pods = append(pods, pod.Name)
+			}
+			if diff := cmp.Diff(tt.expectedPods, pods); diff != "" {
+				t.Errorf("prune() pods mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRoutes, pruned.AllowedRoutes); diff != "" {
+				t.Errorf("prune() routes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package exposition
+
+import (
+	"fmt"
+	"karto/types"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type portFilter struct {
+	start int32
+	end   int32
+}
+
+// Routes without ports are allowed on all ports, and therefore match any filter
+func (filter portFilter) matches(allowedRoute *types.AllowedRoute) bool {
+	if allowedRoute.Ports == nil {
+		return true
+	}
+	for _, port := range allowedRoute.Ports {
+		if port >= filter.start && port <= filter.end {
+			return true
+		}
+	}
+	for _, portRange := range allowedRoute.PortRanges {
+		if portRange.Port <= filter.end && portRange.EndPort >= filter.start {
+			return true
+		}
+	}
+	return false
+}
+
+func portFiltersOf(r *http.Request) ([]portFilter, error) {
+	filters := make([]portFilter, 0)
+	query := r.URL.Query()
+	if rawPort := query.Get("port"); rawPort != "" {
+		port, err := parsePort(rawPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s: %s", rawPort, err)
+		}
+		filters = append(filters, portFilter{start: port, end: port})
+	}
+	if rawPortRange := query.Get("portRange"); rawPortRange != "" {
+		bounds := strings.SplitN(rawPortRange, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid port range %s: expected <start>-<end>", rawPortRange)
+		}
+		start, err := parsePort(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %s: %s", rawPortRange, err)
+		}
+		end, err := parsePort(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %s: %s", rawPortRange, err)
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid port range %s: start is greater than end", rawPortRange)
+		}
+		filters = append(filters, portFilter{start: start, end: end})
+	}
+	return filters, nil
+}
+
+func parsePort(rawPort string) (int32, error) {
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("ports must be integers between 1 and 65535")
+	}
+	return int32(port), nil
+}
+
+func filterRoutes(allowedRoutes []*types.AllowedRoute, filters []portFilter) []*types.AllowedRoute {
+	if len(filters) == 0 {
+		return allowedRoutes
+	}
+	result := make([]*types.AllowedRoute, 0)
+	for _, allowedRoute := range allowedRoutes {
+		matchesAll := true
+		for _, filter := range filters {
+			if !filter.matches(allowedRoute) {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			result = append(result, allowedRoute)
+		}
+	}
+	return result
+}
+
+const sortByRisk = "risk"
+
+func routeSortOf(r *http.Request) (string, error) {
+	routeSort := r.URL.Query().Get("sort")
+	if routeSort != "" && routeSort != sortByRisk {
+		return "", fmt.Errorf("invalid sort %s: expected %s", routeSort, sortByRisk)
+	}
+	return routeSort, nil
+}
+
+// Routes are sorted on a copy, analysis results being shared between requests
+func sortRoutes(allowedRoutes []*types.AllowedRoute, routeSort string) []*types.AllowedRoute {
+	if routeSort != sortByRisk {
+		return allowedRoutes
+	}
+	result := append(make([]*types.AllowedRoute, 0, len(allowedRoutes)), allowedRoutes...)
+	sort.SliceStable(result, func(i, j int) bool { return result[i].RiskScore > result[j].RiskScore })
+	return result
+}
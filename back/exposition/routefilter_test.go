@@ -0,0 +1,129 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilterRoutes(t *testing.T) {
+	type args struct {
+		url string
+	}
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	postgresRoute := &types.AllowedRoute{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{5432}}
+	webRoute := &types.AllowedRoute{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{80, 8080}}
+	allPortsRoute := &types.AllowedRoute{SourcePod: podRef2, TargetPod: podRef1, Ports: nil}
+	nodePortsRoute := &types.AllowedRoute{SourcePod: podRef2, TargetPod: podRef1, Ports: []int32{},
+		PortRanges: []types.PortRange{{Port: 30000, EndPort: 32767}}}
+	allowedRoutes := []*types.AllowedRoute{postgresRoute, webRoute, allPortsRoute, nodePortsRoute}
+	tests := []struct {
+		name           string
+		args           args
+		expectedRoutes []*types.AllowedRoute
+		expectedError  string
+	}{
+		{
+			name:           "all routes are kept without filter",
+			args:           args{url: "/api/analysisResult"},
+			expectedRoutes: allowedRoutes,
+		},
+		{
+			name:           "routes are filtered by port",
+			args:           args{url: "/api/analysisResult?port=5432"},
+			expectedRoutes: []*types.AllowedRoute{postgresRoute, allPortsRoute},
+		},
+		{
+			name:           "routes are filtered by port range",
+			args:           args{url: "/api/analysisResult?portRange=8000-9000"},
+			expectedRoutes: []*types.AllowedRoute{webRoute, allPortsRoute},
+		},
+		{
+			name:           "routes must match both a port and a port range",
+			args:           args{url: "/api/analysisResult?port=80&portRange=8000-9000"},
+			expectedRoutes: []*types.AllowedRoute{webRoute, allPortsRoute},
+		},
+		{
+			name:           "routes allowed on a range of ports match the port ranges overlapping it",
+			args:           args{url: "/api/analysisResult?portRange=32000-40000"},
+			expectedRoutes: []*types.AllowedRoute{allPortsRoute, nodePortsRoute},
+		},
+		{
+			name:          "a port out of bounds is rejected",
+			args:          args{url: "/api/analysisResult?port=70000"},
+			expectedError: "invalid port 70000: ports must be integers between 1 and 65535",
+		},
+		{
+			name:          "a port range without end is rejected",
+			args:          args{url: "/api/analysisResult?portRange=8000"},
+			expectedError: "invalid port range 8000: expected <start>-<end>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := portFiltersOf(httptest.NewRequest("GET", tt.args.url, nil))
+			if err != nil {
+				if diff := cmp.Diff(tt.expectedError, err.Error()); diff != "" {
+					t.Errorf("portFiltersOf() error mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expectedError, ""); diff != "" {
+				t.Errorf("portFiltersOf() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedRoutes, filterRoutes(allowedRoutes, filters)); diff != "" {
+				t.Errorf("filterRoutes() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	lowRiskRoute := &types.AllowedRoute{SourcePod: podRef1, TargetPod: podRef2, RiskScore: 1}
+	highRiskRoute := &types.AllowedRoute{SourcePod: podRef2, TargetPod: podRef1, RiskScore: 5}
+	otherLowRiskRoute := &types.AllowedRoute{SourcePod: podRef1, TargetPod: podRef1, RiskScore: 1}
+	allowedRoutes := []*types.AllowedRoute{lowRiskRoute, highRiskRoute, otherLowRiskRoute}
+	tests := []struct {
+		name           string
+		url            string
+		expectedRoutes []*types.AllowedRoute
+		expectedError  string
+	}{
+		{
+			name:           "routes keep their order without sort",
+			url:            "/api/analysisResult",
+			expectedRoutes: allowedRoutes,
+		},
+		{
+			name:           "routes are sorted by decreasing risk score",
+			url:            "/api/analysisResult?sort=risk",
+			expectedRoutes: []*types.AllowedRoute{highRiskRoute, lowRiskRoute, otherLowRiskRoute},
+		},
+		{
+			name:          "unknown sorts are rejected",
+			url:           "/api/analysisResult?sort=name",
+			expectedError: "invalid sort name: expected risk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeSort, err := routeSortOf(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				if diff := cmp.Diff(tt.expectedError, err.Error()); diff != "" {
+					t.Errorf("routeSortOf() error mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expectedRoutes, sortRoutes(allowedRoutes, routeSort)); diff != "" {
+				t.Errorf("sortRoutes() result mismatch (-want +got):\n%s", diff)
+			}
+			if allowedRoutes[0] != lowRiskRoute {
+				t.Errorf("sortRoutes() modified the given routes")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package exposition
+
+import (
+	"karto/sampling"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultSampleSize = 500
+
+func (handler *handler) sampleRoutes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	size := defaultSampleSize
+	if rawSize := query.Get("n"); rawSize != "" {
+		var err error
+		size, err = strconv.Atoi(rawSize)
+		if err != nil || size < 1 {
+			http.Error(w, "n must be a strictly positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	strategy := query.Get("strategy")
+	if strategy == "" {
+		strategy = sampling.StrategyRisk
+	}
+	// A seed makes random samples reproducible, for example to page through the same sample
+	seed := time.Now().UnixNano()
+	if rawSeed := query.Get("seed"); rawSeed != "" {
+		var err error
+		seed, err = strconv.ParseInt(rawSeed, 10, 64)
+		if err != nil {
+			http.Error(w, "seed must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	filters, err := portFiltersOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	handler.mutex.RLock()
+	allowedRoutes := handler.lastAnalysisResult.AllowedRoutes
+	handler.mutex.RUnlock()
+	sample, err := sampling.Build(filterRoutes(allowedRoutes, filters), size, strategy,
+		rand.New(rand.NewSource(seed)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeResponse(w, r, sample)
+}
@@ -0,0 +1,73 @@
+package exposition
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SimulationOptions struct {
+	// MaxConcurrent is the number of simulations run at once, the others waiting in queue, 0 not to cap them
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+	// CallerQuota is the number of simulations a client (role token or IP) can request per minute, 0 not to limit them
+	CallerQuota int
+}
+
+// simulationLimiter protects the live analyses from bursts of simulations, each running a full what-if analysis
+type simulationLimiter struct {
+	options SimulationOptions
+	slots   chan struct{}
+	quotas  *rateLimiter
+}
+
+func newSimulationLimiter(options SimulationOptions) *simulationLimiter {
+	simulationLimiter := &simulationLimiter{options: options}
+	if options.MaxConcurrent > 0 {
+		simulationLimiter.slots = make(chan struct{}, options.MaxConcurrent)
+	}
+	if options.CallerQuota > 0 {
+		simulationLimiter.quotas = newRateLimiter(RateLimitOptions{
+			RequestsPerSecond: float64(options.CallerQuota) / 60,
+			Burst:             options.CallerQuota,
+		})
+	}
+	return simulationLimiter
+}
+
+func (simulationLimiter *simulationLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quotas := simulationLimiter.quotas
+		if quotas != nil && !quotas.allow(quotas.clientKey(r)) {
+			writeTooManyRequests(w, "the simulation quota of this client is exhausted",
+				time.Minute/time.Duration(simulationLimiter.options.CallerQuota))
+			return
+		}
+		if simulationLimiter.slots == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timer := time.NewTimer(simulationLimiter.options.QueueTimeout)
+		defer timer.Stop()
+		select {
+		case simulationLimiter.slots <- struct{}{}:
+			defer func() { <-simulationLimiter.slots }()
+			next.ServeHTTP(w, r)
+		case <-timer.C:
+			writeTooManyRequests(w, "too many simulations are running", simulationLimiter.options.QueueTimeout)
+		case <-r.Context().Done():
+			writeContextError(w, r.Context().Err())
+		}
+	})
+}
+
+// The client is told to retry after the given delay, rounded up to the second
+func writeTooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
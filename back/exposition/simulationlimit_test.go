@@ -0,0 +1,59 @@
+package exposition
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSimulationLimiterQuota(t *testing.T) {
+	handler := newSimulationLimiter(SimulationOptions{CallerQuota: 1}).limit(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	expectedStatusCodes := []int{200, 429}
+	for i, expectedStatusCode := range expectedStatusCodes {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/explain/policy", nil))
+		if w.Code != expectedStatusCode {
+			t.Errorf("Response %d status code = %d, expected %d", i, w.Code, expectedStatusCode)
+		}
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/explain/policy", nil))
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("Retry-After = %s, expected 60", retryAfter)
+	}
+}
+
+func TestSimulationLimiterConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := newSimulationLimiter(SimulationOptions{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond}).limit(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("block") == "true" {
+				close(started)
+				<-release
+			}
+		}))
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/explain/policy?block=true", nil))
+		done <- w.Code
+	}()
+	<-started
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/explain/policy", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Queued response = %d after %s, expected 429 after 1", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	if statusCode := <-done; statusCode != http.StatusOK {
+		t.Errorf("Running response status code = %d, expected %d", statusCode, http.StatusOK)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/explain/policy", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Response status code after release = %d, expected %d", w.Code, http.StatusOK)
+	}
+}
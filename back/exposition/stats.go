@@ -0,0 +1,14 @@
+package exposition
+
+import "net/http"
+
+func (handler *handler) lastRunStats(w http.ResponseWriter, r *http.Request) {
+	handler.mutex.RLock()
+	stats := handler.lastAnalysisResult.Stats
+	handler.mutex.RUnlock()
+	if stats == nil {
+		http.Error(w, "no analysis has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeResponse(w, r, stats)
+}
@@ -0,0 +1,74 @@
+package exposition
+
+import (
+	"karto/analyzer/finding"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"net/http"
+)
+
+func (handler *handler) resultSummary(w http.ResponseWriter, r *http.Request) {
+	suppressions, err := handler.suppressionStore.List()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	handler.mutex.RLock()
+	analysisResult := handler.lastAnalysisResult
+	analyzed := handler.analyzed
+	handler.mutex.RUnlock()
+	if !analyzed {
+		http.Error(w, "no analysis has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	analysisResult.Findings = suppression.Apply(analysisResult.Findings, suppressions)
+	writeResponse(w, r, summarize(analysisResult))
+}
+
+func summarize(analysisResult types.AnalysisResult) types.ResultSummary {
+	summary := types.ResultSummary{
+		Pods:          len(analysisResult.Pods),
+		Namespaces:    len(analysisResult.Namespaces),
+		AllowedRoutes: len(analysisResult.AllowedRoutes),
+		FindingsBySeverity: map[string]int{
+			finding.SeverityHigh:   0,
+			finding.SeverityMedium: 0,
+			finding.SeverityLow:    0,
+		},
+	}
+	for _, podIsolation := range analysisResult.PodIsolations {
+		if podIsolation.IsIngressIsolated {
+			summary.IngressIsolatedPods++
+		}
+		if podIsolation.IsEgressIsolated {
+			summary.EgressIsolatedPods++
+		}
+	}
+	for _, namespace := range analysisResult.Namespaces {
+		if namespace.DefaultDenyIngress {
+			summary.DefaultDenyIngressNamespaces++
+		}
+		if namespace.DefaultDenyEgress {
+			summary.DefaultDenyEgressNamespaces++
+		}
+	}
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if allowedRoute.SourcePod.Namespace != allowedRoute.TargetPod.Namespace {
+			summary.CrossNamespaceRoutes++
+		}
+	}
+	for _, finding := range analysisResult.Findings {
+		if finding.Suppression != nil {
+			summary.SuppressedFindings++
+			continue
+		}
+		summary.FindingsBySeverity[finding.Severity]++
+	}
+	if analysisResult.Stats != nil {
+		summary.AnalyzedAt = analysisResult.Stats.StartedAt
+		summary.ResourceVersions = analysisResult.Stats.ResourceVersions
+	}
+	return summary
+}
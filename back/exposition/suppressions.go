@@ -0,0 +1,88 @@
+package exposition
+
+import (
+	"encoding/json"
+	"fmt"
+	"karto/types"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const suppressionsPath = "/api/findings/suppressions"
+
+type suppressionRequest struct {
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func (handler *handler) handleSuppressions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		suppressions, err := handler.suppressionStore.List()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeResponse(w, r, suppressions)
+	case http.MethodPost:
+		handler.createSuppression(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *handler) createSuppression(w http.ResponseWriter, r *http.Request) {
+	var request suppressionRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid suppression: %s", err), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if request.Fingerprint == "" || request.Reason == "" {
+		http.Error(w, "invalid suppression: fingerprint and reason are required", http.StatusBadRequest)
+		return
+	}
+	if !request.ExpiresAt.After(now) {
+		http.Error(w, "invalid suppression: expiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+	suppression := &types.FindingSuppression{
+		Fingerprint: request.Fingerprint,
+		Reason:      request.Reason,
+		CreatedAt:   now.UTC(),
+		ExpiresAt:   request.ExpiresAt.UTC(),
+	}
+	err = handler.suppressionStore.Save(suppression)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponseWithStatus(w, r, http.StatusCreated, suppression)
+}
+
+func (handler *handler) deleteSuppression(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	fingerprint := strings.TrimPrefix(r.URL.Path, suppressionsPath+"/")
+	deleted, err := handler.suppressionStore.Delete(fingerprint)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
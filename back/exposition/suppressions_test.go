@@ -0,0 +1,80 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuppressions(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "creates a suppression",
+			method:             "POST",
+			path:               "/api/findings/suppressions",
+			body:               "{\"fingerprint\":\"abc\",\"reason\":\"accepted risk\",\"expiresAt\":\"" + expiresAt + "\"}",
+			expectedStatusCode: 201,
+		},
+		{
+			name:               "rejects a suppression without reason",
+			method:             "POST",
+			path:               "/api/findings/suppressions",
+			body:               "{\"fingerprint\":\"abc\",\"expiresAt\":\"" + expiresAt + "\"}",
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "rejects an already expired suppression",
+			method:             "POST",
+			path:               "/api/findings/suppressions",
+			body:               "{\"fingerprint\":\"abc\",\"reason\":\"accepted risk\",\"expiresAt\":\"2021-01-01T00:00:00Z\"}",
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "lists suppressions",
+			method:             "GET",
+			path:               "/api/findings/suppressions",
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "deletes an existing suppression",
+			method:             "DELETE",
+			path:               "/api/findings/suppressions/existing",
+			expectedStatusCode: 204,
+		},
+		{
+			name:               "does not delete an unknown suppression",
+			method:             "DELETE",
+			path:               "/api/findings/suppressions/unknown",
+			expectedStatusCode: 404,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := suppression.NewMemoryStore()
+			handler := newHandler(store)
+			existing := httptest.NewRecorder()
+			handler.handleSuppressions(existing, httptest.NewRequest("POST", "/api/findings/suppressions",
+				strings.NewReader("{\"fingerprint\":\"existing\",\"reason\":\"r\",\"expiresAt\":\""+expiresAt+"\"}")))
+			w := httptest.NewRecorder()
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method == "DELETE" {
+				handler.deleteSuppression(w, request)
+			} else {
+				handler.handleSuppressions(w, request)
+			}
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s\n%s", diff, w.Body.String())
+			}
+		})
+	}
+}
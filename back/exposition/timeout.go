@@ -0,0 +1,62 @@
+package exposition
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Handlers stop their computation once the context of the request is done, either because the client went away or
+// because the request took longer than the timeout
+func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routeTimeouts applies the request timeout to the request/response routes of the mux only, the routes streaming
+// their response being bounded by their client instead
+type routeTimeouts struct {
+	mux       *http.ServeMux
+	timeout   time.Duration
+	streaming map[string]bool
+}
+
+func newRouteTimeouts(mux *http.ServeMux, timeout time.Duration) *routeTimeouts {
+	return &routeTimeouts{mux: mux, timeout: timeout, streaming: make(map[string]bool)}
+}
+
+// handleStreaming registers a route whose response is streamed for as long as the client reads it
+func (routeTimeouts *routeTimeouts) handleStreaming(pattern string, handler http.Handler) {
+	routeTimeouts.streaming[pattern] = true
+	routeTimeouts.mux.Handle(pattern, handler)
+}
+
+func (routeTimeouts *routeTimeouts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := routeTimeouts.mux.Handler(r); routeTimeouts.streaming[pattern] {
+		routeTimeouts.mux.ServeHTTP(w, r)
+		return
+	}
+	withTimeout(routeTimeouts.mux, routeTimeouts.timeout).ServeHTTP(w, r)
+}
+
+func writeUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		writeContextError(w, r.Context().Err())
+		return
+	}
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// A canceled request has no client left to answer to
+func writeContextError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "the request timed out", http.StatusServiceUnavailable)
+	}
+}
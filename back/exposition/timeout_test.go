@@ -0,0 +1,41 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteTimeouts(t *testing.T) {
+	hasDeadline := func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		if ok {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}
+	mux := http.NewServeMux()
+	routes := newRouteTimeouts(mux, time.Minute)
+	mux.HandleFunc("/api/summary", hasDeadline)
+	routes.handleStreaming(ndjsonPath, http.HandlerFunc(hasDeadline))
+	routes.handleStreaming(eventsPath, http.HandlerFunc(hasDeadline))
+	tests := []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+	}{
+		{name: "request/response routes have a deadline", url: "/api/summary", expectedStatusCode: 504},
+		{name: "the exports have no deadline", url: ndjsonPath + "?limit=10", expectedStatusCode: 200},
+		{name: "the event stream has no deadline", url: eventsPath, expectedStatusCode: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package exposition
+
+import (
+	"encoding/json"
+	"fmt"
+	"karto/types"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	viewsPath       = "/api/views"
+	viewsCollection = "views"
+)
+
+type viewRequest struct {
+	Name     string          `json:"name"`
+	Controls json.RawMessage `json:"controls"`
+}
+
+func (handler *handler) handleViews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		views, err := handler.listViews()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeResponse(w, r, views)
+	case http.MethodPost:
+		handler.saveView(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *handler) listViews() ([]*types.SavedView, error) {
+	names, err := handler.viewStore.Keys(viewsCollection)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]*types.SavedView, 0)
+	for _, name := range names {
+		content, ok, err := handler.viewStore.Get(viewsCollection, name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		view := &types.SavedView{}
+		err = json.Unmarshal(content, view)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func (handler *handler) saveView(w http.ResponseWriter, r *http.Request) {
+	var request viewRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid view: %s", err), http.StatusBadRequest)
+		return
+	}
+	if request.Name == "" || strings.Contains(request.Name, "/") {
+		http.Error(w, "invalid view: a name without slash is required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Controls) == 0 {
+		http.Error(w, "invalid view: controls are required", http.StatusBadRequest)
+		return
+	}
+	view := &types.SavedView{
+		Name:     request.Name,
+		Controls: request.Controls,
+		SavedAt:  time.Now().UTC(),
+	}
+	content, err := json.Marshal(view)
+	if err == nil {
+		err = handler.viewStore.Put(viewsCollection, view.Name, content)
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponseWithStatus(w, r, http.StatusCreated, view)
+}
+
+func (handler *handler) deleteView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	deleted, err := handler.viewStore.Delete(viewsCollection, strings.TrimPrefix(r.URL.Path, viewsPath+"/"))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
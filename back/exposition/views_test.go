@@ -0,0 +1,77 @@
+package exposition
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/suppression"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestViews(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "saves a view",
+			method:             "POST",
+			path:               "/api/views",
+			body:               "{\"name\":\"shop\",\"controls\":{\"namespaces\":[\"shop\"]}}",
+			expectedStatusCode: 201,
+		},
+		{
+			name:               "rejects a view without controls",
+			method:             "POST",
+			path:               "/api/views",
+			body:               "{\"name\":\"shop\"}",
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "rejects a view with a slash in its name",
+			method:             "POST",
+			path:               "/api/views",
+			body:               "{\"name\":\"shop/prod\",\"controls\":{}}",
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "lists views",
+			method:             "GET",
+			path:               "/api/views",
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "deletes an existing view",
+			method:             "DELETE",
+			path:               "/api/views/existing",
+			expectedStatusCode: 204,
+		},
+		{
+			name:               "does not delete an unknown view",
+			method:             "DELETE",
+			path:               "/api/views/unknown",
+			expectedStatusCode: 404,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler(suppression.NewMemoryStore())
+			existing := httptest.NewRecorder()
+			handler.handleViews(existing, httptest.NewRequest("POST", "/api/views",
+				strings.NewReader("{\"name\":\"existing\",\"controls\":{\"autoRefresh\":true}}")))
+			w := httptest.NewRecorder()
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method == "DELETE" {
+				handler.deleteView(w, request)
+			} else {
+				handler.handleViews(w, request)
+			}
+			if diff := cmp.Diff(tt.expectedStatusCode, w.Code); diff != "" {
+				t.Errorf("Response status code mismatch (-want +got):\n%s\n%s", diff, w.Body.String())
+			}
+		})
+	}
+}
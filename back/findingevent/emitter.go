@@ -0,0 +1,141 @@
+package findingevent
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"karto/analyzer/finding"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"time"
+)
+
+const (
+	EventReasonHighSeverityFinding = "HighSeverityFinding"
+	// A change breaking a whole cluster may raise many findings at once, only the first ones are emitted
+	eventsLimit = 20
+)
+
+type emitter struct {
+	k8sClient        kubernetes.Interface
+	suppressionStore suppression.Store
+	now              func() time.Time
+	// emitted holds the fingerprints of the findings of the previous analysis, so that a finding is only emitted when
+	// it appears, and again if it comes back after being fixed
+	emitted map[string]bool
+}
+
+// Emit creates a warning event on the involved policy or namespace for each new high severity finding of the
+// analyses, so that they show in kubectl describe and event based alerting. Suppressed findings are not emitted.
+func Emit(k8sClient kubernetes.Interface, suppressionStore suppression.Store,
+	resultsChannel <-chan types.AnalysisResult) {
+	emitter := &emitter{
+		k8sClient:        k8sClient,
+		suppressionStore: suppressionStore,
+		now:              time.Now,
+	}
+	for {
+		analysisResult := <-resultsChannel
+		err := emitter.emit(context.Background(), analysisResult)
+		if err != nil {
+			log.Printf("Unable to emit the events of the findings: %s\n", err)
+		}
+	}
+}
+
+func (emitter *emitter) emit(ctx context.Context, analysisResult types.AnalysisResult) error {
+	findings := analysisResult.Findings
+	if emitter.suppressionStore != nil {
+		suppressions, err := emitter.suppressionStore.List()
+		if err != nil {
+			return err
+		}
+		findings = suppression.Apply(findings, suppressions)
+	}
+	current := make(map[string]bool)
+	count := 0
+	for _, highFinding := range findings {
+		if highFinding.Suppression != nil || highFinding.Severity != finding.SeverityHigh {
+			continue
+		}
+		current[highFinding.Fingerprint] = true
+		if emitter.emitted[highFinding.Fingerprint] || count == eventsLimit {
+			continue
+		}
+		count++
+		// Events are best effort, the findings being served by the API anyway
+		err := emitter.emitFinding(ctx, highFinding)
+		if err != nil {
+			log.Printf("Unable to emit an event for finding %s on %s: %s\n", highFinding.Rule,
+				resourceOf(highFinding.Resource), err)
+		}
+	}
+	emitter.emitted = current
+	return nil
+}
+
+func (emitter *emitter) emitFinding(ctx context.Context, highFinding *types.Finding) error {
+	involvedObject, err := emitter.involvedObjectOf(ctx, highFinding.Resource)
+	if err != nil {
+		return err
+	}
+	// Events of cluster scoped objects are recorded in the default namespace, as kubectl looks for them there
+	namespace := involvedObject.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	now := metav1.NewTime(emitter.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s.%s.%x", involvedObject.Name, highFinding.Fingerprint,
+			now.UnixNano()), Namespace: namespace},
+		InvolvedObject: involvedObject,
+		Reason:         EventReasonHighSeverityFinding,
+		Message:        fmt.Sprintf("%s: %s", highFinding.Rule, highFinding.Message),
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "karto"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err = emitter.k8sClient.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
+
+// involvedObjectOf returns the policy of the finding, or else its namespace, whose UID is required for kubectl
+// describe to list the event
+func (emitter *emitter) involvedObjectOf(ctx context.Context,
+	resource types.ResourceRef) (corev1.ObjectReference, error) {
+	switch {
+	case resource.Kind == "NetworkPolicy":
+		policy, err := emitter.k8sClient.NetworkingV1().NetworkPolicies(resource.Namespace).Get(ctx, resource.Name,
+			metav1.GetOptions{})
+		if err != nil {
+			return corev1.ObjectReference{}, err
+		}
+		return corev1.ObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy", Name: policy.Name,
+			Namespace: policy.Namespace, UID: policy.UID}, nil
+	case resource.Kind == "Namespace" || resource.Namespace != "":
+		name := resource.Namespace
+		if resource.Kind == "Namespace" {
+			name = resource.Name
+		}
+		namespace, err := emitter.k8sClient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return corev1.ObjectReference{}, err
+		}
+		return corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: namespace.Name,
+			UID: namespace.UID}, nil
+	default:
+		return corev1.ObjectReference{}, fmt.Errorf("no policy or namespace is involved")
+	}
+}
+
+func resourceOf(resource types.ResourceRef) string {
+	if resource.Namespace == "" {
+		return fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", resource.Kind, resource.Namespace, resource.Name)
+}
@@ -0,0 +1,125 @@
+package findingevent
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"karto/analyzer/finding"
+	"karto/suppression"
+	"karto/types"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestEmit(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	shopNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", UID: k8stypes.UID("shop-uid")}}
+	frontPolicy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "front", Namespace: "shop",
+		UID: k8stypes.UID("front-uid")}}
+	dnsFinding := finding.NewFinding(finding.RuleDNSEgressBlocked, finding.SeverityHigh,
+		types.ResourceRef{Kind: "Namespace", Name: "shop"}, finding.CodeDNSEgressBlocked, nil)
+	dnsFinding.Message = "pods of namespace shop cannot resolve names"
+	policyFinding := finding.NewFinding(finding.RuleUnusedNetworkPolicy, finding.SeverityHigh,
+		types.ResourceRef{Kind: "NetworkPolicy", Name: "front", Namespace: "shop"}, "", nil)
+	policyFinding.Message = "network policy shop/front selects no pod"
+	podFinding := finding.NewFinding(finding.RuleHostPortExposed, finding.SeverityHigh,
+		types.ResourceRef{Kind: "Pod", Name: "db", Namespace: "shop"}, "", nil)
+	podFinding.Message = "pod shop/db exposes host port 5432"
+	lowFinding := finding.NewFinding(finding.RulePodNotEgressIsolated, finding.SeverityLow,
+		types.ResourceRef{Kind: "Pod", Name: "db", Namespace: "shop"}, "", nil)
+	lowFinding.Message = "pod shop/db is not isolated for egress"
+	type event struct {
+		Namespace string
+		Involved  corev1.ObjectReference
+		Message   string
+	}
+	tests := []struct {
+		name             string
+		previousFindings []*types.Finding
+		findings         []*types.Finding
+		suppressions     []*types.FindingSuppression
+		expectedEvents   []event
+	}{
+		{
+			name:     "high severity findings are emitted on their policy, or else their namespace",
+			findings: []*types.Finding{policyFinding, dnsFinding, podFinding, lowFinding},
+			expectedEvents: []event{
+				{Namespace: "default", Involved: corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace",
+					Name: "shop", UID: "shop-uid"}, Message: "dns-egress-blocked: pods of namespace shop cannot " +
+					"resolve names"},
+				{Namespace: "default", Involved: corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace",
+					Name: "shop", UID: "shop-uid"}, Message: "host-port-exposed: pod shop/db exposes host port 5432"},
+				{Namespace: "shop", Involved: corev1.ObjectReference{APIVersion: "networking.k8s.io/v1",
+					Kind: "NetworkPolicy", Name: "front", Namespace: "shop", UID: "front-uid"},
+					Message: "unused-network-policy: network policy shop/front selects no pod"},
+			},
+		},
+		{
+			name:             "findings already raised by the previous analysis are not emitted again",
+			previousFindings: []*types.Finding{dnsFinding},
+			findings:         []*types.Finding{dnsFinding, policyFinding},
+			expectedEvents: []event{
+				{Namespace: "shop", Involved: corev1.ObjectReference{APIVersion: "networking.k8s.io/v1",
+					Kind: "NetworkPolicy", Name: "front", Namespace: "shop", UID: "front-uid"},
+					Message: "unused-network-policy: network policy shop/front selects no pod"},
+			},
+		},
+		{
+			name:     "suppressed findings are not emitted",
+			findings: []*types.Finding{dnsFinding},
+			suppressions: []*types.FindingSuppression{{Fingerprint: dnsFinding.Fingerprint, Reason: "known",
+				ExpiresAt: time.Now().Add(time.Hour)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(shopNamespace, frontPolicy)
+			suppressionStore := suppression.NewMemoryStore()
+			for _, findingSuppression := range tt.suppressions {
+				if err := suppressionStore.Save(findingSuppression); err != nil {
+					t.Fatal(err)
+				}
+			}
+			emitter := &emitter{
+				k8sClient:        k8sClient,
+				suppressionStore: suppressionStore,
+				now:              func() time.Time { return now },
+			}
+			ctx := context.Background()
+			if tt.previousFindings != nil {
+				if err := emitter.emit(ctx, types.AnalysisResult{Findings: tt.previousFindings}); err != nil {
+					t.Fatal(err)
+				}
+				k8sClient = fake.NewSimpleClientset(shopNamespace, frontPolicy)
+				emitter.k8sClient = k8sClient
+			}
+			if err := emitter.emit(ctx, types.AnalysisResult{Findings: tt.findings}); err != nil {
+				t.Fatal(err)
+			}
+			events, err := k8sClient.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			emittedEvents := make([]event, 0)
+			for _, emittedEvent := range events.Items {
+				if emittedEvent.Reason != EventReasonHighSeverityFinding ||
+					emittedEvent.Type != corev1.EventTypeWarning {
+					t.Errorf("emit() event has reason %s and type %s", emittedEvent.Reason, emittedEvent.Type)
+				}
+				emittedEvents = append(emittedEvents, event{Namespace: emittedEvent.Namespace,
+					Involved: emittedEvent.InvolvedObject, Message: emittedEvent.Message})
+			}
+			sort.Slice(emittedEvents, func(i, j int) bool {
+				return emittedEvents[i].Message < emittedEvents[j].Message
+			})
+			if diff := cmp.Diff(append(make([]event, 0), tt.expectedEvents...), emittedEvents); diff != "" {
+				t.Errorf("emit() events mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
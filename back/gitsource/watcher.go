@@ -0,0 +1,100 @@
+package gitsource
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"karto/manifest"
+	"karto/types"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	Repository string
+	Branch     string
+	Path       string
+	Interval   time.Duration
+}
+
+type poller struct {
+	options      Options
+	checkoutDir  string
+	lastRevision string
+}
+
+func Watch(options Options, clusterStateChannel chan<- types.ClusterState) {
+	checkoutDir, err := ioutil.TempDir("", "karto-git-")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	poller := &poller{options: options, checkoutDir: checkoutDir}
+	for {
+		clusterState, changed, err := poller.poll()
+		if err != nil {
+			log.Printf("Unable to analyze the manifests of %s: %s\n", options.Repository, err)
+		} else if changed {
+			log.Printf("Analyzing the manifests of %s at revision %s\n", options.Repository, poller.lastRevision)
+			clusterStateChannel <- clusterState
+		}
+		time.Sleep(options.Interval)
+	}
+}
+
+func (poller *poller) poll() (types.ClusterState, bool, error) {
+	err := poller.update()
+	if err != nil {
+		return types.ClusterState{}, false, err
+	}
+	revision, err := poller.git("rev-parse", "HEAD")
+	if err != nil {
+		return types.ClusterState{}, false, err
+	}
+	if revision == poller.lastRevision {
+		return types.ClusterState{}, false, nil
+	}
+	clusterState, err := manifest.LoadDirectory(filepath.Join(poller.checkoutDir, poller.options.Path))
+	if err != nil {
+		return types.ClusterState{}, false, err
+	}
+	poller.lastRevision = revision
+	return manifest.Expand(clusterState), true, nil
+}
+
+func (poller *poller) update() error {
+	if _, err := os.Stat(filepath.Join(poller.checkoutDir, ".git")); os.IsNotExist(err) {
+		args := []string{"clone", "--depth", "1"}
+		if poller.options.Branch != "" {
+			args = append(args, "--branch", poller.options.Branch)
+		}
+		_, err = poller.git(append(args, poller.options.Repository, ".")...)
+		return err
+	}
+	ref := "HEAD"
+	if poller.options.Branch != "" {
+		ref = poller.options.Branch
+	}
+	_, err := poller.git("fetch", "--depth", "1", "origin", ref)
+	if err != nil {
+		return err
+	}
+	_, err = poller.git("reset", "--hard", "FETCH_HEAD")
+	return err
+}
+
+func (poller *poller) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("git", args...)
+	command.Dir = poller.checkoutDir
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	err := command.Run()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,73 @@
+package gitsource
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPoll(t *testing.T) {
+	repository := t.TempDir()
+	runGit(t, repository, "init", "--quiet")
+	writeManifest(t, repository, "apiVersion: v1\nkind: Pod\nmetadata:\n  name: front\n  namespace: shop\n")
+	runGit(t, repository, "add", ".")
+	runGit(t, repository, "commit", "--quiet", "-m", "initial")
+	poller := &poller{options: Options{Repository: repository, Path: "manifests"}, checkoutDir: t.TempDir()}
+
+	clusterState, changed, err := poller.poll()
+	if err != nil {
+		t.Fatalf("poll() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(true, changed); diff != "" {
+		t.Errorf("poll() should report the first revision as a change (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(clusterState.Pods)); diff != "" {
+		t.Errorf("poll() pods mismatch (-want +got):\n%s", diff)
+	}
+
+	_, changed, err = poller.poll()
+	if err != nil {
+		t.Fatalf("poll() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(false, changed); diff != "" {
+		t.Errorf("poll() should not report an unchanged revision (-want +got):\n%s", diff)
+	}
+
+	writeManifest(t, repository, "apiVersion: v1\nkind: Pod\nmetadata:\n  name: front\n  namespace: shop\n---\n"+
+		"apiVersion: v1\nkind: Pod\nmetadata:\n  name: api\n  namespace: shop\n")
+	runGit(t, repository, "commit", "--quiet", "-am", "add api")
+	clusterState, changed, err = poller.poll()
+	if err != nil {
+		t.Fatalf("poll() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(true, changed); diff != "" {
+		t.Errorf("poll() should report a new revision (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(2, len(clusterState.Pods)); diff != "" {
+		t.Errorf("poll() pods mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeManifest(t *testing.T, repository string, content string) {
+	err := os.MkdirAll(filepath.Join(repository, "manifests"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(repository, "manifests", "pods.yaml"), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	command := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"},
+		args...)...)
+	command.Dir = dir
+	output, err := command.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %s: %s", args, err, output)
+	}
+}
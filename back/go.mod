@@ -4,7 +4,11 @@ go 1.16
 
 require (
 	github.com/google/go-cmp v0.5.5
+	github.com/lib/pq v1.10.1
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	k8s.io/api v0.21.0
 	k8s.io/apimachinery v0.21.0
 	k8s.io/client-go v0.21.0
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"karto/config"
+	"karto/manifest"
+	"karto/types"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden analysis results of testdata/golden")
+
+// TestGolden analyzes each cluster dump of testdata/golden, and compares the result with the golden JSON file of the
+// same name. Dumps are those of kubectl get -o yaml, a List of the objects of the cluster.
+func TestGolden(t *testing.T) {
+	dumpPaths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configuration, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysisScheduler := dependencyInjection(configuration).AnalysisScheduler
+	for _, dumpPath := range dumpPaths {
+		goldenPath := strings.TrimSuffix(dumpPath, ".yaml") + ".json"
+		t.Run(filepath.Base(dumpPath), func(t *testing.T) {
+			clusterState, err := manifest.LoadFile(dumpPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			analysisResult := withoutTimings(analysisScheduler.Analyze(clusterState))
+			content, err := json.MarshalIndent(analysisResult, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = append(content, '\n')
+			if *update {
+				err = ioutil.WriteFile(goldenPath, content, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			golden, err := ioutil.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%s, run go test . -run Golden -update to record it", err)
+			}
+			if diff := cmp.Diff(string(golden), string(content)); diff != "" {
+				t.Errorf("Analyze() mismatch with %s (-want +got):\n%s", goldenPath, diff)
+			}
+		})
+	}
+}
+
+// withoutTimings resets what differs from one analysis of the same cluster to the next
+func withoutTimings(analysisResult types.AnalysisResult) types.AnalysisResult {
+	if analysisResult.Stats == nil {
+		return analysisResult
+	}
+	stats := *analysisResult.Stats
+	stats.StartedAt = time.Time{}
+	stats.DurationMs = 0
+	stats.Stages = make([]*types.StageStats, 0, len(analysisResult.Stats.Stages))
+	for _, stage := range analysisResult.Stats.Stages {
+		stats.Stages = append(stats.Stages, &types.StageStats{Name: stage.Name})
+	}
+	analysisResult.Stats = &stats
+	return analysisResult
+}
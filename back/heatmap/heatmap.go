@@ -0,0 +1,96 @@
+package heatmap
+
+import (
+	"fmt"
+	"karto/drift"
+	"karto/types"
+	"sort"
+)
+
+const (
+	GroupByNamespace = "namespace"
+	GroupByWorkload  = "workload"
+	GroupByZone      = "zone"
+	unknownZone      = "unknown"
+)
+
+// Heatmap rows are the source groups and columns the target groups, both in the order of Groups
+type Heatmap struct {
+	GroupBy       string   `json:"groupBy"`
+	Groups        []string `json:"groups"`
+	Routes        [][]int  `json:"routes"`
+	MaxRiskScores [][]int  `json:"maxRiskScores"`
+}
+
+// Build counts the allowed routes between each pair of groups of pods, every pod being part of a group even when
+// no route reaches it
+func Build(analysisResult types.AnalysisResult, groupBy string) (Heatmap, error) {
+	groupOf, err := grouping(analysisResult, groupBy)
+	if err != nil {
+		return Heatmap{}, err
+	}
+	indexes := make(map[string]int)
+	groups := make([]string, 0)
+	addGroup := func(group string) {
+		if _, ok := indexes[group]; !ok {
+			indexes[group] = 0
+			groups = append(groups, group)
+		}
+	}
+	for _, pod := range analysisResult.Pods {
+		addGroup(groupOf(types.PodRef{Name: pod.Name, Namespace: pod.Namespace}))
+	}
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		addGroup(groupOf(allowedRoute.SourcePod))
+		addGroup(groupOf(allowedRoute.TargetPod))
+	}
+	sort.Strings(groups)
+	for index, group := range groups {
+		indexes[group] = index
+	}
+	result := Heatmap{GroupBy: groupBy, Groups: groups, Routes: matrix(len(groups)),
+		MaxRiskScores: matrix(len(groups))}
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		source := indexes[groupOf(allowedRoute.SourcePod)]
+		target := indexes[groupOf(allowedRoute.TargetPod)]
+		result.Routes[source][target]++
+		if allowedRoute.RiskScore > result.MaxRiskScores[source][target] {
+			result.MaxRiskScores[source][target] = allowedRoute.RiskScore
+		}
+	}
+	return result, nil
+}
+
+func grouping(analysisResult types.AnalysisResult, groupBy string) (func(podRef types.PodRef) string, error) {
+	switch groupBy {
+	case GroupByNamespace:
+		return func(podRef types.PodRef) string { return podRef.Namespace }, nil
+	case GroupByWorkload:
+		workloads := drift.PodWorkloads(analysisResult)
+		return func(podRef types.PodRef) string {
+			workload := workloads(podRef)
+			return fmt.Sprintf("%s/%s/%s", workload.Namespace, workload.Kind, workload.Name)
+		}, nil
+	case GroupByZone:
+		zones := make(map[types.PodRef]string)
+		for _, pod := range analysisResult.Pods {
+			zones[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod.Zone
+		}
+		return func(podRef types.PodRef) string {
+			if zone := zones[podRef]; zone != "" {
+				return zone
+			}
+			return unknownZone
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported grouping %s: expected %s, %s or %s", groupBy, GroupByNamespace,
+		GroupByWorkload, GroupByZone)
+}
+
+func matrix(size int) [][]int {
+	result := make([][]int, size)
+	for i := range result {
+		result[i] = make([]int, size)
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package heatmap
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	frontPod := types.PodRef{Name: "front-abc", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-abc", Namespace: "shop"}
+	dbPod := types.PodRef{Name: "db-0", Namespace: "data"}
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front-abc", Namespace: "shop", Zone: "zone-a"},
+			{Name: "api-abc", Namespace: "shop", Zone: "zone-b"},
+			{Name: "db-0", Namespace: "data"},
+			{Name: "batch", Namespace: "jobs", Zone: "zone-a"},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: frontPod, TargetPod: apiPod, RiskScore: 0},
+			{SourcePod: apiPod, TargetPod: dbPod, RiskScore: 3},
+			{SourcePod: frontPod, TargetPod: dbPod, RiskScore: 5},
+		},
+		StatefulSets: []*types.StatefulSet{
+			{Name: "db", Namespace: "data", TargetPods: []types.PodRef{dbPod}},
+		},
+	}
+	tests := []struct {
+		name            string
+		groupBy         string
+		expectedHeatmap Heatmap
+		expectedError   string
+	}{
+		{
+			name:    "routes are counted between namespaces",
+			groupBy: "namespace",
+			expectedHeatmap: Heatmap{
+				GroupBy:       "namespace",
+				Groups:        []string{"data", "jobs", "shop"},
+				Routes:        [][]int{{0, 0, 0}, {0, 0, 0}, {2, 0, 1}},
+				MaxRiskScores: [][]int{{0, 0, 0}, {0, 0, 0}, {5, 0, 0}},
+			},
+		},
+		{
+			name:    "pods without workload are their own group",
+			groupBy: "workload",
+			expectedHeatmap: Heatmap{
+				GroupBy: "workload",
+				Groups: []string{"data/StatefulSet/db", "jobs/Pod/batch", "shop/Pod/api-abc",
+					"shop/Pod/front-abc"},
+				Routes:        [][]int{{0, 0, 0, 0}, {0, 0, 0, 0}, {1, 0, 0, 0}, {1, 0, 1, 0}},
+				MaxRiskScores: [][]int{{0, 0, 0, 0}, {0, 0, 0, 0}, {3, 0, 0, 0}, {5, 0, 0, 0}},
+			},
+		},
+		{
+			name:    "pods on nodes without zone are in the unknown one",
+			groupBy: "zone",
+			expectedHeatmap: Heatmap{
+				GroupBy:       "zone",
+				Groups:        []string{"unknown", "zone-a", "zone-b"},
+				Routes:        [][]int{{0, 0, 0}, {1, 0, 1}, {1, 0, 0}},
+				MaxRiskScores: [][]int{{0, 0, 0}, {5, 0, 0}, {3, 0, 0}},
+			},
+		},
+		{
+			name:          "an unsupported grouping is rejected",
+			groupBy:       "node",
+			expectedError: "unsupported grouping node: expected namespace, workload or zone",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heatmap, err := Build(analysisResult, tt.groupBy)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("Build() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedHeatmap, heatmap); diff != "" {
+				t.Errorf("Build() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,44 +3,425 @@ package main
 import (
 	"flag"
 	"fmt"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"karto/analytics"
+	"karto/archive"
+	"karto/broadcast"
 	"karto/clusterlistener"
+	"karto/config"
+	"karto/crd"
+	"karto/drift"
+	"karto/enrichment"
 	"karto/exposition"
+	"karto/findingevent"
+	"karto/gitsource"
+	"karto/metrics"
+	"karto/objectstore"
+	"karto/preflight"
+	"karto/replication"
+	"karto/report"
+	"karto/routehistory"
+	"karto/store"
+	"karto/suppression"
+	"karto/trigger"
 	"karto/types"
+	"karto/verification"
+	"log"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const version = "1.6.0"
 
+const (
+	storeDestination    = "store"
+	snapshotsCollection = "snapshots"
+)
+
+type commandLine struct {
+	versionFlag      bool
+	k8sConfigPath    string
+	configPath       string
+	configInterval   time.Duration
+	intentsPath      string
+	suppressionsPath string
+	replica          bool
+	replicaInterval  time.Duration
+	gitSource        gitsource.Options
+	verification     verification.Options
+	analytics        analytics.Options
+	archive          archive.Options
+	routeHistory     routehistory.Options
+	customResources  crd.Options
+	findingEvents    bool
+	trigger          trigger.Options
+	s3               objectstore.S3Options
+	exposition       exposition.Options
+}
+
 func main() {
-	versionFlag, k8sConfigPath := parseCmd()
-	if versionFlag {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case explainPolicyCommand:
+			runExplainPolicy(os.Args[2:])
+			return
+		case compareCommand:
+			runCompare(os.Args[2:])
+			return
+		case generateTestsCommand:
+			runGenerateTests(os.Args[2:])
+			return
+		case verifyCommand:
+			runVerify(os.Args[2:])
+			return
+		}
+	}
+	cmd := parseCmd()
+	if cmd.versionFlag {
 		fmt.Printf("Karto v%s\n", version)
 		os.Exit(0)
 	}
-	container := dependencyInjection()
+	configuration, err := config.Load(cmd.configPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	stateStore := store.NewMemoryStore()
+	if configuration.Store != nil {
+		stateStore, err = store.New(store.Options{
+			Driver: configuration.Store.Driver,
+			Path:   configuration.Store.Path,
+			DSN:    configuration.Store.DSN,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	cmd.exposition.ViewStore = stateStore
+	cmd.exposition.AuditStore = stateStore
+	suppressionStore := suppression.NewMemoryStore()
+	if cmd.suppressionsPath != "" {
+		fileSuppressionStore, err := suppression.NewFileStore(cmd.suppressionsPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		suppressionStore = fileSuppressionStore
+	} else if configuration.Store != nil {
+		suppressionStore = suppression.NewStore(stateStore)
+	}
+	if cmd.customResources.Enabled {
+		declaredSuppressionStore := suppression.NewDeclaredStore(suppressionStore)
+		cmd.customResources.SuppressionStore = declaredSuppressionStore
+		suppressionStore = declaredSuppressionStore
+	}
+	cmd.exposition.SuppressionStore = suppressionStore
+	intents, err := config.LoadIntents(cmd.intentsPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	configuration.Intents = append(configuration.Intents, intents...)
+	cmd.exposition.Rules = configuration.Rules
+	cmd.exposition.Intents = configuration.Intents
+	cmd.exposition.Boundaries = configuration.Boundaries
+	cmd.exposition.Redaction = configuration.Redaction
+	if cmd.replica {
+		if configuration.Store == nil || configuration.Store.Driver != store.DriverPostgres {
+			log.Fatalln("replicas require a postgres store shared with the analyzing instance")
+		}
+		replicatedResultsChannel := make(chan types.AnalysisResult)
+		go replication.Follow(stateStore, cmd.replicaInterval, replicatedResultsChannel)
+		watchConfiguration(&cmd, intents, nil, nil)
+		exposition.Expose(":8000", replicatedResultsChannel, cmd.exposition)
+		return
+	}
+	container := dependencyInjection(configuration)
 	analysisScheduler := container.AnalysisScheduler
+	cmd.exposition.PolicyExplainer = container.PolicyExplainer
+	k8sClient := clusterlistener.NewK8sClient(cmd.k8sConfigPath)
+	dynamicClient := clusterlistener.NewDynamicClient(cmd.k8sConfigPath)
+	preflightReport := preflight.Run(k8sClient, preflight.Options{
+		CustomResources: cmd.customResources.Enabled,
+		FindingEvents:   cmd.findingEvents,
+		Verification:    cmd.verification.Enabled,
+	})
+	cmd.exposition.Preflight = &preflightReport
+	analysisResultsChannel := analyzeCluster(k8sClient, dynamicClient, container, &cmd)
+	if cmd.gitSource.Repository != "" {
+		desiredClusterStateChannel := make(chan types.ClusterState)
+		desiredResultsChannel := make(chan types.AnalysisResult)
+		go gitsource.Watch(cmd.gitSource, desiredClusterStateChannel)
+		go analysisScheduler.AnalyzeOnClusterStateChange(desiredClusterStateChannel, desiredResultsChannel, nil)
+		driftedResultsChannel := make(chan types.AnalysisResult)
+		trackedDesiredResultsChannel := make(chan types.AnalysisResult)
+		go drift.Track(analysisResultsChannel, desiredResultsChannel, driftedResultsChannel,
+			trackedDesiredResultsChannel)
+		analysisResultsChannel = driftedResultsChannel
+		cmd.exposition.DesiredResults = trackedDesiredResultsChannel
+	}
+	if cmd.verification.Enabled {
+		verifiedResultsChannel := make(chan types.AnalysisResult)
+		go verification.Verify(k8sClient, cmd.verification, analysisResultsChannel, verifiedResultsChannel)
+		analysisResultsChannel = verifiedResultsChannel
+	}
+	trackedResultsChannel := make(chan types.AnalysisResult)
+	go routehistory.Track(stateStore, cmd.routeHistory, analysisResultsChannel, trackedResultsChannel)
+	analysisResultsChannel = trackedResultsChannel
+	if configuration.Enrichment != nil && len(configuration.Enrichment.Hooks) > 0 {
+		enrichedResultsChannel := make(chan types.AnalysisResult)
+		go enrichment.Track(*configuration.Enrichment, analysisResultsChannel, enrichedResultsChannel)
+		analysisResultsChannel = enrichedResultsChannel
+	}
+	// Consumers are fed by a hub rather than chained, so that a slow export or upload never delays the analysis
+	hub := broadcast.NewHub()
+	if cmd.analytics.Destination != "" {
+		analyticsStore, err := objectstore.New(cmd.analytics.Destination, cmd.s3)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		go analytics.Export(analyticsStore, cmd.analytics, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "analytics", BufferSize: 1, DropPolicy: broadcast.DropOldest}))
+	}
+	if cmd.archive.Destination != "" {
+		archiveStore := objectstore.NewKVStore(stateStore, snapshotsCollection)
+		if cmd.archive.Destination != storeDestination {
+			archiveStore, err = objectstore.New(cmd.archive.Destination, cmd.s3)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		go archive.Archive(archiveStore, cmd.archive, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "archive", BufferSize: 1, DropPolicy: broadcast.DropOldest}))
+	}
+	var reportConfigs chan config.ReportConfig
+	if configuration.Report != nil {
+		reportConfigs = make(chan config.ReportConfig)
+		go report.Schedule(*configuration.Report, suppressionStore, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "report", BufferSize: 1, DropPolicy: broadcast.DropOldest}), reportConfigs)
+	}
+	watchConfiguration(&cmd, intents, &container, reportConfigs)
+	if cmd.customResources.Enabled {
+		go crd.ReportStatus(k8sClient, suppressionStore, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "crdStatus", BufferSize: 1, DropPolicy: broadcast.DropOldest}))
+	}
+	if cmd.findingEvents {
+		go findingevent.Emit(k8sClient, suppressionStore, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "findingEvents", BufferSize: 1, DropPolicy: broadcast.DropOldest}))
+	}
+	if configuration.Store != nil {
+		go replication.Publish(stateStore, hub.Subscribe(broadcast.SubscriberOptions{
+			Name: "replication", BufferSize: 1, DropPolicy: broadcast.DropOldest}))
+	}
+	exposedResultsChannel := hub.Subscribe(broadcast.SubscriberOptions{
+		Name: "exposition", BufferSize: 1, DropPolicy: broadcast.DropOldest})
+	go hub.Run(analysisResultsChannel)
+	exposition.Expose(":8000", exposedResultsChannel, cmd.exposition)
+}
+
+// watchConfiguration applies the configuration file to the analysis, the targets of the report and the API each time
+// it changes. The intents of the intents file are kept, and the sections only read on startup, such as the store or
+// the report schedule, are not reloaded.
+func watchConfiguration(cmd *commandLine, intents []config.Intent, container *Container,
+	reportConfigs chan<- config.ReportConfig) {
+	if cmd.configPath == "" || cmd.configInterval == 0 {
+		return
+	}
+	configurations := make(chan config.Config)
+	exposedConfigurations := make(chan config.Config)
+	cmd.exposition.Configurations = exposedConfigurations
+	go config.Watch(cmd.configPath, cmd.configInterval, configurations)
+	go func() {
+		for configuration := range configurations {
+			configuration.Intents = append(configuration.Intents, intents...)
+			if container != nil {
+				container.Reload(configuration)
+			}
+			if reportConfigs != nil && configuration.Report != nil {
+				reportConfigs <- *configuration.Report
+			}
+			exposedConfigurations <- configuration
+		}
+	}()
+}
+
+// analyzeCluster analyzes the cluster on each change, or as triggered. The exposition options are completed with the
+// channels feeding the API as the analysis goes.
+func analyzeCluster(k8sClient kubernetes.Interface, dynamicClient dynamic.Interface, container Container,
+	cmd *commandLine) chan types.AnalysisResult {
 	analysisResultsChannel := make(chan types.AnalysisResult)
 	clusterStateChannel := make(chan types.ClusterState)
-	go clusterlistener.Listen(k8sConfigPath, clusterStateChannel)
-	go analysisScheduler.AnalyzeOnClusterStateChange(clusterStateChannel, analysisResultsChannel)
-	exposition.Expose(":8000", analysisResultsChannel)
+	trackedClusterStateChannel := make(chan types.ClusterState)
+	policyChurn := metrics.NewPolicyChurn()
+	cmd.exposition.PolicyChurn = policyChurn
+	dataFreshness := metrics.NewDataFreshness()
+	cmd.exposition.DataFreshness = dataFreshness
+	go clusterlistener.Listen(k8sClient, dynamicClient, policyChurn, dataFreshness, clusterStateChannel)
+	if cmd.customResources.Enabled {
+		declaredClusterStateChannel := make(chan types.ClusterState)
+		go crd.Track(k8sClient, cmd.customResources, clusterStateChannel, declaredClusterStateChannel)
+		clusterStateChannel = declaredClusterStateChannel
+	}
+	go container.PolicyExplainer.Track(clusterStateChannel, trackedClusterStateChannel)
+	refreshes := make(chan struct{}, 1)
+	cmd.exposition.Refreshes = refreshes
+	gatedClusterStateChannel := make(chan types.ClusterState)
+	go trigger.Gate(cmd.trigger, refreshes, trackedClusterStateChannel, gatedClusterStateChannel)
+	shardsChannel := make(chan types.NamespaceRoutes)
+	cmd.exposition.NamespaceRoutes = shardsChannel
+	go container.AnalysisScheduler.AnalyzeOnClusterStateChange(gatedClusterStateChannel, analysisResultsChannel,
+		shardsChannel)
+	return analysisResultsChannel
 }
 
-func parseCmd() (bool, string) {
+func parseCmd() commandLine {
 	versionFlag := flag.Bool("version", false, "prints Karto's current version")
+	k8sConfigPath := flag.String("kubeconfig", defaultK8sConfigPath(),
+		"(optional) absolute path to the kubeconfig file")
+	verify := flag.Bool("verify", false,
+		"(optional) periodically verify a sample of routes by launching short-lived probe pods")
+	verifyInterval := flag.Duration("verifyInterval", 10*time.Minute,
+		"(optional) interval between two verifications")
+	verifySampleSize := flag.Int("verifySampleSize", 5,
+		"(optional) number of allowed and denied routes verified each time")
+	verifyProbeImage := flag.String("verifyProbeImage", "",
+		"(optional) image of the probe pods, which must provide nc, busybox:1.36 if not set")
+	disableFrontend := flag.Bool("disableFrontend", false,
+		"(optional) do not serve the embedded web UI, only the API")
+	rateLimit := flag.Float64("rateLimit", 0,
+		"(optional) maximum number of API requests per second and per client (role token or IP), 0 to disable")
+	rateLimitBurst := flag.Int("rateLimitBurst", 10,
+		"(optional) number of API requests a client can burst above the rate limit")
+	simulationConcurrency := flag.Int("simulationConcurrency", 2,
+		"(optional) maximum number of simulations run at once, the others being queued, 0 to disable")
+	simulationQueueTimeout := flag.Duration("simulationQueueTimeout", 10*time.Second,
+		"(optional) maximum time a simulation waits in queue before being rejected")
+	simulationQuota := flag.Int("simulationQuota", 0,
+		"(optional) maximum number of simulations per minute and per client (role token or IP), 0 to disable")
+	requestTimeout := flag.Duration("requestTimeout", time.Minute,
+		"(optional) maximum duration of an API request, after which its computation is abandoned, 0 to disable")
+	omitEmpty := flag.Bool("omitEmpty", false,
+		"(optional) omit null values and empty collections from API responses, unless requested with ?omitEmpty=false")
+	configPath := flag.String("config", "", "(optional) path to Karto's YAML configuration file")
+	configInterval := flag.Duration("configInterval", 30*time.Second,
+		"(optional) interval between two reads of the configuration file to reload it on change, 0 to disable")
+	intentsPath := flag.String("intents", "",
+		"(optional) path to a YAML file declaring the intended flows between pods")
+	suppressionsPath := flag.String("suppressionsFile", "",
+		"(optional) path to the file where findings suppressions are persisted, kept in memory if not set")
+	gitRepository := flag.String("gitRepository", "",
+		"(optional) URL of a Git repository of manifests to analyze as the desired state of the cluster")
+	gitBranch := flag.String("gitBranch", "", "(optional) branch of the Git repository, its default one if not set")
+	gitPath := flag.String("gitPath", "", "(optional) directory of the manifests inside the Git repository")
+	gitInterval := flag.Duration("gitInterval", time.Minute, "(optional) interval between two fetches of the Git "+
+		"repository")
+	analyticsDestination := flag.String("analyticsDestination", "",
+		"(optional) directory or s3://bucket/prefix URL where routes and findings are periodically exported as Parquet")
+	analyticsInterval := flag.Duration("analyticsInterval", time.Hour,
+		"(optional) interval between two Parquet exports")
+	archiveDestination := flag.String("archiveDestination", "",
+		"(optional) directory, s3://bucket/prefix URL, or store for the configured store, where compressed snapshots "+
+			"of the analysis are archived")
+	archiveInterval := flag.Duration("archiveInterval", time.Hour, "(optional) interval between two snapshots")
+	archiveRetention := flag.Duration("archiveRetention", 0,
+		"(optional) age after which archived snapshots are deleted, kept forever if not set")
+	routeHistoryRetention := flag.Duration("routeHistoryRetention", 30*24*time.Hour,
+		"(optional) duration after which routes no longer allowed are forgotten by the route history, 0 to keep them")
+	customResources := flag.Bool("crds", false,
+		"(optional) read intents, suppressions and connectivity rules from the KartoConfig and ConnectivityRule "+
+			"custom resources, whose definitions must be installed")
+	customResourcesInterval := flag.Duration("crdInterval", 30*time.Second,
+		"(optional) interval between two listings of the custom resources")
+	findingEvents := flag.Bool("findingEvents", false,
+		"(optional) emit a warning Kubernetes event on the involved policy or namespace for each new high severity "+
+			"finding")
+	analysisMode := flag.String("analysisMode", trigger.ModeWatch,
+		"(optional) watch to analyze the cluster on every change, onDemand to only analyze it on POST /api/refresh "+
+			"or on schedule")
+	analysisSchedule := flag.String("analysisSchedule", "",
+		"(optional) cron expression on which the cluster is analyzed whatever the mode")
+	minAnalysisInterval := flag.Duration("minAnalysisInterval", 0,
+		"(optional) minimum duration between two analyses triggered by changes in watch mode, 0 for no minimum")
+	replica := flag.Bool("replica", false,
+		"(optional) only serve the API from the analysis results published in the postgres store by another instance")
+	replicaInterval := flag.Duration("replicaInterval", 5*time.Second,
+		"(optional) interval between two reads of the analysis results published in the store by replicas")
+	s3Endpoint := flag.String("s3Endpoint", "", "(optional) URL of the S3-compatible endpoint, AWS S3 if not set")
+	s3Region := flag.String("s3Region", "", "(optional) region of the S3 bucket, us-east-1 if not set")
+	flag.Parse()
+
+	return commandLine{
+		versionFlag:      *versionFlag,
+		k8sConfigPath:    *k8sConfigPath,
+		configPath:       *configPath,
+		configInterval:   *configInterval,
+		intentsPath:      *intentsPath,
+		suppressionsPath: *suppressionsPath,
+		replica:          *replica,
+		replicaInterval:  *replicaInterval,
+		gitSource: gitsource.Options{
+			Repository: *gitRepository,
+			Branch:     *gitBranch,
+			Path:       *gitPath,
+			Interval:   *gitInterval,
+		},
+		verification: verification.Options{
+			Enabled:    *verify,
+			Interval:   *verifyInterval,
+			SampleSize: *verifySampleSize,
+			ProbeImage: *verifyProbeImage,
+		},
+		analytics: analytics.Options{
+			Destination: *analyticsDestination,
+			Interval:    *analyticsInterval,
+		},
+		archive: archive.Options{
+			Destination: *archiveDestination,
+			Interval:    *archiveInterval,
+			Retention:   *archiveRetention,
+		},
+		routeHistory: routehistory.Options{
+			Retention: *routeHistoryRetention,
+		},
+		customResources: crd.Options{
+			Enabled:  *customResources,
+			Interval: *customResourcesInterval,
+		},
+		findingEvents: *findingEvents,
+		trigger: trigger.Options{
+			Mode:        *analysisMode,
+			Schedule:    *analysisSchedule,
+			MinInterval: *minAnalysisInterval,
+		},
+		s3: objectstore.S3Options{
+			Endpoint: *s3Endpoint,
+			Region:   *s3Region,
+		},
+		exposition: exposition.Options{
+			DisableFrontend: *disableFrontend,
+			RateLimit: exposition.RateLimitOptions{
+				RequestsPerSecond: *rateLimit,
+				Burst:             *rateLimitBurst,
+			},
+			Simulation: exposition.SimulationOptions{
+				MaxConcurrent: *simulationConcurrency,
+				QueueTimeout:  *simulationQueueTimeout,
+				CallerQuota:   *simulationQuota,
+			},
+			Encoding: exposition.EncodingOptions{
+				OmitEmpty: *omitEmpty,
+			},
+			RequestTimeout: *requestTimeout,
+		},
+	}
+}
+
+func defaultK8sConfigPath() string {
 	home := os.Getenv("HOME")
 	if home == "" {
 		home = os.Getenv("USERPROFILE")
 	}
-	var k8sConfigPath *string
-	if home != "" {
-		k8sConfigPath = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"(optional) absolute path to the kubeconfig file")
-	} else {
-		k8sConfigPath = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+	if home == "" {
+		return ""
 	}
-	flag.Parse()
-
-	return *versionFlag, *k8sConfigPath
+	return filepath.Join(home, ".kube", "config")
 }
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"karto/types"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// Static manifests declare workloads rather than pods, each workload is represented by a single pod built from its
+// template so that the analysis can resolve routes between them
+func Expand(clusterState types.ClusterState) types.ClusterState {
+	expanded := clusterState
+	expanded.Pods = append([]*corev1.Pod{}, clusterState.Pods...)
+	for _, deployment := range clusterState.Deployments {
+		expanded.Pods = append(expanded.Pods, podFromTemplate(deployment.ObjectMeta, deployment.Spec.Template))
+	}
+	for _, statefulSet := range clusterState.StatefulSets {
+		expanded.Pods = append(expanded.Pods, podFromTemplate(statefulSet.ObjectMeta, statefulSet.Spec.Template))
+	}
+	for _, daemonSet := range clusterState.DaemonSets {
+		expanded.Pods = append(expanded.Pods, podFromTemplate(daemonSet.ObjectMeta, daemonSet.Spec.Template))
+	}
+	for _, replicaSet := range clusterState.ReplicaSets {
+		if metav1.GetControllerOf(replicaSet) == nil {
+			expanded.Pods = append(expanded.Pods, podFromTemplate(replicaSet.ObjectMeta, replicaSet.Spec.Template))
+		}
+	}
+	expanded.Namespaces = append([]*corev1.Namespace{}, clusterState.Namespaces...)
+	declaredNamespaces := make(map[string]bool)
+	for _, namespace := range clusterState.Namespaces {
+		declaredNamespaces[namespace.Name] = true
+	}
+	for _, pod := range expanded.Pods {
+		if !declaredNamespaces[pod.Namespace] {
+			declaredNamespaces[pod.Namespace] = true
+			expanded.Namespaces = append(expanded.Namespaces, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   pod.Namespace,
+					Labels: map[string]string{namespaceNameLabel: pod.Namespace},
+				},
+			})
+		}
+	}
+	return expanded
+}
+
+func podFromTemplate(workloadMeta metav1.ObjectMeta, template corev1.PodTemplateSpec) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workloadMeta.Name,
+			Namespace: workloadMeta.Namespace,
+			Labels:    template.Labels,
+		},
+		Spec: template.Spec,
+	}
+}
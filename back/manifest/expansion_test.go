@@ -0,0 +1,45 @@
+package manifest
+
+import (
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	deployment := testutils.NewDeploymentBuilder().WithName("front").WithNamespace("shop").Build()
+	deployment.Spec.Template.Labels = map[string]string{"app": "front"}
+	clusterState := types.ClusterState{
+		Namespaces:  []*corev1.Namespace{testutils.NewNamespaceBuilder().WithName("other").Build()},
+		Pods:        []*corev1.Pod{testutils.NewPodBuilder().WithName("standalone").WithNamespace("other").Build()},
+		Deployments: []*appsv1.Deployment{deployment},
+	}
+	expanded := Expand(clusterState)
+	podRefs := make([]types.PodRef, 0)
+	for _, pod := range expanded.Pods {
+		podRefs = append(podRefs, types.PodRef{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	expectedPodRefs := []types.PodRef{
+		{Name: "standalone", Namespace: "other"},
+		{Name: "front", Namespace: "shop"},
+	}
+	if diff := cmp.Diff(expectedPodRefs, podRefs); diff != "" {
+		t.Errorf("Expand() pods mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"app": "front"}, expanded.Pods[1].Labels); diff != "" {
+		t.Errorf("Expand() pods should carry the labels of their template (-want +got):\n%s", diff)
+	}
+	namespaceNames := make([]string, 0)
+	for _, namespace := range expanded.Namespaces {
+		namespaceNames = append(namespaceNames, namespace.Name)
+	}
+	if diff := cmp.Diff([]string{"other", "shop"}, namespaceNames); diff != "" {
+		t.Errorf("Expand() undeclared namespaces should be added (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(clusterState.Pods)); diff != "" {
+		t.Errorf("Expand() should not modify the given cluster state (-want +got):\n%s", diff)
+	}
+}
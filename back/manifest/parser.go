@@ -0,0 +1,175 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"karto/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+func LoadFile(path string) (types.ClusterState, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return types.ClusterState{}, err
+	}
+	clusterState, err := Parse(content)
+	if err != nil {
+		return types.ClusterState{}, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return clusterState, nil
+}
+
+func Parse(content []byte) (types.ClusterState, error) {
+	clusterState := types.ClusterState{}
+	reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			return clusterState, nil
+		}
+		if err != nil {
+			return types.ClusterState{}, err
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		object, _, err := scheme.Codecs.UniversalDeserializer().Decode(document, nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			// Custom resources have no impact on the analysis
+			continue
+		}
+		if err != nil {
+			return types.ClusterState{}, err
+		}
+		add(&clusterState, object)
+	}
+}
+
+func add(clusterState *types.ClusterState, object runtime.Object) {
+	if namespacedObject, ok := object.(metav1.Object); ok && namespacedObject.GetNamespace() == "" {
+		// As with kubectl, objects without namespace are applied to the default one
+		namespacedObject.SetNamespace(metav1.NamespaceDefault)
+	}
+	switch typedObject := object.(type) {
+	case *corev1.List:
+		for _, item := range typedObject.Items {
+			itemObject, _, err := scheme.Codecs.UniversalDeserializer().Decode(item.Raw, nil, nil)
+			if err == nil {
+				add(clusterState, itemObject)
+			}
+		}
+	case *corev1.Namespace:
+		typedObject.Namespace = ""
+		clusterState.Namespaces = append(clusterState.Namespaces, typedObject)
+	case *corev1.Node:
+		typedObject.Namespace = ""
+		clusterState.Nodes = append(clusterState.Nodes, typedObject)
+	case *corev1.Pod:
+		clusterState.Pods = append(clusterState.Pods, typedObject)
+	case *corev1.Service:
+		clusterState.Services = append(clusterState.Services, typedObject)
+	case *networkingv1beta1.Ingress:
+		clusterState.Ingresses = append(clusterState.Ingresses, typedObject)
+	case *appsv1.ReplicaSet:
+		clusterState.ReplicaSets = append(clusterState.ReplicaSets, typedObject)
+	case *appsv1.StatefulSet:
+		clusterState.StatefulSets = append(clusterState.StatefulSets, typedObject)
+	case *appsv1.DaemonSet:
+		clusterState.DaemonSets = append(clusterState.DaemonSets, typedObject)
+	case *appsv1.Deployment:
+		clusterState.Deployments = append(clusterState.Deployments, typedObject)
+	case *networkingv1.NetworkPolicy:
+		setPolicyDefaults(typedObject)
+		clusterState.NetworkPolicies = append(clusterState.NetworkPolicies, typedObject)
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		typedObject.Namespace = ""
+		clusterState.ValidatingWebhookConfigurations = append(clusterState.ValidatingWebhookConfigurations,
+			typedObject)
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		typedObject.Namespace = ""
+		clusterState.MutatingWebhookConfigurations = append(clusterState.MutatingWebhookConfigurations, typedObject)
+	}
+	// Other kinds have no impact on the analysis and are ignored
+}
+
+func LoadDirectory(path string) (types.ClusterState, error) {
+	clusterState := types.ClusterState{}
+	err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !manifestExtensions[strings.ToLower(filepath.Ext(filePath))] {
+			return nil
+		}
+		fileClusterState, err := LoadFile(filePath)
+		if err != nil {
+			return err
+		}
+		clusterState = merge(clusterState, fileClusterState)
+		return nil
+	})
+	if err != nil {
+		return types.ClusterState{}, err
+	}
+	return clusterState, nil
+}
+
+func merge(clusterState1 types.ClusterState, clusterState2 types.ClusterState) types.ClusterState {
+	return types.ClusterState{
+		Namespaces:      append(clusterState1.Namespaces, clusterState2.Namespaces...),
+		Nodes:           append(clusterState1.Nodes, clusterState2.Nodes...),
+		Pods:            append(clusterState1.Pods, clusterState2.Pods...),
+		Services:        append(clusterState1.Services, clusterState2.Services...),
+		Ingresses:       append(clusterState1.Ingresses, clusterState2.Ingresses...),
+		ReplicaSets:     append(clusterState1.ReplicaSets, clusterState2.ReplicaSets...),
+		StatefulSets:    append(clusterState1.StatefulSets, clusterState2.StatefulSets...),
+		DaemonSets:      append(clusterState1.DaemonSets, clusterState2.DaemonSets...),
+		Deployments:     append(clusterState1.Deployments, clusterState2.Deployments...),
+		NetworkPolicies: append(clusterState1.NetworkPolicies, clusterState2.NetworkPolicies...),
+		ValidatingWebhookConfigurations: append(clusterState1.ValidatingWebhookConfigurations,
+			clusterState2.ValidatingWebhookConfigurations...),
+		MutatingWebhookConfigurations: append(clusterState1.MutatingWebhookConfigurations,
+			clusterState2.MutatingWebhookConfigurations...),
+	}
+}
+
+// Defaults are usually applied by the API server, the analysis relies on them
+func setPolicyDefaults(policy *networkingv1.NetworkPolicy) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		if len(policy.Spec.Egress) > 0 {
+			policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		}
+	}
+	tcp := corev1.ProtocolTCP
+	for _, ingressRule := range policy.Spec.Ingress {
+		for i := range ingressRule.Ports {
+			if ingressRule.Ports[i].Protocol == nil {
+				ingressRule.Ports[i].Protocol = &tcp
+			}
+		}
+	}
+	for _, egressRule := range policy.Spec.Egress {
+		for i := range egressRule.Ports {
+			if egressRule.Ports[i].Protocol == nil {
+				egressRule.Ports[i].Protocol = &tcp
+			}
+		}
+	}
+}
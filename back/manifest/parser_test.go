@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: shop
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+spec:
+  podSelector: {}
+---
+apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: ignored
+---
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "front", "namespace": "shop"}}
+`
+	clusterState, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(1, len(clusterState.Namespaces)); diff != "" {
+		t.Errorf("Parse() namespaces mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("", clusterState.Namespaces[0].Namespace); diff != "" {
+		t.Errorf("Parse() cluster scoped objects should not have a namespace (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("default", clusterState.NetworkPolicies[0].Namespace); diff != "" {
+		t.Errorf("Parse() objects without namespace should be in the default one (-want +got):\n%s", diff)
+	}
+	expectedPolicyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if diff := cmp.Diff(expectedPolicyTypes, clusterState.NetworkPolicies[0].Spec.PolicyTypes); diff != "" {
+		t.Errorf("Parse() policy types should be defaulted (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("shop", clusterState.Pods[0].Namespace); diff != "" {
+		t.Errorf("Parse() pod namespace mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("apiVersion: v1\nkind: Pod\nmetadata: [invalid"))
+	if err == nil {
+		t.Errorf("Parse() expected an error for an invalid manifest")
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "nested"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "namespace.yaml"),
+		[]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "nested", "pod.json"),
+		[]byte("{\"apiVersion\": \"v1\", \"kind\": \"Pod\", \"metadata\": {\"name\": \"front\"}}"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0644)
+	clusterState, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(1, len(clusterState.Namespaces)); diff != "" {
+		t.Errorf("LoadDirectory() namespaces mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(clusterState.Pods)); diff != "" {
+		t.Errorf("LoadDirectory() pods mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+type changeKey struct {
+	namespace string
+	operation string
+}
+
+// PolicyChurn counts the changes of the network policies of each namespace, to spot unusual churn
+type PolicyChurn struct {
+	mutex      sync.Mutex
+	changes    map[changeKey]int
+	lastChange map[string]time.Time
+	startedAt  time.Time
+	now        func() time.Time
+}
+
+func NewPolicyChurn() *PolicyChurn {
+	return &PolicyChurn{
+		changes:    make(map[changeKey]int),
+		lastChange: make(map[string]time.Time),
+		startedAt:  time.Now(),
+		now:        time.Now,
+	}
+}
+
+// EventHandler records the changes watched by an informer of network policies. The policies listed when the informer
+// starts are not counted as creations, they only tell when their namespace last changed.
+func (churn *PolicyChurn) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			policy, ok := obj.(*networkingv1.NetworkPolicy)
+			if !ok {
+				return
+			}
+			createdAt := policy.CreationTimestamp.Time
+			if createdAt.Before(churn.startedAt) {
+				churn.observe(policy.Namespace, createdAt)
+				return
+			}
+			churn.record(policy.Namespace, OperationCreate)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPolicy, oldOk := oldObj.(*networkingv1.NetworkPolicy)
+			newPolicy, newOk := newObj.(*networkingv1.NetworkPolicy)
+			if !oldOk || !newOk {
+				return
+			}
+			// Only changes of the rules or of the labels matter, not those of the status or of managed fields
+			if equality.Semantic.DeepEqual(oldPolicy.Spec, newPolicy.Spec) &&
+				equality.Semantic.DeepEqual(oldPolicy.Labels, newPolicy.Labels) {
+				return
+			}
+			churn.record(newPolicy.Namespace, OperationUpdate)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			policy, ok := obj.(*networkingv1.NetworkPolicy)
+			if !ok {
+				return
+			}
+			churn.record(policy.Namespace, OperationDelete)
+		},
+	}
+}
+
+func (churn *PolicyChurn) record(namespace string, operation string) {
+	churn.mutex.Lock()
+	defer churn.mutex.Unlock()
+	churn.changes[changeKey{namespace: namespace, operation: operation}]++
+	churn.lastChange[namespace] = churn.now()
+}
+
+func (churn *PolicyChurn) observe(namespace string, changedAt time.Time) {
+	churn.mutex.Lock()
+	defer churn.mutex.Unlock()
+	if changedAt.After(churn.lastChange[namespace]) {
+		churn.lastChange[namespace] = changedAt
+	}
+}
+
+// WriteText writes the metrics in the text format of Prometheus
+func (churn *PolicyChurn) WriteText(w io.Writer) error {
+	churn.mutex.Lock()
+	defer churn.mutex.Unlock()
+	keys := make([]changeKey, 0)
+	for key := range churn.changes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].operation < keys[j].operation
+	})
+	namespaces := make([]string, 0)
+	for namespace := range churn.lastChange {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	lines := []string{
+		"# HELP karto_network_policy_changes_total Network policy creations, updates and deletions per namespace.",
+		"# TYPE karto_network_policy_changes_total counter",
+	}
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("karto_network_policy_changes_total{namespace=%q,operation=%q} %d",
+			key.namespace, key.operation, churn.changes[key]))
+	}
+	lines = append(lines,
+		"# HELP karto_network_policy_seconds_since_last_change Seconds since a network policy of the namespace changed.",
+		"# TYPE karto_network_policy_seconds_since_last_change gauge")
+	now := churn.now()
+	for _, namespace := range namespaces {
+		lines = append(lines, fmt.Sprintf("karto_network_policy_seconds_since_last_change{namespace=%q} %g",
+			namespace, now.Sub(churn.lastChange[namespace]).Seconds()))
+	}
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
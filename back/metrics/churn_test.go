@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"karto/testutils"
+	"testing"
+	"time"
+)
+
+func TestPolicyChurn(t *testing.T) {
+	startedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	now := startedAt
+	churn := NewPolicyChurn()
+	churn.startedAt = startedAt
+	churn.now = func() time.Time { return now }
+	handler := churn.EventHandler()
+	existing := testutils.NewNetworkPolicyBuilder().WithName("existing").WithNamespace("shop").Build()
+	existing.CreationTimestamp = metav1.NewTime(startedAt.Add(-time.Hour))
+	handler.OnAdd(existing)
+	now = startedAt.Add(time.Minute)
+	created := testutils.NewNetworkPolicyBuilder().WithName("created").WithNamespace("dev").Build()
+	created.CreationTimestamp = metav1.NewTime(now)
+	handler.OnAdd(created)
+	relabelled := existing.DeepCopy()
+	relabelled.Labels = map[string]string{"team": "shop"}
+	handler.OnUpdate(existing, relabelled)
+	// A change of the metadata alone is not counted
+	handler.OnUpdate(relabelled, relabelled.DeepCopy())
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "dev/created", Obj: created})
+	now = startedAt.Add(2 * time.Minute)
+	var text bytes.Buffer
+	err := churn.WriteText(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedText := "# HELP karto_network_policy_changes_total Network policy creations, updates and deletions " +
+		"per namespace.\n" +
+		"# TYPE karto_network_policy_changes_total counter\n" +
+		"karto_network_policy_changes_total{namespace=\"dev\",operation=\"create\"} 1\n" +
+		"karto_network_policy_changes_total{namespace=\"dev\",operation=\"delete\"} 1\n" +
+		"karto_network_policy_changes_total{namespace=\"shop\",operation=\"update\"} 1\n" +
+		"# HELP karto_network_policy_seconds_since_last_change Seconds since a network policy of the namespace " +
+		"changed.\n" +
+		"# TYPE karto_network_policy_seconds_since_last_change gauge\n" +
+		"karto_network_policy_seconds_since_last_change{namespace=\"dev\"} 60\n" +
+		"karto_network_policy_seconds_since_last_change{namespace=\"shop\"} 60\n"
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("WriteText() result mismatch (-want +got):\n%s", diff)
+	}
+}
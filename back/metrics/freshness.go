@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"k8s.io/client-go/tools/cache"
+	"karto/types"
+	"sync"
+	"time"
+)
+
+type watchedKind struct {
+	informer  cache.SharedInformer
+	lastEvent time.Time
+	// failedAt is the resourceVersion the watch failed at, until the informer lists or watches a newer one
+	failedAt string
+	failed   bool
+}
+
+// DataFreshness follows the watches of the informers, so that clients can warn that the data may be stale while the
+// API server is disrupted, no new analysis happening meanwhile
+type DataFreshness struct {
+	mutex sync.Mutex
+	kinds map[string]*watchedKind
+	now   func() time.Time
+}
+
+func NewDataFreshness() *DataFreshness {
+	return &DataFreshness{
+		kinds: make(map[string]*watchedKind),
+		now:   time.Now,
+	}
+}
+
+// Track must be called before the informer is started, its watch errors being handled from then on
+func (freshness *DataFreshness) Track(kind string, informer cache.SharedInformer) error {
+	watched := &watchedKind{informer: informer}
+	freshness.mutex.Lock()
+	freshness.kinds[kind] = watched
+	freshness.mutex.Unlock()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { freshness.observe(watched) },
+		UpdateFunc: func(oldObj, newObj interface{}) { freshness.observe(watched) },
+		DeleteFunc: func(obj interface{}) { freshness.observe(watched) },
+	})
+	return informer.SetWatchErrorHandler(func(reflector *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(reflector, err)
+		freshness.mutex.Lock()
+		defer freshness.mutex.Unlock()
+		watched.failed = true
+		watched.failedAt = informer.LastSyncResourceVersion()
+	})
+}
+
+// An event proves the watch works again, as do lists, even of kinds without objects, through their resourceVersion
+func (freshness *DataFreshness) observe(watched *watchedKind) {
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+	watched.lastEvent = freshness.now()
+	watched.failed = false
+}
+
+func (freshness *DataFreshness) Snapshot() map[string]*types.ResourceFreshness {
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+	snapshot := make(map[string]*types.ResourceFreshness)
+	for kind, watched := range freshness.kinds {
+		if watched.failed && watched.informer.LastSyncResourceVersion() != watched.failedAt {
+			watched.failed = false
+		}
+		snapshot[kind] = &types.ResourceFreshness{
+			Synced:    watched.informer.HasSynced() && !watched.failed,
+			LastEvent: watched.lastEvent,
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+type fakeInformer struct {
+	cache.SharedInformer
+	handler           cache.ResourceEventHandler
+	watchErrorHandler cache.WatchErrorHandler
+	synced            bool
+	resourceVersion   string
+}
+
+func (informer *fakeInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	informer.handler = handler
+}
+
+func (informer *fakeInformer) SetWatchErrorHandler(handler cache.WatchErrorHandler) error {
+	informer.watchErrorHandler = handler
+	return nil
+}
+
+func (informer *fakeInformer) HasSynced() bool {
+	return informer.synced
+}
+
+func (informer *fakeInformer) LastSyncResourceVersion() string {
+	return informer.resourceVersion
+}
+
+func TestDataFreshness(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	freshness := NewDataFreshness()
+	freshness.now = func() time.Time { return now }
+	pods := &fakeInformer{synced: true, resourceVersion: "10"}
+	ingresses := &fakeInformer{synced: true, resourceVersion: "10"}
+	nodes := &fakeInformer{}
+	for kind, informer := range map[string]*fakeInformer{"pods": pods, "ingresses": ingresses, "nodes": nodes} {
+		err := freshness.Track(kind, informer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	reflector := cache.NewReflector(&cache.ListWatch{}, &corev1.Pod{}, cache.NewStore(cache.MetaNamespaceKeyFunc), 0)
+	watchError := errors.New("connection refused")
+	pods.handler.OnAdd(&corev1.Pod{})
+	pods.watchErrorHandler(reflector, watchError)
+	ingresses.watchErrorHandler(reflector, watchError)
+	expectedSnapshot := map[string]*types.ResourceFreshness{
+		"pods":      {Synced: false, LastEvent: now},
+		"ingresses": {Synced: false},
+		"nodes":     {Synced: false},
+	}
+	if diff := cmp.Diff(expectedSnapshot, freshness.Snapshot()); diff != "" {
+		t.Errorf("Snapshot() result mismatch while the watches fail (-want +got):\n%s", diff)
+	}
+	// Once the watches recover, pods receive the events of their relist and ingresses, without any object, a newer
+	// resourceVersion
+	now = now.Add(time.Minute)
+	pods.handler.OnUpdate(&corev1.Pod{}, &corev1.Pod{})
+	ingresses.resourceVersion = "12"
+	expectedSnapshot = map[string]*types.ResourceFreshness{
+		"pods":      {Synced: true, LastEvent: now},
+		"ingresses": {Synced: true},
+		"nodes":     {Synced: false},
+	}
+	if diff := cmp.Diff(expectedSnapshot, freshness.Snapshot()); diff != "" {
+		t.Errorf("Snapshot() result mismatch once the watches recover (-want +got):\n%s", diff)
+	}
+}
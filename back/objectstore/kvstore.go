@@ -0,0 +1,39 @@
+package objectstore
+
+import (
+	"karto/store"
+	"strings"
+)
+
+type kvStore struct {
+	store      store.Store
+	collection string
+}
+
+// NewKVStore keeps objects in a collection of the configured store, for installations centralizing their state
+func NewKVStore(sharedStore store.Store, collection string) Store {
+	return kvStore{store: sharedStore, collection: collection}
+}
+
+func (kvStore kvStore) Put(key string, content []byte) error {
+	return kvStore.store.Put(kvStore.collection, key, content)
+}
+
+func (kvStore kvStore) List(prefix string) ([]string, error) {
+	keys, err := kvStore.store.Keys(kvStore.collection)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+func (kvStore kvStore) Delete(key string) error {
+	_, err := kvStore.store.Delete(kvStore.collection, key)
+	return err
+}
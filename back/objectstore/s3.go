@@ -0,0 +1,225 @@
+package objectstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Endpoint = "https://s3.amazonaws.com"
+	defaultS3Region   = "us-east-1"
+	amzDateFormat     = "20060102T150405Z"
+	amzDayFormat      = "20060102"
+	signingAlgorithm  = "AWS4-HMAC-SHA256"
+	maxErrorBodyBytes = 1024
+)
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type S3Options struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+type s3Store struct {
+	options    S3Options
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Store uses path-style requests signed with AWS signature V4, which every S3-compatible endpoint understands.
+// Missing credentials are read from the usual AWS environment variables.
+func NewS3Store(options S3Options) (Store, error) {
+	if options.Endpoint == "" {
+		options.Endpoint = defaultS3Endpoint
+	}
+	if options.Region == "" {
+		options.Region = defaultS3Region
+	}
+	if options.AccessKeyID == "" {
+		options.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		options.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		options.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if options.AccessKeyID == "" || options.SecretAccessKey == "" {
+		return nil, fmt.Errorf("missing credentials for bucket %s", options.Bucket)
+	}
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{
+		options:    options,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: time.Minute},
+		now:        time.Now,
+	}, nil
+}
+
+func (store *s3Store) Put(key string, content []byte) error {
+	_, err := store.send(http.MethodPut, store.objectURL(key), content, "upload "+key)
+	return err
+}
+
+func (store *s3Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {strings.TrimLeft(store.objectKey(prefix), "/")}}
+	for {
+		body, err := store.send(http.MethodGet, store.bucketURL()+"?"+canonicalQueryOf(query), nil, "list "+prefix)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.Unmarshal(body, &result)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(object.Key, store.objectKey("")))
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (store *s3Store) Delete(key string) error {
+	_, err := store.send(http.MethodDelete, store.objectURL(key), nil, "delete "+key)
+	return err
+}
+
+func (store *s3Store) send(method string, requestURL string, content []byte, action string) ([]byte, error) {
+	request, err := http.NewRequest(method, requestURL, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	store.sign(request, content)
+	response, err := store.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodyBytes))
+		return nil, fmt.Errorf("unable to %s: %s %s", action, response.Status, strings.TrimSpace(string(body)))
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+func (store *s3Store) objectKey(key string) string {
+	objectKey := strings.TrimLeft(key, "/")
+	if store.options.Prefix != "" {
+		objectKey = store.options.Prefix + "/" + objectKey
+	}
+	return objectKey
+}
+
+func (store *s3Store) bucketURL() string {
+	return strings.TrimRight(store.endpoint.String(), "/") + "/" + uriEncode(store.options.Bucket)
+}
+
+func (store *s3Store) objectURL(key string) string {
+	segments := strings.Split(store.objectKey(key), "/")
+	for i, segment := range segments {
+		// The path is sent as it is signed, with every reserved character escaped
+		segments[i] = uriEncode(segment)
+	}
+	return store.bucketURL() + "/" + strings.Join(segments, "/")
+}
+
+func (store *s3Store) sign(request *http.Request, payload []byte) {
+	now := store.now().UTC()
+	payloadHash := sha256.Sum256(payload)
+	request.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if store.options.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", store.options.SessionToken)
+	}
+	scope := strings.Join([]string{now.Format(amzDayFormat), store.options.Region, "s3", "aws4_request"}, "/")
+	signedHeaders, canonicalRequest := canonicalRequestOf(request, hex.EncodeToString(payloadHash[:]))
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signingAlgorithm, now.Format(amzDateFormat), scope,
+		hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+store.options.SecretAccessKey), now.Format(amzDayFormat))
+	for _, part := range []string{store.options.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, store.options.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// Every header set on the request, and the host, are signed
+func canonicalRequestOf(request *http.Request, payloadHash string) (string, string) {
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{request.Method, request.URL.EscapedPath(),
+		canonicalQueryOf(request.URL.Query()), canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	return signedHeaders, canonicalRequest
+}
+
+func canonicalQueryOf(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parameters := make([]string, 0)
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parameters = append(parameters, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parameters, "&")
+}
+
+func uriEncode(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package objectstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const s3Scheme = "s3"
+
+type Store interface {
+	Put(key string, content []byte) error
+	List(prefix string) ([]string, error)
+	Delete(key string) error
+}
+
+type fileStore struct {
+	directory string
+}
+
+// New returns a store writing to a local directory, or to a bucket of an S3-compatible endpoint when the destination
+// is an s3://bucket/prefix URL
+func New(destination string, s3Options S3Options) (Store, error) {
+	if !strings.HasPrefix(destination, s3Scheme+"://") {
+		return NewFileStore(destination), nil
+	}
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return nil, err
+	}
+	if destinationURL.Host == "" {
+		return nil, fmt.Errorf("missing bucket in %s", destination)
+	}
+	s3Options.Bucket = destinationURL.Host
+	s3Options.Prefix = strings.Trim(destinationURL.Path, "/")
+	return NewS3Store(s3Options)
+}
+
+func NewFileStore(directory string) Store {
+	return fileStore{directory: directory}
+}
+
+func (store fileStore) Put(key string, content []byte) error {
+	filePath := store.pathOf(key)
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+	// Written aside then renamed, so that readers never see a partial object
+	temporaryPath := filePath + ".tmp"
+	err = ioutil.WriteFile(temporaryPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, filePath)
+}
+
+func (store fileStore) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	err := filepath.Walk(store.directory, func(filePath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(store.directory, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativePath)
+		if strings.HasPrefix(key, prefix) && !strings.HasSuffix(key, ".tmp") {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (store fileStore) Delete(key string) error {
+	err := os.Remove(store.pathOf(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (store fileStore) pathOf(key string) string {
+	return filepath.Join(store.directory, filepath.FromSlash(path.Clean("/"+key)))
+}
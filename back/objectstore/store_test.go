@@ -0,0 +1,109 @@
+package objectstore
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		destination string
+		s3Options   S3Options
+	}
+	tests := []struct {
+		name          string
+		args          args
+		expectedStore Store
+		expectedError string
+	}{
+		{
+			name: "a path is a local directory",
+			args: args{
+				destination: "/var/lib/karto",
+			},
+			expectedStore: fileStore{directory: "/var/lib/karto"},
+		},
+		{
+			name: "an s3 URL is a bucket and a prefix",
+			args: args{
+				destination: "s3://bucket/karto/exports/",
+				s3Options:   S3Options{AccessKeyID: "key", SecretAccessKey: "secret"},
+			},
+			expectedStore: &s3Store{options: S3Options{Endpoint: defaultS3Endpoint, Region: defaultS3Region,
+				Bucket: "bucket", Prefix: "karto/exports", AccessKeyID: "key", SecretAccessKey: "secret"}},
+		},
+		{
+			name: "an s3 URL requires a bucket",
+			args: args{
+				destination: "s3:///karto",
+			},
+			expectedError: "missing bucket in s3:///karto",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := New(tt.args.destination, tt.args.s3Options)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Errorf("New() error mismatch (-want +got):\n%s", diff)
+			}
+			if created, ok := store.(*s3Store); ok {
+				store = &s3Store{options: created.options}
+			}
+			if diff := cmp.Diff(tt.expectedStore, store, cmp.AllowUnexported(fileStore{}, s3Store{})); diff != "" {
+				t.Errorf("New() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilePut(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "objectstore")
+	defer func() {
+		_ = os.RemoveAll(directory)
+	}()
+	store := NewFileStore(directory)
+	err := store.Put("routes/../../routes.parquet", []byte("content"))
+	if err != nil {
+		t.Fatalf("Put() returned an error: %s", err)
+	}
+	content, _ := ioutil.ReadFile(filepath.Join(directory, "routes.parquet"))
+	if diff := cmp.Diff("content", string(content)); diff != "" {
+		t.Errorf("Put() content mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileListAndDelete(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "objectstore")
+	defer func() {
+		_ = os.RemoveAll(directory)
+	}()
+	store := NewFileStore(filepath.Join(directory, "karto"))
+	emptyKeys, err := store.List("")
+	if err != nil {
+		t.Fatalf("List() returned an error on a missing directory: %s", err)
+	}
+	if diff := cmp.Diff([]string{}, emptyKeys); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	for _, key := range []string{"snapshots/a.json.gz", "snapshots/b.json.gz", "routes/c.parquet"} {
+		_ = store.Put(key, []byte("content"))
+	}
+	err = store.Delete("snapshots/a.json.gz")
+	if err != nil {
+		t.Fatalf("Delete() returned an error: %s", err)
+	}
+	keys, err := store.List("snapshots/")
+	if err != nil {
+		t.Fatalf("List() returned an error: %s", err)
+	}
+	if diff := cmp.Diff([]string{"snapshots/b.json.gz"}, keys); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,232 @@
+package orderedpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strconv"
+	"strings"
+)
+
+type calicoPolicyList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Order    *float64     `json:"order"`
+			Selector string       `json:"selector"`
+			Types    []string     `json:"types"`
+			Ingress  []calicoRule `json:"ingress"`
+			Egress   []calicoRule `json:"egress"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type calicoRule struct {
+	Action      string              `json:"action"`
+	Protocol    *intstr.IntOrString `json:"protocol"`
+	Source      calicoEntity        `json:"source"`
+	Destination calicoEntity        `json:"destination"`
+	HTTP        json.RawMessage     `json:"http"`
+	ICMP        json.RawMessage     `json:"icmp"`
+	NotProtocol json.RawMessage     `json:"notProtocol"`
+}
+
+type calicoEntity struct {
+	Selector          string               `json:"selector"`
+	NotSelector       string               `json:"notSelector"`
+	NamespaceSelector string               `json:"namespaceSelector"`
+	Nets              []string             `json:"nets"`
+	NotNets           []string             `json:"notNets"`
+	Ports             []intstr.IntOrString `json:"ports"`
+	NotPorts          json.RawMessage      `json:"notPorts"`
+	ServiceAccounts   json.RawMessage      `json:"serviceAccounts"`
+	Services          json.RawMessage      `json:"services"`
+}
+
+// ParseCalicoPolicies parses a list of Calico NetworkPolicies or GlobalNetworkPolicies of the given kind. Policies
+// with an invalid selector are returned in error, along with the valid ones.
+func ParseCalicoPolicies(content []byte, kind string) ([]*Policy, error) {
+	var list calicoPolicyList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	policies := make([]*Policy, 0, len(list.Items))
+	invalid := make([]string, 0)
+	for _, item := range list.Items {
+		policy := &Policy{
+			Engine:    EngineCalico,
+			Kind:      kind,
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Order:     item.Spec.Order,
+		}
+		err := policy.parseCalicoSpec(item.Spec.Selector, item.Spec.Types, item.Spec.Ingress, item.Spec.Egress)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s %s/%s: %s", kind, policy.Namespace, policy.Name, err))
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	if len(invalid) > 0 {
+		return policies, fmt.Errorf("invalid policies: %s", strings.Join(invalid, ", "))
+	}
+	return policies, nil
+}
+
+func (policy *Policy) parseCalicoSpec(selector string, types []string, ingress []calicoRule,
+	egress []calicoRule) error {
+	var err error
+	if policy.Selector, err = ParseCalicoSelector(selector); err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		policy.Directions = []string{DirectionIngress}
+		if len(egress) > 0 {
+			policy.Directions = append(policy.Directions, DirectionEgress)
+		}
+	}
+	for _, policyType := range types {
+		policy.Directions = append(policy.Directions, strings.ToLower(policyType))
+	}
+	if policy.Ingress, err = calicoRules(DirectionIngress, ingress); err != nil {
+		return err
+	}
+	policy.Egress, err = calicoRules(DirectionEgress, egress)
+	return err
+}
+
+func calicoRules(section string, calicoRules []calicoRule) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(calicoRules))
+	for i, calicoRule := range calicoRules {
+		peer := calicoRule.Source
+		if section == DirectionEgress {
+			peer = calicoRule.Destination
+		}
+		rule := &Rule{
+			Section: section,
+			Index:   i,
+			Action:  calicoRule.Action,
+			Nets:    peer.Nets,
+			NotNets: peer.NotNets,
+			// Unsupported fields narrow what the rule matches, so it is not evaluated
+			Unsupported: unsupportedCalicoField(calicoRule),
+		}
+		peerSelector, err := calicoPeerSelector(peer)
+		if err != nil {
+			return nil, err
+		}
+		rule.Peer = peerSelector
+		if peer.NamespaceSelector != "" {
+			if rule.PeerNamespace, err = ParseCalicoSelector(peer.NamespaceSelector); err != nil {
+				return nil, err
+			}
+		}
+		// Only the selectors of the peer pods of namespaced policies are restricted to the namespace of the policy
+		rule.AnyNamespace = peer.Selector == "" && peer.NotSelector == "" && peer.NamespaceSelector == ""
+		if rule.Ports, err = calicoPorts(calicoRule.Protocol, calicoRule.Destination.Ports); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func unsupportedCalicoField(rule calicoRule) string {
+	fields := []struct {
+		name  string
+		value json.RawMessage
+	}{
+		{"http", rule.HTTP},
+		{"icmp", rule.ICMP},
+		{"notProtocol", rule.NotProtocol},
+		{"source.notPorts", rule.Source.NotPorts},
+		{"source.serviceAccounts", rule.Source.ServiceAccounts},
+		{"source.services", rule.Source.Services},
+		{"destination.notPorts", rule.Destination.NotPorts},
+		{"destination.serviceAccounts", rule.Destination.ServiceAccounts},
+		{"destination.services", rule.Destination.Services},
+	}
+	for _, field := range fields {
+		if present(field.value) {
+			return field.name
+		}
+	}
+	return ""
+}
+
+func present(value json.RawMessage) bool {
+	return len(value) > 0 && string(value) != "null"
+}
+
+func calicoPeerSelector(peer calicoEntity) (Selector, error) {
+	if peer.Selector == "" && peer.NotSelector == "" {
+		return nil, nil
+	}
+	selector, err := ParseCalicoSelector(peer.Selector)
+	if err != nil || peer.NotSelector == "" {
+		return selector, err
+	}
+	notSelector, err := ParseCalicoSelector(peer.NotSelector)
+	if err != nil {
+		return nil, err
+	}
+	return calicoSelector{matches: func(labels map[string]string) bool {
+		return selector.Matches(labels) && !notSelector.Matches(labels)
+	}}, nil
+}
+
+// calicoPorts returns the ports of the protocol, numbers, "first:last" ranges or names, the protocol being given by
+// name or by number
+func calicoPorts(calicoProtocol *intstr.IntOrString, calicoPorts []intstr.IntOrString) ([]Port, error) {
+	var protocol corev1.Protocol
+	if calicoProtocol != nil {
+		protocol = calicoProtocolName(*calicoProtocol)
+	}
+	if len(calicoPorts) == 0 {
+		if protocol == "" {
+			return nil, nil
+		}
+		return []Port{{Protocol: protocol}}, nil
+	}
+	ports := make([]Port, 0, len(calicoPorts))
+	for _, calicoPort := range calicoPorts {
+		if calicoPort.Type == intstr.Int {
+			ports = append(ports, Port{Protocol: protocol, Port: calicoPort.IntVal, EndPort: calicoPort.IntVal})
+			continue
+		}
+		bounds := strings.SplitN(calicoPort.StrVal, ":", 2)
+		if len(bounds) == 1 {
+			ports = append(ports, Port{Protocol: protocol, Name: calicoPort.StrVal})
+			continue
+		}
+		first, err := strconv.ParseInt(bounds[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %s", calicoPort.StrVal)
+		}
+		last, err := strconv.ParseInt(bounds[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %s", calicoPort.StrVal)
+		}
+		ports = append(ports, Port{Protocol: protocol, Port: int32(first), EndPort: int32(last)})
+	}
+	return ports, nil
+}
+
+func calicoProtocolName(protocol intstr.IntOrString) corev1.Protocol {
+	if protocol.Type == intstr.String {
+		return corev1.Protocol(strings.ToUpper(protocol.StrVal))
+	}
+	switch protocol.IntVal {
+	case 6:
+		return corev1.ProtocolTCP
+	case 17:
+		return corev1.ProtocolUDP
+	case 132:
+		return corev1.ProtocolSCTP
+	}
+	return corev1.Protocol(strconv.Itoa(int(protocol.IntVal)))
+}
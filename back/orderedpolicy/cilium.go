@@ -0,0 +1,263 @@
+package orderedpolicy
+
+import (
+	"encoding/json"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strconv"
+	"strings"
+)
+
+// CiliumNamespaceLabel is the label Cilium gives the pods for their namespace
+const CiliumNamespaceLabel = "io.kubernetes.pod.namespace"
+
+// CiliumNamespaceLabelsPrefix prefixes the labels of their namespace Cilium gives the pods
+const CiliumNamespaceLabelsPrefix = "io.cilium.k8s.namespace.labels."
+
+type ciliumPolicyList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec  *ciliumSpec  `json:"spec"`
+		Specs []ciliumSpec `json:"specs"`
+	} `json:"items"`
+}
+
+type ciliumSpec struct {
+	EndpointSelector *metav1.LabelSelector `json:"endpointSelector"`
+	Ingress          []ciliumRule          `json:"ingress"`
+	IngressDeny      []ciliumRule          `json:"ingressDeny"`
+	Egress           []ciliumRule          `json:"egress"`
+	EgressDeny       []ciliumRule          `json:"egressDeny"`
+}
+
+type ciliumRule struct {
+	FromEndpoints []metav1.LabelSelector `json:"fromEndpoints"`
+	ToEndpoints   []metav1.LabelSelector `json:"toEndpoints"`
+	FromEntities  []string               `json:"fromEntities"`
+	ToEntities    []string               `json:"toEntities"`
+	FromCIDR      []string               `json:"fromCIDR"`
+	ToCIDR        []string               `json:"toCIDR"`
+	FromCIDRSet   []ciliumCIDRSet        `json:"fromCIDRSet"`
+	ToCIDRSet     []ciliumCIDRSet        `json:"toCIDRSet"`
+	ToPorts       []struct {
+		Ports []struct {
+			Port     string `json:"port"`
+			EndPort  int32  `json:"endPort"`
+			Protocol string `json:"protocol"`
+		} `json:"ports"`
+		Rules json.RawMessage `json:"rules"`
+	} `json:"toPorts"`
+	FromRequires json.RawMessage `json:"fromRequires"`
+	ToRequires   json.RawMessage `json:"toRequires"`
+	ToFQDNs      json.RawMessage `json:"toFQDNs"`
+	ToServices   json.RawMessage `json:"toServices"`
+}
+
+type ciliumCIDRSet struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except"`
+}
+
+// ParseCiliumPolicies parses a list of CiliumNetworkPolicies or CiliumClusterwideNetworkPolicies of the given kind,
+// every spec of a policy being returned as a policy of its name. Specs selecting nodes rather than endpoints are
+// skipped.
+func ParseCiliumPolicies(content []byte, kind string) ([]*Policy, error) {
+	var list ciliumPolicyList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	policies := make([]*Policy, 0, len(list.Items))
+	for _, item := range list.Items {
+		specs := item.Specs
+		if item.Spec != nil {
+			specs = append([]ciliumSpec{*item.Spec}, specs...)
+		}
+		for _, spec := range specs {
+			if spec.EndpointSelector == nil {
+				continue
+			}
+			policies = append(policies, ciliumPolicy(kind, item.Metadata.Name, item.Metadata.Namespace, spec))
+		}
+	}
+	return policies, nil
+}
+
+func ciliumPolicy(kind string, name string, namespace string, spec ciliumSpec) *Policy {
+	policy := &Policy{
+		Engine:     EngineCilium,
+		Kind:       kind,
+		Name:       name,
+		Namespace:  namespace,
+		Selector:   newLabelSelector(withoutSourcePrefixes(*spec.EndpointSelector)),
+		Directions: make([]string, 0, 2),
+		Ingress:    make([]*Rule, 0),
+		Egress:     make([]*Rule, 0),
+	}
+	sections := []struct {
+		name   string
+		action string
+		rules  []ciliumRule
+	}{
+		{"ingressDeny", ActionDeny, spec.IngressDeny},
+		{"ingress", ActionAllow, spec.Ingress},
+		{"egressDeny", ActionDeny, spec.EgressDeny},
+		{"egress", ActionAllow, spec.Egress},
+	}
+	for _, section := range sections {
+		for i, ciliumRule := range section.rules {
+			rules := ciliumRules(section.name, i, section.action, ciliumRule)
+			if strings.HasPrefix(section.name, DirectionIngress) {
+				policy.Ingress = append(policy.Ingress, rules...)
+			} else {
+				policy.Egress = append(policy.Egress, rules...)
+			}
+		}
+	}
+	if len(spec.Ingress) > 0 || len(spec.IngressDeny) > 0 {
+		policy.Directions = append(policy.Directions, DirectionIngress)
+	}
+	if len(spec.Egress) > 0 || len(spec.EgressDeny) > 0 {
+		policy.Directions = append(policy.Directions, DirectionEgress)
+	}
+	return policy
+}
+
+// ciliumRules splits a rule into one rule per kind of peer. A rule without peers applies to every peer when it has
+// ports, and to none otherwise, the empty rule only turning on the default deny.
+func ciliumRules(section string, index int, action string, ciliumRule ciliumRule) []*Rule {
+	ports := ciliumPorts(ciliumRule)
+	endpoints, entities := ciliumRule.FromEndpoints, ciliumRule.FromEntities
+	cidrs, cidrSets := ciliumRule.FromCIDR, ciliumRule.FromCIDRSet
+	requires := ciliumRule.FromRequires
+	if strings.HasPrefix(section, DirectionEgress) {
+		endpoints, entities = ciliumRule.ToEndpoints, ciliumRule.ToEntities
+		cidrs, cidrSets = ciliumRule.ToCIDR, ciliumRule.ToCIDRSet
+		requires = ciliumRule.ToRequires
+	}
+	unsupported := unsupportedCiliumField(ciliumRule, requires)
+	rules := make([]*Rule, 0)
+	newRule := func() *Rule {
+		return &Rule{Section: section, Index: index, Action: action, Ports: ports, Unsupported: unsupported}
+	}
+	for _, endpoint := range endpoints {
+		selector := withoutSourcePrefixes(endpoint)
+		rule := newRule()
+		rule.Peer = newLabelSelector(selector)
+		rule.AnyNamespace = selectsNamespace(selector)
+		rules = append(rules, rule)
+	}
+	for _, entity := range entities {
+		// Only the entities covering the pods of the cluster are evaluated
+		if entity == "all" || entity == "cluster" {
+			rule := newRule()
+			rule.AnyNamespace = true
+			rules = append(rules, rule)
+		}
+	}
+	for _, cidr := range cidrs {
+		rule := newRule()
+		rule.AnyNamespace = true
+		rule.Nets = []string{cidr}
+		rules = append(rules, rule)
+	}
+	for _, cidrSet := range cidrSets {
+		rule := newRule()
+		rule.AnyNamespace = true
+		rule.Nets = []string{cidrSet.CIDR}
+		rule.NotNets = cidrSet.Except
+		rules = append(rules, rule)
+	}
+	withoutPeers := len(endpoints) == 0 && len(entities) == 0 && len(cidrs) == 0 && len(cidrSets) == 0
+	if withoutPeers && len(ciliumRule.ToPorts) > 0 {
+		rule := newRule()
+		rule.AnyNamespace = true
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func unsupportedCiliumField(ciliumRule ciliumRule, requires json.RawMessage) string {
+	if present(requires) {
+		return "requires"
+	}
+	if present(ciliumRule.ToFQDNs) {
+		return "toFQDNs"
+	}
+	if present(ciliumRule.ToServices) {
+		return "toServices"
+	}
+	for _, toPort := range ciliumRule.ToPorts {
+		if present(toPort.Rules) {
+			return "toPorts.rules"
+		}
+	}
+	return ""
+}
+
+func ciliumPorts(ciliumRule ciliumRule) []Port {
+	ports := make([]Port, 0)
+	for _, toPort := range ciliumRule.ToPorts {
+		for _, ciliumPort := range toPort.Ports {
+			port := Port{}
+			if ciliumPort.Protocol != "" && ciliumPort.Protocol != "ANY" {
+				port.Protocol = corev1.Protocol(ciliumPort.Protocol)
+			}
+			if number, err := strconv.ParseInt(ciliumPort.Port, 10, 32); err == nil {
+				port.Port = int32(number)
+				port.EndPort = port.Port
+				if ciliumPort.EndPort > 0 {
+					port.EndPort = ciliumPort.EndPort
+				}
+			} else if ciliumPort.Port != "" {
+				port.Name = ciliumPort.Port
+			}
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+	return ports
+}
+
+// withoutSourcePrefixes removes the k8s: and any: sources Cilium allows to prefix label keys with
+func withoutSourcePrefixes(selector metav1.LabelSelector) metav1.LabelSelector {
+	unprefixed := metav1.LabelSelector{}
+	if selector.MatchLabels != nil {
+		unprefixed.MatchLabels = make(map[string]string, len(selector.MatchLabels))
+		for key, value := range selector.MatchLabels {
+			unprefixed.MatchLabels[withoutSourcePrefix(key)] = value
+		}
+	}
+	for _, requirement := range selector.MatchExpressions {
+		requirement.Key = withoutSourcePrefix(requirement.Key)
+		unprefixed.MatchExpressions = append(unprefixed.MatchExpressions, requirement)
+	}
+	return unprefixed
+}
+
+func withoutSourcePrefix(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, "k8s:"), "any:")
+}
+
+// selectsNamespace tells whether the selector picks the namespace of the peers, otherwise restricted to that of the
+// policy
+func selectsNamespace(selector metav1.LabelSelector) bool {
+	isNamespaceKey := func(key string) bool {
+		return key == CiliumNamespaceLabel || strings.HasPrefix(key, CiliumNamespaceLabelsPrefix)
+	}
+	for key := range selector.MatchLabels {
+		if isNamespaceKey(key) {
+			return true
+		}
+	}
+	for _, requirement := range selector.MatchExpressions {
+		if isNamespaceKey(requirement.Key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+package orderedpolicy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"net"
+	"sort"
+)
+
+const (
+	VerdictAllow = "allow"
+	VerdictDeny  = "deny"
+	// VerdictPass is given by a Calico Pass rule, which skips the remaining policies to the profiles, allowing the
+	// traffic by default
+	VerdictPass = "pass"
+	// VerdictNone is given when no policy applies to the pod in the direction, which lets the traffic through
+	VerdictNone = "none"
+)
+
+const (
+	calicoNamespaceLabel     = "projectcalico.org/namespace"
+	calicoNamespaceNameLabel = "projectcalico.org/name"
+	namespaceNameLabel       = "kubernetes.io/metadata.name"
+)
+
+// Traffic goes from the source pod to a port of the target pod, 0 standing for any port of the protocol
+type Traffic struct {
+	Source   *corev1.Pod
+	Target   *corev1.Pod
+	Port     int32
+	Protocol corev1.Protocol
+}
+
+type Evaluation struct {
+	Engine    string `json:"engine"`
+	Direction string `json:"direction"`
+	// Policies are those applying to the pod in the direction, by precedence
+	Policies   []PolicyRef `json:"policies"`
+	FirstMatch *RuleMatch  `json:"firstMatch"`
+	// ShadowedRules are the later rules which also match the traffic, but do not apply because of the first match
+	ShadowedRules    []RuleMatch `json:"shadowedRules"`
+	UnsupportedRules []RuleMatch `json:"unsupportedRules"`
+	Verdict          string      `json:"verdict"`
+}
+
+type PolicyRef struct {
+	Engine    string   `json:"engine"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Order     *float64 `json:"order,omitempty"`
+}
+
+type RuleMatch struct {
+	Policy      PolicyRef `json:"policy"`
+	Section     string    `json:"section"`
+	Index       int       `json:"index"`
+	Action      string    `json:"action"`
+	Unsupported string    `json:"unsupported,omitempty"`
+}
+
+// Allowed tells whether the traffic goes through in the direction of the evaluation
+func (evaluation *Evaluation) Allowed() bool {
+	return evaluation.Verdict != VerdictDeny
+}
+
+// Evaluate evaluates the policies of the engine, along with the Kubernetes network policies, in the direction of the
+// traffic: ingress on the target pod from the source one, or egress on the source pod to the target one.
+// Calico evaluates the policies by order, then by name, and the first matching Allow, Deny or Pass rule decides.
+// Cilium evaluates the deny rules of every policy before the allow ones. Traffic selected by policies but matching
+// no rule is denied.
+func Evaluate(engine string, policies []*Policy, direction string, traffic Traffic,
+	namespaces []*corev1.Namespace) *Evaluation {
+	pod, peer := traffic.Target, traffic.Source
+	if direction == DirectionEgress {
+		pod, peer = traffic.Source, traffic.Target
+	}
+	applicable := applicablePolicies(engine, policies, direction, pod, endpointLabels(pod, namespaces))
+	evaluation := &Evaluation{
+		Engine:           engine,
+		Direction:        direction,
+		Policies:         make([]PolicyRef, 0, len(applicable)),
+		ShadowedRules:    make([]RuleMatch, 0),
+		UnsupportedRules: make([]RuleMatch, 0),
+	}
+	for _, policy := range applicable {
+		evaluation.Policies = append(evaluation.Policies, policy.ref())
+	}
+	peerLabels := endpointLabels(peer, namespaces)
+	for _, candidate := range orderedRules(engine, applicable, direction) {
+		match := RuleMatch{
+			Policy:      candidate.policy.ref(),
+			Section:     candidate.rule.Section,
+			Index:       candidate.rule.Index,
+			Action:      candidate.rule.Action,
+			Unsupported: candidate.rule.Unsupported,
+		}
+		if candidate.rule.Unsupported != "" {
+			evaluation.UnsupportedRules = appendMatch(evaluation.UnsupportedRules, match)
+			continue
+		}
+		if !candidate.rule.matches(candidate.policy, peer, peerLabels, traffic, namespaces) {
+			continue
+		}
+		if evaluation.FirstMatch == nil && match.Action != ActionLog {
+			evaluation.FirstMatch = &match
+		} else if evaluation.FirstMatch != nil && match.Action != ActionLog && match != *evaluation.FirstMatch {
+			evaluation.ShadowedRules = appendMatch(evaluation.ShadowedRules, match)
+		}
+	}
+	evaluation.Verdict = verdictOf(evaluation.FirstMatch, len(applicable))
+	return evaluation
+}
+
+func verdictOf(firstMatch *RuleMatch, applicablePolicies int) string {
+	if firstMatch != nil {
+		switch firstMatch.Action {
+		case ActionAllow:
+			return VerdictAllow
+		case ActionPass:
+			return VerdictPass
+		}
+		return VerdictDeny
+	}
+	if applicablePolicies > 0 {
+		return VerdictDeny
+	}
+	return VerdictNone
+}
+
+// appendMatch appends the match unless already there, as for rules split per peer
+func appendMatch(matches []RuleMatch, match RuleMatch) []RuleMatch {
+	for _, existing := range matches {
+		if existing == match {
+			return matches
+		}
+	}
+	return append(matches, match)
+}
+
+func (policy *Policy) ref() PolicyRef {
+	return PolicyRef{
+		Engine:    policy.Engine,
+		Kind:      policy.Kind,
+		Name:      policy.Name,
+		Namespace: policy.Namespace,
+		Order:     policy.Order,
+	}
+}
+
+func (policy *Policy) appliesTo(direction string, pod *corev1.Pod, labels map[string]string) bool {
+	if policy.Namespace != "" && policy.Namespace != pod.Namespace {
+		return false
+	}
+	for _, policyDirection := range policy.Directions {
+		if policyDirection == direction {
+			return policy.Selector.Matches(labels)
+		}
+	}
+	return false
+}
+
+func (policy *Policy) rules(direction string) []*Rule {
+	if direction == DirectionEgress {
+		return policy.Egress
+	}
+	return policy.Ingress
+}
+
+func applicablePolicies(engine string, policies []*Policy, direction string, pod *corev1.Pod,
+	labels map[string]string) []*Policy {
+	applicable := make([]*Policy, 0)
+	for _, policy := range policies {
+		if (policy.Engine == engine || policy.Engine == EngineKubernetes) && policy.appliesTo(direction, pod, labels) {
+			applicable = append(applicable, policy)
+		}
+	}
+	sort.SliceStable(applicable, func(i, j int) bool {
+		first, second := applicable[i], applicable[j]
+		if engine == EngineCalico && !sameOrder(first.Order, second.Order) {
+			return second.Order == nil || (first.Order != nil && *first.Order < *second.Order)
+		}
+		if first.Name != second.Name {
+			return first.Name < second.Name
+		}
+		return first.Namespace < second.Namespace
+	})
+	return applicable
+}
+
+func sameOrder(first *float64, second *float64) bool {
+	if first == nil || second == nil {
+		return first == second
+	}
+	return *first == *second
+}
+
+type orderedRule struct {
+	policy *Policy
+	rule   *Rule
+}
+
+// orderedRules lists the rules of the policies in the order the engine evaluates them
+func orderedRules(engine string, policies []*Policy, direction string) []orderedRule {
+	denyRules := make([]orderedRule, 0)
+	otherRules := make([]orderedRule, 0)
+	for _, policy := range policies {
+		for _, rule := range policy.rules(direction) {
+			if engine == EngineCilium && rule.Action == ActionDeny {
+				denyRules = append(denyRules, orderedRule{policy: policy, rule: rule})
+			} else {
+				otherRules = append(otherRules, orderedRule{policy: policy, rule: rule})
+			}
+		}
+	}
+	return append(denyRules, otherRules...)
+}
+
+func (rule *Rule) matches(policy *Policy, peer *corev1.Pod, peerLabels map[string]string, traffic Traffic,
+	namespaces []*corev1.Namespace) bool {
+	if rule.PeerNamespace != nil {
+		if !rule.PeerNamespace.Matches(namespaceLabels(peer.Namespace, namespaces)) {
+			return false
+		}
+	} else if !rule.AnyNamespace && policy.Namespace != "" && peer.Namespace != policy.Namespace {
+		return false
+	}
+	if rule.Peer != nil && !rule.Peer.Matches(peerLabels) {
+		return false
+	}
+	if (len(rule.Nets) > 0 && !inNets(peer, rule.Nets)) || inNets(peer, rule.NotNets) {
+		return false
+	}
+	return portsMatch(rule.Ports, traffic)
+}
+
+func portsMatch(ports []Port, traffic Traffic) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, port := range ports {
+		if port.Protocol != "" && port.Protocol != traffic.Protocol {
+			continue
+		}
+		if port.Name != "" {
+			if namedPortMatches(traffic, port.Name) {
+				return true
+			}
+			continue
+		}
+		if port.Port == 0 || traffic.Port == 0 || (port.Port <= traffic.Port && traffic.Port <= port.EndPort) {
+			return true
+		}
+	}
+	return false
+}
+
+// namedPortMatches tells whether the name is that of a container port of the target pod for the traffic
+func namedPortMatches(traffic Traffic, name string) bool {
+	for _, container := range traffic.Target.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			protocol := containerPort.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			if containerPort.Name == name && protocol == traffic.Protocol &&
+				(traffic.Port == 0 || containerPort.ContainerPort == traffic.Port) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inNets(pod *corev1.Pod, nets []string) bool {
+	podIPs := make([]string, 0, len(pod.Status.PodIPs)+1)
+	podIPs = append(podIPs, pod.Status.PodIP)
+	for _, podIP := range pod.Status.PodIPs {
+		podIPs = append(podIPs, podIP.IP)
+	}
+	for _, cidr := range nets {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		for _, podIP := range podIPs {
+			if ip := net.ParseIP(podIP); ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// endpointLabels returns the labels of the pod along with those the engines give it for its namespace
+func endpointLabels(pod *corev1.Pod, namespaces []*corev1.Namespace) map[string]string {
+	labels := make(map[string]string, len(pod.Labels)+2)
+	for key, value := range pod.Labels {
+		labels[key] = value
+	}
+	labels[CiliumNamespaceLabel] = pod.Namespace
+	labels[calicoNamespaceLabel] = pod.Namespace
+	for key, value := range namespaceLabels(pod.Namespace, namespaces) {
+		labels[CiliumNamespaceLabelsPrefix+key] = value
+	}
+	return labels
+}
+
+func namespaceLabels(name string, namespaces []*corev1.Namespace) map[string]string {
+	labels := map[string]string{namespaceNameLabel: name, calicoNamespaceNameLabel: name}
+	for _, namespace := range namespaces {
+		if namespace.Name == name {
+			for key, value := range namespace.Labels {
+				labels[key] = value
+			}
+		}
+	}
+	return labels
+}
@@ -0,0 +1,291 @@
+package orderedpolicy
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"karto/testutils"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	front := testutils.NewPodBuilder().WithName("front").WithNamespace("shop").WithLabel("app", "front").
+		WithIP("10.0.0.1").Build()
+	api := testutils.NewPodBuilder().WithName("api").WithNamespace("shop").WithLabel("app", "api").
+		WithContainerPort("http", 8080).Build()
+	monitoring := testutils.NewPodBuilder().WithName("prometheus").WithNamespace("monitoring").
+		WithLabel("app", "prometheus").Build()
+	namespaces := []*corev1.Namespace{
+		testutils.NewNamespaceBuilder().WithName("shop").Build(),
+		testutils.NewNamespaceBuilder().WithName("monitoring").WithLabel("team", "ops").Build(),
+	}
+	order := func(value float64) *float64 {
+		return &value
+	}
+	kubernetesOrder := order(KubernetesOrder)
+	calicoPolicies := func(kind string, content string) []*Policy {
+		policies, err := ParseCalicoPolicies([]byte(content), kind)
+		if err != nil {
+			t.Fatalf("ParseCalicoPolicies() error = %v", err)
+		}
+		return policies
+	}
+	ciliumPolicies := func(kind string, content string) []*Policy {
+		policies, err := ParseCiliumPolicies([]byte(content), kind)
+		if err != nil {
+			t.Fatalf("ParseCiliumPolicies() error = %v", err)
+		}
+		return policies
+	}
+	allowApiFromFront := FromNetworkPolicy(testutils.NewNetworkPolicyBuilder().WithName("allow-front").
+		WithNamespace("shop").
+		WithPodSelector(testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "api").Build()).
+		WithTypes(networkingv1.PolicyTypeIngress).
+		WithIngressRule(networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: testutils.NewLabelSelectorBuilder().WithMatchLabel("app", "front").Build()},
+			},
+		}).Build())
+	allowApiFromFrontRef := PolicyRef{Engine: EngineKubernetes, Kind: "NetworkPolicy", Name: "allow-front",
+		Namespace: "shop", Order: kubernetesOrder}
+	tests := []struct {
+		name               string
+		engine             string
+		policies           []*Policy
+		direction          string
+		traffic            Traffic
+		expectedEvaluation *Evaluation
+	}{
+		{
+			name:   "a Calico deny of lower order shadows a later allow",
+			engine: EngineCalico,
+			policies: calicoPolicies("GlobalNetworkPolicy", `{"items": [
+				{"metadata": {"name": "a-allow-all"}, "spec": {"order": 200, "selector": "app == 'api'",
+					"ingress": [{"action": "Allow"}]}},
+				{"metadata": {"name": "z-deny-front"}, "spec": {"order": 100, "selector": "app == 'api'",
+					"ingress": [{"action": "Deny", "source": {"selector": "app == 'front'"}}]}}
+			]}`),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCalico,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{
+					{Engine: EngineCalico, Kind: "GlobalNetworkPolicy", Name: "z-deny-front", Order: order(100)},
+					{Engine: EngineCalico, Kind: "GlobalNetworkPolicy", Name: "a-allow-all", Order: order(200)},
+				},
+				FirstMatch: &RuleMatch{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "GlobalNetworkPolicy", Name: "z-deny-front",
+						Order: order(100)},
+					Section: DirectionIngress, Index: 0, Action: ActionDeny,
+				},
+				ShadowedRules: []RuleMatch{{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "GlobalNetworkPolicy", Name: "a-allow-all",
+						Order: order(200)},
+					Section: DirectionIngress, Index: 0, Action: ActionAllow,
+				}},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictDeny,
+			},
+		},
+		{
+			name:   "Calico policies without order come after the Kubernetes network policies, Log rules not deciding",
+			engine: EngineCalico,
+			policies: append(calicoPolicies("NetworkPolicy", `{"items": [
+				{"metadata": {"name": "deny-all", "namespace": "shop"}, "spec": {"selector": "all()",
+					"ingress": [{"action": "Log"}, {"action": "Deny"}]}}
+			]}`), allowApiFromFront),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCalico,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{
+					allowApiFromFrontRef,
+					{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "deny-all", Namespace: "shop"},
+				},
+				FirstMatch: &RuleMatch{Policy: allowApiFromFrontRef, Section: DirectionIngress, Index: 0,
+					Action: ActionAllow},
+				ShadowedRules: []RuleMatch{{
+					Policy:  PolicyRef{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "deny-all", Namespace: "shop"},
+					Section: DirectionIngress, Index: 1, Action: ActionDeny,
+				}},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictAllow,
+			},
+		},
+		{
+			name:   "a Calico Pass skips the later policies",
+			engine: EngineCalico,
+			policies: calicoPolicies("NetworkPolicy", `{"items": [
+				{"metadata": {"name": "pass-monitoring", "namespace": "shop"}, "spec": {"order": 10,
+					"selector": "all()", "ingress": [{"action": "Pass",
+					"source": {"namespaceSelector": "team == 'ops'"}}]}},
+				{"metadata": {"name": "deny-all", "namespace": "shop"}, "spec": {"order": 20, "selector": "all()",
+					"ingress": [{"action": "Deny"}]}}
+			]}`),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: monitoring, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCalico,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{
+					{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "pass-monitoring", Namespace: "shop",
+						Order: order(10)},
+					{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "deny-all", Namespace: "shop",
+						Order: order(20)},
+				},
+				FirstMatch: &RuleMatch{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "pass-monitoring",
+						Namespace: "shop", Order: order(10)},
+					Section: DirectionIngress, Index: 0, Action: ActionPass,
+				},
+				ShadowedRules: []RuleMatch{{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "deny-all",
+						Namespace: "shop", Order: order(20)},
+					Section: DirectionIngress, Index: 0, Action: ActionDeny,
+				}},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictPass,
+			},
+		},
+		{
+			name:   "Calico egress rules match the ports of the destination, by range or by name",
+			engine: EngineCalico,
+			policies: calicoPolicies("NetworkPolicy", `{"items": [
+				{"metadata": {"name": "front-egress", "namespace": "shop"}, "spec": {"order": 10,
+					"selector": "app == 'front'", "types": ["Egress"], "egress": [
+						{"action": "Allow", "protocol": "UDP", "destination": {"ports": ["8000:9000"]}},
+						{"action": "Allow", "protocol": 6, "destination": {"selector": "app == 'api'",
+							"ports": ["http"]}},
+						{"action": "Allow", "destination": {"services": {"name": "api"}}}
+					]}}
+			]}`),
+			direction: DirectionEgress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCalico,
+				Direction: DirectionEgress,
+				Policies: []PolicyRef{{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "front-egress",
+					Namespace: "shop", Order: order(10)}},
+				FirstMatch: &RuleMatch{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "front-egress",
+						Namespace: "shop", Order: order(10)},
+					Section: DirectionEgress, Index: 1, Action: ActionAllow,
+				},
+				ShadowedRules: []RuleMatch{},
+				UnsupportedRules: []RuleMatch{{
+					Policy: PolicyRef{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "front-egress",
+						Namespace: "shop", Order: order(10)},
+					Section: DirectionEgress, Index: 2, Action: ActionAllow,
+					Unsupported: "destination.services",
+				}},
+				Verdict: VerdictAllow,
+			},
+		},
+		{
+			name:   "Calico policies selecting the pod deny traffic matching no rule",
+			engine: EngineCalico,
+			policies: calicoPolicies("NetworkPolicy", `{"items": [
+				{"metadata": {"name": "allow-monitoring", "namespace": "shop"}, "spec": {"order": 10,
+					"selector": "all()", "ingress": [{"action": "Allow",
+					"source": {"namespaceSelector": "team == 'ops'"}}]}}
+			]}`),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCalico,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{{Engine: EngineCalico, Kind: "NetworkPolicy", Name: "allow-monitoring",
+					Namespace: "shop", Order: order(10)}},
+				ShadowedRules:    []RuleMatch{},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictDeny,
+			},
+		},
+		{
+			name:   "Cilium deny rules take precedence over the allow rules of every policy",
+			engine: EngineCilium,
+			policies: ciliumPolicies("CiliumNetworkPolicy", `{"items": [
+				{"metadata": {"name": "allow-front", "namespace": "shop"}, "spec": {
+					"endpointSelector": {"matchLabels": {"app": "api"}},
+					"ingress": [{"fromEndpoints": [{"matchLabels": {"k8s:app": "front"}}]}]}},
+				{"metadata": {"name": "z-deny-cidr", "namespace": "shop"}, "spec": {
+					"endpointSelector": {},
+					"ingressDeny": [{"fromCIDR": ["10.0.0.0/24"],
+						"toPorts": [{"ports": [{"port": "8080", "protocol": "TCP"}]}]}]}}
+			]}`),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCilium,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{
+					{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "allow-front", Namespace: "shop"},
+					{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "z-deny-cidr", Namespace: "shop"},
+				},
+				FirstMatch: &RuleMatch{
+					Policy:  PolicyRef{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "z-deny-cidr", Namespace: "shop"},
+					Section: "ingressDeny", Index: 0, Action: ActionDeny,
+				},
+				ShadowedRules: []RuleMatch{{
+					Policy:  PolicyRef{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "allow-front", Namespace: "shop"},
+					Section: DirectionIngress, Index: 0, Action: ActionAllow,
+				}},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictDeny,
+			},
+		},
+		{
+			name:   "Cilium endpoint selectors match other namespaces only through their namespace labels",
+			engine: EngineCilium,
+			policies: ciliumPolicies("CiliumNetworkPolicy", `{"items": [
+				{"metadata": {"name": "allow-prometheus", "namespace": "shop"}, "spec": {
+					"endpointSelector": {"matchLabels": {"app": "api"}},
+					"ingress": [
+						{"fromEndpoints": [{"matchLabels": {"app": "prometheus"}}]},
+						{"fromEndpoints": [{"matchLabels": {"io.cilium.k8s.namespace.labels.team": "ops"}}]}
+					]}}
+			]}`),
+			direction: DirectionIngress,
+			traffic:   Traffic{Source: monitoring, Target: api, Port: 9090, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:    EngineCilium,
+				Direction: DirectionIngress,
+				Policies: []PolicyRef{{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "allow-prometheus",
+					Namespace: "shop"}},
+				FirstMatch: &RuleMatch{
+					Policy: PolicyRef{Engine: EngineCilium, Kind: "CiliumNetworkPolicy", Name: "allow-prometheus",
+						Namespace: "shop"},
+					Section: DirectionIngress, Index: 1, Action: ActionAllow,
+				},
+				ShadowedRules:    []RuleMatch{},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictAllow,
+			},
+		},
+		{
+			name:      "traffic to a pod selected by no policy has no verdict",
+			engine:    EngineCalico,
+			policies:  []*Policy{allowApiFromFront},
+			direction: DirectionEgress,
+			traffic:   Traffic{Source: front, Target: api, Port: 8080, Protocol: corev1.ProtocolTCP},
+			expectedEvaluation: &Evaluation{
+				Engine:           EngineCalico,
+				Direction:        DirectionEgress,
+				Policies:         []PolicyRef{},
+				ShadowedRules:    []RuleMatch{},
+				UnsupportedRules: []RuleMatch{},
+				Verdict:          VerdictNone,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := Evaluate(tt.engine, tt.policies, tt.direction, tt.traffic, namespaces)
+			if diff := cmp.Diff(tt.expectedEvaluation, evaluation); diff != "" {
+				t.Errorf("Evaluate() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package orderedpolicy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	EngineCalico     = "calico"
+	EngineCilium     = "cilium"
+	EngineKubernetes = "kubernetes"
+)
+
+const (
+	ActionAllow = "Allow"
+	ActionDeny  = "Deny"
+	ActionPass  = "Pass"
+	ActionLog   = "Log"
+)
+
+const (
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+)
+
+// KubernetesOrder is the order Calico evaluates the Kubernetes network policies with
+const KubernetesOrder = 1000
+
+// Policy is a Calico, Cilium or Kubernetes policy in a common model, whose rules are evaluated in the precedence of
+// its engine rather than unioned
+type Policy struct {
+	Engine string
+	Kind   string
+	Name   string
+	// Namespace is empty for cluster-wide policies
+	Namespace string
+	// Order is the Calico order, policies without any being evaluated last
+	Order    *float64
+	Selector Selector
+	// Directions are those the policy applies to
+	Directions []string
+	Ingress    []*Rule
+	Egress     []*Rule
+}
+
+// Rule matches the peer of the selected pods, the source of ingress rules and the destination of egress ones. A rule
+// of the manifest matching several kinds of peers is split into one rule each, sharing its section and index.
+type Rule struct {
+	// Section is the list of rules of the manifest the rule is declared in, such as ingress or ingressDeny
+	Section string
+	Index   int
+	Action  string
+	// Peer selects the labels of the peer pods, nil for any
+	Peer Selector
+	// PeerNamespace selects the labels of the namespaces of the peer pods. When nil, the peer pods are in the
+	// namespace of the policy, unless AnyNamespace is set or the policy is cluster-wide.
+	PeerNamespace Selector
+	AnyNamespace  bool
+	// Nets are the CIDRs one of the addresses of the peer pods is in, and NotNets those none of them is in
+	Nets    []string
+	NotNets []string
+	// Ports is empty for all the ports of every protocol
+	Ports []Port
+	// Unsupported names the field of the manifest the rule cannot be evaluated for, such rules matching nothing
+	Unsupported string
+}
+
+// Port is the range of ports from Port to EndPort of a protocol, empty for any. A zero port stands for all ports, and
+// a name for the container port of the target pod.
+type Port struct {
+	Protocol corev1.Protocol
+	Port     int32
+	EndPort  int32
+	Name     string
+}
+
+type Selector interface {
+	Matches(labels map[string]string) bool
+}
+
+// labelSelector is a Kubernetes label selector, an invalid one matching nothing
+type labelSelector struct {
+	selector labels.Selector
+}
+
+func newLabelSelector(selector metav1.LabelSelector) labelSelector {
+	parsed, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return labelSelector{selector: labels.Nothing()}
+	}
+	return labelSelector{selector: parsed}
+}
+
+func (selector labelSelector) Matches(objectLabels map[string]string) bool {
+	return selector.selector.Matches(labels.Set(objectLabels))
+}
+
+// FromNetworkPolicy models a Kubernetes network policy, whose rules all allow, with the order Calico gives it
+func FromNetworkPolicy(networkPolicy *networkingv1.NetworkPolicy) *Policy {
+	order := float64(KubernetesOrder)
+	policy := &Policy{
+		Engine:     EngineKubernetes,
+		Kind:       "NetworkPolicy",
+		Name:       networkPolicy.Name,
+		Namespace:  networkPolicy.Namespace,
+		Order:      &order,
+		Selector:   newLabelSelector(networkPolicy.Spec.PodSelector),
+		Directions: networkPolicyDirections(networkPolicy),
+		Ingress:    make([]*Rule, 0),
+		Egress:     make([]*Rule, 0),
+	}
+	for i, ingressRule := range networkPolicy.Spec.Ingress {
+		policy.Ingress = append(policy.Ingress, networkPolicyRules(DirectionIngress, i, ingressRule.From,
+			ingressRule.Ports)...)
+	}
+	for i, egressRule := range networkPolicy.Spec.Egress {
+		policy.Egress = append(policy.Egress, networkPolicyRules(DirectionEgress, i, egressRule.To,
+			egressRule.Ports)...)
+	}
+	return policy
+}
+
+func networkPolicyDirections(networkPolicy *networkingv1.NetworkPolicy) []string {
+	if len(networkPolicy.Spec.PolicyTypes) == 0 {
+		directions := []string{DirectionIngress}
+		if len(networkPolicy.Spec.Egress) > 0 {
+			directions = append(directions, DirectionEgress)
+		}
+		return directions
+	}
+	directions := make([]string, 0, len(networkPolicy.Spec.PolicyTypes))
+	for _, policyType := range networkPolicy.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			directions = append(directions, DirectionIngress)
+		} else if policyType == networkingv1.PolicyTypeEgress {
+			directions = append(directions, DirectionEgress)
+		}
+	}
+	return directions
+}
+
+func networkPolicyRules(section string, index int, peers []networkingv1.NetworkPolicyPeer,
+	networkPolicyPorts []networkingv1.NetworkPolicyPort) []*Rule {
+	ports := make([]Port, 0, len(networkPolicyPorts))
+	for _, networkPolicyPort := range networkPolicyPorts {
+		port := Port{Protocol: corev1.ProtocolTCP}
+		if networkPolicyPort.Protocol != nil {
+			port.Protocol = *networkPolicyPort.Protocol
+		}
+		if networkPolicyPort.Port != nil && networkPolicyPort.Port.Type == intstr.String {
+			port.Name = networkPolicyPort.Port.StrVal
+		} else if networkPolicyPort.Port != nil {
+			port.Port = networkPolicyPort.Port.IntVal
+			port.EndPort = port.Port
+			if networkPolicyPort.EndPort != nil {
+				port.EndPort = *networkPolicyPort.EndPort
+			}
+		}
+		ports = append(ports, port)
+	}
+	if len(peers) == 0 {
+		return []*Rule{{Section: section, Index: index, Action: ActionAllow, AnyNamespace: true, Ports: ports}}
+	}
+	rules := make([]*Rule, 0, len(peers))
+	for _, peer := range peers {
+		rule := &Rule{Section: section, Index: index, Action: ActionAllow, Ports: ports}
+		if peer.IPBlock != nil {
+			rule.AnyNamespace = true
+			rule.Nets = []string{peer.IPBlock.CIDR}
+			rule.NotNets = peer.IPBlock.Except
+		}
+		if peer.PodSelector != nil {
+			rule.Peer = newLabelSelector(*peer.PodSelector)
+		}
+		if peer.NamespaceSelector != nil {
+			rule.PeerNamespace = newLabelSelector(*peer.NamespaceSelector)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -0,0 +1,231 @@
+package orderedpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// calicoSelector is a parsed Calico selector expression. The supported grammar is all(), global(), has(k), k == 'v',
+// k != 'v', k in {'v', ...} and k not in {'v', ...}, combined with !, &&, || and parentheses.
+type calicoSelector struct {
+	matches func(labels map[string]string) bool
+}
+
+func (selector calicoSelector) Matches(labels map[string]string) bool {
+	return selector.matches(labels)
+}
+
+// ParseCalicoSelector parses a Calico selector, the empty one selecting all labels
+func ParseCalicoSelector(expression string) (Selector, error) {
+	parser := &selectorParser{input: expression}
+	parser.skipSpaces()
+	if parser.done() {
+		return calicoSelector{matches: func(map[string]string) bool { return true }}, nil
+	}
+	matches, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	parser.skipSpaces()
+	if !parser.done() {
+		return nil, parser.errorf("unexpected input")
+	}
+	return calicoSelector{matches: matches}, nil
+}
+
+type matcher func(labels map[string]string) bool
+
+type selectorParser struct {
+	input    string
+	position int
+}
+
+func (parser *selectorParser) parseOr() (matcher, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.consume("||") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		previous := left
+		left = func(labels map[string]string) bool { return previous(labels) || right(labels) }
+	}
+	return left, nil
+}
+
+func (parser *selectorParser) parseAnd() (matcher, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.consume("&&") {
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		previous := left
+		left = func(labels map[string]string) bool { return previous(labels) && right(labels) }
+	}
+	return left, nil
+}
+
+func (parser *selectorParser) parseUnary() (matcher, error) {
+	if parser.consume("!") {
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool { return !operand(labels) }, nil
+	}
+	if parser.consume("(") {
+		inner, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.consume(")") {
+			return nil, parser.errorf("expected )")
+		}
+		return inner, nil
+	}
+	return parser.parseTerm()
+}
+
+func (parser *selectorParser) parseTerm() (matcher, error) {
+	if parser.consume("all()") || parser.consume("global()") {
+		return func(map[string]string) bool { return true }, nil
+	}
+	if parser.consume("has(") {
+		key := parser.parseKey()
+		if key == "" || !parser.consume(")") {
+			return nil, parser.errorf("expected has(key)")
+		}
+		return func(labels map[string]string) bool {
+			_, ok := labels[key]
+			return ok
+		}, nil
+	}
+	key := parser.parseKey()
+	if key == "" {
+		return nil, parser.errorf("expected a label key")
+	}
+	switch {
+	case parser.consume("=="):
+		value, err := parser.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return ok && actual == value
+		}, nil
+	case parser.consume("!="):
+		value, err := parser.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return !ok || actual != value
+		}, nil
+	case parser.consume("not in"):
+		values, err := parser.parseValueSet()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return !ok || !values[actual]
+		}, nil
+	case parser.consume("in"):
+		values, err := parser.parseValueSet()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return ok && values[actual]
+		}, nil
+	}
+	return nil, parser.errorf("expected an operator after %s", key)
+}
+
+func (parser *selectorParser) parseKey() string {
+	parser.skipSpaces()
+	start := parser.position
+	for !parser.done() {
+		char := rune(parser.input[parser.position])
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) && !strings.ContainsRune("-_./", char) {
+			break
+		}
+		parser.position++
+	}
+	return parser.input[start:parser.position]
+}
+
+func (parser *selectorParser) parseValue() (string, error) {
+	parser.skipSpaces()
+	if parser.done() {
+		return "", parser.errorf("expected a quoted value")
+	}
+	quote := parser.input[parser.position]
+	if quote != '\'' && quote != '"' {
+		return "", parser.errorf("expected a quoted value")
+	}
+	end := strings.IndexByte(parser.input[parser.position+1:], quote)
+	if end < 0 {
+		return "", parser.errorf("unterminated value")
+	}
+	value := parser.input[parser.position+1 : parser.position+1+end]
+	parser.position += end + 2
+	return value, nil
+}
+
+func (parser *selectorParser) parseValueSet() (map[string]bool, error) {
+	if !parser.consume("{") {
+		return nil, parser.errorf("expected {")
+	}
+	values := make(map[string]bool)
+	if parser.consume("}") {
+		return values, nil
+	}
+	for {
+		value, err := parser.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values[value] = true
+		if parser.consume("}") {
+			return values, nil
+		}
+		if !parser.consume(",") {
+			return nil, parser.errorf("expected , or }")
+		}
+	}
+}
+
+func (parser *selectorParser) consume(token string) bool {
+	parser.skipSpaces()
+	if strings.HasPrefix(parser.input[parser.position:], token) {
+		parser.position += len(token)
+		return true
+	}
+	return false
+}
+
+func (parser *selectorParser) skipSpaces() {
+	for !parser.done() && parser.input[parser.position] == ' ' {
+		parser.position++
+	}
+}
+
+func (parser *selectorParser) done() bool {
+	return parser.position >= len(parser.input)
+}
+
+func (parser *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid selector %q at %d: %s", parser.input, parser.position, fmt.Sprintf(format, args...))
+}
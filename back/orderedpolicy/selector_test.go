@@ -0,0 +1,52 @@
+package orderedpolicy
+
+import (
+	"testing"
+)
+
+func TestParseCalicoSelector(t *testing.T) {
+	labels := map[string]string{"app": "api", "tier": "back", "projectcalico.org/namespace": "shop"}
+	tests := []struct {
+		name          string
+		expression    string
+		expectedMatch bool
+		expectedError bool
+	}{
+		{name: "empty selector matches all labels", expression: "", expectedMatch: true},
+		{name: "all() matches all labels", expression: "all()", expectedMatch: true},
+		{name: "equality matches the value", expression: "app == 'api'", expectedMatch: true},
+		{name: "equality does not match another value", expression: "app == \"front\"", expectedMatch: false},
+		{name: "inequality matches a missing label", expression: "role != 'db'", expectedMatch: true},
+		{name: "has() matches an existing key", expression: "has(tier)", expectedMatch: true},
+		{name: "negated has() does not match an existing key", expression: "!has(tier)", expectedMatch: false},
+		{name: "in matches a value of the set", expression: "app in {'front', 'api'}", expectedMatch: true},
+		{name: "not in does not match a value of the set", expression: "app not in {'api'}", expectedMatch: false},
+		{
+			name:          "conjunctions need every term",
+			expression:    "app == 'api' && tier == 'front'",
+			expectedMatch: false,
+		},
+		{
+			name:          "disjunctions need a term, with parentheses grouping",
+			expression:    "(app == 'front' || tier == 'back') && projectcalico.org/namespace == 'shop'",
+			expectedMatch: true,
+		},
+		{name: "unquoted values are invalid", expression: "app == api", expectedError: true},
+		{name: "unknown functions are invalid", expression: "starts_with(app, 'a')", expectedError: true},
+		{name: "trailing input is invalid", expression: "app == 'api' tier", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ParseCalicoSelector(tt.expression)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("ParseCalicoSelector() error = %v, expected error %v", err, tt.expectedError)
+			}
+			if err != nil {
+				return
+			}
+			if selector.Matches(labels) != tt.expectedMatch {
+				t.Errorf("Matches() = %v, expected %v", !tt.expectedMatch, tt.expectedMatch)
+			}
+		})
+	}
+}
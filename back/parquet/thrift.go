@@ -0,0 +1,96 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compactWriter only implements the subset of the Thrift compact protocol needed by Parquet footers and page headers
+type compactWriter struct {
+	buffer       bytes.Buffer
+	lastFieldIDs []int16
+}
+
+func (writer *compactWriter) structBegin() {
+	writer.lastFieldIDs = append(writer.lastFieldIDs, 0)
+}
+
+func (writer *compactWriter) structEnd() {
+	writer.buffer.WriteByte(0)
+	writer.lastFieldIDs = writer.lastFieldIDs[:len(writer.lastFieldIDs)-1]
+}
+
+func (writer *compactWriter) fieldBegin(id int16, fieldType byte) {
+	last := len(writer.lastFieldIDs) - 1
+	delta := id - writer.lastFieldIDs[last]
+	if delta > 0 && delta <= 15 {
+		writer.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		writer.buffer.WriteByte(fieldType)
+		writer.varint(int64(id))
+	}
+	writer.lastFieldIDs[last] = id
+}
+
+func (writer *compactWriter) i32Field(id int16, value int32) {
+	writer.fieldBegin(id, thriftI32)
+	writer.varint(int64(value))
+}
+
+func (writer *compactWriter) i64Field(id int16, value int64) {
+	writer.fieldBegin(id, thriftI64)
+	writer.varint(value)
+}
+
+func (writer *compactWriter) stringField(id int16, value string) {
+	writer.fieldBegin(id, thriftBinary)
+	writer.string(value)
+}
+
+func (writer *compactWriter) structField(id int16, writeStruct func()) {
+	writer.fieldBegin(id, thriftStruct)
+	writer.structBegin()
+	writeStruct()
+	writer.structEnd()
+}
+
+func (writer *compactWriter) listField(id int16, elementType byte, size int) {
+	writer.fieldBegin(id, thriftList)
+	if size < 15 {
+		writer.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		writer.buffer.WriteByte(0xf0 | elementType)
+		writer.uvarint(uint64(size))
+	}
+}
+
+func (writer *compactWriter) listStruct(writeStruct func()) {
+	writer.structBegin()
+	writeStruct()
+	writer.structEnd()
+}
+
+func (writer *compactWriter) string(value string) {
+	writer.uvarint(uint64(len(value)))
+	writer.buffer.WriteString(value)
+}
+
+func (writer *compactWriter) varint(value int64) {
+	// Integers are zigzag encoded so that small negative values stay short
+	writer.uvarint(uint64((value << 1) ^ (value >> 63)))
+}
+
+func (writer *compactWriter) uvarint(value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(encoded[:], value)
+	writer.buffer.Write(encoded[:n])
+}
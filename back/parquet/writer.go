@@ -0,0 +1,217 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	magic     = "PAR1"
+	createdBy = "karto"
+)
+
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Int64
+	Boolean
+	Timestamp
+)
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Parquet physical types, converted types, encodings and page types used by the writer
+const (
+	physicalBoolean      = 0
+	physicalInt64        = 2
+	physicalByteArray    = 6
+	convertedUTF8        = 0
+	convertedTimestampMs = 9
+	repetitionRequired   = 0
+	encodingPlain        = 0
+	encodingRLE          = 3
+	codecUncompressed    = 0
+	pageTypeData         = 0
+	fileMetadataVersion  = 1
+	maxValuesPerPage     = math.MaxInt32
+	noConvertedType      = -1
+)
+
+type columnChunk struct {
+	column     Column
+	offset     int64
+	size       int64
+	valueCount int64
+}
+
+// Write writes the rows as a single row group of required, plain encoded and uncompressed columns
+func Write(w io.Writer, columns []Column, rows [][]interface{}) error {
+	if len(rows) > maxValuesPerPage {
+		return fmt.Errorf("too many rows: %d", len(rows))
+	}
+	var body bytes.Buffer
+	body.WriteString(magic)
+	chunks := make([]columnChunk, 0)
+	if len(rows) > 0 {
+		for i, column := range columns {
+			values, err := encodeValues(column, i, rows)
+			if err != nil {
+				return err
+			}
+			offset := int64(body.Len())
+			body.Write(pageHeader(len(rows), len(values)))
+			body.Write(values)
+			chunks = append(chunks, columnChunk{column: column, offset: offset, size: int64(body.Len()) - offset,
+				valueCount: int64(len(rows))})
+		}
+	}
+	footer := fileMetadata(columns, int64(len(rows)), chunks)
+	body.Write(footer)
+	var footerLength [4]byte
+	binary.LittleEndian.PutUint32(footerLength[:], uint32(len(footer)))
+	body.Write(footerLength[:])
+	body.WriteString(magic)
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+func encodeValues(column Column, index int, rows [][]interface{}) ([]byte, error) {
+	var values bytes.Buffer
+	var bits byte
+	for i, row := range rows {
+		if len(row) <= index {
+			return nil, fmt.Errorf("row %d has no value for column %s", i, column.Name)
+		}
+		ok := true
+		switch column.Type {
+		case String:
+			var value string
+			value, ok = row[index].(string)
+			_ = binary.Write(&values, binary.LittleEndian, uint32(len(value)))
+			values.WriteString(value)
+		case Int64:
+			var value int64
+			value, ok = row[index].(int64)
+			_ = binary.Write(&values, binary.LittleEndian, value)
+		case Timestamp:
+			var value time.Time
+			value, ok = row[index].(time.Time)
+			_ = binary.Write(&values, binary.LittleEndian, value.UnixNano()/int64(time.Millisecond))
+		case Boolean:
+			var value bool
+			value, ok = row[index].(bool)
+			// Booleans are bit packed, least significant bit first
+			if value {
+				bits |= 1 << (i % 8)
+			}
+			if i%8 == 7 || i == len(rows)-1 {
+				values.WriteByte(bits)
+				bits = 0
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %v for column %s in row %d", row[index], column.Name, i)
+		}
+	}
+	return values.Bytes(), nil
+}
+
+func pageHeader(valueCount int, size int) []byte {
+	writer := &compactWriter{}
+	writer.structBegin()
+	writer.i32Field(1, pageTypeData)
+	writer.i32Field(2, int32(size))
+	writer.i32Field(3, int32(size))
+	writer.structField(5, func() {
+		writer.i32Field(1, int32(valueCount))
+		writer.i32Field(2, encodingPlain)
+		writer.i32Field(3, encodingRLE)
+		writer.i32Field(4, encodingRLE)
+	})
+	writer.structEnd()
+	return writer.buffer.Bytes()
+}
+
+func fileMetadata(columns []Column, rowCount int64, chunks []columnChunk) []byte {
+	writer := &compactWriter{}
+	writer.structBegin()
+	writer.i32Field(1, fileMetadataVersion)
+	writer.listField(2, thriftStruct, len(columns)+1)
+	writer.listStruct(func() {
+		writer.stringField(4, "schema")
+		writer.i32Field(5, int32(len(columns)))
+	})
+	for _, column := range columns {
+		physicalType, convertedType := typesOf(column.Type)
+		writer.listStruct(func() {
+			writer.i32Field(1, physicalType)
+			writer.i32Field(3, repetitionRequired)
+			writer.stringField(4, column.Name)
+			if convertedType != noConvertedType {
+				writer.i32Field(6, convertedType)
+			}
+		})
+	}
+	writer.i64Field(3, rowCount)
+	rowGroupCount := 0
+	if len(chunks) > 0 {
+		rowGroupCount = 1
+	}
+	writer.listField(4, thriftStruct, rowGroupCount)
+	if rowGroupCount > 0 {
+		writer.listStruct(func() {
+			writeRowGroup(writer, rowCount, chunks)
+		})
+	}
+	writer.stringField(6, createdBy)
+	writer.structEnd()
+	return writer.buffer.Bytes()
+}
+
+func writeRowGroup(writer *compactWriter, rowCount int64, chunks []columnChunk) {
+	totalSize := int64(0)
+	writer.listField(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+		physicalType, _ := typesOf(chunk.column.Type)
+		writer.listStruct(func() {
+			writer.i64Field(2, chunk.offset)
+			writer.structField(3, func() {
+				writer.i32Field(1, physicalType)
+				writer.listField(2, thriftI32, 2)
+				writer.varint(encodingPlain)
+				writer.varint(encodingRLE)
+				writer.listField(3, thriftBinary, 1)
+				writer.string(chunk.column.Name)
+				writer.i32Field(4, codecUncompressed)
+				writer.i64Field(5, chunk.valueCount)
+				writer.i64Field(6, chunk.size)
+				writer.i64Field(7, chunk.size)
+				writer.i64Field(9, chunk.offset)
+			})
+		})
+	}
+	writer.i64Field(2, totalSize)
+	writer.i64Field(3, rowCount)
+}
+
+func typesOf(columnType ColumnType) (int32, int32) {
+	switch columnType {
+	case Int64:
+		return physicalInt64, noConvertedType
+	case Timestamp:
+		return physicalInt64, convertedTimestampMs
+	case Boolean:
+		return physicalBoolean, noConvertedType
+	default:
+		return physicalByteArray, convertedUTF8
+	}
+}
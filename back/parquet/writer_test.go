@@ -0,0 +1,130 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	type args struct {
+		columns []Column
+		rows    [][]interface{}
+	}
+	columns := []Column{{Name: "name", Type: String}, {Name: "count", Type: Int64}, {Name: "enabled", Type: Boolean},
+		{Name: "at", Type: Timestamp}}
+	at := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                string
+		args                args
+		expectedValueChunks [][]byte
+		expectedError       string
+	}{
+		{
+			name: "values are plain encoded, booleans being bit packed",
+			args: args{
+				columns: columns,
+				rows: [][]interface{}{
+					{"a", int64(1), true, at},
+					{"bc", int64(-2), false, at},
+					{"", int64(3), true, at},
+				},
+			},
+			expectedValueChunks: [][]byte{
+				{1, 0, 0, 0, 'a', 2, 0, 0, 0, 'b', 'c', 0, 0, 0, 0},
+				{1, 0, 0, 0, 0, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 3, 0, 0, 0, 0, 0, 0, 0},
+				{0x05},
+				{0x00, 0xf6, 0x4d, 0x8d, 0x78, 0x01, 0x00, 0x00, 0x00, 0xf6, 0x4d, 0x8d, 0x78, 0x01, 0x00, 0x00,
+					0x00, 0xf6, 0x4d, 0x8d, 0x78, 0x01, 0x00, 0x00},
+			},
+		},
+		{
+			name: "a file without rows has no row group",
+			args: args{
+				columns: columns,
+				rows:    [][]interface{}{},
+			},
+			expectedValueChunks: [][]byte{},
+		},
+		{
+			name: "a value of the wrong type is an error",
+			args: args{
+				columns: columns,
+				rows:    [][]interface{}{{"a", 1, true, at}},
+			},
+			expectedError: "unexpected value 1 for column count in row 0",
+		},
+		{
+			name: "a missing value is an error",
+			args: args{
+				columns: columns,
+				rows:    [][]interface{}{{"a"}},
+			},
+			expectedError: "row 0 has no value for column count",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content bytes.Buffer
+			err := Write(&content, tt.args.columns, tt.args.rows)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			if diff := cmp.Diff(tt.expectedError, errorMessage); diff != "" {
+				t.Fatalf("Write() error mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			file := content.Bytes()
+			if string(file[:4]) != magic || string(file[len(file)-4:]) != magic {
+				t.Fatalf("Write() result is not framed by the parquet magic number")
+			}
+			footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8 : len(file)-4]))
+			expectedFooter := fileMetadata(tt.args.columns, int64(len(tt.args.rows)), chunksOf(tt.args.rows,
+				tt.args.columns, tt.expectedValueChunks))
+			if diff := cmp.Diff(expectedFooter, file[len(file)-8-footerLength:len(file)-8]); diff != "" {
+				t.Errorf("Write() footer mismatch (-want +got):\n%s", diff)
+			}
+			expectedBody := []byte(magic)
+			for _, values := range tt.expectedValueChunks {
+				expectedBody = append(expectedBody, pageHeader(len(tt.args.rows), len(values))...)
+				expectedBody = append(expectedBody, values...)
+			}
+			if diff := cmp.Diff(expectedBody, file[:len(file)-8-footerLength]); diff != "" {
+				t.Errorf("Write() column chunks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func chunksOf(rows [][]interface{}, columns []Column, valueChunks [][]byte) []columnChunk {
+	chunks := make([]columnChunk, 0)
+	offset := int64(len(magic))
+	for i, values := range valueChunks {
+		size := int64(len(pageHeader(len(rows), len(values))) + len(values))
+		chunks = append(chunks, columnChunk{column: columns[i], offset: offset, size: size,
+			valueCount: int64(len(rows))})
+		offset += size
+	}
+	return chunks
+}
+
+func TestCompactWriter(t *testing.T) {
+	writer := &compactWriter{}
+	writer.structBegin()
+	writer.i32Field(1, 1)
+	writer.i64Field(3, -1)
+	writer.stringField(20, "ab")
+	writer.structField(21, func() {
+		writer.i32Field(1, 300)
+	})
+	writer.structEnd()
+	expected := []byte{0x15, 0x02, 0x26, 0x01, 0x08, 0x28, 0x02, 'a', 'b', 0x1c, 0x15, 0xd8, 0x04, 0x00, 0x00}
+	if diff := cmp.Diff(expected, writer.buffer.Bytes()); diff != "" {
+		t.Errorf("compactWriter result mismatch (-want +got):\n%s", diff)
+	}
+}
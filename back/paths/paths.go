@@ -0,0 +1,218 @@
+package paths
+
+import (
+	"context"
+	"karto/drift"
+	"karto/routeports"
+	"karto/servicerouting"
+	"karto/types"
+	"sort"
+)
+
+const (
+	DefaultMaxHops = 3
+	maxPaths       = 100
+)
+
+type Hop struct {
+	Source          types.ResourceRef     `json:"source"`
+	Service         *types.ServiceRef     `json:"service"`
+	Target          types.ResourceRef     `json:"target"`
+	Ports           []int32               `json:"ports"`
+	PortRanges      []types.PortRange     `json:"portRanges"`
+	ProtocolPorts   []types.ProtocolPorts `json:"protocolPorts"`
+	IngressPolicies []types.NetworkPolicy `json:"ingressPolicies"`
+	EgressPolicies  []types.NetworkPolicy `json:"egressPolicies"`
+}
+
+type Path struct {
+	Hops []*Hop `json:"hops"`
+}
+
+type Result struct {
+	From      types.ResourceRef `json:"from"`
+	To        types.ResourceRef `json:"to"`
+	Paths     []*Path           `json:"paths"`
+	Truncated bool              `json:"truncated"`
+}
+
+type edge struct {
+	source types.ResourceRef
+	target types.ResourceRef
+}
+
+type finder struct {
+	hopsByEdge map[edge][]*Hop
+	targets    map[types.ResourceRef][]types.ResourceRef
+	maxHops    int
+	result     *Result
+	ctx        context.Context
+	err        error
+}
+
+// Find returns the distinct paths between two workloads, each hop being taken either directly between pods or through
+// one of the services routing its source pods to the pods it reaches. The search is abandoned as soon as the context
+// is done.
+func Find(ctx context.Context, analysisResult types.AnalysisResult, from types.ResourceRef, to types.ResourceRef,
+	maxHops int) (Result, error) {
+	finder := finder{
+		hopsByEdge: hopsByEdge(analysisResult),
+		targets:    make(map[types.ResourceRef][]types.ResourceRef),
+		maxHops:    maxHops,
+		result:     &Result{From: from, To: to, Paths: make([]*Path, 0)},
+		ctx:        ctx,
+	}
+	for edge := range finder.hopsByEdge {
+		finder.targets[edge.source] = append(finder.targets[edge.source], edge.target)
+	}
+	for _, targets := range finder.targets {
+		sort.Slice(targets, func(i, j int) bool { return resourceLess(targets[i], targets[j]) })
+	}
+	finder.walk([]types.ResourceRef{from}, to)
+	if finder.err != nil {
+		return Result{}, finder.err
+	}
+	return *finder.result, nil
+}
+
+func (finder *finder) walk(workloads []types.ResourceRef, to types.ResourceRef) {
+	if finder.result.Truncated || finder.err != nil {
+		return
+	}
+	if finder.err = finder.ctx.Err(); finder.err != nil {
+		return
+	}
+	current := workloads[len(workloads)-1]
+	if current == to && len(workloads) > 1 {
+		finder.expand(workloads, 0, make([]*Hop, 0))
+		return
+	}
+	if len(workloads) > finder.maxHops {
+		return
+	}
+	for _, target := range finder.targets[current] {
+		if contains(workloads, target) {
+			// Paths are simple, a workload is never visited twice
+			continue
+		}
+		finder.walk(append(append(make([]types.ResourceRef, 0), workloads...), target), to)
+	}
+}
+
+// Each hop of a path between workloads can be taken directly or through a service, which makes as many distinct paths
+func (finder *finder) expand(workloads []types.ResourceRef, index int, hops []*Hop) {
+	if index == len(workloads)-1 {
+		if len(finder.result.Paths) >= maxPaths {
+			finder.result.Truncated = true
+			return
+		}
+		finder.result.Paths = append(finder.result.Paths, &Path{Hops: append(make([]*Hop, 0), hops...)})
+		return
+	}
+	for _, hop := range finder.hopsByEdge[edge{source: workloads[index], target: workloads[index+1]}] {
+		finder.expand(workloads, index+1, append(hops, hop))
+	}
+}
+
+func hopsByEdge(analysisResult types.AnalysisResult) map[edge][]*Hop {
+	type hopKey struct {
+		edge    edge
+		service types.ServiceRef
+	}
+	workloads := drift.PodWorkloads(analysisResult)
+	services := servicerouting.NewIndex(analysisResult)
+	hops := make(map[hopKey]*Hop)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		routeEdge := edge{source: workloads(allowedRoute.SourcePod), target: workloads(allowedRoute.TargetPod)}
+		if routeEdge.source == routeEdge.target {
+			continue
+		}
+		// The zero service reference stands for the direct hop
+		for _, service := range append([]types.ServiceRef{{}}, services.Reaching(allowedRoute)...) {
+			key := hopKey{edge: routeEdge, service: service}
+			hop, ok := hops[key]
+			if !ok {
+				none := routeports.None()
+				hop = &Hop{Source: routeEdge.source, Target: routeEdge.target, Ports: none.Ports,
+					ProtocolPorts:   none.ProtocolPorts,
+					IngressPolicies: make([]types.NetworkPolicy, 0), EgressPolicies: make([]types.NetworkPolicy, 0)}
+				if service != (types.ServiceRef{}) {
+					serviceRef := service
+					hop.Service = &serviceRef
+				}
+				hops[key] = hop
+			}
+			merge(hop, allowedRoute)
+		}
+	}
+	result := make(map[edge][]*Hop)
+	for key, hop := range hops {
+		result[key.edge] = append(result[key.edge], hop)
+	}
+	for _, edgeHops := range result {
+		sort.Slice(edgeHops, func(i, j int) bool {
+			if (edgeHops[i].Service == nil) != (edgeHops[j].Service == nil) {
+				return edgeHops[i].Service == nil
+			}
+			return edgeHops[j].Service != nil && serviceLess(*edgeHops[i].Service, *edgeHops[j].Service)
+		})
+	}
+	return result
+}
+
+// A hop is allowed on all ports, with nil ports, as soon as one of its routes is
+func merge(hop *Hop, allowedRoute *types.AllowedRoute) {
+	ports := routeports.Merge(routeports.Set{Ports: hop.Ports, PortRanges: hop.PortRanges,
+		ProtocolPorts: hop.ProtocolPorts}, routeports.Of(allowedRoute))
+	hop.Ports, hop.PortRanges, hop.ProtocolPorts = ports.Ports, ports.PortRanges, ports.ProtocolPorts
+	hop.IngressPolicies = mergePolicies(hop.IngressPolicies, allowedRoute.IngressPolicies)
+	hop.EgressPolicies = mergePolicies(hop.EgressPolicies, allowedRoute.EgressPolicies)
+}
+
+func mergePolicies(policies []types.NetworkPolicy, others []types.NetworkPolicy) []types.NetworkPolicy {
+	for _, other := range others {
+		found := false
+		for _, policy := range policies {
+			if policy.Name == other.Name && policy.Namespace == other.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			policies = append(policies, other)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies
+}
+
+func contains(workloads []types.ResourceRef, workload types.ResourceRef) bool {
+	for _, other := range workloads {
+		if other == workload {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceLess(resource1 types.ResourceRef, resource2 types.ResourceRef) bool {
+	if resource1.Namespace != resource2.Namespace {
+		return resource1.Namespace < resource2.Namespace
+	}
+	if resource1.Name != resource2.Name {
+		return resource1.Name < resource2.Name
+	}
+	return resource1.Kind < resource2.Kind
+}
+
+func serviceLess(service1 types.ServiceRef, service2 types.ServiceRef) bool {
+	if service1.Namespace != service2.Namespace {
+		return service1.Namespace < service2.Namespace
+	}
+	return service1.Name < service2.Name
+}
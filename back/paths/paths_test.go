@@ -0,0 +1,135 @@
+package paths
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	type args struct {
+		from    types.ResourceRef
+		to      types.ResourceRef
+		maxHops int
+	}
+	front := types.ResourceRef{Kind: "Deployment", Name: "front", Namespace: "shop"}
+	api := types.ResourceRef{Kind: "Deployment", Name: "api", Namespace: "shop"}
+	db := types.ResourceRef{Kind: "StatefulSet", Name: "db", Namespace: "shop"}
+	frontPod := types.PodRef{Name: "front-abc", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-abc", Namespace: "shop"}
+	dbPod := types.PodRef{Name: "db-0", Namespace: "shop"}
+	apiPolicy := types.NetworkPolicy{Name: "api", Namespace: "shop"}
+	dbPolicy := types.NetworkPolicy{Name: "db", Namespace: "shop"}
+	apiService := types.ServiceRef{Name: "api", Namespace: "shop"}
+	analysisResult := types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: frontPod, TargetPod: apiPod, IngressPolicies: []types.NetworkPolicy{apiPolicy},
+				Ports: []int32{8080}},
+			{SourcePod: apiPod, TargetPod: dbPod, IngressPolicies: []types.NetworkPolicy{dbPolicy},
+				Ports: []int32{5432}},
+			{SourcePod: frontPod, TargetPod: dbPod, Ports: nil},
+		},
+		Services: []*types.Service{
+			{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+		},
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front-rs", Namespace: "shop", TargetPods: []types.PodRef{frontPod}},
+			{Name: "api-rs", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+		},
+		StatefulSets: []*types.StatefulSet{
+			{Name: "db", Namespace: "shop", TargetPods: []types.PodRef{dbPod}},
+		},
+		Deployments: []*types.Deployment{
+			{Name: "front", Namespace: "shop", TargetReplicaSets: []types.ReplicaSetRef{{Name: "front-rs",
+				Namespace: "shop"}}},
+			{Name: "api", Namespace: "shop", TargetReplicaSets: []types.ReplicaSetRef{{Name: "api-rs",
+				Namespace: "shop"}}},
+		},
+	}
+	directToDB := &Hop{Source: front, Target: db, Ports: nil, IngressPolicies: []types.NetworkPolicy{},
+		EgressPolicies: []types.NetworkPolicy{}}
+	directToAPI := &Hop{Source: front, Target: api, Ports: []int32{8080},
+		IngressPolicies: []types.NetworkPolicy{apiPolicy}, EgressPolicies: []types.NetworkPolicy{}}
+	serviceToAPI := &Hop{Source: front, Service: &apiService, Target: api, Ports: []int32{8080},
+		IngressPolicies: []types.NetworkPolicy{apiPolicy}, EgressPolicies: []types.NetworkPolicy{}}
+	apiToDB := &Hop{Source: api, Target: db, Ports: []int32{5432}, IngressPolicies: []types.NetworkPolicy{dbPolicy},
+		EgressPolicies: []types.NetworkPolicy{}}
+	tests := []struct {
+		name           string
+		args           args
+		expectedResult Result
+	}{
+		{
+			name: "direct paths and paths through services and intermediate workloads are returned",
+			args: args{from: front, to: db, maxHops: DefaultMaxHops},
+			expectedResult: Result{From: front, To: db, Paths: []*Path{
+				{Hops: []*Hop{directToAPI, apiToDB}},
+				{Hops: []*Hop{serviceToAPI, apiToDB}},
+				{Hops: []*Hop{directToDB}},
+			}},
+		},
+		{
+			name: "paths longer than the maximum number of hops are ignored",
+			args: args{from: front, to: db, maxHops: 1},
+			expectedResult: Result{From: front, To: db, Paths: []*Path{
+				{Hops: []*Hop{directToDB}},
+			}},
+		},
+		{
+			name:           "no path is returned against the direction of routes",
+			args:           args{from: db, to: front, maxHops: DefaultMaxHops},
+			expectedResult: Result{From: db, To: front, Paths: []*Path{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Find(context.Background(), analysisResult, tt.args.from, tt.args.to, tt.args.maxHops)
+			if err != nil {
+				t.Fatalf("Find() unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tt.expectedResult, result); diff != "" {
+				t.Errorf("Find() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindPortRanges(t *testing.T) {
+	front := types.ResourceRef{Kind: "Pod", Name: "front", Namespace: "shop"}
+	api := types.ResourceRef{Kind: "Pod", Name: "api", Namespace: "shop"}
+	frontPod := types.PodRef{Name: "front", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api", Namespace: "shop"}
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{{Name: "front", Namespace: "shop"}, {Name: "api", Namespace: "shop"}},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: frontPod, TargetPod: apiPod, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{},
+					PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}}},
+		},
+	}
+	expectedResult := Result{From: front, To: api, Paths: []*Path{{Hops: []*Hop{{Source: front, Target: api,
+		Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+		ProtocolPorts: []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: []int32{},
+			PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}},
+		IngressPolicies: []types.NetworkPolicy{}, EgressPolicies: []types.NetworkPolicy{}}}}}}
+	result, err := Find(context.Background(), analysisResult, front, api, DefaultMaxHops)
+	if err != nil {
+		t.Fatalf("Find() unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(expectedResult, result); diff != "" {
+		t.Errorf("Find() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	workload := types.ResourceRef{Kind: "Deployment", Name: "front", Namespace: "shop"}
+	_, err := Find(ctx, types.AnalysisResult{}, workload, workload, DefaultMaxHops)
+	if err != context.Canceled {
+		t.Errorf("Find() expected the cancellation of the context, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package policytest
+
+import (
+	"io"
+	"sigs.k8s.io/yaml"
+	"text/template"
+)
+
+// The generated test only depends on the standard library, so that it can be checked into any repository. It runs
+// the verify command of the karto binary, found in the path unless KARTO_BINARY is set, with one subtest per
+// expectation.
+var goTestTemplate = template.Must(template.New("test").Parse(`// Code generated by karto generate-tests. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// connectivitySuite locks in the connectivity of the namespace {{ .Namespace }}
+const connectivitySuite = ` + "`" + `{{ .Suite }}` + "`" + `
+
+func TestConnectivity(t *testing.T) {
+	suitePath := filepath.Join(t.TempDir(), "suite.yaml")
+	if err := os.WriteFile(suitePath, []byte(connectivitySuite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := os.Getenv("KARTO_BINARY")
+	if binary == "" {
+		binary = "karto"
+	}
+	args := []string{"verify", "-f", suitePath, "-o", "json"}
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		args = append(args, "-kubeconfig", kubeconfig)
+	}
+	// The verify command fails when expectations are not met, its output still listing them
+	output, err := exec.Command(binary, args...).Output()
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		t.Fatal(err)
+	}
+	var results []struct {
+		Expectation struct {
+			From string ` + "`json:\"from\"`" + `
+			To   string ` + "`json:\"to\"`" + `
+		} ` + "`json:\"expectation\"`" + `
+		Passed bool   ` + "`json:\"passed\"`" + `
+		Reason string ` + "`json:\"reason\"`" + `
+	}
+	if err := json.Unmarshal(output, &results); err != nil {
+		t.Fatalf("invalid output of karto verify: %s", err)
+	}
+	for _, result := range results {
+		result := result
+		t.Run(result.Expectation.From+" to "+result.Expectation.To, func(t *testing.T) {
+			if !result.Passed {
+				t.Error(result.Reason)
+			}
+		})
+	}
+}
+`))
+
+func WriteGo(w io.Writer, suite Suite, packageName string) error {
+	content, err := yaml.Marshal(suite)
+	if err != nil {
+		return err
+	}
+	return goTestTemplate.Execute(w, struct {
+		Package   string
+		Namespace string
+		Suite     string
+	}{Package: packageName, Namespace: suite.Namespace, Suite: string(content)})
+}
@@ -0,0 +1,171 @@
+package policytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"karto/drift"
+	"karto/routeports"
+	"karto/types"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+// Suite locks in the connectivity of the workloads of a namespace, workloads being referenced as namespace/kind/name
+type Suite struct {
+	Namespace    string         `json:"namespace"`
+	Expectations []*Expectation `json:"expectations"`
+}
+
+// Expectation of an allowed connection holds on the given ports and port ranges, or on all ports when none is given.
+// That of a denied connection holds when no route is allowed between the two workloads.
+type Expectation struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Allowed    bool              `json:"allowed"`
+	Ports      []int32           `json:"ports,omitempty"`
+	PortRanges []types.PortRange `json:"portRanges,omitempty"`
+}
+
+type Result struct {
+	Expectation *Expectation `json:"expectation"`
+	Passed      bool         `json:"passed"`
+	Reason      string       `json:"reason,omitempty"`
+}
+
+type workloadPair struct {
+	from string
+	to   string
+}
+
+// connection merges the ports of the routes between the pods of two workloads
+type connection struct {
+	ports routeports.Set
+}
+
+func Load(path string) (Suite, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Suite{}, err
+	}
+	var suite Suite
+	err = yaml.UnmarshalStrict(content, &suite)
+	if err != nil {
+		return Suite{}, fmt.Errorf("invalid test suite %s: %s", path, err)
+	}
+	return suite, nil
+}
+
+// Generate expects the connections allowed from or to the workloads of the namespace, and denies the others between
+// the workloads of the namespace, those with the rest of the cluster being too many to be listed
+func Generate(analysisResult types.AnalysisResult, namespace string) Suite {
+	connections, workloads := connectionsOf(analysisResult)
+	suite := Suite{Namespace: namespace, Expectations: make([]*Expectation, 0)}
+	for pair, connection := range connections {
+		if inNamespace(pair.from, namespace) || inNamespace(pair.to, namespace) {
+			suite.Expectations = append(suite.Expectations, &Expectation{From: pair.from, To: pair.to, Allowed: true,
+				Ports: connection.ports.Ports, PortRanges: connection.ports.PortRanges})
+		}
+	}
+	namespaceWorkloads := make([]string, 0)
+	for workload := range workloads {
+		if inNamespace(workload, namespace) {
+			namespaceWorkloads = append(namespaceWorkloads, workload)
+		}
+	}
+	for _, from := range namespaceWorkloads {
+		for _, to := range namespaceWorkloads {
+			if _, ok := connections[workloadPair{from: from, to: to}]; !ok && from != to {
+				suite.Expectations = append(suite.Expectations, &Expectation{From: from, To: to, Allowed: false})
+			}
+		}
+	}
+	sort.Slice(suite.Expectations, func(i, j int) bool {
+		if suite.Expectations[i].From != suite.Expectations[j].From {
+			return suite.Expectations[i].From < suite.Expectations[j].From
+		}
+		return suite.Expectations[i].To < suite.Expectations[j].To
+	})
+	return suite
+}
+
+// Check tells for each expectation whether the analysis result meets it. Workloads which no longer exist fail their
+// expectations, as the connectivity they locked in cannot be checked anymore.
+func Check(analysisResult types.AnalysisResult, suite Suite) []*Result {
+	connections, workloads := connectionsOf(analysisResult)
+	results := make([]*Result, 0)
+	for _, expectation := range suite.Expectations {
+		result := &Result{Expectation: expectation}
+		connection, allowed := connections[workloadPair{from: expectation.From, to: expectation.To}]
+		switch {
+		case !workloads[expectation.From]:
+			result.Reason = fmt.Sprintf("workload %s not found", expectation.From)
+		case !workloads[expectation.To]:
+			result.Reason = fmt.Sprintf("workload %s not found", expectation.To)
+		case !expectation.Allowed && allowed:
+			result.Reason = fmt.Sprintf("denied connection is allowed on %s", connection.formatPorts())
+		case expectation.Allowed && !allowed:
+			result.Reason = "allowed connection is denied"
+		case expectation.Allowed:
+			result.Reason = connection.missingPorts(expectation)
+		}
+		result.Passed = result.Reason == ""
+		results = append(results, result)
+	}
+	return results
+}
+
+func connectionsOf(analysisResult types.AnalysisResult) (map[workloadPair]*connection, map[string]bool) {
+	podWorkloads := drift.PodWorkloads(analysisResult)
+	workloadOf := func(podRef types.PodRef) string {
+		workloadRef := podWorkloads(podRef)
+		return fmt.Sprintf("%s/%s/%s", workloadRef.Namespace, strings.ToLower(workloadRef.Kind), workloadRef.Name)
+	}
+	workloads := make(map[string]bool)
+	for _, pod := range analysisResult.Pods {
+		workloads[workloadOf(types.PodRef{Name: pod.Name, Namespace: pod.Namespace})] = true
+	}
+	connections := make(map[workloadPair]*connection)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		pair := workloadPair{from: workloadOf(allowedRoute.SourcePod), to: workloadOf(allowedRoute.TargetPod)}
+		if pair.from == pair.to {
+			continue
+		}
+		existing, ok := connections[pair]
+		if !ok {
+			existing = &connection{ports: routeports.None()}
+			connections[pair] = existing
+		}
+		existing.ports = routeports.Merge(existing.ports, routeports.Of(allowedRoute))
+	}
+	return connections, workloads
+}
+
+func (connection *connection) formatPorts() string {
+	if connection.ports.AllPorts() {
+		return "all ports"
+	}
+	return "ports " + strings.Join(connection.ports.Strings(), ", ")
+}
+
+// missingPorts returns an empty reason when all the expected ports are allowed, whatever their protocol
+func (connection *connection) missingPorts(expectation *Expectation) string {
+	if connection.ports.AllPorts() {
+		return ""
+	}
+	if len(expectation.Ports) == 0 && len(expectation.PortRanges) == 0 {
+		return fmt.Sprintf("allowed connection is restricted to %s", connection.formatPorts())
+	}
+	expectedPorts := routeports.Set{Ports: append(make([]int32, 0), expectation.Ports...),
+		PortRanges: expectation.PortRanges}
+	allowedPorts := routeports.Set{Ports: connection.ports.Ports, PortRanges: connection.ports.PortRanges}
+	missing := routeports.Subtract(expectedPorts, allowedPorts)
+	if missing.IsEmpty() {
+		return ""
+	}
+	return fmt.Sprintf("allowed connection is denied on ports %s", strings.Join(missing.Strings(), ", "))
+}
+
+func inNamespace(workload string, namespace string) bool {
+	return strings.HasPrefix(workload, namespace+"/")
+}
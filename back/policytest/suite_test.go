@@ -0,0 +1,137 @@
+package policytest
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"go/parser"
+	"go/token"
+	"karto/types"
+	"testing"
+)
+
+func analysisResultOf(withMonitoring bool, allowedRoutes ...*types.AllowedRoute) types.AnalysisResult {
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front-1", Namespace: "shop"},
+			{Name: "api-1", Namespace: "shop"},
+			{Name: "db-0", Namespace: "shop"},
+		},
+		AllowedRoutes: allowedRoutes,
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front-rs", Namespace: "shop", TargetPods: []types.PodRef{{Name: "front-1", Namespace: "shop"}}},
+			{Name: "api-rs", Namespace: "shop", TargetPods: []types.PodRef{{Name: "api-1", Namespace: "shop"}}},
+		},
+		StatefulSets: []*types.StatefulSet{
+			{Name: "db", Namespace: "shop", TargetPods: []types.PodRef{{Name: "db-0", Namespace: "shop"}}},
+		},
+		Deployments: []*types.Deployment{
+			{Name: "front", Namespace: "shop", TargetReplicaSets: []types.ReplicaSetRef{
+				{Name: "front-rs", Namespace: "shop"}}},
+			{Name: "api", Namespace: "shop", TargetReplicaSets: []types.ReplicaSetRef{
+				{Name: "api-rs", Namespace: "shop"}}},
+		},
+	}
+	if withMonitoring {
+		analysisResult.Pods = append(analysisResult.Pods, &types.Pod{Name: "prom", Namespace: "monitoring"})
+	}
+	return analysisResult
+}
+
+func route(source string, target string, ports ...int32) *types.AllowedRoute {
+	allowedRoute := &types.AllowedRoute{SourcePod: types.PodRef{Name: source, Namespace: "shop"},
+		TargetPod: types.PodRef{Name: target, Namespace: "shop"}}
+	if source == "prom" {
+		allowedRoute.SourcePod.Namespace = "monitoring"
+	}
+	if len(ports) > 0 {
+		allowedRoute.Ports = ports
+	}
+	return allowedRoute
+}
+
+var generatedSuite = Suite{Namespace: "shop", Expectations: []*Expectation{
+	{From: "monitoring/pod/prom", To: "shop/deployment/api", Allowed: true, Ports: []int32{9090}},
+	{From: "shop/deployment/api", To: "shop/deployment/front", Allowed: false},
+	{From: "shop/deployment/api", To: "shop/statefulset/db", Allowed: true},
+	{From: "shop/deployment/front", To: "shop/deployment/api", Allowed: true, Ports: []int32{8080}},
+	{From: "shop/deployment/front", To: "shop/statefulset/db", Allowed: false},
+	{From: "shop/statefulset/db", To: "shop/deployment/api", Allowed: false},
+	{From: "shop/statefulset/db", To: "shop/deployment/front", Allowed: false},
+}}
+
+func TestGenerate(t *testing.T) {
+	analysisResult := analysisResultOf(true, route("front-1", "api-1", 8080), route("api-1", "db-0"),
+		route("prom", "api-1", 9090), route("db-0", "db-0"))
+	if diff := cmp.Diff(generatedSuite, Generate(analysisResult, "shop")); diff != "" {
+		t.Errorf("Generate() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	analysisResult := analysisResultOf(false, route("front-1", "api-1", 80), route("api-1", "db-0", 5432),
+		route("front-1", "db-0", 5432))
+	expectations := generatedSuite.Expectations
+	expectedResults := []*Result{
+		{Expectation: expectations[0], Reason: "workload monitoring/pod/prom not found"},
+		{Expectation: expectations[1], Passed: true},
+		{Expectation: expectations[2], Reason: "allowed connection is restricted to ports 5432"},
+		{Expectation: expectations[3], Reason: "allowed connection is denied on ports 8080"},
+		{Expectation: expectations[4], Reason: "denied connection is allowed on ports 5432"},
+		{Expectation: expectations[5], Passed: true},
+		{Expectation: expectations[6], Passed: true},
+	}
+	if diff := cmp.Diff(expectedResults, Check(analysisResult, generatedSuite)); diff != "" {
+		t.Errorf("Check() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckGeneratedPortRanges(t *testing.T) {
+	rangedRoute := route("front-1", "api-1")
+	rangedRoute.Ports = []int32{}
+	rangedRoute.PortRanges = []types.PortRange{{Port: 8000, EndPort: 8100}}
+	analysisResult := analysisResultOf(false, rangedRoute, route("front-1", "api-1", 9090))
+	suite := Generate(analysisResult, "shop")
+	expectedExpectation := &Expectation{From: "shop/deployment/front", To: "shop/deployment/api", Allowed: true,
+		Ports: []int32{9090}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}
+	index := -1
+	for i, expectation := range suite.Expectations {
+		if expectation.From == expectedExpectation.From && expectation.To == expectedExpectation.To {
+			index = i
+		}
+	}
+	if index < 0 {
+		t.Fatalf("Generate() did not expect the connection from %s to %s", expectedExpectation.From,
+			expectedExpectation.To)
+	}
+	if diff := cmp.Diff(expectedExpectation, suite.Expectations[index]); diff != "" {
+		t.Errorf("Generate() expectation mismatch (-want +got):\n%s", diff)
+	}
+	for _, result := range Check(analysisResult, suite) {
+		if !result.Passed {
+			t.Errorf("Check() of the generated suite failed: %s", result.Reason)
+		}
+	}
+	narrowedRoute := route("front-1", "api-1")
+	narrowedRoute.Ports = []int32{9090}
+	narrowedRoute.PortRanges = []types.PortRange{{Port: 8000, EndPort: 8049}}
+	results := Check(analysisResultOf(false, narrowedRoute), suite)
+	expectedReason := "allowed connection is denied on ports 8050-8100"
+	if diff := cmp.Diff(expectedReason, results[index].Reason); diff != "" {
+		t.Errorf("Check() reason mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteGo(t *testing.T) {
+	var content bytes.Buffer
+	err := WriteGo(&content, generatedSuite, "connectivity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "connectivity_test.go", content.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("WriteGo() wrote invalid Go code: %s\n%s", err, content.String())
+	}
+	if file.Name.Name != "connectivity" {
+		t.Errorf("WriteGo() package = %s, expected connectivity", file.Name.Name)
+	}
+}
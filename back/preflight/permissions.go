@@ -0,0 +1,158 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"karto/analyzer/finding"
+	"karto/types"
+	"log"
+	"time"
+)
+
+const (
+	FeatureAnalysis        = "analysis"
+	FeatureCustomResources = "crds"
+	FeatureFindingEvents   = "findingEvents"
+	FeatureVerification    = "verify"
+)
+
+// Options tell the optional features enabled on the command line, whose permissions are checked along with those of
+// the analysis
+type Options struct {
+	CustomResources bool
+	FindingEvents   bool
+	Verification    bool
+}
+
+// Permission is an access to the resources of the cluster required by a feature
+type Permission struct {
+	Feature     string `json:"feature"`
+	Verb        string `json:"verb"`
+	Group       string `json:"group"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource"`
+}
+
+type Report struct {
+	CheckedAt time.Time    `json:"checkedAt"`
+	Missing   []Permission `json:"missing"`
+}
+
+// Requirements lists the permissions of the enabled features, the analysis watching the resources it is made of
+func Requirements(options Options) []Permission {
+	permissions := make([]Permission, 0)
+	watched := []struct {
+		group     string
+		resources []string
+	}{
+		{group: "", resources: []string{"namespaces", "nodes", "pods", "services"}},
+		{group: "networking.k8s.io", resources: []string{"ingresses", "networkpolicies"}},
+		{group: "apps", resources: []string{"replicasets", "statefulsets", "daemonsets", "deployments"}},
+		{group: "admissionregistration.k8s.io",
+			resources: []string{"validatingwebhookconfigurations", "mutatingwebhookconfigurations"}},
+	}
+	for _, watchedGroup := range watched {
+		for _, resource := range watchedGroup.resources {
+			for _, verb := range []string{"list", "watch"} {
+				permissions = append(permissions, Permission{Feature: FeatureAnalysis, Verb: verb,
+					Group: watchedGroup.group, Resource: resource})
+			}
+		}
+	}
+	permissions = append(permissions, Permission{Feature: FeatureAnalysis, Verb: "list",
+		Group: "apiregistration.k8s.io", Resource: "apiservices"})
+	if options.CustomResources {
+		permissions = append(permissions,
+			Permission{Feature: FeatureCustomResources, Verb: "list", Group: "karto.zenika.com",
+				Resource: "kartoconfigs"},
+			Permission{Feature: FeatureCustomResources, Verb: "list", Group: "karto.zenika.com",
+				Resource: "connectivityrules"},
+			Permission{Feature: FeatureCustomResources, Verb: "patch", Group: "karto.zenika.com",
+				Resource: "connectivityrules", Subresource: "status"},
+			Permission{Feature: FeatureCustomResources, Verb: "create", Resource: "events"},
+		)
+	}
+	if options.FindingEvents {
+		permissions = append(permissions,
+			Permission{Feature: FeatureFindingEvents, Verb: "create", Resource: "events"},
+			Permission{Feature: FeatureFindingEvents, Verb: "get", Resource: "namespaces"},
+			Permission{Feature: FeatureFindingEvents, Verb: "get", Group: "networking.k8s.io",
+				Resource: "networkpolicies"},
+		)
+	}
+	if options.Verification {
+		for _, verb := range []string{"get", "create", "delete"} {
+			permissions = append(permissions, Permission{Feature: FeatureVerification, Verb: verb, Resource: "pods"})
+		}
+	}
+	return permissions
+}
+
+// Check asks the API server, through a SelfSubjectAccessReview per permission, which ones are denied to the service
+// account, as watches would otherwise retry forever with opaque errors
+func Check(ctx context.Context, k8sClient kubernetes.Interface, permissions []Permission,
+	checkedAt time.Time) (Report, error) {
+	report := Report{CheckedAt: checkedAt, Missing: make([]Permission, 0)}
+	for _, permission := range permissions {
+		review, err := k8sClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx,
+			&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:        permission.Verb,
+						Group:       permission.Group,
+						Resource:    permission.Resource,
+						Subresource: permission.Subresource,
+					},
+				},
+			}, metav1.CreateOptions{})
+		if err != nil {
+			return report, err
+		}
+		if !review.Status.Allowed {
+			report.Missing = append(report.Missing, permission)
+		}
+	}
+	return report, nil
+}
+
+// Run checks the permissions of the enabled features and logs the missing ones. The features are started anyway, an
+// unchecked permission being no evidence of a missing one.
+func Run(k8sClient kubernetes.Interface, options Options) Report {
+	report, err := Check(context.Background(), k8sClient, Requirements(options), time.Now())
+	if err != nil {
+		log.Printf("Unable to check the permissions of the service account: %s\n", err)
+		return Report{CheckedAt: report.CheckedAt, Missing: make([]Permission, 0)}
+	}
+	for _, permission := range report.Missing {
+		log.Printf("Missing permission: cannot %s %s, required by %s\n", permission.Verb, ResourceOf(permission),
+			permission.Feature)
+	}
+	return report
+}
+
+// Findings reports each missing permission, so that it shows along the results instead of only in the logs
+func (report Report) Findings() []*types.Finding {
+	findings := make([]*types.Finding, 0, len(report.Missing))
+	for _, permission := range report.Missing {
+		resource := ResourceOf(permission)
+		findings = append(findings, finding.NewFinding(finding.RuleMissingPermission, finding.SeverityHigh,
+			types.ResourceRef{Kind: "APIResource", Name: resource}, finding.CodeMissingPermission,
+			map[string]string{"verb": permission.Verb, "resource": resource, "feature": permission.Feature}))
+	}
+	return findings
+}
+
+// ResourceOf formats the resource of a permission as kubectl does, such as networkpolicies.networking.k8s.io
+func ResourceOf(permission Permission) string {
+	resource := permission.Resource
+	if permission.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, permission.Group)
+	}
+	if permission.Subresource != "" {
+		resource = fmt.Sprintf("%s/%s", resource, permission.Subresource)
+	}
+	return resource
+}
@@ -0,0 +1,94 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"karto/analyzer/finding"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	checkedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	permissions := []Permission{
+		{Feature: FeatureAnalysis, Verb: "list", Resource: "pods"},
+		{Feature: FeatureAnalysis, Verb: "watch", Group: "networking.k8s.io", Resource: "networkpolicies"},
+		{Feature: FeatureCustomResources, Verb: "patch", Group: "karto.zenika.com", Resource: "connectivityrules",
+			Subresource: "status"},
+	}
+	tests := []struct {
+		name           string
+		denied         map[string]bool
+		reviewError    error
+		expectedReport Report
+		expectedError  error
+	}{
+		{
+			name:           "no permission is missing when all are allowed",
+			expectedReport: Report{CheckedAt: checkedAt, Missing: []Permission{}},
+		},
+		{
+			name: "denied permissions are missing",
+			denied: map[string]bool{"watch networkpolicies.networking.k8s.io": true,
+				"patch connectivityrules.karto.zenika.com/status": true},
+			expectedReport: Report{CheckedAt: checkedAt, Missing: []Permission{permissions[1], permissions[2]}},
+		},
+		{
+			name:           "reviews which cannot be created are an error",
+			reviewError:    fmt.Errorf("connection refused"),
+			expectedReport: Report{CheckedAt: checkedAt, Missing: []Permission{}},
+			expectedError:  fmt.Errorf("connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset()
+			k8sClient.PrependReactor("create", "selfsubjectaccessreviews",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if tt.reviewError != nil {
+						return true, nil, tt.reviewError
+					}
+					review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+					attributes := review.Spec.ResourceAttributes
+					resource := ResourceOf(Permission{Group: attributes.Group, Resource: attributes.Resource,
+						Subresource: attributes.Subresource})
+					review.Status.Allowed = !tt.denied[attributes.Verb+" "+resource]
+					return true, review, nil
+				})
+			report, err := Check(context.Background(), k8sClient, permissions, checkedAt)
+			if fmt.Sprint(err) != fmt.Sprint(tt.expectedError) {
+				t.Fatalf("Check() error = %v, expected %v", err, tt.expectedError)
+			}
+			if diff := cmp.Diff(tt.expectedReport, report); diff != "" {
+				t.Errorf("Check() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReportFindings(t *testing.T) {
+	report := Report{Missing: []Permission{
+		{Feature: FeatureAnalysis, Verb: "watch", Group: "networking.k8s.io", Resource: "networkpolicies"},
+	}}
+	expectedFindings := []*types.Finding{
+		finding.NewFinding(finding.RuleMissingPermission, finding.SeverityHigh,
+			types.ResourceRef{Kind: "APIResource", Name: "networkpolicies.networking.k8s.io"},
+			finding.CodeMissingPermission, map[string]string{"verb": "watch",
+				"resource": "networkpolicies.networking.k8s.io", "feature": "analysis"}),
+	}
+	findings := report.Findings()
+	if diff := cmp.Diff(expectedFindings, findings); diff != "" {
+		t.Errorf("Findings() result mismatch (-want +got):\n%s", diff)
+	}
+	expectedMessage := "the service account of Karto cannot watch networkpolicies.networking.k8s.io, required by " +
+		"feature analysis"
+	if findings[0].Message != expectedMessage {
+		t.Errorf("Findings() message = %s, expected %s", findings[0].Message, expectedMessage)
+	}
+}
@@ -0,0 +1,110 @@
+package reachers
+
+import (
+	"context"
+	"karto/servicerouting"
+	"karto/types"
+	"sort"
+)
+
+const DefaultMaxHops = 2
+
+// Reacher is either a pod or, when External, the addresses outside of the cluster. Through is the next pod on one
+// of its shortest paths towards the target, nil when it reaches the target directly, and Services are those through
+// which it can address that next pod, after their routing.
+type Reacher struct {
+	Pod      *types.PodRef      `json:"pod"`
+	External bool               `json:"external"`
+	Hops     int                `json:"hops"`
+	Through  *types.PodRef      `json:"through"`
+	Services []types.ServiceRef `json:"services"`
+}
+
+type Result struct {
+	Pod      types.PodRef `json:"pod"`
+	Reachers []*Reacher   `json:"reachers"`
+}
+
+// Find walks the allowed routes backwards from the pod, breadth first, so that every reacher is found at its
+// shortest distance. The addresses outside of the cluster reach the pods whose ingress lets the internet in. The
+// search is abandoned as soon as the context is done.
+func Find(ctx context.Context, analysisResult types.AnalysisResult, pod types.PodRef, maxHops int) (Result,
+	error) {
+	routesByTarget := make(map[types.PodRef][]*types.AllowedRoute)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if allowedRoute.SourcePod != allowedRoute.TargetPod {
+			routesByTarget[allowedRoute.TargetPod] = append(routesByTarget[allowedRoute.TargetPod], allowedRoute)
+		}
+	}
+	services := servicerouting.NewIndex(analysisResult)
+	internetReached := make(map[types.PodRef]bool)
+	for _, internetAccess := range analysisResult.InternetAccesses {
+		internetReached[internetAccess.Pod] = internetAccess.Ingress
+	}
+	result := Result{Pod: pod, Reachers: make([]*Reacher, 0)}
+	reached := map[types.PodRef]bool{pod: true}
+	frontier := []types.PodRef{pod}
+	for hops := 1; hops <= maxHops && len(frontier) > 0; hops++ {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		next := make([]types.PodRef, 0)
+		reachers := make([]*Reacher, 0)
+		var external *Reacher
+		for _, target := range frontier {
+			if external == nil && internetReached[target] {
+				external = newReacher(nil, hops, pod, target, services.Targeting(target))
+				external.External = true
+			}
+			for _, route := range routesByTarget[target] {
+				source := route.SourcePod
+				if reached[source] {
+					continue
+				}
+				reached[source] = true
+				next = append(next, source)
+				reachers = append(reachers, newReacher(&source, hops, pod, target, services.Reaching(route)))
+			}
+		}
+		sort.Slice(reachers, func(i, j int) bool { return podLess(*reachers[i].Pod, *reachers[j].Pod) })
+		result.Reachers = append(result.Reachers, reachers...)
+		if external != nil && !hasExternal(result.Reachers) {
+			result.Reachers = append(result.Reachers, external)
+		}
+		sort.Slice(next, func(i, j int) bool { return podLess(next[i], next[j]) })
+		frontier = next
+	}
+	return result, nil
+}
+
+func newReacher(source *types.PodRef, hops int, pod types.PodRef, through types.PodRef,
+	services []types.ServiceRef) *Reacher {
+	reacher := &Reacher{Pod: source, Hops: hops, Services: services}
+	if through != pod {
+		throughRef := through
+		reacher.Through = &throughRef
+	}
+	sort.Slice(reacher.Services, func(i, j int) bool {
+		if reacher.Services[i].Namespace != reacher.Services[j].Namespace {
+			return reacher.Services[i].Namespace < reacher.Services[j].Namespace
+		}
+		return reacher.Services[i].Name < reacher.Services[j].Name
+	})
+	return reacher
+}
+
+func hasExternal(reachers []*Reacher) bool {
+	for _, reacher := range reachers {
+		if reacher.External {
+			return true
+		}
+	}
+	return false
+}
+
+func podLess(podRef types.PodRef, other types.PodRef) bool {
+	if podRef.Namespace != other.Namespace {
+		return podRef.Namespace < other.Namespace
+	}
+	return podRef.Name < other.Name
+}
@@ -0,0 +1,92 @@
+package reachers
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	type args struct {
+		pod     types.PodRef
+		maxHops int
+	}
+	ingressPod := types.PodRef{Name: "ingress-nginx", Namespace: "ingress"}
+	frontPod := types.PodRef{Name: "front", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api", Namespace: "shop"}
+	batchPod := types.PodRef{Name: "batch", Namespace: "shop"}
+	dbPod := types.PodRef{Name: "db", Namespace: "shop"}
+	apiService := types.ServiceRef{Name: "api", Namespace: "shop"}
+	dbService := types.ServiceRef{Name: "db", Namespace: "shop"}
+	analysisResult := types.AnalysisResult{
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: ingressPod, TargetPod: frontPod},
+			{SourcePod: frontPod, TargetPod: apiPod},
+			{SourcePod: apiPod, TargetPod: dbPod},
+			{SourcePod: batchPod, TargetPod: dbPod},
+			{SourcePod: batchPod, TargetPod: apiPod},
+		},
+		Services: []*types.Service{
+			{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+			{Name: "db", Namespace: "shop", TargetPods: []types.PodRef{dbPod}},
+		},
+		InternetAccesses: []*types.InternetAccess{
+			{Pod: ingressPod, Ingress: true, Egress: true},
+			{Pod: batchPod, Ingress: false, Egress: true},
+		},
+	}
+	tests := []struct {
+		name           string
+		args           args
+		expectedResult Result
+	}{
+		{
+			name: "direct reachers are found through the services of the pod",
+			args: args{pod: dbPod, maxHops: 1},
+			expectedResult: Result{Pod: dbPod, Reachers: []*Reacher{
+				{Pod: &apiPod, Hops: 1, Services: []types.ServiceRef{dbService}},
+				{Pod: &batchPod, Hops: 1, Services: []types.ServiceRef{dbService}},
+			}},
+		},
+		{
+			name: "reachers are found at their shortest distance through intermediate pods",
+			args: args{pod: dbPod, maxHops: 2},
+			expectedResult: Result{Pod: dbPod, Reachers: []*Reacher{
+				{Pod: &apiPod, Hops: 1, Services: []types.ServiceRef{dbService}},
+				{Pod: &batchPod, Hops: 1, Services: []types.ServiceRef{dbService}},
+				{Pod: &frontPod, Hops: 2, Through: &apiPod, Services: []types.ServiceRef{apiService}},
+			}},
+		},
+		{
+			name: "external sources reach the pods open to the internet",
+			args: args{pod: apiPod, maxHops: 3},
+			expectedResult: Result{Pod: apiPod, Reachers: []*Reacher{
+				{Pod: &batchPod, Hops: 1, Services: []types.ServiceRef{apiService}},
+				{Pod: &frontPod, Hops: 1, Services: []types.ServiceRef{apiService}},
+				{Pod: &ingressPod, Hops: 2, Through: &frontPod, Services: []types.ServiceRef{}},
+				{External: true, Hops: 3, Through: &ingressPod, Services: []types.ServiceRef{}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Find(context.Background(), analysisResult, tt.args.pod, tt.args.maxHops)
+			if err != nil {
+				t.Fatalf("Find() unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tt.expectedResult, result); diff != "" {
+				t.Errorf("Find() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Find(ctx, types.AnalysisResult{}, types.PodRef{Name: "db", Namespace: "shop"}, DefaultMaxHops)
+	if err != context.Canceled {
+		t.Errorf("Find() expected the cancellation of the context, got %v", err)
+	}
+}
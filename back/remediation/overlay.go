@@ -0,0 +1,143 @@
+package remediation
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"karto/analyzer/finding"
+	"karto/types"
+	"path"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"time"
+)
+
+const kustomizationHeader = "# Add the manifests of the namespace to the resources for the patches to apply\n"
+
+type kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources,omitempty"`
+	Patches    []patch  `json:"patches,omitempty"`
+}
+
+type patch struct {
+	Patch string `json:"patch"`
+}
+
+type overlay struct {
+	files         map[string][]byte
+	kustomization kustomization
+	seen          map[string]bool
+}
+
+func WriteOverlays(w io.Writer, findings []*types.Finding) error {
+	overlays, err := overlaysByNamespace(findings)
+	if err != nil {
+		return err
+	}
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	namespaces := make([]string, 0)
+	for namespace := range overlays {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	now := time.Now()
+	for _, namespace := range namespaces {
+		files := overlays[namespace].files
+		fileNames := make([]string, 0)
+		for fileName := range files {
+			fileNames = append(fileNames, fileName)
+		}
+		sort.Strings(fileNames)
+		for _, fileName := range fileNames {
+			err = tarWriter.WriteHeader(&tar.Header{
+				Name:    path.Join(namespace, fileName),
+				Mode:    0644,
+				Size:    int64(len(files[fileName])),
+				ModTime: now,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = tarWriter.Write(files[fileName])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func overlaysByNamespace(findings []*types.Finding) (map[string]*overlay, error) {
+	overlays := make(map[string]*overlay)
+	for _, flaggedFinding := range findings {
+		// Suppressed findings are accepted risks which do not need to be fixed
+		if flaggedFinding.Remediation == nil || flaggedFinding.Suppression != nil {
+			continue
+		}
+		remediation := flaggedFinding.Remediation
+		namespaceOverlay, ok := overlays[remediation.Resource.Namespace]
+		if !ok {
+			namespaceOverlay = &overlay{
+				files: make(map[string][]byte),
+				kustomization: kustomization{
+					APIVersion: "kustomize.config.k8s.io/v1beta1",
+					Kind:       "Kustomization",
+				},
+				seen: make(map[string]bool),
+			}
+			overlays[remediation.Resource.Namespace] = namespaceOverlay
+		}
+		err := namespaceOverlay.add(remediation)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, namespaceOverlay := range overlays {
+		kustomizationYAML, err := yaml.Marshal(namespaceOverlay.kustomization)
+		if err != nil {
+			return nil, err
+		}
+		namespaceOverlay.files["kustomization.yaml"] = append([]byte(kustomizationHeader), kustomizationYAML...)
+	}
+	return overlays, nil
+}
+
+func (overlay *overlay) add(remediation *types.Remediation) error {
+	key := remediation.Operation + "/" + remediation.Resource.Kind + "/" + remediation.Resource.Name
+	if overlay.seen[key] {
+		return nil
+	}
+	overlay.seen[key] = true
+	switch remediation.Operation {
+	case finding.RemediationCreate:
+		manifest, err := yaml.Marshal(remediation.Manifest)
+		if err != nil {
+			return err
+		}
+		fileName := fmt.Sprintf("%s.yaml", remediation.Resource.Name)
+		overlay.files[fileName] = manifest
+		overlay.kustomization.Resources = append(overlay.kustomization.Resources, fileName)
+	case finding.RemediationPatch:
+		manifest, err := yaml.Marshal(remediation.Manifest)
+		if err != nil {
+			return err
+		}
+		overlay.kustomization.Patches = append(overlay.kustomization.Patches, patch{Patch: string(manifest)})
+	case finding.RemediationDelete:
+		deletion := fmt.Sprintf("$patch: delete\napiVersion: networking.k8s.io/v1\nkind: %s\nmetadata:\n"+
+			"  name: %s\n  namespace: %s\n", remediation.Resource.Kind, remediation.Resource.Name,
+			remediation.Resource.Namespace)
+		overlay.kustomization.Patches = append(overlay.kustomization.Patches, patch{Patch: deletion})
+	default:
+		return fmt.Errorf("unknown remediation operation %s", remediation.Operation)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package remediation
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/google/go-cmp/cmp"
+	"io"
+	"io/ioutil"
+	"karto/analyzer/finding"
+	"karto/authoring"
+	"karto/types"
+	"testing"
+)
+
+func TestWriteOverlays(t *testing.T) {
+	unusedPolicy := types.ResourceRef{Kind: "NetworkPolicy", Name: "old", Namespace: "shop"}
+	findings := []*types.Finding{
+		{Rule: "prod-default-deny", Remediation: &types.Remediation{
+			Operation: finding.RemediationCreate,
+			Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: "default-deny-ingress", Namespace: "shop"},
+			Manifest:  authoring.DefaultDenyPolicy("shop", "Ingress"),
+		}},
+		{Rule: finding.RuleUnusedNetworkPolicy, Remediation: &types.Remediation{
+			Operation: finding.RemediationDelete,
+			Resource:  unusedPolicy,
+		}},
+		{Rule: finding.RuleUnusedNetworkPolicy, Remediation: &types.Remediation{
+			Operation: finding.RemediationDelete,
+			Resource:  types.ResourceRef{Kind: "NetworkPolicy", Name: "accepted", Namespace: "other"},
+		}, Suppression: &types.FindingSuppression{Reason: "kept on purpose"}},
+		{Rule: finding.RulePodNotIngressIsolated},
+	}
+	var archive bytes.Buffer
+	err := WriteOverlays(&archive, findings)
+	if err != nil {
+		t.Fatalf("WriteOverlays() unexpected error: %v", err)
+	}
+	files := readArchive(t, archive.Bytes())
+	expectedFiles := map[string]string{
+		"shop/default-deny-ingress.yaml": "apiVersion: networking.k8s.io/v1\n" +
+			"kind: NetworkPolicy\n" +
+			"metadata:\n" +
+			"  creationTimestamp: null\n" +
+			"  name: default-deny-ingress\n" +
+			"  namespace: shop\n" +
+			"spec:\n" +
+			"  podSelector: {}\n" +
+			"  policyTypes:\n" +
+			"  - Ingress\n",
+		"shop/kustomization.yaml": kustomizationHeader +
+			"apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"kind: Kustomization\n" +
+			"patches:\n" +
+			"- patch: |\n" +
+			"    $patch: delete\n" +
+			"    apiVersion: networking.k8s.io/v1\n" +
+			"    kind: NetworkPolicy\n" +
+			"    metadata:\n" +
+			"      name: old\n" +
+			"      namespace: shop\n" +
+			"resources:\n" +
+			"- default-deny-ingress.yaml\n",
+	}
+	if diff := cmp.Diff(expectedFiles, files); diff != "" {
+		t.Errorf("WriteOverlays() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func readArchive(t *testing.T, archive []byte) map[string]string {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	files := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+}
@@ -0,0 +1,110 @@
+package replication
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"karto/store"
+	"karto/types"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	resultsCollection = "analysis"
+	latestResultKey   = "latest"
+)
+
+type publisher struct {
+	store         store.Store
+	mutex         sync.Mutex
+	pendingResult *types.AnalysisResult
+	pending       chan struct{}
+}
+
+// Publish writes each analysis result to the shared store, where replicas serving the API read it from. Writes happen
+// in the background and only the latest result is kept when the store is slower than the analysis.
+func Publish(sharedStore store.Store, resultsChannel <-chan types.AnalysisResult) {
+	publisher := &publisher{
+		store:   sharedStore,
+		pending: make(chan struct{}, 1),
+	}
+	go publisher.publishPending()
+	for {
+		analysisResult := <-resultsChannel
+		publisher.mutex.Lock()
+		publisher.pendingResult = &analysisResult
+		publisher.mutex.Unlock()
+		select {
+		case publisher.pending <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (publisher *publisher) publishPending() {
+	for range publisher.pending {
+		publisher.mutex.Lock()
+		analysisResult := publisher.pendingResult
+		publisher.pendingResult = nil
+		publisher.mutex.Unlock()
+		if analysisResult == nil {
+			continue
+		}
+		err := publish(publisher.store, *analysisResult)
+		if err != nil {
+			log.Printf("Unable to publish the analysis result to the store: %s\n", err)
+		}
+	}
+}
+
+func publish(sharedStore store.Store, analysisResult types.AnalysisResult) error {
+	var content bytes.Buffer
+	writer := gzip.NewWriter(&content)
+	err := json.NewEncoder(writer).Encode(analysisResult)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return sharedStore.Put(resultsCollection, latestResultKey, content.Bytes())
+}
+
+// Follow polls the shared store for the analysis results published by the analyzing instance, and sends them when
+// they change
+func Follow(sharedStore store.Store, interval time.Duration, resultsChannel chan<- types.AnalysisResult) {
+	var lastContent []byte
+	for {
+		content, ok, err := sharedStore.Get(resultsCollection, latestResultKey)
+		if err != nil {
+			log.Printf("Unable to read the analysis result from the store: %s\n", err)
+		} else if ok && !bytes.Equal(content, lastContent) {
+			analysisResult, err := decode(content)
+			if err != nil {
+				log.Printf("Unable to decode the analysis result from the store: %s\n", err)
+			} else {
+				lastContent = content
+				resultsChannel <- analysisResult
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func decode(content []byte) (types.AnalysisResult, error) {
+	var analysisResult types.AnalysisResult
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return analysisResult, err
+	}
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return analysisResult, err
+	}
+	err = json.Unmarshal(decompressed, &analysisResult)
+	return analysisResult, err
+}
@@ -0,0 +1,38 @@
+package replication
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/store"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestPublishAndFollow(t *testing.T) {
+	sharedStore := store.NewMemoryStore()
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{{Name: "pod1", Namespace: "ns", Labels: map[string]string{"app": "front"}}},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: types.PodRef{Name: "pod1", Namespace: "ns"}, TargetPod: types.PodRef{Name: "pod1",
+				Namespace: "ns"}, Ports: []int32{80}},
+		},
+	}
+	resultsChannel := make(chan types.AnalysisResult)
+	followedResultsChannel := make(chan types.AnalysisResult)
+	go Publish(sharedStore, resultsChannel)
+	go Follow(sharedStore, 10*time.Millisecond, followedResultsChannel)
+	resultsChannel <- analysisResult
+	select {
+	case followedResult := <-followedResultsChannel:
+		if diff := cmp.Diff(analysisResult, followedResult); diff != "" {
+			t.Errorf("Follow() result mismatch (-want +got):\n%s", diff)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Test timed out (nothing was followed)")
+	}
+	select {
+	case <-followedResultsChannel:
+		t.Errorf("Follow() sent an unchanged result again")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NumbersExact   = "exact"
+	NumbersGrouped = "grouped"
+	NumbersCompact = "compact"
+)
+
+var compactUnits = []struct {
+	value  int
+	suffix string
+}{
+	{value: 1000000000, suffix: "B"},
+	{value: 1000000, suffix: "M"},
+	{value: 1000, suffix: "k"},
+}
+
+// TextFormat localizes the dates and numbers of the text report for its readers. Its zero value formats them as the
+// scheduled reports do.
+type TextFormat struct {
+	// Location is the time zone of the dates, that of the generation of the report when nil
+	Location *time.Location
+	// DateLayout is the Go layout of the date of the subject and of the timestamps of digests
+	DateLayout string
+	// Numbers is NumbersExact, NumbersGrouped or NumbersCompact
+	Numbers string
+	// ThousandsSeparator groups the digits of NumbersGrouped, a comma when empty
+	ThousandsSeparator string
+}
+
+func ValidNumbers(numbers string) bool {
+	return numbers == "" || numbers == NumbersExact || numbers == NumbersGrouped || numbers == NumbersCompact
+}
+
+func (format TextFormat) date(date time.Time, defaultLayout string) string {
+	if format.Location != nil {
+		date = date.In(format.Location)
+	}
+	if format.DateLayout != "" {
+		return date.Format(format.DateLayout)
+	}
+	return date.Format(defaultLayout)
+}
+
+// number summarizes large counts to three significant digits at most in the compact format, such as 12.3k
+func (format TextFormat) number(count int) string {
+	switch format.Numbers {
+	case NumbersGrouped:
+		separator := format.ThousandsSeparator
+		if separator == "" {
+			separator = ","
+		}
+		return groupDigits(count, separator)
+	case NumbersCompact:
+		for _, unit := range compactUnits {
+			if count >= unit.value || count <= -unit.value {
+				return compactNumber(count, unit.value) + unit.suffix
+			}
+		}
+	}
+	return strconv.Itoa(count)
+}
+
+func groupDigits(count int, separator string) string {
+	digits := strconv.Itoa(count)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	groups := make([]string, 0, len(digits)/3+1)
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	return sign + strings.Join(append([]string{digits}, groups...), separator)
+}
+
+// Digits are truncated rather than rounded, so that 999999 reads 999k and not 1000k
+func compactNumber(count int, unit int) string {
+	scale := 1
+	if units := count / unit; units < 10 && units > -10 {
+		scale = 100
+	} else if units < 100 && units > -100 {
+		scale = 10
+	}
+	return strconv.FormatFloat(float64(count/(unit/scale))/float64(scale), 'f', -1, 64)
+}
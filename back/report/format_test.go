@@ -0,0 +1,72 @@
+package report
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestTextFormatNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   TextFormat
+		count    int
+		expected string
+	}{
+		{name: "exact by default", count: 1234567, expected: "1234567"},
+		{name: "grouped with commas", format: TextFormat{Numbers: NumbersGrouped}, count: 1234567,
+			expected: "1,234,567"},
+		{name: "grouped with the given separator", format: TextFormat{Numbers: NumbersGrouped,
+			ThousandsSeparator: " "}, count: -12345, expected: "-12 345"},
+		{name: "small numbers are not grouped", format: TextFormat{Numbers: NumbersGrouped}, count: 999,
+			expected: "999"},
+		{name: "small numbers are not compacted", format: TextFormat{Numbers: NumbersCompact}, count: 999,
+			expected: "999"},
+		{name: "thousands are compacted to three digits", format: TextFormat{Numbers: NumbersCompact}, count: 1234,
+			expected: "1.23k"},
+		{name: "trailing zeros are dropped", format: TextFormat{Numbers: NumbersCompact}, count: 12000,
+			expected: "12k"},
+		{name: "digits are truncated", format: TextFormat{Numbers: NumbersCompact}, count: 999999,
+			expected: "999k"},
+		{name: "millions", format: TextFormat{Numbers: NumbersCompact}, count: 45670000, expected: "45.6M"},
+		{name: "billions", format: TextFormat{Numbers: NumbersCompact}, count: 2000000000, expected: "2B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.number(tt.count); got != tt.expected {
+				t.Errorf("number() = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteFormattedText(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := Summary{
+		GeneratedAt:        time.Date(2021, time.April, 1, 23, 30, 0, 0, time.UTC),
+		Namespaces:         12,
+		Pods:               1500,
+		AllowedRoutes:      2345678,
+		FindingsBySeverity: map[string]int{"high": 1200, "medium": 0, "low": 3},
+	}
+	var text bytes.Buffer
+	err = WriteFormattedText(&text, summary, TextFormat{Location: paris, DateLayout: "02/01/2006 15:04 MST",
+		Numbers: NumbersCompact})
+	if err != nil {
+		t.Fatalf("WriteFormattedText() returned an error: %s", err)
+	}
+	expectedText := `Karto network exposure report of 02/04/2021 01:30 CEST
+
+Namespaces: 12
+Pods: 1.5k (0 isolated for ingress, 0 isolated for egress)
+Allowed routes: 2.34M
+Findings: 1.2k high, 0 medium, 3 low (0 suppressed)
+`
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("WriteFormattedText() result mismatch (-want +got):\n%s", diff)
+	}
+}
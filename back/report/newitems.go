@@ -0,0 +1,187 @@
+package report
+
+import (
+	"fmt"
+	"karto/drift"
+	"karto/types"
+	"sort"
+	"time"
+)
+
+const (
+	defaultWindow = 24 * time.Hour
+	// Busy clusters may see many new items at once, the text of a digest only lists the first ones of each kind
+	digestLimit = 20
+)
+
+type NewItems struct {
+	Since           time.Time      `json:"since"`
+	Routes          []*NewRoute    `json:"routes"`
+	Workloads       []*NewResource `json:"workloads"`
+	NetworkPolicies []*NewResource `json:"networkPolicies"`
+}
+
+type NewRoute struct {
+	Source    types.ResourceRef `json:"source"`
+	Target    types.ResourceRef `json:"target"`
+	FirstSeen time.Time         `json:"firstSeen"`
+}
+
+type NewResource struct {
+	Resource  types.ResourceRef `json:"resource"`
+	FirstSeen time.Time         `json:"firstSeen"`
+}
+
+// resourceTracker remembers when workloads and network policies first appeared. Those of the first analysis result
+// are the baseline and are never deemed new.
+type resourceTracker struct {
+	firstSeen map[types.ResourceRef]time.Time
+	baselined bool
+}
+
+func newResourceTracker() *resourceTracker {
+	return &resourceTracker{
+		firstSeen: make(map[types.ResourceRef]time.Time),
+	}
+}
+
+func (tracker *resourceTracker) observe(analysisResult types.AnalysisResult, now time.Time) {
+	present := make(map[types.ResourceRef]bool)
+	for _, resource := range resourcesOf(analysisResult) {
+		present[resource] = true
+		if _, ok := tracker.firstSeen[resource]; ok {
+			continue
+		}
+		firstSeen := now
+		if !tracker.baselined {
+			firstSeen = time.Time{}
+		}
+		tracker.firstSeen[resource] = firstSeen
+	}
+	// Resources which disappear are new again if they come back
+	for resource := range tracker.firstSeen {
+		if !present[resource] {
+			delete(tracker.firstSeen, resource)
+		}
+	}
+	tracker.baselined = true
+}
+
+// Pods are tracked through their workload, so that rollouts are not reported
+func resourcesOf(analysisResult types.AnalysisResult) []types.ResourceRef {
+	workloads := drift.PodWorkloads(analysisResult)
+	resources := make([]types.ResourceRef, 0)
+	for _, pod := range analysisResult.Pods {
+		resources = append(resources, workloads(types.PodRef{Name: pod.Name, Namespace: pod.Namespace}))
+	}
+	for _, policy := range analysisResult.NetworkPolicies {
+		resources = append(resources,
+			types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace})
+	}
+	return resources
+}
+
+// Routes are only new if their first appearance is tracked by the route history
+func (tracker *resourceTracker) collect(analysisResult types.AnalysisResult, since time.Time) NewItems {
+	newItems := NewItems{
+		Since:           since,
+		Routes:          make([]*NewRoute, 0),
+		Workloads:       make([]*NewResource, 0),
+		NetworkPolicies: make([]*NewResource, 0),
+	}
+	workloads := drift.PodWorkloads(analysisResult)
+	seenRoutes := make(map[NewRoute]bool)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if allowedRoute.FirstSeen == nil || !allowedRoute.FirstSeen.After(since) {
+			continue
+		}
+		route := NewRoute{Source: workloads(allowedRoute.SourcePod), Target: workloads(allowedRoute.TargetPod),
+			FirstSeen: *allowedRoute.FirstSeen}
+		if !seenRoutes[route] {
+			seenRoutes[route] = true
+			newItems.Routes = append(newItems.Routes, &route)
+		}
+	}
+	for resource, firstSeen := range tracker.firstSeen {
+		if !firstSeen.After(since) {
+			continue
+		}
+		newResource := &NewResource{Resource: resource, FirstSeen: firstSeen}
+		if resource.Kind == "NetworkPolicy" {
+			newItems.NetworkPolicies = append(newItems.NetworkPolicies, newResource)
+		} else {
+			newItems.Workloads = append(newItems.Workloads, newResource)
+		}
+	}
+	sort.Slice(newItems.Routes, func(i, j int) bool {
+		return newRouteLess(newItems.Routes[i], newItems.Routes[j])
+	})
+	sortNewResources(newItems.Workloads)
+	sortNewResources(newItems.NetworkPolicies)
+	return newItems
+}
+
+func (newItems NewItems) isEmpty() bool {
+	return len(newItems.Routes) == 0 && len(newItems.Workloads) == 0 && len(newItems.NetworkPolicies) == 0
+}
+
+func newItemsLines(newItems NewItems, format TextFormat) []string {
+	lines := []string{
+		fmt.Sprintf("New since %s: %s routes, %s workloads, %s network policies",
+			format.date(newItems.Since, time.RFC3339), format.number(len(newItems.Routes)),
+			format.number(len(newItems.Workloads)), format.number(len(newItems.NetworkPolicies))),
+	}
+	routeLines := make([]string, 0)
+	for _, route := range newItems.Routes {
+		routeLines = append(routeLines, fmt.Sprintf("- %s %s -> %s %s", route.Source.Kind,
+			resourceNameOf(route.Source), route.Target.Kind, resourceNameOf(route.Target)))
+	}
+	lines = append(lines, digestLines("Routes", routeLines, format)...)
+	lines = append(lines, digestLines("Workloads", newResourceLines(newItems.Workloads), format)...)
+	lines = append(lines, digestLines("Network policies", newResourceLines(newItems.NetworkPolicies), format)...)
+	return lines
+}
+
+func newResourceLines(newResources []*NewResource) []string {
+	lines := make([]string, 0)
+	for _, newResource := range newResources {
+		lines = append(lines, fmt.Sprintf("- %s %s", newResource.Resource.Kind,
+			resourceNameOf(newResource.Resource)))
+	}
+	return lines
+}
+
+func digestLines(title string, itemLines []string, format TextFormat) []string {
+	if len(itemLines) == 0 {
+		return nil
+	}
+	lines := []string{"", title + ":"}
+	if len(itemLines) <= digestLimit {
+		return append(lines, itemLines...)
+	}
+	lines = append(lines, itemLines[:digestLimit]...)
+	return append(lines, fmt.Sprintf("- and %s more", format.number(len(itemLines)-digestLimit)))
+}
+
+func sortNewResources(newResources []*NewResource) {
+	sort.Slice(newResources, func(i, j int) bool {
+		return resourceLess(newResources[i].Resource, newResources[j].Resource)
+	})
+}
+
+func newRouteLess(route1 *NewRoute, route2 *NewRoute) bool {
+	if route1.Source != route2.Source {
+		return resourceLess(route1.Source, route2.Source)
+	}
+	return resourceLess(route1.Target, route2.Target)
+}
+
+func resourceLess(resource1 types.ResourceRef, resource2 types.ResourceRef) bool {
+	if resource1.Namespace != resource2.Namespace {
+		return resource1.Namespace < resource2.Namespace
+	}
+	if resource1.Kind != resource2.Kind {
+		return resource1.Kind < resource2.Kind
+	}
+	return resource1.Name < resource2.Name
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"karto/analyzer/finding"
+	"karto/types"
+	"sort"
+	"time"
+)
+
+const reportDateFormat = "2006-01-02"
+
+var severities = []string{finding.SeverityHigh, finding.SeverityMedium, finding.SeverityLow}
+
+type Summary struct {
+	GeneratedAt         time.Time        `json:"generatedAt"`
+	Namespaces          int              `json:"namespaces"`
+	Pods                int              `json:"pods"`
+	IngressIsolatedPods int              `json:"ingressIsolatedPods"`
+	EgressIsolatedPods  int              `json:"egressIsolatedPods"`
+	AllowedRoutes       int              `json:"allowedRoutes"`
+	FindingsBySeverity  map[string]int   `json:"findingsBySeverity"`
+	SuppressedFindings  int              `json:"suppressedFindings"`
+	Findings            []*types.Finding `json:"findings"`
+	// Compliance holds the results of the profiles of hardening guidance selected in the configuration
+	Compliance []*types.ComplianceProfile `json:"compliance"`
+	NewItems   *NewItems                  `json:"newItems"`
+}
+
+// Summarize only lists the findings which are not suppressed, most severe first
+func Summarize(analysisResult types.AnalysisResult, generatedAt time.Time) Summary {
+	summary := Summary{
+		GeneratedAt:        generatedAt,
+		Namespaces:         len(analysisResult.Namespaces),
+		Pods:               len(analysisResult.Pods),
+		AllowedRoutes:      len(analysisResult.AllowedRoutes),
+		FindingsBySeverity: make(map[string]int),
+		Findings:           make([]*types.Finding, 0),
+		Compliance:         analysisResult.Compliance,
+	}
+	for _, podIsolation := range analysisResult.PodIsolations {
+		if podIsolation.IsIngressIsolated {
+			summary.IngressIsolatedPods++
+		}
+		if podIsolation.IsEgressIsolated {
+			summary.EgressIsolatedPods++
+		}
+	}
+	for _, severity := range severities {
+		summary.FindingsBySeverity[severity] = 0
+	}
+	for _, finding := range analysisResult.Findings {
+		if finding.Suppression != nil {
+			summary.SuppressedFindings++
+			continue
+		}
+		summary.FindingsBySeverity[finding.Severity]++
+		summary.Findings = append(summary.Findings, finding)
+	}
+	sort.SliceStable(summary.Findings, func(i, j int) bool {
+		return severityRank(summary.Findings[i].Severity) < severityRank(summary.Findings[j].Severity)
+	})
+	return summary
+}
+
+func Subject(summary Summary) string {
+	return subject(summary, TextFormat{})
+}
+
+func subject(summary Summary, format TextFormat) string {
+	if summary.NewItems != nil {
+		return "Karto new network exposure items of " + format.date(summary.GeneratedAt, reportDateFormat)
+	}
+	return "Karto network exposure report of " + format.date(summary.GeneratedAt, reportDateFormat)
+}
+
+func WriteText(w io.Writer, summary Summary) error {
+	return WriteFormattedText(w, summary, TextFormat{})
+}
+
+// WriteFormattedText writes the text report with the dates and numbers localized for its readers
+func WriteFormattedText(w io.Writer, summary Summary, format TextFormat) error {
+	number := format.number
+	lines := []string{
+		subject(summary, format),
+		"",
+		fmt.Sprintf("Namespaces: %s", number(summary.Namespaces)),
+		fmt.Sprintf("Pods: %s (%s isolated for ingress, %s isolated for egress)", number(summary.Pods),
+			number(summary.IngressIsolatedPods), number(summary.EgressIsolatedPods)),
+		fmt.Sprintf("Allowed routes: %s", number(summary.AllowedRoutes)),
+		fmt.Sprintf("Findings: %s high, %s medium, %s low (%s suppressed)",
+			number(summary.FindingsBySeverity[finding.SeverityHigh]),
+			number(summary.FindingsBySeverity[finding.SeverityMedium]),
+			number(summary.FindingsBySeverity[finding.SeverityLow]), number(summary.SuppressedFindings)),
+	}
+	if summary.NewItems != nil {
+		// Digests of new items do not repeat the findings, to keep their volume manageable
+		lines = append(lines, "")
+		lines = append(lines, newItemsLines(*summary.NewItems, format)...)
+	} else {
+		lines = append(lines, complianceLines(summary.Compliance, format)...)
+		lines = append(lines, findingLines(summary.Findings)...)
+	}
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findingLines(findings []*types.Finding) []string {
+	if len(findings) == 0 {
+		return nil
+	}
+	lines := []string{""}
+	for _, finding := range findings {
+		lines = append(lines, fmt.Sprintf("- [%s] %s %s: %s", finding.Severity, finding.Resource.Kind,
+			resourceNameOf(finding.Resource), finding.Message))
+	}
+	return lines
+}
+
+func complianceLines(profiles []*types.ComplianceProfile, format TextFormat) []string {
+	if len(profiles) == 0 {
+		return nil
+	}
+	lines := []string{"", "Compliance:"}
+	for _, profile := range profiles {
+		lines = append(lines, fmt.Sprintf("- %s: %s passed, %s failed", profile.Title,
+			format.number(profile.Passed), format.number(profile.Failed)))
+		for _, check := range profile.Checks {
+			if check.Passed {
+				lines = append(lines, fmt.Sprintf("  - [passed] %s", check.Title))
+			} else {
+				lines = append(lines, fmt.Sprintf("  - [failed] %s (%s findings)", check.Title,
+					format.number(len(check.Findings))))
+			}
+		}
+	}
+	return lines
+}
+
+func resourceNameOf(resource types.ResourceRef) string {
+	if resource.Namespace == "" {
+		return resource.Name
+	}
+	return resource.Namespace + "/" + resource.Name
+}
+
+func severityRank(severity string) int {
+	for i, knownSeverity := range severities {
+		if severity == knownSeverity {
+			return i
+		}
+	}
+	return len(severities)
+}
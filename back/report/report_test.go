@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	generatedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	lowFinding := &types.Finding{Rule: "rule1", Severity: "low",
+		Resource: types.ResourceRef{Kind: "Pod", Name: "pod1", Namespace: "ns"}, Message: "low"}
+	highFinding := &types.Finding{Rule: "rule2", Severity: "high",
+		Resource: types.ResourceRef{Kind: "Namespace", Name: "ns"}, Message: "high"}
+	suppressedFinding := &types.Finding{Rule: "rule3", Severity: "high",
+		Suppression: &types.FindingSuppression{Fingerprint: "abc"}}
+	complianceProfile := &types.ComplianceProfile{Profile: "nsa", Title: "NSA/CISA Kubernetes Hardening Guidance",
+		Passed: 1, Failed: 1, Checks: []*types.ComplianceCheck{
+			{Rule: "compliance-default-deny", Title: "Deny all traffic by default", Findings: []string{"abc", "def"}},
+			{Rule: "compliance-unrestricted-egress", Title: "Do not allow unrestricted egress", Passed: true,
+				Findings: []string{}},
+		}}
+	analysisResult := types.AnalysisResult{
+		Namespaces: []*types.Namespace{{Name: "ns"}},
+		Pods:       []*types.Pod{{Name: "pod1", Namespace: "ns"}, {Name: "pod2", Namespace: "ns"}},
+		PodIsolations: []*types.PodIsolation{
+			{Pod: podRef1, IsIngressIsolated: true, IsEgressIsolated: true},
+			{Pod: podRef2, IsIngressIsolated: true},
+		},
+		AllowedRoutes: []*types.AllowedRoute{{SourcePod: podRef1, TargetPod: podRef2}},
+		Findings:      []*types.Finding{lowFinding, suppressedFinding, highFinding},
+		Compliance:    []*types.ComplianceProfile{complianceProfile},
+	}
+	summary := Summarize(analysisResult, generatedAt)
+	expectedSummary := Summary{
+		GeneratedAt:         generatedAt,
+		Namespaces:          1,
+		Pods:                2,
+		IngressIsolatedPods: 2,
+		EgressIsolatedPods:  1,
+		AllowedRoutes:       1,
+		FindingsBySeverity:  map[string]int{"high": 1, "medium": 0, "low": 1},
+		SuppressedFindings:  1,
+		Findings:            []*types.Finding{highFinding, lowFinding},
+		Compliance:          []*types.ComplianceProfile{complianceProfile},
+	}
+	if diff := cmp.Diff(expectedSummary, summary); diff != "" {
+		t.Errorf("Summarize() result mismatch (-want +got):\n%s", diff)
+	}
+	var text bytes.Buffer
+	err := WriteText(&text, summary)
+	if err != nil {
+		t.Fatalf("WriteText() returned an error: %s", err)
+	}
+	expectedText := "Karto network exposure report of 2021-04-01\n" +
+		"\n" +
+		"Namespaces: 1\n" +
+		"Pods: 2 (2 isolated for ingress, 1 isolated for egress)\n" +
+		"Allowed routes: 1\n" +
+		"Findings: 1 high, 0 medium, 1 low (1 suppressed)\n" +
+		"\n" +
+		"Compliance:\n" +
+		"- NSA/CISA Kubernetes Hardening Guidance: 1 passed, 1 failed\n" +
+		"  - [failed] Deny all traffic by default (2 findings)\n" +
+		"  - [passed] Do not allow unrestricted egress\n" +
+		"\n" +
+		"- [high] Namespace ns: high\n" +
+		"- [low] Pod ns/pod1: low\n"
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("WriteText() result mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,193 @@
+package report
+
+import (
+	"bytes"
+	"karto/config"
+	"karto/cron"
+	"karto/suppression"
+	"karto/types"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type reporter struct {
+	schedule           cron.Schedule
+	senders            []Sender
+	suppressionStore   suppression.Store
+	mode               string
+	window             time.Duration
+	resourceTracker    *resourceTracker
+	mutex              sync.Mutex
+	lastAnalysisResult *types.AnalysisResult
+	lastReportAt       time.Time
+	teamLabel          string
+	teamSenders        map[string]Sender
+	now                func() time.Time
+}
+
+func newReporter(schedule cron.Schedule, senders []Sender, suppressionStore suppression.Store, mode string,
+	window time.Duration) *reporter {
+	if window == 0 {
+		window = defaultWindow
+	}
+	return &reporter{
+		schedule:         schedule,
+		senders:          senders,
+		suppressionStore: suppressionStore,
+		mode:             mode,
+		window:           window,
+		resourceTracker:  newResourceTracker(),
+		now:              time.Now,
+	}
+}
+
+// Schedule sends the report of the last analysis result on every occurrence of the configured cron expression. In the
+// new items mode, it instead sends a digest of what appeared since the previous one, within the configured window, and
+// each team the digest of the items involving its workloads.
+// The targets of the report follow the configurations received on reportConfigs, which may be nil.
+func Schedule(reportConfig config.ReportConfig, suppressionStore suppression.Store,
+	resultsChannel <-chan types.AnalysisResult, reportConfigs <-chan config.ReportConfig) {
+	schedule, err := cron.Parse(reportConfig.Schedule)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// The window is validated with the configuration
+	window, _ := time.ParseDuration(reportConfig.Window)
+	reporter := newReporter(schedule, nil, suppressionStore, reportConfig.Mode, window)
+	reporter.setTargets(reportConfig)
+	go reporter.reportPeriodically()
+	for {
+		select {
+		case analysisResult := <-resultsChannel:
+			reporter.observe(analysisResult)
+		case reportConfig = <-reportConfigs:
+			reporter.setTargets(reportConfig)
+		}
+	}
+}
+
+// setTargets replaces the senders of the report and of the team digests with those of the configuration
+func (reporter *reporter) setTargets(reportConfig config.ReportConfig) {
+	senders := make([]Sender, 0)
+	if reportConfig.SMTP != nil {
+		senders = append(senders, NewSMTPSender(*reportConfig.SMTP))
+	}
+	if reportConfig.Webhook != nil {
+		senders = append(senders, NewWebhookSender(*reportConfig.Webhook))
+	}
+	teamLabel := ""
+	var teamSenders map[string]Sender
+	if reportConfig.Teams != nil {
+		teamLabel = reportConfig.Teams.Label
+		teamSenders = make(map[string]Sender)
+		for team, webhook := range reportConfig.Teams.Webhooks {
+			teamSenders[team] = NewWebhookSender(webhook)
+		}
+	}
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.senders = senders
+	reporter.teamLabel = teamLabel
+	reporter.teamSenders = teamSenders
+}
+
+func (reporter *reporter) reportPeriodically() {
+	for {
+		next := reporter.schedule.Next(reporter.now())
+		if next.IsZero() {
+			log.Println("The report schedule never matches, no report will be sent")
+			return
+		}
+		time.Sleep(time.Until(next))
+		reporter.mutex.Lock()
+		lastAnalysisResult := reporter.lastAnalysisResult
+		reporter.mutex.Unlock()
+		if lastAnalysisResult == nil {
+			continue
+		}
+		reporter.report(*lastAnalysisResult)
+	}
+}
+
+func (reporter *reporter) observe(analysisResult types.AnalysisResult) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.lastAnalysisResult = &analysisResult
+	if reporter.mode == config.ReportModeNewItems {
+		reporter.resourceTracker.observe(analysisResult, reporter.now())
+	}
+}
+
+func (reporter *reporter) report(analysisResult types.AnalysisResult) {
+	suppressions, err := reporter.suppressionStore.List()
+	if err != nil {
+		log.Printf("Unable to list findings suppressions for the report: %s\n", err)
+		return
+	}
+	analysisResult.Findings = suppression.Apply(analysisResult.Findings, suppressions)
+	summary := Summarize(analysisResult, reporter.now())
+	reporter.mutex.Lock()
+	senders, teamLabel, teamSenders := reporter.senders, reporter.teamLabel, reporter.teamSenders
+	reporter.mutex.Unlock()
+	if reporter.mode == config.ReportModeNewItems {
+		newItems := reporter.newItems(analysisResult, summary.GeneratedAt)
+		if newItems.isEmpty() {
+			return
+		}
+		summary.NewItems = &newItems
+		if teamSenders != nil {
+			sendTeamDigests(analysisResult, summary, teamLabel, teamSenders)
+		}
+	}
+	send(senders, summary)
+}
+
+// sendTeamDigests skips the teams without any new item, as well as those without webhook
+func sendTeamDigests(analysisResult types.AnalysisResult, summary Summary, teamLabel string,
+	teamSenders map[string]Sender) {
+	itemsByTeam := splitByTeam(*summary.NewItems, teamResolver(analysisResult, teamLabel))
+	teams := make([]string, 0, len(itemsByTeam))
+	for team := range itemsByTeam {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	for _, team := range teams {
+		sender, ok := teamSenders[team]
+		if !ok {
+			continue
+		}
+		teamItems := itemsByTeam[team]
+		teamSummary := summary
+		teamSummary.NewItems = &teamItems
+		send([]Sender{sender}, teamSummary)
+	}
+}
+
+func send(senders []Sender, summary Summary) {
+	var text bytes.Buffer
+	err := WriteText(&text, summary)
+	if err != nil {
+		log.Printf("Unable to render the report: %s\n", err)
+		return
+	}
+	for _, sender := range senders {
+		// A failing sender does not prevent the others from delivering the report
+		err = sender.Send(summary, text.String())
+		if err != nil {
+			log.Printf("Unable to send the report: %s\n", err)
+		}
+	}
+}
+
+func (reporter *reporter) newItems(analysisResult types.AnalysisResult, now time.Time) NewItems {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	since := now.Add(-reporter.window)
+	if reporter.lastReportAt.After(since) {
+		since = reporter.lastReportAt
+	}
+	reporter.lastReportAt = now
+	return reporter.resourceTracker.collect(analysisResult, since)
+}
@@ -0,0 +1,277 @@
+package report
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"karto/config"
+	"karto/cron"
+	"karto/suppression"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+type mockSender struct {
+	summaries []Summary
+}
+
+func (sender *mockSender) Send(summary Summary, _ string) error {
+	sender.summaries = append(sender.summaries, summary)
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	generatedAt := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	finding1 := &types.Finding{Fingerprint: "abc", Severity: "high"}
+	finding2 := &types.Finding{Fingerprint: "def", Severity: "low"}
+	suppressionStore := suppression.NewMemoryStore()
+	_ = suppressionStore.Save(&types.FindingSuppression{Fingerprint: "abc", ExpiresAt: time.Now().Add(time.Hour)})
+	sender1 := &mockSender{}
+	sender2 := &mockSender{}
+	schedule, _ := cron.Parse("0 8 * * 1")
+	reporter := newReporter(schedule, []Sender{sender1, sender2}, suppressionStore, "", 0)
+	reporter.now = func() time.Time { return generatedAt }
+	reporter.report(types.AnalysisResult{Findings: []*types.Finding{finding1, finding2}})
+	expectedSummaries := []Summary{
+		{
+			GeneratedAt:        generatedAt,
+			FindingsBySeverity: map[string]int{"high": 0, "medium": 0, "low": 1},
+			SuppressedFindings: 1,
+			Findings:           []*types.Finding{finding2},
+		},
+	}
+	if diff := cmp.Diff(expectedSummaries, sender1.summaries); diff != "" {
+		t.Errorf("report() result mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedSummaries, sender2.summaries); diff != "" {
+		t.Errorf("report() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportNewItems(t *testing.T) {
+	start := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	oldRouteFirstSeen := start.Add(-48 * time.Hour)
+	newRouteFirstSeen := start.Add(time.Hour)
+	frontPod := types.PodRef{Name: "front-abc", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-abc", Namespace: "shop"}
+	baselineResult := types.AnalysisResult{
+		Pods:            []*types.Pod{{Name: "front-abc", Namespace: "shop"}},
+		NetworkPolicies: []*types.NetworkPolicy{{Name: "front", Namespace: "shop"}},
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{frontPod}},
+		},
+	}
+	changedResult := types.AnalysisResult{
+		Pods:            []*types.Pod{{Name: "front-abc", Namespace: "shop"}, {Name: "api-abc", Namespace: "shop"}},
+		NetworkPolicies: []*types.NetworkPolicy{{Name: "front", Namespace: "shop"}, {Name: "api", Namespace: "shop"}},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: frontPod, TargetPod: frontPod, FirstSeen: &oldRouteFirstSeen},
+			{SourcePod: frontPod, TargetPod: apiPod, FirstSeen: &newRouteFirstSeen},
+		},
+		ReplicaSets: []*types.ReplicaSet{
+			{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{frontPod}},
+			{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+		},
+	}
+	sender := &mockSender{}
+	schedule, _ := cron.Parse("0 * * * *")
+	reporter := newReporter(schedule, []Sender{sender}, suppression.NewMemoryStore(), "newItems", 24*time.Hour)
+	now := start
+	reporter.now = func() time.Time { return now }
+	reporter.observe(baselineResult)
+	now = start.Add(time.Hour)
+	reporter.observe(changedResult)
+	now = start.Add(2 * time.Hour)
+	reporter.report(changedResult)
+	// Nothing appeared since the previous digest
+	now = start.Add(3 * time.Hour)
+	reporter.report(changedResult)
+	front := types.ResourceRef{Kind: "ReplicaSet", Name: "front", Namespace: "shop"}
+	api := types.ResourceRef{Kind: "ReplicaSet", Name: "api", Namespace: "shop"}
+	expectedNewItems := []*NewItems{
+		{
+			Since:  start.Add(-22 * time.Hour),
+			Routes: []*NewRoute{{Source: front, Target: api, FirstSeen: newRouteFirstSeen}},
+			Workloads: []*NewResource{
+				{Resource: api, FirstSeen: start.Add(time.Hour)},
+			},
+			NetworkPolicies: []*NewResource{
+				{Resource: types.ResourceRef{Kind: "NetworkPolicy", Name: "api", Namespace: "shop"},
+					FirstSeen: start.Add(time.Hour)},
+			},
+		},
+	}
+	newItems := make([]*NewItems, 0)
+	for _, summary := range sender.summaries {
+		newItems = append(newItems, summary.NewItems)
+	}
+	if diff := cmp.Diff(expectedNewItems, newItems); diff != "" {
+		t.Errorf("report() new items mismatch (-want +got):\n%s", diff)
+	}
+	var text bytes.Buffer
+	_ = WriteText(&text, sender.summaries[0])
+	expectedText := "Karto new network exposure items of 2021-04-01\n" +
+		"\n" +
+		"Namespaces: 0\n" +
+		"Pods: 2 (0 isolated for ingress, 0 isolated for egress)\n" +
+		"Allowed routes: 2\n" +
+		"Findings: 0 high, 0 medium, 0 low (0 suppressed)\n" +
+		"\n" +
+		"New since 2021-03-31T14:00:00Z: 1 routes, 1 workloads, 1 network policies\n" +
+		"\n" +
+		"Routes:\n" +
+		"- ReplicaSet shop/front -> ReplicaSet shop/api\n" +
+		"\n" +
+		"Workloads:\n" +
+		"- ReplicaSet shop/api\n" +
+		"\n" +
+		"Network policies:\n" +
+		"- NetworkPolicy shop/api\n"
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("WriteText() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportTeamDigests(t *testing.T) {
+	start := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	routeFirstSeen := start.Add(time.Hour)
+	frontPod := types.PodRef{Name: "front-abc", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-abc", Namespace: "shop"}
+	reportPod := types.PodRef{Name: "report-abc", Namespace: "finance"}
+	replicaSets := []*types.ReplicaSet{
+		{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{frontPod}},
+		{Name: "api", Namespace: "shop", TargetPods: []types.PodRef{apiPod}},
+		{Name: "report", Namespace: "finance", TargetPods: []types.PodRef{reportPod}},
+	}
+	baselineResult := types.AnalysisResult{
+		Pods:        []*types.Pod{{Name: "front-abc", Namespace: "shop", Labels: map[string]string{"team": "web"}}},
+		ReplicaSets: replicaSets,
+	}
+	changedResult := types.AnalysisResult{
+		Namespaces: []*types.Namespace{{Name: "finance", Labels: map[string]string{"team": "payments"}}},
+		Pods: []*types.Pod{
+			{Name: "front-abc", Namespace: "shop", Labels: map[string]string{"team": "web"}},
+			{Name: "api-abc", Namespace: "shop"},
+			{Name: "report-abc", Namespace: "finance"},
+		},
+		NetworkPolicies: []*types.NetworkPolicy{
+			{Name: "front", Namespace: "shop", Labels: map[string]string{"team": "web"}},
+		},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: reportPod, TargetPod: frontPod, FirstSeen: &routeFirstSeen},
+			{SourcePod: frontPod, TargetPod: apiPod, FirstSeen: &routeFirstSeen},
+		},
+		ReplicaSets: replicaSets,
+	}
+	sender := &mockSender{}
+	webSender := &mockSender{}
+	paymentsSender := &mockSender{}
+	schedule, _ := cron.Parse("0 * * * *")
+	reporter := newReporter(schedule, []Sender{sender}, suppression.NewMemoryStore(), "newItems", 24*time.Hour)
+	reporter.teamLabel = "team"
+	reporter.teamSenders = map[string]Sender{"web": webSender, "payments": paymentsSender}
+	now := start
+	reporter.now = func() time.Time { return now }
+	reporter.observe(baselineResult)
+	now = start.Add(time.Hour)
+	reporter.observe(changedResult)
+	now = start.Add(2 * time.Hour)
+	reporter.report(changedResult)
+	front := types.ResourceRef{Kind: "ReplicaSet", Name: "front", Namespace: "shop"}
+	api := types.ResourceRef{Kind: "ReplicaSet", Name: "api", Namespace: "shop"}
+	report := types.ResourceRef{Kind: "ReplicaSet", Name: "report", Namespace: "finance"}
+	since := start.Add(-22 * time.Hour)
+	tests := []struct {
+		name             string
+		sender           *mockSender
+		expectedNewItems []*NewItems
+	}{
+		{
+			name:   "everything is sent to the senders of the report",
+			sender: sender,
+			expectedNewItems: []*NewItems{
+				{
+					Since: since,
+					Routes: []*NewRoute{
+						{Source: report, Target: front, FirstSeen: routeFirstSeen},
+						{Source: front, Target: api, FirstSeen: routeFirstSeen},
+					},
+					Workloads: []*NewResource{
+						{Resource: report, FirstSeen: start.Add(time.Hour)},
+						{Resource: api, FirstSeen: start.Add(time.Hour)},
+					},
+					NetworkPolicies: []*NewResource{
+						{Resource: types.ResourceRef{Kind: "NetworkPolicy", Name: "front", Namespace: "shop"},
+							FirstSeen: start.Add(time.Hour)},
+					},
+				},
+			},
+		},
+		{
+			name:   "teams are read from the labels of the pods and policies",
+			sender: webSender,
+			expectedNewItems: []*NewItems{
+				{
+					Since: since,
+					Routes: []*NewRoute{
+						{Source: report, Target: front, FirstSeen: routeFirstSeen},
+						{Source: front, Target: api, FirstSeen: routeFirstSeen},
+					},
+					Workloads: []*NewResource{},
+					NetworkPolicies: []*NewResource{
+						{Resource: types.ResourceRef{Kind: "NetworkPolicy", Name: "front", Namespace: "shop"},
+							FirstSeen: start.Add(time.Hour)},
+					},
+				},
+			},
+		},
+		{
+			name:   "teams are read from the labels of the namespaces otherwise",
+			sender: paymentsSender,
+			expectedNewItems: []*NewItems{
+				{
+					Since:           since,
+					Routes:          []*NewRoute{{Source: report, Target: front, FirstSeen: routeFirstSeen}},
+					Workloads:       []*NewResource{{Resource: report, FirstSeen: start.Add(time.Hour)}},
+					NetworkPolicies: []*NewResource{},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newItems := make([]*NewItems, 0)
+			for _, summary := range tt.sender.summaries {
+				newItems = append(newItems, summary.NewItems)
+			}
+			if diff := cmp.Diff(tt.expectedNewItems, newItems); diff != "" {
+				t.Errorf("report() new items mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetTargets(t *testing.T) {
+	schedule, _ := cron.Parse("0 * * * *")
+	reporter := newReporter(schedule, []Sender{&mockSender{}}, suppression.NewMemoryStore(), "", 0)
+	reporter.setTargets(config.ReportConfig{
+		Webhook: &config.WebhookConfig{URL: "https://hooks.example.com/report"},
+		Teams: &config.ReportTeamsConfig{Label: "team", Webhooks: map[string]config.WebhookConfig{
+			"web": {URL: "https://hooks.example.com/web"}}},
+	})
+	targets := make([]string, 0)
+	for _, sender := range reporter.senders {
+		targets = append(targets, sender.(webhookSender).config.URL)
+	}
+	for team, sender := range reporter.teamSenders {
+		targets = append(targets, reporter.teamLabel+"="+team+": "+sender.(webhookSender).config.URL)
+	}
+	expectedTargets := []string{"https://hooks.example.com/report", "team=web: https://hooks.example.com/web"}
+	if diff := cmp.Diff(expectedTargets, targets); diff != "" {
+		t.Errorf("setTargets() targets mismatch (-want +got):\n%s", diff)
+	}
+	reporter.setTargets(config.ReportConfig{})
+	if len(reporter.senders) != 0 || reporter.teamSenders != nil {
+		t.Errorf("setTargets() kept %d senders and team senders %v", len(reporter.senders), reporter.teamSenders)
+	}
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"karto/config"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	smtpPasswordEnv = "KARTO_SMTP_PASSWORD"
+	webhookTimeout  = 10 * time.Second
+)
+
+type Sender interface {
+	Send(summary Summary, text string) error
+}
+
+type smtpSender struct {
+	config   config.SMTPConfig
+	password string
+	sendMail func(address string, auth smtp.Auth, from string, to []string, message []byte) error
+}
+
+type webhookSender struct {
+	config     config.WebhookConfig
+	httpClient *http.Client
+}
+
+type webhookPayload struct {
+	Text    string  `json:"text"`
+	Summary Summary `json:"summary"`
+}
+
+func NewSMTPSender(config config.SMTPConfig) Sender {
+	return smtpSender{
+		config:   config,
+		password: os.Getenv(smtpPasswordEnv),
+		sendMail: smtp.SendMail,
+	}
+}
+
+func NewWebhookSender(config config.WebhookConfig) Sender {
+	return webhookSender{
+		config:     config,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (sender smtpSender) Send(summary Summary, text string) error {
+	var auth smtp.Auth
+	if sender.config.Username != "" {
+		host, _, err := net.SplitHostPort(sender.config.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", sender.config.Username, sender.password, host)
+	}
+	return sender.sendMail(sender.config.Address, auth, sender.config.From, sender.config.To,
+		sender.message(summary, text))
+}
+
+func (sender smtpSender) message(summary Summary, text string) []byte {
+	var message bytes.Buffer
+	headers := []string{
+		"From: " + sender.config.From,
+		"To: " + strings.Join(sender.config.To, ", "),
+		"Subject: " + Subject(summary),
+		"Date: " + summary.GeneratedAt.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+	for _, header := range headers {
+		message.WriteString(header + "\r\n")
+	}
+	message.WriteString("\r\n")
+	// SMTP requires CRLF line endings
+	message.WriteString(strings.Replace(text, "\n", "\r\n", -1))
+	return message.Bytes()
+}
+
+// The text field makes the payload usable as is by Slack or Mattermost incoming webhooks
+func (sender webhookSender) Send(summary Summary, text string) error {
+	body, err := json.Marshal(webhookPayload{Text: text, Summary: summary})
+	if err != nil {
+		return err
+	}
+	response, err := sender.httpClient.Post(sender.config.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", sender.config.URL, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"karto/config"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+)
+
+func TestSMTPSend(t *testing.T) {
+	summary := Summary{GeneratedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)}
+	var sentAddress, sentFrom string
+	var sentTo []string
+	var sentMessage []byte
+	sender := smtpSender{
+		config: config.SMTPConfig{Address: "smtp.example.com:587", From: "karto@example.com",
+			To: []string{"a@example.com", "b@example.com"}},
+		sendMail: func(address string, auth smtp.Auth, from string, to []string, message []byte) error {
+			sentAddress, sentFrom, sentTo, sentMessage = address, from, to, message
+			return nil
+		},
+	}
+	err := sender.Send(summary, "line1\nline2\n")
+	if err != nil {
+		t.Fatalf("Send() returned an error: %s", err)
+	}
+	expectedMessage := "From: karto@example.com\r\n" +
+		"To: a@example.com, b@example.com\r\n" +
+		"Subject: Karto network exposure report of 2021-04-01\r\n" +
+		"Date: Thu, 01 Apr 2021 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line1\r\nline2\r\n"
+	if diff := cmp.Diff("smtp.example.com:587", sentAddress); diff != "" {
+		t.Errorf("Send() address mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("karto@example.com", sentFrom); diff != "" {
+		t.Errorf("Send() sender mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"a@example.com", "b@example.com"}, sentTo); diff != "" {
+		t.Errorf("Send() recipients mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedMessage, string(sentMessage)); diff != "" {
+		t.Errorf("Send() message mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		expectedError bool
+	}{
+		{
+			name:       "the report is posted as JSON",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:          "a rejected report is an error",
+			statusCode:    http.StatusInternalServerError,
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedBody string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				receivedBody = string(body)
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+			sender := NewWebhookSender(config.WebhookConfig{URL: server.URL})
+			summary := Summary{GeneratedAt: time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)}
+			err := sender.Send(summary, "report")
+			if diff := cmp.Diff(tt.expectedError, err != nil); diff != "" {
+				t.Errorf("Send() error mismatch (-want +got):\n%s", diff)
+			}
+			expectedBody := "{\"text\":\"report\",\"summary\":{\"generatedAt\":\"2021-04-01T12:00:00Z\"," +
+				"\"namespaces\":0,\"pods\":0,\"ingressIsolatedPods\":0,\"egressIsolatedPods\":0,\"allowedRoutes\":0," +
+				"\"findingsBySeverity\":null,\"suppressedFindings\":0,\"findings\":null,\"compliance\":null,\"newItems\":null}}"
+			if diff := cmp.Diff(expectedBody, receivedBody); diff != "" {
+				t.Errorf("Send() body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"karto/drift"
+	"karto/types"
+)
+
+// teamResolver returns the team of a workload or network policy, read from the label of its pods or of the policy, or
+// else from that of its namespace. Resources without the label belong to no team.
+func teamResolver(analysisResult types.AnalysisResult, label string) func(resource types.ResourceRef) string {
+	namespaceTeams := make(map[string]string)
+	for _, namespace := range analysisResult.Namespaces {
+		if team := namespace.Labels[label]; team != "" {
+			namespaceTeams[namespace.Name] = team
+		}
+	}
+	resourceTeams := make(map[types.ResourceRef]string)
+	workloads := drift.PodWorkloads(analysisResult)
+	for _, pod := range analysisResult.Pods {
+		if team := pod.Labels[label]; team != "" {
+			resourceTeams[workloads(types.PodRef{Name: pod.Name, Namespace: pod.Namespace})] = team
+		}
+	}
+	for _, policy := range analysisResult.NetworkPolicies {
+		if team := policy.Labels[label]; team != "" {
+			resourceTeams[types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name,
+				Namespace: policy.Namespace}] = team
+		}
+	}
+	return func(resource types.ResourceRef) string {
+		if team, ok := resourceTeams[resource]; ok {
+			return team
+		}
+		return namespaceTeams[resource.Namespace]
+	}
+}
+
+// splitByTeam keeps the order of the items. Routes between two teams are in the digest of both.
+func splitByTeam(newItems NewItems, teamOf func(resource types.ResourceRef) string) map[string]NewItems {
+	itemsByTeam := make(map[string]NewItems)
+	itemsOf := func(team string) NewItems {
+		teamItems, ok := itemsByTeam[team]
+		if !ok {
+			teamItems = NewItems{
+				Since:           newItems.Since,
+				Routes:          make([]*NewRoute, 0),
+				Workloads:       make([]*NewResource, 0),
+				NetworkPolicies: make([]*NewResource, 0),
+			}
+		}
+		return teamItems
+	}
+	for _, route := range newItems.Routes {
+		for _, team := range uniqueTeams(teamOf(route.Source), teamOf(route.Target)) {
+			teamItems := itemsOf(team)
+			teamItems.Routes = append(teamItems.Routes, route)
+			itemsByTeam[team] = teamItems
+		}
+	}
+	for _, workload := range newItems.Workloads {
+		for _, team := range uniqueTeams(teamOf(workload.Resource)) {
+			teamItems := itemsOf(team)
+			teamItems.Workloads = append(teamItems.Workloads, workload)
+			itemsByTeam[team] = teamItems
+		}
+	}
+	for _, policy := range newItems.NetworkPolicies {
+		for _, team := range uniqueTeams(teamOf(policy.Resource)) {
+			teamItems := itemsOf(team)
+			teamItems.NetworkPolicies = append(teamItems.NetworkPolicies, policy)
+			itemsByTeam[team] = teamItems
+		}
+	}
+	return itemsByTeam
+}
+
+func uniqueTeams(teams ...string) []string {
+	unique := make([]string, 0, len(teams))
+	for i, team := range teams {
+		if team != "" && (i == 0 || team != teams[0]) {
+			unique = append(unique, team)
+		}
+	}
+	return unique
+}
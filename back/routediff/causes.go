@@ -0,0 +1,173 @@
+package routediff
+
+import (
+	"fmt"
+	"karto/types"
+)
+
+const (
+	CausePodDeleted             = "podDeleted"
+	CausePodLabelsChanged       = "podLabelsChanged"
+	CauseNamespaceLabelsChanged = "namespaceLabelsChanged"
+	CausePolicyDeleted          = "policyDeleted"
+	CausePolicyCreated          = "policyCreated"
+	CausePolicyModified         = "policyModified"
+)
+
+type Cause struct {
+	SourcePod types.PodRef      `json:"sourcePod"`
+	TargetPod types.PodRef      `json:"targetPod"`
+	Kind      string            `json:"kind"`
+	Resource  types.ResourceRef `json:"resource"`
+}
+
+type resourceChanges struct {
+	beforePods       map[types.PodRef]*types.Pod
+	afterPods        map[types.PodRef]*types.Pod
+	beforeNamespaces map[string]*types.Namespace
+	afterNamespaces  map[string]*types.Namespace
+	afterPolicies    map[types.ResourceRef]bool
+	createdPolicies  []types.ResourceRef
+}
+
+// ComputeWithCauses also tells the likely causes of the removal of routes, inferred from the changes of the pods,
+// namespaces and network policies between both analysis results
+func ComputeWithCauses(before types.AnalysisResult, after types.AnalysisResult) Diff {
+	diff := Compute(before.AllowedRoutes, after.AllowedRoutes)
+	changes := resourceChangesOf(before, after)
+	for _, route := range diff.Removed {
+		diff.Causes = append(diff.Causes, changes.causesOf(route)...)
+	}
+	return diff
+}
+
+func resourceChangesOf(before types.AnalysisResult, after types.AnalysisResult) resourceChanges {
+	changes := resourceChanges{
+		beforePods:       podsByRef(before.Pods),
+		afterPods:        podsByRef(after.Pods),
+		beforeNamespaces: namespacesByName(before.Namespaces),
+		afterNamespaces:  namespacesByName(after.Namespaces),
+		afterPolicies:    make(map[types.ResourceRef]bool),
+		createdPolicies:  make([]types.ResourceRef, 0),
+	}
+	beforePolicies := make(map[types.ResourceRef]bool)
+	for _, policy := range before.NetworkPolicies {
+		beforePolicies[policyRefOf(*policy)] = true
+	}
+	for _, policy := range after.NetworkPolicies {
+		policyRef := policyRefOf(*policy)
+		changes.afterPolicies[policyRef] = true
+		if !beforePolicies[policyRef] {
+			changes.createdPolicies = append(changes.createdPolicies, policyRef)
+		}
+	}
+	return changes
+}
+
+func (changes resourceChanges) causesOf(route *types.AllowedRoute) []*Cause {
+	causes := make([]*Cause, 0)
+	addCause := func(kind string, resource types.ResourceRef) {
+		for _, cause := range causes {
+			if cause.Kind == kind && cause.Resource == resource {
+				return
+			}
+		}
+		causes = append(causes, &Cause{SourcePod: route.SourcePod, TargetPod: route.TargetPod, Kind: kind,
+			Resource: resource})
+	}
+	podDeleted := false
+	for _, podRef := range []types.PodRef{route.SourcePod, route.TargetPod} {
+		resource := types.ResourceRef{Kind: "Pod", Name: podRef.Name, Namespace: podRef.Namespace}
+		afterPod, ok := changes.afterPods[podRef]
+		if !ok {
+			podDeleted = true
+			addCause(CausePodDeleted, resource)
+		} else if beforePod, ok := changes.beforePods[podRef]; ok && !sameLabels(beforePod.Labels, afterPod.Labels) {
+			addCause(CausePodLabelsChanged, resource)
+		}
+		beforeNamespace, beforeOk := changes.beforeNamespaces[podRef.Namespace]
+		afterNamespace, afterOk := changes.afterNamespaces[podRef.Namespace]
+		if beforeOk && afterOk && !sameLabels(beforeNamespace.Labels, afterNamespace.Labels) {
+			addCause(CauseNamespaceLabelsChanged, types.ResourceRef{Kind: "Namespace", Name: podRef.Namespace})
+		}
+	}
+	if podDeleted {
+		// Policies are irrelevant to the routes of deleted pods
+		return causes
+	}
+	routePolicies := make([]types.ResourceRef, 0)
+	for _, policy := range append(append([]types.NetworkPolicy{}, route.EgressPolicies...), route.IngressPolicies...) {
+		routePolicies = append(routePolicies, policyRefOf(policy))
+	}
+	for _, policyRef := range routePolicies {
+		if !changes.afterPolicies[policyRef] {
+			addCause(CausePolicyDeleted, policyRef)
+		}
+	}
+	for _, policyRef := range changes.createdPolicies {
+		if policyRef.Namespace == route.SourcePod.Namespace || policyRef.Namespace == route.TargetPod.Namespace {
+			addCause(CausePolicyCreated, policyRef)
+		}
+	}
+	if len(causes) > 0 {
+		return causes
+	}
+	// Nothing else changed around the route, the rules of the policies which allowed it most likely did
+	for _, policyRef := range routePolicies {
+		addCause(CausePolicyModified, policyRef)
+	}
+	return causes
+}
+
+func FormatCause(cause *Cause) string {
+	resource := cause.Resource.Name
+	if cause.Resource.Namespace != "" {
+		resource = cause.Resource.Namespace + "/" + resource
+	}
+	switch cause.Kind {
+	case CausePodDeleted:
+		return fmt.Sprintf("pod %s deleted", resource)
+	case CausePodLabelsChanged:
+		return fmt.Sprintf("labels of pod %s changed", resource)
+	case CauseNamespaceLabelsChanged:
+		return fmt.Sprintf("labels of namespace %s changed", resource)
+	case CausePolicyDeleted:
+		return fmt.Sprintf("network policy %s deleted", resource)
+	case CausePolicyCreated:
+		return fmt.Sprintf("network policy %s created", resource)
+	default:
+		return fmt.Sprintf("network policy %s modified", resource)
+	}
+}
+
+func podsByRef(pods []*types.Pod) map[types.PodRef]*types.Pod {
+	podsByRef := make(map[types.PodRef]*types.Pod)
+	for _, pod := range pods {
+		podsByRef[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod
+	}
+	return podsByRef
+}
+
+func namespacesByName(namespaces []*types.Namespace) map[string]*types.Namespace {
+	namespacesByName := make(map[string]*types.Namespace)
+	for _, namespace := range namespaces {
+		namespacesByName[namespace.Name] = namespace
+	}
+	return namespacesByName
+}
+
+func policyRefOf(policy types.NetworkPolicy) types.ResourceRef {
+	return types.ResourceRef{Kind: "NetworkPolicy", Name: policy.Name, Namespace: policy.Namespace}
+}
+
+func sameLabels(labels1 map[string]string, labels2 map[string]string) bool {
+	if len(labels1) != len(labels2) {
+		return false
+	}
+	for key, value := range labels1 {
+		if otherValue, ok := labels2[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package routediff
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestComputeWithCauses(t *testing.T) {
+	podA := types.PodRef{Name: "a", Namespace: "ns"}
+	podB := types.PodRef{Name: "b", Namespace: "ns"}
+	policyB := types.NetworkPolicy{Name: "allow-b", Namespace: "ns"}
+	policyBRef := types.ResourceRef{Kind: "NetworkPolicy", Name: "allow-b", Namespace: "ns"}
+	denyAll := types.ResourceRef{Kind: "NetworkPolicy", Name: "deny-all", Namespace: "ns"}
+	before := types.AnalysisResult{
+		Namespaces: []*types.Namespace{{Name: "ns", Labels: map[string]string{"team": "shop"}}},
+		Pods: []*types.Pod{
+			{Name: "a", Namespace: "ns", Labels: map[string]string{"app": "a"}},
+			{Name: "b", Namespace: "ns", Labels: map[string]string{"app": "b"}},
+		},
+		NetworkPolicies: []*types.NetworkPolicy{&policyB},
+		AllowedRoutes: []*types.AllowedRoute{
+			{SourcePod: podA, TargetPod: podB, IngressPolicies: []types.NetworkPolicy{policyB}},
+		},
+	}
+	tests := []struct {
+		name           string
+		after          types.AnalysisResult
+		expectedCauses []*Cause
+	}{
+		{
+			name: "routes of deleted pods are caused by the deletion only",
+			after: types.AnalysisResult{
+				Namespaces: before.Namespaces,
+				Pods:       []*types.Pod{before.Pods[0]},
+			},
+			expectedCauses: []*Cause{
+				{SourcePod: podA, TargetPod: podB, Kind: CausePodDeleted,
+					Resource: types.ResourceRef{Kind: "Pod", Name: "b", Namespace: "ns"}},
+			},
+		},
+		{
+			name: "changed labels of pods and namespaces are causes",
+			after: types.AnalysisResult{
+				Namespaces: []*types.Namespace{{Name: "ns", Labels: map[string]string{"team": "payment"}}},
+				Pods: []*types.Pod{
+					{Name: "a", Namespace: "ns", Labels: map[string]string{"app": "a", "env": "dev"}},
+					before.Pods[1],
+				},
+				NetworkPolicies: before.NetworkPolicies,
+			},
+			expectedCauses: []*Cause{
+				{SourcePod: podA, TargetPod: podB, Kind: CausePodLabelsChanged,
+					Resource: types.ResourceRef{Kind: "Pod", Name: "a", Namespace: "ns"}},
+				{SourcePod: podA, TargetPod: podB, Kind: CauseNamespaceLabelsChanged,
+					Resource: types.ResourceRef{Kind: "Namespace", Name: "ns"}},
+			},
+		},
+		{
+			name: "deleted policies which allowed the route and created policies are causes",
+			after: types.AnalysisResult{
+				Namespaces:      before.Namespaces,
+				Pods:            before.Pods,
+				NetworkPolicies: []*types.NetworkPolicy{{Name: "deny-all", Namespace: "ns"}},
+			},
+			expectedCauses: []*Cause{
+				{SourcePod: podA, TargetPod: podB, Kind: CausePolicyDeleted, Resource: policyBRef},
+				{SourcePod: podA, TargetPod: podB, Kind: CausePolicyCreated, Resource: denyAll},
+			},
+		},
+		{
+			name: "policies which allowed the route were modified when nothing else changed",
+			after: types.AnalysisResult{
+				Namespaces:      before.Namespaces,
+				Pods:            before.Pods,
+				NetworkPolicies: before.NetworkPolicies,
+			},
+			expectedCauses: []*Cause{
+				{SourcePod: podA, TargetPod: podB, Kind: CausePolicyModified, Resource: policyBRef},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := ComputeWithCauses(before, tt.after)
+			if diff := cmp.Diff(tt.expectedCauses, diff.Causes); diff != "" {
+				t.Errorf("ComputeWithCauses() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteTextWithCauses(t *testing.T) {
+	podA := types.PodRef{Name: "a", Namespace: "ns"}
+	podB := types.PodRef{Name: "b", Namespace: "ns"}
+	diff := Diff{
+		Removed: []*types.AllowedRoute{{SourcePod: podA, TargetPod: podB, Ports: []int32{80}}},
+		Causes: []*Cause{
+			{SourcePod: podA, TargetPod: podB, Kind: CausePodLabelsChanged,
+				Resource: types.ResourceRef{Kind: "Pod", Name: "a", Namespace: "ns"}},
+			{SourcePod: podA, TargetPod: podB, Kind: CausePolicyCreated,
+				Resource: types.ResourceRef{Kind: "NetworkPolicy", Name: "deny-all", Namespace: "ns"}},
+		},
+	}
+	var text bytes.Buffer
+	WriteText(&text, diff)
+	expectedText := "  - ns/a -> ns/b on ports 80 (labels of pod ns/a changed, network policy ns/deny-all created)\n"
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("WriteText() result mismatch (-want +got):\n%s", diff)
+	}
+}
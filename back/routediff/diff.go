@@ -0,0 +1,84 @@
+package routediff
+
+import (
+	"karto/routeports"
+	"karto/types"
+	"sort"
+)
+
+type Diff struct {
+	Added   []*types.AllowedRoute `json:"added"`
+	Removed []*types.AllowedRoute `json:"removed"`
+	Changed []*Change             `json:"changed"`
+	Causes  []*Cause              `json:"causes"`
+}
+
+type Change struct {
+	Before *types.AllowedRoute `json:"before"`
+	After  *types.AllowedRoute `json:"after"`
+}
+
+type routeKey struct {
+	sourcePod types.PodRef
+	targetPod types.PodRef
+}
+
+func Compute(before []*types.AllowedRoute, after []*types.AllowedRoute) Diff {
+	diff := Diff{
+		Added:   make([]*types.AllowedRoute, 0),
+		Removed: make([]*types.AllowedRoute, 0),
+		Changed: make([]*Change, 0),
+		Causes:  make([]*Cause, 0),
+	}
+	beforeByKey := make(map[routeKey]*types.AllowedRoute)
+	for _, route := range before {
+		beforeByKey[keyOf(route)] = route
+	}
+	afterByKey := make(map[routeKey]*types.AllowedRoute)
+	for _, route := range after {
+		afterByKey[keyOf(route)] = route
+		beforeRoute, ok := beforeByKey[keyOf(route)]
+		if !ok {
+			diff.Added = append(diff.Added, route)
+		} else if !routeports.Equal(routeports.Of(beforeRoute), routeports.Of(route)) {
+			diff.Changed = append(diff.Changed, &Change{Before: beforeRoute, After: route})
+		}
+	}
+	for _, route := range before {
+		if _, ok := afterByKey[keyOf(route)]; !ok {
+			diff.Removed = append(diff.Removed, route)
+		}
+	}
+	sortRoutes(diff.Added)
+	sortRoutes(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return less(keyOf(diff.Changed[i].After), keyOf(diff.Changed[j].After))
+	})
+	return diff
+}
+
+func (diff Diff) IsEmpty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+func keyOf(route *types.AllowedRoute) routeKey {
+	return routeKey{sourcePod: route.SourcePod, targetPod: route.TargetPod}
+}
+
+func sortRoutes(routes []*types.AllowedRoute) {
+	sort.Slice(routes, func(i, j int) bool { return less(keyOf(routes[i]), keyOf(routes[j])) })
+}
+
+func less(key1 routeKey, key2 routeKey) bool {
+	if key1.sourcePod != key2.sourcePod {
+		return podLess(key1.sourcePod, key2.sourcePod)
+	}
+	return podLess(key1.targetPod, key2.targetPod)
+}
+
+func podLess(pod1 types.PodRef, pod2 types.PodRef) bool {
+	if pod1.Namespace != pod2.Namespace {
+		return pod1.Namespace < pod2.Namespace
+	}
+	return pod1.Name < pod2.Name
+}
@@ -0,0 +1,77 @@
+package routediff
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	podA := types.PodRef{Name: "a", Namespace: "ns"}
+	podB := types.PodRef{Name: "b", Namespace: "ns"}
+	podC := types.PodRef{Name: "c", Namespace: "ns"}
+	tests := []struct {
+		name         string
+		before       []*types.AllowedRoute
+		after        []*types.AllowedRoute
+		expectedDiff Diff
+	}{
+		{
+			name:   "identical routes have no diff",
+			before: []*types.AllowedRoute{{SourcePod: podA, TargetPod: podB, Ports: []int32{80}}},
+			after:  []*types.AllowedRoute{{SourcePod: podA, TargetPod: podB, Ports: []int32{80}}},
+			expectedDiff: Diff{
+				Added:   []*types.AllowedRoute{},
+				Removed: []*types.AllowedRoute{},
+				Changed: []*Change{},
+				Causes:  []*Cause{},
+			},
+		},
+		{
+			name: "routes are added, removed and changed",
+			before: []*types.AllowedRoute{
+				{SourcePod: podA, TargetPod: podB, Ports: nil},
+				{SourcePod: podA, TargetPod: podC, Ports: []int32{80}},
+			},
+			after: []*types.AllowedRoute{
+				{SourcePod: podA, TargetPod: podB, Ports: []int32{80}},
+				{SourcePod: podB, TargetPod: podC, Ports: []int32{443}},
+			},
+			expectedDiff: Diff{
+				Added:   []*types.AllowedRoute{{SourcePod: podB, TargetPod: podC, Ports: []int32{443}}},
+				Removed: []*types.AllowedRoute{{SourcePod: podA, TargetPod: podC, Ports: []int32{80}}},
+				Changed: []*Change{{
+					Before: &types.AllowedRoute{SourcePod: podA, TargetPod: podB, Ports: nil},
+					After:  &types.AllowedRoute{SourcePod: podA, TargetPod: podB, Ports: []int32{80}},
+				}},
+				Causes: []*Cause{},
+			},
+		},
+		{
+			name: "a widened port range changes the route",
+			before: []*types.AllowedRoute{{SourcePod: podA, TargetPod: podB, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8080}}}},
+			after: []*types.AllowedRoute{{SourcePod: podA, TargetPod: podB, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}},
+			expectedDiff: Diff{
+				Added:   []*types.AllowedRoute{},
+				Removed: []*types.AllowedRoute{},
+				Changed: []*Change{{
+					Before: &types.AllowedRoute{SourcePod: podA, TargetPod: podB, Ports: []int32{},
+						PortRanges: []types.PortRange{{Port: 8000, EndPort: 8080}}},
+					After: &types.AllowedRoute{SourcePod: podA, TargetPod: podB, Ports: []int32{},
+						PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+				}},
+				Causes: []*Cause{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Compute(tt.before, tt.after)
+			if diff := cmp.Diff(tt.expectedDiff, diff); diff != "" {
+				t.Errorf("Compute() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
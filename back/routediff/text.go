@@ -0,0 +1,47 @@
+package routediff
+
+import (
+	"fmt"
+	"io"
+	"karto/routeports"
+	"karto/types"
+	"strings"
+)
+
+func WriteText(w io.Writer, diff Diff) {
+	if diff.IsEmpty() {
+		fmt.Fprintln(w, "  no change")
+		return
+	}
+	for _, route := range diff.Added {
+		fmt.Fprintf(w, "  + %s\n", FormatRoute(route))
+	}
+	for _, route := range diff.Removed {
+		causes := make([]string, 0)
+		for _, cause := range diff.Causes {
+			if cause.SourcePod == route.SourcePod && cause.TargetPod == route.TargetPod {
+				causes = append(causes, FormatCause(cause))
+			}
+		}
+		if len(causes) == 0 {
+			fmt.Fprintf(w, "  - %s\n", FormatRoute(route))
+		} else {
+			fmt.Fprintf(w, "  - %s (%s)\n", FormatRoute(route), strings.Join(causes, ", "))
+		}
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "  ~ %s (was %s)\n", FormatRoute(change.After), FormatPorts(routeports.Of(change.Before)))
+	}
+}
+
+func FormatRoute(route *types.AllowedRoute) string {
+	return fmt.Sprintf("%s/%s -> %s/%s on %s", route.SourcePod.Namespace, route.SourcePod.Name,
+		route.TargetPod.Namespace, route.TargetPod.Name, FormatPorts(routeports.Of(route)))
+}
+
+func FormatPorts(ports routeports.Set) string {
+	if ports.AllPorts() {
+		return "all ports"
+	}
+	return "ports " + strings.Join(ports.Strings(), ", ")
+}
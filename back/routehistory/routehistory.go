@@ -0,0 +1,112 @@
+package routehistory
+
+import (
+	"encoding/json"
+	"karto/drift"
+	"karto/store"
+	"karto/types"
+	"log"
+	"time"
+)
+
+const (
+	historyCollection = "routeHistory"
+	historyKey        = "routes"
+)
+
+type Options struct {
+	Retention time.Duration
+}
+
+type routeTimestamps struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type tracker struct {
+	store     store.Store
+	retention time.Duration
+	history   map[string]*routeTimestamps
+	now       func() time.Time
+}
+
+// Track annotates the allowed routes of each analysis result with when they were first and last seen. Pods are
+// renamed on each rollout, so the history is kept between workloads, in the store so that it survives restarts.
+func Track(historyStore store.Store, options Options, resultsChannel <-chan types.AnalysisResult,
+	trackedResultsChannel chan<- types.AnalysisResult) {
+	tracker := newTracker(historyStore, options)
+	for {
+		analysisResult := <-resultsChannel
+		trackedResultsChannel <- tracker.track(analysisResult)
+	}
+}
+
+func newTracker(historyStore store.Store, options Options) *tracker {
+	tracker := &tracker{
+		store:     historyStore,
+		retention: options.Retention,
+		history:   make(map[string]*routeTimestamps),
+		now:       time.Now,
+	}
+	content, ok, err := historyStore.Get(historyCollection, historyKey)
+	if err != nil {
+		log.Printf("Unable to read the route history from the store, starting a new one: %s\n", err)
+		return tracker
+	}
+	if !ok {
+		return tracker
+	}
+	err = json.Unmarshal(content, &tracker.history)
+	if err != nil {
+		log.Printf("Unable to decode the route history, starting a new one: %s\n", err)
+		tracker.history = make(map[string]*routeTimestamps)
+	}
+	return tracker
+}
+
+func (tracker *tracker) track(analysisResult types.AnalysisResult) types.AnalysisResult {
+	now := tracker.now().UTC().Truncate(time.Second)
+	workloads := drift.PodWorkloads(analysisResult)
+	allowedRoutes := make([]*types.AllowedRoute, 0, len(analysisResult.AllowedRoutes))
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		key := routeKey(workloads(allowedRoute.SourcePod), workloads(allowedRoute.TargetPod))
+		timestamps, ok := tracker.history[key]
+		if !ok {
+			timestamps = &routeTimestamps{FirstSeen: now}
+			tracker.history[key] = timestamps
+		}
+		timestamps.LastSeen = now
+		// Results are shared with other consumers, routes are therefore copied rather than modified
+		trackedRoute := *allowedRoute
+		firstSeen, lastSeen := timestamps.FirstSeen, timestamps.LastSeen
+		trackedRoute.FirstSeen = &firstSeen
+		trackedRoute.LastSeen = &lastSeen
+		allowedRoutes = append(allowedRoutes, &trackedRoute)
+	}
+	if tracker.retention > 0 {
+		for key, timestamps := range tracker.history {
+			if now.Sub(timestamps.LastSeen) > tracker.retention {
+				delete(tracker.history, key)
+			}
+		}
+	}
+	tracker.save()
+	analysisResult.AllowedRoutes = allowedRoutes
+	return analysisResult
+}
+
+// The history is written as a single value, so that an analysis costs one write whatever the number of routes
+func (tracker *tracker) save() {
+	content, err := json.Marshal(tracker.history)
+	if err == nil {
+		err = tracker.store.Put(historyCollection, historyKey, content)
+	}
+	if err != nil {
+		log.Printf("Unable to save the route history to the store: %s\n", err)
+	}
+}
+
+func routeKey(source types.ResourceRef, target types.ResourceRef) string {
+	return source.Kind + "/" + source.Namespace + "/" + source.Name + ">" +
+		target.Kind + "/" + target.Namespace + "/" + target.Name
+}
@@ -0,0 +1,94 @@
+package routehistory
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/store"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestTrack(t *testing.T) {
+	day1 := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	day40 := day1.Add(40 * 24 * time.Hour)
+	frontPod1 := types.PodRef{Name: "front-5d8f-abc", Namespace: "shop"}
+	frontPod2 := types.PodRef{Name: "front-7c9e-def", Namespace: "shop"}
+	apiPod := types.PodRef{Name: "api-0", Namespace: "shop"}
+	debugPod := types.PodRef{Name: "debug", Namespace: "shop"}
+	analysisResultWith := func(frontPod types.PodRef, allowedRoutes ...*types.AllowedRoute) types.AnalysisResult {
+		return types.AnalysisResult{
+			AllowedRoutes: allowedRoutes,
+			ReplicaSets: []*types.ReplicaSet{
+				{Name: "front", Namespace: "shop", TargetPods: []types.PodRef{frontPod}},
+			},
+		}
+	}
+	historyStore := store.NewMemoryStore()
+	tests := []struct {
+		name           string
+		now            time.Time
+		analysisResult types.AnalysisResult
+		expectedRoutes []*types.AllowedRoute
+	}{
+		{
+			name: "routes are first seen in the first analysis",
+			now:  day1,
+			analysisResult: analysisResultWith(frontPod1,
+				&types.AllowedRoute{SourcePod: frontPod1, TargetPod: apiPod}),
+			expectedRoutes: []*types.AllowedRoute{
+				{SourcePod: frontPod1, TargetPod: apiPod, FirstSeen: timeRef(day1), LastSeen: timeRef(day1)},
+			},
+		},
+		{
+			name: "routes of renamed pods keep their history and new routes are first seen now",
+			now:  day2,
+			analysisResult: analysisResultWith(frontPod2,
+				&types.AllowedRoute{SourcePod: frontPod2, TargetPod: apiPod},
+				&types.AllowedRoute{SourcePod: debugPod, TargetPod: apiPod}),
+			expectedRoutes: []*types.AllowedRoute{
+				{SourcePod: frontPod2, TargetPod: apiPod, FirstSeen: timeRef(day1), LastSeen: timeRef(day2)},
+				{SourcePod: debugPod, TargetPod: apiPod, FirstSeen: timeRef(day2), LastSeen: timeRef(day2)},
+			},
+		},
+		{
+			name: "routes absent for longer than the retention are forgotten",
+			now:  day40,
+			analysisResult: analysisResultWith(frontPod2,
+				&types.AllowedRoute{SourcePod: debugPod, TargetPod: apiPod}),
+			expectedRoutes: []*types.AllowedRoute{
+				{SourcePod: debugPod, TargetPod: apiPod, FirstSeen: timeRef(day2), LastSeen: timeRef(day40)},
+			},
+		},
+		{
+			name: "forgotten routes are first seen again when they reappear",
+			now:  day40.Add(time.Hour),
+			analysisResult: analysisResultWith(frontPod2,
+				&types.AllowedRoute{SourcePod: frontPod2, TargetPod: apiPod}),
+			expectedRoutes: []*types.AllowedRoute{
+				{SourcePod: frontPod2, TargetPod: apiPod, FirstSeen: timeRef(day40.Add(time.Hour)),
+					LastSeen: timeRef(day40.Add(time.Hour))},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A new tracker for each analysis ensures the history is read back from the store
+			tracker := newTracker(historyStore, Options{Retention: 30 * 24 * time.Hour})
+			tracker.now = func() time.Time { return tt.now }
+			trackedResult := tracker.track(tt.analysisResult)
+			if diff := cmp.Diff(tt.expectedRoutes, trackedResult.AllowedRoutes); diff != "" {
+				t.Errorf("track() result mismatch (-want +got):\n%s", diff)
+			}
+			for _, allowedRoute := range tt.analysisResult.AllowedRoutes {
+				if allowedRoute.FirstSeen != nil {
+					t.Errorf("track() modified the routes of the analysis result")
+				}
+			}
+		})
+	}
+}
+
+func timeRef(value time.Time) *time.Time {
+	return &value
+}
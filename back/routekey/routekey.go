@@ -0,0 +1,165 @@
+package routekey
+
+import (
+	"fmt"
+	"karto/types"
+	"sort"
+)
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+	// allPortsHash is never the hash of a set of ports, whose computation always starts from the offset
+	allPortsHash = 0
+	// rangesMarker is folded before the port ranges, so that they never hash as ports, which are positive
+	rangesMarker = -1
+	// protocolMarker is folded around the protocols of the ports of each protocol, so that they never hash as ports
+	protocolMarker = -2
+)
+
+// Key identifies a route by its pods and its set of ports, whatever the policies allowing it
+type Key struct {
+	Source types.PodRef
+	Target types.PodRef
+	// PortsHash hashes the set of ports and the port ranges, along with those of each protocol, a route allowed on all
+	// ports hashing differently from one allowed on none
+	PortsHash uint64
+}
+
+// Of expects the port ranges and the ports of each protocol of the route sorted and merged, as the allowed route
+// analyzer lists them
+func Of(route *types.AllowedRoute) Key {
+	hash := foldRanges(portsHash(route.Ports), route.PortRanges)
+	if hash != allPortsHash {
+		for _, protocolPorts := range route.ProtocolPorts {
+			hash = fold(hash, protocolMarker)
+			for _, character := range protocolPorts.Protocol {
+				hash = fold(hash, character)
+			}
+			hash = fold(hash, protocolMarker)
+			for _, port := range protocolPorts.Ports {
+				hash = fold(hash, port)
+			}
+			hash = foldRanges(hash, protocolPorts.PortRanges)
+		}
+	}
+	return Key{Source: route.SourcePod, Target: route.TargetPod, PortsHash: hash}
+}
+
+func foldRanges(hash uint64, portRanges []types.PortRange) uint64 {
+	if hash == allPortsHash || len(portRanges) == 0 {
+		return hash
+	}
+	hash = fold(hash, rangesMarker)
+	for _, portRange := range portRanges {
+		hash = fold(fold(hash, portRange.Port), portRange.EndPort)
+	}
+	return hash
+}
+
+func (key Key) String() string {
+	return fmt.Sprintf("%s/%s>%s/%s:%016x", key.Source.Namespace, key.Source.Name, key.Target.Namespace,
+		key.Target.Name, key.PortsHash)
+}
+
+// portsHash computes the FNV-1a hash of the sorted and unique ports inline, as it is computed for every route
+func portsHash(ports []int32) uint64 {
+	if ports == nil {
+		return allPortsHash
+	}
+	if !sortedAndUnique(ports) {
+		uniquePorts := make(map[int32]bool, len(ports))
+		for _, port := range ports {
+			uniquePorts[port] = true
+		}
+		ports = make([]int32, 0, len(uniquePorts))
+		for port := range uniquePorts {
+			ports = append(ports, port)
+		}
+		sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	}
+	hash := uint64(fnvOffset)
+	for _, port := range ports {
+		hash = fold(hash, port)
+	}
+	return hash
+}
+
+func fold(hash uint64, port int32) uint64 {
+	for shift := 0; shift < 32; shift += 8 {
+		hash ^= uint64(byte(port >> shift))
+		hash *= fnvPrime
+	}
+	return hash
+}
+
+func sortedAndUnique(ports []int32) bool {
+	for i := 1; i < len(ports); i++ {
+		if ports[i-1] >= ports[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Deduplicate keeps a single route per key, in the order of their first occurrence. Policies and warnings of the
+// duplicates are listed on the kept route, which is a copy when it has duplicates. It also returns the number of
+// routes merged into another.
+func Deduplicate(routes []*types.AllowedRoute) ([]*types.AllowedRoute, int) {
+	indexByKey := make(map[Key]int, len(routes))
+	deduplicated := make([]*types.AllowedRoute, 0, len(routes))
+	merged := 0
+	for _, route := range routes {
+		key := Of(route)
+		i, ok := indexByKey[key]
+		if !ok {
+			indexByKey[key] = len(deduplicated)
+			deduplicated = append(deduplicated, route)
+			continue
+		}
+		mergedRoute := *deduplicated[i]
+		mergedRoute.IngressPolicies = mergePolicies(mergedRoute.IngressPolicies, route.IngressPolicies)
+		mergedRoute.EgressPolicies = mergePolicies(mergedRoute.EgressPolicies, route.EgressPolicies)
+		mergedRoute.Warnings = mergeWarnings(mergedRoute.Warnings, route.Warnings)
+		deduplicated[i] = &mergedRoute
+		merged++
+	}
+	return deduplicated, merged
+}
+
+func mergePolicies(policies []types.NetworkPolicy, others []types.NetworkPolicy) []types.NetworkPolicy {
+	type policyRef struct {
+		name      string
+		namespace string
+	}
+	result := make([]types.NetworkPolicy, 0, len(policies)+len(others))
+	listed := make(map[policyRef]bool)
+	for _, policyList := range [][]types.NetworkPolicy{policies, others} {
+		for _, policy := range policyList {
+			ref := policyRef{name: policy.Name, namespace: policy.Namespace}
+			if !listed[ref] {
+				listed[ref] = true
+				result = append(result, policy)
+			}
+		}
+	}
+	return result
+}
+
+func mergeWarnings(warnings []string, others []string) []string {
+	if warnings == nil && others == nil {
+		return nil
+	}
+	warningsSet := make(map[string]bool)
+	for _, warningList := range [][]string{warnings, others} {
+		for _, warning := range warningList {
+			warningsSet[warning] = true
+		}
+	}
+	result := make([]string, 0, len(warningsSet))
+	for warning := range warningsSet {
+		result = append(result, warning)
+	}
+	sort.Strings(result)
+	return result
+}
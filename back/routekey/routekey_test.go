@@ -0,0 +1,105 @@
+package routekey
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"karto/types"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	db := types.PodRef{Name: "db", Namespace: "shop"}
+	tests := []struct {
+		name          string
+		route1        *types.AllowedRoute
+		route2        *types.AllowedRoute
+		expectedEqual bool
+	}{
+		{
+			name:          "ports in another order have the same key",
+			route1:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{443, 80}},
+			route2:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{80, 443, 80}},
+			expectedEqual: true,
+		},
+		{
+			name: "policies are not part of the key",
+			route1: &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{80},
+				IngressPolicies: []types.NetworkPolicy{{Name: "allow-front", Namespace: "shop"}}},
+			route2:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{80}},
+			expectedEqual: true,
+		},
+		{
+			name:          "all ports differ from no port",
+			route1:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: nil},
+			route2:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{}},
+			expectedEqual: false,
+		},
+		{
+			name: "port ranges are part of the key",
+			route1: &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			route2:        &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{8000, 8100}},
+			expectedEqual: false,
+		},
+		{
+			name: "the protocols of the ports are part of the key",
+			route1: &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{53, 80},
+				ProtocolPorts: []types.ProtocolPorts{
+					{Protocol: corev1.ProtocolTCP, Ports: []int32{80}},
+					{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+				}},
+			route2: &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{53, 80},
+				ProtocolPorts: []types.ProtocolPorts{
+					{Protocol: corev1.ProtocolTCP, Ports: []int32{53}},
+					{Protocol: corev1.ProtocolUDP, Ports: []int32{80}},
+				}},
+			expectedEqual: false,
+		},
+		{
+			name:          "the direction is part of the key",
+			route1:        &types.AllowedRoute{SourcePod: front, TargetPod: db},
+			route2:        &types.AllowedRoute{SourcePod: db, TargetPod: front},
+			expectedEqual: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := Of(tt.route1) == Of(tt.route2); equal != tt.expectedEqual {
+				t.Errorf("Of(%s) == Of(%s) is %v, expected %v", Of(tt.route1), Of(tt.route2), equal,
+					tt.expectedEqual)
+			}
+		})
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	db := types.PodRef{Name: "db", Namespace: "shop"}
+	allowFront := types.NetworkPolicy{Name: "allow-front", Namespace: "shop"}
+	allowAll := types.NetworkPolicy{Name: "allow-all", Namespace: "shop"}
+	egress := types.NetworkPolicy{Name: "egress", Namespace: "shop"}
+	first := &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{5432},
+		IngressPolicies: []types.NetworkPolicy{allowFront}, EgressPolicies: []types.NetworkPolicy{egress},
+		Warnings: []string{"b"}}
+	other := &types.AllowedRoute{SourcePod: db, TargetPod: front, Ports: nil}
+	duplicate := &types.AllowedRoute{SourcePod: front, TargetPod: db, Ports: []int32{5432},
+		IngressPolicies: []types.NetworkPolicy{allowAll, allowFront}, EgressPolicies: []types.NetworkPolicy{egress},
+		Warnings: []string{"a"}}
+	routes, merged := Deduplicate([]*types.AllowedRoute{first, other, duplicate})
+	expectedRoutes := []*types.AllowedRoute{
+		{SourcePod: front, TargetPod: db, Ports: []int32{5432},
+			IngressPolicies: []types.NetworkPolicy{allowFront, allowAll}, EgressPolicies: []types.NetworkPolicy{egress},
+			Warnings: []string{"a", "b"}},
+		other,
+	}
+	if diff := cmp.Diff(expectedRoutes, routes); diff != "" {
+		t.Errorf("Deduplicate() routes mismatch (-want +got):\n%s", diff)
+	}
+	if merged != 1 {
+		t.Errorf("Deduplicate() merged = %d, expected 1", merged)
+	}
+	if len(first.IngressPolicies) != 1 {
+		t.Errorf("Deduplicate() modified the first route instead of merging into a copy")
+	}
+}
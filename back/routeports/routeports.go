@@ -0,0 +1,350 @@
+package routeports
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/types"
+	"sort"
+	"strconv"
+)
+
+const (
+	minPort = 1
+	maxPort = 65535
+)
+
+// protocolRanks order the ports of each protocol as the protocols of network policies
+var protocolRanks = map[corev1.Protocol]int{corev1.ProtocolTCP: 0, corev1.ProtocolUDP: 1, corev1.ProtocolSCTP: 2}
+
+// Set is the set of ports of a route, laid out as on the allowed routes: nil ports stand for all the ports of every
+// protocol, the ranges being listed along with ports only. The ports of each protocol detail them, those of a route
+// which does not list them applying to every protocol.
+type Set struct {
+	Ports         []int32
+	PortRanges    []types.PortRange
+	ProtocolPorts []types.ProtocolPorts
+}
+
+func Of(route *types.AllowedRoute) Set {
+	return Set{Ports: route.Ports, PortRanges: route.PortRanges, ProtocolPorts: route.ProtocolPorts}
+}
+
+// None is the empty set, from which the ports of several routes are merged
+func None() Set {
+	return Set{Ports: make([]int32, 0), ProtocolPorts: make([]types.ProtocolPorts, 0)}
+}
+
+func (set Set) AllPorts() bool {
+	return set.Ports == nil
+}
+
+func (set Set) IsEmpty() bool {
+	return set.Ports != nil && len(set.Ports) == 0 && len(set.PortRanges) == 0
+}
+
+// Equal tells whether both sets list the same ports, all ports differing from no port
+func Equal(set Set, other Set) bool {
+	if !samePorts(set.Ports, other.Ports) || !sameRanges(set.PortRanges, other.PortRanges) ||
+		(set.ProtocolPorts == nil) != (other.ProtocolPorts == nil) || len(set.ProtocolPorts) != len(other.ProtocolPorts) {
+		return false
+	}
+	for i, protocolPorts := range set.ProtocolPorts {
+		otherProtocolPorts := other.ProtocolPorts[i]
+		if protocolPorts.Protocol != otherProtocolPorts.Protocol ||
+			!samePorts(protocolPorts.Ports, otherProtocolPorts.Ports) ||
+			!sameRanges(protocolPorts.PortRanges, otherProtocolPorts.PortRanges) {
+			return false
+		}
+	}
+	return true
+}
+
+func samePorts(ports []int32, others []int32) bool {
+	if (ports == nil) != (others == nil) || len(ports) != len(others) {
+		return false
+	}
+	for i := range ports {
+		if ports[i] != others[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRanges(ranges []types.PortRange, others []types.PortRange) bool {
+	if len(ranges) != len(others) {
+		return false
+	}
+	for i := range ranges {
+		if ranges[i] != others[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge returns the ports of either set. The ports of a protocol listed by a single set are kept as they are, those
+// listed by both being merged.
+func Merge(set Set, other Set) Set {
+	ports, portRanges := mergePortsAndRanges(set.Ports, set.PortRanges, other.Ports, other.PortRanges)
+	return Set{Ports: ports, PortRanges: portRanges, ProtocolPorts: mergeProtocolPorts(set.ProtocolPorts,
+		other.ProtocolPorts)}
+}
+
+// A nil ports list means all ports, which subsumes any other list
+func mergePorts(ports []int32, others []int32) []int32 {
+	if ports == nil || others == nil {
+		return nil
+	}
+	seen := make(map[int32]bool)
+	result := make([]int32, 0)
+	for _, port := range append(append(make([]int32, 0), ports...), others...) {
+		if !seen[port] {
+			seen[port] = true
+			result = append(result, port)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func mergePortsAndRanges(ports []int32, ranges []types.PortRange, otherPorts []int32,
+	otherRanges []types.PortRange) ([]int32, []types.PortRange) {
+	mergedPorts := mergePorts(ports, otherPorts)
+	if mergedPorts == nil || len(ranges)+len(otherRanges) == 0 {
+		return mergedPorts, nil
+	}
+	mergedRanges := mergeRanges(append(append(make([]types.PortRange, 0), ranges...), otherRanges...))
+	return outsideRanges(mergedPorts, mergedRanges), mergedRanges
+}
+
+// mergeRanges sorts the ranges and merges those overlapping or adjacent, in place
+func mergeRanges(ranges []types.PortRange) []types.PortRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Port < ranges[j].Port })
+	merged := []types.PortRange{ranges[0]}
+	for _, portRange := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if portRange.Port > last.EndPort+1 {
+			merged = append(merged, portRange)
+		} else if portRange.EndPort > last.EndPort {
+			last.EndPort = portRange.EndPort
+		}
+	}
+	return merged
+}
+
+func mergeProtocolPorts(protocolPorts []types.ProtocolPorts, others []types.ProtocolPorts) []types.ProtocolPorts {
+	if protocolPorts == nil || others == nil {
+		return nil
+	}
+	result := append(make([]types.ProtocolPorts, 0, len(protocolPorts)+len(others)), protocolPorts...)
+	for _, other := range others {
+		found := false
+		for i := range result {
+			if result[i].Protocol == other.Protocol {
+				result[i].Ports, result[i].PortRanges = mergePortsAndRanges(result[i].Ports, result[i].PortRanges,
+					other.Ports, other.PortRanges)
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, other)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return protocolRanks[result[i].Protocol] < protocolRanks[result[j].Protocol]
+	})
+	return result
+}
+
+// Subtract returns the ports of the set which the other one does not list. All the ports of a protocol are kept
+// whole unless the other set lists all of them too, as they cannot be listed once some are removed.
+func Subtract(set Set, other Set) Set {
+	if other.AllPorts() {
+		return None()
+	}
+	if set.AllPorts() {
+		return set
+	}
+	if set.ProtocolPorts == nil {
+		ports, portRanges := subtractPorts(set.Ports, set.PortRanges, other.Ports, other.PortRanges)
+		return Set{Ports: ports, PortRanges: portRanges}
+	}
+	result := Set{Ports: make([]int32, 0), ProtocolPorts: make([]types.ProtocolPorts, 0)}
+	for _, protocolPorts := range set.ProtocolPorts {
+		otherPorts, otherRanges := other.portsOf(protocolPorts.Protocol)
+		if otherPorts == nil {
+			continue
+		}
+		remaining := protocolPorts
+		if protocolPorts.Ports != nil {
+			remaining.Ports, remaining.PortRanges = subtractPorts(protocolPorts.Ports, protocolPorts.PortRanges,
+				otherPorts, otherRanges)
+			if len(remaining.Ports) == 0 && len(remaining.PortRanges) == 0 {
+				continue
+			}
+		}
+		result.ProtocolPorts = append(result.ProtocolPorts, remaining)
+		ports, portRanges := remaining.Ports, remaining.PortRanges
+		if ports == nil {
+			// The union lists the ports of the protocols whose ports are all listed as the range of all ports
+			ports, portRanges = make([]int32, 0), []types.PortRange{{Port: minPort, EndPort: maxPort}}
+		}
+		result.Ports, result.PortRanges = mergePortsAndRanges(result.Ports, result.PortRanges, ports, portRanges)
+	}
+	return result
+}
+
+// portsOf returns the ports and ranges of the protocol, nil ports standing for all of them
+func (set Set) portsOf(protocol corev1.Protocol) ([]int32, []types.PortRange) {
+	if set.ProtocolPorts == nil {
+		return set.Ports, set.PortRanges
+	}
+	for _, protocolPorts := range set.ProtocolPorts {
+		if protocolPorts.Protocol == protocol {
+			return protocolPorts.Ports, protocolPorts.PortRanges
+		}
+	}
+	return make([]int32, 0), nil
+}
+
+// subtractPorts removes the other ports and ranges from the ports and ranges, the single ports left of a range being
+// listed as ports
+func subtractPorts(ports []int32, ranges []types.PortRange, otherPorts []int32,
+	otherRanges []types.PortRange) ([]int32, []types.PortRange) {
+	resultPorts := make([]int32, 0)
+	for _, port := range ports {
+		if !containsPort(otherPorts, port) && !inRanges(otherRanges, port) {
+			resultPorts = append(resultPorts, port)
+		}
+	}
+	cuts := append(make([]types.PortRange, 0, len(otherPorts)+len(otherRanges)), otherRanges...)
+	for _, port := range otherPorts {
+		cuts = append(cuts, types.PortRange{Port: port, EndPort: port})
+	}
+	cuts = mergeRanges(cuts)
+	var resultRanges []types.PortRange
+	for _, portRange := range ranges {
+		start := portRange.Port
+		for _, cut := range cuts {
+			if cut.EndPort < start || cut.Port > portRange.EndPort {
+				continue
+			}
+			if cut.Port > start {
+				resultPorts, resultRanges = appendSpan(resultPorts, resultRanges, start, cut.Port-1)
+			}
+			start = cut.EndPort + 1
+		}
+		if start <= portRange.EndPort {
+			resultPorts, resultRanges = appendSpan(resultPorts, resultRanges, start, portRange.EndPort)
+		}
+	}
+	sort.Slice(resultPorts, func(i, j int) bool { return resultPorts[i] < resultPorts[j] })
+	return resultPorts, resultRanges
+}
+
+func appendSpan(ports []int32, ranges []types.PortRange, port int32, endPort int32) ([]int32, []types.PortRange) {
+	if port == endPort {
+		return append(ports, port), ranges
+	}
+	return ports, append(ranges, types.PortRange{Port: port, EndPort: endPort})
+}
+
+func outsideRanges(ports []int32, ranges []types.PortRange) []int32 {
+	if len(ranges) == 0 {
+		return ports
+	}
+	result := make([]int32, 0, len(ports))
+	for _, port := range ports {
+		if !inRanges(ranges, port) {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+func containsPort(ports []int32, port int32) bool {
+	for _, candidate := range ports {
+		if candidate == port {
+			return true
+		}
+	}
+	return false
+}
+
+func inRanges(ranges []types.PortRange, port int32) bool {
+	for _, portRange := range ranges {
+		if portRange.Port <= port && port <= portRange.EndPort {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings formats the ports and ranges of the set, whatever their protocol, in the order of their first port. It is
+// nil when the set lists all ports.
+func (set Set) Strings() []string {
+	if set.AllPorts() {
+		return nil
+	}
+	type span struct {
+		port      int32
+		formatted string
+	}
+	spans := make([]span, 0, len(set.Ports)+len(set.PortRanges))
+	for _, port := range set.Ports {
+		spans = append(spans, span{port: port, formatted: strconv.Itoa(int(port))})
+	}
+	for _, portRange := range set.PortRanges {
+		spans = append(spans, span{port: portRange.Port,
+			formatted: strconv.Itoa(int(portRange.Port)) + "-" + strconv.Itoa(int(portRange.EndPort))})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].port < spans[j].port })
+	result := make([]string, 0, len(spans))
+	for _, span := range spans {
+		result = append(result, span.formatted)
+	}
+	return result
+}
+
+// NetworkPolicyPorts returns the ports of network policy rules allowing the set, nil when it lists all ports. The
+// ports of a set which does not list those of each protocol are allowed for TCP, by far the most common.
+func (set Set) NetworkPolicyPorts() []networkingv1.NetworkPolicyPort {
+	if set.AllPorts() {
+		return nil
+	}
+	protocolPorts := set.ProtocolPorts
+	if protocolPorts == nil {
+		protocolPorts = []types.ProtocolPorts{{Protocol: corev1.ProtocolTCP, Ports: set.Ports,
+			PortRanges: set.PortRanges}}
+	}
+	policyPorts := make([]networkingv1.NetworkPolicyPort, 0)
+	for _, ports := range protocolPorts {
+		if ports.Ports == nil {
+			protocol := ports.Protocol
+			policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol})
+			continue
+		}
+		spans := append(make([]types.PortRange, 0, len(ports.Ports)+len(ports.PortRanges)), ports.PortRanges...)
+		for _, port := range ports.Ports {
+			spans = append(spans, types.PortRange{Port: port, EndPort: port})
+		}
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].Port < spans[j].Port })
+		for _, span := range spans {
+			protocol := ports.Protocol
+			policyPort := intstr.FromInt(int(span.Port))
+			networkPolicyPort := networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &policyPort}
+			if span.EndPort != span.Port {
+				endPort := span.EndPort
+				networkPolicyPort.EndPort = &endPort
+			}
+			policyPorts = append(policyPorts, networkPolicyPort)
+		}
+	}
+	return policyPorts
+}
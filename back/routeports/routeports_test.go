@@ -0,0 +1,191 @@
+package routeports
+
+import (
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"karto/types"
+	"testing"
+)
+
+func tcpPorts(ports []int32, portRanges ...types.PortRange) types.ProtocolPorts {
+	return types.ProtocolPorts{Protocol: corev1.ProtocolTCP, Ports: ports, PortRanges: portRanges}
+}
+
+func udpPorts(ports []int32, portRanges ...types.PortRange) types.ProtocolPorts {
+	return types.ProtocolPorts{Protocol: corev1.ProtocolUDP, Ports: ports, PortRanges: portRanges}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name          string
+		set           Set
+		other         Set
+		expectedEqual bool
+	}{
+		{
+			name:          "sets listing the same ports and ranges are equal",
+			set:           Set{Ports: []int32{80}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			other:         Set{Ports: []int32{80}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			expectedEqual: true,
+		},
+		{
+			name:          "all ports differ from no port",
+			set:           Set{Ports: nil},
+			other:         Set{Ports: []int32{}},
+			expectedEqual: false,
+		},
+		{
+			name:          "sets with different ranges differ",
+			set:           Set{Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8080}}},
+			other:         Set{Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			expectedEqual: false,
+		},
+		{
+			name: "sets with the same union but different protocols differ",
+			set: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				tcpPorts([]int32{53})}},
+			other: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				udpPorts([]int32{53})}},
+			expectedEqual: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expectedEqual, Equal(tt.set, tt.other)); diff != "" {
+				t.Errorf("Equal() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		set         Set
+		other       Set
+		expectedSet Set
+	}{
+		{
+			name:        "all ports subsume any other port",
+			set:         Set{Ports: nil},
+			other:       Set{Ports: []int32{80}},
+			expectedSet: Set{Ports: nil},
+		},
+		{
+			name:  "ports and overlapping ranges are merged",
+			set:   Set{Ports: []int32{80, 8050}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8060}}},
+			other: Set{Ports: []int32{443}, PortRanges: []types.PortRange{{Port: 8061, EndPort: 8100}}},
+			expectedSet: Set{Ports: []int32{80, 443},
+				PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+		},
+		{
+			name: "the ports of each protocol are merged",
+			set: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				udpPorts([]int32{53})}},
+			other: Set{Ports: []int32{}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{tcpPorts([]int32{}, types.PortRange{Port: 8000, EndPort: 8100})}},
+			expectedSet: Set{Ports: []int32{53}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}},
+				ProtocolPorts: []types.ProtocolPorts{tcpPorts([]int32{}, types.PortRange{Port: 8000, EndPort: 8100}),
+					udpPorts([]int32{53})}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expectedSet, Merge(tt.set, tt.other)); diff != "" {
+				t.Errorf("Merge() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		set         Set
+		other       Set
+		expectedSet Set
+	}{
+		{
+			name:        "nothing is left once all ports are subtracted",
+			set:         Set{Ports: []int32{80}},
+			other:       Set{Ports: nil},
+			expectedSet: None(),
+		},
+		{
+			name:  "ranges are cut by the subtracted ports and ranges",
+			set:   Set{Ports: []int32{80}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			other: Set{Ports: []int32{80, 8000, 8100}, PortRanges: []types.PortRange{{Port: 8050, EndPort: 8098}}},
+			expectedSet: Set{Ports: []int32{8099},
+				PortRanges: []types.PortRange{{Port: 8001, EndPort: 8049}}},
+		},
+		{
+			name: "the ports of each protocol are subtracted",
+			set: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				tcpPorts([]int32{53}), udpPorts([]int32{53})}},
+			other: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				tcpPorts([]int32{53})}},
+			expectedSet: Set{Ports: []int32{53}, ProtocolPorts: []types.ProtocolPorts{
+				udpPorts([]int32{53})}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expectedSet, Subtract(tt.set, tt.other)); diff != "" {
+				t.Errorf("Subtract() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	set := Set{Ports: []int32{9090, 80}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}}
+	expectedStrings := []string{"80", "8000-8100", "9090"}
+	if diff := cmp.Diff(expectedStrings, set.Strings()); diff != "" {
+		t.Errorf("Strings() result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNetworkPolicyPorts(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	udp := corev1.ProtocolUDP
+	port80 := intstr.FromInt(80)
+	port8000 := intstr.FromInt(8000)
+	endPort := int32(8100)
+	tests := []struct {
+		name          string
+		set           Set
+		expectedPorts []networkingv1.NetworkPolicyPort
+	}{
+		{
+			name:          "all ports need no port",
+			set:           Set{Ports: nil},
+			expectedPorts: nil,
+		},
+		{
+			name: "the ports of a set not listing protocols are allowed for TCP",
+			set:  Set{Ports: []int32{80}, PortRanges: []types.PortRange{{Port: 8000, EndPort: 8100}}},
+			expectedPorts: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: &port80},
+				{Protocol: &tcp, Port: &port8000, EndPort: &endPort},
+			},
+		},
+		{
+			name: "all the ports of a protocol are allowed by the protocol alone",
+			set: Set{Ports: []int32{80}, PortRanges: []types.PortRange{{Port: 1, EndPort: 65535}},
+				ProtocolPorts: []types.ProtocolPorts{tcpPorts([]int32{80}), udpPorts(nil)}},
+			expectedPorts: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: &port80},
+				{Protocol: &udp},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expectedPorts, tt.set.NetworkPolicyPorts()); diff != "" {
+				t.Errorf("NetworkPolicyPorts() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package sampling
+
+import (
+	"fmt"
+	"karto/types"
+	"math/rand"
+	"sort"
+)
+
+const (
+	StrategyRisk           = "risk"
+	StrategyRandom         = "random"
+	StrategyCrossNamespace = "crossNamespace"
+)
+
+// Sample is a subset of the allowed routes, summarized by statistics computed on all of them
+type Sample struct {
+	Strategy string                `json:"strategy"`
+	Routes   []*types.AllowedRoute `json:"routes"`
+	Summary  Summary               `json:"summary"`
+}
+
+type Summary struct {
+	TotalRoutes          int     `json:"totalRoutes"`
+	SampledRoutes        int     `json:"sampledRoutes"`
+	CrossNamespaceRoutes int     `json:"crossNamespaceRoutes"`
+	Namespaces           int     `json:"namespaces"`
+	MaxRiskScore         int     `json:"maxRiskScore"`
+	AverageRiskScore     float64 `json:"averageRiskScore"`
+}
+
+// Build selects at most size routes: the riskiest ones, uniformly random ones, or the riskiest cross-namespace ones.
+// The allowed routes are never modified, being shared with other readers of the analysis result.
+func Build(allowedRoutes []*types.AllowedRoute, size int, strategy string, random *rand.Rand) (Sample, error) {
+	if size < 1 {
+		return Sample{}, fmt.Errorf("invalid sample size %d: expected a positive integer", size)
+	}
+	var routes []*types.AllowedRoute
+	switch strategy {
+	case StrategyRisk:
+		routes = riskiest(allowedRoutes, size)
+	case StrategyRandom:
+		routes = randomRoutes(allowedRoutes, size, random)
+	case StrategyCrossNamespace:
+		crossNamespace := make([]*types.AllowedRoute, 0)
+		for _, allowedRoute := range allowedRoutes {
+			if isCrossNamespace(allowedRoute) {
+				crossNamespace = append(crossNamespace, allowedRoute)
+			}
+		}
+		routes = riskiest(crossNamespace, size)
+	default:
+		return Sample{}, fmt.Errorf("invalid strategy %s: expected %s, %s or %s", strategy, StrategyRisk,
+			StrategyRandom, StrategyCrossNamespace)
+	}
+	summary := summarize(allowedRoutes)
+	summary.SampledRoutes = len(routes)
+	return Sample{Strategy: strategy, Routes: routes, Summary: summary}, nil
+}
+
+func riskiest(allowedRoutes []*types.AllowedRoute, size int) []*types.AllowedRoute {
+	result := append(make([]*types.AllowedRoute, 0, len(allowedRoutes)), allowedRoutes...)
+	sort.SliceStable(result, func(i, j int) bool { return result[i].RiskScore > result[j].RiskScore })
+	if len(result) > size {
+		result = result[:size]
+	}
+	return result
+}
+
+// Randomly picked routes keep their original order, so that successive samples are displayed consistently
+func randomRoutes(allowedRoutes []*types.AllowedRoute, size int, random *rand.Rand) []*types.AllowedRoute {
+	if len(allowedRoutes) <= size {
+		return append(make([]*types.AllowedRoute, 0, len(allowedRoutes)), allowedRoutes...)
+	}
+	indexes := random.Perm(len(allowedRoutes))[:size]
+	sort.Ints(indexes)
+	result := make([]*types.AllowedRoute, 0, size)
+	for _, index := range indexes {
+		result = append(result, allowedRoutes[index])
+	}
+	return result
+}
+
+func summarize(allowedRoutes []*types.AllowedRoute) Summary {
+	summary := Summary{TotalRoutes: len(allowedRoutes)}
+	namespaces := make(map[string]bool)
+	totalRiskScore := 0
+	for _, allowedRoute := range allowedRoutes {
+		namespaces[allowedRoute.SourcePod.Namespace] = true
+		namespaces[allowedRoute.TargetPod.Namespace] = true
+		if isCrossNamespace(allowedRoute) {
+			summary.CrossNamespaceRoutes++
+		}
+		if allowedRoute.RiskScore > summary.MaxRiskScore {
+			summary.MaxRiskScore = allowedRoute.RiskScore
+		}
+		totalRiskScore += allowedRoute.RiskScore
+	}
+	summary.Namespaces = len(namespaces)
+	if len(allowedRoutes) > 0 {
+		summary.AverageRiskScore = float64(totalRiskScore) / float64(len(allowedRoutes))
+	}
+	return summary
+}
+
+func isCrossNamespace(allowedRoute *types.AllowedRoute) bool {
+	return allowedRoute.SourcePod.Namespace != allowedRoute.TargetPod.Namespace
+}
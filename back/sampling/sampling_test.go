@@ -0,0 +1,116 @@
+package sampling
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"math/rand"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	api := types.PodRef{Name: "api", Namespace: "shop"}
+	db := types.PodRef{Name: "db", Namespace: "data"}
+	frontToAPI := &types.AllowedRoute{SourcePod: front, TargetPod: api, RiskScore: 2}
+	apiToDB := &types.AllowedRoute{SourcePod: api, TargetPod: db, RiskScore: 3}
+	frontToDB := &types.AllowedRoute{SourcePod: front, TargetPod: db, RiskScore: 7}
+	dbToFront := &types.AllowedRoute{SourcePod: db, TargetPod: front, RiskScore: 0}
+	allowedRoutes := []*types.AllowedRoute{frontToAPI, apiToDB, frontToDB, dbToFront}
+	summary := Summary{TotalRoutes: 4, CrossNamespaceRoutes: 3, Namespaces: 2, MaxRiskScore: 7,
+		AverageRiskScore: 3}
+	withSampledRoutes := func(sampledRoutes int) Summary {
+		result := summary
+		result.SampledRoutes = sampledRoutes
+		return result
+	}
+	tests := []struct {
+		name           string
+		allowedRoutes  []*types.AllowedRoute
+		size           int
+		strategy       string
+		expectedSample Sample
+		expectedError  string
+	}{
+		{
+			name:          "riskiest routes first",
+			allowedRoutes: allowedRoutes,
+			size:          2,
+			strategy:      "risk",
+			expectedSample: Sample{Strategy: "risk", Routes: []*types.AllowedRoute{frontToDB, apiToDB},
+				Summary: withSampledRoutes(2)},
+		},
+		{
+			name:          "riskiest cross-namespace routes only",
+			allowedRoutes: allowedRoutes,
+			size:          10,
+			strategy:      "crossNamespace",
+			expectedSample: Sample{Strategy: "crossNamespace",
+				Routes: []*types.AllowedRoute{frontToDB, apiToDB, dbToFront}, Summary: withSampledRoutes(3)},
+		},
+		{
+			name:          "random sample larger than the routes keeps them all",
+			allowedRoutes: allowedRoutes,
+			size:          4,
+			strategy:      "random",
+			expectedSample: Sample{Strategy: "random", Routes: allowedRoutes,
+				Summary: withSampledRoutes(4)},
+		},
+		{
+			name:          "no routes",
+			allowedRoutes: []*types.AllowedRoute{},
+			size:          500,
+			strategy:      "risk",
+			expectedSample: Sample{Strategy: "risk", Routes: []*types.AllowedRoute{},
+				Summary: Summary{}},
+		},
+		{
+			name:          "unknown strategy",
+			allowedRoutes: allowedRoutes,
+			size:          2,
+			strategy:      "latest",
+			expectedError: "invalid strategy latest: expected risk, random or crossNamespace",
+		},
+		{
+			name:          "size is not positive",
+			allowedRoutes: allowedRoutes,
+			size:          0,
+			strategy:      "risk",
+			expectedError: "invalid sample size 0: expected a positive integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, err := Build(tt.allowedRoutes, tt.size, tt.strategy, rand.New(rand.NewSource(1)))
+			if err != nil || tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Build() error = %v, expected %s", err, tt.expectedError)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.expectedSample, sample); diff != "" {
+				t.Errorf("Build() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildRandomKeepsOrder(t *testing.T) {
+	allowedRoutes := make([]*types.AllowedRoute, 0)
+	for i := 0; i < 100; i++ {
+		allowedRoutes = append(allowedRoutes, &types.AllowedRoute{
+			SourcePod: types.PodRef{Name: "source", Namespace: "ns"}, TargetPod: types.PodRef{Namespace: "ns"},
+			RiskScore: i})
+	}
+	sample, err := Build(allowedRoutes, 10, StrategyRandom, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample.Routes) != 10 || sample.Summary.SampledRoutes != 10 || sample.Summary.TotalRoutes != 100 {
+		t.Fatalf("Build() sampled %d routes, summary %+v, expected 10 of 100", len(sample.Routes), sample.Summary)
+	}
+	for i := 1; i < len(sample.Routes); i++ {
+		if sample.Routes[i-1].RiskScore >= sample.Routes[i].RiskScore {
+			t.Errorf("Build() randomly sampled routes are not in their original order")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package servicerouting
+
+import (
+	"karto/types"
+)
+
+const (
+	RoutingCluster = "cluster"
+	RoutingNode    = "node"
+	RoutingZone    = "zone"
+)
+
+// Index tells through which services a pod reaches another one, after the routing of the services
+type Index struct {
+	servicesByPod map[types.PodRef][]*types.Service
+	zonesByPod    map[types.PodRef]string
+	serviceZones  map[*types.Service]map[string]bool
+}
+
+func NewIndex(analysisResult types.AnalysisResult) *Index {
+	index := &Index{
+		servicesByPod: make(map[types.PodRef][]*types.Service),
+		zonesByPod:    make(map[types.PodRef]string),
+		serviceZones:  make(map[*types.Service]map[string]bool),
+	}
+	for _, pod := range analysisResult.Pods {
+		if pod.Zone != "" {
+			index.zonesByPod[types.PodRef{Name: pod.Name, Namespace: pod.Namespace}] = pod.Zone
+		}
+	}
+	for _, service := range analysisResult.Services {
+		zones := make(map[string]bool)
+		for _, podRef := range service.TargetPods {
+			index.servicesByPod[podRef] = append(index.servicesByPod[podRef], service)
+			if zone, ok := index.zonesByPod[podRef]; ok {
+				zones[zone] = true
+			}
+		}
+		index.serviceZones[service] = zones
+	}
+	return index
+}
+
+// Targeting returns the services targeting the pod, whatever the pod reaching it
+func (index *Index) Targeting(pod types.PodRef) []types.ServiceRef {
+	services := make([]types.ServiceRef, 0)
+	for _, service := range index.servicesByPod[pod] {
+		services = append(services, types.ServiceRef{Name: service.Name, Namespace: service.Namespace})
+	}
+	return services
+}
+
+// Reaching returns the services through which the source pod of the route reaches its target pod. The target pod
+// must be on the node of the source pod for a node routing, and in its zone for a zone routing, unless no target pod
+// of the service is in that zone, the traffic then falling back to all of them.
+func (index *Index) Reaching(route *types.AllowedRoute) []types.ServiceRef {
+	services := make([]types.ServiceRef, 0)
+	for _, service := range index.servicesByPod[route.TargetPod] {
+		if index.eligible(service, route) {
+			services = append(services, types.ServiceRef{Name: service.Name, Namespace: service.Namespace})
+		}
+	}
+	return services
+}
+
+func (index *Index) eligible(service *types.Service, route *types.AllowedRoute) bool {
+	switch service.Routing {
+	case RoutingNode:
+		return route.SameNode != nil && *route.SameNode
+	case RoutingZone:
+		sourceZone, ok := index.zonesByPod[route.SourcePod]
+		if !ok || !index.serviceZones[service][sourceZone] {
+			return true
+		}
+		return index.zonesByPod[route.TargetPod] == sourceZone
+	default:
+		return true
+	}
+}
@@ -0,0 +1,64 @@
+package servicerouting
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+)
+
+func TestIndexReaching(t *testing.T) {
+	front := types.PodRef{Name: "front", Namespace: "shop"}
+	back1 := types.PodRef{Name: "back-1", Namespace: "shop"}
+	back2 := types.PodRef{Name: "back-2", Namespace: "shop"}
+	sameNode, otherNode := true, false
+	analysisResult := types.AnalysisResult{
+		Pods: []*types.Pod{
+			{Name: "front", Namespace: "shop", Zone: "a"},
+			{Name: "back-1", Namespace: "shop", Zone: "a"},
+			{Name: "back-2", Namespace: "shop", Zone: "b"},
+		},
+		Services: []*types.Service{
+			{Name: "back", Namespace: "shop", TargetPods: []types.PodRef{back1, back2}, Routing: RoutingCluster},
+			{Name: "back-local", Namespace: "shop", TargetPods: []types.PodRef{back1, back2}, Routing: RoutingNode},
+			{Name: "back-zonal", Namespace: "shop", TargetPods: []types.PodRef{back1, back2}, Routing: RoutingZone},
+			{Name: "back-2-zonal", Namespace: "shop", TargetPods: []types.PodRef{back2}, Routing: RoutingZone},
+		},
+	}
+	tests := []struct {
+		name             string
+		route            *types.AllowedRoute
+		expectedServices []types.ServiceRef
+	}{
+		{
+			name:  "target pods on the node and in the zone of the source pod are reached through every service",
+			route: &types.AllowedRoute{SourcePod: front, TargetPod: back1, SameNode: &sameNode},
+			expectedServices: []types.ServiceRef{
+				{Name: "back", Namespace: "shop"},
+				{Name: "back-local", Namespace: "shop"},
+				{Name: "back-zonal", Namespace: "shop"},
+			},
+		},
+		{
+			name:             "target pods on another node are not reached through local services",
+			route:            &types.AllowedRoute{SourcePod: front, TargetPod: back1, SameNode: &otherNode},
+			expectedServices: []types.ServiceRef{{Name: "back", Namespace: "shop"}, {Name: "back-zonal", Namespace: "shop"}},
+		},
+		{
+			name:  "target pods of another zone are only reached through zonal services without pods in the zone",
+			route: &types.AllowedRoute{SourcePod: front, TargetPod: back2},
+			expectedServices: []types.ServiceRef{
+				{Name: "back", Namespace: "shop"},
+				{Name: "back-2-zonal", Namespace: "shop"},
+			},
+		},
+	}
+	index := NewIndex(analysisResult)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := index.Reaching(tt.route)
+			if diff := cmp.Diff(tt.expectedServices, services); diff != "" {
+				t.Errorf("Reaching() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
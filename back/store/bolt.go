@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+const boltOpenTimeout = 10 * time.Second
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+// Collections are stored as buckets of a single database file, which can only be opened by one Karto instance
+func NewBoltStore(path string) (Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the bolt store requires a path")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bolt store %s: %s", path, err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (store *boltStore) Get(collection string, key string) ([]byte, bool, error) {
+	var value []byte
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+		if storedValue := bucket.Get([]byte(key)); storedValue != nil {
+			// Values are only valid during the transaction
+			value = append(make([]byte, 0, len(storedValue)), storedValue...)
+		}
+		return nil
+	})
+	return value, value != nil, err
+}
+
+func (store *boltStore) Put(collection string, key string, value []byte) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(collection))
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			value = make([]byte, 0)
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+func (store *boltStore) Delete(collection string, key string) (bool, error) {
+	deleted := false
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil || bucket.Get([]byte(key)) == nil {
+			return nil
+		}
+		deleted = true
+		return bucket.Delete([]byte(key))
+	})
+	return deleted, err
+}
+
+func (store *boltStore) Keys(collection string) ([]string, error) {
+	keys := make([]string, 0)
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+		// Bolt iterates over keys in byte order
+		return bucket.ForEach(func(key []byte, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+func (store *boltStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/lib/pq"
+	"os"
+)
+
+const postgresDSNEnv = "KARTO_STORE_DSN"
+
+const createTableStatement = `CREATE TABLE IF NOT EXISTS karto_store (
+	collection TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BYTEA NOT NULL,
+	PRIMARY KEY (collection, key)
+)`
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// The DSN is read from the KARTO_STORE_DSN environment variable when not set, to keep the password out of the
+// configuration file
+func NewPostgresStore(dsn string) (Store, error) {
+	if dsn == "" {
+		dsn = os.Getenv(postgresDSNEnv)
+	}
+	if dsn == "" {
+		return nil, fmt.Errorf("the postgres store requires a dsn or the %s environment variable", postgresDSNEnv)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open postgres store: %s", err)
+	}
+	_, err = db.Exec(createTableStatement)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to create postgres store table: %s", err)
+	}
+	return &postgresStore{db: db}, nil
+}
+
+func (store *postgresStore) Get(collection string, key string) ([]byte, bool, error) {
+	var value []byte
+	err := store.db.QueryRow("SELECT value FROM karto_store WHERE collection = $1 AND key = $2",
+		collection, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (store *postgresStore) Put(collection string, key string, value []byte) error {
+	if value == nil {
+		value = make([]byte, 0)
+	}
+	_, err := store.db.Exec("INSERT INTO karto_store (collection, key, value) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (collection, key) DO UPDATE SET value = EXCLUDED.value", collection, key, value)
+	return err
+}
+
+func (store *postgresStore) Delete(collection string, key string) (bool, error) {
+	result, err := store.db.Exec("DELETE FROM karto_store WHERE collection = $1 AND key = $2", collection, key)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func (store *postgresStore) Keys(collection string) ([]string, error) {
+	rows, err := store.db.Query("SELECT key FROM karto_store WHERE collection = $1 ORDER BY key", collection)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (store *postgresStore) Close() error {
+	return store.db.Close()
+}
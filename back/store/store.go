@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	DriverMemory   = "memory"
+	DriverBolt     = "bolt"
+	DriverPostgres = "postgres"
+)
+
+// Store keeps values by key in collections, so that the state of Karto can be shared or survive restarts
+type Store interface {
+	Get(collection string, key string) ([]byte, bool, error)
+	Put(collection string, key string, value []byte) error
+	Delete(collection string, key string) (bool, error)
+	Keys(collection string) ([]string, error)
+	Close() error
+}
+
+type Options struct {
+	Driver string
+	Path   string
+	DSN    string
+}
+
+func New(options Options) (Store, error) {
+	switch options.Driver {
+	case "", DriverMemory:
+		return NewMemoryStore(), nil
+	case DriverBolt:
+		return NewBoltStore(options.Path)
+	case DriverPostgres:
+		return NewPostgresStore(options.DSN)
+	default:
+		return nil, fmt.Errorf("unknown store driver %s", options.Driver)
+	}
+}
+
+type memoryStore struct {
+	mutex       sync.RWMutex
+	collections map[string]map[string][]byte
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		collections: make(map[string]map[string][]byte),
+	}
+}
+
+func (store *memoryStore) Get(collection string, key string) ([]byte, bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	value, ok := store.collections[collection][key]
+	return value, ok, nil
+}
+
+func (store *memoryStore) Put(collection string, key string, value []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.collections[collection] == nil {
+		store.collections[collection] = make(map[string][]byte)
+	}
+	store.collections[collection][key] = append(make([]byte, 0, len(value)), value...)
+	return nil
+}
+
+func (store *memoryStore) Delete(collection string, key string) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.collections[collection][key]; !ok {
+		return false, nil
+	}
+	delete(store.collections[collection], key)
+	return true, nil
+}
+
+func (store *memoryStore) Keys(collection string) ([]string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	keys := make([]string, 0)
+	for key := range store.collections[collection] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (store *memoryStore) Close() error {
+	return nil
+}
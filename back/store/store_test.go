@@ -0,0 +1,90 @@
+package store
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	directory, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(directory)
+	}()
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name:    "memory store",
+			options: Options{Driver: DriverMemory},
+		},
+		{
+			name:    "bolt store",
+			options: Options{Driver: DriverBolt, Path: filepath.Join(directory, "karto.db")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := New(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = store.Close()
+			}()
+			for _, key := range []string{"b", "a"} {
+				err = store.Put("views", key, []byte("value "+key))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = store.Put("other", "c", []byte("other"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys, err := store.Keys("views")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff([]string{"a", "b"}, keys); diff != "" {
+				t.Errorf("Keys() result mismatch (-want +got):\n%s", diff)
+			}
+			value, ok, err := store.Get("views", "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff("value a", string(value)); !ok || diff != "" {
+				t.Errorf("Get() result mismatch (-want +got):\n%s", diff)
+			}
+			_, ok, _ = store.Get("views", "c")
+			if diff := cmp.Diff(false, ok); diff != "" {
+				t.Errorf("Get() of a key of another collection mismatch (-want +got):\n%s", diff)
+			}
+			deleted, _ := store.Delete("views", "a")
+			if diff := cmp.Diff(true, deleted); diff != "" {
+				t.Errorf("Delete() result mismatch (-want +got):\n%s", diff)
+			}
+			deleted, _ = store.Delete("views", "a")
+			if diff := cmp.Diff(false, deleted); diff != "" {
+				t.Errorf("Delete() of a missing key result mismatch (-want +got):\n%s", diff)
+			}
+			keys, _ = store.Keys("unknown")
+			if diff := cmp.Diff([]string{}, keys); diff != "" {
+				t.Errorf("Keys() of an unknown collection mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{Driver: "redis"})
+	if diff := cmp.Diff("unknown store driver redis", err.Error()); diff != "" {
+		t.Errorf("New() error mismatch (-want +got):\n%s", diff)
+	}
+}
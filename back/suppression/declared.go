@@ -0,0 +1,71 @@
+package suppression
+
+import (
+	"karto/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeclaredStore adds the suppressions declared as custom resources to those of another store. Saving and deleting
+// only affect the other store, declared suppressions being managed through their resources.
+type DeclaredStore struct {
+	store    Store
+	mutex    sync.RWMutex
+	declared []*types.FindingSuppression
+	now      func() time.Time
+}
+
+func NewDeclaredStore(store Store) *DeclaredStore {
+	return &DeclaredStore{
+		store:    store,
+		declared: make([]*types.FindingSuppression, 0),
+		now:      time.Now,
+	}
+}
+
+func (declaredStore *DeclaredStore) Declare(suppressions []*types.FindingSuppression) {
+	declaredStore.mutex.Lock()
+	defer declaredStore.mutex.Unlock()
+	declaredStore.declared = suppressions
+}
+
+// Declared suppressions without expiration date never expire, and replace the stored ones of the same fingerprint
+func (declaredStore *DeclaredStore) List() ([]*types.FindingSuppression, error) {
+	suppressions, err := declaredStore.store.List()
+	if err != nil {
+		return nil, err
+	}
+	declaredStore.mutex.RLock()
+	declared := declaredStore.declared
+	declaredStore.mutex.RUnlock()
+	if len(declared) == 0 {
+		return suppressions, nil
+	}
+	now := declaredStore.now()
+	suppressionsByFingerprint := make(map[string]*types.FindingSuppression)
+	for _, suppression := range suppressions {
+		suppressionsByFingerprint[suppression.Fingerprint] = suppression
+	}
+	for _, suppression := range declared {
+		if suppression.ExpiresAt.IsZero() || suppression.ExpiresAt.After(now) {
+			suppressionsByFingerprint[suppression.Fingerprint] = suppression
+		}
+	}
+	merged := make([]*types.FindingSuppression, 0)
+	for _, suppression := range suppressionsByFingerprint {
+		merged = append(merged, suppression)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Fingerprint < merged[j].Fingerprint
+	})
+	return merged, nil
+}
+
+func (declaredStore *DeclaredStore) Save(suppression *types.FindingSuppression) error {
+	return declaredStore.store.Save(suppression)
+}
+
+func (declaredStore *DeclaredStore) Delete(fingerprint string) (bool, error) {
+	return declaredStore.store.Delete(fingerprint)
+}
@@ -0,0 +1,47 @@
+package suppression
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+func TestDeclaredStore(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	stored := &types.FindingSuppression{Fingerprint: "a", Reason: "accepted", CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour)}
+	declaredForever := &types.FindingSuppression{Fingerprint: "a", Reason: "declared by the shop team"}
+	declaredExpired := &types.FindingSuppression{Fingerprint: "b", Reason: "temporary",
+		ExpiresAt: now.Add(-time.Hour)}
+	memoryStore := NewMemoryStore()
+	memoryStore.(*storeImpl).now = func() time.Time { return now }
+	err := memoryStore.Save(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaredStore := NewDeclaredStore(memoryStore)
+	declaredStore.now = func() time.Time { return now }
+	declaredStore.Declare([]*types.FindingSuppression{declaredForever, declaredExpired})
+	suppressions, err := declaredStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*types.FindingSuppression{declaredForever}, suppressions); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	deleted, err := declaredStore.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(true, deleted); diff != "" {
+		t.Errorf("Delete() result mismatch (-want +got):\n%s", diff)
+	}
+	suppressions, err = declaredStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*types.FindingSuppression{declaredForever}, suppressions); diff != "" {
+		t.Errorf("List() after Delete() result mismatch (-want +got):\n%s", diff)
+	}
+}
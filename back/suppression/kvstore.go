@@ -0,0 +1,68 @@
+package suppression
+
+import (
+	"encoding/json"
+	"karto/store"
+	"karto/types"
+	"time"
+)
+
+const suppressionsCollection = "suppressions"
+
+type kvStore struct {
+	store store.Store
+	now   func() time.Time
+}
+
+// Suppressions are read from the shared store on each call, so that several Karto instances see the same ones
+func NewStore(sharedStore store.Store) Store {
+	return &kvStore{
+		store: sharedStore,
+		now:   time.Now,
+	}
+}
+
+func (kvStore *kvStore) List() ([]*types.FindingSuppression, error) {
+	fingerprints, err := kvStore.store.Keys(suppressionsCollection)
+	if err != nil {
+		return nil, err
+	}
+	now := kvStore.now()
+	suppressions := make([]*types.FindingSuppression, 0)
+	for _, fingerprint := range fingerprints {
+		content, ok, err := kvStore.store.Get(suppressionsCollection, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// Deleted by another instance in the meantime
+			continue
+		}
+		suppression := &types.FindingSuppression{}
+		err = json.Unmarshal(content, suppression)
+		if err != nil {
+			return nil, err
+		}
+		if !suppression.ExpiresAt.After(now) {
+			_, err = kvStore.store.Delete(suppressionsCollection, fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions, nil
+}
+
+func (kvStore *kvStore) Save(suppression *types.FindingSuppression) error {
+	content, err := json.Marshal(suppression)
+	if err != nil {
+		return err
+	}
+	return kvStore.store.Put(suppressionsCollection, suppression.Fingerprint, content)
+}
+
+func (kvStore *kvStore) Delete(fingerprint string) (bool, error) {
+	return kvStore.store.Delete(suppressionsCollection, fingerprint)
+}
@@ -0,0 +1,134 @@
+package suppression
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"karto/types"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	List() ([]*types.FindingSuppression, error)
+	Save(suppression *types.FindingSuppression) error
+	Delete(fingerprint string) (bool, error)
+}
+
+type storeImpl struct {
+	mutex        sync.Mutex
+	path         string
+	suppressions map[string]*types.FindingSuppression
+	now          func() time.Time
+}
+
+func NewMemoryStore() Store {
+	return &storeImpl{
+		suppressions: make(map[string]*types.FindingSuppression),
+		now:          time.Now,
+	}
+}
+
+func NewFileStore(path string) (Store, error) {
+	store := &storeImpl{
+		path:         path,
+		suppressions: make(map[string]*types.FindingSuppression),
+		now:          time.Now,
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	suppressions := make([]*types.FindingSuppression, 0)
+	err = json.Unmarshal(content, &suppressions)
+	if err != nil {
+		return nil, err
+	}
+	for _, suppression := range suppressions {
+		store.suppressions[suppression.Fingerprint] = suppression
+	}
+	return store, nil
+}
+
+func (store *storeImpl) List() ([]*types.FindingSuppression, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := store.now()
+	expired := false
+	for fingerprint, suppression := range store.suppressions {
+		if !suppression.ExpiresAt.After(now) {
+			delete(store.suppressions, fingerprint)
+			expired = true
+		}
+	}
+	if expired {
+		err := store.persist()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return store.sortedSuppressions(), nil
+}
+
+func (store *storeImpl) Save(suppression *types.FindingSuppression) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.suppressions[suppression.Fingerprint] = suppression
+	return store.persist()
+}
+
+func (store *storeImpl) Delete(fingerprint string) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.suppressions[fingerprint]; !ok {
+		return false, nil
+	}
+	delete(store.suppressions, fingerprint)
+	return true, store.persist()
+}
+
+func (store *storeImpl) sortedSuppressions() []*types.FindingSuppression {
+	suppressions := make([]*types.FindingSuppression, 0)
+	for _, suppression := range store.suppressions {
+		suppressions = append(suppressions, suppression)
+	}
+	sort.Slice(suppressions, func(i, j int) bool {
+		return suppressions[i].Fingerprint < suppressions[j].Fingerprint
+	})
+	return suppressions
+}
+
+func (store *storeImpl) persist() error {
+	if store.path == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(store.sortedSuppressions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// Writing to a temporary file first prevents a crash from leaving a truncated file behind
+	temporaryPath := store.path + ".tmp"
+	err = ioutil.WriteFile(temporaryPath, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, store.path)
+}
+
+func Apply(findings []*types.Finding, suppressions []*types.FindingSuppression) []*types.Finding {
+	suppressionsByFingerprint := make(map[string]*types.FindingSuppression)
+	for _, suppression := range suppressions {
+		suppressionsByFingerprint[suppression.Fingerprint] = suppression
+	}
+	suppressedFindings := make([]*types.Finding, 0)
+	for _, finding := range findings {
+		suppressedFinding := *finding
+		suppressedFinding.Suppression = suppressionsByFingerprint[finding.Fingerprint]
+		suppressedFindings = append(suppressedFindings, &suppressedFinding)
+	}
+	return suppressedFindings
+}
@@ -0,0 +1,116 @@
+package suppression
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"karto/store"
+	"karto/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	active := &types.FindingSuppression{Fingerprint: "a", Reason: "accepted", CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour)}
+	expired := &types.FindingSuppression{Fingerprint: "b", Reason: "temporary", CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour)}
+	directory, err := ioutil.TempDir("", "suppressions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(directory)
+	}()
+	path := filepath.Join(directory, "suppressions.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.(*storeImpl).now = func() time.Time { return now }
+	for _, suppression := range []*types.FindingSuppression{expired, active} {
+		err = store.Save(suppression)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	suppressions, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*types.FindingSuppression{active}, suppressions); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	reloadedStore, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloadedStore.(*storeImpl).now = func() time.Time { return now }
+	suppressions, _ = reloadedStore.List()
+	if diff := cmp.Diff([]*types.FindingSuppression{active}, suppressions); diff != "" {
+		t.Errorf("List() after reload result mismatch (-want +got):\n%s", diff)
+	}
+	deleted, _ := reloadedStore.Delete("a")
+	if diff := cmp.Diff(true, deleted); diff != "" {
+		t.Errorf("Delete() result mismatch (-want +got):\n%s", diff)
+	}
+	deleted, _ = reloadedStore.Delete("a")
+	if diff := cmp.Diff(false, deleted); diff != "" {
+		t.Errorf("Delete() of a missing suppression result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApply(t *testing.T) {
+	suppression := &types.FindingSuppression{Fingerprint: "a", Reason: "accepted"}
+	findings := []*types.Finding{
+		{Fingerprint: "a", Rule: "rule1"},
+		{Fingerprint: "b", Rule: "rule2"},
+	}
+	expectedFindings := []*types.Finding{
+		{Fingerprint: "a", Rule: "rule1", Suppression: suppression},
+		{Fingerprint: "b", Rule: "rule2"},
+	}
+	suppressedFindings := Apply(findings, []*types.FindingSuppression{suppression})
+	if diff := cmp.Diff(expectedFindings, suppressedFindings); diff != "" {
+		t.Errorf("Apply() result mismatch (-want +got):\n%s", diff)
+	}
+	if findings[0].Suppression != nil {
+		t.Errorf("Apply() modified the original findings")
+	}
+}
+
+func TestKVStore(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	active := &types.FindingSuppression{Fingerprint: "a", Reason: "accepted", CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour)}
+	expired := &types.FindingSuppression{Fingerprint: "b", Reason: "temporary", CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour)}
+	sharedStore := store.NewMemoryStore()
+	suppressionStore := NewStore(sharedStore)
+	suppressionStore.(*kvStore).now = func() time.Time { return now }
+	for _, suppression := range []*types.FindingSuppression{expired, active} {
+		err := suppressionStore.Save(suppression)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	otherInstanceStore := NewStore(sharedStore)
+	otherInstanceStore.(*kvStore).now = func() time.Time { return now }
+	suppressions, err := otherInstanceStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*types.FindingSuppression{active}, suppressions); diff != "" {
+		t.Errorf("List() result mismatch (-want +got):\n%s", diff)
+	}
+	deleted, _ := otherInstanceStore.Delete("a")
+	if diff := cmp.Diff(true, deleted); diff != "" {
+		t.Errorf("Delete() result mismatch (-want +got):\n%s", diff)
+	}
+	suppressions, _ = suppressionStore.List()
+	if diff := cmp.Diff([]*types.FindingSuppression{}, suppressions); diff != "" {
+		t.Errorf("List() after deletion result mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type NamespaceBuilder struct {
@@ -40,18 +41,87 @@ func (namespaceBuilder *NamespaceBuilder) Build() *corev1.Namespace {
 	}
 }
 
+type NodeBuilder struct {
+	name      string
+	labels    map[string]string
+	taints    []corev1.Taint
+	addresses []corev1.NodeAddress
+	podCIDRs  []string
+}
+
+func NewNodeBuilder() *NodeBuilder {
+	return &NodeBuilder{
+		labels: map[string]string{},
+	}
+}
+
+func (nodeBuilder *NodeBuilder) WithName(name string) *NodeBuilder {
+	nodeBuilder.name = name
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) WithLabel(key string, value string) *NodeBuilder {
+	nodeBuilder.labels[key] = value
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) WithTaint(key string, effect corev1.TaintEffect) *NodeBuilder {
+	nodeBuilder.taints = append(nodeBuilder.taints, corev1.Taint{Key: key, Effect: effect})
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) WithInternalIP(ip string) *NodeBuilder {
+	nodeBuilder.addresses = append(nodeBuilder.addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) WithExternalIP(ip string) *NodeBuilder {
+	nodeBuilder.addresses = append(nodeBuilder.addresses, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: ip})
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) WithPodCIDR(cidr string) *NodeBuilder {
+	nodeBuilder.podCIDRs = append(nodeBuilder.podCIDRs, cidr)
+	return nodeBuilder
+}
+
+func (nodeBuilder *NodeBuilder) Build() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   nodeBuilder.name,
+			Labels: nodeBuilder.labels,
+		},
+		Spec: corev1.NodeSpec{
+			Taints:   nodeBuilder.taints,
+			PodCIDRs: nodeBuilder.podCIDRs,
+		},
+		Status: corev1.NodeStatus{
+			Addresses: nodeBuilder.addresses,
+		},
+	}
+}
+
 type PodBuilder struct {
 	name              string
 	namespace         string
+	nodeName          string
+	ip                string
 	ownerUID          string
 	labels            map[string]string
+	annotations       map[string]string
+	containerPorts    []corev1.ContainerPort
 	containerStatuses []corev1.ContainerStatus
+	command           []string
+	hostPID           bool
+	hostIPC           bool
+	privileged        bool
 }
 
 func NewPodBuilder() *PodBuilder {
 	return &PodBuilder{
 		namespace:         "default",
 		labels:            map[string]string{},
+		containerPorts:    make([]corev1.ContainerPort, 0),
 		containerStatuses: make([]corev1.ContainerStatus, 0),
 	}
 }
@@ -71,6 +141,43 @@ func (podBuilder *PodBuilder) WithLabel(key string, value string) *PodBuilder {
 	return podBuilder
 }
 
+func (podBuilder *PodBuilder) WithAnnotation(key string, value string) *PodBuilder {
+	if podBuilder.annotations == nil {
+		podBuilder.annotations = map[string]string{}
+	}
+	podBuilder.annotations[key] = value
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithNodeName(nodeName string) *PodBuilder {
+	podBuilder.nodeName = nodeName
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithIP(ip string) *PodBuilder {
+	podBuilder.ip = ip
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithContainerPort(name string, port int32) *PodBuilder {
+	containerPort := corev1.ContainerPort{
+		Name:          name,
+		ContainerPort: port,
+		Protocol:      corev1.ProtocolTCP,
+	}
+	podBuilder.containerPorts = append(podBuilder.containerPorts, containerPort)
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithHostPort(containerPort int32, hostPort int32) *PodBuilder {
+	podBuilder.containerPorts = append(podBuilder.containerPorts, corev1.ContainerPort{
+		ContainerPort: containerPort,
+		HostPort:      hostPort,
+		Protocol:      corev1.ProtocolTCP,
+	})
+	return podBuilder
+}
+
 func (podBuilder *PodBuilder) WithOwnerUID(ownerUID string) *PodBuilder {
 	podBuilder.ownerUID = ownerUID
 	return podBuilder
@@ -91,26 +198,67 @@ func (podBuilder *PodBuilder) WithContainerStatus(isRunning bool, isReady bool,
 	return podBuilder
 }
 
+func (podBuilder *PodBuilder) WithHostPID() *PodBuilder {
+	podBuilder.hostPID = true
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithHostIPC() *PodBuilder {
+	podBuilder.hostIPC = true
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithPrivilegedContainer() *PodBuilder {
+	podBuilder.privileged = true
+	return podBuilder
+}
+
+func (podBuilder *PodBuilder) WithCommand(command ...string) *PodBuilder {
+	podBuilder.command = command
+	return podBuilder
+}
+
 func (podBuilder *PodBuilder) Build() *corev1.Pod {
 	return &corev1.Pod{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      podBuilder.name,
-			Namespace: podBuilder.namespace,
-			Labels:    podBuilder.labels,
+			Name:        podBuilder.name,
+			Namespace:   podBuilder.namespace,
+			Labels:      podBuilder.labels,
+			Annotations: podBuilder.annotations,
 			OwnerReferences: []v1.OwnerReference{
 				{UID: types.UID(podBuilder.ownerUID)},
 			},
 		},
+		Spec: corev1.PodSpec{
+			NodeName:   podBuilder.nodeName,
+			Containers: podBuilder.containers(),
+			HostPID:    podBuilder.hostPID,
+			HostIPC:    podBuilder.hostIPC,
+		},
 		Status: corev1.PodStatus{
+			PodIP:             podBuilder.ip,
 			ContainerStatuses: podBuilder.containerStatuses,
 		},
 	}
 }
 
+func (podBuilder *PodBuilder) containers() []corev1.Container {
+	if len(podBuilder.containerPorts) == 0 && len(podBuilder.command) == 0 && !podBuilder.privileged {
+		return nil
+	}
+	container := corev1.Container{Ports: podBuilder.containerPorts, Command: podBuilder.command}
+	if podBuilder.privileged {
+		privileged := true
+		container.SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+	}
+	return []corev1.Container{container}
+}
+
 type NetworkPolicyBuilder struct {
 	name        string
 	namespace   string
 	labels      map[string]string
+	annotations map[string]string
 	podSelector metav1.LabelSelector
 	types       []networkingv1.PolicyType
 	ingress     []networkingv1.NetworkPolicyIngressRule
@@ -140,6 +288,14 @@ func (networkPolicyBuilder *NetworkPolicyBuilder) WithLabel(key string, value st
 	return networkPolicyBuilder
 }
 
+func (networkPolicyBuilder *NetworkPolicyBuilder) WithAnnotation(key string, value string) *NetworkPolicyBuilder {
+	if networkPolicyBuilder.annotations == nil {
+		networkPolicyBuilder.annotations = map[string]string{}
+	}
+	networkPolicyBuilder.annotations[key] = value
+	return networkPolicyBuilder
+}
+
 func (networkPolicyBuilder *NetworkPolicyBuilder) WithPodSelector(
 	podSelector *metav1.LabelSelector) *NetworkPolicyBuilder {
 	networkPolicyBuilder.podSelector = *podSelector
@@ -166,9 +322,10 @@ func (networkPolicyBuilder *NetworkPolicyBuilder) WithEgressRule(
 func (networkPolicyBuilder *NetworkPolicyBuilder) Build() *networkingv1.NetworkPolicy {
 	return &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      networkPolicyBuilder.name,
-			Namespace: networkPolicyBuilder.namespace,
-			Labels:    networkPolicyBuilder.labels,
+			Name:        networkPolicyBuilder.name,
+			Namespace:   networkPolicyBuilder.namespace,
+			Labels:      networkPolicyBuilder.labels,
+			Annotations: networkPolicyBuilder.annotations,
 		},
 		Spec: networkingv1.NetworkPolicySpec{
 			PodSelector: networkPolicyBuilder.podSelector,
@@ -201,15 +358,23 @@ func (labelSelectorBuilder *LabelSelectorBuilder) Build() *metav1.LabelSelector
 }
 
 type ServiceBuilder struct {
-	name      string
-	namespace string
-	selector  map[string]string
+	name                  string
+	namespace             string
+	annotations           map[string]string
+	selector              map[string]string
+	serviceType           corev1.ServiceType
+	internalTrafficPolicy *corev1.ServiceInternalTrafficPolicyType
+	externalTrafficPolicy corev1.ServiceExternalTrafficPolicyType
+	ports                 []corev1.ServicePort
+	loadBalancerIPs       []string
+	sourceRanges          []string
 }
 
 func NewServiceBuilder() *ServiceBuilder {
 	return &ServiceBuilder{
 		namespace: "default",
 		selector:  map[string]string{},
+		ports:     make([]corev1.ServicePort, 0),
 	}
 }
 
@@ -223,19 +388,96 @@ func (serviceBuilder *ServiceBuilder) WithNamespace(namespace string) *ServiceBu
 	return serviceBuilder
 }
 
+func (serviceBuilder *ServiceBuilder) WithAnnotation(key string, value string) *ServiceBuilder {
+	if serviceBuilder.annotations == nil {
+		serviceBuilder.annotations = map[string]string{}
+	}
+	serviceBuilder.annotations[key] = value
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithInternalTrafficPolicy(
+	policy corev1.ServiceInternalTrafficPolicyType) *ServiceBuilder {
+	serviceBuilder.internalTrafficPolicy = &policy
+	return serviceBuilder
+}
+
 func (serviceBuilder *ServiceBuilder) WithSelectorLabel(key string, value string) *ServiceBuilder {
 	serviceBuilder.selector[key] = value
 	return serviceBuilder
 }
 
+func (serviceBuilder *ServiceBuilder) WithType(serviceType corev1.ServiceType) *ServiceBuilder {
+	serviceBuilder.serviceType = serviceType
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithPort(port int32, targetPort int32) *ServiceBuilder {
+	serviceBuilder.ports = append(serviceBuilder.ports, corev1.ServicePort{
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: intstr.FromInt(int(targetPort)),
+	})
+	return serviceBuilder
+}
+
+// WithNamedTargetPort targets the container port of the given name, as declared with PodBuilder.WithContainerPort
+func (serviceBuilder *ServiceBuilder) WithNamedTargetPort(port int32, targetPortName string) *ServiceBuilder {
+	serviceBuilder.ports = append(serviceBuilder.ports, corev1.ServicePort{
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: intstr.FromString(targetPortName),
+	})
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithNodePort(port int32, nodePort int32) *ServiceBuilder {
+	serviceBuilder.ports = append(serviceBuilder.ports, corev1.ServicePort{
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: intstr.FromInt(int(port)),
+		NodePort:   nodePort,
+	})
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithLoadBalancerIP(ip string) *ServiceBuilder {
+	serviceBuilder.loadBalancerIPs = append(serviceBuilder.loadBalancerIPs, ip)
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithExternalTrafficPolicy(
+	policy corev1.ServiceExternalTrafficPolicyType) *ServiceBuilder {
+	serviceBuilder.externalTrafficPolicy = policy
+	return serviceBuilder
+}
+
+func (serviceBuilder *ServiceBuilder) WithLoadBalancerSourceRange(cidr string) *ServiceBuilder {
+	serviceBuilder.sourceRanges = append(serviceBuilder.sourceRanges, cidr)
+	return serviceBuilder
+}
+
 func (serviceBuilder *ServiceBuilder) Build() *corev1.Service {
+	loadBalancerIngresses := make([]corev1.LoadBalancerIngress, 0, len(serviceBuilder.loadBalancerIPs))
+	for _, ip := range serviceBuilder.loadBalancerIPs {
+		loadBalancerIngresses = append(loadBalancerIngresses, corev1.LoadBalancerIngress{IP: ip})
+	}
 	return &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      serviceBuilder.name,
-			Namespace: serviceBuilder.namespace,
+			Name:        serviceBuilder.name,
+			Namespace:   serviceBuilder.namespace,
+			Annotations: serviceBuilder.annotations,
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: serviceBuilder.selector,
+			Selector:                 serviceBuilder.selector,
+			Type:                     serviceBuilder.serviceType,
+			Ports:                    serviceBuilder.ports,
+			InternalTrafficPolicy:    serviceBuilder.internalTrafficPolicy,
+			ExternalTrafficPolicy:    serviceBuilder.externalTrafficPolicy,
+			LoadBalancerSourceRanges: serviceBuilder.sourceRanges,
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: loadBalancerIngresses},
 		},
 	}
 }
@@ -244,14 +486,16 @@ type ReplicaSetBuilder struct {
 	name            string
 	namespace       string
 	uid             string
-	ownerUid        string
+	ownerReference  v1.OwnerReference
 	desiredReplicas int32
+	podLabels       map[string]string
 }
 
 func NewReplicaSetBuilder() *ReplicaSetBuilder {
 	return &ReplicaSetBuilder{
 		namespace:       "default",
 		desiredReplicas: 1,
+		podLabels:       map[string]string{},
 	}
 }
 
@@ -276,22 +520,43 @@ func (replicaSetBuilder *ReplicaSetBuilder) WithDesiredReplicas(replicas int32)
 }
 
 func (replicaSetBuilder *ReplicaSetBuilder) WithOwnerUID(ownerUID string) *ReplicaSetBuilder {
-	replicaSetBuilder.ownerUid = ownerUID
+	replicaSetBuilder.ownerReference.UID = types.UID(ownerUID)
+	return replicaSetBuilder
+}
+
+// WithOwnerDeployment sets the controller reference a deployment gives to its replica sets
+func (replicaSetBuilder *ReplicaSetBuilder) WithOwnerDeployment(deployment *appsv1.Deployment) *ReplicaSetBuilder {
+	controller := true
+	replicaSetBuilder.ownerReference = v1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		UID:        deployment.UID,
+		Controller: &controller,
+	}
+	return replicaSetBuilder
+}
+
+// WithPodLabel labels the pods of the replica set, which are selected by this label
+func (replicaSetBuilder *ReplicaSetBuilder) WithPodLabel(key string, value string) *ReplicaSetBuilder {
+	replicaSetBuilder.podLabels[key] = value
 	return replicaSetBuilder
 }
 
 func (replicaSetBuilder *ReplicaSetBuilder) Build() *appsv1.ReplicaSet {
 	return &appsv1.ReplicaSet{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      replicaSetBuilder.name,
-			Namespace: replicaSetBuilder.namespace,
-			UID:       types.UID(replicaSetBuilder.uid),
-			OwnerReferences: []v1.OwnerReference{
-				{UID: types.UID(replicaSetBuilder.ownerUid)},
-			},
+			Name:            replicaSetBuilder.name,
+			Namespace:       replicaSetBuilder.namespace,
+			UID:             types.UID(replicaSetBuilder.uid),
+			OwnerReferences: []v1.OwnerReference{replicaSetBuilder.ownerReference},
 		},
 		Spec: appsv1.ReplicaSetSpec{
 			Replicas: &replicaSetBuilder.desiredReplicas,
+			Selector: &v1.LabelSelector{MatchLabels: replicaSetBuilder.podLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{Labels: replicaSetBuilder.podLabels},
+			},
 		},
 	}
 }
@@ -344,14 +609,16 @@ func (statefulSetBuilder *StatefulSetBuilder) Build() *appsv1.StatefulSet {
 }
 
 type DaemonSetBuilder struct {
-	name      string
-	namespace string
-	uid       string
+	name       string
+	namespace  string
+	uid        string
+	containers []corev1.Container
 }
 
 func NewDaemonSetBuilder() *DaemonSetBuilder {
 	return &DaemonSetBuilder{
-		namespace: "default",
+		namespace:  "default",
+		containers: make([]corev1.Container, 0),
 	}
 }
 
@@ -370,6 +637,11 @@ func (daemonSetBuilder *DaemonSetBuilder) WithUID(UID string) *DaemonSetBuilder
 	return daemonSetBuilder
 }
 
+func (daemonSetBuilder *DaemonSetBuilder) WithContainer(containerName string) *DaemonSetBuilder {
+	daemonSetBuilder.containers = append(daemonSetBuilder.containers, corev1.Container{Name: containerName})
+	return daemonSetBuilder
+}
+
 func (daemonSetBuilder *DaemonSetBuilder) Build() *appsv1.DaemonSet {
 	return &appsv1.DaemonSet{
 		ObjectMeta: v1.ObjectMeta{
@@ -377,19 +649,28 @@ func (daemonSetBuilder *DaemonSetBuilder) Build() *appsv1.DaemonSet {
 			Namespace: daemonSetBuilder.namespace,
 			UID:       types.UID(daemonSetBuilder.uid),
 		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: daemonSetBuilder.containers,
+				},
+			},
+		},
 	}
 }
 
 type IngressBuilder struct {
 	name            string
 	namespace       string
-	serviceBackends []string
+	ingressClass    *string
+	host            string
+	serviceBackends []networkingv1beta1.IngressBackend
 }
 
 func NewIngressBuilder() *IngressBuilder {
 	return &IngressBuilder{
 		namespace:       "default",
-		serviceBackends: make([]string, 0),
+		serviceBackends: make([]networkingv1beta1.IngressBackend, 0),
 	}
 }
 
@@ -403,8 +684,25 @@ func (ingressBuilder *IngressBuilder) WithNamespace(namespace string) *IngressBu
 	return ingressBuilder
 }
 
+func (ingressBuilder *IngressBuilder) WithIngressClass(ingressClass string) *IngressBuilder {
+	ingressBuilder.ingressClass = &ingressClass
+	return ingressBuilder
+}
+
+func (ingressBuilder *IngressBuilder) WithHost(host string) *IngressBuilder {
+	ingressBuilder.host = host
+	return ingressBuilder
+}
+
 func (ingressBuilder *IngressBuilder) WithServiceBackend(serviceName string) *IngressBuilder {
-	ingressBuilder.serviceBackends = append(ingressBuilder.serviceBackends, serviceName)
+	ingressBuilder.serviceBackends = append(ingressBuilder.serviceBackends,
+		networkingv1beta1.IngressBackend{ServiceName: serviceName})
+	return ingressBuilder
+}
+
+func (ingressBuilder *IngressBuilder) WithServicePortBackend(serviceName string, servicePort int32) *IngressBuilder {
+	ingressBuilder.serviceBackends = append(ingressBuilder.serviceBackends,
+		networkingv1beta1.IngressBackend{ServiceName: serviceName, ServicePort: intstr.FromInt(int(servicePort))})
 	return ingressBuilder
 }
 
@@ -412,9 +710,7 @@ func (ingressBuilder *IngressBuilder) Build() *networkingv1beta1.Ingress {
 	ingressPaths := make([]networkingv1beta1.HTTPIngressPath, 0)
 	for _, serviceBackend := range ingressBuilder.serviceBackends {
 		ingressPath := networkingv1beta1.HTTPIngressPath{
-			Backend: networkingv1beta1.IngressBackend{
-				ServiceName: serviceBackend,
-			},
+			Backend: serviceBackend,
 		}
 		ingressPaths = append(ingressPaths, ingressPath)
 	}
@@ -424,8 +720,10 @@ func (ingressBuilder *IngressBuilder) Build() *networkingv1beta1.Ingress {
 			Namespace: ingressBuilder.namespace,
 		},
 		Spec: networkingv1beta1.IngressSpec{
+			IngressClassName: ingressBuilder.ingressClass,
 			Rules: []networkingv1beta1.IngressRule{
 				{
+					Host: ingressBuilder.host,
 					IngressRuleValue: networkingv1beta1.IngressRuleValue{
 						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
 							Paths: ingressPaths,
@@ -438,14 +736,18 @@ func (ingressBuilder *IngressBuilder) Build() *networkingv1beta1.Ingress {
 }
 
 type DeploymentBuilder struct {
-	name      string
-	namespace string
-	uid       string
+	name            string
+	namespace       string
+	uid             string
+	desiredReplicas int32
+	podLabels       map[string]string
 }
 
 func NewDeploymentBuilder() *DeploymentBuilder {
 	return &DeploymentBuilder{
-		namespace: "default",
+		namespace:       "default",
+		desiredReplicas: 1,
+		podLabels:       map[string]string{},
 	}
 }
 
@@ -464,6 +766,17 @@ func (deploymentBuilder *DeploymentBuilder) WithUID(UID string) *DeploymentBuild
 	return deploymentBuilder
 }
 
+func (deploymentBuilder *DeploymentBuilder) WithDesiredReplicas(replicas int32) *DeploymentBuilder {
+	deploymentBuilder.desiredReplicas = replicas
+	return deploymentBuilder
+}
+
+// WithPodLabel labels the pods of the deployment, which are selected by this label
+func (deploymentBuilder *DeploymentBuilder) WithPodLabel(key string, value string) *DeploymentBuilder {
+	deploymentBuilder.podLabels[key] = value
+	return deploymentBuilder
+}
+
 func (deploymentBuilder *DeploymentBuilder) Build() *appsv1.Deployment {
 	return &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
@@ -471,5 +784,12 @@ func (deploymentBuilder *DeploymentBuilder) Build() *appsv1.Deployment {
 			Namespace: deploymentBuilder.namespace,
 			UID:       types.UID(deploymentBuilder.uid),
 		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &deploymentBuilder.desiredReplicas,
+			Selector: &v1.LabelSelector{MatchLabels: deploymentBuilder.podLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{Labels: deploymentBuilder.podLabels},
+			},
+		},
 	}
 }
@@ -0,0 +1,102 @@
+package trigger
+
+import (
+	"karto/cron"
+	"karto/types"
+	"log"
+	"time"
+)
+
+const (
+	// ModeWatch analyzes the cluster on every change, at most once per minimum interval
+	ModeWatch = "watch"
+	// ModeOnDemand only analyzes the cluster when a refresh is requested or on schedule
+	ModeOnDemand = "onDemand"
+)
+
+type Options struct {
+	Mode string
+	// Schedule is a cron expression on which the cluster is analyzed whatever the mode, none if empty
+	Schedule string
+	// MinInterval is the minimum duration between two analyses triggered by changes, 0 for no minimum
+	MinInterval time.Duration
+}
+
+type gate struct {
+	options Options
+	now     func() time.Time
+	after   func(duration time.Duration) <-chan time.Time
+}
+
+// Gate forwards the latest cluster state to analyze when the mode, the schedule or a refresh triggers an analysis.
+// The first cluster state is always forwarded, so that there is a result to serve after a start.
+func Gate(options Options, refreshes <-chan struct{}, clusterStateChannel <-chan types.ClusterState,
+	gatedClusterStateChannel chan<- types.ClusterState) {
+	if options.Mode != ModeWatch && options.Mode != ModeOnDemand {
+		log.Fatalf("unknown analysis mode %q, expected %s or %s\n", options.Mode, ModeWatch, ModeOnDemand)
+	}
+	var scheduled <-chan time.Time
+	if options.Schedule != "" {
+		schedule, err := cron.Parse(options.Schedule)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		ticks := make(chan time.Time)
+		go tick(schedule, ticks)
+		scheduled = ticks
+	}
+	gate := gate{options: options, now: time.Now, after: time.After}
+	gate.run(refreshes, scheduled, clusterStateChannel, gatedClusterStateChannel)
+}
+
+func tick(schedule cron.Schedule, ticks chan<- time.Time) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Println("The analysis schedule never matches, no analysis will be scheduled")
+			return
+		}
+		time.Sleep(time.Until(next))
+		ticks <- next
+	}
+}
+
+func (gate gate) run(refreshes <-chan struct{}, scheduled <-chan time.Time,
+	clusterStateChannel <-chan types.ClusterState, gatedClusterStateChannel chan<- types.ClusterState) {
+	var latest *types.ClusterState
+	var lastForwardedAt time.Time
+	// delayed is set while a change waits for the minimum interval to elapse
+	var delayed <-chan time.Time
+	forward := func() {
+		if latest == nil {
+			return
+		}
+		gatedClusterStateChannel <- *latest
+		lastForwardedAt = gate.now()
+		delayed = nil
+	}
+	for {
+		select {
+		case clusterState := <-clusterStateChannel:
+			first := latest == nil
+			latest = &clusterState
+			if first {
+				forward()
+			} else if gate.options.Mode == ModeWatch && delayed == nil {
+				wait := gate.options.MinInterval - gate.now().Sub(lastForwardedAt)
+				if wait <= 0 {
+					forward()
+				} else {
+					delayed = gate.after(wait)
+				}
+			}
+		case <-delayed:
+			forward()
+		case <-refreshes:
+			log.Println("Analysis requested")
+			forward()
+		case <-scheduled:
+			forward()
+		}
+	}
+}
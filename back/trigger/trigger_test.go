@@ -0,0 +1,96 @@
+package trigger
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+	"time"
+)
+
+type gateChannels struct {
+	refreshes    chan struct{}
+	scheduled    chan time.Time
+	delayed      chan time.Time
+	clusterState chan types.ClusterState
+	gated        chan types.ClusterState
+}
+
+func startGate(options Options) gateChannels {
+	channels := gateChannels{
+		refreshes:    make(chan struct{}),
+		scheduled:    make(chan time.Time),
+		delayed:      make(chan time.Time),
+		clusterState: make(chan types.ClusterState),
+		gated:        make(chan types.ClusterState),
+	}
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	gate := gate{
+		options: options,
+		now:     func() time.Time { return now },
+		after:   func(time.Duration) <-chan time.Time { return channels.delayed },
+	}
+	go gate.run(channels.refreshes, channels.scheduled, channels.clusterState, channels.gated)
+	return channels
+}
+
+func clusterStateOf(namespace string) types.ClusterState {
+	return types.ClusterState{
+		Namespaces: []*corev1.Namespace{testutils.NewNamespaceBuilder().WithName(namespace).Build()},
+	}
+}
+
+func expectForwarded(t *testing.T, gated <-chan types.ClusterState, namespace string) {
+	t.Helper()
+	select {
+	case clusterState := <-gated:
+		if clusterState.Namespaces[0].Name != namespace {
+			t.Errorf("Gate() forwarded the cluster state of %s, expected %s", clusterState.Namespaces[0].Name,
+				namespace)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Test timed out (the cluster state of %s was not forwarded)", namespace)
+	}
+}
+
+func expectNothingForwarded(t *testing.T, gated <-chan types.ClusterState) {
+	t.Helper()
+	select {
+	case clusterState := <-gated:
+		t.Errorf("Gate() forwarded the cluster state of %s, expected nothing", clusterState.Namespaces[0].Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestGateWatch(t *testing.T) {
+	channels := startGate(Options{Mode: ModeWatch})
+	channels.clusterState <- clusterStateOf("first")
+	expectForwarded(t, channels.gated, "first")
+	channels.clusterState <- clusterStateOf("second")
+	expectForwarded(t, channels.gated, "second")
+}
+
+func TestGateWatchMinInterval(t *testing.T) {
+	channels := startGate(Options{Mode: ModeWatch, MinInterval: time.Minute})
+	channels.clusterState <- clusterStateOf("first")
+	expectForwarded(t, channels.gated, "first")
+	channels.clusterState <- clusterStateOf("second")
+	channels.clusterState <- clusterStateOf("third")
+	expectNothingForwarded(t, channels.gated)
+	channels.delayed <- time.Time{}
+	expectForwarded(t, channels.gated, "third")
+}
+
+func TestGateOnDemand(t *testing.T) {
+	channels := startGate(Options{Mode: ModeOnDemand})
+	channels.refreshes <- struct{}{}
+	channels.clusterState <- clusterStateOf("first")
+	expectForwarded(t, channels.gated, "first")
+	channels.clusterState <- clusterStateOf("second")
+	expectNothingForwarded(t, channels.gated)
+	channels.refreshes <- struct{}{}
+	expectForwarded(t, channels.gated, "second")
+	channels.clusterState <- clusterStateOf("third")
+	channels.scheduled <- time.Time{}
+	expectForwarded(t, channels.gated, "third")
+}
@@ -1,28 +1,71 @@
 package types
 
 import (
+	"encoding/json"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"karto/config"
+	"karto/orderedpolicy"
+	"time"
 )
 
 type ClusterState struct {
-	Namespaces      []*corev1.Namespace
-	Pods            []*corev1.Pod
-	Services        []*corev1.Service
-	Ingresses       []*networkingv1beta1.Ingress
-	ReplicaSets     []*appsv1.ReplicaSet
-	StatefulSets    []*appsv1.StatefulSet
-	DaemonSets      []*appsv1.DaemonSet
-	Deployments     []*appsv1.Deployment
-	NetworkPolicies []*networkingv1.NetworkPolicy
+	Namespaces                      []*corev1.Namespace
+	Nodes                           []*corev1.Node
+	Pods                            []*corev1.Pod
+	Services                        []*corev1.Service
+	Ingresses                       []*networkingv1beta1.Ingress
+	ReplicaSets                     []*appsv1.ReplicaSet
+	StatefulSets                    []*appsv1.StatefulSet
+	DaemonSets                      []*appsv1.DaemonSet
+	Deployments                     []*appsv1.Deployment
+	NetworkPolicies                 []*networkingv1.NetworkPolicy
+	ServerVersion                   *version.Info
+	APIGroups                       []metav1.APIGroup
+	ValidatingWebhookConfigurations []*admissionregistrationv1.ValidatingWebhookConfiguration
+	MutatingWebhookConfigurations   []*admissionregistrationv1.MutatingWebhookConfiguration
+	APIServices                     []*APIService
+	// OrderedPolicies are the Calico and Cilium policies, only evaluated by the route explanations
+	OrderedPolicies []*orderedpolicy.Policy
+	// Declared as custom resources by tenants, when enabled
+	Intents           []config.Intent
+	ConnectivityRules []config.ConnectivityRule
+	// ResourceVersions holds the resourceVersion of each kind the state was listed at, when read from a cluster
+	ResourceVersions map[string]string
+}
+
+// APIService is the part of the aggregated API services of apiregistration.k8s.io needed by the analysis, their
+// client not being part of client-go
+type APIService struct {
+	Name    string
+	Service *ServiceRef
+}
+
+type Namespace struct {
+	Name                string            `json:"name"`
+	Labels              map[string]string `json:"labels"`
+	PodCount            int               `json:"podCount"`
+	IngressIsolatedPods int               `json:"ingressIsolatedPods"`
+	EgressIsolatedPods  int               `json:"egressIsolatedPods"`
+	DefaultDenyIngress  bool              `json:"defaultDenyIngress"`
+	DefaultDenyEgress   bool              `json:"defaultDenyEgress"`
 }
 
 type Pod struct {
-	Name      string            `json:"name"`
-	Namespace string            `json:"namespace"`
-	Labels    map[string]string `json:"labels"`
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace"`
+	Labels     map[string]string `json:"labels"`
+	HostPID    bool              `json:"hostPID"`
+	HostIPC    bool              `json:"hostIPC"`
+	Privileged bool              `json:"privileged"`
+	Zone       string            `json:"zone"`
+	// Enrichment holds the external metadata attached by the enrichment hooks
+	Enrichment map[string]string `json:"enrichment"`
 }
 
 type PodRef struct {
@@ -42,18 +85,131 @@ type NetworkPolicy struct {
 	Labels    map[string]string `json:"labels"`
 }
 
+// PolicyException is a network policy annotated as a temporary exception, which is stale once older than the maximum
+// age of exceptions
+type PolicyException struct {
+	Policy    NetworkPolicy `json:"policy"`
+	Owner     string        `json:"owner"`
+	Ticket    string        `json:"ticket"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Stale     bool          `json:"stale"`
+}
+
 type AllowedRoute struct {
 	SourcePod       PodRef          `json:"sourcePod"`
 	EgressPolicies  []NetworkPolicy `json:"egressPolicies"`
 	TargetPod       PodRef          `json:"targetPod"`
 	IngressPolicies []NetworkPolicy `json:"ingressPolicies"`
 	Ports           []int32         `json:"ports"`
+	// PortRanges are the ranges allowed by rules with an endPort, in addition to the ports. The ports are then empty
+	// rather than null, which stands for all ports.
+	PortRanges []PortRange `json:"portRanges"`
+	// ProtocolPorts are the ports and ranges of each protocol, of which the ports and ranges above are the union. They
+	// are null when all the ports of every protocol are allowed.
+	ProtocolPorts []ProtocolPorts `json:"protocolPorts"`
+	Warnings      []string        `json:"warnings"`
+	// SameNode tells whether both pods run on the same node, whose traffic some CNIs handle differently. It is null
+	// when the node of either pod is unknown.
+	SameNode  *bool      `json:"sameNode"`
+	Intents   []string   `json:"intents"`
+	RiskScore int        `json:"riskScore"`
+	FirstSeen *time.Time `json:"firstSeen"`
+	LastSeen  *time.Time `json:"lastSeen"`
+}
+
+// PortRange is the range of ports from Port to EndPort, both included
+type PortRange struct {
+	Port    int32 `json:"port"`
+	EndPort int32 `json:"endPort"`
+}
+
+// ProtocolPorts are the ports and ranges allowed for a protocol, both null when all its ports are
+type ProtocolPorts struct {
+	Protocol   corev1.Protocol `json:"protocol"`
+	Ports      []int32         `json:"ports"`
+	PortRanges []PortRange     `json:"portRanges"`
+}
+
+// HostPortExposure is the synthetic edge from a node to a pod exposing host ports on it. Traffic reaching the node on
+// these ports is forwarded to the pod outside of the isolation its network policies model.
+type HostPortExposure struct {
+	Node  string     `json:"node"`
+	Pod   PodRef     `json:"pod"`
+	Ports []HostPort `json:"ports"`
+}
+
+// HairpinRoute is the route from a pod to the pods of a service of the cluster, through the addresses of its nodes or
+// of its load balancer which an ipBlock of the egress policies of the pod covers
+type HairpinRoute struct {
+	SourcePod      PodRef          `json:"sourcePod"`
+	EgressPolicies []NetworkPolicy `json:"egressPolicies"`
+	Service        ServiceRef      `json:"service"`
+	TargetPod      PodRef          `json:"targetPod"`
+	// NodePorts are reached through the addresses of the nodes, LoadBalancerPorts through those of the load balancer
+	NodePorts         []int32 `json:"nodePorts"`
+	LoadBalancerPorts []int32 `json:"loadBalancerPorts"`
+}
+
+// ClusterNetwork holds the address ranges of the pods and services of the cluster, as configured or detected
+type ClusterNetwork struct {
+	PodCIDRs     []string `json:"podCIDRs"`
+	ServiceCIDRs []string `json:"serviceCIDRs"`
+}
+
+// IPBlockScope tells whether the ipBlock peer of a policy rule covers addresses of the cluster, addresses outside of
+// it, or both
+type IPBlockScope struct {
+	Policy NetworkPolicy `json:"policy"`
+	// Direction is Ingress or Egress, after the rule of the peer
+	Direction string   `json:"direction"`
+	CIDR      string   `json:"cidr"`
+	Except    []string `json:"except"`
+	// Scope is internal, external or mixed
+	Scope string `json:"scope"`
+	// ExceptedPods are the pods having an address in the cidr, denied by an except entry
+	ExceptedPods []*ExceptedPod `json:"exceptedPods"`
+}
+
+// ExceptedPod is a pod left out of an ipBlock by the except entry containing its address
+type ExceptedPod struct {
+	Pod    PodRef `json:"pod"`
+	Except string `json:"except"`
+}
+
+// InternetAccess is the edge between a pod and the addresses outside of the cluster, as allowed by its policies
+type InternetAccess struct {
+	Pod     PodRef `json:"pod"`
+	Egress  bool   `json:"egress"`
+	Ingress bool   `json:"ingress"`
+	// LoadBalancers are the load balancer services through which clients outside of the cluster reach the pod
+	LoadBalancers []*LoadBalancerAccess `json:"loadBalancers"`
+}
+
+// LoadBalancerAccess tells which client ranges get in through a load balancer service, after its
+// loadBalancerSourceRanges and the ingress policies of the pod
+type LoadBalancerAccess struct {
+	Service ServiceRef `json:"service"`
+	// ExternalTrafficPolicy is Cluster or Local, only the latter preserving the address of the clients
+	ExternalTrafficPolicy string   `json:"externalTrafficPolicy"`
+	ClientCIDRs           []string `json:"clientCIDRs"`
+}
+
+type HostPort struct {
+	HostPort      int32  `json:"hostPort"`
+	ContainerPort int32  `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+	// HostIP is empty when the port is bound on all the addresses of the node
+	HostIP string `json:"hostIP"`
 }
 
 type Service struct {
 	Name       string   `json:"name"`
 	Namespace  string   `json:"namespace"`
 	TargetPods []PodRef `json:"targetPods"`
+	// Routing tells which target pods a pod reaches through the service: all of them (cluster), those of its node
+	// (node), after an internalTrafficPolicy of Local, or those of its zone (zone), after topology-aware routing
+	Routing    string            `json:"routing"`
+	Enrichment map[string]string `json:"enrichment"`
 }
 
 type ServiceRef struct {
@@ -64,6 +220,7 @@ type ServiceRef struct {
 type Ingress struct {
 	Name           string       `json:"name"`
 	Namespace      string       `json:"namespace"`
+	IngressClass   string       `json:"ingressClass"`
 	TargetServices []ServiceRef `json:"targetServices"`
 }
 
@@ -97,16 +254,107 @@ type Deployment struct {
 }
 
 type AnalysisResult struct {
-	Pods          []*Pod          `json:"pods"`
-	PodIsolations []*PodIsolation `json:"podIsolations"`
-	AllowedRoutes []*AllowedRoute `json:"allowedRoutes"`
-	Services      []*Service      `json:"services"`
-	Ingresses     []*Ingress      `json:"ingresses"`
-	ReplicaSets   []*ReplicaSet   `json:"replicaSets"`
-	StatefulSets  []*StatefulSet  `json:"statefulSets"`
-	DaemonSets    []*DaemonSet    `json:"daemonSets"`
-	Deployments   []*Deployment   `json:"deployments"`
-	PodHealths    []*PodHealth    `json:"podHealths"`
+	Namespaces            []*Namespace            `json:"namespaces"`
+	Pods                  []*Pod                  `json:"pods"`
+	PodIsolations         []*PodIsolation         `json:"podIsolations"`
+	AllowedRoutes         []*AllowedRoute         `json:"allowedRoutes"`
+	NetworkPolicies       []*NetworkPolicy        `json:"networkPolicies"`
+	PolicyExceptions      []*PolicyException      `json:"policyExceptions"`
+	Services              []*Service              `json:"services"`
+	Ingresses             []*Ingress              `json:"ingresses"`
+	ReplicaSets           []*ReplicaSet           `json:"replicaSets"`
+	StatefulSets          []*StatefulSet          `json:"statefulSets"`
+	DaemonSets            []*DaemonSet            `json:"daemonSets"`
+	Deployments           []*Deployment           `json:"deployments"`
+	PodHealths            []*PodHealth            `json:"podHealths"`
+	SystemComponents      []*SystemComponent      `json:"systemComponents"`
+	HostPortExposures     []*HostPortExposure     `json:"hostPortExposures"`
+	HairpinRoutes         []*HairpinRoute         `json:"hairpinRoutes"`
+	Network               *ClusterNetwork         `json:"network"`
+	IPBlocks              []*IPBlockScope         `json:"ipBlocks"`
+	InternetAccesses      []*InternetAccess       `json:"internetAccesses"`
+	Capabilities          ClusterCapabilities     `json:"capabilities"`
+	RouteVerifications    []*RouteVerification    `json:"routeVerifications"`
+	Findings              []*Finding              `json:"findings"`
+	Compliance            []*ComplianceProfile    `json:"compliance"`
+	TighteningSuggestions []*TighteningSuggestion `json:"tighteningSuggestions"`
+	Drift                 *DriftReport            `json:"drift"`
+	// Extensions holds the sections contributed by the registered extensions, by name
+	Extensions map[string]json.RawMessage `json:"extensions"`
+	Stats      *AnalysisStats             `json:"stats"`
+	// DataFreshness tells, per kind, whether its watch was synced when the result was served, so that it can be
+	// flagged as possibly stale
+	DataFreshness map[string]*ResourceFreshness `json:"dataFreshness,omitempty"`
+	// Complete is false while the first analysis is in progress, Progress being the percentage of the namespaces
+	// whose routes are already part of the result
+	Complete bool `json:"complete"`
+	Progress int  `json:"progress"`
+}
+
+type ResourceFreshness struct {
+	Synced    bool      `json:"synced"`
+	LastEvent time.Time `json:"lastEvent"`
+}
+
+// AnalysisStats tells how long each stage of an analysis took, and how many objects it analyzed
+type AnalysisStats struct {
+	StartedAt  time.Time     `json:"startedAt"`
+	DurationMs float64       `json:"durationMs"`
+	Stages     []*StageStats `json:"stages"`
+	Counts     ObjectCounts  `json:"counts"`
+	// ResourceVersions are those of the analyzed cluster state, to reproduce the analysis from the same objects
+	ResourceVersions map[string]string `json:"resourceVersions,omitempty"`
+}
+
+// NamespaceRoutes are the allowed routes towards the pods of a namespace, published with these pods by the analysis
+// started at AnalysisStartedAt as soon as they are computed, before being scored or matched against intents
+type NamespaceRoutes struct {
+	Namespace         string          `json:"namespace"`
+	AnalysisStartedAt time.Time       `json:"analysisStartedAt"`
+	Pods              []*Pod          `json:"pods"`
+	PodIsolations     []*PodIsolation `json:"podIsolations"`
+	AllowedRoutes     []*AllowedRoute `json:"allowedRoutes"`
+	// Progress is the percentage of the namespaces whose routes were computed by the analysis so far
+	Progress int `json:"progress"`
+}
+
+type StageStats struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// ResultSummary holds the counts of the last analysis result, for dashboards and status lines which do not need the
+// full result
+type ResultSummary struct {
+	Pods                         int `json:"pods"`
+	IngressIsolatedPods          int `json:"ingressIsolatedPods"`
+	EgressIsolatedPods           int `json:"egressIsolatedPods"`
+	Namespaces                   int `json:"namespaces"`
+	DefaultDenyIngressNamespaces int `json:"defaultDenyIngressNamespaces"`
+	DefaultDenyEgressNamespaces  int `json:"defaultDenyEgressNamespaces"`
+	AllowedRoutes                int `json:"allowedRoutes"`
+	CrossNamespaceRoutes         int `json:"crossNamespaceRoutes"`
+	// FindingsBySeverity only counts the findings which are not suppressed
+	FindingsBySeverity map[string]int `json:"findingsBySeverity"`
+	SuppressedFindings int            `json:"suppressedFindings"`
+	// AnalyzedAt and ResourceVersions identify the snapshot of the cluster the result was computed from
+	AnalyzedAt       time.Time         `json:"analyzedAt"`
+	ResourceVersions map[string]string `json:"resourceVersions,omitempty"`
+}
+
+type ObjectCounts struct {
+	Namespaces      int `json:"namespaces"`
+	Pods            int `json:"pods"`
+	NetworkPolicies int `json:"networkPolicies"`
+	Services        int `json:"services"`
+	Ingresses       int `json:"ingresses"`
+	Workloads       int `json:"workloads"`
+	AllowedRoutes   int `json:"allowedRoutes"`
+	Findings        int `json:"findings"`
+	// ExcludedPairs counts the pairs of pods whose routes were not computed because of the configured exclusions
+	ExcludedPairs int `json:"excludedPairs"`
+	// MergedRoutes counts the routes merged into another between the same pods on the same ports
+	MergedRoutes int `json:"mergedRoutes"`
 }
 
 type PodHealth struct {
@@ -116,3 +364,132 @@ type PodHealth struct {
 	ContainersReady          int32  `json:"containersReady"`
 	ContainersWithoutRestart int32  `json:"containersWithoutRestart"`
 }
+
+type SystemComponent struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Pods      []PodRef `json:"pods"`
+}
+
+type ClusterCapabilities struct {
+	ServerVersion      string `json:"serverVersion"`
+	SCTP               bool   `json:"sctp"`
+	EndPort            bool   `json:"endPort"`
+	AdminNetworkPolicy bool   `json:"adminNetworkPolicy"`
+	CalicoPolicies     bool   `json:"calicoPolicies"`
+	CiliumPolicies     bool   `json:"ciliumPolicies"`
+}
+
+type RouteVerification struct {
+	SourcePod  PodRef    `json:"sourcePod"`
+	TargetPod  PodRef    `json:"targetPod"`
+	Port       int32     `json:"port"`
+	Allowed    bool      `json:"allowed"`
+	Reachable  bool      `json:"reachable"`
+	Status     string    `json:"status"`
+	Message    string    `json:"message"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// ConnectivityQuery asks whether the source pod may reach the port of the target pod, any port when zero, over the
+// protocol, TCP when empty
+type ConnectivityQuery struct {
+	SourcePod PodRef          `json:"sourcePod"`
+	TargetPod PodRef          `json:"targetPod"`
+	Port      int32           `json:"port"`
+	Protocol  corev1.Protocol `json:"protocol"`
+}
+
+type ConnectivityVerdict struct {
+	Query   ConnectivityQuery `json:"query"`
+	Allowed bool              `json:"allowed"`
+	Error   string            `json:"error"`
+}
+
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type Finding struct {
+	Fingerprint string              `json:"fingerprint"`
+	Rule        string              `json:"rule"`
+	Severity    string              `json:"severity"`
+	Resource    ResourceRef         `json:"resource"`
+	Peer        *ResourceRef        `json:"peer"`
+	Message     string              `json:"message"`
+	Code        string              `json:"code"`
+	Parameters  map[string]string   `json:"parameters"`
+	Remediation *Remediation        `json:"remediation"`
+	Suppression *FindingSuppression `json:"suppression"`
+}
+
+type Remediation struct {
+	Operation string                      `json:"operation"`
+	Resource  ResourceRef                 `json:"resource"`
+	Manifest  *networkingv1.NetworkPolicy `json:"manifest"`
+}
+
+// ComplianceProfile holds the results of the checks of a hardening guidance, each failed check listing the
+// fingerprints of the findings it raised
+type ComplianceProfile struct {
+	Profile string             `json:"profile"`
+	Title   string             `json:"title"`
+	Passed  int                `json:"passed"`
+	Failed  int                `json:"failed"`
+	Checks  []*ComplianceCheck `json:"checks"`
+}
+
+type ComplianceCheck struct {
+	Rule     string   `json:"rule"`
+	Title    string   `json:"title"`
+	Passed   bool     `json:"passed"`
+	Findings []string `json:"findings"`
+}
+
+type FindingSuppression struct {
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+type SavedView struct {
+	Name     string          `json:"name"`
+	Controls json.RawMessage `json:"controls"`
+	SavedAt  time.Time       `json:"savedAt"`
+}
+
+type AuditEntry struct {
+	Time          time.Time `json:"time"`
+	Caller        string    `json:"caller"`
+	RemoteAddress string    `json:"remoteAddress"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	PayloadHash   string    `json:"payloadHash,omitempty"`
+	StatusCode    int       `json:"statusCode"`
+}
+
+type TighteningSuggestion struct {
+	Policy          NetworkPolicy               `json:"policy"`
+	Reasons         []string                    `json:"reasons"`
+	SuggestedPolicy *networkingv1.NetworkPolicy `json:"suggestedPolicy"`
+}
+
+// RouteDrift lists the ports of a route which are only allowed on one side, laid out as on the allowed routes
+type RouteDrift struct {
+	Source          ResourceRef     `json:"source"`
+	Target          ResourceRef     `json:"target"`
+	Ports           []int32         `json:"ports"`
+	PortRanges      []PortRange     `json:"portRanges"`
+	ProtocolPorts   []ProtocolPorts `json:"protocolPorts"`
+	IngressPolicies []NetworkPolicy `json:"ingressPolicies"`
+	EgressPolicies  []NetworkPolicy `json:"egressPolicies"`
+}
+
+type DriftReport struct {
+	LiveOnly    []*RouteDrift `json:"liveOnly"`
+	DesiredOnly []*RouteDrift `json:"desiredOnly"`
+	ComputedAt  time.Time     `json:"computedAt"`
+}
@@ -0,0 +1,474 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"karto/types"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	probeLabel          = "karto.zenika.com/probe"
+	probeContainerName  = "probe"
+	probeImage          = "busybox:1.36"
+	probeConnectTimeout = "3"
+	probePollInterval   = time.Second
+	probeTimeout        = 2 * time.Minute
+)
+
+// The probe exits with probeRefusedExitCode when the target pod refuses the connection, as it does when nothing listens
+// to the port, which tells that the traffic reached it while policies drop denied traffic silently
+const (
+	probeRefusedExitCode = 2
+	probeScript          = "output=$(nc -z -w " + probeConnectTimeout + " \"$1\" \"$2\" 2>&1) && exit 0\n" +
+		"case \"$output\" in *refused*) exit 2;; esac\n" +
+		"exit 1\n"
+)
+
+const (
+	StatusConfirmed    = "confirmed"
+	StatusContradicted = "contradicted"
+	StatusInconclusive = "inconclusive"
+	StatusError        = "error"
+)
+
+type Options struct {
+	Enabled    bool
+	Interval   time.Duration
+	SampleSize int
+	ProbeImage string
+}
+
+type probe struct {
+	sourcePod types.PodRef
+	targetPod types.PodRef
+	port      int32
+	allowed   bool
+}
+
+// probeOutcome is the result of a probe, along with the labels of the source pod which the probe did not carry as they
+// are selected by services, and those of them also selected by network policies
+type probeOutcome struct {
+	port           int32
+	reachable      bool
+	refused        bool
+	strippedLabels []string
+	policyLabels   []string
+}
+
+type verifier struct {
+	k8sClient          kubernetes.Interface
+	options            Options
+	random             *rand.Rand
+	mutex              sync.Mutex
+	lastAnalysisResult *types.AnalysisResult
+	verifications      []*types.RouteVerification
+}
+
+func newVerifier(k8sClient kubernetes.Interface, options Options) *verifier {
+	return &verifier{
+		k8sClient:     k8sClient,
+		options:       options,
+		random:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		verifications: make([]*types.RouteVerification, 0),
+	}
+}
+
+func Verify(k8sClient kubernetes.Interface, options Options, resultsChannel <-chan types.AnalysisResult,
+	verifiedResultsChannel chan<- types.AnalysisResult) {
+	verifier := newVerifier(k8sClient, options)
+	go verifier.verifyPeriodically(verifiedResultsChannel)
+	for {
+		analysisResult := <-resultsChannel
+		verifier.mutex.Lock()
+		verifier.lastAnalysisResult = &analysisResult
+		verifiedResultsChannel <- verifier.withVerifications(analysisResult)
+		verifier.mutex.Unlock()
+	}
+}
+
+func (verifier *verifier) verifyPeriodically(verifiedResultsChannel chan<- types.AnalysisResult) {
+	for {
+		time.Sleep(verifier.options.Interval)
+		verifier.mutex.Lock()
+		lastAnalysisResult := verifier.lastAnalysisResult
+		verifier.mutex.Unlock()
+		if lastAnalysisResult == nil {
+			continue
+		}
+		verifications := verifier.runProbes(verifier.sampleProbes(*lastAnalysisResult))
+		verifier.mutex.Lock()
+		verifier.verifications = verifications
+		verifiedResultsChannel <- verifier.withVerifications(*verifier.lastAnalysisResult)
+		verifier.mutex.Unlock()
+	}
+}
+
+func (verifier *verifier) withVerifications(analysisResult types.AnalysisResult) types.AnalysisResult {
+	analysisResult.RouteVerifications = verifier.verifications
+	return analysisResult
+}
+
+func (verifier *verifier) sampleProbes(analysisResult types.AnalysisResult) []probe {
+	probes := make([]probe, 0)
+	allowedPairs := make(map[types.PodRef]map[types.PodRef]bool)
+	for _, allowedRoute := range analysisResult.AllowedRoutes {
+		if allowedPairs[allowedRoute.SourcePod] == nil {
+			allowedPairs[allowedRoute.SourcePod] = make(map[types.PodRef]bool)
+		}
+		allowedPairs[allowedRoute.SourcePod][allowedRoute.TargetPod] = true
+	}
+	for _, i := range verifier.random.Perm(len(analysisResult.AllowedRoutes)) {
+		if len(probes) >= verifier.options.SampleSize {
+			break
+		}
+		allowedRoute := analysisResult.AllowedRoutes[i]
+		port, ok := verifier.tcpPortOf(allowedRoute)
+		if !ok {
+			continue
+		}
+		probes = append(probes, probe{sourcePod: allowedRoute.SourcePod, targetPod: allowedRoute.TargetPod,
+			port: port, allowed: true})
+	}
+	pods := analysisResult.Pods
+	deniedProbes := 0
+	for _, i := range verifier.random.Perm(len(pods)) {
+		if deniedProbes >= verifier.options.SampleSize {
+			break
+		}
+		sourcePod := verifier.toPodRef(pods[i])
+		for _, j := range verifier.random.Perm(len(pods)) {
+			targetPod := verifier.toPodRef(pods[j])
+			if i == j || allowedPairs[sourcePod][targetPod] {
+				continue
+			}
+			// At most one denied route is probed per source pod to spread the sample
+			probes = append(probes, probe{sourcePod: sourcePod, targetPod: targetPod, allowed: false})
+			deniedProbes++
+			break
+		}
+	}
+	return probes
+}
+
+func (verifier *verifier) runProbes(probes []probe) []*types.RouteVerification {
+	verifications := make([]*types.RouteVerification, 0)
+	for _, probe := range probes {
+		verification := &types.RouteVerification{
+			SourcePod:  probe.sourcePod,
+			TargetPod:  probe.targetPod,
+			Port:       probe.port,
+			Allowed:    probe.allowed,
+			VerifiedAt: time.Now(),
+		}
+		outcome, err := verifier.runProbe(probe)
+		verification.Port = outcome.port
+		if err != nil {
+			log.Printf("Unable to verify route from %s/%s to %s/%s: %s\n", probe.sourcePod.Namespace,
+				probe.sourcePod.Name, probe.targetPod.Namespace, probe.targetPod.Name, err)
+			verification.Status = StatusError
+			verification.Message = err.Error()
+		} else {
+			verification.Reachable = outcome.reachable
+			verification.Status, verification.Message = verifier.statusOf(probe, outcome)
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications
+}
+
+// statusOf only contradicts the analysis on the ports named by the route, with a probe having the identity of the
+// source pod regarding network policies. A probe lacking labels selected by policies, or a port of the target pod
+// probed for want of one named by the route, leaves the verification inconclusive.
+func (verifier *verifier) statusOf(probe probe, outcome probeOutcome) (string, string) {
+	messages := make([]string, 0)
+	if outcome.refused {
+		messages = append(messages, "the connection was refused by the target pod, which it therefore reached")
+	}
+	status := StatusConfirmed
+	if outcome.reachable != probe.allowed {
+		status = StatusContradicted
+		if probe.port == 0 {
+			status = StatusInconclusive
+			messages = append(messages, fmt.Sprintf("port %d was probed for want of a port named by the route",
+				outcome.port))
+		}
+	}
+	if len(outcome.policyLabels) > 0 {
+		status = StatusInconclusive
+		messages = append(messages, fmt.Sprintf(
+			"the probe did not carry the labels %s selected by services and network policies",
+			strings.Join(outcome.policyLabels, ", ")))
+	} else if len(outcome.strippedLabels) > 0 {
+		messages = append(messages, fmt.Sprintf("the probe did not carry the labels %s selected by services",
+			strings.Join(outcome.strippedLabels, ", ")))
+	}
+	return status, strings.Join(messages, "; ")
+}
+
+// runProbe tells whether the probe reached the target pod, either connecting to it or being refused by it
+func (verifier *verifier) runProbe(probe probe) (probeOutcome, error) {
+	ctx := context.Background()
+	outcome := probeOutcome{port: probe.port}
+	sourcePod, err := verifier.k8sClient.CoreV1().Pods(probe.sourcePod.Namespace).Get(ctx, probe.sourcePod.Name,
+		metav1.GetOptions{})
+	if err != nil {
+		return outcome, err
+	}
+	targetPod, err := verifier.k8sClient.CoreV1().Pods(probe.targetPod.Namespace).Get(ctx, probe.targetPod.Name,
+		metav1.GetOptions{})
+	if err != nil {
+		return outcome, err
+	}
+	if targetPod.Status.PodIP == "" {
+		return outcome, fmt.Errorf("target pod has no IP")
+	}
+	if outcome.port == 0 {
+		outcome.port = verifier.firstTCPContainerPort(targetPod)
+		if outcome.port == 0 {
+			return outcome, fmt.Errorf("target pod does not declare any TCP port to probe")
+		}
+	}
+	var targetNode *corev1.Node
+	if targetPod.Spec.NodeName != "" {
+		targetNode, err = verifier.k8sClient.CoreV1().Nodes().Get(ctx, targetPod.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			// The probe can still be scheduled, only without any placement hint
+			log.Printf("Unable to get node %s of pod %s/%s: %s\n", targetPod.Spec.NodeName, targetPod.Namespace,
+				targetPod.Name, err)
+			targetNode = nil
+		}
+	}
+	services, err := verifier.k8sClient.CoreV1().Services(sourcePod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return outcome, err
+	}
+	probeLabels, strippedLabels := verifier.probeLabelsFor(sourcePod, services.Items)
+	if len(strippedLabels) > 0 {
+		policies, err := verifier.k8sClient.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx,
+			metav1.ListOptions{})
+		if err != nil {
+			return outcome, err
+		}
+		outcome.policyLabels = verifier.labelsSelectedByPolicies(strippedLabels, sourcePod.Namespace, policies.Items)
+	}
+	probePod, err := verifier.k8sClient.CoreV1().Pods(sourcePod.Namespace).Create(ctx,
+		verifier.probePodFor(sourcePod, probeLabels, targetNode, targetPod.Status.PodIP, outcome.port),
+		metav1.CreateOptions{})
+	if err != nil {
+		return outcome, err
+	}
+	defer func() {
+		err := verifier.k8sClient.CoreV1().Pods(probePod.Namespace).Delete(ctx, probePod.Name,
+			metav1.DeleteOptions{})
+		if err != nil {
+			log.Printf("Unable to delete probe pod %s/%s: %s\n", probePod.Namespace, probePod.Name, err)
+		}
+	}()
+	var currentProbePod *corev1.Pod
+	err = wait.PollImmediate(probePollInterval, probeTimeout, func() (bool, error) {
+		var err error
+		currentProbePod, err = verifier.k8sClient.CoreV1().Pods(probePod.Namespace).Get(ctx, probePod.Name,
+			metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase := currentProbePod.Status.Phase
+		return phase == corev1.PodSucceeded || phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return outcome, err
+	}
+	outcome.refused = verifier.exitCodeOf(currentProbePod) == probeRefusedExitCode
+	outcome.reachable = currentProbePod.Status.Phase == corev1.PodSucceeded || outcome.refused
+	outcome.strippedLabels = strippedLabels
+	return outcome, nil
+}
+
+func (verifier *verifier) exitCodeOf(probePod *corev1.Pod) int32 {
+	for _, containerStatus := range probePod.Status.ContainerStatuses {
+		if containerStatus.Name == probeContainerName && containerStatus.State.Terminated != nil {
+			return containerStatus.State.Terminated.ExitCode
+		}
+	}
+	return 0
+}
+
+// labelsSelectedByPolicies returns the labels which the selectors of network policies may match the source pod on:
+// the pod selectors of the policies of its namespace, and the pod selectors of the peers of any policy
+func (verifier *verifier) labelsSelectedByPolicies(labelKeys []string, namespace string,
+	policies []networkingv1.NetworkPolicy) []string {
+	selectedKeys := make(map[string]bool)
+	addKeys := func(selector *metav1.LabelSelector) {
+		if selector == nil {
+			return
+		}
+		for key := range selector.MatchLabels {
+			selectedKeys[key] = true
+		}
+		for _, requirement := range selector.MatchExpressions {
+			selectedKeys[requirement.Key] = true
+		}
+	}
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Namespace == namespace {
+			addKeys(&policy.Spec.PodSelector)
+		}
+		for _, rule := range policy.Spec.Ingress {
+			for _, peer := range rule.From {
+				addKeys(peer.PodSelector)
+			}
+		}
+		for _, rule := range policy.Spec.Egress {
+			for _, peer := range rule.To {
+				addKeys(peer.PodSelector)
+			}
+		}
+	}
+	result := make([]string, 0)
+	for _, key := range labelKeys {
+		if selectedKeys[key] {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// probeLabelsFor returns the labels of the source pod, which are its identity regarding network policies, except those
+// selected by the services of the source pod, which would otherwise send their traffic to the probe. The stripped
+// labels are returned sorted.
+func (verifier *verifier) probeLabelsFor(sourcePod *corev1.Pod, services []corev1.Service) (map[string]string,
+	[]string) {
+	probeLabels := map[string]string{probeLabel: "true"}
+	for key, value := range sourcePod.Labels {
+		probeLabels[key] = value
+	}
+	strippedLabels := make([]string, 0)
+	for _, service := range services {
+		if len(service.Spec.Selector) == 0 ||
+			!labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(sourcePod.Labels)) {
+			continue
+		}
+		for key := range service.Spec.Selector {
+			if _, ok := probeLabels[key]; ok {
+				delete(probeLabels, key)
+				strippedLabels = append(strippedLabels, key)
+			}
+		}
+	}
+	sort.Strings(strippedLabels)
+	return probeLabels, strippedLabels
+}
+
+func (verifier *verifier) probePodFor(sourcePod *corev1.Pod, probeLabels map[string]string, targetNode *corev1.Node,
+	targetIP string, port int32) *corev1.Pod {
+	isController := true
+	automountServiceAccountToken := false
+	image := verifier.options.ProbeImage
+	if image == "" {
+		image = probeImage
+	}
+	nodeSelector, tolerations := verifier.placementOn(targetNode)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "karto-probe-",
+			Namespace:    sourcePod.Namespace,
+			Labels:       probeLabels,
+			// Being controlled by the source pod prevents the probe from being adopted by the source's controller
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "Pod", Name: sourcePod.Name, UID: sourcePod.UID, Controller: &isController},
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                corev1.RestartPolicyNever,
+			AutomountServiceAccountToken: &automountServiceAccountToken,
+			NodeSelector:                 nodeSelector,
+			Tolerations:                  tolerations,
+			Containers: []corev1.Container{
+				{
+					Name:    probeContainerName,
+					Image:   image,
+					Command: []string{"sh", "-c", probeScript, "probe", targetIP, strconv.Itoa(int(port))},
+				},
+			},
+		},
+	}
+}
+
+// The probe runs on a node of the same OS and architecture as the target's one, tolerating its taints, so that it
+// can be scheduled next to workloads of tainted or arm64 nodes
+func (verifier *verifier) placementOn(node *corev1.Node) (map[string]string, []corev1.Toleration) {
+	if node == nil {
+		return nil, nil
+	}
+	nodeSelector := make(map[string]string)
+	for _, label := range []string{corev1.LabelOSStable, corev1.LabelArchStable} {
+		if value, ok := node.Labels[label]; ok {
+			nodeSelector[label] = value
+		}
+	}
+	tolerations := make([]corev1.Toleration, 0)
+	for _, taint := range node.Spec.Taints {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      taint.Key,
+			Operator: corev1.TolerationOpExists,
+			Effect:   taint.Effect,
+		})
+	}
+	return nodeSelector, tolerations
+}
+
+// tcpPortOf returns the first TCP port of the route, as probes connect over TCP, 0 when all its TCP ports are allowed.
+// Routes allowed over other protocols only cannot be probed.
+func (verifier *verifier) tcpPortOf(allowedRoute *types.AllowedRoute) (int32, bool) {
+	ports, portRanges := allowedRoute.Ports, allowedRoute.PortRanges
+	if allowedRoute.ProtocolPorts != nil {
+		found := false
+		for _, protocolPorts := range allowedRoute.ProtocolPorts {
+			if protocolPorts.Protocol == corev1.ProtocolTCP {
+				ports, portRanges, found = protocolPorts.Ports, protocolPorts.PortRanges, true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	if len(ports) > 0 {
+		return ports[0], true
+	}
+	if len(portRanges) > 0 {
+		return portRanges[0].Port, true
+	}
+	return 0, true
+}
+
+func (verifier *verifier) firstTCPContainerPort(pod *corev1.Pod) int32 {
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			if containerPort.Protocol == "" || containerPort.Protocol == corev1.ProtocolTCP {
+				return containerPort.ContainerPort
+			}
+		}
+	}
+	return 0
+}
+
+func (verifier *verifier) toPodRef(pod *types.Pod) types.PodRef {
+	return types.PodRef{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+	}
+}
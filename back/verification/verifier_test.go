@@ -0,0 +1,377 @@
+package verification
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"karto/testutils"
+	"karto/types"
+	"testing"
+)
+
+func TestSampleProbes(t *testing.T) {
+	type args struct {
+		analysisResult types.AnalysisResult
+		sampleSize     int
+	}
+	pod1 := &types.Pod{Name: "pod1", Namespace: "ns"}
+	pod2 := &types.Pod{Name: "pod2", Namespace: "ns"}
+	podRef1 := types.PodRef{Name: "pod1", Namespace: "ns"}
+	podRef2 := types.PodRef{Name: "pod2", Namespace: "ns"}
+	tests := []struct {
+		name           string
+		args           args
+		expectedProbes []probe
+	}{
+		{
+			name: "samples allowed routes on their first port and denied routes between other pods",
+			args: args{
+				analysisResult: types.AnalysisResult{
+					Pods: []*types.Pod{pod1, pod2},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{443, 8080}},
+					},
+				},
+				sampleSize: 1,
+			},
+			expectedProbes: []probe{
+				{sourcePod: podRef1, targetPod: podRef2, port: 443, allowed: true},
+				{sourcePod: podRef2, targetPod: podRef1, port: 0, allowed: false},
+			},
+		},
+		{
+			name: "routes allowed on all ports are probed without a port",
+			args: args{
+				analysisResult: types.AnalysisResult{
+					Pods: []*types.Pod{pod1, pod2},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: podRef1, TargetPod: podRef2, Ports: nil},
+						{SourcePod: podRef2, TargetPod: podRef1, Ports: nil},
+					},
+				},
+				sampleSize: 1,
+			},
+			expectedProbes: []probe{
+				{sourcePod: podRef1, targetPod: podRef2, port: 0, allowed: true},
+			},
+		}, {
+			name: "routes are probed on their TCP ports only",
+			args: args{
+				analysisResult: types.AnalysisResult{
+					Pods: []*types.Pod{pod1, pod2},
+					AllowedRoutes: []*types.AllowedRoute{
+						{SourcePod: podRef1, TargetPod: podRef2, Ports: []int32{53, 80},
+							ProtocolPorts: []types.ProtocolPorts{
+								{Protocol: corev1.ProtocolTCP, Ports: []int32{80}},
+								{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+							}},
+						{SourcePod: podRef2, TargetPod: podRef1, Ports: []int32{53},
+							ProtocolPorts: []types.ProtocolPorts{
+								{Protocol: corev1.ProtocolUDP, Ports: []int32{53}},
+							}},
+					},
+				},
+				sampleSize: 2,
+			},
+			expectedProbes: []probe{
+				{sourcePod: podRef1, targetPod: podRef2, port: 80, allowed: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newVerifier(fake.NewSimpleClientset(), Options{SampleSize: tt.args.sampleSize})
+			probes := verifier.sampleProbes(tt.args.analysisResult)
+			if len(probes) != len(tt.expectedProbes) {
+				t.Fatalf("sampleProbes() returned %d probes, expected %d", len(probes), len(tt.expectedProbes))
+			}
+			if tt.expectedProbes[0].allowed && probes[0].sourcePod != tt.expectedProbes[0].sourcePod {
+				// Allowed routes are sampled randomly, only the shape of the probe is relevant
+				probes[0].sourcePod, probes[0].targetPod = probes[0].targetPod, probes[0].sourcePod
+			}
+			if diff := cmp.Diff(tt.expectedProbes, probes, cmp.AllowUnexported(probe{})); diff != "" {
+				t.Errorf("sampleProbes() result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunProbes(t *testing.T) {
+	type args struct {
+		pods          []*corev1.Pod
+		services      []*corev1.Service
+		policies      []*networkingv1.NetworkPolicy
+		probes        []probe
+		probeSucceeds bool
+		probeRefused  bool
+	}
+	sourcePod := testutils.NewPodBuilder().WithName("source").WithNamespace("ns").WithLabel("app", "front").
+		WithIP("10.0.0.1").Build()
+	targetPod := testutils.NewPodBuilder().WithName("target").WithNamespace("ns").
+		WithContainerPort("http", 8080).WithIP("10.0.0.2").Build()
+	targetPodWithoutIP := testutils.NewPodBuilder().WithName("target").WithNamespace("ns").
+		WithContainerPort("http", 8080).Build()
+	sourcePodRef := types.PodRef{Name: "source", Namespace: "ns"}
+	targetPodRef := types.PodRef{Name: "target", Namespace: "ns"}
+	tests := []struct {
+		name                  string
+		args                  args
+		expectedVerifications []*types.RouteVerification
+	}{
+		{
+			name: "an allowed route which can be connected to is confirmed",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPod},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: true,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: true,
+					Status: StatusConfirmed},
+			},
+		},
+		{
+			name: "a denied route which can be connected to on the first container port is inconclusive",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPod},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 0, allowed: false}},
+				probeSucceeds: true,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 8080, Allowed: false, Reachable: true,
+					Status: StatusInconclusive, Message: "port 8080 was probed for want of a port named by the route"},
+			},
+		},
+		{
+			name: "a denied route which the first container port does not accept is confirmed",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPod},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 0, allowed: false}},
+				probeSucceeds: false,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 8080, Allowed: false, Reachable: false,
+					Status: StatusConfirmed},
+			},
+		},
+		{
+			name: "an allowed route whose connection is refused by the target pod is confirmed",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPod},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: false,
+				probeRefused:  true,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: true,
+					Status:  StatusConfirmed,
+					Message: "the connection was refused by the target pod, which it therefore reached"},
+			},
+		},
+		{
+			name: "an allowed route which cannot be connected to is contradicted",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPod},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: false,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: false,
+					Status: StatusContradicted},
+			},
+		},
+		{
+			name: "a probe does not carry the labels of the source pod selected by services",
+			args: args{
+				pods: []*corev1.Pod{sourcePod, targetPod},
+				services: []*corev1.Service{
+					testutils.NewServiceBuilder().WithName("front").WithNamespace("ns").WithSelectorLabel("app", "front").
+						Build(),
+				},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: true,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: true,
+					Status: StatusConfirmed, Message: "the probe did not carry the labels app selected by services"},
+			},
+		},
+		{
+			name: "a probe without the labels of the source pod selected by policies is inconclusive",
+			args: args{
+				pods: []*corev1.Pod{sourcePod, targetPod},
+				services: []*corev1.Service{
+					testutils.NewServiceBuilder().WithName("front").WithNamespace("ns").WithSelectorLabel("app", "front").
+						Build(),
+				},
+				policies: []*networkingv1.NetworkPolicy{
+					testutils.NewNetworkPolicyBuilder().WithName("target-ingress").WithNamespace("ns").
+						WithIngressRule(networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+							{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}}},
+						}}).Build(),
+				},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: false,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: false,
+					Status:  StatusInconclusive,
+					Message: "the probe did not carry the labels app selected by services and network policies"},
+			},
+		},
+		{
+			name: "a route to a pod without IP cannot be verified",
+			args: args{
+				pods:          []*corev1.Pod{sourcePod, targetPodWithoutIP},
+				probes:        []probe{{sourcePod: sourcePodRef, targetPod: targetPodRef, port: 80, allowed: true}},
+				probeSucceeds: true,
+			},
+			expectedVerifications: []*types.RouteVerification{
+				{SourcePod: sourcePodRef, TargetPod: targetPodRef, Port: 80, Allowed: true, Reachable: false,
+					Status: StatusError, Message: "target pod has no IP"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0)
+			for _, pod := range tt.args.pods {
+				objects = append(objects, pod)
+			}
+			for _, service := range tt.args.services {
+				objects = append(objects, service)
+			}
+			for _, policy := range tt.args.policies {
+				objects = append(objects, policy)
+			}
+			k8sClient := fake.NewSimpleClientset(objects...)
+			k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				probePod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+				probePod.Name = probePod.GenerateName + "test"
+				if tt.args.probeSucceeds {
+					probePod.Status.Phase = corev1.PodSucceeded
+				} else {
+					probePod.Status.Phase = corev1.PodFailed
+				}
+				if tt.args.probeRefused {
+					probePod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: probeContainerName,
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: probeRefusedExitCode}}}}
+				}
+				return false, nil, nil
+			})
+			verifier := newVerifier(k8sClient, Options{})
+			verifications := verifier.runProbes(tt.args.probes)
+			if diff := cmp.Diff(tt.expectedVerifications, verifications,
+				cmpopts.IgnoreFields(types.RouteVerification{}, "VerifiedAt")); diff != "" {
+				t.Errorf("runProbes() result mismatch (-want +got):\n%s", diff)
+			}
+			remainingPods, _ := k8sClient.CoreV1().Pods("ns").List(context.Background(), metav1.ListOptions{})
+			if diff := cmp.Diff(len(tt.args.pods), len(remainingPods.Items)); diff != "" {
+				t.Errorf("probe pods were not cleaned up (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProbeLabelsFor(t *testing.T) {
+	sourcePod := testutils.NewPodBuilder().WithName("source").WithNamespace("ns").WithLabel("app", "front").
+		WithLabel("tier", "web").WithLabel("team", "shop").Build()
+	tests := []struct {
+		name                   string
+		services               []corev1.Service
+		expectedLabels         map[string]string
+		expectedStrippedLabels []string
+	}{
+		{
+			name:     "the probe carries the labels of the source pod",
+			services: []corev1.Service{},
+			expectedLabels: map[string]string{probeLabel: "true", "app": "front", "tier": "web",
+				"team": "shop"},
+			expectedStrippedLabels: []string{},
+		},
+		{
+			name: "the labels selected by the services of the source pod are stripped",
+			services: []corev1.Service{
+				*testutils.NewServiceBuilder().WithName("front").WithSelectorLabel("app", "front").
+					WithSelectorLabel("tier", "web").Build(),
+				*testutils.NewServiceBuilder().WithName("back").WithSelectorLabel("app", "back").
+					WithSelectorLabel("team", "shop").Build(),
+				*testutils.NewServiceBuilder().WithName("external").Build(),
+			},
+			expectedLabels:         map[string]string{probeLabel: "true", "team": "shop"},
+			expectedStrippedLabels: []string{"app", "tier"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newVerifier(fake.NewSimpleClientset(), Options{})
+			probeLabels, strippedLabels := verifier.probeLabelsFor(sourcePod, tt.services)
+			if diff := cmp.Diff(tt.expectedLabels, probeLabels); diff != "" {
+				t.Errorf("probeLabelsFor() labels mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedStrippedLabels, strippedLabels); diff != "" {
+				t.Errorf("probeLabelsFor() stripped labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProbePodFor(t *testing.T) {
+	type args struct {
+		options    Options
+		targetNode *corev1.Node
+	}
+	sourcePod := testutils.NewPodBuilder().WithName("source").WithNamespace("ns").Build()
+	tests := []struct {
+		name                 string
+		args                 args
+		expectedImage        string
+		expectedNodeSelector map[string]string
+		expectedTolerations  []corev1.Toleration
+	}{
+		{
+			name: "the probe is free to run anywhere when the target node is unknown",
+			args: args{
+				options:    Options{},
+				targetNode: nil,
+			},
+			expectedImage: probeImage,
+		},
+		{
+			name: "the probe runs on the OS and architecture of the target node and tolerates its taints",
+			args: args{
+				options: Options{ProbeImage: "registry.example.com/busybox:1.36"},
+				targetNode: testutils.NewNodeBuilder().WithName("node").WithLabel("kubernetes.io/os", "linux").
+					WithLabel("kubernetes.io/arch", "arm64").WithLabel("other", "value").
+					WithTaint("dedicated", corev1.TaintEffectNoSchedule).Build(),
+			},
+			expectedImage:        "registry.example.com/busybox:1.36",
+			expectedNodeSelector: map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "arm64"},
+			expectedTolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newVerifier(fake.NewSimpleClientset(), tt.args.options)
+			probePod := verifier.probePodFor(sourcePod, map[string]string{}, tt.args.targetNode, "10.0.0.2", 80)
+			if diff := cmp.Diff(tt.expectedImage, probePod.Spec.Containers[0].Image); diff != "" {
+				t.Errorf("probePodFor() image mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedNodeSelector, probePod.Spec.NodeSelector); diff != "" {
+				t.Errorf("probePodFor() node selector mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedTolerations, probePod.Spec.Tolerations); diff != "" {
+				t.Errorf("probePodFor() tolerations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -118,6 +118,7 @@ const Content = ({ className }) => {
     const allLabels = state.analysisResult ? state.analysisResult.allLabels : {};
 
     useEffect(() => {
+        let timeout = null;
         const fetchAndUpdate = async () => {
             const analysisResult = await fetchAnalysisResult();
             setState(oldState => ({
@@ -126,15 +127,25 @@ const Content = ({ className }) => {
                 analysisResult: analysisResult,
                 dataSet: computeDataSet(analysisResult, oldState.controls)
             }));
+            return analysisResult;
+        };
+        // The first analysis of a large cluster is published namespace by namespace until it completes
+        const fetchUntilComplete = async () => {
+            const analysisResult = await fetchAndUpdate();
+            if (analysisResult && analysisResult.complete === false) {
+                timeout = setTimeout(fetchUntilComplete, 2000);
+            }
         };
-        fetchAndUpdate();
 
         if (state.controls.autoRefresh) {
+            fetchAndUpdate();
             const interval = setInterval(() => {
                 fetchAndUpdate();
             }, 2000);
             return () => clearInterval(interval);
         }
+        fetchUntilComplete();
+        return () => clearTimeout(timeout);
     }, [state.controls.autoRefresh]);
 
     useEffect(() => {
@@ -226,6 +237,11 @@ const Content = ({ className }) => {
                         Analyzing your cluster...
                     </Typography>
                 </>}
+                {!state.isLoading && state.analysisResult && state.analysisResult.complete === false && <>
+                    <Typography className={classes.message} variant="caption">
+                        {`Analyzing your cluster... ${state.analysisResult.progress}% of the namespaces are displayed`}
+                    </Typography>
+                </>}
                 {!state.isLoading && state.dataSet && state.dataSet.pods.length === 0 && <>
                     <Typography className={classes.message} variant="caption">
                         No pod to display
@@ -1,5 +1,5 @@
 export async function fetchAnalysisResult() {
-    const response = await fetch('./api/analysisResult');
+    const response = await fetch('./api/analysisResult?groupSystemComponents=true');
     if (response.status !== 200) {
         console.error(`Could not fetch analysis result: error code : ${response.status}`);
         return;